@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// errBarrierFailed is why statements after a barrier were not run
+var errBarrierFailed = errors.New("not run: a statement before a barrier failed")
+
+// phaseFailed reports whether any instance failed a statement, or produced no
+// results at all, during a phase
+func phaseFailed(instanceList []string, phaseResults map[string][]db.QueryResult) bool {
+	for _, instanceDSN := range instanceList {
+		results, ok := phaseResults[instanceDSN]
+		if !ok {
+			return true
+		}
+		for _, res := range results {
+			if res.Err != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+// execution returns the first execution of query on srv
+func execution(t *testing.T, srv *dbtest.Server, query string) dbtest.Execution {
+	t.Helper()
+	for _, e := range srv.Executions() {
+		if e.Query == query {
+			return e
+		}
+	}
+	t.Fatalf("%s never executed %q (executed %v)", srv.Host, query, srv.Executed())
+	return dbtest.Execution{}
+}
+
+func TestExecuteQueries_BarrierWaitsForSlowInstances(t *testing.T) {
+	useFakeDriver(t)
+
+	const alter, backfill = "ALTER TABLE t ADD c INT", "UPDATE t SET c = 1"
+	newServers := func(prefix string) (fast, slow *dbtest.Server) {
+		fast = dbtest.NewServer(t, prefix+"-fast")
+		slow = dbtest.NewServer(t, prefix+"-slow")
+		slow.Handle(alter, dbtest.Response{Delay: 150 * time.Millisecond})
+		return fast, slow
+	}
+
+	// Without a barrier the fast instance moves on while the slow one is still altering
+	fast, slow := newServers("nobarrier")
+	config := &Config{Concurrent: true, output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, []string{fast.DSN(), slow.DSN()}, alter+";\n"+backfill); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	if !execution(t, fast, backfill).Started.Before(execution(t, slow, alter).Finished) {
+		t.Fatal("control run: fast instance waited for the slow one even without a barrier")
+	}
+
+	// With a barrier no instance starts the backfill before every instance altered
+	fast, slow = newServers("barrier")
+	config = &Config{Concurrent: true, output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	sqls := alter + ";\n-- csql: barrier\n" + backfill
+	if err := executeQueries(context.Background(), config, []string{fast.DSN(), slow.DSN()}, sqls); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	slowAltered := execution(t, slow, alter).Finished
+	for _, srv := range []*dbtest.Server{fast, slow} {
+		if started := execution(t, srv, backfill).Started; started.Before(slowAltered) {
+			t.Errorf("%s started the backfill %v before the slow instance finished altering",
+				srv.Host, slowAltered.Sub(started))
+		}
+		if srv.Opened() != 1 {
+			t.Errorf("%s opened %d connections, want one session across the barrier", srv.Host, srv.Opened())
+		}
+	}
+}
+
+func TestExecuteQueries_BarrierAbortsAfterFailure(t *testing.T) {
+	useFakeDriver(t)
+
+	const before, after = "ALTER TABLE t ADD c INT", "UPDATE t SET c = 1"
+	sqls := before + ";\n-- csql: barrier\n" + after + ";\nSELECT 1"
+
+	tests := []struct {
+		name        string
+		ignore      bool
+		wantAfter   bool
+		wantSkipped int
+	}{
+		{name: "failure aborts the next phase", wantSkipped: 4},
+		{name: "--barrier-ignore-errors continues", ignore: true, wantAfter: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy := dbtest.NewServer(t, "abort-ok")
+			broken := dbtest.NewServer(t, "abort-broken")
+			broken.Handle(before, dbtest.Response{Err: errors.New("duplicate column name 'c'")})
+
+			var stdout, stderr bytes.Buffer
+			config := &Config{Concurrent: true, BarrierIgnoreErrors: tt.ignore, output: db.NewOutputSink(&stdout, &stderr)}
+			err := executeQueries(context.Background(), config, []string{healthy.DSN(), broken.DSN()}, sqls)
+
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) || exitErr.category != categoryQueryError {
+				t.Fatalf("executeQueries() error = %v, want a query-error exit", err)
+			}
+			for _, srv := range []*dbtest.Server{healthy, broken} {
+				ranAfter := strings.Contains(strings.Join(srv.Executed(), ";"), after)
+				if ranAfter != tt.wantAfter {
+					t.Errorf("%s ran %q = %t, want %t", srv.Host, after, ranAfter, tt.wantAfter)
+				}
+			}
+			if got := strings.Contains(stderr.String(), "skipping the remaining phases"); got != !tt.ignore {
+				t.Errorf("abort notice printed = %t, stderr:\n%s", got, stderr.String())
+			}
+			if tt.wantSkipped > 0 && !strings.Contains(exitErr.Error(), fmt.Sprintf("%d skipped", tt.wantSkipped)) {
+				t.Errorf("exit error = %v, want the %d post-barrier statements counted as skipped", exitErr, tt.wantSkipped)
+			}
+		})
+	}
+}
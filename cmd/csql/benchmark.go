@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// defaultBenchmarkIterations is how often --benchmark runs the statements without --iterations
+const defaultBenchmarkIterations = 10
+
+// latencyStats summarizes the latencies of repeated statement executions
+type latencyStats struct {
+	Count  int
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration // Sample standard deviation; zero for fewer than two samples
+	P50    time.Duration // Median, by the nearest-rank method
+	P95    time.Duration
+	P99    time.Duration
+}
+
+// computeLatencyStats summarizes durations; the zero value is returned for none
+func computeLatencyStats(durations []time.Duration) latencyStats {
+	if len(durations) == 0 {
+		return latencyStats{}
+	}
+	stats := latencyStats{Count: len(durations), Min: durations[0], Max: durations[0]}
+	var sum float64
+	for _, d := range durations {
+		stats.Min = min(stats.Min, d)
+		stats.Max = max(stats.Max, d)
+		sum += float64(d)
+	}
+	mean := sum / float64(len(durations))
+	stats.Mean = time.Duration(math.Round(mean))
+	if len(durations) > 1 {
+		var squares float64
+		for _, d := range durations {
+			squares += (float64(d) - mean) * (float64(d) - mean)
+		}
+		stats.StdDev = time.Duration(math.Round(math.Sqrt(squares / float64(len(durations)-1))))
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	stats.P50, stats.P95, stats.P99 = percentile(sorted, 50), percentile(sorted, 95), percentile(sorted, 99)
+	return stats
+}
+
+// percentile returns the nearest-rank pth percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// statementLatencies collects the successful durations and error count of one
+// statement on one instance
+type statementLatencies struct {
+	durations []time.Duration
+	errors    int
+}
+
+// benchmarkIterations returns how often --benchmark runs the statements
+func (c *Config) benchmarkIterations() int {
+	if c.Iterations > 0 {
+		return c.Iterations
+	}
+	return defaultBenchmarkIterations
+}
+
+// runBenchmark runs sqls repeatedly on every instance over one session each,
+// suppressing row output, and prints latency statistics per statement for each
+// instance and across the fleet. It returns every iteration's results.
+func (c *Config) runBenchmark(ctx context.Context, instanceList []string, sqls string, opts db.ExecOptions) map[string][]db.QueryResult {
+	iterations := c.benchmarkIterations()
+	allResults := make(map[string][]db.QueryResult)
+	var mu sync.Mutex
+
+	benchmarkInstance := func(instanceDSN string) {
+		var results []db.QueryResult
+		for i := 0; i < iterations && ctx.Err() == nil; i++ {
+			iteration := c.runInstance(ctx, instanceDSN, sqls, opts)
+			results = append(results, iteration...)
+			if connectFailed(iteration) {
+				break
+			}
+		}
+		mu.Lock()
+		allResults[instanceDSN] = results
+		mu.Unlock()
+	}
+
+	if c.Concurrent {
+		var wg sync.WaitGroup
+		for _, instanceDSN := range instanceList {
+			wg.Add(1)
+			go func(dsn string) {
+				defer wg.Done()
+				benchmarkInstance(dsn)
+			}(instanceDSN)
+		}
+		wg.Wait()
+	} else {
+		for _, instanceDSN := range instanceList {
+			benchmarkInstance(instanceDSN)
+		}
+	}
+
+	for _, instanceDSN := range instanceList {
+		for _, res := range allResults[instanceDSN] {
+			if res.Err != nil && !res.Skipped {
+				c.sink().Printf(db.StreamDiagnostics, "Error: %s: %s: %v\n", db.MaskDSN(instanceDSN), res.Statement, res.Err)
+				break // One error per instance is enough to diagnose it
+			}
+		}
+	}
+	_ = c.sink().Block(db.StreamResults, func(w io.Writer) {
+		writeBenchmarkReport(w, instanceList, allResults, iterations)
+	})
+	return allResults
+}
+
+// writeBenchmarkReport prints min/max/mean/stddev latency for each statement, per
+// instance and across all instances
+func writeBenchmarkReport(w io.Writer, instanceList []string, results map[string][]db.QueryResult, iterations int) {
+	// Statements in the order they first ran, each with its latencies per instance
+	var statements []string
+	latencies := make(map[string]map[string]*statementLatencies)
+	for _, instanceDSN := range instanceList {
+		for _, res := range results[instanceDSN] {
+			if res.Skipped || res.ConnectFailed {
+				continue
+			}
+			perInstance, seen := latencies[res.Statement]
+			if !seen {
+				statements = append(statements, res.Statement)
+				perInstance = make(map[string]*statementLatencies)
+				latencies[res.Statement] = perInstance
+			}
+			l := perInstance[instanceDSN]
+			if l == nil {
+				l = &statementLatencies{}
+				perInstance[instanceDSN] = l
+			}
+			if res.Err != nil {
+				l.errors++
+			} else {
+				l.durations = append(l.durations, res.Duration)
+			}
+		}
+	}
+
+	if len(statements) == 0 {
+		fmt.Fprintln(w, "Benchmark: no statements completed")
+		return
+	}
+	for _, stmt := range statements {
+		fmt.Fprintf(w, "Benchmark: %s (%d iteration(s))\n", stmt, iterations)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "instance\truns\tmin\tmax\tmean\tstddev\terrors")
+		var fleet []time.Duration
+		fleetErrors := 0
+		for _, instanceDSN := range instanceList {
+			l := latencies[stmt][instanceDSN]
+			if l == nil {
+				continue
+			}
+			writeLatencyRow(tw, db.MaskDSN(instanceDSN), computeLatencyStats(l.durations), l.errors)
+			fleet = append(fleet, l.durations...)
+			fleetErrors += l.errors
+		}
+		writeLatencyRow(tw, "fleet", computeLatencyStats(fleet), fleetErrors)
+		tw.Flush()
+		fmt.Fprintln(w, "---")
+	}
+}
+
+// writeLatencyRow writes one row of the benchmark table
+func writeLatencyRow(w io.Writer, label string, stats latencyStats, errors int) {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\t%d\n",
+		label, stats.Count, round(stats.Min), round(stats.Max), round(stats.Mean), round(stats.StdDev), errors)
+}
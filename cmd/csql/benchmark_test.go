@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestComputeLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      latencyStats
+	}{
+		{
+			name: "no samples",
+			want: latencyStats{},
+		},
+		{
+			name:      "one sample has no deviation",
+			durations: []time.Duration{3 * ms},
+			want:      latencyStats{Count: 1, Min: 3 * ms, Max: 3 * ms, Mean: 3 * ms, P50: 3 * ms, P95: 3 * ms, P99: 3 * ms},
+		},
+		{
+			name:      "identical samples",
+			durations: []time.Duration{5 * ms, 5 * ms, 5 * ms},
+			want:      latencyStats{Count: 3, Min: 5 * ms, Max: 5 * ms, Mean: 5 * ms, P50: 5 * ms, P95: 5 * ms, P99: 5 * ms},
+		},
+		{
+			// Sum of squared deviations 32ms², sample variance 32/7 ms²
+			name:      "known set",
+			durations: []time.Duration{2 * ms, 4 * ms, 4 * ms, 4 * ms, 5 * ms, 5 * ms, 7 * ms, 9 * ms},
+			want: latencyStats{Count: 8, Min: 2 * ms, Max: 9 * ms, Mean: 5 * ms, StdDev: 2138090 * time.Nanosecond,
+				P50: 4 * ms, P95: 9 * ms, P99: 9 * ms},
+		},
+		{
+			name:      "unsorted, rounded to whole nanoseconds",
+			durations: []time.Duration{10, 1, 2},
+			want:      latencyStats{Count: 3, Min: 1, Max: 10, Mean: 4, StdDev: 5, P50: 2, P95: 10, P99: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeLatencyStats(tt.durations); got != tt.want {
+				t.Errorf("computeLatencyStats(%v) = %+v, want %+v", tt.durations, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_Benchmark(t *testing.T) {
+	useFakeDriver(t)
+
+	const query, broken = "SELECT id FROM t WHERE id = 1", "SELECT nope"
+	fast := dbtest.NewServer(t, "bench-fast")
+	slow := dbtest.NewServer(t, "bench-slow")
+	for _, srv := range []*dbtest.Server{fast, slow} {
+		srv.Handle(query, dbtest.Response{Columns: []string{"id"}, Rows: [][]driver.Value{{"row-value"}}})
+	}
+	slow.Handle(query, dbtest.Response{Columns: []string{"id"}, Rows: [][]driver.Value{{"row-value"}}, Delay: 5 * time.Millisecond})
+	slow.Handle(broken, dbtest.Response{Err: errors.New("unknown column 'nope'")})
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Concurrent: true, Benchmark: true, Iterations: 4, output: db.NewOutputSink(&stdout, &stderr)}
+	err := executeQueries(context.Background(), config, []string{fast.DSN(), slow.DSN()}, query+";\n"+broken)
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryQueryError {
+		t.Fatalf("executeQueries() error = %v, want a query-error exit for the failing statement", err)
+	}
+	for _, srv := range []*dbtest.Server{fast, slow} {
+		if got := strings.Count(strings.Join(srv.Executed(), "\n"), query); got != 4 {
+			t.Errorf("%s ran the query %d times, want 4", srv.Host, got)
+		}
+		if srv.Opened() != 1 {
+			t.Errorf("%s opened %d connections, want one session for all iterations", srv.Host, srv.Opened())
+		}
+	}
+
+	out := stdout.String()
+	if strings.Contains(out, "row-value") {
+		t.Errorf("benchmark printed rows:\n%s", out)
+	}
+	lines := strings.Split(out, "\n")
+	fleet := ""
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Benchmark: "+query) && i+4 < len(lines) {
+			fleet = lines[i+4]
+		}
+	}
+	// Both instances ran the query 4 times, and the slowest run took at least the scripted delay
+	if fields := strings.Fields(fleet); len(fields) != 7 || fields[0] != "fleet" || fields[1] != "8" || fields[6] != "0" {
+		t.Fatalf("fleet row = %q, want 8 runs and no errors; output:\n%s", fleet, out)
+	} else if slowest, _ := time.ParseDuration(fields[3]); slowest < 5*time.Millisecond {
+		t.Errorf("fleet max = %v, want at least the 5ms delay", slowest)
+	}
+	if !strings.Contains(out, "Benchmark: "+broken) || !strings.Contains(stderr.String(), "unknown column 'nope'") {
+		t.Errorf("failing statement not reported; stdout:\n%s\nstderr:\n%s", out, stderr.String())
+	}
+}
+
+func TestExecuteQueries_BenchmarkReusesConnections(t *testing.T) {
+	useFakeDriver(t)
+
+	const iterations = 5
+	tests := []struct {
+		name   string
+		config Config
+		group  bool // The first instance is an unreachable group member backed by a second one
+	}{
+		{name: "sequential", config: Config{}},
+		{name: "concurrent", config: Config{Concurrent: true}},
+		{name: "pre-connect", config: Config{Concurrent: true, PreConnect: true}},
+		{name: "failover group", config: Config{Concurrent: true}, group: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := "bench-reuse-" + strings.ReplaceAll(tt.name, " ", "-")
+			servers := []*dbtest.Server{dbtest.NewServer(t, prefix+"-1"), dbtest.NewServer(t, prefix+"-2")}
+			instances := []string{servers[0].DSN(), servers[1].DSN()}
+
+			config := tt.config
+			config.Benchmark, config.Iterations = true, iterations
+			config.output = db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})
+			if tt.group {
+				down := dbtest.NewServer(t, prefix+"-down")
+				down.FailConnect(errors.New("connection refused"))
+				config.groups = map[string][]string{down.DSN(): {down.DSN(), servers[0].DSN()}}
+				instances[0] = down.DSN()
+			}
+
+			if err := executeQueries(context.Background(), &config, instances, "SELECT 1;\nSELECT 2"); err != nil {
+				t.Fatalf("executeQueries() error = %v", err)
+			}
+			for _, srv := range servers {
+				if got := len(srv.Executed()); got != 2*iterations {
+					t.Errorf("%s executed %d statements, want %d", srv.Host, got, 2*iterations)
+				}
+				if srv.Opened() != 1 {
+					t.Errorf("%s opened %d connections over %d iterations, want 1", srv.Host, srv.Opened(), iterations)
+				}
+			}
+		})
+	}
+}
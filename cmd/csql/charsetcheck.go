@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/fatih/color"
+)
+
+// charsetWarning makes a charset mismatch stand out among the other diagnostics
+var charsetWarning = color.New(color.FgRed, color.Bold)
+
+// verifiesCharset reports whether the run checks the sessions' character sets
+// before executing statements
+func (c *Config) verifiesCharset() bool {
+	return c.VerifyCharset || c.ExpectCharset != "" || c.StrictCharset
+}
+
+// verifyCharsets reads the character set variables of every instance's pooled
+// session before any statement runs and warns about each instance that differs
+// from --expect-charset or from the rest of the fleet. Instances that cannot be
+// checked are noted and left to the run. With --strict-charset a mismatch aborts
+// the run.
+func (c *Config) verifyCharsets(ctx context.Context, instanceList []string, opts db.ExecOptions) error {
+	charsets := make([]db.SessionCharset, len(instanceList))
+	var wg sync.WaitGroup
+	for i, instanceDSN := range instanceList {
+		wg.Add(1)
+		go func(i int, dsn string) {
+			defer wg.Done()
+			charsets[i] = c.pool.Charset(ctx, dsn, opts)
+		}(i, instanceDSN)
+	}
+	wg.Wait()
+
+	checked := 0
+	for _, cs := range charsets {
+		if cs.Err != nil {
+			c.infof("Charset check skipped on %s: %v\n", db.MaskDSN(cs.Instance), cs.Err)
+			continue
+		}
+		checked++
+	}
+
+	mismatches := db.CompareCharsets(charsets, c.ExpectCharset)
+	byInstance := make(map[string][]string)
+	for _, m := range mismatches {
+		byInstance[m.Instance] = append(byInstance[m.Instance], m.String())
+	}
+	for _, instanceDSN := range instanceList {
+		if found := byInstance[instanceDSN]; len(found) > 0 {
+			c.sink().Printf(db.StreamDiagnostics, "%s character set mismatch on %s: %s\n",
+				charsetWarning.Sprint("WARNING:"), db.MaskDSN(instanceDSN), strings.Join(found, ", "))
+		}
+	}
+
+	if len(byInstance) == 0 {
+		c.infof("Character sets match on %d instance(s)\n", checked)
+		return nil
+	}
+	if c.StrictCharset {
+		return fmt.Errorf("--strict-charset: %d of %d instance(s) have mismatched character sets; no statements were executed",
+			len(byInstance), len(instanceList))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestExecuteQueries_StrictCharset(t *testing.T) {
+	useFakeDriver(t)
+	const charsetQuery = "SELECT @@character_set_client, @@character_set_connection, @@character_set_results, @@collation_connection"
+	const update = "UPDATE t SET name = 'Zoë'"
+	newServer := func(host, charset, collation string) *dbtest.Server {
+		srv := dbtest.NewServer(t, host)
+		srv.Handle(charsetQuery, dbtest.Response{
+			Columns: []string{"client", "connection", "results", "collation"},
+			Rows:    [][]driver.Value{{charset, charset, charset, collation}},
+		})
+		return srv
+	}
+	good := newServer("strict-good", "utf8mb4", "utf8mb4_0900_ai_ci")
+	bad := newServer("strict-bad", "latin1", "latin1_swedish_ci")
+
+	var stderr bytes.Buffer
+	config := &Config{ExpectCharset: "utf8mb4", StrictCharset: true, output: db.NewOutputSink(&bytes.Buffer{}, &stderr)}
+	err := executeQueries(context.Background(), config, []string{good.DSN(), bad.DSN()}, update)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 instance(s) have mismatched character sets") {
+		t.Fatalf("executeQueries() error = %v, want the run aborted", err)
+	}
+	want := "character set mismatch on user:****@tcp(strict-bad:3306)/app: character_set_client=latin1 (expected utf8mb4)"
+	if !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr is missing %q:\n%s", want, stderr.String())
+	}
+	if strings.Contains(stderr.String(), "strict-good") {
+		t.Errorf("warned about a matching instance:\n%s", stderr.String())
+	}
+	for _, srv := range []*dbtest.Server{good, bad} {
+		for _, query := range srv.Executed() {
+			if query == update {
+				t.Errorf("%s executed the statement despite --strict-charset", srv.Host)
+			}
+		}
+	}
+
+	// Without --strict-charset the mismatch is only a warning
+	config = &Config{ExpectCharset: "utf8mb4", output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, []string{good.DSN(), bad.DSN()}, update); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	if got := bad.Executed(); got[len(got)-1] != update {
+		t.Errorf("executed %v, want the statement run after the warning", got)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// checkAuth verifies every instance's credentials and database without running
+// any statements, printing one line per instance in instance order. Any failed
+// check ends the run with the connection-error exit code.
+func checkAuth(ctx context.Context, config *Config, instanceList []string) error {
+	config.infof("Checking credentials on %d instance(s) (%s)...\n", len(instanceList), config.concurrencyNote(len(instanceList)))
+	opts := config.connectOptions()
+
+	results := make([]db.AuthResult, len(instanceList))
+	if config.Concurrent {
+		var wg sync.WaitGroup
+		for i, instanceDSN := range instanceList {
+			wg.Add(1)
+			go func(i int, dsn string) {
+				defer wg.Done()
+				results[i] = db.CheckAuth(ctx, dsn, opts)
+			}(i, instanceDSN)
+		}
+		wg.Wait()
+	} else {
+		for i, instanceDSN := range instanceList {
+			results[i] = db.CheckAuth(ctx, instanceDSN, opts)
+		}
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Status == db.AuthOK {
+			config.sink().Printf(db.StreamResults, "%-16s %s (%v)\n", res.Status, db.MaskDSN(res.Instance), res.Handshake.Round(time.Millisecond))
+			continue
+		}
+		failed++
+		config.sink().Printf(db.StreamResults, "%-16s %s: %v\n", res.Status, db.MaskDSN(res.Instance), res.Err)
+	}
+
+	if failed == 0 {
+		config.infof("All %d instance(s) accepted the credentials.\n", len(instanceList))
+		return nil
+	}
+	code := config.exitCodes.code(categoryConnectionError)
+	if code == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%s: %d of %d instance(s) failed the credential check", categoryConnectionError, failed, len(instanceList))
+	return &exitError{category: categoryConnectionError, code: code, err: err}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestCheckAuth(t *testing.T) {
+	useFakeDriver(t)
+
+	ok := dbtest.NewServer(t, "checkauth-ok")
+	ok.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: [][]driver.Value{{int64(1)}}})
+	noDB := dbtest.NewServer(t, "checkauth-nodb")
+	noDB.FailConnect(&mysql.MySQLError{Number: 1049, Message: "Unknown database 'shpo'"})
+	instances := []string{ok.DSN(), noDB.DSN()}
+
+	var stdout bytes.Buffer
+	config := &Config{Concurrent: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	err := checkAuth(context.Background(), config, instances)
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryConnectionError {
+		t.Fatalf("checkAuth() error = %v, want a connection-error exit", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")[1:] // After the banner
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ok ") || !strings.HasPrefix(lines[1], "unknown-database ") {
+		t.Errorf("output = %q, want one line per instance in order", lines)
+	}
+	if strings.Contains(stdout.String(), "secret") {
+		t.Errorf("output exposes the password:\n%s", stdout.String())
+	}
+
+	// A failed check can be remapped to success like any connection error
+	config = &Config{exitCodes: exitCodeMap{categoryConnectionError: 0}, output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	if err := checkAuth(context.Background(), config, instances); err != nil {
+		t.Errorf("checkAuth() with connection-error=0 error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// errMissingColumns is reported for a result lacking columns of the first
+// instance's result with --canonical-columns
+var errMissingColumns = errors.New("result lacks columns of the first instance's result (--canonical-columns)")
+
+// columnOrder holds, for each statement of a phase, the columns of the first
+// instance that returned any, which --canonical-columns reorders the other
+// instances' results to. A nil *columnOrder leaves results as they are.
+type columnOrder struct {
+	reference map[int][]string // By the statement's index within the phase
+}
+
+// newColumnOrder returns the column order of a phase, or nil without --canonical-columns
+func (c *Config) newColumnOrder() *columnOrder {
+	if !c.CanonicalColumns {
+		return nil
+	}
+	return &columnOrder{reference: make(map[int][]string)}
+}
+
+// align reorders an instance's results to the reference columns of their
+// statements; the first instance to return columns for a statement sets them.
+// Instances must be aligned in instance order. A result lacking a reference
+// column is replaced by an error.
+func (o *columnOrder) align(results []db.QueryResult) []db.QueryResult {
+	if o == nil {
+		return results
+	}
+	aligned := make([]db.QueryResult, len(results))
+	for i, res := range results {
+		aligned[i] = res
+		if res.Err != nil || res.Skipped || len(res.Columns) == 0 {
+			continue
+		}
+		reference, ok := o.reference[i]
+		if !ok {
+			o.reference[i] = res.Columns
+			continue
+		}
+		reordered, err := reorderColumns(res, reference)
+		if err != nil {
+			reordered = res
+			reordered.Err = err
+			reordered.Columns, reordered.ColumnTypes, reordered.Rows, reordered.RowCount = nil, nil, nil, 0
+		}
+		aligned[i] = reordered
+	}
+	return aligned
+}
+
+// reorderColumns returns res with its columns, column types and row values in the
+// order of reference, matched by name; repeated names are matched by occurrence.
+// Columns of res not in reference follow, in their order, so no value is lost.
+// It fails if res lacks a column of reference.
+func reorderColumns(res db.QueryResult, reference []string) (db.QueryResult, error) {
+	if slices.Equal(res.Columns, reference) {
+		return res, nil
+	}
+	extra := slices.Clone(res.Columns)
+	var missing []string
+	for _, col := range reference {
+		if i := slices.Index(extra, col); i >= 0 {
+			extra = slices.Delete(extra, i, i+1)
+		} else {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return res, fmt.Errorf("%w: missing %s", errMissingColumns, strings.Join(missing, ", "))
+	}
+	return db.CoerceColumns(res, append(slices.Clone(reference), extra...)), nil
+}
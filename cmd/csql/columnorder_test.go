@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestReorderColumns(t *testing.T) {
+	res := db.QueryResult{
+		Columns:     []string{"name", "id", "extra", "id"},
+		ColumnTypes: []db.ColumnType{{Name: "name"}, {Name: "id", DatabaseType: "INT"}, {Name: "extra"}, {Name: "id", DatabaseType: "BIGINT"}},
+		Rows:        [][]interface{}{{"a", int64(1), "x", int64(10)}, {"b", int64(2), "y", int64(20)}},
+	}
+
+	got, err := reorderColumns(res, []string{"id", "name", "id"})
+	if err != nil {
+		t.Fatalf("reorderColumns() error = %v", err)
+	}
+	if want := []string{"id", "name", "id", "extra"}; !reflect.DeepEqual(got.Columns, want) {
+		t.Errorf("columns = %q, want %q", got.Columns, want)
+	}
+	if want := [][]interface{}{{int64(1), "a", int64(10), "x"}, {int64(2), "b", int64(20), "y"}}; !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("rows = %v, want %v", got.Rows, want)
+	}
+	if got.ColumnTypes[2].DatabaseType != "BIGINT" {
+		t.Errorf("column types = %+v, want the second id's type to follow it", got.ColumnTypes)
+	}
+	if res.Columns[0] != "name" || res.Rows[0][0] != "a" {
+		t.Error("reorderColumns() modified its input")
+	}
+
+	// A missing column, or a repeated name occurring fewer times, fails
+	for _, reference := range [][]string{{"id", "name", "created_at"}, {"id", "id", "id"}} {
+		if _, err := reorderColumns(res, reference); !errors.Is(err, errMissingColumns) {
+			t.Errorf("reorderColumns(%q) error = %v, want errMissingColumns", reference, err)
+		}
+	}
+}
+
+func TestExecuteQueries_CanonicalColumns(t *testing.T) {
+	useFakeDriver(t)
+	responses := []dbtest.Response{
+		{Columns: []string{"id", "name"}, Rows: [][]driver.Value{{int64(1), []byte("a")}}},
+		{Columns: []string{"name", "id"}, Rows: [][]driver.Value{{[]byte("b"), int64(2)}}},
+		{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(3)}}},
+	}
+	for _, concurrent := range []bool{false, true} {
+		var instances []string
+		for i, resp := range responses {
+			srv := dbtest.NewServer(t, fmt.Sprintf("canonical-%t-%d", concurrent, i+1))
+			srv.Handle("SELECT * FROM t", resp)
+			instances = append(instances, srv.DSN())
+		}
+
+		var stdout, stderr bytes.Buffer
+		config := &Config{Format: formatCSV, CanonicalColumns: true, Concurrent: concurrent, output: db.NewOutputSink(&stdout, &stderr)}
+		_ = executeQueries(context.Background(), config, instances, "SELECT * FROM t")
+		out := stdout.String()
+		if strings.Count(out, "id,name\n") != 2 || !strings.Contains(out, "2,b\n") {
+			t.Errorf("concurrent=%t: the second instance's columns were not reordered:\n%s", concurrent, out)
+		}
+		if !strings.Contains(stderr.String(), "(--canonical-columns): missing name") {
+			t.Errorf("concurrent=%t: the third instance's missing column was not reported:\n%s", concurrent, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// commandValidateConfig is the first argument that selects the validate-config command
+const commandValidateConfig = "validate-config"
+
+// configIssue is a problem found in a --json servers file
+type configIssue struct {
+	Line    int    // Line of the file, 0 if unknown
+	Server  int    // 1-based position of the server entry, 0 for the file as a whole
+	Message string // What is wrong
+}
+
+func (i configIssue) String() string {
+	var where []string
+	if i.Line > 0 {
+		where = append(where, fmt.Sprintf("line %d", i.Line))
+	}
+	if i.Server > 0 {
+		where = append(where, fmt.Sprintf("server %d", i.Server))
+	}
+	if len(where) == 0 {
+		return i.Message
+	}
+	return strings.Join(where, ", ") + ": " + i.Message
+}
+
+// serverField returns the index of the Server field a servers file key decodes
+// into, matching json tags ignoring case as encoding/json does
+func serverField(key string) (int, bool) {
+	t := reflect.TypeOf(Server{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if strings.EqualFold(name, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// serverSchema returns the JSON Schema of a --json servers file, derived from
+// the json tags of Server so it always lists the keys that are accepted
+func serverSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	t := reflect.TypeOf(Server{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch field.Type.Kind() {
+		case reflect.Slice:
+			properties[name] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+		default:
+			properties[name] = map[string]interface{}{"type": "string"}
+		}
+	}
+	return map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "go-csql servers file",
+		"description": "Servers for --json: each entry gives a dsn, or the parts to build one from. Lines starting with # are comments.",
+		"type":        "array",
+		"items": map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		},
+	}
+}
+
+// lineAt returns the 1-based line of content that offset falls on
+func lineAt(content []byte, offset int64) int {
+	return bytes.Count(content[:min(int(offset), len(content))], []byte("\n")) + 1
+}
+
+// serverEntry is a server decoded from a servers file and the line it starts on
+type serverEntry struct {
+	Server
+	Line int
+}
+
+// inspectServers decodes a servers file, with comments already blanked out, key
+// by key: it returns the servers and every unknown key or value of the wrong type,
+// with its line. Malformed JSON, where decoding cannot go on, is an error.
+func inspectServers(content []byte) ([]serverEntry, []configIssue, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	syntaxErr := func(err error) error {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return fmt.Errorf("line %d: %w", lineAt(content, syntax.Offset), err)
+		}
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("unexpected end of file")
+		}
+		return fmt.Errorf("line %d: %w", lineAt(content, dec.InputOffset()), err)
+	}
+	expect := func(want json.Delim, what string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return syntaxErr(err)
+		}
+		if tok != want {
+			return fmt.Errorf("line %d: expected %s, found %v", lineAt(content, dec.InputOffset()), what, tok)
+		}
+		return nil
+	}
+
+	if err := expect('[', "a list of servers"); err != nil {
+		return nil, nil, err
+	}
+	var servers []serverEntry
+	var issues []configIssue
+	for dec.More() {
+		if err := expect('{', "a server object"); err != nil {
+			return nil, nil, err
+		}
+		s := serverEntry{Line: lineAt(content, dec.InputOffset())}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, nil, syntaxErr(err)
+			}
+			key := tok.(string) // Object keys are always strings
+			line := lineAt(content, dec.InputOffset())
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return nil, nil, syntaxErr(err)
+			}
+			field, ok := serverField(key)
+			if !ok {
+				issues = append(issues, configIssue{Line: line, Server: len(servers) + 1, Message: fmt.Sprintf("unknown key %q", key)})
+				continue
+			}
+			target := reflect.ValueOf(&s.Server).Elem().Field(field)
+			if err := json.Unmarshal(value, target.Addr().Interface()); err != nil {
+				issues = append(issues, configIssue{Line: line, Server: len(servers) + 1,
+					Message: fmt.Sprintf("%q must be a %s, not %s", key, schemaType(target.Type()), value)})
+			}
+		}
+		if err := expect('}', "the end of the server object"); err != nil {
+			return nil, nil, err
+		}
+		servers = append(servers, s)
+	}
+	if err := expect(']', "the end of the list of servers"); err != nil {
+		return nil, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("line %d: unexpected content after the list of servers", lineAt(content, dec.InputOffset()))
+	}
+	return servers, issues, nil
+}
+
+// decodeServersStrict decodes a servers file, rejecting unknown keys. The decoder
+// only names an offending key, so problems are reported with their line instead.
+func decodeServersStrict(content []byte) ([]Server, error) {
+	var servers []Server
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&servers)
+	if err == nil && !dec.More() {
+		return servers, nil
+	}
+	_, issues, inspectErr := inspectServers(content)
+	switch {
+	case inspectErr != nil:
+		return nil, inspectErr
+	case len(issues) > 1:
+		return nil, fmt.Errorf("%s (and %d more problem(s); run validate-config to list them)", issues[0], len(issues)-1)
+	case len(issues) == 1:
+		return nil, errors.New(issues[0].String())
+	case err == nil:
+		return nil, fmt.Errorf("unexpected content after the list of servers")
+	}
+	return nil, err
+}
+
+// schemaType names a Server field type the way the schema does
+func schemaType(t reflect.Type) string {
+	if t.Kind() == reflect.Slice {
+		return "list of strings"
+	}
+	return "string"
+}
+
+// validateConfigFile checks the --json servers files without connecting anywhere:
+// unknown keys, values of the wrong type and entries that do not make a valid DSN
+// are reported with their file and line, and the DSN of every entry is printed
+// with the password masked. Each file is checked on its own; any problem fails
+// the command, so it can gate a config repo in CI.
+func validateConfigFile(config *Config) error {
+	if config.ConfigSchema {
+		data, err := db.MarshalJSON(serverSchema(), true)
+		if err != nil {
+			return err
+		}
+		config.sink().Printf(db.StreamResults, "%s\n", data)
+		return nil
+	}
+
+	files := config.jsonFiles()
+	var failed []error
+	for _, path := range files {
+		if err := validateServersFile(config, path); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(files) > 1 && len(failed) > 0 {
+		return fmt.Errorf("%d of %d servers file(s) have problems: %w", len(failed), len(files), errors.Join(failed...))
+	}
+	return errors.Join(failed...)
+}
+
+// validateServersFile checks a single servers file for validateConfigFile
+func validateServersFile(config *Config, path string) error {
+	expandedPath, err := expandPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to expand JSON file path: %w", err)
+	}
+	content, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return fmt.Errorf("failed to read JSON file: %w", err)
+	}
+	content = stripJSONComments(content)
+
+	servers, issues, err := inspectServers(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(servers) == 0 {
+		issues = append(issues, configIssue{Message: "no servers"})
+	}
+
+	invalid := make(map[int]bool) // Servers with issues, by position
+	for _, issue := range issues {
+		invalid[issue.Server] = true
+	}
+	for i, s := range servers {
+		dsn := s.BuildDSN()
+		if err := validateDSN(dsn); err != nil {
+			issues = append(issues, configIssue{Line: s.Line, Server: i + 1, Message: err.Error()})
+			invalid[i+1] = true
+			continue
+		}
+		if bastion := s.bastion(); bastion != "" {
+			if _, _, err := db.ParseBastion(bastion); err != nil {
+				issues = append(issues, configIssue{Line: s.Line, Server: i + 1, Message: err.Error()})
+				invalid[i+1] = true
+				continue
+			}
+		}
+		if s.Timeout != "" {
+			if _, err := parseServerTimeout(s.Timeout); err != nil {
+				issues = append(issues, configIssue{Line: s.Line, Server: i + 1, Message: err.Error()})
+				invalid[i+1] = true
+				continue
+			}
+		}
+		if !invalid[i+1] {
+			config.sink().Printf(db.StreamResults, "server %d (line %d): %s\n", i+1, s.Line, db.MaskDSN(dsn))
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	for _, issue := range issues {
+		config.sink().Printf(db.StreamDiagnostics, "%s: %s\n", path, issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%s: %d problem(s) found", path, len(issues))
+	}
+	config.infof("%s: %d server(s) OK\n", path, len(servers))
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// writeServers writes a servers file for a test and returns its path
+func writeServers(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "servers.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantErr   bool
+		wantOut   []string // On stdout: the masked DSNs of valid servers
+		wantIssue []string // On stderr
+	}{
+		{
+			name: "valid",
+			content: `# Production fleet
+[
+  {"dsn": "app:s3cret@tcp(db-1:3306)/shop", "tags": ["primary"]},
+  # Replica
+  {"user": "app", "password": "s3cret", "host": "db-2", "database": "shop"}
+]`,
+			wantOut: []string{"server 1 (line 3): app:****@tcp(db-1:3306)/shop", "server 2 (line 5): app:****@tcp(db-2:3306)/shop"},
+		},
+		{
+			name: "problems are reported with their lines",
+			content: `[
+  {"user": "app", "password": "s3cret", "host": "db-1"},
+  {
+    "user": "app",
+    "passsword": "s3cret",
+    "host": "db-2",
+    "port": 3307
+  },
+  {"dsn": "db-3"}
+]`,
+			wantErr: true,
+			wantOut: []string{"server 1 (line 2): app:****@tcp(db-1:3306)"},
+			wantIssue: []string{
+				`servers.json: line 5, server 2: unknown key "passsword"`,
+				`servers.json: line 7, server 2: "port" must be a string, not 3307`,
+				`servers.json: line 9, server 3: invalid DSN format: missing protocol or @ symbol`,
+			},
+		},
+		{
+			name:      "empty list",
+			content:   "[]",
+			wantErr:   true,
+			wantIssue: []string{"servers.json: no servers"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			config := &Config{ValidateConfig: true, JSONFile: writeServers(t, tt.content), output: db.NewOutputSink(&stdout, &stderr)}
+			err := validateConfigFile(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateConfigFile() error = %v, wantErr %v\n%s", err, tt.wantErr, stderr.String())
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout is missing %q:\n%s", want, stdout.String())
+				}
+			}
+			issues := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+			if len(tt.wantIssue) == 0 && stderr.Len() > 0 {
+				t.Errorf("unexpected issues:\n%s", stderr.String())
+			}
+			for i, want := range tt.wantIssue {
+				if i >= len(issues) || !strings.HasSuffix(issues[i], want) {
+					t.Errorf("issue %d: want %q in\n%s", i, want, stderr.String())
+				}
+			}
+			if strings.Contains(stdout.String()+stderr.String(), "s3cret") {
+				t.Errorf("a password is exposed:\n%s%s", stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestInspectServers_Malformed(t *testing.T) {
+	tests := []struct {
+		content string
+		wantErr string
+	}{
+		{content: "[\n  {\"dsn\": \"a@tcp(h)/d\"},\n  {\"dsn\" \"b\"}\n]", wantErr: "line 3: invalid character"},
+		{content: `{"dsn": "a@tcp(h)/d"}`, wantErr: "line 1: expected a list of servers"},
+		{content: "[\n  \"a@tcp(h)/d\"\n]", wantErr: "line 2: expected a server object"},
+		{content: "[\n  {\"dsn\": \"a@tcp(h)/d\"}", wantErr: "line 2: unexpected end of JSON input"},
+		{content: "\n\n", wantErr: "unexpected end of file"},
+		{content: "[]\n[]", wantErr: "line 2: unexpected content after the list of servers"},
+	}
+	for _, tt := range tests {
+		if _, _, err := inspectServers([]byte(tt.content)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("inspectServers(%q) error = %v, want %q", tt.content, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadInstancesFromJSON_StrictConfig(t *testing.T) {
+	path := writeServers(t, "[\n  {\"user\": \"app\", \"passsword\": \"s3cret\", \"host\": \"db-1\"}\n]")
+
+	lenient := &Config{JSONFile: path}
+	if got, err := lenient.loadInstancesFromJSON(nil); err != nil || len(got) != 1 {
+		t.Fatalf("without --strict-config: %v, %v; want the misspelled key ignored", got, err)
+	}
+
+	strict := &Config{JSONFile: path, StrictConfig: true}
+	_, err := strict.loadInstancesFromJSON(nil)
+	if err == nil || !strings.Contains(err.Error(), `line 2, server 1: unknown key "passsword"`) {
+		t.Errorf("with --strict-config: error = %v, want the unknown key and its line", err)
+	}
+}
+
+func TestServerSchema(t *testing.T) {
+	data, err := json.Marshal(serverSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Type  string
+		Items struct {
+			Properties           map[string]map[string]interface{}
+			AdditionalProperties bool
+		}
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Type != "array" || schema.Items.AdditionalProperties {
+		t.Errorf("schema = %s, want an array of closed objects", data)
+	}
+	for _, key := range []string{"dsn", "user", "password", "host", "port", "database", "tags", "group", "ssh"} {
+		if _, ok := schema.Items.Properties[key]; !ok {
+			t.Errorf("schema is missing %q: %s", key, data)
+		}
+	}
+	if got := schema.Items.Properties["tags"]["type"]; got != "array" {
+		t.Errorf("tags type = %v, want array", got)
+	}
+}
+
+func TestConfig_LoadFromFlags_ValidateConfig(t *testing.T) {
+	originalArgs, originalFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = originalArgs, originalFlags })
+	flag.CommandLine = flag.NewFlagSet("go-csql", flag.ContinueOnError)
+	os.Args = []string{"go-csql", "validate-config", "servers.json"}
+
+	var config Config
+	if err := config.LoadFromFlags(); err != nil {
+		t.Fatalf("LoadFromFlags() error = %v", err)
+	}
+	if !config.ValidateConfig || config.JSONFile != "servers.json" || config.Statements != "" {
+		t.Errorf("ValidateConfig = %t, JSONFile = %q, Statements = %q; want the servers file, not SQL",
+			config.ValidateConfig, config.JSONFile, config.Statements)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestConfig_Validate_ValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "servers file", config: Config{ValidateConfig: true, JSONFile: "servers.json"}},
+		{name: "schema", config: Config{ValidateConfig: true, ConfigSchema: true}},
+		{name: "no file", config: Config{ValidateConfig: true}, wantErr: "requires a servers file"},
+		{name: "with SQL", config: Config{ValidateConfig: true, JSONFile: "servers.json", Statements: "SELECT 1"}, wantErr: "only checks a servers file"},
+		{name: "schema needs the command", config: Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1", ConfigSchema: true}, wantErr: "requires the validate-config command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadInstancesFromJSON_MultipleFiles(t *testing.T) {
+	prod := writeServers(t, `[{"user": "app", "host": "db-1"}, {"user": "app", "host": "db-2"}]`)
+	staging := writeServers(t, `[{"user": "other", "host": "db-2", "port": "3306"}, {"user": "app", "host": "db-3"}]`)
+
+	var stderr bytes.Buffer
+	config := &Config{JSONFile: prod + ", " + staging, output: db.NewOutputSink(&bytes.Buffer{}, &stderr)}
+	got, err := config.loadInstancesFromJSON(nil)
+	if err != nil {
+		t.Fatalf("loadInstancesFromJSON() error = %v", err)
+	}
+	want := []string{"app@tcp(db-1:3306)", "app@tcp(db-2:3306)", "app@tcp(db-3:3306)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("instances = %q, want %q", got, want)
+	}
+	if note := "Skipping db-2:3306 from " + staging + ": already listed in " + prod; !strings.Contains(stderr.String(), note) {
+		t.Errorf("stderr lacks %q:\n%s", note, stderr.String())
+	}
+
+	broken := writeServers(t, `[{"host": "db-4"`)
+	config = &Config{JSONFile: prod + "," + broken, output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	if _, err := config.loadInstancesFromJSON(nil); err == nil || !strings.HasPrefix(err.Error(), broken+": failed to parse JSON") {
+		t.Errorf("error = %v, want it to name %s", err, broken)
+	}
+}
+
+func TestValidateConfigFile_MultipleFiles(t *testing.T) {
+	good := writeServers(t, `[{"user": "app", "host": "db-1"}]`)
+	bad := writeServers(t, "[\n  {\"user\": \"app\", \"hots\": \"db-2\"}\n]")
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{ValidateConfig: true, JSONFile: good + "," + bad, output: db.NewOutputSink(&stdout, &stderr)}
+	err := validateConfigFile(config)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 servers file(s) have problems") || !strings.Contains(err.Error(), bad) {
+		t.Fatalf("validateConfigFile() error = %v, want the bad file named", err)
+	}
+	if !strings.Contains(stdout.String(), good+": 1 server(s) OK") {
+		t.Errorf("the good file is not reported OK:\n%s", stdout.String())
+	}
+	if want := bad + `: line 2, server 1: unknown key "hots"`; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr lacks %q:\n%s", want, stderr.String())
+	}
+}
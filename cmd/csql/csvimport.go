@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// validateImportCSV checks the --import-csv flags. The CSV is the only source of
+// the statements, so no other may be given.
+func (c *Config) validateImportCSV(sqlSourceCount int) error {
+	if c.ImportCSV == "" {
+		if c.ImportTable != "" || c.BatchSize != 0 {
+			return fmt.Errorf("--import-table and --batch-size require --import-csv")
+		}
+		return nil
+	}
+	switch {
+	case c.ImportTable == "":
+		return fmt.Errorf("--import-csv requires --import-table")
+	case c.BatchSize < 0:
+		return fmt.Errorf("--batch-size must be greater than 0")
+	case sqlSourceCount > 0:
+		return fmt.Errorf("--import-csv generates the statements; it cannot be combined with --stdin, --sqlfile, --file, --statements or SQL arguments")
+	case (c.InputFormat != "" && c.InputFormat != inputSQL) || c.ForEachFile != "":
+		return fmt.Errorf("--import-csv cannot be combined with --input-format or --for-each-file")
+	}
+	return nil
+}
+
+// loadImportCSV reads the --import-csv file and returns its rows as INSERT
+// statements into --import-table, separated by the --terminator
+func (c *Config) loadImportCSV() (string, error) {
+	expandedPath, err := expandPath(c.ImportCSV)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand --import-csv path: %w", err)
+	}
+	f, err := os.Open(expandedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --import-csv file: %w", err)
+	}
+	defer f.Close()
+
+	statements, err := db.CSVInserts(f, db.CSVImportOptions{Table: c.ImportTable, BatchSize: c.BatchSize, Null: c.CSVNull})
+	if err != nil {
+		return "", fmt.Errorf("--import-csv %s: %w", c.ImportCSV, err)
+	}
+	var b strings.Builder
+	for _, stmt := range statements {
+		b.WriteString(stmt)
+		b.WriteString(c.terminator.Separator())
+	}
+	return b.String(), nil
+}
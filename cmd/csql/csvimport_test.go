@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestExecuteQueries_ImportCSV(t *testing.T) {
+	useFakeDriver(t)
+	path := filepath.Join(t.TempDir(), "products.csv")
+	if err := os.WriteFile(path, []byte("sku,name\nA1,tea\nB2,\"coffee; black\"\nC3,\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var servers []*dbtest.Server
+	var instances []string
+	for _, host := range []string{"import-1", "import-2"} {
+		srv := dbtest.NewServer(t, host)
+		servers = append(servers, srv)
+		instances = append(instances, srv.DSN())
+	}
+
+	config := &Config{Instances: "x", ImportCSV: path, ImportTable: "shop.products", BatchSize: 2}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	sqls, err := config.LoadStatements()
+	if err != nil {
+		t.Fatalf("LoadStatements() error = %v", err)
+	}
+	config.output = db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})
+	if err := executeQueries(context.Background(), config, instances, sqls); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+
+	want := []string{
+		"INSERT INTO `shop`.`products` (`sku`, `name`) VALUES ('A1','tea'),('B2','coffee; black')",
+		"INSERT INTO `shop`.`products` (`sku`, `name`) VALUES ('C3','')",
+	}
+	for _, srv := range servers {
+		if got := srv.Executed(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s executed %q, want %q", srv.Host, got, want)
+		}
+	}
+}
+
+func TestExecuteQueries_ImportCSVNull(t *testing.T) {
+	useFakeDriver(t)
+	path := filepath.Join(t.TempDir(), "notes.csv")
+	if err := os.WriteFile(path, []byte("id,note\n1,\n2,\\N\n3,\"\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	srv := dbtest.NewServer(t, "import-null")
+
+	tests := []struct {
+		name    string
+		csvNull string
+		want    string
+	}{
+		// Empty fields, quoted or not, are empty strings unless asked otherwise
+		{name: "default", want: "INSERT INTO `notes` (`id`, `note`) VALUES ('1',''),('2','\\\\N'),('3','')"},
+		{name: "explicit NULL token", csvNull: `\N`, want: "INSERT INTO `notes` (`id`, `note`) VALUES ('1',''),('2',NULL),('3','')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Instances: "x", ImportCSV: path, ImportTable: "notes", CSVNull: tt.csvNull}
+			if err := config.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			sqls, err := config.LoadStatements()
+			if err != nil {
+				t.Fatalf("LoadStatements() error = %v", err)
+			}
+			config.output = db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})
+			before := len(srv.Executed())
+			if err := executeQueries(context.Background(), config, []string{srv.DSN()}, sqls); err != nil {
+				t.Fatalf("executeQueries() error = %v", err)
+			}
+			if got := srv.Executed()[before:]; !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("executed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_ImportCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "valid", config: Config{ImportCSV: "rows.csv", ImportTable: "t", BatchSize: 500, CSVNull: `\N`}},
+		{name: "no table", config: Config{ImportCSV: "rows.csv"}, wantErr: "--import-table"},
+		{name: "table without a file", config: Config{ImportTable: "t", Statements: "SELECT 1"}, wantErr: "require --import-csv"},
+		{name: "negative batch", config: Config{ImportCSV: "rows.csv", ImportTable: "t", BatchSize: -1}, wantErr: "--batch-size"},
+		{name: "with statements", config: Config{ImportCSV: "rows.csv", ImportTable: "t", Statements: "SELECT 1"}, wantErr: "cannot be combined"},
+		{name: "with for-each-file", config: Config{ImportCSV: "rows.csv", ImportTable: "t", ForEachFile: "tables.txt"}, wantErr: "--for-each-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			c.Instances = "user:pass@tcp(host:3306)/db"
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want one mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
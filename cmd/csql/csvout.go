@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// csvHeaders remembers the CSV header last written to each destination, so that
+// with --csv-instance-column the rows of all instances returning the same columns
+// follow a single header, loadable as one table
+type csvHeaders struct {
+	mu   sync.Mutex
+	last map[string]string // Joined header by destination: "" for stdout, or the instance under --output-dir
+}
+
+// printCSV prints a result as CSV for --format csv. With an instance column the
+// header is written only when it differs from the one above, which is checked and
+// written under one lock so concurrent instances cannot both skip or both write it.
+func (c *Config) printCSV(instanceDSN string, res db.QueryResult) {
+	opts := db.CSVOptions{InstanceColumn: c.CSVInstanceColumn, Null: c.CSVNull}
+	var csvErr error
+	write := func() {
+		_ = c.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			csvErr = db.PrintResultCSV(w, res, opts)
+		})
+	}
+
+	if !opts.InstanceColumn || c.csvHeaders == nil || res.Err != nil || len(res.Columns) == 0 {
+		write()
+	} else {
+		dest := ""
+		if c.OutputDir != "" {
+			dest = instanceDSN
+		}
+		header := strings.Join(db.CSVHeader(res, opts), "\x00")
+		c.csvHeaders.mu.Lock()
+		last, written := c.csvHeaders.last[dest]
+		opts.SkipHeader = written && last == header
+		write()
+		c.csvHeaders.last[dest] = header
+		c.csvHeaders.mu.Unlock()
+	}
+	if csvErr != nil {
+		c.sink().Printf(db.StreamDiagnostics, "Error: %s: %v\n", res.Statement, csvErr)
+	}
+}
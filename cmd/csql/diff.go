@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// diffOptions returns how --diff compares results
+func (c *Config) diffOptions() db.DiffOptions {
+	var ignore []string
+	for _, col := range strings.Split(c.DiffIgnoreColumns, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			ignore = append(ignore, col)
+		}
+	}
+	return db.DiffOptions{Ordered: c.DiffOrdered, IgnoreColumns: ignore, VerifyOrder: c.VerifyOrder}
+}
+
+// printDiff prints the --diff report of a run's results and returns the number
+// of statements whose results differ across instances. Statements whose columns
+// differ across instances are printed per instance instead.
+func (c *Config) printDiff(instanceList []string, allResults map[string][]db.QueryResult) int {
+	report := db.DiffResults(instanceList, allResults, c.diffOptions())
+	_ = c.sink().Block(db.StreamResults, func(w io.Writer) {
+		db.WriteDiffReport(w, report)
+	})
+	c.printShapeFallbacks(instanceList, allResults, report)
+	return report.Differences()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestExecuteQueries_Diff(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for _, host := range []string{"diff-1", "diff-2", "diff-3"} {
+		srv := dbtest.NewServer(t, host)
+		srv.Handle("SELECT @@version", dbtest.Response{Columns: []string{"@@version"}, Rows: [][]driver.Value{{[]byte("8.0.36")}}})
+		rows := [][]driver.Value{{int64(1), []byte(host)}, {int64(2), []byte(host)}}
+		if host == "diff-2" {
+			rows = [][]driver.Value{rows[1], rows[0]}
+		}
+		if host == "diff-3" {
+			rows = rows[:1]
+		}
+		srv.Handle("SELECT id, host FROM t", dbtest.Response{Columns: []string{"id", "host"}, Rows: rows})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout bytes.Buffer
+	config := &Config{Instances: "x", Statements: "x", Diff: true, DiffIgnoreColumns: "host", VerifyOrder: true}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	config.output = db.NewOutputSink(&stdout, &bytes.Buffer{})
+	err := executeQueries(context.Background(), config, instances, "SELECT @@version; SELECT id, host FROM t")
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryExpectationFailed {
+		t.Fatalf("executeQueries() error = %v, want an expectation-failed exit for the difference", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"Statement 1: SELECT @@version\n  same on all 3 instance(s) (1 row(s))\n",
+		"Statement 2: SELECT id, host FROM t\n  DIFFERS: 2 distinct result(s)\n",
+		"user:****@tcp(diff-1:3306)/app, user:****@tcp(diff-2:3306)/app\n",
+		"  order: user:****@tcp(diff-2:3306)/app returned the same rows as user:****@tcp(diff-1:3306)/app in a different order, first at row 1\n",
+		"1 of 2 statement(s) differ across instances.\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stdout lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "8.0.36") {
+		t.Errorf("the results were printed besides the diff:\n%s", out)
+	}
+}
+
+func TestConfig_Validate_Diff(t *testing.T) {
+	base := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1"}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "diff", modify: func(c *Config) { c.Diff = true }},
+		{name: "ordered diff", modify: func(c *Config) { c.Diff, c.DiffOrdered = true, true }},
+		{name: "ignore columns without diff", modify: func(c *Config) { c.DiffIgnoreColumns = "updated_at" }, wantErr: true},
+		{name: "verify order without diff", modify: func(c *Config) { c.VerifyOrder = true }, wantErr: true},
+		{name: "verify order with ordered diff", modify: func(c *Config) { c.Diff, c.DiffOrdered, c.VerifyOrder = true, true, true }, wantErr: true},
+		{name: "diff with json", modify: func(c *Config) { c.Diff, c.Format = true, formatJSON }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_VerifyOrder(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for _, host := range []string{"order-1", "order-2"} {
+		srv := dbtest.NewServer(t, host)
+		rows := [][]driver.Value{{[]byte("Apple")}, {[]byte("apple")}}
+		if host == "order-2" { // A case-insensitive collation puts them the other way round
+			rows = [][]driver.Value{rows[1], rows[0]}
+		}
+		srv.Handle("SELECT name FROM fruit ORDER BY name", dbtest.Response{Columns: []string{"name"}, Rows: rows})
+		instances = append(instances, srv.DSN())
+	}
+
+	run := func(verifyOrder bool) (string, error) {
+		var stdout bytes.Buffer
+		config := &Config{Diff: true, VerifyOrder: verifyOrder, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+		err := executeQueries(context.Background(), config, instances, "SELECT name FROM fruit ORDER BY name")
+		return stdout.String(), err
+	}
+
+	// Rows are compared sorted, so the order alone is no difference
+	out, err := run(false)
+	if err != nil || !strings.Contains(out, "same on all 2 instance(s) (2 row(s))") {
+		t.Fatalf("without --verify-order: error = %v, stdout:\n%s\nwant the instances to agree", err, out)
+	}
+
+	out, err = run(true)
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryExpectationFailed {
+		t.Fatalf("with --verify-order: error = %v, want an expectation-failed exit", err)
+	}
+	want := "  order: user:****@tcp(order-2:3306)/app returned the same rows as user:****@tcp(order-1:3306)/app in a different order, first at row 1\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("stdout lacks %q:\n%s", want, out)
+	}
+	if strings.Contains(out, "distinct result(s)") {
+		t.Errorf("the rows were reported as different, want only their order:\n%s", out)
+	}
+}
+
+func TestExecuteQueries_DiffIgnoreColumns(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for _, host := range []string{"ignore-1", "ignore-2"} {
+		srv := dbtest.NewServer(t, host)
+		enabled := []byte("1")
+		if host == "ignore-2" {
+			enabled = []byte("0")
+		}
+		srv.Handle("SELECT name, updated_at FROM flags", dbtest.Response{
+			Columns: []string{"name", "updated_at"},
+			Rows:    [][]driver.Value{{[]byte("beta"), []byte(host + " 12:00")}},
+		})
+		srv.Handle("SELECT name, enabled, updated_at FROM flags", dbtest.Response{
+			Columns: []string{"name", "enabled", "updated_at"},
+			Rows:    [][]driver.Value{{[]byte("beta"), enabled, []byte(host + " 12:00")}},
+		})
+		instances = append(instances, srv.DSN())
+	}
+
+	run := func(ignore, statement string) (string, error) {
+		var stdout bytes.Buffer
+		config := &Config{Diff: true, DiffIgnoreColumns: ignore, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+		err := executeQueries(context.Background(), config, instances, statement)
+		return stdout.String(), err
+	}
+
+	// Only the ignored column differs
+	out, err := run("UPDATED_AT", "SELECT name, updated_at FROM flags")
+	if err != nil || !strings.Contains(out, "same on all 2 instance(s) (1 row(s))") {
+		t.Errorf("ignoring updated_at: error = %v, stdout:\n%s\nwant the instances to agree", err, out)
+	}
+	out, err = run("", "SELECT name, updated_at FROM flags")
+	if err == nil || !strings.Contains(out, "DIFFERS: 2 distinct result(s)") {
+		t.Errorf("ignoring nothing: error = %v, stdout:\n%s\nwant updated_at to differ", err, out)
+	}
+
+	// A column not ignored still differs
+	out, err = run("updated_at", "SELECT name, enabled, updated_at FROM flags")
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryExpectationFailed || !strings.Contains(out, "DIFFERS: 2 distinct result(s)") {
+		t.Errorf("ignoring updated_at: error = %v, stdout:\n%s\nwant enabled to differ", err, out)
+	}
+	if want := "2 column(s)"; !strings.Contains(out, want) {
+		t.Errorf("stdout lacks %q, want updated_at left out of the compared columns:\n%s", want, out)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exitCategory classifies how a run ended; each category has a documented exit code
+type exitCategory string
+
+const (
+	categoryOK                exitCategory = "ok"                 // Everything ran and succeeded
+	categoryQueryError        exitCategory = "query-error"        // A statement failed on the server
+	categoryConnectionError   exitCategory = "connection-error"   // An instance could not be reached
+	categoryTimeout           exitCategory = "timeout"            // A connection or statement hit its deadline
+	categoryExpectationFailed exitCategory = "expectation-failed" // A result check did not hold
+	categoryInterrupted       exitCategory = "interrupted"        // The run was cancelled
+	categoryCancelled         exitCategory = "cancelled"          // Straggling instances were cancelled, the rest ran
+	categoryPartial           exitCategory = "partial"            // Statements were skipped, e.g. by a run budget
+)
+
+// exitFailure is the exit code for failures before a run starts (flags, config, input)
+const exitFailure = 1
+
+// defaultExitCodes are the documented exit codes for each category
+var defaultExitCodes = exitCodeMap{
+	categoryOK:                0,
+	categoryQueryError:        1,
+	categoryConnectionError:   2,
+	categoryTimeout:           3,
+	categoryExpectationFailed: 4,
+	categoryInterrupted:       130, // As shells report a process ended by Ctrl-C
+	categoryPartial:           6,
+	categoryCancelled:         7,
+}
+
+// exitCategoryPriority orders failure categories from most to least significant;
+// a run is classified by the first one that applies. A failed statement outranks
+// unreachable instances, so connection-error means every failure was a connection.
+var exitCategoryPriority = []exitCategory{
+	categoryInterrupted,
+	categoryCancelled,
+	categoryTimeout,
+	categoryQueryError,
+	categoryConnectionError,
+	categoryExpectationFailed,
+	categoryPartial,
+}
+
+// ignoredErrorCategories are the categories --ignore-errors exits 0 for: failed
+// and unreachable instances and statements. Failed expectations and interrupted
+// runs still fail.
+var ignoredErrorCategories = []exitCategory{
+	categoryQueryError,
+	categoryConnectionError,
+	categoryTimeout,
+	categoryCancelled,
+	categoryPartial,
+}
+
+// exitCodeMap maps exit categories to process exit codes
+type exitCodeMap map[exitCategory]int
+
+// code returns the exit code for a category, falling back to the default
+func (m exitCodeMap) code(category exitCategory) int {
+	if code, ok := m[category]; ok {
+		return code
+	}
+	return defaultExitCodes[category]
+}
+
+// parseExitCodeMap parses --exit-code-map, e.g. "query-error=0,partial=0", on top of
+// the default codes
+func parseExitCodeMap(spec string) (exitCodeMap, error) {
+	codes := exitCodeMap{}
+	for category, code := range defaultExitCodes {
+		codes[category] = code
+	}
+	if strings.TrimSpace(spec) == "" {
+		return codes, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --exit-code-map entry %q: want category=code", entry)
+		}
+		category := exitCategory(strings.TrimSpace(name))
+		if _, known := defaultExitCodes[category]; !known || category == categoryOK {
+			return nil, fmt.Errorf("invalid --exit-code-map category %q: must be one of %s", name, strings.Join(failureCategoryNames(), ", "))
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid --exit-code-map code %q for %s: must be 0-255", value, category)
+		}
+		codes[category] = code
+	}
+	return codes, nil
+}
+
+// failureCategoryNames lists the remappable categories, sorted
+func failureCategoryNames() []string {
+	names := make([]string, 0, len(exitCategoryPriority))
+	for _, category := range exitCategoryPriority {
+		names = append(names, string(category))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// classifyRun returns the category describing how a run ended
+func classifyRun(summary runSummary) exitCategory {
+	present := map[exitCategory]bool{
+		categoryExpectationFailed: summary.ExpectationFailures > 0,
+	}
+	for _, s := range summary.Instances {
+		present[categoryInterrupted] = present[categoryInterrupted] || s.Interrupted > 0
+		present[categoryCancelled] = present[categoryCancelled] || s.Cancelled > 0
+		present[categoryTimeout] = present[categoryTimeout] || s.TimedOut > 0
+		present[categoryConnectionError] = present[categoryConnectionError] || s.ConnectFailed
+		present[categoryQueryError] = present[categoryQueryError] || s.QueryErrors > 0
+		present[categoryPartial] = present[categoryPartial] || s.Skipped > 0 || s.Truncated > 0
+	}
+	for _, category := range exitCategoryPriority {
+		if present[category] {
+			return category
+		}
+	}
+	return categoryOK
+}
+
+// exitError is a run failure that maps to a specific process exit code
+type exitError struct {
+	category exitCategory
+	code     int
+	err      error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitErrorFor classifies a finished run and returns the error run() should return:
+// nil when the run's category maps to exit code 0. cause, if set, is wrapped so
+// callers can still test for it with errors.Is.
+func exitErrorFor(summary runSummary, codes exitCodeMap, cause error) error {
+	category := classifyRun(summary)
+	code := codes.code(category)
+	if category == categoryOK || code == 0 {
+		return nil
+	}
+
+	var failed, skipped int
+	for _, s := range summary.Instances {
+		failed += s.Failed
+		skipped += s.Skipped
+	}
+	err := fmt.Errorf("%s: %d statement(s) failed, %d skipped", category, failed, skipped)
+	if cause != nil {
+		err = fmt.Errorf("%w (%v)", cause, err)
+	}
+	return &exitError{category: category, code: code, err: err}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestClassifyRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary runSummary
+		want    exitCategory
+	}{
+		{name: "empty run", summary: runSummary{}, want: categoryOK},
+		{
+			name:    "all succeeded",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 3}, {Executed: 3}}},
+			want:    categoryOK,
+		},
+		{
+			name:    "query error",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 2, Failed: 1, QueryErrors: 1}}},
+			want:    categoryQueryError,
+		},
+		{
+			name:    "connection error",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 1, Failed: 1, ConnectFailed: true}}},
+			want:    categoryConnectionError,
+		},
+		{
+			name:    "timeout",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 1, Failed: 1, TimedOut: 1}}},
+			want:    categoryTimeout,
+		},
+		{
+			name:    "expectation failed",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 1}}, ExpectationFailures: 1},
+			want:    categoryExpectationFailed,
+		},
+		{
+			name:    "interrupted",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 1, Skipped: 2, Interrupted: 2}}},
+			want:    categoryInterrupted,
+		},
+		{
+			name: "cancelled stragglers outrank the other failures",
+			summary: runSummary{Instances: []instanceSummary{
+				{Executed: 1},
+				{Executed: 1, Failed: 1, Skipped: 1, Cancelled: 2},
+				{Failed: 1, TimedOut: 1},
+			}},
+			want: categoryCancelled,
+		},
+		{
+			name:    "skipped statements",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 1, Skipped: 2}}},
+			want:    categoryPartial,
+		},
+		{
+			name:    "truncated by budget",
+			summary: runSummary{Instances: []instanceSummary{{Executed: 1, Failed: 1, Truncated: 1}}},
+			want:    categoryPartial,
+		},
+		{
+			name: "interrupted outranks everything",
+			summary: runSummary{Instances: []instanceSummary{
+				{Failed: 1, ConnectFailed: true},
+				{Failed: 2, QueryErrors: 1, TimedOut: 1},
+				{Skipped: 1, Interrupted: 1},
+			}, ExpectationFailures: 1},
+			want: categoryInterrupted,
+		},
+		{
+			name: "timeout outranks connection and query errors",
+			summary: runSummary{Instances: []instanceSummary{
+				{Failed: 1, ConnectFailed: true},
+				{Failed: 2, QueryErrors: 1, TimedOut: 1},
+			}},
+			want: categoryTimeout,
+		},
+		{
+			name: "query error outranks connection error",
+			summary: runSummary{Instances: []instanceSummary{
+				{Failed: 1, ConnectFailed: true},
+				{Executed: 2, Failed: 1, QueryErrors: 1},
+			}},
+			want: categoryQueryError,
+		},
+		{
+			name: "connection error when no statement failed",
+			summary: runSummary{Instances: []instanceSummary{
+				{Failed: 1, ConnectFailed: true},
+				{Executed: 2},
+				{Skipped: 1},
+			}},
+			want: categoryConnectionError,
+		},
+		{
+			name: "query error outranks expectation and partial",
+			summary: runSummary{Instances: []instanceSummary{
+				{Failed: 1, QueryErrors: 1},
+				{Skipped: 1},
+			}, ExpectationFailures: 2},
+			want: categoryQueryError,
+		},
+		{
+			name:    "expectation outranks partial",
+			summary: runSummary{Instances: []instanceSummary{{Skipped: 1}}, ExpectationFailures: 1},
+			want:    categoryExpectationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyRun(tt.summary); got != tt.want {
+				t.Errorf("classifyRun() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeRun_FailureBreakdown(t *testing.T) {
+	results := map[string][]db.QueryResult{
+		"a": {
+			{Instance: "a", Statement: "SELECT 1", Err: errors.New("syntax error")},
+			{Instance: "a", Statement: "SELECT 2", Err: fmt.Errorf("query error: %w", context.DeadlineExceeded)},
+			{Instance: "a", Statement: "SELECT 3", Err: fmt.Errorf("query error: %w", db.ErrBudgetExceeded)},
+			{Instance: "a", Statement: "SELECT 4", Skipped: true, Err: context.Canceled},
+			{Instance: "a", Statement: "SELECT 5", Skipped: true, Err: db.ErrBudgetExceeded},
+		},
+		"b": {
+			{Instance: "b", Err: errors.New("connection refused"), ConnectFailed: true},
+		},
+	}
+
+	summary := summarizeRun([]string{"a", "b"}, results)
+	a, b := summary.Instances[0], summary.Instances[1]
+	if a.QueryErrors != 1 || a.TimedOut != 1 || a.Truncated != 1 || a.Interrupted != 1 || a.Skipped != 2 || a.ConnectFailed {
+		t.Errorf("instance a breakdown = %+v", a)
+	}
+	if !b.ConnectFailed || b.QueryErrors != 0 {
+		t.Errorf("instance b breakdown = %+v", b)
+	}
+}
+
+func TestParseExitCodeMap(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[exitCategory]int // Codes expected to differ from or match the defaults
+		wantErr bool
+	}{
+		{spec: "", want: map[exitCategory]int{categoryQueryError: 1, categoryPartial: 6}},
+		{spec: "query-error=0", want: map[exitCategory]int{categoryQueryError: 0, categoryConnectionError: 2}},
+		{spec: " query-error = 0 , partial=0", want: map[exitCategory]int{categoryQueryError: 0, categoryPartial: 0}},
+		{spec: "connection-error=10,timeout=11,interrupted=12,expectation-failed=13", want: map[exitCategory]int{
+			categoryConnectionError: 10, categoryTimeout: 11, categoryInterrupted: 12, categoryExpectationFailed: 13,
+		}},
+		{spec: "query-error=255", want: map[exitCategory]int{categoryQueryError: 255}},
+		{spec: "ok=1", wantErr: true},
+		{spec: "bogus=1", wantErr: true},
+		{spec: "query-error", wantErr: true},
+		{spec: "query-error=x", wantErr: true},
+		{spec: "query-error=-1", wantErr: true},
+		{spec: "query-error=256", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseExitCodeMap(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExitCodeMap(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			for category, code := range tt.want {
+				if got.code(category) != code {
+					t.Errorf("code(%s) = %d, want %d", category, got.code(category), code)
+				}
+			}
+		})
+	}
+}
+
+func TestExitErrorFor(t *testing.T) {
+	failedQuery := runSummary{Instances: []instanceSummary{{Executed: 1, Failed: 1, QueryErrors: 1}}}
+	skipped := runSummary{Instances: []instanceSummary{{Skipped: 1}}}
+
+	tests := []struct {
+		name     string
+		summary  runSummary
+		codes    exitCodeMap
+		cause    error
+		wantCode int // 0 means no error
+	}{
+		{name: "success", summary: runSummary{Instances: []instanceSummary{{Executed: 1}}}, wantCode: 0},
+		{name: "default query error code", summary: failedQuery, wantCode: 1},
+		{name: "remapped to zero keeps legacy behavior", summary: failedQuery, codes: exitCodeMap{categoryQueryError: 0}, wantCode: 0},
+		{name: "remapped code", summary: failedQuery, codes: exitCodeMap{categoryQueryError: 42}, wantCode: 42},
+		{name: "nil map uses defaults", summary: skipped, codes: nil, wantCode: 6},
+		{name: "cause is wrapped", summary: skipped, cause: db.ErrBudgetExceeded, wantCode: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exitErrorFor(tt.summary, tt.codes, tt.cause)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("exitErrorFor() = %v, want nil", err)
+				}
+				return
+			}
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) || exitErr.code != tt.wantCode {
+				t.Fatalf("exitErrorFor() = %v, want exit code %d", err, tt.wantCode)
+			}
+			if exitErr.category != classifyRun(tt.summary) {
+				t.Errorf("category = %s, want %s", exitErr.category, classifyRun(tt.summary))
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("exitErrorFor() = %v, want it to wrap %v", err, tt.cause)
+			}
+		})
+	}
+}
+
+func TestRunSummary_FailureLine(t *testing.T) {
+	summary := runSummary{Instances: []instanceSummary{
+		{Executed: 20, Failed: 1},
+		{Executed: 10, Skipped: 9, Failed: 1},
+		{Executed: 1, Failed: 1, ConnectFailed: true},
+		{Executed: 20},
+	}}
+	if got, want := summary.failureLine(), "3 of 60 statement(s) failed across 3 instance(s)"; got != want {
+		t.Errorf("failureLine() = %q, want %q", got, want)
+	}
+	if got := (runSummary{Instances: []instanceSummary{{Executed: 5}}}).failureLine(); got != "" {
+		t.Errorf("failureLine() = %q without failures, want none", got)
+	}
+}
+
+func TestExecuteQueries_IgnoreErrors(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "ignore-errors")
+	srv.Handle("SELECT broken", dbtest.Response{Err: errors.New("Error 1054: Unknown column 'broken'")})
+	down := dbtest.NewServer(t, "ignore-errors-down")
+	down.FailConnect(errors.New("connection refused"))
+	instances := []string{srv.DSN(), down.DSN()}
+
+	for _, ignore := range []bool{false, true} {
+		var stderr bytes.Buffer
+		config := &Config{Instances: "x", Statements: "SELECT 1; SELECT broken", IgnoreErrors: ignore}
+		if err := config.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		config.output = db.NewOutputSink(&bytes.Buffer{}, &stderr)
+		err := executeQueries(context.Background(), config, instances, config.Statements)
+		if ignore && err != nil {
+			t.Errorf("executeQueries() with --ignore-errors error = %v, want nil", err)
+		}
+		var exitErr *exitError
+		if !ignore && (!errors.As(err, &exitErr) || exitErr.code == 0) {
+			t.Errorf("executeQueries() error = %v, want a non-zero exit", err)
+		}
+		// The summary is printed either way
+		if want := "2 of 3 statement(s) failed across 2 instance(s)\n"; !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr lacks %q:\n%s", want, stderr.String())
+		}
+	}
+
+	config := &Config{Instances: "x", Statements: "SELECT 1", IgnoreErrors: true, ExitCodeMap: "query-error=0"}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() accepted --ignore-errors with --exit-code-map")
+	}
+}
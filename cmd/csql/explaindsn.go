@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/go-sql-driver/mysql"
+)
+
+// sourcePasswordPrompt marks a password typed at --password-prompt in a
+// db.DSNProvenance
+const sourcePasswordPrompt = "password-prompt"
+
+// fillDSN fills the missing parts of a DSN from .my.cnf, keeping the host the DSN
+// names, then the password from --password-prompt. Where each part came from is
+// recorded for --explain-dsn.
+func (c *Config) fillDSN(dsn string, myCnf *db.MyCnf) string {
+	cnf := myCnf
+	if cnf != nil && dsnHasHost(dsn) {
+		// Create a temporary cnf without host to fill other details
+		tempCnf := *myCnf
+		tempCnf.Host = "" // Don't override host from .my.cnf
+		cnf = &tempCnf
+	}
+	filled, prov := db.FillDSN(dsn, cnf)
+	if withPassword := injectPassword(filled, c.password); withPassword != filled {
+		filled, prov.Password = withPassword, sourcePasswordPrompt
+	}
+
+	if c.dsnSources == nil {
+		c.dsnSources = make(map[string]db.DSNProvenance)
+	}
+	c.dsnSources[filled] = prov
+	if myCnf != nil {
+		c.myCnfPath = myCnf.Path
+	}
+	return filled
+}
+
+// sourceLabel describes where a part of a DSN came from
+func (c *Config) sourceLabel(source string) string {
+	switch source {
+	case db.SourceDSN:
+		return "given"
+	case db.SourceMyCnf:
+		return "from " + c.myCnfPath
+	case db.SourceDefault:
+		return "default"
+	case sourcePasswordPrompt:
+		return "from --password-prompt"
+	}
+	return "not set"
+}
+
+// explainDSNs prints, for every instance and failover group member, each part of
+// its DSN and where it came from: the DSN as given, .my.cnf, a default or
+// --password-prompt. No instance is connected to.
+func explainDSNs(config *Config, instanceList []string) {
+	for i, instanceDSN := range instanceList {
+		members := config.groups[instanceDSN]
+		if len(members) == 0 {
+			members = []string{instanceDSN}
+		}
+		for j, dsn := range members {
+			if i > 0 || j > 0 {
+				config.sink().Printf(db.StreamResults, "\n")
+			}
+			config.sink().Printf(db.StreamResults, "%s", config.explainDSN(dsn))
+		}
+	}
+}
+
+// explainDSN lays out the parts of a DSN with their sources; the password is masked
+func (c *Config) explainDSN(dsn string) string {
+	prov, ok := c.dsnSources[dsn]
+	if !ok {
+		_, prov = db.FillDSN(dsn, nil)
+	}
+	var user, password, host, port, database string
+	if cfg, err := mysql.ParseDSN(dsn); err == nil {
+		user, database = cfg.User, cfg.DBName
+		if cfg.Passwd != "" {
+			password = "****"
+		}
+		host = cfg.Addr
+		if h, p, err := net.SplitHostPort(cfg.Addr); err == nil {
+			host, port = h, p
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintln(&sb, db.MaskDSN(dsn))
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, part := range []struct{ name, value, source string }{
+		{"user", user, prov.User},
+		{"password", password, prov.Password},
+		{"host", host, prov.Host},
+		{"port", port, prov.Port},
+		{"database", database, prov.Database},
+	} {
+		value := part.value
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", part.name, value, c.sourceLabel(part.source))
+	}
+	tw.Flush()
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+func TestExplainDSNs(t *testing.T) {
+	myCnf := &db.MyCnf{User: "root", Password: "cnfpass", Host: "cnfhost", Database: "prod", Path: "/home/me/.my.cnf"}
+	var stdout bytes.Buffer
+	config := &Config{output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	instances := config.fillInstances([]string{"app:@tcp(db1:3306)/", "@/orders"}, myCnf)
+
+	if instances[0] != "app:cnfpass@tcp(db1:3306)/prod" || instances[1] != "root:cnfpass@tcp(cnfhost:3306)/orders" {
+		t.Fatalf("fillInstances() = %q", instances)
+	}
+	explainDSNs(config, instances)
+
+	want := "app:****@tcp(db1:3306)/prod\n" +
+		"  user      app   given\n" +
+		"  password  ****  from /home/me/.my.cnf\n" +
+		"  host      db1   given\n" +
+		"  port      3306  given\n" +
+		"  database  prod  from /home/me/.my.cnf\n" +
+		"\n" +
+		"root:****@tcp(cnfhost:3306)/orders\n" +
+		"  user      root     from /home/me/.my.cnf\n" +
+		"  password  ****     from /home/me/.my.cnf\n" +
+		"  host      cnfhost  from /home/me/.my.cnf\n" +
+		"  port      3306     default\n" +
+		"  database  orders   given\n"
+	if stdout.String() != want {
+		t.Errorf("explainDSNs() printed\n%s\nwant\n%s", stdout.String(), want)
+	}
+	if strings.Contains(stdout.String(), "cnfpass") {
+		t.Errorf("output exposes the password:\n%s", stdout.String())
+	}
+}
+
+func TestExplainDSNs_PasswordPrompt(t *testing.T) {
+	var stdout bytes.Buffer
+	config := &Config{password: "typed", output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	instances := config.fillInstances([]string{"app@tcp(db1:3306)/", "other:given@tcp(db2:3306)/"}, nil)
+	explainDSNs(config, instances)
+
+	for _, want := range []string{
+		"  password  ****  from --password-prompt\n",
+		"  password  ****   given\n",
+		"  database  -     not set\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// forEachPlaceholder is replaced by each line of the --for-each-file list
+const forEachPlaceholder = "{{item}}"
+
+// readForEachList reads the items of a --for-each-file list: one per line, with
+// surrounding spaces trimmed and blank lines and # comments skipped
+func readForEachList(path string) ([]string, error) {
+	expandedPath, err := expandPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand --for-each-file path: %w", err)
+	}
+	content, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --for-each-file list: %w", err)
+	}
+	var items []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, line)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("--for-each-file %s lists no items", path)
+	}
+	return items, nil
+}
+
+// renderForEach repeats the statements once per --for-each-file item, in list
+// order, with {{item}} replaced by the item. Items are inserted verbatim, so a
+// list of table names needs quoting in the statements, e.g. `{{item}}`.
+func (c *Config) renderForEach(sqls string) (string, error) {
+	if !strings.Contains(sqls, forEachPlaceholder) {
+		return "", fmt.Errorf("--for-each-file: the statements do not use %s", forEachPlaceholder)
+	}
+	items, err := readForEachList(c.ForEachFile)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(strings.ReplaceAll(sqls, forEachPlaceholder, item))
+		b.WriteString(c.terminator.Separator())
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// writeList writes a --for-each-file list to a temporary file
+func writeList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tables.txt")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfig_LoadStatements_ForEachFile(t *testing.T) {
+	list := writeList(t, "orders\n  customers  \n\n# archived later\ninvoices\n")
+	tests := []struct {
+		name       string
+		terminator string
+		statements string
+		want       []string
+	}{
+		{
+			name:       "one statement per item",
+			statements: "ANALYZE TABLE `{{item}}`",
+			want:       []string{"ANALYZE TABLE `orders`", "ANALYZE TABLE `customers`", "ANALYZE TABLE `invoices`"},
+		},
+		{
+			name:       "each item renders the whole script",
+			statements: "SELECT COUNT(*) FROM {{item}}; OPTIMIZE TABLE {{item}} -- reclaim space",
+			want: []string{
+				"SELECT COUNT(*) FROM orders", "OPTIMIZE TABLE orders -- reclaim space",
+				"SELECT COUNT(*) FROM customers", "OPTIMIZE TABLE customers -- reclaim space",
+				"SELECT COUNT(*) FROM invoices", "OPTIMIZE TABLE invoices -- reclaim space",
+			},
+		},
+		{
+			name:       "line terminator",
+			terminator: "line:GO",
+			statements: "DBCC {{item}}",
+			want:       []string{"DBCC orders", "DBCC customers", "DBCC invoices"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Statements: tt.statements, ForEachFile: list, Terminator: tt.terminator}
+			term, err := db.ParseTerminator(tt.terminator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.terminator = term
+			sqls, err := config.LoadStatements()
+			if err != nil {
+				t.Fatalf("LoadStatements() error = %v", err)
+			}
+			var got []string
+			for _, stmt := range term.Split(sqls) {
+				got = append(got, stmt.SQL)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("statements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadStatements_ForEachFileErrors(t *testing.T) {
+	config := &Config{Statements: "ANALYZE TABLE orders", ForEachFile: writeList(t, "orders\n")}
+	if _, err := config.LoadStatements(); err == nil || !strings.Contains(err.Error(), "{{item}}") {
+		t.Errorf("LoadStatements() error = %v, want the missing placeholder reported", err)
+	}
+	config = &Config{Statements: "ANALYZE TABLE {{item}}", ForEachFile: writeList(t, "# nothing yet\n\n")}
+	if _, err := config.LoadStatements(); err == nil || !strings.Contains(err.Error(), "lists no items") {
+		t.Errorf("LoadStatements() error = %v, want the empty list reported", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// runInstance runs sqls on an instance within its timeout, counting it against
+// --max-errors as soon as a statement fails, so a run that reaches the limit stops
+// before the instance's next statement, or if it could not be reached
+func (c *Config) runInstance(ctx context.Context, instanceDSN string, sqls string, opts db.ExecOptions) []db.QueryResult {
+	ctx, cancel := c.withInstanceTimeout(ctx, instanceDSN)
+	defer cancel()
+	if c.errorLimit != nil {
+		opts.OnFailure = func(db.QueryResult) { c.errorLimit.fail(instanceDSN) }
+	}
+	results := c.runGroup(ctx, instanceDSN, sqls, opts)
+	c.errorLimit.record(instanceDSN, results)
+	return results
+}
+
+// runGroup runs sqls on an instance. With --failover, an instance that leads a
+// group is replaced by the next group member whenever it cannot be reached; the
+// results name the member that actually served the statements.
+func (c *Config) runGroup(ctx context.Context, instanceDSN string, sqls string, opts db.ExecOptions) []db.QueryResult {
+	members := c.groups[instanceDSN]
+	if len(members) < 2 {
+		return c.runMember(ctx, instanceDSN, sqls, opts)
+	}
+
+	var results []db.QueryResult
+	for i, member := range members {
+		results = c.runMember(ctx, member, sqls, opts)
+		if !connectFailed(results) {
+			if i > 0 {
+				c.infof("Failover: served by %s (group member %d of %d)\n",
+					db.MaskDSN(member), i+1, len(members))
+			}
+			return results
+		}
+		if i < len(members)-1 {
+			c.infof("Failover: %s unreachable (%v), trying next group member\n",
+				db.MaskDSN(member), results[0].Err)
+		}
+	}
+	return results // Every member failed; report the last failure
+}
+
+// connectFailed reports whether an instance run failed before any statement executed
+func connectFailed(results []db.QueryResult) bool {
+	return len(results) == 1 && results[0].ConnectFailed
+}
+
+// runMember runs sqls on a single instance, over its warm session under --pre-connect
+func (c *Config) runMember(ctx context.Context, instanceDSN string, sqls string, opts db.ExecOptions) []db.QueryResult {
+	if c.pool != nil {
+		return c.pool.Run(ctx, instanceDSN, sqls, opts)
+	}
+	return db.RunSQLOnInstanceWithOptions(ctx, instanceDSN, sqls, opts)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestLoadInstancesFromJSON_FailoverGroups(t *testing.T) {
+	servers := `[
+  {"dsn": "user:pass@tcp(read-1:3306)/app", "group": "reads"},
+  {"dsn": "user:pass@tcp(solo:3306)/app"},
+  {"dsn": "user:pass@tcp(read-2:3306)/app", "group": "reads"}
+]`
+	jsonFile := filepath.Join(t.TempDir(), "servers.json")
+	if err := os.WriteFile(jsonFile, []byte(servers), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without --failover every server runs
+	c := &Config{JSONFile: jsonFile}
+	got, err := c.loadInstancesFromJSON(nil)
+	if err != nil {
+		t.Fatalf("loadInstancesFromJSON() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("without --failover got %d instances, want 3", len(got))
+	}
+
+	// With --failover a group runs once, led by its first member
+	c = &Config{JSONFile: jsonFile, Failover: true}
+	got, err = c.loadInstancesFromJSON(nil)
+	if err != nil {
+		t.Fatalf("loadInstancesFromJSON() error = %v", err)
+	}
+	want := []string{"user:pass@tcp(read-1:3306)/app", "user:pass@tcp(solo:3306)/app"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("with --failover got %v, want %v", got, want)
+	}
+	if members := c.groups[want[0]]; len(members) != 2 || members[1] != "user:pass@tcp(read-2:3306)/app" {
+		t.Errorf("group members = %v, want read-1 then read-2", members)
+	}
+}
+
+func TestRunInstance_FailoverToNextMember(t *testing.T) {
+	useFakeDriver(t)
+	first := dbtest.NewServer(t, "member-1")
+	second := dbtest.NewServer(t, "member-2")
+	first.FailConnect(errors.New("connection refused"))
+	second.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: [][]driver.Value{{int64(1)}}})
+
+	c := &Config{groups: map[string][]string{first.DSN(): {first.DSN(), second.DSN()}}}
+	results := c.runInstance(context.Background(), first.DSN(), "SELECT 1", db.ExecOptions{})
+
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("runInstance() = %+v, want one successful result", results)
+	}
+	if results[0].Instance != second.DSN() {
+		t.Errorf("served by %s, want %s", results[0].Instance, second.DSN())
+	}
+	if len(first.Executed()) != 0 {
+		t.Errorf("unreachable member executed %v", first.Executed())
+	}
+}
+
+func TestRunInstance_NoFailoverOnStatementError(t *testing.T) {
+	useFakeDriver(t)
+	first := dbtest.NewServer(t, "member-1")
+	second := dbtest.NewServer(t, "member-2")
+	first.Handle("SELECT broken", dbtest.Response{Err: errors.New("syntax error")})
+
+	c := &Config{groups: map[string][]string{first.DSN(): {first.DSN(), second.DSN()}}}
+	results := c.runInstance(context.Background(), first.DSN(), "SELECT broken", db.ExecOptions{})
+
+	if len(results) != 1 || results[0].Err == nil || results[0].Instance != first.DSN() {
+		t.Fatalf("runInstance() = %+v, want the first member's statement error", results)
+	}
+	if second.Opened() != 0 {
+		t.Errorf("second member was contacted %d time(s), want 0", second.Opened())
+	}
+}
+
+func TestRunInstance_AllMembersUnreachable(t *testing.T) {
+	useFakeDriver(t)
+	first := dbtest.NewServer(t, "member-1")
+	second := dbtest.NewServer(t, "member-2")
+	first.FailConnect(errors.New("connection refused"))
+	second.FailConnect(errors.New("no route to host"))
+
+	c := &Config{groups: map[string][]string{first.DSN(): {first.DSN(), second.DSN()}}}
+	results := c.runInstance(context.Background(), first.DSN(), "SELECT 1", db.ExecOptions{})
+
+	if !connectFailed(results) || results[0].Instance != second.DSN() {
+		t.Fatalf("runInstance() = %+v, want the last member's connect failure", results)
+	}
+}
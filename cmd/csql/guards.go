@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// guardDropDatabase refuses statements that drop a database unless
+// --allow-drop-database is given. Run across a fleet, a stray DROP DATABASE is
+// unrecoverable, so the guard holds even when nothing would ask for confirmation.
+func (c *Config) guardDropDatabase(sqls string) error {
+	if c.AllowDropDatabase {
+		return nil
+	}
+	if drops := db.FindDropDatabase(sqls, c.terminator); len(drops) > 0 {
+		return fmt.Errorf("refusing to drop a database without --allow-drop-database (%s); no statements were executed",
+			c.describeStatements(drops))
+	}
+	return nil
+}
+
+// guardSafeUpdates refuses UPDATE and DELETE statements without a WHERE or LIMIT
+// clause under --safe-updates, as the mysql client does
+func (c *Config) guardSafeUpdates(sqls string) error {
+	if !c.SafeUpdates {
+		return nil
+	}
+	if unsafe := db.FindUnsafeUpdates(sqls, c.terminator); len(unsafe) > 0 {
+		return fmt.Errorf("--safe-updates: refusing UPDATE or DELETE without WHERE or LIMIT (%s); no statements were executed",
+			c.describeStatements(unsafe))
+	}
+	return nil
+}
+
+// describeStatements lists statements on one line each, prefixed with where they
+// are in the script when known
+func (c *Config) describeStatements(statements []db.StatementInfo) string {
+	source := c.statementSource()
+	described := make([]string, len(statements))
+	for i, stmt := range statements {
+		described[i] = strings.Join(strings.Fields(stmt.SQL), " ")
+		if source != "" && stmt.Line > 0 {
+			described[i] = fmt.Sprintf("%s:%d: %s", source, stmt.Line, described[i])
+		}
+	}
+	return strings.Join(described, ", ")
+}
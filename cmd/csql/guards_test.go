@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestExecuteQueries_DropDatabaseGuard(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "drop-guard")
+	srv.Fallback(dbtest.Response{})
+	sqls := "SELECT 1;\n/*!40000 DROP DATABASE IF EXISTS `app`*/;\nCREATE DATABASE app;"
+
+	config := &Config{File: "restore.sql", output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	err := executeQueries(context.Background(), config, []string{srv.DSN()}, sqls)
+	if err == nil || !strings.Contains(err.Error(), "--allow-drop-database") || !strings.Contains(err.Error(), "restore.sql:2:") {
+		t.Fatalf("executeQueries() error = %v, want the DROP DATABASE at restore.sql:2 refused", err)
+	}
+	if executed := srv.Executed(); len(executed) > 0 {
+		t.Errorf("executed %q, want nothing run once a DROP DATABASE is refused", executed)
+	}
+
+	config = &Config{File: "restore.sql", AllowDropDatabase: true, output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, []string{srv.DSN()}, sqls); err != nil {
+		t.Fatalf("executeQueries() with --allow-drop-database error = %v", err)
+	}
+	if executed := strings.Join(srv.Executed(), "\n"); !strings.Contains(executed, "DROP DATABASE") {
+		t.Errorf("executed %q, want the DROP DATABASE run with --allow-drop-database", executed)
+	}
+}
+
+func TestExecuteQueries_SafeUpdates(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "safe-updates")
+	srv.Fallback(dbtest.Response{})
+	tests := []struct {
+		name    string
+		sqls    string
+		refused bool
+	}{
+		{name: "UPDATE without WHERE", sqls: "SELECT 1;\nUPDATE users SET active = 0;", refused: true},
+		{name: "DELETE without WHERE", sqls: "DELETE FROM sessions -- WHERE expired\n;", refused: true},
+		{name: "UPDATE with WHERE", sqls: "UPDATE users SET active = 0 WHERE id = 7;"},
+		{name: "DELETE with LIMIT", sqls: "DELETE FROM sessions ORDER BY created LIMIT 100;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(srv.Executed())
+			config := &Config{Statements: tt.sqls, SafeUpdates: true, output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+			err := executeQueries(context.Background(), config, []string{srv.DSN()}, tt.sqls)
+			if refused := err != nil && strings.Contains(err.Error(), "--safe-updates"); refused != tt.refused {
+				t.Fatalf("executeQueries() error = %v, want refused %t", err, tt.refused)
+			}
+			if ran := len(srv.Executed()) > before; ran == tt.refused {
+				t.Errorf("statements executed = %t, want %t", ran, !tt.refused)
+			}
+		})
+	}
+
+	// Without the flag, the same statements run
+	config := &Config{output: db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, []string{srv.DSN()}, "UPDATE users SET active = 0"); err != nil {
+		t.Errorf("executeQueries() without --safe-updates error = %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// histogramStatementWidth is how much of a statement heads its histogram
+const histogramStatementWidth = 60
+
+// writeRowCountHistograms prints, for each statement whose every successful
+// result is a single number, such as SELECT COUNT(*), how the numbers are
+// distributed across the instances. Statements with any other result are left
+// out; instances where the statement failed, was skipped or returned NULL are
+// counted apart.
+func writeRowCountHistograms(w io.Writer, instanceList []string, allResults map[string][]db.QueryResult) {
+	statements := 0
+	for _, instanceDSN := range instanceList {
+		statements = max(statements, len(allResults[instanceDSN]))
+	}
+
+	for i := 0; i < statements; i++ {
+		var h db.Histogram
+		stmt := ""
+		missing := 0
+		numeric := true
+		for _, instanceDSN := range instanceList {
+			results := allResults[instanceDSN]
+			if i >= len(results) {
+				missing++
+				continue
+			}
+			res := results[i]
+			if stmt == "" {
+				stmt = res.Statement
+			}
+			if res.Err != nil || res.Skipped {
+				missing++
+				continue
+			}
+			v, ok := db.SingleValue(res)
+			if !ok && len(res.Columns) == 1 && len(res.Rows) == 1 && res.Rows[0][0] == nil {
+				missing++
+				continue
+			}
+			if !ok {
+				numeric = false
+				break
+			}
+			h.Add(v)
+		}
+		if !numeric || h.N == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "Distribution of statement %d across %d instance(s): %s\n",
+			i+1, h.N, truncateText(oneLine(stmt), histogramStatementWidth))
+		fmt.Fprintf(w, "  min %s, max %s\n", formatValue(h.Min), formatValue(h.Max))
+		db.WriteHistogram(w, &h, db.ValueRange)
+		if missing > 0 {
+			fmt.Fprintf(w, "  %d instance(s) without a value (failed, skipped or NULL)\n", missing)
+		}
+	}
+}
+
+// formatValue prints a number without exponent or trailing zeros
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+func TestWriteRowCountHistograms(t *testing.T) {
+	count := func(instance, stmt string, v interface{}) db.QueryResult {
+		return db.QueryResult{Instance: instance, Statement: stmt, Columns: []string{"COUNT(*)"}, Rows: [][]interface{}{{v}}, RowCount: 1}
+	}
+	const (
+		countOrders = "SELECT COUNT(*)\nFROM orders"
+		listTables  = "SHOW TABLES"
+		sumRefunds  = "SELECT SUM(amount) FROM refunds"
+	)
+	tables := func(instance string) db.QueryResult {
+		return db.QueryResult{Instance: instance, Statement: listTables, Columns: []string{"Tables_in_shop"}, Rows: [][]interface{}{{"orders"}}, RowCount: 1}
+	}
+	instances := []string{"db1", "db2", "db3", "db4", "db5"}
+	allResults := map[string][]db.QueryResult{
+		"db1": {count("db1", countOrders, []byte("0")), tables("db1"), count("db1", sumRefunds, []byte("10"))},
+		"db2": {count("db2", countOrders, []byte("9")), tables("db2"), count("db2", sumRefunds, nil)},
+		"db3": {count("db3", countOrders, []byte("12")), tables("db3"), count("db3", sumRefunds, []byte("12.5"))},
+		"db4": {{Instance: "db4", Statement: countOrders, Err: errors.New("Error 1146: Table 'shop.orders' doesn't exist")}, tables("db4"), count("db4", sumRefunds, []byte("11"))},
+		"db5": {{Instance: "db5", Err: errors.New("dial tcp: connection refused"), ConnectFailed: true}},
+	}
+
+	var buf bytes.Buffer
+	writeRowCountHistograms(&buf, instances, allResults)
+	want := "" +
+		"Distribution of statement 1 across 3 instance(s): SELECT COUNT(*) FROM orders\n" +
+		"  min 0, max 12\n" +
+		"      < 1  1 ####################\n" +
+		"   [1, 2)  0\n" +
+		"   [2, 4)  0\n" +
+		"   [4, 8)  0\n" +
+		"  [8, 16)  2 ########################################\n" +
+		"  2 instance(s) without a value (failed, skipped or NULL)\n" +
+		"Distribution of statement 3 across 3 instance(s): SELECT SUM(amount) FROM refunds\n" +
+		"  min 10, max 12.5\n" +
+		"  [8, 16)  3 ########################################\n" +
+		"  2 instance(s) without a value (failed, skipped or NULL)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeRowCountHistograms() =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(buf.String(), "SHOW TABLES") {
+		t.Error("a statement returning text got a histogram")
+	}
+}
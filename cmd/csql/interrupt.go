@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// interruptSignals are the signals that cancel a run
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// interruptError is the cause of a run cancelled by a signal. It wraps
+// context.Canceled, so the statements it cut short count as interrupted.
+type interruptError struct {
+	signal os.Signal
+}
+
+func (e *interruptError) Error() string {
+	return fmt.Sprintf("cancelled by user (%v)", e.signal)
+}
+
+func (e *interruptError) Unwrap() error { return context.Canceled }
+
+// notifyInterrupt returns a context cancelled by the first SIGINT or SIGTERM. The
+// running statements are then killed on the server, the remaining ones skipped,
+// and the run ends with the results collected so far. A second signal exits at
+// once. stop restores the default signal handling.
+func (c *Config) notifyInterrupt(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, interruptSignals...)
+	c.interruptible = true
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			c.sink().Printf(db.StreamDiagnostics, "\n%v received: cancelling the running statements (again to exit now)\n", sig)
+			cancel(&interruptError{signal: sig})
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			os.Exit(c.exitCodes.code(categoryInterrupted))
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel(nil)
+	}
+}
+
+// writeInterruptSummary reports how far a run cancelled by a signal got, when any
+// statement was cut short
+func writeInterruptSummary(ctx context.Context, config *Config, summary runSummary) error {
+	var interrupt *interruptError
+	if !errors.As(context.Cause(ctx), &interrupt) {
+		return nil
+	}
+	var completed, interrupted, instances int
+	for _, s := range summary.Instances {
+		completed += s.Executed - s.Failed
+		interrupted += s.Interrupted
+		if s.Interrupted > 0 {
+			instances++
+		}
+	}
+	if interrupted == 0 {
+		return nil
+	}
+	config.sink().Printf(db.StreamDiagnostics, "Run cancelled by user: %d statement(s) completed, %d interrupted or skipped on %d instance(s)\n",
+		completed, interrupted, instances)
+	return interrupt
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+func TestExecuteQueries_Interrupt(t *testing.T) {
+	useFakeDriver(t)
+	instances, stuck := stragglerFleet(t, "interrupt")
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{output: db.NewOutputSink(&stdout, &stderr)}
+	ctx, stop := config.notifyInterrupt(context.Background())
+	defer stop()
+	time.AfterFunc(100*time.Millisecond, func() { _ = syscall.Kill(syscall.Getpid(), syscall.SIGINT) })
+
+	start := time.Now()
+	err := executeQueries(ctx, config, instances, "UPDATE t SET n = n + 1;\nSELECT 1")
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("run took %v, want it cancelled by the signal", elapsed)
+	}
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryInterrupted || exitErr.code != 130 {
+		t.Fatalf("executeQueries() error = %v, want the interrupted exit code 130", err)
+	}
+	if !strings.Contains(err.Error(), "cancelled by user") {
+		t.Errorf("executeQueries() error = %v, want it to say the user cancelled the run", err)
+	}
+	if executed := strings.Join(stuck.Executed(), "\n"); !strings.Contains(executed, "KILL QUERY 42") {
+		t.Errorf("stuck instance executed:\n%s\nwant KILL QUERY 42", executed)
+	}
+	want := "Run cancelled by user: 4 statement(s) completed, 2 interrupted or skipped on 1 instance(s)"
+	if !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr =\n%s\nwant %q", stderr.String(), want)
+	}
+}
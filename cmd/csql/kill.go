@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// commandKill is the first argument that selects the kill command
+const commandKill = "kill"
+
+// defaultKillLog is the audit file of the kill command without --kill-log
+const defaultKillLog = "~/.csql/kill.log"
+
+// killQueryWidth is how much of a thread's statement the kill listing shows
+const killQueryWidth = 80
+
+// killTarget is a thread selected by the kill command, with the outcome of its KILL
+type killTarget struct {
+	process db.Process
+	err     error // Why the KILL failed; nil when it was killed or had exited
+	exited  bool  // The thread was gone by the time its KILL arrived
+}
+
+// matchesKill reports whether a thread is one the kill command should kill: its
+// statement matches --match and it passes the --user and --min-time filters
+func (c *Config) matchesKill(p db.Process) bool {
+	return p.Info != "" && c.killMatch.MatchString(p.Info) &&
+		(c.KillUser == "" || p.User == c.KillUser) &&
+		p.Time >= int64(c.KillMinTime)
+}
+
+// runKill kills the threads matching the kill filters on every instance: it reads
+// each processlist, lists the matches, asks for confirmation unless --yes is given,
+// then sends KILL for each and reports its outcome. Every KILL sent is appended to
+// the kill log (--kill-log, by default ~/.csql/kill.log); if that file cannot be
+// opened nothing is killed.
+func runKill(ctx context.Context, config *Config, instanceList []string) error {
+	config.infof("Reading the processlist on %d instance(s) (%s)...\n", len(instanceList), config.concurrencyNote(len(instanceList)))
+	opts := config.connectOptions()
+
+	lists := make([][]db.Process, len(instanceList))
+	listErrs := make([]error, len(instanceList))
+	config.forEachInstance(instanceList, func(i int, instanceDSN string) {
+		lists[i], listErrs[i] = db.ListProcesses(ctx, instanceDSN, opts)
+	})
+
+	unreachable := 0
+	byInstance := make([][]*killTarget, len(instanceList))
+	total, instancesWithTargets := 0, 0
+	for i, instanceDSN := range instanceList {
+		if listErrs[i] != nil {
+			unreachable++
+			config.sink().Printf(db.StreamDiagnostics, "Error: %s: %v\n", db.MaskDSN(instanceDSN), listErrs[i])
+			continue
+		}
+		for _, p := range lists[i] {
+			if config.matchesKill(p) {
+				byInstance[i] = append(byInstance[i], &killTarget{process: p})
+			}
+		}
+		if len(byInstance[i]) > 0 {
+			total += len(byInstance[i])
+			instancesWithTargets++
+		}
+	}
+
+	if total == 0 {
+		config.infof("No threads match %q.\n", config.KillMatch)
+		return killExit(config, unreachable, len(instanceList), 0, 0)
+	}
+	_ = config.sink().Block(db.StreamResults, func(w io.Writer) {
+		writeKillTargets(w, byInstance)
+	})
+
+	if !config.Yes {
+		confirmed, err := config.confirm(fmt.Sprintf("Kill %d thread(s) on %d instance(s)? [y/N] ", total, instancesWithTargets))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			config.infof("Nothing was killed.\n")
+			return nil
+		}
+	}
+
+	audit, err := config.openKillLog()
+	if err != nil {
+		return fmt.Errorf("failed to open the kill log; nothing was killed: %w", err)
+	}
+	defer audit.Close()
+
+	var auditMu sync.Mutex
+	config.forEachInstance(instanceList, func(i int, instanceDSN string) {
+		targets := byInstance[i]
+		if len(targets) == 0 {
+			return
+		}
+		ids := make([]uint64, len(targets))
+		for j, target := range targets {
+			ids[j] = target.process.ID
+		}
+		for j, err := range db.KillThreads(ctx, instanceDSN, ids, opts) {
+			targets[j].exited = db.IsNoSuchThread(err)
+			if !targets[j].exited {
+				targets[j].err = err
+			}
+		}
+		auditMu.Lock()
+		defer auditMu.Unlock()
+		for _, target := range targets {
+			fmt.Fprintln(audit, killAuditLine(time.Now(), target))
+		}
+	})
+
+	failed := 0
+	_ = config.sink().Block(db.StreamResults, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, targets := range byInstance {
+			for _, target := range targets {
+				if target.err != nil {
+					failed++
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\n", db.MaskDSN(target.process.Instance), target.process.ID, target.outcome())
+			}
+		}
+		tw.Flush()
+	})
+	config.infof("Killed %d of %d thread(s).\n", total-failed, total)
+	return killExit(config, unreachable, len(instanceList), failed, total)
+}
+
+// forEachInstance calls fn for every instance, in order or, with --concurrent, at
+// most --max-parallel at a time
+func (c *Config) forEachInstance(instanceList []string, fn func(i int, instanceDSN string)) {
+	if !c.Concurrent {
+		for i, instanceDSN := range instanceList {
+			fn(i, instanceDSN)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, c.parallelism(len(instanceList)))
+	for i, instanceDSN := range instanceList {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, instanceDSN string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			fn(i, instanceDSN)
+		}(i, instanceDSN)
+	}
+	wg.Wait()
+}
+
+// writeKillTargets lists the threads about to be killed, in instance order
+func writeKillTargets(w io.Writer, byInstance [][]*killTarget) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "instance\tid\tuser\ttime\tquery")
+	for _, targets := range byInstance {
+		for _, target := range targets {
+			p := target.process
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%ds\t%s\n", db.MaskDSN(p.Instance), p.ID, p.User, p.Time,
+				truncateText(oneLine(p.Info), killQueryWidth))
+		}
+	}
+	tw.Flush()
+}
+
+// outcome describes what the KILL of a thread did
+func (t *killTarget) outcome() string {
+	switch {
+	case t.err != nil:
+		return "failed: " + t.err.Error()
+	case t.exited:
+		return "killed (had already exited)"
+	default:
+		return "killed"
+	}
+}
+
+// openKillLog opens the audit file every KILL is appended to: --kill-log, or else
+// defaultKillLog, creating its directory
+func (c *Config) openKillLog() (*os.File, error) {
+	logPath := c.KillLog
+	if logPath == "" {
+		logPath = defaultKillLog
+	}
+	path, err := expandPath(logPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+}
+
+// killAuditLine records one KILL for the kill log: when it was sent, where, which
+// thread and statement, and its outcome. The instance's password is masked.
+func killAuditLine(at time.Time, t *killTarget) string {
+	p := t.process
+	return fmt.Sprintf("%s\t%s\tid=%d\tuser=%s\thost=%s\tdb=%s\ttime=%ds\t%s\tquery=%s",
+		at.UTC().Format(time.RFC3339), db.MaskDSN(p.Instance), p.ID, p.User, p.Host, p.DB, p.Time, t.outcome(), oneLine(p.Info))
+}
+
+// oneLine collapses the whitespace of a statement, line breaks included
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin; only
+// y or yes confirms
+func (c *Config) confirm(prompt string) (bool, error) {
+	c.sink().Printf(db.StreamDiagnostics, "%s", prompt)
+	input := c.confirmInput
+	if input == nil {
+		input = os.Stdin
+	}
+	answer, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read the confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// killExit classifies the end of the kill command: instances whose processlist
+// could not be read are a connection error, failed KILLs a query error
+func killExit(config *Config, unreachable, instances, failed, total int) error {
+	var category exitCategory
+	var err error
+	switch {
+	case unreachable > 0:
+		category = categoryConnectionError
+		err = fmt.Errorf("%s: the processlist of %d of %d instance(s) could not be read", category, unreachable, instances)
+	case failed > 0:
+		category = categoryQueryError
+		err = fmt.Errorf("%s: %d of %d KILL(s) failed", category, failed, total)
+	default:
+		return nil
+	}
+	code := config.exitCodes.code(category)
+	if code == 0 {
+		return nil
+	}
+	return &exitError{category: category, code: code, err: err}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+const testProcessListQuery = "SELECT ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO " +
+	"FROM information_schema.PROCESSLIST WHERE ID <> CONNECTION_ID()"
+
+// processList scripts a processlist of rows (id, user, time, info) on srv
+func processList(srv *dbtest.Server, rows ...[]driver.Value) {
+	resp := dbtest.Response{Columns: []string{"ID", "USER", "HOST", "DB", "COMMAND", "TIME", "STATE", "INFO"}}
+	for _, row := range rows {
+		command := "Query"
+		if row[3] == nil {
+			command = "Sleep"
+		}
+		resp.Rows = append(resp.Rows, []driver.Value{row[0], row[1], "10.0.0.7:51234", "shop", command, row[2], nil, row[3]})
+	}
+	srv.Handle(testProcessListQuery, resp)
+}
+
+// kills returns the KILL statements srv received
+func kills(srv *dbtest.Server) []string {
+	var out []string
+	for _, query := range srv.Executed() {
+		if strings.HasPrefix(query, "KILL ") {
+			out = append(out, query)
+		}
+	}
+	return out
+}
+
+func TestRunKill(t *testing.T) {
+	useFakeDriver(t)
+	first := dbtest.NewServer(t, "kill-1")
+	processList(first,
+		[]driver.Value{int64(10), "app", int64(45), "SELECT *\n  FROM big_table WHERE id > 5"},
+		[]driver.Value{int64(11), "app", int64(5), "SELECT * FROM big_table"},     // Too young
+		[]driver.Value{int64(12), "report", int64(90), "SELECT * FROM big_table"}, // Other user
+		[]driver.Value{int64(13), "app", int64(300), nil},                         // Idle
+		[]driver.Value{int64(14), "app", int64(60), "SELECT * FROM small_table"},
+	)
+	second := dbtest.NewServer(t, "kill-2")
+	processList(second, []driver.Value{int64(20), "app", int64(31), "SELECT id FROM big_table"})
+	second.Handle("KILL 20", dbtest.Response{Err: &mysql.MySQLError{Number: 1094, Message: "Unknown thread id: 20"}})
+	instances := []string{first.DSN(), second.DSN()}
+
+	newConfig := func(answer string, stdout *bytes.Buffer) *Config {
+		return &Config{
+			Kill:         true,
+			KillMatch:    "SELECT.*FROM big_table",
+			KillUser:     "app",
+			KillMinTime:  30,
+			KillLog:      filepath.Join(t.TempDir(), "kill.log"),
+			killMatch:    regexp.MustCompile("(?s)SELECT.*FROM big_table"),
+			confirmInput: strings.NewReader(answer),
+			output:       db.NewOutputSink(stdout, &bytes.Buffer{}),
+		}
+	}
+
+	t.Run("declined", func(t *testing.T) {
+		var stdout bytes.Buffer
+		config := newConfig("n\n", &stdout)
+		if err := runKill(context.Background(), config, instances); err != nil {
+			t.Fatalf("runKill() error = %v", err)
+		}
+		if got := append(kills(first), kills(second)...); len(got) != 0 {
+			t.Errorf("sent %q without confirmation", got)
+		}
+		if _, err := os.Stat(config.KillLog); !os.IsNotExist(err) {
+			t.Errorf("kill log was created although nothing was killed: %v", err)
+		}
+		for _, want := range []string{"kill-1:3306", "10", "45s", "SELECT * FROM big_table WHERE id > 5", "kill-2:3306", "20"} {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("listing is missing %q:\n%s", want, stdout.String())
+			}
+		}
+	})
+
+	t.Run("confirmed", func(t *testing.T) {
+		var stdout bytes.Buffer
+		config := newConfig("yes\n", &stdout)
+		if err := runKill(context.Background(), config, instances); err != nil {
+			t.Fatalf("runKill() error = %v, want nil as a thread that exited counts as killed", err)
+		}
+		if got := kills(first); !slices.Equal(got, []string{"KILL 10"}) {
+			t.Errorf("first instance got %q, want only KILL 10", got)
+		}
+		if got := kills(second); !slices.Equal(got, []string{"KILL 20"}) {
+			t.Errorf("second instance got %q, want KILL 20", got)
+		}
+		if !strings.Contains(stdout.String(), "killed (had already exited)") {
+			t.Errorf("output does not note the exited thread:\n%s", stdout.String())
+		}
+
+		audit, err := os.ReadFile(config.KillLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(audit)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("kill log has %d line(s), want 2:\n%s", len(lines), audit)
+		}
+		for _, want := range []string{"user:****@tcp(kill-1:3306)/app", "id=10", "user=app", "time=45s", "query=SELECT * FROM big_table WHERE id > 5"} {
+			if !strings.Contains(string(audit), want) {
+				t.Errorf("kill log is missing %q:\n%s", want, audit)
+			}
+		}
+		if strings.Contains(string(audit)+stdout.String(), "secret") {
+			t.Errorf("the password is exposed:\n%s\n%s", audit, stdout.String())
+		}
+	})
+
+	t.Run("default kill log", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		config := newConfig("", &bytes.Buffer{})
+		config.KillLog = ""
+		config.Yes = true
+		if err := runKill(context.Background(), config, instances); err != nil {
+			t.Fatalf("runKill() error = %v", err)
+		}
+
+		audit, err := os.ReadFile(filepath.Join(home, ".csql", "kill.log"))
+		if err != nil {
+			t.Fatalf("no kill log without --kill-log: %v", err)
+		}
+		for _, want := range []string{"user:****@tcp(kill-1:3306)/app\tid=10", "user:****@tcp(kill-2:3306)/app\tid=20"} {
+			if !strings.Contains(string(audit), want) {
+				t.Errorf("kill log is missing %q:\n%s", want, audit)
+			}
+		}
+	})
+
+	t.Run("unwritable kill log", func(t *testing.T) {
+		before := len(kills(first))
+		config := newConfig("", &bytes.Buffer{})
+		config.KillLog = filepath.Join(t.TempDir(), "missing", "dir", "kill.log")
+		if err := os.WriteFile(filepath.Dir(filepath.Dir(config.KillLog)), nil, 0600); err != nil {
+			t.Fatal(err) // A file where the log's directory would be
+		}
+		config.Yes = true
+		if err := runKill(context.Background(), config, instances); err == nil || !strings.Contains(err.Error(), "nothing was killed") {
+			t.Errorf("runKill() error = %v, want the kill log reported", err)
+		}
+		if got := kills(first); len(got) != before {
+			t.Errorf("sent %q although the kill log could not be opened", got[before:])
+		}
+	})
+
+	t.Run("failed kill", func(t *testing.T) {
+		first.Handle("KILL 10", dbtest.Response{Err: &mysql.MySQLError{Number: 1095, Message: "You are not owner of thread 10"}})
+		var stdout bytes.Buffer
+		config := newConfig("", &stdout)
+		config.Yes = true
+		err := runKill(context.Background(), config, instances)
+		var exitErr *exitError
+		if !errors.As(err, &exitErr) || exitErr.category != categoryQueryError {
+			t.Fatalf("runKill() error = %v, want a query-error exit", err)
+		}
+		if !strings.Contains(stdout.String(), "failed: Error 1095") {
+			t.Errorf("output does not report the failed KILL:\n%s", stdout.String())
+		}
+	})
+}
+
+func TestConfig_Validate_Kill(t *testing.T) {
+	base := Config{Instances: "user:pass@tcp(host:3306)/db"}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "kill", modify: func(c *Config) { c.Kill, c.KillMatch, c.KillMinTime = true, "FROM big_table", 30 }},
+		{name: "needs a pattern", modify: func(c *Config) { c.Kill = true }, wantErr: "requires --match"},
+		{name: "invalid pattern", modify: func(c *Config) { c.Kill, c.KillMatch = true, "(" }, wantErr: "invalid --match"},
+		{name: "negative time", modify: func(c *Config) { c.Kill, c.KillMatch, c.KillMinTime = true, "x", -1 }, wantErr: "--min-time"},
+		{name: "no statements", modify: func(c *Config) { c.Kill, c.KillMatch, c.Statements = true, "x", "SELECT 1" }, wantErr: "runs no statements"},
+		{name: "filters need kill", modify: func(c *Config) { c.Statements, c.Yes = "SELECT 1", true }, wantErr: "require the kill command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			err := config.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
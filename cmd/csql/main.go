@@ -2,17 +2,26 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/sqllog"
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
 // Define a list of colors to cycle through for different instances
@@ -27,25 +36,293 @@ var instanceColors = []*color.Color{
 
 // Config holds all configuration for the CLI application
 type Config struct {
-	Instances   string
-	Statements  string
-	File        string
-	JSONFile    string
-	SQLFile     string
-	Stdin       bool
-	Concurrent  bool
-	TableFormat bool
-	Verbose     int
+	Instances    string
+	InstancesEnv string // Environment variable holding the comma-separated DSNs, to keep credentials out of shell history
+	Statements   string
+	File         string
+	JSONFile     string
+	SQLFile      string
+	Stdin        bool
+	Runbook      string // YAML file declaring both the instances and the statements
+	Concurrent   bool
+	TableFormat  bool
+	Verbose      int
+
+	InputFormat         string // How the SQL source is read: sql (default), binlog-text or slow-log
+	IncludeSessionSetup bool   // With binlog-text, keep SET TIMESTAMP, SET @@session... and similar setup
+	BinlogDatabase      string // With binlog-text, only statements for this database
+	BinlogServerID      uint   // With binlog-text, only events from this server_id (0 = all)
+
+	ForEachFile string // Run the statements once per line of this file, with {{item}} replaced by the line
+
+	ImportCSV   string // Insert the rows of this CSV file, whose header names the columns, as the statements
+	ImportTable string // Table the --import-csv rows are inserted into, as table or db.table
+	BatchSize   int    // Rows per INSERT statement for --import-csv (0 = db.DefaultValuesPerInsert)
+
+	ReplayTiming bool           // With slow-log, dispatch statements at their logged pace and compare latencies
+	ReplaySpeed  float64        // How much faster than logged --replay-timing runs (0 = 1)
+	replayEvents []sqllog.Event // Statements parsed from the slow log, with their times
+
+	TableRowThreshold int    // Results with more rows skip tablewriter under --table (0 = no limit)
+	LargeTable        string // What --table does over the threshold: fallback or chunk
+	TableStyle        string // Border style of --table: mysql, markdown, box or ascii
+	HeaderTypes       bool   // Show each column's type under its name in the --table header
+	TableSampleRows   int    // Rows sampled for column widths (and rows per chunk) with --table-large=chunk
+
+	Align       bool // Pad the default output's columns to their widths
+	AlignSample int  // Rows sampled for column widths with --align
+
+	VerticalSeparator int  // Asterisks on each side of the row markers of vertical (\G) output
+	VerticalHeaders   bool // Head vertical rows with the instance and leave a blank line between instances
+
+	Output          string // Output mode: text (default) or sql
+	OutputSQLTable  string // Target table for --output sql, as table or db.table
+	ValuesPerInsert int    // Rows batched per INSERT statement for --output sql
+
+	Format             string      // Result format: text (default), json (one object per result), ndjson (one object per row) or csv
+	JSONPretty         bool        // Indent JSON output for reading instead of one object per line
+	CSVInstanceColumn  bool        // In CSV output, lead each row with its instance instead of a comment line per block
+	CSVNull            string      // How NULL is written in CSV output ("" = an empty field)
+	MarkdownItalicNull bool        // In Markdown output, write NULL as an italic *NULL* instead of NULL
+	csvHeaders         *csvHeaders // Headers written during a run with --format csv
+
+	MaxTotalRows   int64 // Run-wide cap on rows received across all instances (0 = unlimited)
+	MaxTotalBytes  int64 // Run-wide cap on bytes received across all instances (0 = unlimited)
+	MaxResultBytes int64 // Per-statement cap on the approximate size of a result's rows (0 = unlimited)
+
+	MaxErrorsPerInstance int // Skip the rest of an instance's statements once this many failed (0 = unlimited)
+
+	MaxErrors  int         // Stop the run once this many instances had a statement fail (0 = unlimited)
+	errorLimit *errorLimit // Counts failed instances during a run with MaxErrors or FailFast
+	FailFast   bool        // Stop the run at the first instance with a failed statement (MaxErrors of 1)
+
+	Timeout  time.Duration            // Cancel an instance's statements once they have run this long (0 = no limit)
+	timeouts map[string]time.Duration // Timeouts of --json servers naming their own, by DSN
+
+	QueryTimeout time.Duration // Interrupt each statement that runs longer than this and go on to the next (0 = no limit)
+
+	FirstRowOnly bool // Keep and print only the first row of each result
+
+	StatusLine bool // Print a one-line STATUS summary after each instance's output
+
+	RowCountHistogram bool // Print how single-number results, e.g. of COUNT(*), are distributed across instances
+
+	ErrorsFirst bool // Print the output of instances with failed statements before the others
+
+	ShowColumnsTypes bool // Print each result's column types as a JSON line instead of its rows
+
+	NullOutput bool // Run statements with Exec and print only an OK/ERROR line per instance
+
+	Diff              bool   // Print which instances agree on each statement's result instead of the results
+	DiffOrdered       bool   // Compare rows in the order they came in rather than as a multiset
+	DiffIgnoreColumns string // Comma-separated columns left out of the comparison
+	VerifyOrder       bool   // Report instances returning the same rows as the others in another order
+
+	CanonicalColumns bool // Reorder each result's columns to the first instance's order for the same statement
+	CoerceColumns    bool // Give a statement's results on every instance the union of their columns, NULL-filled
+
+	Sort       bool          // Sort each result's rows on the client before printing
+	SortLocale string        // Compare text with this locale's collation instead of byte by byte
+	rowSorter  *db.RowSorter // Built from SortLocale by Validate
+
+	AllowDropDatabase bool // Run DROP DATABASE and DROP SCHEMA statements, which are refused otherwise
+	SafeUpdates       bool // Refuse UPDATE and DELETE statements without a WHERE or LIMIT clause
+
+	ExplainOnSlow   time.Duration // EXPLAIN statements that take longer than this on an instance (0 = never)
+	ExplainOnError  bool          // EXPLAIN statements that fail with an execution error, e.g. a lock wait timeout
+	ExplainWarnings bool          // Follow each EXPLAIN with SHOW WARNINGS and print them, e.g. the rewritten query
+
+	VerifyCharset bool   // Compare the sessions' character set variables across the fleet before running statements
+	ExpectCharset string // Character set every session is expected to use, e.g. utf8mb4 (implies VerifyCharset)
+	StrictCharset bool   // Abort the run before any statement if the character sets do not match
+
+	StripComments bool // Remove comments (except optimizer hints) from executed SQL
+	FailoverAware bool // Reconnect with fresh DNS and retry once on read-only/connection-lost errors
+	ShowQueryID   bool // Mark each executed statement with a /* csql:<id> */ comment and echo the id
+
+	Terminator string        // How statements are separated: semicolon, line:<token> or null
+	terminator db.Terminator // Parsed from Terminator by Validate
+
+	PrettySQL bool   // Show statements with their line breaks and syntax highlighting
+	Color     string // When to emit ANSI colors: always, auto or never
+
+	Lang     string      // Language of result messages; empty means from LC_ALL, LC_MESSAGES or LANG
+	messages db.Messages // Resolved from Lang by Validate
+
+	Target string // Which tagged servers to run against: primary, replica or all
+	Lint   bool   // Check statements client-side before connecting to any instance
+
+	CheckAuth bool // Only verify each instance's credentials and database; run no statements
+
+	ExplainDSN bool                        // Only print where each instance's DSN parts came from; connect to nothing
+	dsnSources map[string]db.DSNProvenance // Where each filled DSN's parts came from, by DSN
+	myCnfPath  string                      // The option file DSNs were filled from
+
+	Kill         bool           // Run the kill command instead of statements
+	KillMatch    string         // Regular expression matched against each thread's statement
+	KillUser     string         // Only kill threads of this user
+	KillMinTime  int            // Only kill threads running for at least this many seconds
+	KillLog      string         // Append a line per KILL sent to this audit file ("" = defaultKillLog)
+	Yes          bool           // Kill without asking for confirmation
+	killMatch    *regexp.Regexp // Compiled from KillMatch by Validate
+	confirmInput io.Reader      // Where confirmations are read from (nil means stdin)
+
+	ValidateConfig bool // Run the validate-config command on the --json servers file instead of statements
+	ConfigSchema   bool // With validate-config, print the JSON Schema of servers files instead
+	StrictConfig   bool // Reject unknown keys in the --json servers file
+
+	runbookInstances []string // Instance DSNs from --runbook
+
+	Failover bool                // Treat servers sharing a group as alternatives, tried in order
+	groups   map[string][]string // With --failover: first member DSN -> all members, in order
+
+	output *db.OutputSink // Serializes all output (nil means db.DefaultOutput)
+	clock  *runClock      // Client time spent printing each instance's results during a run
+
+	Report string // Write a JSON run report to this file at the end of the run
+
+	Benchmark  bool // Run the statements repeatedly and report latency statistics instead of rows
+	Iterations int  // How often --benchmark runs the statements (0 = defaultBenchmarkIterations)
+
+	BarrierIgnoreErrors bool // Run the phases after a "-- csql: barrier" even if statements before it failed
+
+	StragglerAfter   time.Duration // List the instances a concurrent run is still waiting on after this long (0 = never)
+	StragglerTimeout time.Duration // Cancel the instances a concurrent run is still waiting on after this long (0 = never)
+
+	OutputDir string // Write each instance's results to its own file in this directory
+	Tee       string // Also write all results to this file
+	Parquet   string // Write the rows of all results to this Parquet file instead of printing them
+
+	ExitCodeMap  string      // Overrides of the default exit code per category, e.g. "query-error=0"
+	exitCodes    exitCodeMap // Parsed from ExitCodeMap by Validate
+	IgnoreErrors bool        // Exit 0 even though statements failed or instances were unreachable
+
+	interruptible bool // SIGINT and SIGTERM cancel the run, killing its running statements
+
+	PreConnect  bool             // Open all instance connections concurrently before running statements
+	MaxParallel int              // Maximum instances connected to or run on at once (0 = unlimited)
+	RequireAll  bool             // Abort the run if any instance fails to pre-connect
+	pool        *db.InstancePool // Warm sessions opened by --pre-connect
+
+	PasswordPrompt bool   // Read a password from the terminal for the DSNs that have none
+	password       string // The password read for PasswordPrompt
+	DefaultsFile   string // Option file to fill DSNs from instead of ~/.my.cnf; must exist
+
+	SSH           string            // Reach the instances through this bastion, as [user@]host[:port]
+	SSHKey        string            // Private key for the bastions ("" = the keys in ~/.ssh and ssh-agent)
+	SSHKnownHosts string            // known_hosts file the bastions' host keys are checked against
+	bastions      map[string]string // Bastions of --json servers naming their own, by DSN ("" = direct)
+	tunnels       *db.Tunnels       // Opened by openTunnels when any instance is behind a bastion
+
+	Proxy string // Connect through this SOCKS5 proxy, as socks5://[user:password@]host:port
+
+	TLSMode string // Encrypt connections: disable, preferred, require, verify-ca or verify-identity ("" = as each DSN says)
+	TLSCA   string // CA certificates (PEM) server certificates are checked against ("" = the system's)
+	TLSCert string // Client certificate (PEM), for servers that require one
+	TLSKey  string // Key of TLSCert
+
+	ConnectRetries int           // Reconnect attempts, backing off, while a server has too many connections (1040/1203)
+	ConnectTimeout time.Duration // Give up connecting to an instance after this long (0 = the driver's default)
+	ReadTimeout    time.Duration // Fail a statement when the server sends nothing for this long (0 = wait forever)
+	WriteTimeout   time.Duration // Fail a statement when the server takes no data for this long (0 = wait forever)
+}
+
+// Supported output modes
+const (
+	outputText = "text"
+	outputSQL  = "sql"
+)
+
+// Supported --format values
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatNDJSON   = "ndjson"
+	formatMarkdown = "markdown"
+	formatXML      = "xml"
+)
+
+// Supported --input-format values
+const (
+	inputSQL        = "sql"
+	inputBinlogText = "binlog-text"
+	inputSlowLog    = "slow-log"
+)
+
+// Supported --color modes
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// stdoutIsTerminal reports whether stdout is a terminal; tests substitute a stub
+var stdoutIsTerminal = func() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// colorEnabled decides whether to emit ANSI colors for a --color mode. In auto mode
+// colors need a terminal and are turned off by a non-empty NO_COLOR.
+func colorEnabled(mode string, isTerminal bool, noColorEnv bool) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	default:
+		return isTerminal && !noColorEnv
+	}
+}
+
+// applyColorMode turns color output on or off for the whole process
+func applyColorMode(mode string) {
+	color.NoColor = !colorEnabled(mode, stdoutIsTerminal(), os.Getenv("NO_COLOR") != "")
 }
 
 // Server represents a database server configuration
 type Server struct {
-	DSN      string `json:"dsn,omitempty"`      // Traditional DSN format
-	User     string `json:"user,omitempty"`     // Separate user field
-	Password string `json:"password,omitempty"` // Separate password field
-	Host     string `json:"host,omitempty"`     // Separate host field
-	Port     string `json:"port,omitempty"`     // Separate port field
-	Database string `json:"database,omitempty"` // Separate database field
+	DSN      string   `json:"dsn,omitempty"`      // Traditional DSN format
+	User     string   `json:"user,omitempty"`     // Separate user field
+	Password string   `json:"password,omitempty"` // Separate password field
+	Host     string   `json:"host,omitempty"`     // Separate host field
+	Port     string   `json:"port,omitempty"`     // Separate port field
+	Database string   `json:"database,omitempty"` // Separate database field
+	Tags     []string `json:"tags,omitempty"`     // Free-form labels, e.g. "primary" or "replica"
+	Group    string   `json:"group,omitempty"`    // Servers sharing a group are alternatives under --failover
+	SSH      string   `json:"ssh,omitempty"`      // Bastion to reach the server through, overriding --ssh; "none" for direct
+	Timeout  string   `json:"timeout,omitempty"`  // How long the server's statements may run, overriding --timeout, e.g. "5m"
+}
+
+// defaultParallelPerCPU is how many instances run at once per CPU when
+// --max-parallel is not given; most of the time is spent waiting on the servers.
+// An explicit --max-parallel 0 lifts the limit.
+const defaultParallelPerCPU = 4
+
+// Supported --target values
+const (
+	targetAll     = "all"
+	targetPrimary = "primary"
+	targetReplica = "replica"
+)
+
+// hasTag reports whether the server carries the given tag (case-insensitive)
+func (s *Server) hasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(strings.TrimSpace(t), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTarget reports whether the server is selected by a --target value
+func (s *Server) matchesTarget(target string) bool {
+	if target == "" || target == targetAll {
+		return true
+	}
+	return s.hasTag(target)
 }
 
 // BuildDSN constructs a proper DSN from Server fields, handling complex passwords
@@ -90,6 +367,22 @@ func (s *Server) BuildDSN() string {
 	return dsn.String()
 }
 
+// addr returns the host:port the server is reached at, with the defaults BuildDSN
+// fills in
+func (s *Server) addr() string {
+	if s.DSN != "" {
+		return db.InstanceAddr(s.DSN)
+	}
+	host, port := s.Host, s.Port
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "3306"
+	}
+	return host + ":" + port
+}
+
 // parseVerbosityFlags handles -v, -vv, -vvv style flags manually
 func parseVerbosityFlags() (int, []string) {
 	var verbose int
@@ -127,6 +420,16 @@ func (c *Config) LoadFromFlags() error {
 	// Handle verbosity flags first
 	verbose, filteredArgs := parseVerbosityFlags()
 	c.Verbose = verbose
+	if len(filteredArgs) > 0 {
+		switch filteredArgs[0] {
+		case commandKill:
+			c.Kill = true
+			filteredArgs = filteredArgs[1:]
+		case commandValidateConfig:
+			c.ValidateConfig = true
+			filteredArgs = filteredArgs[1:]
+		}
+	}
 
 	// Temporarily replace os.Args for flag parsing
 	originalArgs := os.Args
@@ -134,35 +437,313 @@ func (c *Config) LoadFromFlags() error {
 	defer func() { os.Args = originalArgs }()
 
 	// CLI flags
+	ssh := flag.String("ssh", "", "Reach the instances through this SSH bastion, as [user@]host[:port], by forwarding each instance's host:port from a local port; --json servers may name their own with \"ssh\"")
+	sshKey := flag.String("ssh-key", "", "Private key to authenticate to SSH bastions with (default: ~/.ssh/id_ed25519, id_ecdsa or id_rsa, and the keys in ssh-agent)")
+	sshKnownHosts := flag.String("ssh-known-hosts", "~/.ssh/known_hosts", "known_hosts file the SSH bastions' host keys are checked against")
+	connectRetries := flag.Int("connect-retry-on-too-many-connections", 0, "When a server refuses a connection with too many connections (1040 or 1203), wait and connect again up to this many times, backing off from 0.5s to 8s (0 = fail at once)")
+	connectTimeout := flag.Duration("connect-timeout", 0, "Give up connecting to an instance after this long, e.g. 5s, so unreachable hosts fail fast with a connection timeout (0 = the driver's default); sets the DSN's timeout parameter")
+	readTimeout := flag.Duration("read-timeout", 0, "Fail a statement when the server sends nothing for this long on an established connection, e.g. 30s, so half-dead connections do not hang the run (0 = wait forever); must exceed the slowest statement. Sets the DSN's readTimeout parameter")
+	writeTimeout := flag.Duration("write-timeout", 0, "Fail a statement when the server takes no data for this long on an established connection (0 = wait forever); sets the DSN's writeTimeout parameter")
+	proxyURL := flag.String("proxy", "", "Connect to the instances through this SOCKS5 proxy, as socks5://[user:password@]host:port; TLS to the instances is unaffected")
+	tlsMode := flag.String("tls-mode", "", "Encrypt connections to every instance, overriding the DSNs' tls parameter: disable, preferred (if the server supports it), require, verify-ca (check the certificate's CA) or verify-identity (also check it names the host); defaults to verify-ca with --tls-ca")
+	tlsCA := flag.String("tls-ca", "", "PEM file of the CA certificates server certificates are checked against (default: the system's)")
+	tlsCert := flag.String("tls-cert", "", "PEM file of a client certificate, for servers that require one (with --tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM file of the --tls-cert client certificate's private key")
+	passwordPrompt := flag.Bool("password-prompt", false, "Read a password from the terminal, without echo, and use it for every instance that names a user but no password")
+	defaultsFile := flag.String("defaults-file", "", "Fill missing DSN parts from this option file, e.g. /etc/mysql/client.cnf, instead of ~/.my.cnf; unlike ~/.my.cnf it must exist")
 	instances := flag.String("instances", "", "Comma-separated list of MySQL instance connection strings (user:password@tcp(host:port)/dbname)")
+	instancesEnv := flag.String("instances-env", "", "Read the comma-separated instance DSNs from this environment variable, keeping credentials out of shell history (overrides --json; --instances overrides it); without any source, "+defaultInstancesEnv+" is read")
 	statements := flag.String("statements", "", "Semicolon-separated list of SQL statements to execute")
 	file := flag.String("file", "", "Path to a file containing SQL statements (overrides --statements)")
-	jsonFile := flag.String("json", "", "Path to a JSON file with server and schema information (overrides --instances)")
+	jsonFile := flag.String("json", "", "Path to a JSON file with server and schema information (--instances and --instances-env override it); a comma-separated list merges several files, skipping servers whose host:port an earlier file lists")
+	strictConfig := flag.Bool("strict-config", false, "Reject unknown keys in the --json file (e.g. a misspelled \"passsword\") instead of ignoring them")
+	configSchema := flag.Bool("schema", false, "With validate-config, print the JSON Schema of the --json servers file")
 	sqlFile := flag.String("sqlfile", "", "Path to a .txt file with SQL statements (overrides --statements and --file)")
+	runbook := flag.String("runbook", "", "YAML file declaring both the instances (instances:) and the statements (sql:) to run, instead of the separate flags")
 	stdin := flag.Bool("stdin", false, "Read SQL statements from standard input (pipe support)")
 	concurrent := flag.Bool("concurrent", true, "Run queries against instances concurrently")
+	importCSV := flag.String("import-csv", "", "Insert the rows of this CSV file into --import-table on every instance instead of running SQL; the header row names the columns")
+	importTable := flag.String("import-table", "", "Table (table or db.table) --import-csv inserts into")
+	batchSize := flag.Int("batch-size", 0, fmt.Sprintf("Rows per INSERT statement generated by --import-csv (0 = %d)", db.DefaultValuesPerInsert))
+	forEachFile := flag.String("for-each-file", "", "Render the statements once per line of this file, replacing {{item}} with the line, e.g. a list of tables; blank lines and # comments are skipped")
+	inputFormat := flag.String("input-format", inputSQL, "Format of the SQL source: sql, binlog-text for the output of mysqlbinlog --base64-output=decode-rows -v, or slow-log for a slow query log")
+	includeSessionSetup := flag.Bool("include-session-setup", false, "With --input-format binlog-text, also run the session setup (SET TIMESTAMP, SET @@session...) logged before each statement")
+	binlogDatabase := flag.String("binlog-database", "", "With --input-format binlog-text, only replay statements for this database")
+	binlogServerID := flag.Uint("binlog-server-id", 0, "With --input-format binlog-text, only replay events written by this server_id (0 = all)")
+	replayTiming := flag.Bool("replay-timing", false, "With --input-format slow-log, dispatch each statement at its logged time and compare the replayed latencies with the logged Query_time")
+	replaySpeed := flag.Float64("replay-speed", 1, "With --replay-timing, how much faster than logged to replay, e.g. 2 halves the gaps between statements")
 	tableFormat := flag.Bool("table", false, "Format tabular output with borders")
+	tableRowThreshold := flag.Int("table-row-threshold", db.DefaultTableRowThreshold, "With --table, results with more rows than this are not drawn by the table renderer (0 = no limit)")
+	headerTypes := flag.Bool("header-types", false, "With --table, show each column's type (e.g. BIGINT) under its name in the header")
+	tableStyle := flag.String("table-style", db.TableStyleMySQL, "With --table, the border style: "+strings.Join(db.TableStyles(), ", "))
+	largeTable := flag.String("table-large", db.LargeTableFallback, "With --table, how to print results over --table-row-threshold: fallback (plain output) or chunk (table with sampled column widths)")
+	tableSampleRows := flag.Int("table-sample-rows", db.DefaultTableSampleRows, "With --table-large=chunk, rows used to size columns and rendered per chunk")
+	align := flag.Bool("align", false, "Line up the columns of the default output by padding values to the width of their column")
+	verticalHeaders := flag.Bool("vertical-headers", false, "In vertical (\\G) output, print the masked instance above each result's row markers and a blank line after each instance")
+	verticalSeparator := flag.Int("vertical-separator", db.DefaultVerticalSeparator, "Number of * on each side of the \"N. row\" markers of vertical (\\G) output")
+	alignSample := flag.Int("align-sample", db.DefaultAlignSampleRows, "With --align, rows used to size columns; later wider values are printed out of line and reported")
+	output := flag.String("output", outputText, "Output mode: text or sql (INSERT statements)")
+	outputSQLTable := flag.String("output-sql-table", "", "Target table (table or db.table) for --output sql")
+	format := flag.String("format", formatText, "Result format: text, json for one JSON object per result (instance, statement, columns, rows, row_count, duration_ms, error), ndjson for one JSON object per row, streamed as it is read, csv for a header and rows per result, markdown for a GitHub-flavored table per result, or xml as by mysql --xml, with an <instance> element per instance; all but text print progress messages on stderr")
+	jsonPretty := flag.Bool("json-pretty", false, "Indent the JSON of --format json and --show-columns-types for reading; compact lines are the default for piping")
+	csvInstanceColumn := flag.Bool("csv-instance-column", false, "With --format csv, add a leading instance column holding the masked DSN instead of a \"# instance:\" comment line before each block, so the instances' rows share one header")
+	csvShorthand := flag.Bool("csv", false, "Shorthand for --format csv --csv-instance-column")
+	csvNull := flag.String("csv-null", "", "With --format csv, write NULL as this token, e.g. \\N, instead of an empty field; with --import-csv, insert fields holding it as NULL (empty fields are empty strings)")
+	markdownItalicNull := flag.Bool("markdown-italic-null", false, "With --format markdown, write NULL as an italic *NULL*, telling it apart from the string 'NULL'")
+	valuesPerInsert := flag.Int("values-per-insert", db.DefaultValuesPerInsert, "Rows per INSERT statement for --output sql")
+	maxTotalRows := flag.Int64("max-total-rows", 0, "Abort the run once this many rows have been received across all instances (0 = unlimited)")
+	showQueryID := flag.Bool("show-query-id", false, "Prefix each executed statement with a unique /* csql:<id> */ comment, echoed in the output, to find it in the server's slow or general log")
+	terminator := flag.String("terminator", db.TerminatorSemicolon, "How statements are separated: semicolon (also \\g and \\G), line:<token> for a line holding only the token (e.g. line:GO), or null for 0x00 bytes")
+	stripComments := flag.Bool("strip-comments", false, "Remove comments from executed SQL, keeping optimizer hints (/*+ ... */)")
+	prettySQL := flag.Bool("pretty-sql", false, "Show statements with their original line breaks and syntax highlighting (implied by -v)")
+	colorMode := flag.String("color", colorAuto, "When to use colors: always, auto (only when stdout is a terminal) or never")
+	lang := flag.String("lang", "", "Language for result messages such as \"Empty set.\": "+strings.Join(db.Languages(), ", ")+" (default from LC_ALL, LC_MESSAGES or LANG, else en)")
+	noColor := flag.Bool("no-color", false, "Disable colored output (same as --color=never)")
+	preConnect := flag.Bool("pre-connect", false, "Connect to all instances concurrently before executing, then run statements over the warm connections")
+	maxParallel := flag.Int("max-parallel", runtime.NumCPU()*defaultParallelPerCPU, "Maximum number of instances to run on, or connect to during --pre-connect, at once; results still print in instance order. Defaults to 4 per CPU (0 = unlimited)")
+	requireAll := flag.Bool("require-all", false, "With --pre-connect, abort without executing anything if any instance cannot be reached")
+	exitCodeMapFlag := flag.String("exit-code-map", "", "Remap exit codes per category, e.g. \"query-error=0,partial=0\" (categories: query-error, connection-error, timeout, expectation-failed, interrupted, cancelled, partial)")
+	ignoreErrors := flag.Bool("ignore-errors", false, "Exit 0 even when statements fail or instances cannot be reached (fire and forget); failed result checks and interrupted runs still exit non-zero")
+	outputDir := flag.String("output-dir", "", "Write each instance's results to its own file (host_port_schema.out) in this directory instead of stdout")
+	parquetFile := flag.String("parquet", "", "Write the rows of all instances' results, which must share their columns, to this Parquet file with an instance column and types inferred from the column types, instead of printing them; needs a build with -tags parquet")
+	tee := flag.String("tee", "", "Also write all results to this file")
+	benchmark := flag.Bool("benchmark", false, "Run the statements repeatedly on each instance and report min/max/mean/stddev latency per instance and across all instances instead of rows")
+	iterations := flag.Int("iterations", 0, fmt.Sprintf("With --benchmark, how often to run the statements on each instance (default %d)", defaultBenchmarkIterations))
+	barrierIgnoreErrors := flag.Bool("barrier-ignore-errors", false, "Continue past \"-- csql: barrier\" directives even if statements before them failed")
+	stragglerAfter := flag.Duration("straggler-after", 0, "In a concurrent run, once other instances finished, list the ones still running after this long; on a terminal, press s to cancel them")
+	stragglerTimeout := flag.Duration("straggler-timeout", 0, "In a concurrent run, once other instances finished, cancel the ones still running after this long (KILL QUERY) and finish without them")
+	report := flag.String("report", "", "Write a JSON run report (per-instance status, failures, duration) to this file")
+	failover := flag.Bool("failover", false, "Treat --json servers sharing a \"group\" as alternatives: if one cannot be reached, try the next")
+	explainDSN := flag.Bool("explain-dsn", false, "Only print each instance's user, password (masked), host, port and database, and whether each was given, filled from ~/.my.cnf, defaulted or typed at --password-prompt; nothing is connected to or run")
+	checkAuth := flag.Bool("check-auth", false, "Only check that each instance accepts the credentials and database (connect, ping, SELECT 1); no statements are run")
+	killMatch := flag.String("match", "", "With kill, regular expression selecting the threads to kill by their running statement")
+	killUser := flag.String("user", "", "With kill, only kill threads of this user")
+	killMinTime := flag.Int("min-time", 0, "With kill, only kill threads that have been running for at least this many seconds")
+	killLog := flag.String("kill-log", "", "With kill, append a line per KILL sent (time, instance, thread, statement, outcome) to this file instead of ~/.csql/kill.log")
+	yes := flag.Bool("yes", false, "With kill, kill the matching threads without asking for confirmation")
+	lint := flag.Bool("lint", false, "Check statements for syntax errors before connecting; aborts the run on errors")
+	target := flag.String("target", targetAll, "Run against servers tagged primary or replica in the --json file, or all")
+	failoverAware := flag.Bool("failover-aware", false, "On read-only (1290/1836) or connection-lost errors, re-resolve the host, reconnect and retry the statement once")
+	maxResultBytes := flag.Int64("max-result-bytes", 0, "Abort any statement whose result grows past this many bytes, instead of holding it all in memory (0 = unlimited)")
+	explainOnSlow := flag.Duration("explain-on-slow", 0, "EXPLAIN statements that take longer than this on an instance, on the same connection, and print the plan under the result (0 = never)")
+	explainOnError := flag.Bool("explain-on-error", false, "EXPLAIN statements that fail with an execution error, such as a lock wait timeout or max_execution_time, and print the plan under the error")
+	explainWarnings := flag.Bool("explain-warnings", false, "After each EXPLAIN, in the statements or captured by --explain-on-slow or --explain-on-error, run SHOW WARNINGS on the same connection and print them under the plan, e.g. the query as the optimizer rewrote it")
+	errorsFirst := flag.Bool("errors-first", false, "Print the output of instances with failed statements before that of the others, each group in instance order; results are held until every instance finished")
+	rowCountHistogram := flag.Bool("rowcount-histogram", false, "After the run, for statements returning a single number per instance (e.g. SELECT COUNT(*)), print a histogram of the values across instances in power-of-two buckets")
+	safeUpdates := flag.Bool("safe-updates", false, "Refuse to run if an UPDATE or DELETE has no WHERE or LIMIT clause, like mysql --safe-updates; nothing is executed")
+	allowDropDatabase := flag.Bool("allow-drop-database", false, "Run DROP DATABASE and DROP SCHEMA statements; without it, a run containing one is refused before connecting")
+	showColumnsTypes := flag.Bool("show-columns-types", false, "Instead of rows, print a JSON line per statement and instance describing the result's columns (name, type, nullable, length); rows are not read")
+	statusLineFlag := flag.Bool("status-line", false, "After each instance's output, print a line such as \"STATUS instance=host:3306 rows=5 err= dur=12ms\" for log scraping")
+	verifyCharset := flag.Bool("verify-charset", false, "Before running statements, compare character_set_client/connection/results and collation_connection across instances and warn about those that differ")
+	expectCharset := flag.String("expect-charset", "", "Warn about instances whose session character set is not this one, e.g. utf8mb4 (implies --verify-charset)")
+	strictCharset := flag.Bool("strict-charset", false, "Abort the run before any statement executes if an instance's character set does not match (implies --verify-charset)")
+	diff := flag.Bool("diff", false, "Compare each statement's result across instances and print which instances agree and which differ, grouped by a digest of the columns and rows, instead of the results; differences exit as expectation-failed")
+	diffOrdered := flag.Bool("diff-ordered", false, "With --diff, compare rows in the order they came in; by default rows are sorted before hashing, as their order without ORDER BY is not defined")
+	diffIgnoreColumns := flag.String("diff-ignore-columns", "", "With --diff, comma-separated columns left out of the comparison (case-insensitive), e.g. updated_at,last_seen")
+	verifyOrder := flag.Bool("verify-order", false, "With --diff, also report instances that returned the same rows in a different order, e.g. because of another collation")
+	coerceColumns := flag.Bool("coerce-columns", false, "When a statement returns different columns on different instances, e.g. SHOW REPLICA STATUS across versions, give every instance's result the union of the columns, NULL where an instance lacks one, so --diff, --parquet and a shared CSV header can compare or merge them; results print once all instances finished")
+	canonicalColumns := flag.Bool("canonical-columns", false, "Reorder the columns of each statement's result to the order of the first instance that returned it, matched by name, before printing or --diff; a result lacking one of those columns fails")
+	sortRows := flag.Bool("sort", false, "Sort each result's rows on the client before printing, by the first column, then the second and so on; NULL first and numeric columns by value, text byte by byte unless --sort-locale is set")
+	sortLocale := flag.String("sort-locale", "", "With --sort, compare text with the collation of this locale (e.g. en, de, sv), so accented and capitalized words sort as a reader expects")
+	nullOutput := flag.Bool("null-output", false, "Run statements with Exec, discarding any result sets unread, and print only an OK or ERROR line per instance; for fire-and-forget DDL across a fleet")
+	firstRowOnly := flag.Bool("first-row-only", false, "Print only the first row of each result, with a note counting the rows left out; later rows are not scanned")
+	queryTimeout := flag.Duration("query-timeout", 0, "Interrupt any single statement that runs longer than this, e.g. 30s, failing it with \"query timed out\" and going on to the instance's next statement (0 = no limit)")
+	timeout := flag.Duration("timeout", 0, "Cancel the statements of an instance once they have run this long in total, e.g. 10m; a --json server's \"timeout\" overrides it (0 = no limit)")
+	maxErrors := flag.Int("max-errors", 0, "Stop the run once this many instances had a statement fail or could not be reached; statements not yet run are skipped (0 = unlimited)")
+	failFast := flag.Bool("fail-fast", false, "Stop the run at the first instance whose statement fails or that cannot be reached: in-flight instances are cancelled and the rest are not attempted and listed, so a rerun can resume there (like --max-errors 1)")
+	maxErrorsPerInstance := flag.Int("max-errors-per-instance", 0, "Skip the remaining statements on an instance once this many of its statements failed; other instances carry on (0 = unlimited)")
+	stopOnError := flag.Bool("stop-on-error", false, "Skip the remaining statements on an instance once one of its statements failed, for scripts whose steps depend on each other; other instances carry on (shorthand for --max-errors-per-instance 1)")
+	maxTotalBytes := flag.Int64("max-total-bytes", 0, "Abort the run once this many bytes have been received across all instances (0 = unlimited)")
 
 	// Parse flags
 	flag.Parse()
 
 	// Populate config
 	c.Instances = *instances
+	c.InstancesEnv = *instancesEnv
+	c.PasswordPrompt = *passwordPrompt
+	c.DefaultsFile = *defaultsFile
+	c.SSH = *ssh
+	c.SSHKey = *sshKey
+	c.SSHKnownHosts = *sshKnownHosts
+	c.Proxy = *proxyURL
+	c.TLSMode = *tlsMode
+	c.TLSCA = *tlsCA
+	c.TLSCert = *tlsCert
+	c.TLSKey = *tlsKey
+	c.ConnectRetries = *connectRetries
+	c.ConnectTimeout = *connectTimeout
+	c.ReadTimeout = *readTimeout
+	c.WriteTimeout = *writeTimeout
 	c.Statements = *statements
 	c.File = *file
 	c.JSONFile = *jsonFile
+	c.StrictConfig = *strictConfig
+	c.ConfigSchema = *configSchema
 	c.SQLFile = *sqlFile
 	c.Stdin = *stdin
+	c.Runbook = *runbook
 	c.Concurrent = *concurrent
+	c.InputFormat = *inputFormat
+	c.ForEachFile = *forEachFile
+	c.ImportCSV = *importCSV
+	c.ImportTable = *importTable
+	c.BatchSize = *batchSize
+	c.IncludeSessionSetup = *includeSessionSetup
+	c.BinlogDatabase = *binlogDatabase
+	c.BinlogServerID = *binlogServerID
 	c.TableFormat = *tableFormat
+	c.ReplayTiming = *replayTiming
+	c.ReplaySpeed = *replaySpeed
+	c.TableRowThreshold = *tableRowThreshold
+	c.LargeTable = *largeTable
+	c.TableStyle = *tableStyle
+	c.HeaderTypes = *headerTypes
+	c.TableSampleRows = *tableSampleRows
+	c.Align = *align
+	c.AlignSample = *alignSample
+	c.VerticalSeparator = *verticalSeparator
+	c.VerticalHeaders = *verticalHeaders
+	c.Output = *output
+	c.OutputSQLTable = *outputSQLTable
+	c.ValuesPerInsert = *valuesPerInsert
+	c.Format = *format
+	c.JSONPretty = *jsonPretty
+	c.CSVInstanceColumn = *csvInstanceColumn
+	c.CSVNull = *csvNull
+	c.MarkdownItalicNull = *markdownItalicNull
+	if *csvShorthand {
+		if c.Format != formatText && c.Format != formatCSV {
+			return fmt.Errorf("--csv conflicts with --format %s", c.Format)
+		}
+		c.Format, c.CSVInstanceColumn = formatCSV, true
+	}
+	c.MaxTotalRows = *maxTotalRows
+	c.MaxTotalBytes = *maxTotalBytes
+	c.MaxResultBytes = *maxResultBytes
+	c.MaxErrorsPerInstance = *maxErrorsPerInstance
+	if *stopOnError {
+		if c.MaxErrorsPerInstance > 1 {
+			return fmt.Errorf("--stop-on-error conflicts with --max-errors-per-instance %d", c.MaxErrorsPerInstance)
+		}
+		c.MaxErrorsPerInstance = 1
+	}
+	c.MaxErrors = *maxErrors
+	c.FailFast = *failFast
+	c.Timeout = *timeout
+	c.QueryTimeout = *queryTimeout
+	c.FirstRowOnly = *firstRowOnly
+	c.StatusLine = *statusLineFlag
+	c.RowCountHistogram = *rowCountHistogram
+	c.ErrorsFirst = *errorsFirst
+	c.ShowColumnsTypes = *showColumnsTypes
+	c.NullOutput = *nullOutput
+	c.Diff = *diff
+	c.DiffOrdered = *diffOrdered
+	c.DiffIgnoreColumns = *diffIgnoreColumns
+	c.VerifyOrder = *verifyOrder
+	c.CanonicalColumns = *canonicalColumns
+	c.CoerceColumns = *coerceColumns
+	c.Sort = *sortRows
+	c.SortLocale = *sortLocale
+	c.AllowDropDatabase = *allowDropDatabase
+	c.SafeUpdates = *safeUpdates
+	c.ExplainOnSlow = *explainOnSlow
+	c.ExplainOnError = *explainOnError
+	c.ExplainWarnings = *explainWarnings
+	c.VerifyCharset = *verifyCharset
+	c.ExpectCharset = *expectCharset
+	c.StrictCharset = *strictCharset
+	c.StripComments = *stripComments
+	c.FailoverAware = *failoverAware
+	c.ShowQueryID = *showQueryID
+	c.Terminator = *terminator
+	c.PrettySQL = *prettySQL
+	c.Color = *colorMode
+	if *noColor {
+		c.Color = colorNever
+	}
+	c.Lang = *lang
+	c.Target = *target
+	c.Lint = *lint
+	c.CheckAuth = *checkAuth
+	c.ExplainDSN = *explainDSN
+	c.KillMatch = *killMatch
+	c.KillUser = *killUser
+	c.KillMinTime = *killMinTime
+	c.KillLog = *killLog
+	c.Yes = *yes
+	c.Failover = *failover
+	c.Report = *report
+	c.Benchmark = *benchmark
+	c.Iterations = *iterations
+	c.BarrierIgnoreErrors = *barrierIgnoreErrors
+	c.StragglerAfter = *stragglerAfter
+	c.StragglerTimeout = *stragglerTimeout
+	c.OutputDir = *outputDir
+	c.Parquet = *parquetFile
+	c.Tee = *tee
+	c.ExitCodeMap = *exitCodeMapFlag
+	c.IgnoreErrors = *ignoreErrors
+	c.PreConnect = *preConnect
+	c.MaxParallel = *maxParallel
+	c.RequireAll = *requireAll
+
+	if c.ValidateConfig {
+		// The arguments are the servers files to check, not SQL
+		switch args := flag.Args(); {
+		case len(args) > 0 && c.JSONFile != "":
+			return fmt.Errorf("validate-config got both --json and %s", strings.Join(args, " "))
+		case len(args) > 0:
+			c.JSONFile = strings.Join(args, ",")
+		}
+		return nil
+	}
+	if err := c.applyRunbook(); err != nil {
+		return err
+	}
+	return c.applyPositionalArgs(flag.Args())
+}
 
+// applyPositionalArgs uses the arguments left after the flags as the statements, as
+// in `go-csql --instances=... "SELECT 1"`. Unquoted words are joined with spaces.
+func (c *Config) applyPositionalArgs(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	for _, arg := range args {
+		if len(arg) > 1 && strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("flag %s follows the SQL argument; flags must come before it", arg)
+		}
+	}
+	if c.Stdin || c.SQLFile != "" || c.File != "" || c.Statements != "" {
+		return fmt.Errorf("SQL given as an argument (%q) cannot be combined with --stdin, --sqlfile, --file or --statements",
+			strings.Join(args, " "))
+	}
+	c.Statements = strings.Join(args, " ")
 	return nil
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.Instances == "" && c.JSONFile == "" {
-		return fmt.Errorf("--instances or --json is required")
+	if c.ValidateConfig {
+		switch {
+		case c.JSONFile == "" && !c.ConfigSchema:
+			return fmt.Errorf("validate-config requires a servers file, e.g. validate-config servers.json")
+		case c.Instances != "" || c.InstancesEnv != "" || c.Runbook != "" || c.Stdin || c.SQLFile != "" || c.File != "" || c.Statements != "":
+			return fmt.Errorf("validate-config only checks a servers file; it cannot be combined with --instances, --runbook or SQL")
+		}
+		return nil
+	}
+	if c.ConfigSchema {
+		return fmt.Errorf("--schema requires the validate-config command")
+	}
+
+	if env := c.instancesEnv(); env != "" && strings.Trim(os.Getenv(env), ", \t\r\n") == "" {
+		return fmt.Errorf("--instances-env: environment variable %s is not set or holds no DSNs", env)
+	}
+	if c.Instances == "" && c.JSONFile == "" && len(c.runbookInstances) == 0 && c.instancesEnv() == "" {
+		return fmt.Errorf("--instances, --instances-env, --json or --runbook is required, or set %s", defaultInstancesEnv)
 	}
 
 	sqlSourceCount := 0
@@ -178,14 +759,356 @@ func (c *Config) Validate() error {
 	if c.Statements != "" {
 		sqlSourceCount++
 	}
+	if err := c.validateImportCSV(sqlSourceCount); err != nil {
+		return err
+	}
+	if c.ImportCSV != "" {
+		sqlSourceCount++
+	}
+
+	if c.Kill {
+		if err := c.validateKill(sqlSourceCount); err != nil {
+			return err
+		}
+	} else if c.KillMatch != "" || c.KillUser != "" || c.KillMinTime != 0 || c.KillLog != "" || c.Yes {
+		return fmt.Errorf("--match, --user, --min-time, --kill-log and --yes require the kill command")
+	}
+
+	if c.ExplainDSN {
+		if sqlSourceCount > 0 || c.CheckAuth || c.Kill {
+			return fmt.Errorf("--explain-dsn connects to nothing; it cannot be combined with --check-auth, kill, --stdin, --sqlfile, --file, --statements or SQL arguments")
+		}
+	} else if c.CheckAuth {
+		if sqlSourceCount > 0 {
+			return fmt.Errorf("--check-auth runs no statements; it cannot be combined with --stdin, --sqlfile, --file, --statements or SQL arguments")
+		}
+	} else if sqlSourceCount == 0 && !c.Kill {
+		return fmt.Errorf("must provide --stdin, --sqlfile, --file, --statements, --import-csv or the SQL as an argument")
+	}
+
+	switch c.InputFormat {
+	case "", inputSQL, inputSlowLog:
+		if c.IncludeSessionSetup || c.BinlogDatabase != "" || c.BinlogServerID != 0 {
+			return fmt.Errorf("--include-session-setup, --binlog-database and --binlog-server-id require --input-format binlog-text")
+		}
+	case inputBinlogText:
+		if c.BinlogServerID > math.MaxUint32 {
+			return fmt.Errorf("--binlog-server-id %d is out of range", c.BinlogServerID)
+		}
+	default:
+		return fmt.Errorf("invalid --input-format %q: must be sql, binlog-text or slow-log", c.InputFormat)
+	}
+	terminator, err := db.ParseTerminator(c.Terminator)
+	if err != nil {
+		return fmt.Errorf("--terminator: %w", err)
+	}
+	if !terminator.Semicolon() && c.InputFormat != "" && c.InputFormat != inputSQL {
+		return fmt.Errorf("--terminator %s requires --input-format sql", c.Terminator)
+	}
+	c.terminator = terminator
+
+	if c.ForEachFile != "" && c.InputFormat != "" && c.InputFormat != inputSQL {
+		return fmt.Errorf("--for-each-file requires --input-format sql")
+	}
+
+	if c.ReplayTiming && c.InputFormat != inputSlowLog {
+		return fmt.Errorf("--replay-timing requires --input-format slow-log")
+	}
+	if c.ReplayTiming && c.Benchmark {
+		return fmt.Errorf("--replay-timing cannot be combined with --benchmark")
+	}
+	if c.ReplaySpeed < 0 {
+		return fmt.Errorf("--replay-speed must be greater than 0")
+	}
+
+	switch c.Output {
+	case "", outputText:
+	case outputSQL:
+		if c.OutputSQLTable == "" {
+			return fmt.Errorf("--output sql requires --output-sql-table")
+		}
+		if c.ValuesPerInsert <= 0 {
+			return fmt.Errorf("--values-per-insert must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("invalid --output %q: must be text or sql", c.Output)
+	}
+	switch c.Format {
+	case "", formatText:
+	case formatJSON, formatNDJSON, formatCSV, formatMarkdown, formatXML:
+		if c.Output == outputSQL || c.ShowColumnsTypes {
+			return fmt.Errorf("--format %s cannot be combined with --output sql or --show-columns-types, which print results their own way", c.Format)
+		}
+		if c.RowCountHistogram || c.StatusLine || c.Benchmark || c.ReplayTiming {
+			return fmt.Errorf("--format %s cannot be combined with --rowcount-histogram, --status-line, --benchmark or --replay-timing, whose reports are text", c.Format)
+		}
+	default:
+		return fmt.Errorf("invalid --format %q: must be text, json, ndjson, csv, markdown or xml", c.Format)
+	}
+	if c.Format == formatXML && c.OutputDir != "" {
+		return fmt.Errorf("--format xml writes a single document; it cannot be combined with --output-dir")
+	}
+	if c.JSONPretty && c.Format != formatJSON && !c.ShowColumnsTypes {
+		return fmt.Errorf("--json-pretty requires --format json or --show-columns-types")
+	}
+	if c.CSVInstanceColumn && c.Format != formatCSV {
+		return fmt.Errorf("--csv-instance-column requires --format csv")
+	}
+	if c.CSVNull != "" && c.Format != formatCSV && c.ImportCSV == "" {
+		return fmt.Errorf("--csv-null requires --format csv or --import-csv")
+	}
+	if c.MarkdownItalicNull && c.Format != formatMarkdown {
+		return fmt.Errorf("--markdown-italic-null requires --format markdown")
+	}
+
+	switch c.LargeTable {
+	case "", db.LargeTableFallback, db.LargeTableChunk:
+	default:
+		return fmt.Errorf("invalid --table-large %q: must be fallback or chunk", c.LargeTable)
+	}
+	if !db.ValidTableStyle(c.TableStyle) {
+		return fmt.Errorf("invalid --table-style %q: must be one of %s", c.TableStyle, strings.Join(db.TableStyles(), ", "))
+	}
+	if c.TableStyle != "" && c.TableStyle != db.TableStyleMySQL && !c.TableFormat {
+		return fmt.Errorf("--table-style requires --table")
+	}
+	if c.HeaderTypes && !c.TableFormat {
+		return fmt.Errorf("--header-types requires --table")
+	}
+	if c.TableRowThreshold < 0 || c.TableSampleRows < 0 {
+		return fmt.Errorf("--table-row-threshold and --table-sample-rows cannot be negative")
+	}
+	if c.Align && c.TableFormat {
+		return fmt.Errorf("--align cannot be combined with --table, which lines up columns itself")
+	}
+	if c.AlignSample < 0 {
+		return fmt.Errorf("--align-sample cannot be negative")
+	}
+	if c.VerticalSeparator < 0 {
+		return fmt.Errorf("--vertical-separator cannot be negative")
+	}
+
+	switch c.Color {
+	case "", colorAuto, colorAlways, colorNever:
+	default:
+		return fmt.Errorf("invalid --color %q: must be always, auto or never", c.Color)
+	}
+
+	lang := c.Lang
+	if lang == "" {
+		lang = db.LanguageFromEnv(os.Getenv)
+	}
+	messages, ok := db.MessagesFor(lang)
+	if !ok && c.Lang != "" {
+		return fmt.Errorf("invalid --lang %q: must be one of %s", c.Lang, strings.Join(db.Languages(), ", "))
+	}
+	c.messages = messages // An unknown locale from the environment falls back to English
+
+	switch c.Target {
+	case "", targetAll:
+	case targetPrimary, targetReplica:
+		if c.JSONFile == "" {
+			return fmt.Errorf("--target %s requires --json with tagged servers", c.Target)
+		}
+	default:
+		return fmt.Errorf("invalid --target %q: must be primary, replica or all", c.Target)
+	}
+
+	if c.Failover && c.JSONFile == "" {
+		return fmt.Errorf("--failover requires --json with grouped servers")
+	}
+
+	if c.SSH != "" {
+		if _, _, err := db.ParseBastion(c.SSH); err != nil {
+			return fmt.Errorf("--ssh: %w", err)
+		}
+	}
+	if c.SSHKey != "" && c.SSH == "" && c.JSONFile == "" {
+		return fmt.Errorf("--ssh-key requires --ssh or --json servers with an \"ssh\" bastion")
+	}
+	if c.Proxy != "" {
+		if _, err := db.ParseProxy(c.Proxy); err != nil {
+			return fmt.Errorf("--proxy: %w", err)
+		}
+		if c.SSH != "" {
+			return fmt.Errorf("--proxy cannot be combined with --ssh")
+		}
+	}
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+
+	exitCodes, err := parseExitCodeMap(c.ExitCodeMap)
+	if err != nil {
+		return err
+	}
+	if c.IgnoreErrors {
+		if c.ExitCodeMap != "" {
+			return fmt.Errorf("--ignore-errors cannot be combined with --exit-code-map, which sets the exit codes itself")
+		}
+		for _, category := range ignoredErrorCategories {
+			exitCodes[category] = 0
+		}
+	}
+	c.exitCodes = exitCodes
 
-	if sqlSourceCount == 0 {
-		return fmt.Errorf("must provide --stdin, --sqlfile, --file, or --statements")
+	if c.MaxParallel < 0 {
+		return fmt.Errorf("--max-parallel cannot be negative")
+	}
+	if c.RequireAll && !c.PreConnect {
+		return fmt.Errorf("--require-all requires --pre-connect")
 	}
 
+	if c.Iterations < 0 {
+		return fmt.Errorf("--iterations cannot be negative")
+	}
+	if c.Iterations > 0 && !c.Benchmark {
+		return fmt.Errorf("--iterations requires --benchmark")
+	}
+
+	if c.StragglerAfter < 0 || c.StragglerTimeout < 0 {
+		return fmt.Errorf("--straggler-after and --straggler-timeout cannot be negative")
+	}
+	if (c.StragglerAfter > 0 || c.StragglerTimeout > 0) && !c.Concurrent {
+		return fmt.Errorf("--straggler-after and --straggler-timeout require --concurrent")
+	}
+
+	if c.MaxTotalRows < 0 || c.MaxTotalBytes < 0 {
+		return fmt.Errorf("--max-total-rows and --max-total-bytes cannot be negative")
+	}
+	if c.MaxResultBytes < 0 {
+		return fmt.Errorf("--max-result-bytes cannot be negative")
+	}
+	if c.MaxErrorsPerInstance < 0 {
+		return fmt.Errorf("--max-errors-per-instance cannot be negative")
+	}
+	if c.MaxErrors < 0 {
+		return fmt.Errorf("--max-errors cannot be negative")
+	}
+	if c.FailFast && c.MaxErrors > 0 {
+		return fmt.Errorf("--fail-fast cannot be combined with --max-errors; it stops at the first failed instance")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("--timeout cannot be negative")
+	}
+	if c.QueryTimeout < 0 {
+		return fmt.Errorf("--query-timeout cannot be negative")
+	}
+	if c.ConnectRetries < 0 {
+		return fmt.Errorf("--connect-retry-on-too-many-connections cannot be negative")
+	}
+	if c.ConnectTimeout < 0 {
+		return fmt.Errorf("--connect-timeout cannot be negative")
+	}
+	if c.ReadTimeout < 0 || c.WriteTimeout < 0 {
+		return fmt.Errorf("--read-timeout and --write-timeout cannot be negative")
+	}
+	if c.ExplainOnSlow < 0 {
+		return fmt.Errorf("--explain-on-slow cannot be negative")
+	}
+	if c.StatusLine && c.Output == outputSQL {
+		return fmt.Errorf("--status-line cannot be combined with --output sql, whose output must stay valid SQL")
+	}
+	if c.RowCountHistogram && c.Output == outputSQL {
+		return fmt.Errorf("--rowcount-histogram cannot be combined with --output sql, whose output must stay valid SQL")
+	}
+	if c.RowCountHistogram && c.Benchmark {
+		return fmt.Errorf("--rowcount-histogram cannot be combined with --benchmark, which reports latencies instead of rows")
+	}
+	if c.ShowColumnsTypes && (c.Output == outputSQL || c.Benchmark || c.RowCountHistogram || c.StatusLine) {
+		return fmt.Errorf("--show-columns-types cannot be combined with --output sql, --benchmark, --rowcount-histogram or --status-line, which need the rows")
+	}
+	if (c.DiffOrdered || c.DiffIgnoreColumns != "" || c.VerifyOrder) && !c.Diff {
+		return fmt.Errorf("--diff-ordered, --diff-ignore-columns and --verify-order require --diff")
+	}
+	if c.VerifyOrder && c.DiffOrdered {
+		return fmt.Errorf("--verify-order cannot be combined with --diff-ordered, which already counts another order as a difference")
+	}
+	if c.Diff && (c.NullOutput || c.Output == outputSQL || (c.Format != "" && c.Format != formatText) || c.ShowColumnsTypes ||
+		c.Benchmark || c.ReplayTiming) {
+		return fmt.Errorf("--diff cannot be combined with --null-output, --output sql, --format other than text, --show-columns-types, --benchmark or --replay-timing")
+	}
+	if c.NullOutput && (c.Output == outputSQL || (c.Format != "" && c.Format != formatText) || c.ShowColumnsTypes ||
+		c.FirstRowOnly || c.RowCountHistogram || c.StatusLine || c.Benchmark) {
+		return fmt.Errorf("--null-output cannot be combined with --output sql, --format json, ndjson or csv, --show-columns-types, --first-row-only, --rowcount-histogram, --status-line or --benchmark, which need the results")
+	}
+	if c.CanonicalColumns && (c.Format == formatNDJSON || c.Benchmark || c.ReplayTiming) {
+		return fmt.Errorf("--canonical-columns cannot be combined with --format ndjson, which streams rows as they are read, --benchmark or --replay-timing")
+	}
+	if c.CoerceColumns && (c.CanonicalColumns || c.Format == formatNDJSON || c.Benchmark || c.ReplayTiming) {
+		return fmt.Errorf("--coerce-columns cannot be combined with --canonical-columns, --format ndjson, which streams rows as they are read, --benchmark or --replay-timing")
+	}
+	if err := c.validateParquet(); err != nil {
+		return err
+	}
+	if err := c.validateSort(); err != nil {
+		return err
+	}
+	if c.verifiesCharset() && c.ReplayTiming {
+		return fmt.Errorf("--verify-charset cannot be combined with --replay-timing, which opens its own sessions")
+	}
+
+	return nil
+}
+
+// validateKill checks the options of the kill command and compiles --match
+func (c *Config) validateKill(sqlSourceCount int) error {
+	if sqlSourceCount > 0 || c.CheckAuth {
+		return fmt.Errorf("kill runs no statements; it cannot be combined with --check-auth, --stdin, --sqlfile, --file, --statements or SQL arguments")
+	}
+	if c.KillMatch == "" {
+		return fmt.Errorf("kill requires --match")
+	}
+	re, err := regexp.Compile("(?s)" + c.KillMatch) // . also matches the line breaks of a statement
+	if err != nil {
+		return fmt.Errorf("invalid --match %q: %w", c.KillMatch, err)
+	}
+	c.killMatch = re
+	if c.KillMinTime < 0 {
+		return fmt.Errorf("--min-time cannot be negative")
+	}
 	return nil
 }
 
+// sink returns the output sink all results and diagnostics are written through
+func (c *Config) sink() *db.OutputSink {
+	if c.output != nil {
+		return c.output
+	}
+	return db.DefaultOutput
+}
+
+// connectOptions returns the options for commands that only connect and inspect,
+// such as --check-auth and kill: how instances are reached and where diagnostics go
+func (c *Config) connectOptions() db.ExecOptions {
+	return db.ExecOptions{
+		Verbose:        c.Verbose,
+		Output:         c.sink(),
+		Tunnels:        c.tunnels,
+		Proxy:          c.Proxy != "",
+		TLS:            c.tlsMode(),
+		ConnectRetries: c.ConnectRetries,
+		ConnectTimeout: c.ConnectTimeout,
+		ReadTimeout:    c.ReadTimeout,
+		WriteTimeout:   c.WriteTimeout,
+	}
+}
+
+// machineReadable reports whether results are printed for other programs to parse,
+// so stdout must carry nothing else
+func (c *Config) machineReadable() bool {
+	return c.Output == outputSQL || c.ShowColumnsTypes || (c.Format != "" && c.Format != formatText)
+}
+
+// infof writes a progress banner; machine-readable output modes keep stdout clean
+func (c *Config) infof(format string, args ...interface{}) {
+	stream := db.StreamResults
+	if c.machineReadable() {
+		stream = db.StreamDiagnostics
+	}
+	c.sink().Printf(stream, format, args...)
+}
+
 // validateDSN validates a MySQL DSN format
 func validateDSN(dsn string) error {
 	if dsn == "" {
@@ -243,24 +1166,19 @@ func expandPath(path string) (string, error) {
 	return path, nil
 }
 
-// stripJSONComments removes comment lines from JSON content while preserving strings that may contain #
+// stripJSONComments blanks out comment lines in JSON content while preserving strings
+// that may contain #. Lines are kept, so parse errors point at the right line.
 func stripJSONComments(content []byte) []byte {
 	lines := strings.Split(string(content), "\n")
-	var cleanLines []string
-
-	for _, line := range lines {
+	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
-		// Only skip lines that are pure comments (start with # and are not inside JSON strings)
+		// Only blank lines that are pure comments (start with # and are not inside JSON strings)
 		// A line is a comment if it starts with # and doesn't contain JSON syntax like quotes, braces, etc.
 		if strings.HasPrefix(trimmed, "#") && !containsJSONSyntax(trimmed) {
-			continue // Skip comment lines
-		}
-		if trimmed != "" || len(cleanLines) > 0 { // Keep non-empty lines or preserve structure
-			cleanLines = append(cleanLines, line)
+			lines[i] = ""
 		}
 	}
-
-	return []byte(strings.Join(cleanLines, "\n"))
+	return []byte(strings.Join(lines, "\n"))
 }
 
 // containsJSONSyntax checks if a line contains JSON syntax characters that would indicate it's not a pure comment
@@ -275,18 +1193,53 @@ func containsJSONSyntax(line string) bool {
 	return false
 }
 
+// parseMyCnf parses the --defaults-file option file, or else ~/.my.cnf
+func (c *Config) parseMyCnf() (*db.MyCnf, error) {
+	if c.DefaultsFile == "" {
+		return db.ParseMyCnf()
+	}
+	path, err := expandPath(c.DefaultsFile)
+	if err != nil {
+		return nil, err
+	}
+	return db.ParseMyCnfFrom(path)
+}
+
 // LoadInstances loads and processes database instances from config
 func (c *Config) LoadInstances() ([]string, error) {
-	var myCnf *db.MyCnf
-	myCnf, _ = db.ParseMyCnf() // ignore error if file doesn't exist
+	myCnf, cnfErr := c.parseMyCnf()
+	switch {
+	case errors.Is(cnfErr, db.ErrInsecureMyCnf):
+		return nil, cnfErr
+	case cnfErr != nil && c.DefaultsFile != "":
+		return nil, fmt.Errorf("--defaults-file: %w", cnfErr)
+	case cnfErr != nil:
+		myCnf = nil // No usable ~/.my.cnf, e.g. it doesn't exist
+	case c.Verbose >= 1:
+		for _, warning := range myCnf.Warnings {
+			c.sink().Printf(db.StreamDiagnostics, "Warning: %s: %s\n", myCnf.Path, warning)
+		}
+	}
+
+	if c.PasswordPrompt {
+		password, err := readPassword()
+		if err != nil {
+			return nil, err
+		}
+		c.password = password
+	}
 
 	var instanceList []string
 	var err error
-
-	if c.JSONFile != "" {
-		instanceList, err = c.loadInstancesFromJSON(myCnf)
-	} else {
+	switch {
+	case c.runbookInstances != nil:
+		instanceList = c.fillInstances(c.runbookInstances, myCnf)
+	case c.Instances != "":
 		instanceList, err = c.loadInstancesFromFlag(myCnf)
+	case c.instancesEnv() != "":
+		instanceList = c.fillInstances(strings.Split(os.Getenv(c.instancesEnv()), ","), myCnf)
+	default:
+		instanceList, err = c.loadInstancesFromJSON(myCnf)
 	}
 
 	if err != nil {
@@ -301,14 +1254,24 @@ func (c *Config) LoadInstances() ([]string, error) {
 	return instanceList, nil
 }
 
-// loadInstancesFromJSON loads instances from JSON file
-func (c *Config) loadInstancesFromJSON(myCnf *db.MyCnf) ([]string, error) {
-	var instanceList []string
+// jsonFiles returns the servers files given to --json as a comma-separated list
+func (c *Config) jsonFiles() []string {
+	var files []string
+	for _, path := range strings.Split(c.JSONFile, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
 
+// readServersFile reads the servers of a --json file. Errors name the file, so a
+// problem in one of several files points at it.
+func (c *Config) readServersFile(path string) ([]Server, error) {
 	// Expand ~ to home directory
-	expandedPath, err := expandPath(c.JSONFile)
+	expandedPath, err := expandPath(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to expand JSON file path: %w", err)
+		return nil, fmt.Errorf("%s: failed to expand JSON file path: %w", path, err)
 	}
 
 	// JSON file format supports both DSN strings and individual components
@@ -321,60 +1284,158 @@ func (c *Config) loadInstancesFromJSON(myCnf *db.MyCnf) ([]string, error) {
 	cleanContent := stripJSONComments(content)
 
 	var servers []Server
-	err = json.Unmarshal(cleanContent, &servers)
+	if c.StrictConfig {
+		servers, err = decodeServersStrict(cleanContent)
+	} else {
+		err = json.Unmarshal(cleanContent, &servers)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		return nil, fmt.Errorf("%s: failed to parse JSON: %w", path, err)
 	}
+	return servers, nil
+}
+
+// serverFile is a server read from a --json file, with the file it came from
+type serverFile struct {
+	Server
+	path string
+}
+
+// readServersFiles reads the servers of every --json file, in order. A server
+// whose host:port an earlier file already lists is left out with a note, so
+// inventories split by environment can overlap.
+func (c *Config) readServersFiles() ([]serverFile, error) {
+	var servers []serverFile
+	seenIn := make(map[string]string) // host:port -> file that listed it first
+	for _, path := range c.jsonFiles() {
+		fileServers, err := c.readServersFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range fileServers {
+			addr := s.addr()
+			if first, ok := seenIn[addr]; ok && first != path {
+				c.sink().Printf(db.StreamDiagnostics, "Skipping %s from %s: already listed in %s\n", addr, path, first)
+				continue
+			}
+			seenIn[addr] = path
+			servers = append(servers, serverFile{Server: s, path: path})
+		}
+	}
+	return servers, nil
+}
+
+// loadInstancesFromJSON loads instances from the --json files
+func (c *Config) loadInstancesFromJSON(myCnf *db.MyCnf) ([]string, error) {
+	var instanceList []string
+
+	servers, err := c.readServersFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	c.groups = make(map[string][]string)
+	c.bastions = make(map[string]string)
+	c.timeouts = make(map[string]time.Duration)
+	groupFirst := make(map[string]string) // Group name -> DSN of its first member
 
 	for _, s := range servers {
-		dsnToUse := s.BuildDSN() // Build DSN with proper password encoding
-		if myCnf != nil {
-			// Apply .my.cnf credentials respecting existing host info
-			if !dsnHasHost(dsnToUse) {
-				dsnToUse = db.FillDSN(dsnToUse, myCnf)
-			} else {
-				// Create a temporary cnf without host to fill other details
-				tempCnf := *myCnf
-				tempCnf.Host = "" // Don't override host from .my.cnf
-				dsnToUse = db.FillDSN(dsnToUse, &tempCnf)
+		if !s.matchesTarget(c.Target) {
+			continue
+		}
+		dsnToUse := c.fillDSN(s.BuildDSN(), myCnf) // Build DSN with proper password encoding
+		if s.SSH != "" {
+			c.bastions[dsnToUse] = s.bastion()
+		}
+		if s.Timeout != "" {
+			d, err := parseServerTimeout(s.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("%s: server %s: %w", s.path, db.MaskDSN(dsnToUse), err)
+			}
+			c.timeouts[dsnToUse] = d
+		}
+		if c.Failover && s.Group != "" {
+			if first, ok := groupFirst[s.Group]; ok {
+				// Later members are only used if earlier ones cannot be reached
+				c.groups[first] = append(c.groups[first], dsnToUse)
+				continue
 			}
+			groupFirst[s.Group] = dsnToUse
+			c.groups[dsnToUse] = []string{dsnToUse}
 		}
 		instanceList = append(instanceList, dsnToUse)
 	}
 
+	if len(instanceList) == 0 && c.Target != "" && c.Target != targetAll {
+		return nil, fmt.Errorf("no servers tagged %q in %s", c.Target, c.JSONFile)
+	}
+
 	return instanceList, nil
 }
 
+// defaultInstancesEnv is the environment variable the instances are read from when
+// no other source of instances is given
+const defaultInstancesEnv = "CSQL_INSTANCES"
+
+// instancesEnv returns the environment variable the instances are read from, or ""
+// if they come from elsewhere. --instances overrides --instances-env, which
+// overrides --json; CSQL_INSTANCES is only read when none of them is given.
+func (c *Config) instancesEnv() string {
+	switch {
+	case c.Instances != "" || c.runbookInstances != nil:
+		return ""
+	case c.InstancesEnv != "":
+		return c.InstancesEnv
+	case c.JSONFile == "" && os.Getenv(defaultInstancesEnv) != "":
+		return defaultInstancesEnv
+	}
+	return ""
+}
+
 // loadInstancesFromFlag loads instances from command line flag
 func (c *Config) loadInstancesFromFlag(myCnf *db.MyCnf) ([]string, error) {
-	var instanceList []string
-	rawInstances := strings.Split(c.Instances, ",")
+	return c.fillInstances(strings.Split(c.Instances, ","), myCnf), nil
+}
 
+// fillInstances sanitizes DSNs and fills their missing parts from .my.cnf and
+// --password-prompt, dropping empty entries
+func (c *Config) fillInstances(rawInstances []string, myCnf *db.MyCnf) []string {
+	var instanceList []string
 	for _, dsn := range rawInstances {
 		dsnToUse := strings.TrimSpace(dsn)
 		if dsnToUse == "" {
 			continue
 		}
 		dsnToUse = sanitizeDSN(dsnToUse) // Sanitize complex passwords
-		if myCnf != nil {
-			// Apply .my.cnf credentials respecting existing host info
-			if !dsnHasHost(dsnToUse) {
-				dsnToUse = db.FillDSN(dsnToUse, myCnf)
-			} else {
-				// Create a temporary cnf without host to fill other details
-				tempCnf := *myCnf
-				tempCnf.Host = "" // Don't override host from .my.cnf
-				dsnToUse = db.FillDSN(dsnToUse, &tempCnf)
-			}
-		}
-		instanceList = append(instanceList, dsnToUse)
+		instanceList = append(instanceList, c.fillDSN(dsnToUse, myCnf))
 	}
-
-	return instanceList, nil
+	return instanceList
 }
 
-// LoadStatements loads SQL statements from various sources
+// LoadStatements loads SQL statements from various sources, extracting them from
+// mysqlbinlog output with --input-format binlog-text
 func (c *Config) LoadStatements() (string, error) {
+	sqls, err := c.loadSource()
+	if err != nil {
+		return "", err
+	}
+	switch c.InputFormat {
+	case inputBinlogText:
+		return c.statementsFromBinlog(sqls)
+	case inputSlowLog:
+		return c.statementsFromSlowLog(sqls)
+	}
+	if c.ForEachFile != "" {
+		return c.renderForEach(sqls)
+	}
+	return sqls, nil
+}
+
+// loadSource reads the text of the configured SQL source
+func (c *Config) loadSource() (string, error) {
+	if c.ImportCSV != "" {
+		return c.loadImportCSV()
+	}
 	if c.Stdin {
 		return c.loadStatementsFromStdin()
 	}
@@ -390,6 +1451,36 @@ func (c *Config) LoadStatements() (string, error) {
 	return "", fmt.Errorf("no SQL statements provided")
 }
 
+// statementsFromBinlog extracts the statements to replay from mysqlbinlog output
+func (c *Config) statementsFromBinlog(text string) (string, error) {
+	events, err := sqllog.ParseBinlogText(strings.NewReader(text), sqllog.BinlogOptions{
+		IncludeSessionSetup: c.IncludeSessionSetup,
+		Database:            c.BinlogDatabase,
+		ServerID:            uint32(c.BinlogServerID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse binlog text: %w", err)
+	}
+	if len(events) == 0 {
+		return "", fmt.Errorf("no statements found in the binlog text")
+	}
+	return sqllog.JoinStatements(events), nil
+}
+
+// statementsFromSlowLog extracts the statements to replay from a slow query log,
+// keeping them with their times for --replay-timing
+func (c *Config) statementsFromSlowLog(text string) (string, error) {
+	events, err := sqllog.ParseSlowLog(strings.NewReader(text))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse slow log: %w", err)
+	}
+	if len(events) == 0 {
+		return "", fmt.Errorf("no statements found in the slow log")
+	}
+	c.replayEvents = events
+	return sqllog.JoinStatements(events), nil
+}
+
 // loadStatementsFromStdin reads SQL statements from standard input
 func (c *Config) loadStatementsFromStdin() (string, error) {
 	scanner := bufio.NewScanner(os.Stdin)
@@ -431,6 +1522,12 @@ func run() error {
 		return err
 	}
 
+	applyColorMode(config.Color)
+
+	if config.ValidateConfig {
+		return validateConfigFile(config)
+	}
+
 	// Load instances
 	instanceList, err := config.LoadInstances()
 	if err != nil {
@@ -441,25 +1538,233 @@ func run() error {
 		return fmt.Errorf("no valid instances found after processing flags and files")
 	}
 
+	if config.ExplainDSN {
+		explainDSNs(config, instanceList)
+		return nil
+	}
+
+	if err := config.openTunnels(); err != nil {
+		return err
+	}
+	defer config.closeTunnels()
+	if config.Proxy != "" {
+		if err := db.RegisterProxy(config.Proxy); err != nil {
+			return fmt.Errorf("--proxy: %w", err)
+		}
+	}
+	if err := config.registerTLS(); err != nil {
+		return err
+	}
+
+	if config.CheckAuth {
+		return checkAuth(context.Background(), config, instanceList)
+	}
+	if config.Kill {
+		return runKill(context.Background(), config, instanceList)
+	}
+
 	// Load SQL statements
 	sqls, err := config.LoadStatements()
 	if err != nil {
 		return fmt.Errorf("failed to load statements: %w", err)
 	}
 
-	// Execute queries
-	return executeQueries(config, instanceList, sqls)
+	if config.Lint {
+		var lintErr error
+		_ = config.sink().Block(db.StreamDiagnostics, func(w io.Writer) {
+			lintErr = lintStatements(w, config.sqlSourceName(), sqls, config.terminator)
+		})
+		if lintErr != nil {
+			return lintErr
+		}
+	}
+	// Only semicolons can be swallowed by a quote; other terminators are unambiguous
+	if config.terminator.Semicolon() {
+		if err := db.CheckTerminated(sqls); err != nil {
+			return fmt.Errorf("%s: %w; no statements were executed", config.sqlSourceName(), err)
+		}
+	}
+
+	// Execute queries; Ctrl-C kills the running statements and ends the run
+	ctx, stop := config.notifyInterrupt(context.Background())
+	defer stop()
+	return executeQueries(ctx, config, instanceList, sqls)
+}
+
+// sqlSourceName names where the statements came from, for diagnostics
+func (c *Config) sqlSourceName() string {
+	switch {
+	case c.Stdin:
+		return "stdin"
+	case c.SQLFile != "":
+		return c.SQLFile
+	case c.File != "":
+		return c.File
+	case c.Runbook != "":
+		return c.Runbook
+	case c.ImportCSV != "":
+		return c.ImportCSV
+	default:
+		return "--statements"
+	}
+}
+
+// statementSource names the script for statement locations such as "deploy.sql:412".
+// It is empty when lines would not help: inline --statements and runbooks, whose
+// lines are not those of a file, statements extracted from logs and scripts
+// rendered once per --for-each-file line.
+func (c *Config) statementSource() string {
+	if (c.InputFormat != "" && c.InputFormat != inputSQL) || c.ForEachFile != "" {
+		return ""
+	}
+	if c.Stdin || c.SQLFile != "" || c.File != "" {
+		return c.sqlSourceName()
+	}
+	return ""
+}
+
+// lintStatements reports lint issues as source:line:column diagnostics and fails
+// if any statement has a syntax error
+func lintStatements(w io.Writer, source string, sqls string, term db.Terminator) error {
+	errCount := 0
+	for _, issue := range db.LintSQL(sqls, term) {
+		fmt.Fprintf(w, "%s:%s\n", source, issue)
+		if !issue.Warning {
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		return fmt.Errorf("lint found %d error(s) in %s; no statements were executed", errCount, source)
+	}
+	return nil
 }
 
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		code := exitFailure
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		os.Exit(code)
 	}
 }
 
 // executeQueries handles the execution of SQL queries against instances
-func executeQueries(config *Config, instanceList []string, sqls string) error {
+func executeQueries(ctx context.Context, config *Config, instanceList []string, sqls string) (err error) {
+	if err := config.guardDropDatabase(sqls); err != nil {
+		return err
+	}
+	if err := config.guardSafeUpdates(sqls); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	startTime := time.Now()
+
+	if config.OutputDir != "" || config.Tee != "" {
+		files, openErr := config.openOutputFiles(instanceList)
+		if openErr != nil {
+			return openErr
+		}
+		defer func() {
+			if closeErr := files.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to write output files: %w", closeErr)
+			}
+		}()
+	}
+
+	opts := db.ExecOptions{
+		Verbose:        config.Verbose,
+		MaxResultBytes: config.MaxResultBytes,
+		StripComments:  config.StripComments,
+		FailoverAware:  config.FailoverAware,
+		KillOnCancel:   config.interruptible || config.watchesStragglers() || config.usesTimeouts() || config.QueryTimeout > 0,
+		MarkQueryID:    config.ShowQueryID,
+		Terminator:     config.terminator,
+		Output:         config.sink(),
+
+		MaxErrorsPerInstance: config.MaxErrorsPerInstance,
+		FirstRowOnly:         config.FirstRowOnly,
+		ColumnsOnly:          config.ShowColumnsTypes,
+		ExecOnly:             config.NullOutput,
+		OnRow:                config.rowHandler(),
+		Source:               config.statementSource(),
+		ExplainOnSlow:        config.ExplainOnSlow,
+		ExplainOnError:       config.ExplainOnError,
+		ExplainWarnings:      config.ExplainWarnings,
+		Tunnels:              config.tunnels,
+		Proxy:                config.Proxy != "",
+		TLS:                  config.tlsMode(),
+		ConnectRetries:       config.ConnectRetries,
+		ConnectTimeout:       config.ConnectTimeout,
+		ReadTimeout:          config.ReadTimeout,
+		WriteTimeout:         config.WriteTimeout,
+		QueryTimeout:         config.QueryTimeout,
+	}
+	if config.MaxTotalRows > 0 || config.MaxTotalBytes > 0 {
+		// The budget cancels ctx once exceeded, skipping whatever hasn't run yet
+		opts.Budget = db.NewRunBudget(config.MaxTotalRows, config.MaxTotalBytes, cancel)
+	}
+	if config.MaxErrors > 0 || config.FailFast {
+		// The limit cancels ctx once reached, skipping whatever hasn't run yet
+		limit, cause := config.MaxErrors, errMaxErrors
+		if config.FailFast {
+			limit, cause = 1, errFailFast
+		}
+		var stop context.CancelCauseFunc
+		ctx, stop = context.WithCancelCause(ctx)
+		defer stop(nil)
+		config.errorLimit = newErrorLimit(limit, cause, stop)
+		defer func() { config.errorLimit = nil }()
+	}
+
+	if config.PreConnect {
+		pool, err := config.preConnect(ctx, instanceList, opts)
+		if err != nil {
+			return err
+		}
+		config.pool = pool
+		defer func() {
+			pool.Close()
+			config.pool = nil
+		}()
+	}
+
+	// Barriers split the statements into phases that every instance finishes before
+	// any instance moves on; sessions are kept open across phases, as they are
+	// across benchmark iterations, and the sessions checked by --verify-charset are
+	// the ones the statements run on
+	phases := db.SplitBarriers(sqls, opts.Terminator)
+	if (len(phases) > 1 || config.Benchmark || config.verifiesCharset()) && config.pool == nil {
+		pool := db.NewInstancePool()
+		config.pool = pool
+		defer func() {
+			pool.Close()
+			config.pool = nil
+		}()
+	}
+
+	if config.verifiesCharset() {
+		if err := config.verifyCharsets(ctx, instanceList, opts); err != nil {
+			return err
+		}
+	}
+
+	config.clock = newRunClock(startTime)
+	defer func() { config.clock = nil }()
+	if config.Format == formatCSV {
+		config.csvHeaders = &csvHeaders{last: make(map[string]string)}
+		defer func() { config.csvHeaders = nil }()
+	}
+	if config.Format == formatXML {
+		// Closed however the run ends, so the output always parses
+		_ = config.sink().Block(db.StreamResults, db.WriteXMLDocumentStart)
+		defer func() { _ = config.sink().Block(db.StreamResults, db.WriteXMLDocumentEnd) }()
+	}
+
 	// --- Assign colors to instances ---
 	instanceColorMap := make(map[string]*color.Color)
 	for i, instanceDSN := range instanceList {
@@ -467,9 +1772,125 @@ func executeQueries(config *Config, instanceList []string, sqls string) error {
 	}
 
 	// --- Execute Concurrently or Sequentially ---
-	fmt.Printf("Executing statements on %d instance(s) (concurrent: %t)...\n", len(instanceList), config.Concurrent)
+	if config.ReplayTiming {
+		config.infof("Replaying the slow log on %d instance(s) at %gx speed...\n", len(instanceList), config.replaySpeed())
+	} else if config.Benchmark {
+		config.infof("Benchmarking %d iteration(s) on %d instance(s) (%s)...\n",
+			config.benchmarkIterations(), len(instanceList), config.concurrencyNote(len(instanceList)))
+	} else {
+		config.infof("Executing statements on %d instance(s) (%s)...\n", len(instanceList), config.concurrencyNote(len(instanceList)))
+	}
+
+	var allResults map[string][]db.QueryResult
+	if config.ReplayTiming {
+		allResults = config.runReplay(ctx, instanceList, opts, systemClock{})
+	} else if config.Benchmark {
+		allResults = config.runBenchmark(ctx, instanceList, sqls, opts)
+	} else {
+		allResults = config.runPhases(ctx, instanceList, phases, opts, instanceColorMap)
+	}
+
+	summary := summarizeRun(instanceList, allResults)
+	summary.applyClock(config.clock, time.Now())
+	if config.Diff {
+		summary.ExpectationFailures += config.printDiff(instanceList, allResults)
+	}
+	summary.ExpectationFailures += config.checkCSVShapes(instanceList, allResults)
+	if config.Parquet != "" {
+		if err := config.writeParquet(instanceList, allResults); err != nil {
+			return err
+		}
+	}
+	if config.RowCountHistogram {
+		_ = config.sink().Block(db.StreamResults, func(w io.Writer) {
+			writeRowCountHistograms(w, instanceList, allResults)
+		})
+	}
+	if config.Report != "" {
+		if err := writeRunReport(config.Report, newRunReport(summary, startTime, time.Since(startTime))); err != nil {
+			return err
+		}
+	}
+
+	if config.PreConnect {
+		_ = config.sink().Block(db.StreamDiagnostics, func(w io.Writer) {
+			writeTimingSummary(w, summary)
+		})
+	}
+	if config.Verbose >= 2 {
+		_ = config.sink().Block(db.StreamDiagnostics, func(w io.Writer) {
+			writeInstanceTimings(w, summary)
+		})
+	}
+
+	if summary.abandoned() {
+		_ = config.sink().Block(db.StreamDiagnostics, func(w io.Writer) {
+			writeAbandonedSummary(w, summary)
+		})
+	}
+
+	var cause error
+	if interrupt := writeInterruptSummary(ctx, config, summary); interrupt != nil {
+		cause = interrupt
+	} else if opts.Budget.Exceeded() {
+		_ = config.sink().Block(db.StreamDiagnostics, func(w io.Writer) {
+			writeBudgetSummary(w, summary, opts.Budget)
+		})
+		cause = db.ErrBudgetExceeded
+	} else if config.errorLimit.reached() && config.FailFast {
+		_ = config.sink().Block(db.StreamDiagnostics, func(w io.Writer) {
+			writeFailFastSummary(w, summary)
+		})
+		cause = errFailFast
+	} else if config.errorLimit.reached() {
+		config.sink().Printf(db.StreamDiagnostics, "Run stopped: %d instance(s) failed (--max-errors %d)\n",
+			config.errorLimit.count.Load(), config.MaxErrors)
+		cause = errMaxErrors
+	} else {
+		config.infof("All executions complete.\n")
+	}
+	if line := summary.failureLine(); line != "" {
+		config.sink().Printf(db.StreamDiagnostics, "%s\n", line)
+	}
+	return exitErrorFor(summary, config.exitCodes, cause)
+}
+
+// runPhases runs the phases between barriers in order. Once statements failed
+// before a barrier, the later phases are skipped unless --barrier-ignore-errors.
+func (c *Config) runPhases(ctx context.Context, instanceList []string, phases []string, opts db.ExecOptions, instanceColorMap map[string]*color.Color) map[string][]db.QueryResult {
+	allResults := make(map[string][]db.QueryResult)
+	var barrierErr error
+	for i, phase := range phases {
+		if barrierErr != nil {
+			for _, instanceDSN := range instanceList {
+				allResults[instanceDSN] = append(allResults[instanceDSN], db.SkippedResults(instanceDSN, phase, opts.Terminator, barrierErr)...)
+			}
+			continue
+		}
+		if i > 0 {
+			c.infof("Barrier %d of %d reached by all instances\n", i, len(phases)-1)
+		}
+
+		phaseResults := c.runPhase(ctx, instanceList, phase, opts, instanceColorMap)
+		for instanceDSN, results := range phaseResults {
+			allResults[instanceDSN] = append(allResults[instanceDSN], results...)
+		}
+		if i < len(phases)-1 && !c.BarrierIgnoreErrors && phaseFailed(instanceList, phaseResults) {
+			barrierErr = errBarrierFailed
+			c.sink().Printf(db.StreamDiagnostics,
+				"Barrier %d: statements failed before the barrier; skipping the remaining phases (see --barrier-ignore-errors)\n", i+1)
+		}
+	}
+	return allResults
+}
 
-	if config.Concurrent {
+// runPhase runs sqls on every instance, concurrently or sequentially, printing the
+// results in instance order. It returns each instance's results.
+func (c *Config) runPhase(ctx context.Context, instanceList []string, sqls string, opts db.ExecOptions, instanceColorMap map[string]*color.Color) map[string][]db.QueryResult {
+	allResults := make(map[string][]db.QueryResult)
+	order := c.newColumnOrder()
+
+	if c.Concurrent {
 		// --- Execute Concurrently ---
 		type instanceResult struct {
 			instance string
@@ -480,9 +1901,25 @@ func executeQueries(config *Config, instanceList []string, sqls string) error {
 		var wg sync.WaitGroup
 		resultsChan := make(chan instanceResult, len(instanceList)) // Buffered channel
 
+		// Each instance runs under a context of its own, so stragglers can be cancelled alone
+		watch := newStragglerWatch(instanceList)
+		stopWatching, watcherDone := make(chan struct{}), make(chan struct{})
+		if c.watchesStragglers() {
+			go func() {
+				defer close(watcherDone)
+				c.watchStragglers(watch, stopWatching)
+			}()
+		} else {
+			close(watcherDone)
+		}
+
+		// At most --max-parallel instances run at once; the others wait for a slot
+		// before they start, so waiting does not count against straggler limits
+		slots := make(chan struct{}, c.parallelism(len(instanceList)))
 		for _, instanceDSN := range instanceList {
+			slots <- struct{}{}
 			wg.Add(1)
-			go func(dsn string) {
+			go func(dsn string, instanceCtx context.Context) {
 				defer func() {
 					if r := recover(); r != nil {
 						resultsChan <- instanceResult{
@@ -490,63 +1927,241 @@ func executeQueries(config *Config, instanceList []string, sqls string) error {
 							err:      fmt.Errorf("panic in goroutine: %v", r),
 						}
 					}
+					watch.done(dsn)
+					<-slots
 					wg.Done()
 				}()
 
 				// Run SQL for this specific instance
-				instanceResults := db.RunSQLOnInstanceWithVerbosity(dsn, sqls, config.Verbose)
+				instanceResults := c.runInstance(instanceCtx, dsn, sqls, opts)
 				resultsChan <- instanceResult{
 					instance: dsn,
 					results:  instanceResults,
 				}
-			}(instanceDSN) // Pass instanceDSN to the goroutine
+			}(instanceDSN, watch.start(ctx, instanceDSN)) // Pass instanceDSN to the goroutine
 		}
 
-		// Wait for all goroutines to complete
+		// Wait for all goroutines to complete, and for the watcher to give the terminal back
 		wg.Wait()
+		close(stopWatching)
+		<-watcherDone
 		close(resultsChan)
 
 		// Collect all results and maintain order
-		allResults := make(map[string][]db.QueryResult)
-		var errors []error
+		var goroutineErrs []error
 
 		for result := range resultsChan {
 			if result.err != nil {
-				errors = append(errors, fmt.Errorf("instance %s: %w", result.instance, result.err))
+				goroutineErrs = append(goroutineErrs, fmt.Errorf("instance %s: %w", result.instance, result.err))
 			} else {
 				allResults[result.instance] = result.results
 			}
 		}
 
 		// Print any goroutine errors
-		for _, err := range errors {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		for _, err := range goroutineErrs {
+			c.sink().Printf(db.StreamDiagnostics, "Error: %v\n", err)
 		}
 
-		// Print results in the original instance order
+		// Aligned in instance order, so the first instance's columns are the reference
 		for _, instanceDSN := range instanceList {
 			if results, exists := allResults[instanceDSN]; exists {
-				instanceColor := instanceColorMap[instanceDSN]
-				for _, res := range results {
-					db.PrintResultWithVerbosity(res, instanceColor, config.TableFormat, config.Verbose)
-					fmt.Println("---")
-				}
+				allResults[instanceDSN] = order.align(results)
+			}
+		}
+		c.coerceColumns(instanceList, allResults)
+
+		// Print results in the original instance order, or failed instances first
+		for _, instanceDSN := range c.printOrder(instanceList, allResults) {
+			if results, exists := allResults[instanceDSN]; exists {
+				c.printResults(instanceDSN, results, instanceColorMap[instanceDSN])
 			}
 		}
 	} else {
 		// --- Execute Sequentially ---
+		// Which instances failed, or the columns of all instances, are only known
+		// once all have run
+		hold := c.ErrorsFirst || c.CoerceColumns
 		for _, instanceDSN := range instanceList {
-			instanceColor := instanceColorMap[instanceDSN] // Get color for this instance
-			instanceResults := db.RunSQLOnInstanceWithVerbosity(instanceDSN, sqls, config.Verbose)
-			for _, res := range instanceResults {
-				db.PrintResultWithVerbosity(res, instanceColor, config.TableFormat, config.Verbose)
-				fmt.Println("---") // Separator between results
+			instanceResults := order.align(c.runInstance(ctx, instanceDSN, sqls, opts))
+			allResults[instanceDSN] = instanceResults
+			if !hold {
+				c.printResults(instanceDSN, instanceResults, instanceColorMap[instanceDSN])
+			}
+		}
+		if hold {
+			c.coerceColumns(instanceList, allResults)
+			for _, instanceDSN := range c.printOrder(instanceList, allResults) {
+				c.printResults(instanceDSN, allResults[instanceDSN], instanceColorMap[instanceDSN])
 			}
 		}
 	}
+	return allResults
+}
 
-	fmt.Println("All executions complete.")
-	return nil
+// parallelism returns how many of a run's instances run at once in concurrent
+// mode: --max-parallel, or all of them when it is 0
+func (c *Config) parallelism(instances int) int {
+	if c.MaxParallel <= 0 || c.MaxParallel > instances {
+		return instances
+	}
+	return c.MaxParallel
+}
+
+// concurrencyNote describes how a run's instances are run, for progress lines:
+// with --concurrent, how many at a time
+func (c *Config) concurrencyNote(instances int) string {
+	if !c.Concurrent {
+		return "concurrent: false"
+	}
+	return fmt.Sprintf("concurrent: true, %d at a time", c.parallelism(instances))
+}
+
+// watchesStragglers reports whether concurrent phases look out for stragglers
+func (c *Config) watchesStragglers() bool {
+	return c.Concurrent && (c.StragglerAfter > 0 || c.StragglerTimeout > 0)
+}
+
+// openOutputFiles creates the --output-dir and --tee files and routes results to
+// them through a sink of the run's own
+func (c *Config) openOutputFiles(instanceList []string) (*db.OutputFiles, error) {
+	if c.output == nil {
+		c.output = db.NewOutputSink(os.Stdout, os.Stderr)
+	}
+	outputDir, err := expandPath(c.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	tee, err := expandPath(c.Tee)
+	if err != nil {
+		return nil, err
+	}
+	return db.OpenOutputFiles(c.output, outputDir, instanceList, tee)
+}
+
+// preConnect opens sessions to all instances up front and reports the outcome.
+// With --require-all, any unreachable instance aborts the run.
+func (c *Config) preConnect(ctx context.Context, instanceList []string, opts db.ExecOptions) (*db.InstancePool, error) {
+	start := time.Now()
+	pool := db.PreConnect(ctx, instanceList, c.parallelism(len(instanceList)), opts)
+	failures := pool.Failures()
+
+	c.infof("Pre-connected %d/%d instance(s) in %v\n",
+		len(instanceList)-len(failures), len(instanceList), time.Since(start).Round(time.Millisecond))
+	for _, instanceDSN := range instanceList {
+		if res, failed := failures[instanceDSN]; failed {
+			c.sink().Printf(db.StreamDiagnostics, "Pre-connect failed: %s: %v\n", db.MaskDSN(instanceDSN), res.Err)
+		}
+	}
+
+	if len(failures) > 0 && c.RequireAll {
+		pool.Close()
+		return nil, fmt.Errorf("--require-all: %d of %d instance(s) could not be reached; no statements were executed",
+			len(failures), len(instanceList))
+	}
+	return pool, nil
+}
+
+// printResults prints an instance's results in order, timing the client work, then
+// its --status-line
+func (c *Config) printResults(instanceDSN string, results []db.QueryResult, instanceColor *color.Color) {
+	if c.NullOutput {
+		c.printNullOutput(instanceDSN, results)
+		return
+	}
+	if c.Diff {
+		return // Compared across instances once all have run
+	}
+	if c.Parquet != "" {
+		c.printParquetFailures(instanceDSN, results, instanceColor)
+		return
+	}
+	results = c.sortResults(results)
+	if c.Format == formatXML {
+		c.printXML(instanceDSN, results)
+		return
+	}
+	vertical := false
+	for _, res := range results {
+		start := time.Now()
+		printResult(c, instanceDSN, res, instanceColor)
+		c.clock.printed(instanceDSN, start)
+		vertical = vertical || res.VerticalFormat
+	}
+	if c.StatusLine {
+		_ = c.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			fmt.Fprintln(w, statusLine(instanceDSN, results))
+		})
+	}
+	// A blank line sets the instance's vertical rows apart from the next instance's
+	if c.VerticalHeaders && vertical && !c.machineReadable() {
+		_ = c.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			fmt.Fprintln(w)
+		})
+	}
+}
+
+// printResult renders a single result of an instance in the configured output mode
+func printResult(config *Config, instanceDSN string, res db.QueryResult, instanceColor *color.Color) {
+	if config.ShowColumnsTypes {
+		printSchema(config, instanceDSN, res)
+		return
+	}
+	if config.Format == formatJSON {
+		var jsonErr error
+		_ = config.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			jsonErr = db.WriteResultJSON(w, res, config.JSONPretty)
+		})
+		if jsonErr != nil {
+			config.sink().Printf(db.StreamDiagnostics, "Error: %s: %v\n", res.Statement, jsonErr)
+		}
+		return
+	}
+	if config.Format == formatCSV {
+		config.printCSV(instanceDSN, res)
+		return
+	}
+	if config.Format == formatNDJSON {
+		config.printNDJSONError(instanceDSN, res)
+		return
+	}
+	if config.Format == formatMarkdown {
+		_ = config.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			db.PrintResultMarkdown(w, res, db.MarkdownOptions{ItalicNull: config.MarkdownItalicNull})
+		})
+		return
+	}
+	if config.Output == outputSQL {
+		var exportErr error
+		_ = config.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			exportErr = db.PrintResultSQL(w, res, config.OutputSQLTable, config.ValuesPerInsert)
+		})
+		if exportErr != nil {
+			config.sink().Printf(db.StreamDiagnostics, "Error: %s: %v\n", res.Statement, exportErr)
+		}
+		return
+	}
+	// Files under --output-dir and --tee are rendered without colors of their own
+	_ = config.sink().RenderFor(instanceDSN, db.StreamResults, func(w io.Writer, plain bool) {
+		db.RenderResult(w, res, instanceColor, db.PrintOptions{
+			TableFormat:       config.TableFormat,
+			Verbose:           config.Verbose,
+			PrettySQL:         config.PrettySQL,
+			Output:            config.sink(),
+			Plain:             plain,
+			TableRowThreshold: config.TableRowThreshold,
+			LargeTable:        config.LargeTable,
+			TableStyle:        config.TableStyle,
+			HeaderTypes:       config.HeaderTypes,
+			TableSampleRows:   config.TableSampleRows,
+			Align:             config.Align,
+			AlignSampleRows:   config.AlignSample,
+			VerticalSeparator: config.VerticalSeparator,
+			VerticalHeaders:   config.VerticalHeaders,
+			Messages:          config.messages,
+		})
+		fmt.Fprintln(w, "---") // Separator between results
+	})
 }
 
 // sanitizeDSN safely handles complex passwords by URL encoding them
@@ -1,9 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/fatih/color"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -50,6 +63,220 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid sql output",
+			config: Config{
+				Instances:       "user:pass@tcp(host:3306)/db",
+				Statements:      "SELECT 1",
+				Output:          "sql",
+				OutputSQLTable:  "db.t",
+				ValuesPerInsert: 100,
+			},
+			wantErr: false,
+		},
+		{
+			name: "sql output without table",
+			config: Config{
+				Instances:       "user:pass@tcp(host:3306)/db",
+				Statements:      "SELECT 1",
+				Output:          "sql",
+				ValuesPerInsert: 100,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid output mode",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				Output:     "yaml",
+			},
+			wantErr: true,
+		},
+		{
+			name: "target replica with json file",
+			config: Config{
+				JSONFile:   "servers.json",
+				Statements: "SELECT 1",
+				Target:     "replica",
+			},
+			wantErr: false,
+		},
+		{
+			name: "target primary without json file",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				Target:     "primary",
+			},
+			wantErr: true,
+		},
+		{
+			name: "failover without json file",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				Failover:   true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "require-all without pre-connect",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				RequireAll: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid color mode",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				Color:      "sometimes",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid table-large mode",
+			config: Config{
+				Instances:   "user:pass@tcp(host:3306)/db",
+				Statements:  "SELECT 1",
+				TableFormat: true,
+				LargeTable:  "paginate",
+			},
+			wantErr: true,
+		},
+		{
+			name: "align with table",
+			config: Config{
+				Instances:   "user:pass@tcp(host:3306)/db",
+				Statements:  "SELECT 1",
+				TableFormat: true,
+				Align:       true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "table style",
+			config: Config{
+				Instances:   "user:pass@tcp(host:3306)/db",
+				Statements:  "SELECT 1",
+				TableFormat: true,
+				TableStyle:  "box",
+			},
+			wantErr: false,
+		},
+		{
+			name: "table style without table",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				TableStyle: "markdown",
+			},
+			wantErr: true,
+		},
+		{
+			name: "header types without table",
+			config: Config{
+				Instances:   "user:pass@tcp(host:3306)/db",
+				Statements:  "SELECT 1",
+				HeaderTypes: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown table style",
+			config: Config{
+				Instances:   "user:pass@tcp(host:3306)/db",
+				Statements:  "SELECT 1",
+				TableFormat: true,
+				TableStyle:  "fancy",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown language",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				Lang:       "tlh",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown input format",
+			config: Config{
+				Instances:   "user:pass@tcp(host:3306)/db",
+				Statements:  "SELECT 1",
+				InputFormat: "slowlog",
+			},
+			wantErr: true,
+		},
+		{
+			name: "binlog filters without binlog-text",
+			config: Config{
+				Instances:      "user:pass@tcp(host:3306)/db",
+				Statements:     "SELECT 1",
+				BinlogDatabase: "shop",
+			},
+			wantErr: true,
+		},
+		{
+			name: "binlog-text with filters",
+			config: Config{
+				Instances:      "user:pass@tcp(host:3306)/db",
+				SQLFile:        "binlog.txt",
+				InputFormat:    inputBinlogText,
+				BinlogDatabase: "shop",
+				BinlogServerID: 1,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid target",
+			config: Config{
+				JSONFile:   "servers.json",
+				Statements: "SELECT 1",
+				Target:     "leader",
+			},
+			wantErr: true,
+		},
+		{
+			name: "check-auth needs no SQL source",
+			config: Config{
+				Instances: "user:pass@tcp(host:3306)/db",
+				CheckAuth: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "check-auth with statements",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				Statements: "SELECT 1",
+				CheckAuth:  true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "explain-dsn needs no SQL source",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				ExplainDSN: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "explain-dsn with check-auth",
+			config: Config{
+				Instances:  "user:pass@tcp(host:3306)/db",
+				ExplainDSN: true,
+				CheckAuth:  true,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -62,6 +289,52 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_Language(t *testing.T) {
+	tests := []struct {
+		lang      string
+		envLang   string
+		wantEmpty string
+	}{
+		{lang: "", envLang: "", wantEmpty: ""},
+		{lang: "", envLang: "de_DE.UTF-8", wantEmpty: "Leere Menge."},
+		{lang: "", envLang: "tlh_XX.UTF-8", wantEmpty: ""}, // Unknown locales fall back to English
+		{lang: "fr", envLang: "de_DE.UTF-8", wantEmpty: "Ensemble vide."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.envLang, func(t *testing.T) {
+			t.Setenv("LC_ALL", "")
+			t.Setenv("LC_MESSAGES", "")
+			t.Setenv("LANG", tt.envLang)
+			config := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1", Lang: tt.lang}
+			if err := config.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if config.messages.EmptySet != tt.wantEmpty {
+				t.Errorf("EmptySet = %q, want %q", config.messages.EmptySet, tt.wantEmpty)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadStatements_BinlogText(t *testing.T) {
+	fixture := filepath.Join("..", "..", "pkg", "sqllog", "testdata", "row.txt")
+
+	config := Config{SQLFile: fixture, InputFormat: inputBinlogText, BinlogDatabase: "audit"}
+	sqls, err := config.LoadStatements()
+	if err != nil {
+		t.Fatalf("LoadStatements() error = %v", err)
+	}
+	want := "BEGIN\n;\nDELETE FROM `audit`.`log` WHERE `id`=-5 AND `msg`='old' LIMIT 1\n;\nCOMMIT\n;\n"
+	if sqls != want {
+		t.Errorf("LoadStatements() = %q, want %q", sqls, want)
+	}
+
+	config.BinlogDatabase = "missing"
+	if _, err := config.LoadStatements(); err == nil || !strings.Contains(err.Error(), "no statements") {
+		t.Errorf("LoadStatements() with no matching events error = %v, want no statements", err)
+	}
+}
+
 func TestValidateDSN(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -341,6 +614,84 @@ func stringSliceEqual(a, b []string) bool {
 	return true
 }
 
+func TestConfig_ApplyPositionalArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		args           []string
+		wantStatements string
+		wantErr        bool
+	}{
+		{
+			name: "no arguments",
+		},
+		{
+			name:           "quoted statement",
+			args:           []string{"SELECT 1; SELECT 2"},
+			wantStatements: "SELECT 1; SELECT 2",
+		},
+		{
+			name:           "unquoted words are joined",
+			args:           []string{"SHOW", "DATABASES"},
+			wantStatements: "SHOW DATABASES",
+		},
+		{
+			name:           "other sources without arguments are kept",
+			config:         Config{Statements: "SELECT 1"},
+			wantStatements: "SELECT 1",
+		},
+		{
+			name:    "combined with --statements",
+			config:  Config{Statements: "SELECT 1"},
+			args:    []string{"SELECT 2"},
+			wantErr: true,
+		},
+		{
+			name:    "combined with --stdin",
+			config:  Config{Stdin: true},
+			args:    []string{"SELECT 2"},
+			wantErr: true,
+		},
+		{
+			name:    "flag after the statement",
+			args:    []string{"SELECT 1", "--table"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			err := config.applyPositionalArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyPositionalArgs(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if !tt.wantErr && config.Statements != tt.wantStatements {
+				t.Errorf("Statements = %q, want %q", config.Statements, tt.wantStatements)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadFromFlags_PositionalSQL(t *testing.T) {
+	originalArgs, originalFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = originalArgs, originalFlags })
+	flag.CommandLine = flag.NewFlagSet("go-csql", flag.ContinueOnError)
+	os.Args = []string{"go-csql", "--instances=user:pass@tcp(host:3306)/db", "-vv", "SELECT @@version"}
+
+	var config Config
+	if err := config.LoadFromFlags(); err != nil {
+		t.Fatalf("LoadFromFlags() error = %v", err)
+	}
+	if config.Statements != "SELECT @@version" || config.Verbose != 2 {
+		t.Errorf("Statements = %q, Verbose = %d; want the positional SQL at -vv", config.Statements, config.Verbose)
+	}
+	sqls, err := config.LoadStatements()
+	if err != nil || sqls != "SELECT @@version" {
+		t.Errorf("LoadStatements() = %q, %v", sqls, err)
+	}
+}
+
 func TestStripJSONComments(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -397,3 +748,442 @@ func TestStripJSONComments(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadInstancesFromJSON_Target(t *testing.T) {
+	servers := `[
+  {"dsn": "user:pass@tcp(db-1:3306)/app", "tags": ["primary"]},
+  {"dsn": "user:pass@tcp(db-2:3306)/app", "tags": ["replica"]},
+  {"dsn": "user:pass@tcp(db-3:3306)/app", "tags": ["Replica", "reporting"]},
+  {"dsn": "user:pass@tcp(db-4:3306)/app"}
+]`
+	jsonFile := filepath.Join(t.TempDir(), "servers.json")
+	if err := os.WriteFile(jsonFile, []byte(servers), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{name: "all", target: "all", want: []string{"db-1", "db-2", "db-3", "db-4"}},
+		{name: "default is all", target: "", want: []string{"db-1", "db-2", "db-3", "db-4"}},
+		{name: "primary", target: "primary", want: []string{"db-1"}},
+		{name: "replica", target: "replica", want: []string{"db-2", "db-3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{JSONFile: jsonFile, Target: tt.target}
+			got, err := c.loadInstancesFromJSON(nil)
+			if err != nil {
+				t.Fatalf("loadInstancesFromJSON() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("loadInstancesFromJSON() = %v, want hosts %v", got, tt.want)
+			}
+			for i, host := range tt.want {
+				if !strings.Contains(got[i], "("+host+":") {
+					t.Errorf("instance %d = %s, want host %s", i, got[i], host)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadInstancesFromJSON_TargetNoMatch(t *testing.T) {
+	jsonFile := filepath.Join(t.TempDir(), "servers.json")
+	content := `[{"dsn": "user:pass@tcp(db-1:3306)/app", "tags": ["replica"]}]`
+	if err := os.WriteFile(jsonFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{JSONFile: jsonFile, Target: "primary"}
+	if _, err := c.loadInstancesFromJSON(nil); err == nil {
+		t.Error("loadInstancesFromJSON() expected error when no server matches --target")
+	}
+}
+
+func TestLintStatements(t *testing.T) {
+	var buf strings.Builder
+	if err := lintStatements(&buf, "ok.sql", "SELECT 1;\nSELECT (2);", db.Terminator{}); err != nil {
+		t.Errorf("lintStatements() error = %v for valid SQL", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("lintStatements() wrote %q for valid SQL", buf.String())
+	}
+
+	buf.Reset()
+	err := lintStatements(&buf, "bad.sql", "SELECT 1;\nSELECT 'oops;\n", db.Terminator{})
+	if err == nil {
+		t.Fatal("lintStatements() expected error for unterminated string")
+	}
+	if want := "bad.sql:2:8: error: unterminated string"; !strings.Contains(buf.String(), want) {
+		t.Errorf("lintStatements() output = %q, want it to contain %q", buf.String(), want)
+	}
+}
+
+func TestExecuteQueries_PreConnect(t *testing.T) {
+	useFakeDriver(t)
+
+	healthy := dbtest.NewServer(t, "preconnect-ok")
+	down := dbtest.NewServer(t, "preconnect-down")
+	down.FailConnect(errors.New("connection refused"))
+	instances := []string{healthy.DSN(), down.DSN()}
+
+	// Without --require-all the unreachable instance is skipped and the rest run
+	config := &Config{PreConnect: true, MaxParallel: 2}
+	var exitErr *exitError
+	if err := executeQueries(context.Background(), config, instances, "SELECT 1"); !errors.As(err, &exitErr) || exitErr.category != categoryConnectionError {
+		t.Fatalf("executeQueries() error = %v, want a connection-error exit", err)
+	}
+	if got := healthy.Executed(); len(got) != 1 || got[0] != "SELECT 1" {
+		t.Errorf("healthy instance executed %v, want [SELECT 1]", got)
+	}
+	if healthy.Opened() != 1 {
+		t.Errorf("healthy instance opened %d connections, want 1", healthy.Opened())
+	}
+
+	// With --require-all nothing runs
+	config = &Config{PreConnect: true, RequireAll: true}
+	if err := executeQueries(context.Background(), config, instances, "SELECT 2"); err == nil {
+		t.Fatal("executeQueries() expected error with --require-all and an unreachable instance")
+	}
+	for _, stmt := range healthy.Executed() {
+		if stmt == "SELECT 2" {
+			t.Error("statement executed despite --require-all failure")
+		}
+	}
+}
+
+func TestExecuteQueries_MaxParallel(t *testing.T) {
+	useFakeDriver(t)
+
+	var instances []string
+	for i := 0; i < 6; i++ {
+		srv := dbtest.NewServer(t, fmt.Sprintf("max-parallel-%d", i))
+		srv.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: dbtest.IntRows(1), Delay: 30 * time.Millisecond})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout bytes.Buffer
+	config := &Config{Concurrent: true, MaxParallel: 2, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	dbtest.ResetConnectStats()
+	baseline := dbtest.OpenConnections() // Left open by earlier tests
+	if err := executeQueries(context.Background(), config, instances, "SELECT 1"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	if got := dbtest.MaxOpenConnections() - baseline; got != 2 {
+		t.Errorf("at most %d connection(s) were open at once, want 2", got)
+	}
+	if want := "Executing statements on 6 instance(s) (concurrent: true, 2 at a time)"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("stdout lacks %q:\n%s", want, stdout.String())
+	}
+
+	// Results still print in instance order
+	last := -1
+	for i := range instances {
+		at := strings.Index(stdout.String(), fmt.Sprintf("max-parallel-%d:", i))
+		if at < last {
+			t.Errorf("instance %d printed out of order:\n%s", i, stdout.String())
+		}
+		last = at
+	}
+}
+
+func TestConfig_Parallelism(t *testing.T) {
+	if got := (&Config{MaxParallel: 3}).parallelism(10); got != 3 {
+		t.Errorf("parallelism(10) = %d with --max-parallel 3", got)
+	}
+	if got := (&Config{MaxParallel: 3}).parallelism(2); got != 2 {
+		t.Errorf("parallelism(2) = %d with --max-parallel 3, want 2", got)
+	}
+	if got := (&Config{MaxParallel: 0}).parallelism(500); got != 500 {
+		t.Errorf("parallelism(500) = %d with --max-parallel 0, want all 500 at once", got)
+	}
+}
+
+func TestConfig_LoadFromFlags_MaxParallel(t *testing.T) {
+	originalArgs, originalFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = originalArgs, originalFlags })
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "unset", want: runtime.NumCPU() * defaultParallelPerCPU},
+		{name: "unlimited", args: []string{"--max-parallel=0"}, want: 0},
+		{name: "explicit", args: []string{"--max-parallel=7"}, want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag.CommandLine = flag.NewFlagSet("go-csql", flag.ContinueOnError)
+			os.Args = append([]string{"go-csql", "--instances=user:pass@tcp(host:3306)/db", "--concurrent", "--statements=SELECT 1"}, tt.args...)
+			var config Config
+			if err := config.LoadFromFlags(); err != nil {
+				t.Fatalf("LoadFromFlags() error = %v", err)
+			}
+			if config.MaxParallel != tt.want {
+				t.Errorf("MaxParallel = %d, want %d", config.MaxParallel, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_MaxParallelUnlimited(t *testing.T) {
+	useFakeDriver(t)
+
+	var instances []string
+	for i := 0; i < 6; i++ {
+		srv := dbtest.NewServer(t, fmt.Sprintf("unlimited-%d", i))
+		srv.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: dbtest.IntRows(1), Delay: 50 * time.Millisecond})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout bytes.Buffer
+	config := &Config{Concurrent: true, MaxParallel: 0, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	dbtest.ResetConnectStats()
+	baseline := dbtest.OpenConnections()
+	if err := executeQueries(context.Background(), config, instances, "SELECT 1"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	if got := dbtest.MaxOpenConnections() - baseline; got != 6 {
+		t.Errorf("at most %d connection(s) were open at once, want all 6", got)
+	}
+	if want := "Executing statements on 6 instance(s) (concurrent: true, 6 at a time)"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("stdout lacks %q:\n%s", want, stdout.String())
+	}
+}
+
+func TestExecuteQueries_OutputDir(t *testing.T) {
+	useFakeDriver(t)
+	originalNoColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = originalNoColor })
+	color.NoColor = true
+
+	first := dbtest.NewServer(t, "outdir-1")
+	first.Handle("SELECT @@hostname", dbtest.Response{Columns: []string{"@@hostname"}, Rows: [][]driver.Value{{"first"}}})
+	second := dbtest.NewServer(t, "outdir-2")
+	second.Handle("SELECT @@hostname", dbtest.Response{Columns: []string{"@@hostname"}, Rows: [][]driver.Value{{"second"}}})
+
+	dir := filepath.Join(t.TempDir(), "out")
+	teePath := filepath.Join(t.TempDir(), "tee.log")
+	var stdout, stderr bytes.Buffer
+	config := &Config{Concurrent: true, OutputDir: dir, Tee: teePath, output: db.NewOutputSink(&stdout, &stderr)}
+	if err := executeQueries(context.Background(), config, []string{first.DSN(), second.DSN()}, "SELECT @@hostname"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+
+	for host, want := range map[string]string{"outdir-1": "first", "outdir-2": "second"} {
+		data, err := os.ReadFile(filepath.Join(dir, host+"_3306_app.out"))
+		if err != nil {
+			t.Fatalf("output file for %s: %v", host, err)
+		}
+		if !strings.Contains(string(data), want+"\n") || strings.Count(string(data), "---") != 1 {
+			t.Errorf("output file for %s = %q, want only its own result", host, data)
+		}
+	}
+
+	tee, err := os.ReadFile(teePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"first\n", "second\n", "All executions complete."} {
+		if !strings.Contains(string(tee), want) {
+			t.Errorf("tee file missing %q:\n%s", want, tee)
+		}
+	}
+	if strings.Contains(stdout.String(), "first") || strings.Contains(stdout.String(), "second") {
+		t.Errorf("results also printed to stdout:\n%s", stdout.String())
+	}
+}
+
+func TestExecuteQueries_FilesWithoutColor(t *testing.T) {
+	useFakeDriver(t)
+	originalNoColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = originalNoColor })
+
+	// A raw binary value holding an ESC byte must reach the files untouched
+	const binary = "\x1b[0m\x00raw"
+	srv := dbtest.NewServer(t, "nocolor")
+	srv.Handle("SELECT id, payload FROM blobs", dbtest.Response{Columns: []string{"id", "payload"}, Rows: [][]driver.Value{{int64(1), []byte(binary)}}})
+	srv.Handle("SELECT nope", dbtest.Response{Err: errors.New("unknown column 'nope'")})
+
+	// run writes the results of one run to stdout and to a tee file, and returns both
+	run := func(t *testing.T, colored bool) (stdout, tee string) {
+		color.NoColor = !colored
+		teePath := filepath.Join(t.TempDir(), "tee.log")
+		var out bytes.Buffer
+		config := &Config{PrettySQL: true, Tee: teePath, output: db.NewOutputSink(&out, &bytes.Buffer{})}
+		err := executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT id, payload FROM blobs;\nSELECT nope")
+		var exitErr *exitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("executeQueries() error = %v, want the failing statement's exit", err)
+		}
+		data, readErr := os.ReadFile(teePath)
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		return out.String(), string(data)
+	}
+
+	coloredStdout, coloredTee := run(t, true)
+	plainStdout, plainTee := run(t, false)
+
+	if coloredTee != plainTee {
+		t.Errorf("tee file differs with a colored terminal:\n%q\nwithout:\n%q", coloredTee, plainTee)
+	}
+	if !strings.Contains(plainTee, binary) || strings.Count(plainTee, "\x1b") != 1 {
+		t.Errorf("tee file = %q, want the binary value as its only ESC byte", plainTee)
+	}
+	if coloredStdout == plainStdout || !strings.Contains(coloredStdout, "\x1b[1m") {
+		t.Errorf("terminal output is not colored:\n%q", coloredStdout)
+	}
+}
+
+func TestApplyColorMode(t *testing.T) {
+	originalTTY, originalNoColor := stdoutIsTerminal, color.NoColor
+	t.Cleanup(func() { stdoutIsTerminal, color.NoColor = originalTTY, originalNoColor })
+
+	tests := []struct {
+		mode      string
+		tty       bool
+		noColor   string // NO_COLOR environment value
+		wantColor bool
+	}{
+		{mode: "auto", tty: true, wantColor: true},
+		{mode: "auto", tty: false, wantColor: false},
+		{mode: "auto", tty: true, noColor: "1", wantColor: false},
+		{mode: "", tty: true, wantColor: true},
+		{mode: "always", tty: true, wantColor: true},
+		{mode: "always", tty: false, wantColor: true},
+		{mode: "always", tty: false, noColor: "1", wantColor: true},
+		{mode: "never", tty: true, wantColor: false},
+		{mode: "never", tty: false, wantColor: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/tty=%t/NO_COLOR=%q", tt.mode, tt.tty, tt.noColor), func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			tty := tt.tty
+			stdoutIsTerminal = func() bool { return tty }
+
+			applyColorMode(tt.mode)
+			if got := !color.NoColor; got != tt.wantColor {
+				t.Errorf("color enabled = %t, want %t", got, tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_VerticalHeaders(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for _, name := range []string{"vertical-1", "vertical-2"} {
+		srv := dbtest.NewServer(t, name)
+		srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(2)})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout bytes.Buffer
+	config := &Config{VerticalHeaders: true, VerticalSeparator: 3, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, instances, "SELECT id FROM t\\G"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	want := "=== user:****@tcp(vertical-1:3306)/app ===\n*** 1. row ***\nid: 1\n*** 2. row ***\nid: 2\n"
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("output does not head the rows with the instance, want %q in:\n%s", want, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "---\n\n[user:****@tcp(vertical-2:3306)/app]") {
+		t.Errorf("output has no blank line between instances:\n%s", stdout.String())
+	}
+}
+
+func TestLoadInstances_Env(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // No ~/.my.cnf
+	jsonFile := filepath.Join(t.TempDir(), "servers.json")
+	if err := os.WriteFile(jsonFile, []byte(`[{"dsn": "user:pass@tcp(json-1:3306)/app"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHARDS", " user:p@ss@tcp(env-1:3306)/app, ,user:pass@tcp(env-2:3306)/app ")
+	t.Setenv("EMPTY", " , ")
+	t.Setenv(defaultInstancesEnv, "user:pass@tcp(default-1:3306)/app")
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    []string // Hosts
+		wantErr bool
+	}{
+		{name: "instances-env", config: Config{InstancesEnv: "SHARDS"}, want: []string{"env-1", "env-2"}},
+		{name: "instances beats env", config: Config{Instances: "user:pass@tcp(flag-1:3306)/app", InstancesEnv: "SHARDS"}, want: []string{"flag-1"}},
+		{name: "env beats json", config: Config{InstancesEnv: "SHARDS", JSONFile: jsonFile}, want: []string{"env-1", "env-2"}},
+		{name: "json beats the default variable", config: Config{JSONFile: jsonFile}, want: []string{"json-1"}},
+		{name: "default variable", config: Config{}, want: []string{"default-1"}},
+		{name: "empty variable", config: Config{InstancesEnv: "EMPTY"}, wantErr: true},
+		{name: "unset variable", config: Config{InstancesEnv: "CSQL_TEST_UNSET"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			c.Statements = "SELECT 1"
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := c.LoadInstances()
+			if err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LoadInstances() = %v, want hosts %v", got, tt.want)
+			}
+			for i, host := range tt.want {
+				if !strings.Contains(got[i], "("+host+":") {
+					t.Errorf("instance %d = %s, want host %s", i, got[i], host)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadInstances_DefaultsFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home) // For ~ in --defaults-file
+	if err := os.WriteFile(filepath.Join(home, "client.cnf"), []byte("[client]\nuser=project_user\npassword=project_pw\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		defaultsFile string
+		wantErr      bool
+	}{
+		{name: "tilde", defaultsFile: "~/client.cnf"},
+		{name: "absolute", defaultsFile: filepath.Join(home, "client.cnf")},
+		{name: "missing", defaultsFile: "~/missing.cnf", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{Instances: "@tcp(db1:3306)/app", DefaultsFile: tt.defaultsFile}
+			got, err := c.LoadInstances()
+			if tt.wantErr {
+				// Unlike a missing ~/.my.cnf, a missing --defaults-file is an error
+				if !errors.Is(err, db.ErrMyCnfNotFound) || !strings.Contains(err.Error(), "missing.cnf") {
+					t.Fatalf("LoadInstances() error = %v, want ErrMyCnfNotFound naming the file", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadInstances() error = %v", err)
+			}
+			if len(got) != 1 || !strings.HasPrefix(got[0], "project_user:project_pw@") {
+				t.Errorf("LoadInstances() = %v, want credentials from %s", got, tt.defaultsFile)
+			}
+		})
+	}
+}
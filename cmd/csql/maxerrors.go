@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// errMaxErrors is why statements are skipped once --max-errors instances failed
+var errMaxErrors = errors.New("run stopped: too many instances failed (--max-errors)")
+
+// errFailFast is why statements are skipped once an instance failed with --fail-fast
+var errFailFast = errors.New("stopped due to an earlier failure (--fail-fast)")
+
+// errorLimit stops a run once a number of instances had a statement fail or could
+// not be reached. It is safe for concurrent use; a nil *errorLimit never stops.
+type errorLimit struct {
+	max    int64
+	failed sync.Map // Instances already counted, so one failing in several phases counts once
+	count  atomic.Int64
+	cause  error // Passed to stop: errMaxErrors or errFailFast
+	stop   context.CancelCauseFunc
+}
+
+// newErrorLimit creates a limit that calls stop with cause once limit instances
+// have failed
+func newErrorLimit(limit int, cause error, stop context.CancelCauseFunc) *errorLimit {
+	return &errorLimit{max: int64(limit), cause: cause, stop: stop}
+}
+
+// record counts an instance whose results include a failed statement. Statements
+// skipped because the run was already stopping do not count.
+func (l *errorLimit) record(instanceDSN string, results []db.QueryResult) {
+	if instanceFailed(results) {
+		l.fail(instanceDSN)
+	}
+}
+
+// fail counts an instance as failed, once however many of its statements fail,
+// and stops the run when that reaches the limit
+func (l *errorLimit) fail(instanceDSN string) {
+	if l == nil {
+		return
+	}
+	if _, counted := l.failed.LoadOrStore(instanceDSN, true); counted {
+		return
+	}
+	if l.count.Add(1) == l.max {
+		l.stop(l.cause)
+	}
+}
+
+// reached reports whether the run was stopped
+func (l *errorLimit) reached() bool {
+	return l != nil && l.count.Load() >= l.max
+}
+
+// instanceFailed reports whether a statement failed on an instance, or it could not
+// be reached
+func instanceFailed(results []db.QueryResult) bool {
+	for _, res := range results {
+		if res.Err != nil && !res.Skipped {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFailFastSummary reports where a --fail-fast run stopped: the instances that
+// failed, those cancelled mid-statement and those not attempted, where a rerun can
+// resume
+func writeFailFastSummary(w io.Writer, summary runSummary) {
+	var failed, cancelled, notAttempted []string
+	for _, s := range summary.Instances {
+		switch {
+		case !s.started():
+			notAttempted = append(notAttempted, s.Instance)
+		case s.Failed > s.Stopped:
+			failed = append(failed, s.Instance)
+		case s.Stopped > 0:
+			cancelled = append(cancelled, s.Instance)
+		}
+	}
+	fmt.Fprintf(w, "Run stopped at the first failure (--fail-fast): %s\n", maskedInstances(failed))
+	if len(cancelled) > 0 {
+		fmt.Fprintf(w, "Cancelled while running: %s\n", maskedInstances(cancelled))
+	}
+	if len(notAttempted) > 0 {
+		fmt.Fprintf(w, "Not attempted due to earlier failure: %s\n", maskedInstances(notAttempted))
+	}
+}
+
+// maskedInstances joins DSNs with their passwords masked
+func maskedInstances(instances []string) string {
+	masked := make([]string, len(instances))
+	for i, instanceDSN := range instances {
+		masked[i] = db.MaskDSN(instanceDSN)
+	}
+	return strings.Join(masked, ", ")
+}
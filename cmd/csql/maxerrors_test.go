@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestErrorLimit_Record(t *testing.T) {
+	var stopped []error
+	limit := newErrorLimit(2, errMaxErrors, func(cause error) { stopped = append(stopped, cause) })
+	failed := []db.QueryResult{{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")}}
+	skipped := []db.QueryResult{{Err: errMaxErrors, Skipped: true}}
+
+	limit.record("db1", failed)
+	limit.record("db1", failed) // A later phase of the same instance
+	limit.record("db2", skipped)
+	limit.record("db3", []db.QueryResult{{}})
+	if limit.reached() || len(stopped) > 0 {
+		t.Fatalf("stopped after one failed instance, want the limit of 2 to hold")
+	}
+	limit.record("db4", failed)
+	limit.record("db5", failed)
+	if !limit.reached() || len(stopped) != 1 || stopped[0] != errMaxErrors {
+		t.Errorf("stop calls = %v, want one with errMaxErrors once two instances failed", stopped)
+	}
+
+	var none *errorLimit
+	none.record("db1", failed)
+	if none.reached() {
+		t.Error("a nil limit reached, want it to never stop a run")
+	}
+}
+
+func TestExecuteQueries_MaxErrors(t *testing.T) {
+	useFakeDriver(t)
+	var servers []*dbtest.Server
+	var instances []string
+	for i, fail := range []bool{false, true, true, false, true} {
+		srv := dbtest.NewServer(t, fmt.Sprintf("max-errors-%d", i+1))
+		if fail {
+			srv.Handle("UPDATE t SET c = 1", dbtest.Response{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")})
+		} else {
+			srv.Handle("UPDATE t SET c = 1", dbtest.Response{})
+		}
+		servers = append(servers, srv)
+		instances = append(instances, srv.DSN())
+	}
+
+	var stderr bytes.Buffer
+	config := &Config{MaxErrors: 2, output: db.NewOutputSink(&bytes.Buffer{}, &stderr)}
+	err := executeQueries(context.Background(), config, instances, "UPDATE t SET c = 1")
+	if !errors.Is(err, errMaxErrors) {
+		t.Fatalf("executeQueries() error = %v, want errMaxErrors", err)
+	}
+	for i, srv := range servers {
+		ran := len(srv.Executed()) > 0
+		if want := i < 3; ran != want {
+			t.Errorf("instance %d ran statements = %t, want %t", i+1, ran, want)
+		}
+	}
+	if !strings.Contains(stderr.String(), "Run stopped: 2 instance(s) failed (--max-errors 2)") {
+		t.Errorf("diagnostics do not say why the run stopped:\n%s", stderr.String())
+	}
+}
+
+func TestExecuteQueries_FailFast(t *testing.T) {
+	useFakeDriver(t)
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%t", concurrent), func(t *testing.T) {
+			var servers []*dbtest.Server
+			var instances []string
+			for i := 0; i < 5; i++ {
+				srv := dbtest.NewServer(t, fmt.Sprintf("fail-fast-%t-%d", concurrent, i+1))
+				switch i {
+				case 0:
+					srv.Handle("ALTER TABLE t ADD c INT", dbtest.Response{Err: errors.New("Error 1060: Duplicate column name 'c'")})
+				case 1:
+					srv.Handle("ALTER TABLE t ADD c INT", dbtest.Response{Delay: 10 * time.Second}) // In flight when the first fails
+				default:
+					srv.Handle("ALTER TABLE t ADD c INT", dbtest.Response{})
+				}
+				servers = append(servers, srv)
+				instances = append(instances, srv.DSN())
+			}
+
+			var stdout, stderr bytes.Buffer
+			config := &Config{FailFast: true, Concurrent: concurrent, MaxParallel: 2, output: db.NewOutputSink(&stdout, &stderr)}
+			start := time.Now()
+			err := executeQueries(context.Background(), config, instances, "ALTER TABLE t ADD c INT")
+			if !errors.Is(err, errFailFast) {
+				t.Fatalf("executeQueries() error = %v, want errFailFast", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("run took %s, want the in-flight instance cancelled", elapsed)
+			}
+			for i, srv := range servers[2:] {
+				if len(srv.Executed()) > 0 {
+					t.Errorf("instance %d ran statements after the first failure", i+3)
+				}
+			}
+			if !strings.Contains(stdout.String(), "SKIPPED : stopped due to an earlier failure (--fail-fast)") {
+				t.Errorf("output does not mark the skipped instances:\n%s", stdout.String())
+			}
+
+			// Sequentially the second instance has not started either
+			dsn := func(i int) string { return fmt.Sprintf("user:****@tcp(fail-fast-%t-%d:3306)/app", concurrent, i) }
+			want := "Run stopped at the first failure (--fail-fast): " + dsn(1) + "\n"
+			if concurrent {
+				want += "Cancelled while running: " + dsn(2) + "\n" +
+					"Not attempted due to earlier failure: " + dsn(3) + ", " + dsn(4) + ", " + dsn(5) + "\n"
+			} else {
+				want += "Not attempted due to earlier failure: " + dsn(2) + ", " + dsn(3) + ", " + dsn(4) + ", " + dsn(5) + "\n"
+			}
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("diagnostics do not say where the run stopped:\n%s\nwant\n%s", stderr.String(), want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_FailFastScript(t *testing.T) {
+	useFakeDriver(t)
+	const script = "ALTER TABLE t ADD c INT;\nUPDATE t SET c = 1 WHERE id > 0"
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%t", concurrent), func(t *testing.T) {
+			var servers []*dbtest.Server
+			var instances []string
+			for i := 0; i < 4; i++ {
+				srv := dbtest.NewServer(t, fmt.Sprintf("fail-fast-script-%t-%d", concurrent, i+1))
+				if i == 0 {
+					srv.Handle("ALTER TABLE t ADD c INT", dbtest.Response{Err: errors.New("Error 1060: Duplicate column name 'c'")})
+					srv.Handle("UPDATE t SET c = 1 WHERE id > 0", dbtest.Response{Delay: 3 * time.Second})
+				}
+				servers = append(servers, srv)
+				instances = append(instances, srv.DSN())
+			}
+
+			var stderr bytes.Buffer
+			config := &Config{FailFast: true, Concurrent: concurrent, MaxParallel: 1, output: db.NewOutputSink(&bytes.Buffer{}, &stderr)}
+			start := time.Now()
+			err := executeQueries(context.Background(), config, instances, script)
+			if !errors.Is(err, errFailFast) {
+				t.Fatalf("executeQueries() error = %v, want errFailFast", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("run took %s, want it stopped at the failed ALTER", elapsed)
+			}
+
+			// The failing instance's UPDATE never runs after its ALTER failed
+			if executed := strings.Join(servers[0].Executed(), "\n"); strings.Contains(executed, "UPDATE") {
+				t.Errorf("failing instance executed:\n%s\nwant nothing after the failed ALTER", executed)
+			}
+			for i, srv := range servers[1:] {
+				if len(srv.Executed()) > 0 {
+					t.Errorf("instance %d ran statements after the first failure", i+2)
+				}
+			}
+			dsn := func(i int) string {
+				return fmt.Sprintf("user:****@tcp(fail-fast-script-%t-%d:3306)/app", concurrent, i)
+			}
+			want := "Run stopped at the first failure (--fail-fast): " + dsn(1) + "\n" +
+				"Not attempted due to earlier failure: " + dsn(2) + ", " + dsn(3) + ", " + dsn(4) + "\n"
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("diagnostics do not say where the run stopped:\n%s\nwant\n%s", stderr.String(), want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_MaxErrorsScript(t *testing.T) {
+	useFakeDriver(t)
+	fail := dbtest.Response{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")}
+	var servers []*dbtest.Server
+	var instances []string
+	for i := 0; i < 3; i++ {
+		srv := dbtest.NewServer(t, fmt.Sprintf("max-errors-script-%d", i+1))
+		srv.Handle("UPDATE t SET c = 1", fail)
+		if i == 0 {
+			srv.Handle("UPDATE t SET c = 2", fail) // A second failure of the same instance
+		}
+		servers = append(servers, srv)
+		instances = append(instances, srv.DSN())
+	}
+
+	var stderr bytes.Buffer
+	config := &Config{MaxErrors: 2, output: db.NewOutputSink(&bytes.Buffer{}, &stderr)}
+	err := executeQueries(context.Background(), config, instances, "UPDATE t SET c = 1; UPDATE t SET c = 2")
+	if !errors.Is(err, errMaxErrors) {
+		t.Fatalf("executeQueries() error = %v, want errMaxErrors", err)
+	}
+
+	// The first instance counts once for its two failures, so the second still runs
+	// and stops the run at its first failure
+	want := []string{"UPDATE t SET c = 1\nUPDATE t SET c = 2", "UPDATE t SET c = 1", ""}
+	for i, srv := range servers {
+		if got := strings.Join(srv.Executed(), "\n"); got != want[i] {
+			t.Errorf("instance %d executed %q, want %q", i+1, got, want[i])
+		}
+	}
+	if !strings.Contains(stderr.String(), "Run stopped: 2 instance(s) failed (--max-errors 2)") {
+		t.Errorf("diagnostics do not say why the run stopped:\n%s", stderr.String())
+	}
+}
+
+func TestConfig_LoadFromFlags_StopOnError(t *testing.T) {
+	originalArgs, originalFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = originalArgs, originalFlags })
+
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{args: []string{"--stop-on-error"}, want: 1},
+		{args: []string{"--stop-on-error", "--max-errors-per-instance=1"}, want: 1},
+		{args: []string{"--stop-on-error", "--max-errors-per-instance=3"}, wantErr: true},
+		{args: nil, want: 0},
+	}
+	for _, tt := range tests {
+		flag.CommandLine = flag.NewFlagSet("go-csql", flag.ContinueOnError)
+		os.Args = append([]string{"go-csql", "--instances=user:pass@tcp(host:3306)/db", "--statements=SELECT 1"}, tt.args...)
+		var config Config
+		err := config.LoadFromFlags()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("LoadFromFlags(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if err == nil && config.MaxErrorsPerInstance != tt.want {
+			t.Errorf("LoadFromFlags(%q): MaxErrorsPerInstance = %d, want %d", tt.args, config.MaxErrorsPerInstance, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// rowHandler returns the handler that streams rows for --format ndjson as they
+// are read, or nil when results are printed once complete
+func (c *Config) rowHandler() db.RowHandler {
+	if c.Format != formatNDJSON {
+		return nil
+	}
+	return func(instanceDSN, statement string, columns []string, row []interface{}) {
+		var rowErr error
+		_ = c.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+			rowErr = db.WriteRowNDJSON(w, instanceDSN, statement, columns, row)
+		})
+		if rowErr != nil {
+			c.sink().Printf(db.StreamDiagnostics, "Error: %s: %v\n", statement, rowErr)
+		}
+	}
+}
+
+// printNDJSONError prints the error of a failed or skipped statement for --format
+// ndjson; the rows of the others were streamed while they ran
+func (c *Config) printNDJSONError(instanceDSN string, res db.QueryResult) {
+	if res.Err == nil {
+		return
+	}
+	_ = c.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+		_ = db.WriteErrorNDJSON(w, res)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/fatih/color"
+)
+
+// Labels of the --null-output summary lines
+var (
+	nullOutputOK    = color.New(color.FgGreen, color.Bold)
+	nullOutputError = color.New(color.FgRed, color.Bold)
+)
+
+// nullOutputLine summarizes an instance's results for --null-output, e.g.
+// "OK    db1:3306  3 statement(s), 12 row(s) affected in 40ms" or
+// "ERROR db2:3306  1 of 3 statement(s) failed: ...". The error is that of the
+// first statement that failed, or else the reason statements were skipped.
+func nullOutputLine(instanceDSN string, results []db.QueryResult) (bool, string) {
+	if len(results) > 0 && results[0].Instance != "" {
+		instanceDSN = results[0].Instance // The group member that served, under --failover
+	}
+	addr := db.InstanceAddr(instanceDSN)
+	if len(results) == 1 && results[0].ConnectFailed {
+		return false, fmt.Sprintf("%s  connection failed: %v", addr, results[0].Err)
+	}
+
+	failed, skipped := 0, 0
+	var affected int64
+	var duration time.Duration
+	var executedErr, skippedErr error
+	for _, res := range results {
+		affected += res.RowsAffected
+		duration += res.Duration
+		switch {
+		case res.Err == nil:
+		case res.Skipped:
+			skipped++
+			if skippedErr == nil {
+				skippedErr = res.Err
+			}
+		default:
+			failed++
+			if executedErr == nil {
+				executedErr = res.Err
+			}
+		}
+	}
+
+	switch {
+	case executedErr != nil:
+		return false, fmt.Sprintf("%s  %d of %d statement(s) failed: %v", addr, failed, len(results), executedErr)
+	case skippedErr != nil:
+		return false, fmt.Sprintf("%s  %d of %d statement(s) skipped: %v", addr, skipped, len(results), skippedErr)
+	}
+	return true, fmt.Sprintf("%s  %d statement(s), %d row(s) affected in %s",
+		addr, len(results), affected, duration.Round(time.Millisecond))
+}
+
+// printNullOutput prints the --null-output line of an instance in place of its results
+func (c *Config) printNullOutput(instanceDSN string, results []db.QueryResult) {
+	ok, line := nullOutputLine(instanceDSN, results)
+	label, labelColor := "OK   ", nullOutputOK
+	if !ok {
+		label, labelColor = "ERROR", nullOutputError
+	}
+	_ = c.sink().RenderFor(instanceDSN, db.StreamResults, func(w io.Writer, plain bool) {
+		if plain {
+			fmt.Fprintf(w, "%s %s\n", label, line)
+			return
+		}
+		fmt.Fprintf(w, "%s %s\n", labelColor.Sprint(label), line)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestNullOutputLine(t *testing.T) {
+	const dsn = "u:p@tcp(db1:3306)/app"
+	tests := []struct {
+		name     string
+		results  []db.QueryResult
+		wantOK   bool
+		wantLine string
+	}{
+		{
+			name: "all succeeded",
+			results: []db.QueryResult{
+				{Instance: dsn, RowsAffected: 2, Duration: 10 * time.Millisecond},
+				{Instance: dsn, RowsAffected: 3, Duration: 20 * time.Millisecond},
+			},
+			wantOK:   true,
+			wantLine: "db1:3306  2 statement(s), 5 row(s) affected in 30ms",
+		},
+		{
+			name: "first failure is reported",
+			results: []db.QueryResult{
+				{Instance: dsn},
+				{Instance: dsn, Err: errors.New("lock wait timeout")},
+				{Instance: dsn, Err: errors.New("later failure")},
+			},
+			wantLine: "db1:3306  2 of 3 statement(s) failed: lock wait timeout",
+		},
+		{
+			name: "skipped",
+			results: []db.QueryResult{
+				{Instance: dsn},
+				{Instance: dsn, Skipped: true, Err: context.Canceled},
+			},
+			wantLine: "db1:3306  1 of 2 statement(s) skipped: context canceled",
+		},
+		{
+			name:     "connection failed",
+			results:  []db.QueryResult{{Instance: dsn, ConnectFailed: true, Err: errors.New("connection refused")}},
+			wantLine: "db1:3306  connection failed: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, line := nullOutputLine(dsn, tt.results)
+			if ok != tt.wantOK || line != tt.wantLine {
+				t.Errorf("nullOutputLine() = %t, %q; want %t, %q", ok, line, tt.wantOK, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_NullOutput(t *testing.T) {
+	useFakeDriver(t)
+	good, bad := dbtest.NewServer(t, "null-ok"), dbtest.NewServer(t, "null-err")
+	for _, srv := range []*dbtest.Server{good, bad} {
+		srv.Handle("ALTER TABLE t ADD COLUMN c INT", dbtest.Response{})
+		srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(4)})
+	}
+	bad.Handle("ALTER TABLE t ADD COLUMN c INT", dbtest.Response{Err: errors.New("Error 1060: Duplicate column name 'c'")})
+
+	var stdout bytes.Buffer
+	config := &Config{NullOutput: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	executeQueries(context.Background(), config, []string{good.DSN(), bad.DSN()}, "ALTER TABLE t ADD COLUMN c INT; SELECT id FROM t")
+
+	out := stdout.String()
+	for _, want := range []string{
+		"OK    null-ok:3306  2 statement(s), 4 row(s) affected in ",
+		"ERROR null-err:3306  1 of 2 statement(s) failed: query error: Error 1060: Duplicate column name 'c'",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	// The SELECT's rows are discarded, not printed
+	if strings.Contains(out, "SELECT id FROM t") || strings.Contains(out, "---") {
+		t.Errorf("output has results besides the summary lines:\n%s", out)
+	}
+}
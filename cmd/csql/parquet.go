@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/fatih/color"
+)
+
+// validateParquet checks the --parquet options
+func (c *Config) validateParquet() error {
+	if c.Parquet == "" {
+		return nil
+	}
+	if !db.HasParquetEncoder() {
+		return fmt.Errorf("--parquet: %w", db.ErrParquetUnavailable)
+	}
+	if c.Diff || c.NullOutput || c.Output == outputSQL || (c.Format != "" && c.Format != formatText) ||
+		c.ShowColumnsTypes || c.Benchmark || c.ReplayTiming || c.OutputDir != "" {
+		return fmt.Errorf("--parquet cannot be combined with --diff, --null-output, --output sql, --format other than text, --show-columns-types, --benchmark, --replay-timing or --output-dir")
+	}
+	return nil
+}
+
+// printParquetFailures prints an instance's failed and skipped statements, which
+// have no rows for the --parquet file
+func (c *Config) printParquetFailures(instanceDSN string, results []db.QueryResult, instanceColor *color.Color) {
+	for _, res := range results {
+		if res.Err != nil {
+			printResult(c, instanceDSN, res, instanceColor)
+		}
+	}
+}
+
+// writeParquet writes the rows of a run's results, in instance order, to the
+// --parquet file
+func (c *Config) writeParquet(instanceList []string, allResults map[string][]db.QueryResult) error {
+	var results []db.QueryResult
+	for _, instanceDSN := range instanceList {
+		results = append(results, allResults[instanceDSN]...)
+	}
+	f, err := os.Create(c.Parquet)
+	if err != nil {
+		return fmt.Errorf("--parquet: %w", err)
+	}
+	rows, err := db.WriteParquet(f, results)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(c.Parquet) // A partial file would not parse
+		return fmt.Errorf("--parquet %s: %w", c.Parquet, err)
+	}
+	c.infof("Wrote %d row(s) to %s\n", rows, c.Parquet)
+	return nil
+}
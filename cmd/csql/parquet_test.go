@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+// countingEncoder is a ParquetEncoder writing one line per row
+type countingEncoder struct{}
+
+func (countingEncoder) Encode(w io.Writer, columns []db.ParquetColumn, rows [][]*string) error {
+	for _, row := range rows {
+		if _, err := io.WriteString(w, *row[0]+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExecuteQueries_Parquet(t *testing.T) {
+	if db.HasParquetEncoder() {
+		t.Skip("built with a Parquet encoder, which pkg/db tests read back")
+	}
+	useFakeDriver(t)
+	db.RegisterParquetEncoder(countingEncoder{})
+	t.Cleanup(func() { db.RegisterParquetEncoder(nil) })
+
+	var instances []string
+	for i, host := range []string{"parquet-1", "parquet-2"} {
+		srv := dbtest.NewServer(t, host)
+		srv.Handle("SELECT n FROM t", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(i + 2)})
+		srv.Handle("SELECT missing FROM t", dbtest.Response{Err: errors.New("Error 1054: Unknown column 'missing'")})
+		instances = append(instances, srv.DSN())
+	}
+
+	path := filepath.Join(t.TempDir(), "out.parquet")
+	var stdout bytes.Buffer
+	config := &Config{Instances: "x", Statements: "x", Parquet: path}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	config.output = db.NewOutputSink(&stdout, &bytes.Buffer{})
+	_ = executeQueries(context.Background(), config, instances, "SELECT n FROM t; SELECT missing FROM t")
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Repeat("user:****@tcp(parquet-1:3306)/app\n", 2) + strings.Repeat("user:****@tcp(parquet-2:3306)/app\n", 3)
+	if string(written) != want {
+		t.Errorf("file =\n%s\nwant\n%s", written, want)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "Unknown column 'missing'") || strings.Contains(out, "SELECT n FROM t") {
+		t.Errorf("stdout should hold only the failed statements:\n%s", out)
+	}
+}
+
+func TestConfig_Validate_Parquet(t *testing.T) {
+	config := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1", Parquet: "out.parquet"}
+	err := config.Validate()
+	if db.HasParquetEncoder() {
+		if err != nil {
+			t.Errorf("Validate() error = %v", err)
+		}
+	} else if !errors.Is(err, db.ErrParquetUnavailable) {
+		t.Errorf("Validate() error = %v, want ErrParquetUnavailable in a build without -tags parquet", err)
+	}
+
+	config.Diff = true
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() accepted --parquet with --diff")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// readPassword prompts on stderr and reads a password from the terminal on stdin
+// without echoing it. Tests substitute a stub.
+var readPassword = func() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("--password-prompt needs a terminal to read the password from, and stdin is not one")
+	}
+	fmt.Fprint(os.Stderr, "Enter password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
+// injectPassword adds password to a DSN that names a user but has no password of
+// its own. The driver takes everything between the first ':' and the last '@' as
+// the password, so it is inserted as is.
+func injectPassword(dsn, password string) string {
+	if password == "" {
+		return dsn
+	}
+	userEnd := len(dsn)
+	for _, protocol := range []string{"tcp(", "unix("} {
+		if i := strings.Index(dsn, protocol); i >= 0 {
+			userEnd = i
+			break
+		}
+	}
+	at := strings.LastIndex(dsn[:userEnd], "@")
+	if at <= 0 || strings.Contains(dsn[:at], ":") {
+		return dsn // No user to log in as, or a password was given
+	}
+	return dsn[:at] + ":" + password + dsn[at:]
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInjectPassword(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{dsn: "app@tcp(db1:3306)/shop", want: "app:p@ss:w/rd@tcp(db1:3306)/shop"},
+		{dsn: "app@unix(/var/run/mysqld.sock)/shop", want: "app:p@ss:w/rd@unix(/var/run/mysqld.sock)/shop"},
+		{dsn: "app@db1/shop", want: "app:p@ss:w/rd@db1/shop"},
+		{dsn: "app:own@tcp(db1:3306)/shop", want: "app:own@tcp(db1:3306)/shop"},
+		{dsn: "app:@tcp(db1:3306)/shop", want: "app:@tcp(db1:3306)/shop"},
+		{dsn: "tcp(db1:3306)/shop", want: "tcp(db1:3306)/shop"},
+		{dsn: "@tcp(db1:3306)/shop", want: "@tcp(db1:3306)/shop"},
+	}
+	for _, tt := range tests {
+		if got := injectPassword(tt.dsn, "p@ss:w/rd"); got != tt.want {
+			t.Errorf("injectPassword(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+	if got := injectPassword("app@tcp(db1:3306)/shop", ""); got != "app@tcp(db1:3306)/shop" {
+		t.Errorf("empty password: injectPassword() = %q, want the DSN unchanged", got)
+	}
+}
+
+// stubPassword makes readPassword return password and err for the test's duration
+func stubPassword(t *testing.T, password string, err error) *int {
+	t.Helper()
+	calls := 0
+	original := readPassword
+	readPassword = func() (string, error) {
+		calls++
+		return password, err
+	}
+	t.Cleanup(func() { readPassword = original })
+	return &calls
+}
+
+func TestLoadInstances_PasswordPrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // No ~/.my.cnf
+
+	calls := stubPassword(t, "s3cret", nil)
+	config := Config{Instances: "app@tcp(db1:3306)/shop,ops:own@tcp(db2:3306)/shop", PasswordPrompt: true}
+	got, err := config.LoadInstances()
+	if err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+	want := []string{"app:s3cret@tcp(db1:3306)/shop", "ops:own@tcp(db2:3306)/shop"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadInstances() = %q, want %q", got, want)
+	}
+	if *calls != 1 {
+		t.Errorf("prompted %d time(s), want once for all instances", *calls)
+	}
+
+	// Without the flag nobody is prompted
+	calls = stubPassword(t, "s3cret", nil)
+	config = Config{Instances: "app@tcp(db1:3306)/shop"}
+	if got, err := config.LoadInstances(); err != nil || got[0] != "app@tcp(db1:3306)/shop" || *calls != 0 {
+		t.Errorf("without --password-prompt: %q, %v after %d prompt(s)", got, err, *calls)
+	}
+
+	noTTY := errors.New("--password-prompt needs a terminal to read the password from, and stdin is not one")
+	stubPassword(t, "", noTTY)
+	config = Config{Instances: "app@tcp(db1:3306)/shop", PasswordPrompt: true}
+	if _, err := config.LoadInstances(); !errors.Is(err, noTTY) {
+		t.Errorf("LoadInstances() error = %v, want %v", err, noTTY)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/sqllog"
+)
+
+// replayDigestWidth is how much of a statement fingerprint the replay report shows
+const replayDigestWidth = 60
+
+// replayItem is a slow log statement scheduled for replay
+type replayItem struct {
+	index  int // Position in dispatch order
+	event  sqllog.Event
+	offset time.Duration // When to dispatch it, from the start of the replay
+}
+
+// replayClock is the time source of a replay; tests substitute a fake clock so the
+// scheduler runs without real sleeps
+type replayClock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the wall clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// replaySpeed returns the --replay-speed factor
+func (c *Config) replaySpeed() float64 {
+	if c.ReplaySpeed > 0 {
+		return c.ReplaySpeed
+	}
+	return 1
+}
+
+// planReplay schedules slow log statements at the gaps between their start times
+// (the logged finish time minus Query_time), divided by speed, in start order. A
+// statement without a time keeps the offset of the one before it. Setup events
+// are left out, as each lane switches databases itself.
+func planReplay(events []sqllog.Event, speed float64) []replayItem {
+	var first time.Time
+	for _, e := range events {
+		if start := e.Time.Add(-e.QueryTime); !e.Setup && !e.Time.IsZero() && (first.IsZero() || start.Before(first)) {
+			first = start
+		}
+	}
+
+	var items []replayItem
+	var offset time.Duration
+	for _, e := range events {
+		if e.Setup {
+			continue
+		}
+		if !e.Time.IsZero() {
+			offset = time.Duration(float64(e.Time.Add(-e.QueryTime).Sub(first)) / speed)
+		}
+		items = append(items, replayItem{event: e, offset: offset})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].offset < items[j].offset })
+	for i := range items {
+		items[i].index = i
+	}
+	return items
+}
+
+// dispatchReplay calls dispatch with each item, in order, once its offset has
+// passed on clock, along with the time it was due. It stops when ctx is cancelled
+// and returns the cause.
+func dispatchReplay(ctx context.Context, clock replayClock, items []replayItem, dispatch func(item replayItem, due time.Time)) error {
+	start := clock.Now()
+	for _, item := range items {
+		due := start.Add(item.offset)
+		if wait := due.Sub(clock.Now()); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case <-clock.After(wait):
+			}
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		dispatch(item, due)
+	}
+	return nil
+}
+
+// replayDispatch is an item handed to a lane, with the time it was due
+type replayDispatch struct {
+	item replayItem
+	due  time.Time
+}
+
+// replayLaneKey identifies a lane: one connection of the log on one instance
+type replayLaneKey struct {
+	instance   string
+	connection uint64
+}
+
+// instanceReplay collects an instance's replay results and dispatch lags, indexed
+// by item. Each item is written by exactly one lane.
+type instanceReplay struct {
+	results []db.QueryResult
+	lags    []time.Duration // How late each statement started
+}
+
+// runReplay replays the slow log statements on every instance at their logged
+// pace. Each connection of the log becomes a lane per instance that runs its
+// statements in order over a session of its own, so statements of different
+// connections overlap as they did on the logging server. Rows are not printed;
+// the latencies are reported against the logged Query_time instead.
+func (c *Config) runReplay(ctx context.Context, instanceList []string, opts db.ExecOptions, clock replayClock) map[string][]db.QueryResult {
+	items := planReplay(c.replayEvents, c.replaySpeed())
+	perConnection := make(map[uint64]int)
+	for _, item := range items {
+		perConnection[item.event.Connection]++
+	}
+
+	replays := make(map[string]*instanceReplay, len(instanceList))
+	for _, instanceDSN := range instanceList {
+		replays[instanceDSN] = &instanceReplay{results: make([]db.QueryResult, len(items)), lags: make([]time.Duration, len(items))}
+	}
+
+	var wg sync.WaitGroup
+	lanes := make(map[replayLaneKey]chan replayDispatch)
+	err := dispatchReplay(ctx, clock, items, func(item replayItem, due time.Time) {
+		for _, instanceDSN := range instanceList {
+			key := replayLaneKey{instanceDSN, item.event.Connection}
+			lane, ok := lanes[key]
+			if !ok {
+				// Sized to never block the scheduler on a lane that is running behind
+				lane = make(chan replayDispatch, perConnection[item.event.Connection])
+				lanes[key] = lane
+				wg.Add(1)
+				go func(instanceDSN string) {
+					defer wg.Done()
+					c.replayLane(ctx, instanceDSN, lane, opts, clock, replays[instanceDSN])
+				}(instanceDSN)
+			}
+			lane <- replayDispatch{item: item, due: due}
+		}
+	})
+	for _, lane := range lanes {
+		close(lane)
+	}
+	wg.Wait()
+
+	allResults := make(map[string][]db.QueryResult, len(instanceList))
+	for _, instanceDSN := range instanceList {
+		results := replays[instanceDSN].results
+		for i, item := range items {
+			if results[i].Instance == "" { // Never dispatched
+				results[i] = db.QueryResult{Instance: instanceDSN, Statement: item.event.SQL, Skipped: true, Err: err}
+			}
+		}
+		allResults[instanceDSN] = results
+	}
+
+	for _, instanceDSN := range instanceList {
+		for _, res := range allResults[instanceDSN] {
+			if res.Err != nil && !res.Skipped {
+				c.sink().Printf(db.StreamDiagnostics, "Error: %s: %s: %v\n", db.MaskDSN(instanceDSN), res.Statement, res.Err)
+				break // One error per instance is enough to diagnose it
+			}
+		}
+	}
+	_ = c.sink().Block(db.StreamResults, func(w io.Writer) {
+		writeReplayReport(w, items, instanceList, replays, c.replaySpeed())
+	})
+	return allResults
+}
+
+// replayLane runs the statements of one logged connection on an instance as they
+// are dispatched, over a session opened on the first of them
+func (c *Config) replayLane(ctx context.Context, instanceDSN string, lane <-chan replayDispatch, opts db.ExecOptions, clock replayClock, out *instanceReplay) {
+	var sess *db.Session
+	var connectErr error
+	database := ""
+	for d := range lane {
+		if sess == nil && connectErr == nil {
+			if sess, connectErr = db.Connect(ctx, instanceDSN, opts); connectErr == nil {
+				defer sess.Close()
+			}
+		}
+		i := d.item.index
+		out.lags[i] = max(0, clock.Now().Sub(d.due))
+		if connectErr != nil {
+			out.results[i] = db.QueryResult{Instance: instanceDSN, Statement: d.item.event.SQL, Err: connectErr, ConnectFailed: true}
+			continue
+		}
+
+		sqls := d.item.event.SQL
+		switchDB := d.item.event.Database != "" && d.item.event.Database != database
+		if switchDB {
+			sqls = "USE " + db.QuoteIdentifier(d.item.event.Database) + ";\n" + sqls
+		}
+		results := db.RunSQLOnSession(ctx, sess, sqls, opts)
+		switch {
+		case len(results) == 0:
+			out.results[i] = db.QueryResult{Instance: instanceDSN, Statement: d.item.event.SQL}
+		case switchDB && results[0].Err != nil:
+			out.results[i] = results[0] // The statement ran in the wrong database, if at all
+		default:
+			if switchDB {
+				database = d.item.event.Database
+			}
+			out.results[i] = results[len(results)-1]
+		}
+	}
+}
+
+// replayDigest collects the logged and replayed latencies of one statement fingerprint
+type replayDigest struct {
+	fingerprint string
+	logged      []time.Duration
+	replayed    []time.Duration
+	errors      int
+}
+
+// writeReplayReport compares the replayed latencies of each statement fingerprint,
+// across all instances, with the Query_time the slow log recorded for it, and
+// reports how far the lanes fell behind the schedule
+func writeReplayReport(w io.Writer, items []replayItem, instanceList []string, replays map[string]*instanceReplay, speed float64) {
+	var digests []*replayDigest
+	byFingerprint := make(map[string]*replayDigest)
+	connections := make(map[uint64]bool)
+	var lags []time.Duration
+	for _, item := range items {
+		connections[item.event.Connection] = true
+		fingerprint := sqllog.Fingerprint(item.event.SQL)
+		d := byFingerprint[fingerprint]
+		if d == nil {
+			d = &replayDigest{fingerprint: fingerprint}
+			byFingerprint[fingerprint] = d
+			digests = append(digests, d)
+		}
+		d.logged = append(d.logged, item.event.QueryTime)
+		for _, instanceDSN := range instanceList {
+			res := replays[instanceDSN].results[item.index]
+			switch {
+			case res.Skipped:
+			case res.Err != nil:
+				d.errors++
+			default:
+				d.replayed = append(d.replayed, res.Duration)
+				lags = append(lags, replays[instanceDSN].lags[item.index])
+			}
+		}
+	}
+
+	var span time.Duration
+	if len(items) > 0 {
+		span = items[len(items)-1].offset
+	}
+	fmt.Fprintf(w, "Replay: %d statement(s) from %d connection(s) over %v at %gx speed on %d instance(s)\n",
+		len(items), len(connections), span.Round(time.Millisecond), speed, len(instanceList))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "digest\tcount\tlogged p50\tlogged p95\treplayed p50\treplayed p95\terrors")
+	var allLogged, allReplayed []time.Duration
+	allErrors := 0
+	for _, d := range digests {
+		writeReplayRow(tw, truncateText(d.fingerprint, replayDigestWidth), d.logged, d.replayed, d.errors)
+		allLogged = append(allLogged, d.logged...)
+		allReplayed = append(allReplayed, d.replayed...)
+		allErrors += d.errors
+	}
+	writeReplayRow(tw, "all", allLogged, allReplayed, allErrors)
+	tw.Flush()
+
+	lag := computeLatencyStats(lags)
+	fmt.Fprintf(w, "Dispatch lag: p95 %v, max %v\n", lag.P95.Round(time.Microsecond), lag.Max.Round(time.Microsecond))
+}
+
+// writeReplayRow writes one row of the replay report
+func writeReplayRow(w io.Writer, label string, logged, replayed []time.Duration, errors int) {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	l, r := computeLatencyStats(logged), computeLatencyStats(replayed)
+	fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\t%d\n", label, l.Count, round(l.P50), round(l.P95), round(r.P50), round(r.P95), errors)
+}
+
+// truncateText shortens s to width runes for a report column, marking the cut
+func truncateText(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/ChaosHour/go-csql/pkg/sqllog"
+)
+
+// fakeClock is a replayClock whose waits return at once, moving its time forward
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+	wait  func() // Called on every wait, e.g. to cancel the replay
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wait != nil {
+		c.wait()
+	}
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+	fired := make(chan time.Time, 1)
+	fired <- c.now
+	return fired
+}
+
+func TestPlanReplay(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	events := []sqllog.Event{
+		{SQL: "USE `shop`", Setup: true, Time: base.Add(time.Second)},
+		{SQL: "slow", Time: base.Add(3 * time.Second), QueryTime: 2 * time.Second}, // Started at +1s
+		{SQL: "fast", Time: base.Add(2 * time.Second)},                             // Started later, logged earlier
+		{SQL: "untimed"},
+		{SQL: "last", Time: base.Add(5 * time.Second), QueryTime: 500 * time.Millisecond},
+	}
+
+	tests := []struct {
+		speed float64
+		want  []string // sql@offset
+	}{
+		{speed: 1, want: []string{"slow@0s", "fast@1s", "untimed@1s", "last@3.5s"}},
+		{speed: 2, want: []string{"slow@0s", "fast@500ms", "untimed@500ms", "last@1.75s"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.speed), func(t *testing.T) {
+			var got []string
+			for i, item := range planReplay(events, tt.speed) {
+				if item.index != i {
+					t.Errorf("item %d has index %d", i, item.index)
+				}
+				got = append(got, fmt.Sprintf("%s@%v", item.event.SQL, item.offset))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planReplay() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchReplay(t *testing.T) {
+	items := []replayItem{{offset: 0}, {offset: 250 * time.Millisecond}, {offset: 250 * time.Millisecond}, {offset: 2 * time.Second}}
+	for i := range items {
+		items[i].index = i
+	}
+
+	t.Run("waits for each offset", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		var due []time.Duration
+		err := dispatchReplay(context.Background(), clock, items, func(item replayItem, at time.Time) {
+			if now := clock.Now(); now.Before(at) {
+				t.Errorf("item %d dispatched at %v, before it was due at %v", item.index, now, at)
+			}
+			due = append(due, at.Sub(time.Unix(1000, 0)))
+		})
+		if err != nil {
+			t.Fatalf("dispatchReplay() error = %v", err)
+		}
+		if want := []time.Duration{0, 250 * time.Millisecond, 250 * time.Millisecond, 2 * time.Second}; !reflect.DeepEqual(due, want) {
+			t.Errorf("due = %v, want %v", due, want)
+		}
+		if want := []time.Duration{250 * time.Millisecond, 1750 * time.Millisecond}; !reflect.DeepEqual(clock.waits, want) {
+			t.Errorf("waits = %v, want %v", clock.waits, want)
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		interrupted := errors.New("interrupted")
+		clock := &fakeClock{wait: func() { cancel(interrupted) }}
+		dispatched := 0
+		err := dispatchReplay(ctx, clock, items, func(replayItem, time.Time) { dispatched++ })
+		if !errors.Is(err, interrupted) || dispatched != 1 {
+			t.Errorf("dispatchReplay() = %v after %d dispatched, want the cause after 1", err, dispatched)
+		}
+	})
+}
+
+func TestRunReplay(t *testing.T) {
+	useFakeDriver(t)
+	healthy := dbtest.NewServer(t, "replay-1")
+	failing := dbtest.NewServer(t, "replay-2")
+	failing.Handle("UPDATE orders\nSET status = 'late; check'\nWHERE id IN (1, 2, 3)", dbtest.Response{Err: errors.New("lock wait timeout")})
+	instances := []string{healthy.DSN(), failing.DSN()}
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{
+		File:         filepath.Join("..", "..", "pkg", "sqllog", "testdata", "slow.log"),
+		InputFormat:  inputSlowLog,
+		ReplayTiming: true,
+		ReplaySpeed:  2,
+		output:       db.NewOutputSink(&stdout, &stderr),
+	}
+	if _, err := config.LoadStatements(); err != nil {
+		t.Fatalf("LoadStatements() error = %v", err)
+	}
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	results := config.runReplay(context.Background(), instances, db.ExecOptions{Output: config.sink()}, clock)
+
+	// Started at +0s, +0.5s and +4.5s in the log
+	if want := []time.Duration{250 * time.Millisecond, 2 * time.Second}; !reflect.DeepEqual(clock.waits, want) {
+		t.Errorf("waits = %v, want %v", clock.waits, want)
+	}
+	for _, instanceDSN := range instances {
+		if len(results[instanceDSN]) != 3 {
+			t.Fatalf("%s: got %d results, want 3", instanceDSN, len(results[instanceDSN]))
+		}
+	}
+	if err := results[failing.DSN()][1].Err; err == nil || !strings.Contains(err.Error(), "lock wait timeout") {
+		t.Errorf("UPDATE on the failing instance error = %v", err)
+	}
+
+	// Every connection of the log gets a session that starts in its database. The
+	// lanes run concurrently, so only what ran is compared, not the order.
+	var executed []string
+	for _, query := range healthy.Executed() {
+		if !strings.Contains(query, "information_schema") {
+			executed = append(executed, strings.ReplaceAll(query, "\n", " "))
+		}
+	}
+	want := []string{
+		"USE `shop`",
+		"SELECT COUNT(*) FROM orders WHERE status = 'open'",
+		"USE `shop`",
+		"UPDATE orders SET status = 'late; check' WHERE id IN (1, 2, 3)",
+		"USE `audit`",
+		"SELECT * FROM log LIMIT 10",
+	}
+	slices.Sort(executed)
+	slices.Sort(want)
+	if !reflect.DeepEqual(executed, want) {
+		t.Errorf("executed =\n%q\nwant\n%q", executed, want)
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"Replay: 3 statement(s) from 3 connection(s) over 2.25s at 2x speed on 2 instance(s)\n",
+		"\nDispatch lag: ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report is missing %q:\n%s", want, out)
+		}
+	}
+	// Digest rows: count, logged p50 and p95, and errors across both instances
+	wantRows := map[string]string{
+		"select count(*) from orders where status = ?":  "1 250ms 250ms 0",
+		"update orders set status = ? where id in (?+)": "1 1s 1s 1",
+		"select * from log limit ?":                     "1 500ms 500ms 0",
+		"all":                                           "3 500ms 1s 1",
+	}
+	for digest, want := range wantRows {
+		found := false
+		for _, line := range strings.Split(out, "\n") {
+			if rest, ok := strings.CutPrefix(line, digest+"  "); ok {
+				fields := strings.Fields(rest)
+				if got := strings.Join([]string{fields[0], fields[1], fields[2], fields[5]}, " "); got != want {
+					t.Errorf("row %q = %q, want %q", digest, got, want)
+				}
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("report has no row for %q:\n%s", digest, out)
+		}
+	}
+	if !strings.Contains(stderr.String(), "lock wait timeout") {
+		t.Errorf("stderr does not report the failed statement:\n%s", stderr.String())
+	}
+}
+
+func TestConfig_Validate_Replay(t *testing.T) {
+	base := Config{Instances: "user:pass@tcp(host:3306)/db", File: "slow.log"}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "slow log replay", modify: func(c *Config) { c.InputFormat, c.ReplayTiming, c.ReplaySpeed = inputSlowLog, true, 4 }},
+		{name: "replay needs a slow log", modify: func(c *Config) { c.ReplayTiming = true }, wantErr: "requires --input-format slow-log"},
+		{name: "negative speed", modify: func(c *Config) { c.InputFormat, c.ReplayTiming, c.ReplaySpeed = inputSlowLog, true, -1 }, wantErr: "--replay-speed"},
+		{name: "not with benchmark", modify: func(c *Config) { c.InputFormat, c.ReplayTiming, c.Benchmark = inputSlowLog, true, true }, wantErr: "--benchmark"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			err := config.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Runbook declares both the instances and the statements of a run in one YAML
+// file, for reproducible runs:
+//
+//	instances:
+//	  - user:pass@tcp(db1:3306)/app
+//	  - {host: db2, port: "3306", user: app, password: secret, database: app}
+//	sql: |
+//	  SELECT COUNT(*) FROM orders;
+type Runbook struct {
+	Instances []runbookInstance `yaml:"instances"`
+	SQL       string            `yaml:"sql"`
+}
+
+// runbookInstance is an instance written as a DSN string or as a mapping with the
+// same fields as a --json server
+type runbookInstance struct {
+	Server
+}
+
+// UnmarshalYAML accepts a plain DSN or a server mapping
+func (i *runbookInstance) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&i.DSN)
+	}
+	var server struct {
+		DSN      string `yaml:"dsn"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Host     string `yaml:"host"`
+		Port     string `yaml:"port"`
+		Database string `yaml:"database"`
+	}
+	if err := node.Decode(&server); err != nil {
+		return err
+	}
+	i.Server = Server{DSN: server.DSN, User: server.User, Password: server.Password,
+		Host: server.Host, Port: server.Port, Database: server.Database}
+	return nil
+}
+
+// loadRunbook reads and checks a runbook file
+func loadRunbook(path string) (*Runbook, error) {
+	expandedPath, err := expandPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand runbook path: %w", err)
+	}
+	content, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runbook: %w", err)
+	}
+
+	var rb Runbook
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true) // Catch misspelled keys instead of silently running nothing
+	if err := decoder.Decode(&rb); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse runbook %s: %w", path, err)
+	}
+	if len(rb.Instances) == 0 {
+		return nil, fmt.Errorf("runbook %s declares no instances", path)
+	}
+	if strings.TrimSpace(rb.SQL) == "" {
+		return nil, fmt.Errorf("runbook %s declares no sql", path)
+	}
+	return &rb, nil
+}
+
+// applyRunbook takes the instances and statements from --runbook. The runbook
+// replaces the separate instance and statement flags, so combining them is an error.
+func (c *Config) applyRunbook() error {
+	if c.Runbook == "" {
+		return nil
+	}
+	if c.Instances != "" || c.JSONFile != "" || c.Stdin || c.SQLFile != "" || c.File != "" || c.Statements != "" {
+		return fmt.Errorf("--runbook declares the instances and statements; it cannot be combined with --instances, --json, --stdin, --sqlfile, --file or --statements")
+	}
+	rb, err := loadRunbook(c.Runbook)
+	if err != nil {
+		return err
+	}
+	c.runbookInstances = make([]string, 0, len(rb.Instances))
+	for _, instance := range rb.Instances {
+		c.runbookInstances = append(c.runbookInstances, instance.BuildDSN())
+	}
+	c.Statements = rb.SQL
+	return nil
+}
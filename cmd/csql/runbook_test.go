@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeRunbook writes a runbook file to a temp dir and returns its path
+func writeRunbook(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "runbook.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfig_ApplyRunbook(t *testing.T) {
+	path := writeRunbook(t, `# Nightly order checks
+instances:
+  - user:pass@tcp(db1:3306)/shop
+  - host: db2
+    port: "3307"
+    user: app
+    password: "p@ss:word"
+    database: shop
+sql: |
+  SELECT COUNT(*) FROM orders;
+  SELECT MAX(id) FROM orders;
+`)
+
+	config := Config{Runbook: path}
+	if err := config.applyRunbook(); err != nil {
+		t.Fatalf("applyRunbook() error = %v", err)
+	}
+	wantInstances := []string{"user:pass@tcp(db1:3306)/shop", "app:p%40ss%3Aword@tcp(db2:3307)/shop"}
+	if !reflect.DeepEqual(config.runbookInstances, wantInstances) {
+		t.Errorf("instances = %q, want %q", config.runbookInstances, wantInstances)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	sqls, err := config.LoadStatements()
+	if err != nil {
+		t.Fatalf("LoadStatements() error = %v", err)
+	}
+	if want := "SELECT COUNT(*) FROM orders;\nSELECT MAX(id) FROM orders;\n"; sqls != want {
+		t.Errorf("LoadStatements() = %q, want %q", sqls, want)
+	}
+	instances, err := config.LoadInstances()
+	if err != nil {
+		t.Fatalf("LoadInstances() error = %v", err)
+	}
+	if len(instances) != 2 || !strings.Contains(instances[1], "tcp(db2:3307)") {
+		t.Errorf("LoadInstances() = %q, want both runbook instances", instances)
+	}
+}
+
+func TestConfig_ApplyRunbook_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		config  Config
+		wantErr string
+	}{
+		{
+			name:    "no instances",
+			content: "sql: SELECT 1\n",
+			wantErr: "declares no instances",
+		},
+		{
+			name:    "no sql",
+			content: "instances: [\"user:pass@tcp(db1:3306)/shop\"]\n",
+			wantErr: "declares no sql",
+		},
+		{
+			name:    "misspelled key",
+			content: "instances: [\"user:pass@tcp(db1:3306)/shop\"]\nstatements: SELECT 1\n",
+			wantErr: "field statements not found",
+		},
+		{
+			name:    "empty file",
+			content: "",
+			wantErr: "declares no instances",
+		},
+		{
+			name:    "combined with --statements",
+			content: "instances: [\"user:pass@tcp(db1:3306)/shop\"]\nsql: SELECT 1\n",
+			config:  Config{Statements: "SELECT 2"},
+			wantErr: "cannot be combined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Runbook = writeRunbook(t, tt.content)
+			err := config.applyRunbook()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("applyRunbook() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
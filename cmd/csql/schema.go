@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// printSchema prints the columns of a result as a JSON line for --show-columns-types
+func printSchema(config *Config, instanceDSN string, res db.QueryResult) {
+	data, err := db.MarshalJSON(db.ResultSchema(res), config.JSONPretty)
+	if err != nil {
+		config.sink().Printf(db.StreamDiagnostics, "Error: %s: %v\n", res.Statement, err)
+		return
+	}
+	_ = config.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+		fmt.Fprintf(w, "%s\n", data)
+	})
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestExecuteQueries_ShowColumnsTypes(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for _, name := range []string{"columns-1", "columns-2"} {
+		srv := dbtest.NewServer(t, name)
+		srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Types: []string{"INT"}, Rows: dbtest.IntRows(3)})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout bytes.Buffer
+	config := &Config{ShowColumnsTypes: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, instances, "SELECT id FROM t"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d line(s), want one JSON line per instance:\n%s", len(lines), stdout.String())
+	}
+	for i, line := range lines {
+		var schema db.StatementSchema
+		if err := json.Unmarshal([]byte(line), &schema); err != nil {
+			t.Fatalf("line %d is not JSON: %v\n%s", i+1, err, line)
+		}
+		if !strings.Contains(schema.Instance, fmt.Sprintf("columns-%d", i+1)) || len(schema.Columns) != 1 || schema.Columns[0].DatabaseType != "INT" {
+			t.Errorf("line %d = %+v, want the INT column of instance %d", i+1, schema, i+1)
+		}
+	}
+}
+
+func TestConfig_Validate_ShowColumnsTypes(t *testing.T) {
+	config := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1", ShowColumnsTypes: true, Output: outputSQL}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() accepted --show-columns-types with --output sql")
+	}
+}
+
+func TestExecuteQueries_FormatJSON(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "format-json")
+	srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(2)})
+	srv.Handle("SELECT broken", dbtest.Response{Err: errors.New("Error 1054: Unknown column 'broken'")})
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Format: formatJSON, output: db.NewOutputSink(&stdout, &stderr)}
+	executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT id FROM t; SELECT broken")
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d line(s) on stdout, want one JSON object per result:\n%s", len(lines), stdout.String())
+	}
+	var results []db.JSONResult
+	for _, line := range lines {
+		var res db.JSONResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatalf("stdout line is not JSON: %v\n%s", err, line)
+		}
+		results = append(results, res)
+	}
+	if results[0].RowCount != 2 || results[0].Error != nil || len(results[0].Rows) != 2 {
+		t.Errorf("first result = %+v, want 2 rows and no error", results[0])
+	}
+	if results[1].Error == nil || !strings.Contains(*results[1].Error, "Unknown column") {
+		t.Errorf("second result = %+v, want its error", results[1])
+	}
+	if !strings.Contains(stderr.String(), "Executing statements on 1 instance(s)") {
+		t.Errorf("progress banner not on stderr:\n%s", stderr.String())
+	}
+}
+
+func TestExecuteQueries_JSONPretty(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "json-pretty")
+	srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(2)})
+
+	run := func(pretty bool) (string, []db.JSONResult) {
+		var stdout bytes.Buffer
+		config := &Config{Format: formatJSON, JSONPretty: pretty, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+		executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT id FROM t; SELECT id FROM t")
+		var results []db.JSONResult
+		dec := json.NewDecoder(strings.NewReader(stdout.String()))
+		for dec.More() {
+			var res db.JSONResult
+			if err := dec.Decode(&res); err != nil {
+				t.Fatalf("stdout is not a stream of JSON objects: %v\n%s", err, stdout.String())
+			}
+			res.DurationMS = 0 // Differs between the runs
+			results = append(results, res)
+		}
+		return stdout.String(), results
+	}
+
+	compactOut, compact := run(false)
+	prettyOut, pretty := run(true)
+	if got := strings.Count(compactOut, "\n"); got != 2 {
+		t.Errorf("compact output has %d line(s), want one per result:\n%s", got, compactOut)
+	}
+	if !strings.Contains(prettyOut, "{\n"+db.JSONIndent+`"instance": "user:****@tcp(json-pretty:3306)/app",`) {
+		t.Errorf("pretty output is not indented:\n%s", prettyOut)
+	}
+	if len(compact) != 2 || !reflect.DeepEqual(pretty, compact) {
+		t.Errorf("pretty results = %+v, want the compact ones %+v", pretty, compact)
+	}
+}
+
+func TestExecuteQueries_FormatMarkdown(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "format-markdown")
+	srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(2)})
+	srv.Handle("SELECT id FROM empty", dbtest.Response{Columns: []string{"id"}})
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Format: formatMarkdown, output: db.NewOutputSink(&stdout, &stderr)}
+	executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT id FROM t; SELECT id FROM empty")
+
+	out := stdout.String()
+	for _, want := range []string{
+		"#### user:****@tcp(format-markdown:3306)/app\n\n```sql\nSELECT id FROM t\n```\n\n| id |\n| :--- |\n| 1 |\n| 2 |\n",
+		"```sql\nSELECT id FROM empty\n```\n\n_Empty set._\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stdout lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Executing statements") {
+		t.Errorf("progress banner on stdout, where it would be pasted with the tables:\n%s", out)
+	}
+}
+
+func TestExecuteQueries_FormatXML(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for _, host := range []string{"format-xml-1", "format-xml-2"} {
+		srv := dbtest.NewServer(t, host)
+		srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(2)})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Format: formatXML, Concurrent: true, output: db.NewOutputSink(&stdout, &stderr)}
+	if err := executeQueries(context.Background(), config, instances, "SELECT id FROM t"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+
+	// Both instances are elements of one document
+	var doc struct {
+		XMLName   xml.Name `xml:"csql"`
+		Instances []struct {
+			DSN        string `xml:"dsn,attr"`
+			Resultsets []struct {
+				Rows []struct {
+					Fields []string `xml:"field"`
+				} `xml:"row"`
+			} `xml:"resultset"`
+		} `xml:"instance"`
+	}
+	if err := xml.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("stdout is not an XML document: %v\n%s", err, stdout.String())
+	}
+	if len(doc.Instances) != 2 || doc.Instances[0].DSN != "user:****@tcp(format-xml-1:3306)/app" {
+		t.Fatalf("instances = %+v, want both in instance order", doc.Instances)
+	}
+	if rows := doc.Instances[1].Resultsets[0].Rows; len(rows) != 2 || rows[1].Fields[0] != "2" {
+		t.Errorf("rows = %+v, want 2 rows", rows)
+	}
+}
+
+func TestConfig_Validate_Format(t *testing.T) {
+	base := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1"}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "json", modify: func(c *Config) { c.Format = formatJSON }},
+		{name: "pretty json", modify: func(c *Config) { c.Format, c.JSONPretty = formatJSON, true }},
+		{name: "pretty column types", modify: func(c *Config) { c.ShowColumnsTypes, c.JSONPretty = true, true }},
+		{name: "unknown format", modify: func(c *Config) { c.Format = "yaml" }, wantErr: true},
+		{name: "pretty without json", modify: func(c *Config) { c.JSONPretty = true }, wantErr: true},
+		{name: "json with status line", modify: func(c *Config) { c.Format, c.StatusLine = formatJSON, true }, wantErr: true},
+		{name: "csv", modify: func(c *Config) { c.Format = formatCSV }},
+		{name: "ndjson", modify: func(c *Config) { c.Format = formatNDJSON }},
+		{name: "markdown", modify: func(c *Config) { c.Format = formatMarkdown }},
+		{name: "xml", modify: func(c *Config) { c.Format = formatXML }},
+		{name: "xml with output dir", modify: func(c *Config) { c.Format, c.OutputDir = formatXML, "out" }, wantErr: true},
+		{name: "markdown with sql output", modify: func(c *Config) { c.Format, c.Output, c.OutputSQLTable = formatMarkdown, outputSQL, "t" }, wantErr: true},
+		{name: "pretty ndjson", modify: func(c *Config) { c.Format, c.JSONPretty = formatNDJSON, true }, wantErr: true},
+		{name: "csv with instance column", modify: func(c *Config) { c.Format, c.CSVInstanceColumn = formatCSV, true }},
+		{name: "instance column without csv", modify: func(c *Config) { c.CSVInstanceColumn = true }, wantErr: true},
+		{name: "null token without csv", modify: func(c *Config) { c.CSVNull = "NULL" }, wantErr: true},
+		{name: "csv with output sql", modify: func(c *Config) { c.Format, c.Output, c.OutputSQLTable = formatCSV, outputSQL, "t" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_FormatCSV(t *testing.T) {
+	useFakeDriver(t)
+	first, second := dbtest.NewServer(t, "csv-a"), dbtest.NewServer(t, "csv-b")
+	for _, srv := range []*dbtest.Server{first, second} {
+		srv.Handle("SELECT id, note FROM t", dbtest.Response{
+			Columns: []string{"id", "note"},
+			Rows:    [][]driver.Value{{int64(1), "a, \"quoted\" note"}, {int64(2), nil}},
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Format: formatCSV, CSVInstanceColumn: true, CSVNull: `\N`, output: db.NewOutputSink(&stdout, &stderr)}
+	executeQueries(context.Background(), config, []string{first.DSN(), second.DSN()}, "SELECT id, note FROM t")
+
+	records, err := csv.NewReader(&stdout).ReadAll()
+	if err != nil {
+		t.Fatalf("stdout is not CSV: %v\n%s", err, stdout.String())
+	}
+	// Both instances returned the same columns, so their rows share one header
+	want := [][]string{
+		{"instance", "id", "note"},
+		{db.MaskDSN(first.DSN()), "1", `a, "quoted" note`},
+		{db.MaskDSN(first.DSN()), "2", `\N`},
+		{db.MaskDSN(second.DSN()), "1", `a, "quoted" note`},
+		{db.MaskDSN(second.DSN()), "2", `\N`},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+	if !strings.Contains(stderr.String(), "Executing statements on 2 instance(s)") {
+		t.Errorf("progress banner not on stderr:\n%s", stderr.String())
+	}
+}
+
+func TestExecuteQueries_FormatCSVConcurrentHeaders(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for i := 0; i < 8; i++ {
+		srv := dbtest.NewServer(t, fmt.Sprintf("csv-concurrent-%d", i))
+		srv.Handle("SELECT n FROM t", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(2)})
+		srv.Handle("SELECT a, b FROM u", dbtest.Response{Columns: []string{"a", "b"}, Rows: [][]driver.Value{{"x", "y"}}})
+		instances = append(instances, srv.DSN())
+	}
+
+	var stdout bytes.Buffer
+	config := &Config{Format: formatCSV, CSVInstanceColumn: true, Concurrent: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	executeQueries(context.Background(), config, instances, "SELECT n FROM t; SELECT a, b FROM u")
+
+	// However the instances' results interleave, every row follows a header of its shape
+	r := csv.NewReader(&stdout)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("stdout is not CSV: %v\n%s", err, stdout.String())
+	}
+	var header []string
+	rows := 0
+	for _, record := range records {
+		if record[0] == db.CSVInstanceColumn {
+			header = record
+			continue
+		}
+		if len(record) != len(header) {
+			t.Fatalf("row %q under header %q", record, header)
+		}
+		rows++
+	}
+	if want := 8 * 3; rows != want {
+		t.Errorf("got %d rows, want %d", rows, want)
+	}
+}
+
+func TestExecuteQueries_FormatNDJSON(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "ndjson")
+	srv.Handle("SELECT id, name FROM t", dbtest.Response{Columns: []string{"id", "name"}, Rows: [][]driver.Value{{int64(1), "a"}, {int64(2), nil}}})
+	srv.Handle("SELECT broken", dbtest.Response{Err: errors.New("Error 1054: Unknown column 'broken'")})
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Format: formatNDJSON, output: db.NewOutputSink(&stdout, &stderr)}
+	executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT id, name FROM t; SELECT broken")
+
+	masked := db.MaskDSN(srv.DSN())
+	want := []string{
+		`{"instance":"` + masked + `","statement":"SELECT id, name FROM t","row":{"id":1,"name":"a"}}`,
+		`{"instance":"` + masked + `","statement":"SELECT id, name FROM t","row":{"id":2,"name":null}}`,
+		`{"instance":"` + masked + `","statement":"SELECT broken","error":"query error: Error 1054: Unknown column 'broken'"}`,
+	}
+	if got := strings.Split(strings.TrimSpace(stdout.String()), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("stdout =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(stderr.String(), "Executing statements on 1 instance(s)") {
+		t.Errorf("progress banner not on stderr:\n%s", stderr.String())
+	}
+}
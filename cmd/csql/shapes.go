@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// statementResults returns the results of each statement across instances, which
+// run the same statements, so the nth result of every instance is of the same
+// statement. Unreachable instances have no results.
+func statementResults(instanceList []string, allResults map[string][]db.QueryResult) [][]db.QueryResult {
+	var byStatement [][]db.QueryResult
+	for _, instanceDSN := range instanceList {
+		results := allResults[instanceDSN]
+		if len(results) == 1 && results[0].ConnectFailed {
+			continue
+		}
+		for i, res := range results {
+			if i == len(byStatement) {
+				byStatement = append(byStatement, nil)
+			}
+			byStatement[i] = append(byStatement[i], res)
+		}
+	}
+	return byStatement
+}
+
+// coerceColumns, with --coerce-columns, gives the results of each statement that
+// returned different columns on different instances the union of their columns,
+// NULL where an instance lacks one
+func (c *Config) coerceColumns(instanceList []string, allResults map[string][]db.QueryResult) {
+	if !c.CoerceColumns {
+		return
+	}
+	for i, results := range statementResults(instanceList, allResults) {
+		shapes := db.ResultShapes(results)
+		if len(shapes) < 2 {
+			continue
+		}
+		union := db.UnionColumns(shapes)
+		for _, instanceDSN := range instanceList {
+			if i >= len(allResults[instanceDSN]) {
+				continue
+			}
+			res := &allResults[instanceDSN][i]
+			if res.Err == nil && !res.Skipped && len(res.Columns) > 0 {
+				*res = db.CoerceColumns(*res, union)
+			}
+		}
+	}
+}
+
+// printShapeFallbacks prints, instance by instance, the results of the statements
+// --diff could not compare because their columns differ across instances
+func (c *Config) printShapeFallbacks(instanceList []string, allResults map[string][]db.QueryResult, report db.DiffReport) {
+	for i, d := range report.Statements {
+		if d.Shapes == nil {
+			continue
+		}
+		c.sink().Printf(db.StreamDiagnostics, "Error: --diff: %v\nShowing statement %d per instance instead (see --coerce-columns)\n", d.Shapes, i+1)
+		for _, instanceDSN := range instanceList {
+			if results := allResults[instanceDSN]; i < len(results) && !results[i].ConnectFailed {
+				printResult(c, instanceDSN, results[i], nil)
+			}
+		}
+	}
+}
+
+// checkCSVShapes reports the statements whose rows could not share a CSV header
+// under --csv-instance-column because their columns differ across instances; each
+// block of their rows was written under a header of its own. It returns the number
+// of such statements.
+func (c *Config) checkCSVShapes(instanceList []string, allResults map[string][]db.QueryResult) int {
+	if c.Format != formatCSV || !c.CSVInstanceColumn || c.OutputDir != "" {
+		return 0
+	}
+	mismatches := 0
+	for _, results := range statementResults(instanceList, allResults) {
+		if err := db.CheckShapes(results); err != nil {
+			c.sink().Printf(db.StreamDiagnostics, "Error: CSV header: %v\nIts rows were written under a header per column set (see --coerce-columns)\n", err)
+			mismatches++
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+// shapeFleet returns two instances whose SELECT * FROM settings returns the same
+// values, on the second in another column order and with an extra column
+func shapeFleet(t *testing.T, prefix string) []string {
+	t.Helper()
+	old := dbtest.NewServer(t, prefix+"-old")
+	old.Handle("SELECT * FROM settings", dbtest.Response{Columns: []string{"name", "value"}, Rows: [][]driver.Value{{[]byte("mode"), []byte("on")}}})
+	upgraded := dbtest.NewServer(t, prefix+"-new")
+	upgraded.Handle("SELECT * FROM settings", dbtest.Response{
+		Columns: []string{"value", "name", "scope"},
+		Rows:    [][]driver.Value{{[]byte("on"), []byte("mode"), []byte("global")}},
+	})
+	return []string{old.DSN(), upgraded.DSN()}
+}
+
+func TestExecuteQueries_DiffShapeMismatch(t *testing.T) {
+	useFakeDriver(t)
+	instances := shapeFleet(t, "diff-shapes")
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Diff: true, output: db.NewOutputSink(&stdout, &stderr)}
+	err := executeQueries(context.Background(), config, instances, "SELECT * FROM settings")
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryExpectationFailed {
+		t.Fatalf("executeQueries() error = %v, want an expectation-failed exit", err)
+	}
+	for _, want := range []string{
+		"DIFFERS: 2 distinct column set(s); rows not compared\n",
+		"  columns (name, value): user:****@tcp(diff-shapes-old:3306)/app\n",
+		"  columns (value, name, scope): user:****@tcp(diff-shapes-new:3306)/app\n",
+		"global", // Each instance's result, printed as it is
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout lacks %q:\n%s", want, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), "Showing statement 1 per instance instead (see --coerce-columns)") {
+		t.Errorf("stderr does not explain the fallback:\n%s", stderr.String())
+	}
+}
+
+func TestExecuteQueries_CoerceColumns(t *testing.T) {
+	useFakeDriver(t)
+
+	t.Run("diff", func(t *testing.T) {
+		instances := shapeFleet(t, "coerce-diff")
+		var stdout bytes.Buffer
+		config := &Config{Diff: true, DiffIgnoreColumns: "scope", CoerceColumns: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+		if err := executeQueries(context.Background(), config, instances, "SELECT * FROM settings"); err != nil {
+			t.Fatalf("executeQueries() error = %v, want the coerced results to agree", err)
+		}
+		if want := "same on all 2 instance(s)"; !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout lacks %q:\n%s", want, stdout.String())
+		}
+	})
+
+	t.Run("csv with a shared header", func(t *testing.T) {
+		instances := shapeFleet(t, "coerce-csv")
+		var stdout bytes.Buffer
+		config := &Config{Format: formatCSV, CSVInstanceColumn: true, CoerceColumns: true, csvHeaders: &csvHeaders{last: map[string]string{}},
+			output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+		if err := executeQueries(context.Background(), config, instances, "SELECT * FROM settings"); err != nil {
+			t.Fatalf("executeQueries() error = %v", err)
+		}
+		want := "instance,name,value,scope\n" +
+			"user:****@tcp(coerce-csv-old:3306)/app,mode,on,\n" +
+			"user:****@tcp(coerce-csv-new:3306)/app,mode,on,global\n"
+		if stdout.String() != want {
+			t.Errorf("stdout =\n%s\nwant\n%s", stdout.String(), want)
+		}
+	})
+}
+
+func TestExecuteQueries_CSVShapeMismatch(t *testing.T) {
+	useFakeDriver(t)
+	instances := shapeFleet(t, "csv-shapes")
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Format: formatCSV, CSVInstanceColumn: true, csvHeaders: &csvHeaders{last: map[string]string{}},
+		output: db.NewOutputSink(&stdout, &stderr)}
+	err := executeQueries(context.Background(), config, instances, "SELECT * FROM settings")
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryExpectationFailed {
+		t.Fatalf("executeQueries() error = %v, want an expectation-failed exit", err)
+	}
+	for _, want := range []string{"Error: CSV header: SELECT * FROM settings returned 2 different column sets", "(value, name, scope) from user:****@tcp(csv-shapes-new:3306)/app"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr lacks %q:\n%s", want, stderr.String())
+		}
+	}
+	// Each column set is written under its own header
+	if got := strings.Count(stdout.String(), "instance,"); got != 2 {
+		t.Errorf("stdout has %d header(s), want 2:\n%s", got, stdout.String())
+	}
+}
+
+func TestConfig_Validate_CoerceColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "with diff", modify: func(c *Config) { c.Diff = true }},
+		{name: "with canonical columns", modify: func(c *Config) { c.CanonicalColumns = true }, wantErr: true},
+		{name: "with ndjson", modify: func(c *Config) { c.Format = formatNDJSON }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1", CoerceColumns: true}
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// validateSort checks the --sort options and builds the sorter
+func (c *Config) validateSort() error {
+	if !c.Sort {
+		if c.SortLocale != "" {
+			return fmt.Errorf("--sort-locale requires --sort")
+		}
+		return nil
+	}
+	if c.Format == formatNDJSON || c.NullOutput {
+		return fmt.Errorf("--sort cannot be combined with --format ndjson, which streams rows as they are read, or --null-output, which reads none")
+	}
+	sorter, err := db.NewRowSorter(c.SortLocale)
+	if err != nil {
+		return fmt.Errorf("--sort-locale: %w", err)
+	}
+	c.rowSorter = sorter
+	return nil
+}
+
+// sortResults returns an instance's results with their rows sorted, if --sort is set
+func (c *Config) sortResults(results []db.QueryResult) []db.QueryResult {
+	if c.rowSorter == nil {
+		return results
+	}
+	sorted := make([]db.QueryResult, len(results))
+	for i, res := range results {
+		sorted[i] = c.rowSorter.Sort(res)
+	}
+	return sorted
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestExecuteQueries_SortLocale(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "sort-1")
+	srv.Handle("SELECT name FROM fruit", dbtest.Response{
+		Columns: []string{"name"},
+		Rows:    [][]driver.Value{{[]byte("zebra")}, {[]byte("Äpfel")}, {[]byte("apple")}},
+	})
+
+	var stdout bytes.Buffer
+	config := &Config{Instances: "x", Statements: "x", Format: formatCSV, Sort: true, SortLocale: "de"}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	config.output = db.NewOutputSink(&stdout, &bytes.Buffer{})
+	if err := executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT name FROM fruit"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "name\nÄpfel\napple\nzebra\n") {
+		t.Errorf("stdout lacks the collated rows:\n%s", stdout.String())
+	}
+}
+
+func TestConfig_Validate_Sort(t *testing.T) {
+	base := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1"}
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{name: "sort", modify: func(c *Config) { c.Sort = true }},
+		{name: "sort with locale", modify: func(c *Config) { c.Sort, c.SortLocale = true, "sv-SE" }},
+		{name: "locale without sort", modify: func(c *Config) { c.SortLocale = "de" }, wantErr: true},
+		{name: "invalid locale", modify: func(c *Config) { c.Sort, c.SortLocale = true, "not a locale!" }, wantErr: true},
+		{name: "sort with ndjson", modify: func(c *Config) { c.Sort, c.Format = true, formatNDJSON }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// statusValue formats a value of a --status-line field, quoting it if it would
+// otherwise break the line into more fields
+func statusValue(v string) string {
+	if strings.ContainsAny(v, " \t\r\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// statusLine summarizes an instance's results as a single greppable line for
+// --status-line, e.g. "STATUS instance=db1:3306 rows=5 err= dur=12ms". err is the
+// first error of a statement that ran, or else the reason statements were skipped;
+// dur is the time spent connecting, querying and reading rows.
+func statusLine(instanceDSN string, results []db.QueryResult) string {
+	if len(results) > 0 && results[0].Instance != "" {
+		instanceDSN = results[0].Instance // The group member that served, under --failover
+	}
+	rows := 0
+	var executedErr, skippedErr error
+	for _, res := range results {
+		rows += res.RowCount
+		switch {
+		case res.Err == nil:
+		case res.Skipped && skippedErr == nil:
+			skippedErr = res.Err
+		case !res.Skipped && executedErr == nil:
+			executedErr = res.Err
+		}
+	}
+	err := executedErr
+	if err == nil {
+		err = skippedErr
+	}
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+	}
+	timing := db.TimingOf(results, 0)
+	dur := (timing.Handshake + timing.QueryTime + timing.Processing).Round(time.Millisecond)
+	return fmt.Sprintf("STATUS instance=%s rows=%d err=%s dur=%s",
+		statusValue(db.InstanceAddr(instanceDSN)), rows, statusValue(errText), dur)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestStatusLine(t *testing.T) {
+	const dsn = "app:s3cret@tcp(db1:3306)/shop"
+	tests := []struct {
+		name    string
+		results []db.QueryResult
+		want    string
+	}{
+		{
+			name: "success",
+			results: []db.QueryResult{
+				{Instance: dsn, RowCount: 2, Duration: 4 * time.Millisecond, Handshake: 3 * time.Millisecond},
+				{Instance: dsn, RowCount: 3, Duration: 5 * time.Millisecond},
+			},
+			want: "STATUS instance=db1:3306 rows=5 err= dur=12ms",
+		},
+		{
+			name: "error",
+			results: []db.QueryResult{
+				{Instance: dsn, RowCount: 1, Duration: time.Millisecond},
+				{Instance: dsn, Err: errors.New("Error 1146: Table 'shop.t' doesn't exist")},
+				{Instance: dsn, Skipped: true, Err: errors.New("skipped")},
+			},
+			want: `STATUS instance=db1:3306 rows=1 err="Error 1146: Table 'shop.t' doesn't exist" dur=1ms`,
+		},
+		{
+			name:    "nothing ran",
+			results: []db.QueryResult{{Instance: dsn, Skipped: true, Err: context.Canceled}},
+			want:    `STATUS instance=db1:3306 rows=0 err="context canceled" dur=0s`,
+		},
+		{
+			name:    "unparsable DSN is masked",
+			results: []db.QueryResult{{Instance: "app:s3cret@tcp(db1", Err: errors.New("bad")}},
+			want:    "STATUS instance=app:****@tcp(db1 rows=0 err=bad dur=0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusLine(dsn, tt.results); got != tt.want {
+				t.Errorf("statusLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_StatusLine(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "status-line")
+	srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(3)})
+
+	var stdout bytes.Buffer
+	config := &Config{StatusLine: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	if err := executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT id FROM t"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+	var status []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if strings.HasPrefix(line, "STATUS ") {
+			status = append(status, line)
+		}
+	}
+	if len(status) != 1 || !strings.HasPrefix(status[0], "STATUS instance=status-line:3306 rows=3 err= dur=") {
+		t.Errorf("status lines = %q, want one for the instance:\n%s", status, stdout.String())
+	}
+}
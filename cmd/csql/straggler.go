@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"golang.org/x/term"
+)
+
+// Causes an instance is cancelled with when it holds up a concurrent phase. Both
+// count towards the "cancelled" exit category rather than "interrupted".
+var (
+	errStragglerCancelled  = errors.New("cancelled")
+	errCancelledByOperator = fmt.Errorf("%w by operator", errStragglerCancelled)
+	errStragglerTimeout    = fmt.Errorf("%w after --straggler-timeout", errStragglerCancelled)
+)
+
+// stragglerPollInterval is how often a concurrent phase checks for stragglers
+const stragglerPollInterval = 100 * time.Millisecond
+
+// keyCtrlC is the byte Ctrl-C produces while the terminal is in raw mode
+const keyCtrlC = 3
+
+// stragglerWatch tracks the instances still running in a concurrent phase, each
+// under a context of its own, so the ones holding up the run can be cancelled
+// without aborting the others
+type stragglerWatch struct {
+	mu        sync.Mutex
+	order     []string // Instances in run order, for listing
+	running   map[string]context.CancelCauseFunc
+	firstDone time.Time // When the first instance finished; stragglers are timed from it
+}
+
+// newStragglerWatch returns a watch over a phase running on instanceList
+func newStragglerWatch(instanceList []string) *stragglerWatch {
+	return &stragglerWatch{order: instanceList, running: make(map[string]context.CancelCauseFunc)}
+}
+
+// start returns the context an instance runs under
+func (w *stragglerWatch) start(ctx context.Context, instanceDSN string) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[instanceDSN] = cancel
+	return ctx
+}
+
+// done records that an instance finished and releases its context
+func (w *stragglerWatch) done(instanceDSN string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cancel, ok := w.running[instanceDSN]; ok {
+		cancel(nil)
+		delete(w.running, instanceDSN)
+	}
+	if w.firstDone.IsZero() {
+		w.firstDone = time.Now()
+	}
+}
+
+// stragglers returns the instances still running, in run order, and how long the
+// phase has been waiting on them; the wait is zero until some instance finished
+func (w *stragglerWatch) stragglers() ([]string, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.firstDone.IsZero() {
+		return nil, 0
+	}
+	var stragglers []string
+	for _, instanceDSN := range w.order {
+		if _, ok := w.running[instanceDSN]; ok {
+			stragglers = append(stragglers, instanceDSN)
+		}
+	}
+	return stragglers, time.Since(w.firstDone)
+}
+
+// cancel cancels the instances among stragglers that are still running with cause,
+// and returns them
+func (w *stragglerWatch) cancel(stragglers []string, cause error) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var cancelled []string
+	for _, instanceDSN := range stragglers {
+		if cancel, ok := w.running[instanceDSN]; ok {
+			cancel(cause)
+			delete(w.running, instanceDSN)
+			cancelled = append(cancelled, instanceDSN)
+		}
+	}
+	return cancelled
+}
+
+// watchStragglers runs alongside a concurrent phase until stop is closed. Once the
+// phase has waited --straggler-timeout on the instances still running, they are
+// cancelled. After --straggler-after they are listed and, when stdin is a
+// terminal, pressing s cancels them.
+func (c *Config) watchStragglers(w *stragglerWatch, stop <-chan struct{}) {
+	ticker := time.NewTicker(stragglerPollInterval)
+	defer ticker.Stop()
+
+	var keys <-chan byte
+	restore := func() {}
+	closeKeyboard := func() {
+		restore()
+		keys, restore = nil, func() {}
+	}
+	defer closeKeyboard()
+	listed := false
+
+	for {
+		select {
+		case <-stop:
+			return
+		case key := <-keys:
+			cause := errCancelledByOperator
+			switch key {
+			case 's', 'S':
+			case keyCtrlC: // Raw mode swallows the signal; interrupt the stragglers as it would
+				cause = context.Canceled
+			default:
+				continue
+			}
+			closeKeyboard()
+			stragglers, _ := w.stragglers()
+			c.reportCancelled(w.cancel(stragglers, cause), cause)
+		case <-ticker.C:
+			stragglers, waited := w.stragglers()
+			if len(stragglers) == 0 || waited == 0 {
+				continue
+			}
+			if c.StragglerTimeout > 0 && waited >= c.StragglerTimeout {
+				closeKeyboard()
+				c.reportCancelled(w.cancel(stragglers, errStragglerTimeout), errStragglerTimeout)
+				continue
+			}
+			if c.StragglerAfter > 0 && waited >= c.StragglerAfter && !listed {
+				listed = true
+				keys, restore = openKeyboard()
+				c.listStragglers(stragglers, waited, keys != nil)
+			}
+		}
+	}
+}
+
+// listStragglers tells the operator which instances the run is waiting on
+func (c *Config) listStragglers(stragglers []string, waited time.Duration, interactive bool) {
+	eol := "\n"
+	if interactive {
+		eol = "\r\n" // The terminal is in raw mode, which does not return the carriage
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Waiting %v on %d instance(s):%s", waited.Round(time.Millisecond), len(stragglers), eol)
+	for _, instanceDSN := range stragglers {
+		fmt.Fprintf(&b, "  %s%s", db.MaskDSN(instanceDSN), eol)
+	}
+	if interactive {
+		fmt.Fprintf(&b, "Press s to cancel them and let the run finish without them.%s", eol)
+	}
+	_ = c.sink().WriteBlock(db.StreamDiagnostics, []byte(b.String()))
+}
+
+// reportCancelled lists the instances cancelled as stragglers and why
+func (c *Config) reportCancelled(cancelled []string, cause error) {
+	for _, instanceDSN := range cancelled {
+		c.sink().Printf(db.StreamDiagnostics, "Cancelled %s: %v\n", db.MaskDSN(instanceDSN), cause)
+	}
+}
+
+// Keys read from an interactive stdin. One reader serves the whole process, as a
+// read in progress cannot be abandoned; keys pressed while nobody listens are dropped.
+var (
+	keyboardOnce sync.Once
+	keyboardKeys chan byte
+)
+
+// openKeyboard puts an interactive stdin into raw mode, so single keys are read
+// without Enter, and returns the keys pressed and a function restoring the
+// terminal. keys is nil when stdin is not a terminal. Tests substitute a scripted
+// keyboard.
+var openKeyboard = func() (keys <-chan byte, restore func()) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, func() {}
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, func() {}
+	}
+	keyboardOnce.Do(func() {
+		keyboardKeys = make(chan byte)
+		go func() {
+			buf := make([]byte, 1)
+			for {
+				if n, err := os.Stdin.Read(buf); err != nil {
+					return
+				} else if n == 1 {
+					select {
+					case keyboardKeys <- buf[0]:
+					default:
+					}
+				}
+			}
+		}()
+	})
+	return keyboardKeys, func() { _ = term.Restore(fd, state) }
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+// stragglerFleet returns two quick instances and one stuck on a lock for a minute
+func stragglerFleet(t *testing.T, prefix string) (instances []string, stuck *dbtest.Server) {
+	t.Helper()
+	for _, host := range []string{prefix + "-1", prefix + "-2"} {
+		instances = append(instances, dbtest.NewServer(t, host).DSN())
+	}
+	stuck = dbtest.NewServer(t, prefix+"-stuck")
+	stuck.Handle("SELECT CONNECTION_ID()", dbtest.Response{Columns: []string{"CONNECTION_ID()"}, Rows: [][]driver.Value{{int64(42)}}})
+	stuck.Handle("UPDATE t SET n = n + 1", dbtest.Response{Delay: time.Minute})
+	return append(instances, stuck.DSN()), stuck
+}
+
+func TestExecuteQueries_StragglerTimeout(t *testing.T) {
+	useFakeDriver(t)
+	instances, stuck := stragglerFleet(t, "straggler-timeout")
+
+	var stdout, stderr bytes.Buffer
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	config := &Config{Concurrent: true, StragglerTimeout: 50 * time.Millisecond, Report: reportFile, output: db.NewOutputSink(&stdout, &stderr)}
+	start := time.Now()
+	err := executeQueries(context.Background(), config, instances, "UPDATE t SET n = n + 1;\nSELECT 1")
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("run took %v, want the stuck instance cancelled", elapsed)
+	}
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryCancelled || exitErr.code != 7 {
+		t.Fatalf("executeQueries() error = %v, want the cancelled exit code", err)
+	}
+
+	// The server is told to stop the statement too, over a connection of its own
+	if executed := strings.Join(stuck.Executed(), "\n"); !strings.Contains(executed, "KILL QUERY 42") {
+		t.Errorf("stuck instance executed:\n%s\nwant KILL QUERY 42", executed)
+	}
+	for _, query := range stuck.Executed() {
+		if query == "SELECT 1" {
+			t.Errorf("statements after the cancelled one ran on the stuck instance")
+		}
+	}
+	if want := "Cancelled user:****@tcp(straggler-timeout-stuck:3306)/app: cancelled after --straggler-timeout"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr is missing %q:\n%s", want, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "query error: cancelled after --straggler-timeout") {
+		t.Errorf("stdout does not mark the cancelled statement:\n%s", stdout.String())
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Succeeded != 2 || report.Cancelled != 1 || report.Instances[2].Status != "cancelled" {
+		t.Errorf("report = %+v, want two succeeded instances and the cancelled one", report)
+	}
+}
+
+func TestExecuteQueries_StragglerCancelledByOperator(t *testing.T) {
+	useFakeDriver(t)
+	instances, _ := stragglerFleet(t, "straggler-key")
+
+	keys := make(chan byte)
+	restored := make(chan struct{})
+	originalKeyboard := openKeyboard
+	t.Cleanup(func() { openKeyboard = originalKeyboard })
+	openKeyboard = func() (<-chan byte, func()) {
+		go func() { keys <- 'x'; keys <- 's' }() // Other keys are ignored
+		return keys, func() { close(restored) }
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := &Config{Concurrent: true, StragglerAfter: 20 * time.Millisecond, output: db.NewOutputSink(&stdout, &stderr)}
+	err := executeQueries(context.Background(), config, instances, "UPDATE t SET n = n + 1")
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryCancelled {
+		t.Fatalf("executeQueries() error = %v, want a cancelled exit", err)
+	}
+	select {
+	case <-restored:
+	default:
+		t.Error("terminal was not restored")
+	}
+	for _, want := range []string{
+		"on 1 instance(s):",
+		"  user:****@tcp(straggler-key-stuck:3306)/app",
+		"Press s to cancel them",
+		"Cancelled user:****@tcp(straggler-key-stuck:3306)/app: cancelled by operator",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr is missing %q:\n%s", want, stderr.String())
+		}
+	}
+	if strings.Count(stdout.String(), "Statement executed successfully") != 2 {
+		t.Errorf("want the two quick instances to succeed:\n%s", stdout.String())
+	}
+}
+
+func TestSummarizeRun_Cancelled(t *testing.T) {
+	results := map[string][]db.QueryResult{
+		"a": {
+			{Instance: "a", Err: errors.Join(errors.New("query error"), errCancelledByOperator)},
+			{Instance: "a", Skipped: true, Err: errCancelledByOperator},
+		},
+	}
+	summary := summarizeRun([]string{"a"}, results)
+	s := summary.Instances[0]
+	if s.Cancelled != 2 || s.Interrupted != 0 || s.QueryErrors != 0 || s.status() != "cancelled" {
+		t.Errorf("summary = %+v (status %s), want both statements counted as cancelled", s, s.status())
+	}
+}
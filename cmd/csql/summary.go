@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// instanceSummary records how far a run got on a single instance
+type instanceSummary struct {
+	Instance string
+	Executed int // Statements that ran, successfully or not
+	Failed   int
+	Skipped  int
+	Rows     int
+	Bytes    int64
+	Errors   []string // Why statements failed, in execution order
+
+	// Failure breakdown for exit codes
+	ConnectFailed bool // The instance could not be reached
+	QueryErrors   int  // Statements the server rejected or that failed while reading
+	TimedOut      int  // Statements failed or skipped because a deadline passed
+	Interrupted   int  // Statements failed or skipped because the run was cancelled
+	Cancelled     int  // Statements failed or skipped because the instance was cancelled as a straggler
+	Truncated     int  // Statements cut short by the run budget
+	Abandoned     int  // Errors after which --max-errors-per-instance skipped the rest (0 = not abandoned)
+	Stopped       int  // Statements cut short when --max-errors or --fail-fast stopped the run
+
+	db.InstanceTiming // Where the instance's wall time went
+}
+
+// completed reports whether every statement on the instance ran
+func (s instanceSummary) completed() bool {
+	return s.Skipped == 0
+}
+
+// started reports whether any statement ran on the instance
+func (s instanceSummary) started() bool {
+	return s.Executed > 0
+}
+
+// status classifies the instance's outcome for reports
+func (s instanceSummary) status() string {
+	switch {
+	case s.Cancelled > 0:
+		return "cancelled"
+	case s.Failed > 0:
+		return "failed"
+	case !s.started():
+		return "not_started"
+	case !s.completed():
+		return "partial"
+	default:
+		return "succeeded"
+	}
+}
+
+// runSummary aggregates the outcome of a run across all instances, in instance order
+type runSummary struct {
+	Instances []instanceSummary
+
+	ExpectationFailures int // Result checks that did not hold
+}
+
+// summarizeRun builds a runSummary from the per-instance results
+func summarizeRun(instanceList []string, results map[string][]db.QueryResult) runSummary {
+	var summary runSummary
+	for _, instanceDSN := range instanceList {
+		s := instanceSummary{Instance: instanceDSN}
+		var abandoned *db.AbandonedError
+		for _, res := range results[instanceDSN] {
+			switch {
+			case res.Skipped:
+				s.Skipped++
+				switch {
+				case errors.Is(res.Err, errStragglerCancelled):
+					s.Cancelled++
+				case errors.Is(res.Err, context.DeadlineExceeded):
+					s.TimedOut++
+				case errors.Is(res.Err, context.Canceled):
+					s.Interrupted++
+				case errors.As(res.Err, &abandoned):
+					s.Abandoned = abandoned.Errors
+				}
+			case res.Err != nil:
+				s.Executed++
+				s.Failed++
+				switch {
+				case res.ConnectFailed:
+					s.ConnectFailed = true
+				case errors.Is(res.Err, db.ErrBudgetExceeded):
+					s.Truncated++
+				case errors.Is(res.Err, errStragglerCancelled):
+					s.Cancelled++
+				case errors.Is(res.Err, context.DeadlineExceeded):
+					s.TimedOut++
+				case errors.Is(res.Err, context.Canceled):
+					s.Interrupted++
+				case errors.Is(res.Err, errMaxErrors), errors.Is(res.Err, errFailFast):
+					s.Stopped++
+				default:
+					s.QueryErrors++
+				}
+				if location := res.Location(); location != "" {
+					s.Errors = append(s.Errors, fmt.Sprintf("%s: %s: %v", location, res.MarkedStatement(), res.Err))
+				} else if res.Statement != "" {
+					s.Errors = append(s.Errors, fmt.Sprintf("%s: %v", res.MarkedStatement(), res.Err))
+				} else {
+					s.Errors = append(s.Errors, res.Err.Error())
+				}
+			default:
+				s.Executed++
+			}
+			s.Rows += res.RowCount
+			s.Bytes += res.BytesReceived
+			s.Add(res)
+		}
+		summary.Instances = append(summary.Instances, s)
+	}
+	return summary
+}
+
+// printOrder returns the order instance blocks are printed in: instance order or,
+// with --errors-first, the instances where a statement failed before the others,
+// each group keeping instance order
+func (c *Config) printOrder(instanceList []string, results map[string][]db.QueryResult) []string {
+	if !c.ErrorsFirst {
+		return instanceList
+	}
+	var failed, succeeded []string
+	for _, s := range summarizeRun(instanceList, results).Instances {
+		if s.Failed > 0 {
+			failed = append(failed, s.Instance)
+		} else {
+			succeeded = append(succeeded, s.Instance)
+		}
+	}
+	return append(failed, succeeded...)
+}
+
+// runClock records when a run started and, per instance, the client time spent
+// printing results and when its last result was printed. It is only used from the
+// goroutine printing results.
+type runClock struct {
+	start    time.Time
+	printing map[string]time.Duration
+	finished map[string]time.Time
+}
+
+// newRunClock starts a clock for a run that started at start
+func newRunClock(start time.Time) *runClock {
+	return &runClock{
+		start:    start,
+		printing: make(map[string]time.Duration),
+		finished: make(map[string]time.Time),
+	}
+}
+
+// printed records that printing one of an instance's results took from since until now
+func (c *runClock) printed(instanceDSN string, since time.Time) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.printing[instanceDSN] += now.Sub(since)
+	c.finished[instanceDSN] = now
+}
+
+// applyClock completes each instance's timing with the time spent printing its
+// results and its wall time, up to its last printed result or else the end of the run
+func (s *runSummary) applyClock(clock *runClock, end time.Time) {
+	if clock == nil {
+		return
+	}
+	for i := range s.Instances {
+		inst := &s.Instances[i]
+		inst.Processing += clock.printing[inst.Instance]
+		finished, ok := clock.finished[inst.Instance]
+		if !ok {
+			finished = end
+		}
+		inst.Finish(finished.Sub(clock.start))
+	}
+}
+
+// failureLine summarizes the statements that failed, e.g. "2 of 40 statement(s)
+// failed across 3 instance(s)", or returns "" when none did. An instance that
+// could not be reached counts as one failed statement.
+func (s runSummary) failureLine() string {
+	var failed, total, instances int
+	for _, inst := range s.Instances {
+		failed += inst.Failed
+		total += inst.Executed + inst.Skipped
+		if inst.Failed > 0 {
+			instances++
+		}
+	}
+	if failed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d statement(s) failed across %d instance(s)", failed, total, instances)
+}
+
+// abandoned reports whether --max-errors-per-instance gave up on any instance
+func (s runSummary) abandoned() bool {
+	for _, inst := range s.Instances {
+		if inst.Abandoned > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAbandonedSummary lists the instances --max-errors-per-instance gave up on
+func writeAbandonedSummary(w io.Writer, summary runSummary) {
+	for _, s := range summary.Instances {
+		if s.Abandoned > 0 {
+			fmt.Fprintf(w, "%s: abandoned after %d errors; %d statement(s) skipped\n",
+				db.MaskDSN(s.Instance), s.Abandoned, s.Skipped)
+		}
+	}
+}
+
+// writeBudgetSummary reports how far a run got before its row/byte budget ran out
+func writeBudgetSummary(w io.Writer, summary runSummary, budget *db.RunBudget) {
+	var completed, partial, notStarted, executed, skipped int
+	for _, s := range summary.Instances {
+		switch {
+		case s.completed():
+			completed++
+		case s.started():
+			partial++
+		default:
+			notStarted++
+		}
+		executed += s.Executed
+		skipped += s.Skipped
+	}
+
+	fmt.Fprintf(w, "Run budget exceeded after %d rows / %d bytes (limits: max-total-rows=%d, max-total-bytes=%d)\n",
+		budget.Rows(), budget.Bytes(), budget.MaxRows, budget.MaxBytes)
+	fmt.Fprintf(w, "  instances: %d completed, %d partially run, %d not started (of %d)\n",
+		completed, partial, notStarted, len(summary.Instances))
+	fmt.Fprintf(w, "  statements: %d executed, %d skipped\n", executed, skipped)
+}
+
+// writeTimingSummary reports connection handshake time separately from query time
+// and the client's own processing time
+func writeTimingSummary(w io.Writer, summary runSummary) {
+	var total db.InstanceTiming
+	var slowest time.Duration
+	slowestInstance := ""
+	for _, s := range summary.Instances {
+		total.Sum(s.InstanceTiming)
+		if s.Handshake > slowest {
+			slowest, slowestInstance = s.Handshake, s.Instance
+		}
+	}
+	fmt.Fprintf(w, "Timing: handshake %v, query %v, client %v, idle %v (summed over %d instance(s))\n",
+		total.Handshake.Round(time.Millisecond), total.QueryTime.Round(time.Millisecond),
+		total.Processing.Round(time.Millisecond), total.Idle.Round(time.Millisecond), len(summary.Instances))
+	if slowestInstance != "" {
+		fmt.Fprintf(w, "  slowest handshake: %s (%v)\n", db.MaskDSN(slowestInstance), slowest.Round(time.Millisecond))
+	}
+}
+
+// writeInstanceTimings breaks down each instance's wall time into handshake, query,
+// client processing and idle time, with the total over all instances
+func writeInstanceTimings(w io.Writer, summary runSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Timing per instance:")
+	fmt.Fprintln(tw, "  instance\twall\thandshake\tquery\tclient\tidle")
+	var total db.InstanceTiming
+	for _, s := range summary.Instances {
+		total.Sum(s.InstanceTiming)
+		writeTimingRow(tw, db.MaskDSN(s.Instance), s.InstanceTiming)
+	}
+	writeTimingRow(tw, "total", total)
+	tw.Flush()
+}
+
+// writeTimingRow writes one row of the per-instance timing table
+func writeTimingRow(w io.Writer, label string, t db.InstanceTiming) {
+	fmt.Fprintf(w, "  %s\t%v\t%v\t%v\t%v\t%v\n", label,
+		t.Wall.Round(time.Microsecond), t.Handshake.Round(time.Microsecond), t.QueryTime.Round(time.Microsecond),
+		t.Processing.Round(time.Microsecond), t.Idle.Round(time.Microsecond))
+}
+
+// runReport is the machine-readable run summary written by --report
+type runReport struct {
+	StartedAt       time.Time        `json:"started_at"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	Attempted       int              `json:"instances_attempted"`
+	Succeeded       int              `json:"instances_succeeded"`
+	Failed          int              `json:"instances_failed"`
+	Cancelled       int              `json:"instances_cancelled"`
+	Instances       []instanceReport `json:"instances"`
+}
+
+// instanceReport is the per-instance part of a runReport
+type instanceReport struct {
+	Instance string   `json:"instance"` // DSN with the password masked
+	Status   string   `json:"status"`   // succeeded, failed, cancelled, partial or not_started
+	Executed int      `json:"statements_executed"`
+	Failed   int      `json:"statements_failed"`
+	Skipped  int      `json:"statements_skipped"`
+	Rows     int      `json:"rows"`
+	Errors   []string `json:"errors,omitempty"`
+
+	AbandonedAfterErrors int `json:"abandoned_after_errors,omitempty"` // Set when --max-errors-per-instance gave up on the instance
+
+	WallSeconds       float64 `json:"wall_seconds"`
+	HandshakeSeconds  float64 `json:"handshake_seconds"`
+	QuerySeconds      float64 `json:"query_seconds"`
+	ProcessingSeconds float64 `json:"processing_seconds"` // Client side: scanning and printing rows
+	IdleSeconds       float64 `json:"idle_seconds"`
+}
+
+// newRunReport builds the --report document from a run summary
+func newRunReport(summary runSummary, startedAt time.Time, duration time.Duration) runReport {
+	report := runReport{
+		StartedAt:       startedAt,
+		DurationSeconds: duration.Seconds(),
+		Instances:       []instanceReport{},
+	}
+	for _, s := range summary.Instances {
+		status := s.status()
+		switch status {
+		case "succeeded":
+			report.Succeeded++
+		case "failed":
+			report.Failed++
+		case "cancelled":
+			report.Cancelled++
+		}
+		if s.started() {
+			report.Attempted++
+		}
+		report.Instances = append(report.Instances, instanceReport{
+			Instance: db.MaskDSN(s.Instance),
+			Status:   status,
+			Executed: s.Executed,
+			Failed:   s.Failed,
+			Skipped:  s.Skipped,
+			Rows:     s.Rows,
+			Errors:   s.Errors,
+
+			AbandonedAfterErrors: s.Abandoned,
+
+			WallSeconds:       s.Wall.Seconds(),
+			HandshakeSeconds:  s.Handshake.Seconds(),
+			QuerySeconds:      s.QueryTime.Seconds(),
+			ProcessingSeconds: s.Processing.Seconds(),
+			IdleSeconds:       s.Idle.Seconds(),
+		})
+	}
+	return report
+}
+
+// writeRunReport writes the report as indented JSON to path
+func writeRunReport(path string, report runReport) error {
+	data, err := db.MarshalJSON(report, true)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
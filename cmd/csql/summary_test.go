@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+// useFakeDriver routes instance connections to the dbtest driver for the test's duration
+func useFakeDriver(t *testing.T) {
+	t.Helper()
+	original := db.DriverName
+	db.DriverName = dbtest.DriverName
+	t.Cleanup(func() { db.DriverName = original })
+}
+
+func TestSummarizeRun(t *testing.T) {
+	instances := []string{"a", "b", "c"}
+	results := map[string][]db.QueryResult{
+		"a": {
+			{Instance: "a", RowCount: 2, BytesReceived: 10},
+			{Instance: "a", Err: errors.New("boom")},
+		},
+		"b": {
+			{Instance: "b", RowCount: 5, BytesReceived: 50},
+			{Instance: "b", Skipped: true, Err: db.ErrBudgetExceeded},
+		},
+		"c": {
+			{Instance: "c", Skipped: true, Err: db.ErrBudgetExceeded},
+		},
+	}
+
+	summary := summarizeRun(instances, results)
+	want := []instanceSummary{
+		{Instance: "a", Executed: 2, Failed: 1, Rows: 2, Bytes: 10, Errors: []string{"boom"}, QueryErrors: 1},
+		{Instance: "b", Executed: 1, Skipped: 1, Rows: 5, Bytes: 50},
+		{Instance: "c", Skipped: 1},
+	}
+	if len(summary.Instances) != len(want) {
+		t.Fatalf("summarizeRun() returned %d instances, want %d", len(summary.Instances), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(summary.Instances[i], want[i]) {
+			t.Errorf("instance %d = %+v, want %+v", i, summary.Instances[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	budget := db.NewRunBudget(5, 0, nil)
+	writeBudgetSummary(&buf, summary, budget)
+	if !strings.Contains(buf.String(), "1 completed, 1 partially run, 1 not started (of 3)") {
+		t.Errorf("writeBudgetSummary() = %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "3 executed, 2 skipped") {
+		t.Errorf("writeBudgetSummary() = %q", buf.String())
+	}
+}
+
+func TestExecuteQueries_BudgetExceeded(t *testing.T) {
+	useFakeDriver(t)
+
+	for _, concurrent := range []bool{true, false} {
+		t.Run(fmt.Sprintf("concurrent=%t", concurrent), func(t *testing.T) {
+			var instances []string
+			for i := 0; i < 3; i++ {
+				srv := dbtest.NewServer(t, fmt.Sprintf("exec-budget-%d", i))
+				srv.Handle("SELECT n FROM big", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(100)})
+				instances = append(instances, srv.DSN())
+			}
+
+			config := &Config{Concurrent: concurrent, MaxTotalRows: 150}
+			err := executeQueries(context.Background(), config, instances, "SELECT n FROM big")
+
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) || exitErr.category != categoryPartial || exitErr.code != 6 {
+				t.Fatalf("executeQueries() error = %v, want partial run with exit code 6", err)
+			}
+			if !errors.Is(err, db.ErrBudgetExceeded) {
+				t.Errorf("executeQueries() error = %v, want ErrBudgetExceeded", err)
+			}
+		})
+	}
+}
+
+func TestExecuteQueries_WithinBudget(t *testing.T) {
+	useFakeDriver(t)
+
+	srv := dbtest.NewServer(t, "exec-budget-ok")
+	srv.Handle("SELECT n FROM small", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(10)})
+
+	config := &Config{Concurrent: true, MaxTotalRows: 10}
+	if err := executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT n FROM small"); err != nil {
+		t.Errorf("executeQueries() error = %v, want nil", err)
+	}
+}
+
+func TestExecuteQueries_Report(t *testing.T) {
+	useFakeDriver(t)
+
+	healthy := dbtest.NewServer(t, "report-ok")
+	healthy.Handle("SELECT n FROM t", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(3)})
+	down := dbtest.NewServer(t, "report-down")
+	down.FailConnect(errors.New("connection refused"))
+	broken := dbtest.NewServer(t, "report-broken")
+	broken.Handle("SELECT n FROM t", dbtest.Response{Err: errors.New("table t doesn't exist")})
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	config := &Config{Concurrent: true, Report: reportFile}
+	instances := []string{healthy.DSN(), down.DSN(), broken.DSN()}
+	err := executeQueries(context.Background(), config, instances, "SELECT n FROM t")
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryQueryError {
+		t.Fatalf("executeQueries() error = %v, want a query-error exit, which outranks the unreachable instance", err)
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	var report map[string]interface{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, data)
+	}
+
+	for _, field := range []string{"started_at", "duration_seconds", "instances"} {
+		if _, ok := report[field]; !ok {
+			t.Errorf("report is missing %q:\n%s", field, data)
+		}
+	}
+	for field, want := range map[string]float64{"instances_attempted": 3, "instances_succeeded": 1, "instances_failed": 2} {
+		if report[field] != want {
+			t.Errorf("report %s = %v, want %v", field, report[field], want)
+		}
+	}
+
+	instancesJSON := report["instances"].([]interface{})
+	if len(instancesJSON) != 3 {
+		t.Fatalf("report has %d instances, want 3", len(instancesJSON))
+	}
+	wantStatus := []string{"succeeded", "failed", "failed"}
+	for i, raw := range instancesJSON {
+		inst := raw.(map[string]interface{})
+		if inst["status"] != wantStatus[i] {
+			t.Errorf("instance %d status = %v, want %s", i, inst["status"], wantStatus[i])
+		}
+		if strings.Contains(inst["instance"].(string), "secret") {
+			t.Errorf("instance %d exposes the password: %v", i, inst["instance"])
+		}
+	}
+	if errs := instancesJSON[1].(map[string]interface{})["errors"].([]interface{}); !strings.Contains(errs[0].(string), "connection refused") {
+		t.Errorf("unreachable instance errors = %v, want the connect failure", errs)
+	}
+	if errs := instancesJSON[2].(map[string]interface{})["errors"].([]interface{}); !strings.Contains(errs[0].(string), "SELECT n FROM t: ") {
+		t.Errorf("failed statement errors = %v, want the statement and its error", errs)
+	}
+}
+
+func TestExecuteQueries_ErrorLocation(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "error-location")
+	srv.Handle("-- Backfill\nUPDATE t SET c = 1", dbtest.Response{Err: errors.New("Error 1054: Unknown column 'c'")})
+
+	var stdout bytes.Buffer
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	config := &Config{File: "deploy.sql", Report: reportFile, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+	sqls := "SELECT 1;\n\n-- Backfill\nUPDATE t SET c = 1;\n"
+	_ = executeQueries(context.Background(), config, []string{srv.DSN()}, sqls)
+
+	// The statement starts at its first token, past the comment before it
+	const want = "deploy.sql:4: -- Backfill\\nUPDATE t SET c = 1: query error: Error 1054"
+	if text := strings.ReplaceAll(want, `\n`, "\n"); !strings.Contains(stdout.String(), text) {
+		t.Errorf("output is missing %q:\n%s", text, stdout.String())
+	}
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), want) {
+		t.Errorf("report is missing %q:\n%s", want, data)
+	}
+}
+
+func TestExecuteQueries_MaxErrorsPerInstance(t *testing.T) {
+	useFakeDriver(t)
+
+	healthy := dbtest.NewServer(t, "abandon-ok")
+	broken := dbtest.NewServer(t, "abandon-broken")
+	broken.Fallback(dbtest.Response{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")})
+	sqls := "UPDATE t SET a = 1; UPDATE t SET a = 2; UPDATE t SET a = 3; UPDATE t SET a = 4"
+
+	var stdout, stderr bytes.Buffer
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	config := &Config{Concurrent: true, MaxErrorsPerInstance: 2, Report: reportFile, output: db.NewOutputSink(&stdout, &stderr)}
+	err := executeQueries(context.Background(), config, []string{healthy.DSN(), broken.DSN()}, sqls)
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.category != categoryQueryError {
+		t.Fatalf("executeQueries() error = %v, want a query-error exit", err)
+	}
+
+	if got := len(healthy.Executed()); got != 4 {
+		t.Errorf("healthy instance ran %d statements, want all 4", got)
+	}
+	if got := len(broken.Executed()); got != 2 {
+		t.Errorf("broken instance ran %d statements, want 2 before it was abandoned", got)
+	}
+	if want := "broken:3306)/app: abandoned after 2 errors; 2 statement(s) skipped"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("diagnostics do not report the abandoned instance, want %q:\n%s", want, stderr.String())
+	}
+	if strings.Contains(stderr.String(), "abandon-ok") {
+		t.Errorf("healthy instance reported as abandoned:\n%s", stderr.String())
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if got := report.Instances[1]; got.AbandonedAfterErrors != 2 || got.Skipped != 2 || got.Status != "failed" {
+		t.Errorf("abandoned instance report = %+v", got)
+	}
+	if got := report.Instances[0].AbandonedAfterErrors; got != 0 {
+		t.Errorf("healthy instance abandoned_after_errors = %d, want 0", got)
+	}
+}
+
+func TestExecuteQueries_InstanceTimings(t *testing.T) {
+	useFakeDriver(t)
+
+	first := dbtest.NewServer(t, "timing-first")
+	second := dbtest.NewServer(t, "timing-second")
+	for _, srv := range []*dbtest.Server{first, second} {
+		srv.Handle("SELECT n FROM t", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(3), Delay: 5 * time.Millisecond})
+	}
+
+	var stdout, stderr bytes.Buffer
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	config := &Config{Verbose: 2, Report: reportFile, output: db.NewOutputSink(&stdout, &stderr)}
+	if err := executeQueries(context.Background(), config, []string{first.DSN(), second.DSN()}, "SELECT n FROM t"); err != nil {
+		t.Fatalf("executeQueries() error = %v", err)
+	}
+
+	for _, want := range []string{"Timing per instance:", "wall", "idle", db.MaskDSN(second.DSN()), "  total"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("timing breakdown is missing %q:\n%s", want, stderr.String())
+		}
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, data)
+	}
+	// Run sequentially, the second instance waited for the first one's query
+	firstReport, secondReport := report.Instances[0], report.Instances[1]
+	if firstReport.QuerySeconds < 0.005 || firstReport.ProcessingSeconds <= 0 {
+		t.Errorf("first instance = %+v, want its query and processing time", firstReport)
+	}
+	if secondReport.IdleSeconds < 0.005 || secondReport.WallSeconds < secondReport.IdleSeconds+secondReport.QuerySeconds {
+		t.Errorf("second instance = %+v, want the wait for the first instance as idle time", secondReport)
+	}
+}
+
+func TestConfig_PrintOrder(t *testing.T) {
+	ok := []db.QueryResult{{Statement: "SELECT 1"}}
+	failed := []db.QueryResult{{Statement: "SELECT 1"}, {Statement: "SELECT * FROM t", Err: errors.New("Error 1146: Table 'app.t' doesn't exist")}}
+	skipped := []db.QueryResult{{Statement: "SELECT 1", Skipped: true, Err: context.Canceled}}
+	unreachable := []db.QueryResult{{Err: errors.New("dial tcp: connection refused"), ConnectFailed: true}}
+
+	instances := []string{"db1", "db2", "db3", "db4", "db5", "db6"}
+	results := map[string][]db.QueryResult{
+		"db1": ok, "db2": failed, "db3": skipped, "db4": unreachable, "db5": ok, "db6": failed,
+	}
+
+	if got := (&Config{}).printOrder(instances, results); strings.Join(got, ",") != strings.Join(instances, ",") {
+		t.Errorf("printOrder() = %q, want instance order", got)
+	}
+	want := "db2,db4,db6,db1,db3,db5"
+	if got := (&Config{ErrorsFirst: true}).printOrder(instances, results); strings.Join(got, ",") != want {
+		t.Errorf("printOrder() with --errors-first = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteQueries_ErrorsFirst(t *testing.T) {
+	useFakeDriver(t)
+	var instances []string
+	for i, fail := range []bool{false, true, false, true} {
+		srv := dbtest.NewServer(t, fmt.Sprintf("errors-first-%d", i+1))
+		if fail {
+			srv.Handle("SELECT id FROM t", dbtest.Response{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")})
+		} else {
+			srv.Handle("SELECT id FROM t", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(1)})
+		}
+		instances = append(instances, srv.DSN())
+	}
+
+	for _, concurrent := range []bool{false, true} {
+		var stdout bytes.Buffer
+		config := &Config{Concurrent: concurrent, ErrorsFirst: true, output: db.NewOutputSink(&stdout, &bytes.Buffer{})}
+		executeQueries(context.Background(), config, instances, "SELECT id FROM t")
+		last := -1
+		for _, host := range []string{"errors-first-2", "errors-first-4", "errors-first-1", "errors-first-3"} {
+			at := strings.Index(stdout.String(), host)
+			if at < last {
+				t.Errorf("concurrent=%t: %s printed out of order, want 2, 4, 1, 3:\n%s", concurrent, host, stdout.String())
+			}
+			last = at
+		}
+	}
+}
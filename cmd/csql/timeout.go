@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// parseServerTimeout parses the "timeout" of a --json server, a duration such as
+// "90s" or "5m"; "0" lifts --timeout for the server
+func parseServerTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: expected a duration such as 30s or 5m", value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q: cannot be negative", value)
+	}
+	return d, nil
+}
+
+// instanceTimeout returns how long an instance's statements may run: its server's
+// own timeout if it names one, else --timeout (0 = no limit)
+func (c *Config) instanceTimeout(instanceDSN string) time.Duration {
+	if d, ok := c.timeouts[instanceDSN]; ok {
+		return d
+	}
+	return c.Timeout
+}
+
+// usesTimeouts reports whether any instance's statements are bounded
+func (c *Config) usesTimeouts() bool {
+	if c.Timeout > 0 {
+		return true
+	}
+	for _, d := range c.timeouts {
+		if d > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withInstanceTimeout bounds ctx by the instance's timeout, if it has one. Once it
+// expires, the statement in flight is cancelled and the rest are skipped.
+func (c *Config) withInstanceTimeout(ctx context.Context, instanceDSN string) (context.Context, context.CancelFunc) {
+	if d := c.instanceTimeout(instanceDSN); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
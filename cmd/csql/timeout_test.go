@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestLoadInstancesFromJSON_Timeouts(t *testing.T) {
+	path := writeServers(t, `[
+  {"user": "app", "host": "db-1"},
+  {"user": "app", "host": "db-2", "timeout": "5m"},
+  {"user": "app", "host": "db-3", "timeout": "0"}
+]`)
+	config := &Config{JSONFile: path, Timeout: time.Minute}
+	instances, err := config.loadInstancesFromJSON(nil)
+	if err != nil || len(instances) != 3 {
+		t.Fatalf("loadInstancesFromJSON() = %q, %v", instances, err)
+	}
+	for i, want := range []time.Duration{time.Minute, 5 * time.Minute, 0} {
+		if got := config.instanceTimeout(instances[i]); got != want {
+			t.Errorf("timeout of %s = %v, want %v", db.MaskDSN(instances[i]), got, want)
+		}
+	}
+
+	config = &Config{JSONFile: writeServers(t, `[{"user": "app", "host": "db-1", "timeout": "10"}]`)}
+	if _, err := config.loadInstancesFromJSON(nil); err == nil || !strings.Contains(err.Error(), `invalid timeout "10"`) {
+		t.Errorf("loadInstancesFromJSON() error = %v, want the timeout without a unit rejected", err)
+	}
+}
+
+func TestConfig_UsesTimeouts(t *testing.T) {
+	if (&Config{timeouts: map[string]time.Duration{"app@tcp(db-1:3306)/": 0}}).usesTimeouts() {
+		t.Error("usesTimeouts() = true when no instance has a limit")
+	}
+	if !(&Config{timeouts: map[string]time.Duration{"app@tcp(db-1:3306)/": time.Second}}).usesTimeouts() {
+		t.Error("usesTimeouts() = false with a per-server timeout")
+	}
+}
+
+func TestConfig_RunPhase_InstanceTimeout(t *testing.T) {
+	useFakeDriver(t)
+	slow := dbtest.NewServer(t, "timeout-slow")
+	patient := dbtest.NewServer(t, "timeout-patient")
+	for _, srv := range []*dbtest.Server{slow, patient} {
+		srv.Handle("SELECT SLEEP(1)", dbtest.Response{Columns: []string{"s"}, Rows: dbtest.IntRows(1), Delay: 200 * time.Millisecond})
+		srv.Handle("SELECT 2", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1)})
+	}
+
+	// The global timeout cuts the slow instance short; the patient one's own outlasts the statement
+	config := &Config{
+		Timeout:  50 * time.Millisecond,
+		timeouts: map[string]time.Duration{patient.DSN(): time.Minute},
+		output:   db.NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{}),
+	}
+	results := config.runPhase(context.Background(), []string{slow.DSN(), patient.DSN()}, "SELECT SLEEP(1); SELECT 2", db.ExecOptions{}, nil)
+
+	summary := summarizeRun([]string{slow.DSN(), patient.DSN()}, results)
+	if s := summary.Instances[0]; s.TimedOut != 2 {
+		t.Errorf("slow instance timed out %d statement(s), want the in-flight one and the skipped one: %+v", s.TimedOut, results[slow.DSN()])
+	}
+	if s := summary.Instances[1]; s.Failed > 0 || s.Skipped > 0 {
+		t.Errorf("patient instance failed %d and skipped %d statement(s), want its own timeout to apply", s.Failed, s.Skipped)
+	}
+}
+
+func TestExecuteQueries_ConnectTimeout(t *testing.T) {
+	useFakeDriver(t)
+	unreachable := dbtest.NewServer(t, "connect-timeout-down")
+	unreachable.ConnectDelay(time.Second)
+	up := dbtest.NewServer(t, "connect-timeout-up")
+	up.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: dbtest.IntRows(1)})
+
+	var stdout bytes.Buffer
+	config := &Config{Concurrent: true, ConnectTimeout: 30 * time.Millisecond, output: db.NewOutputSink(&stdout, &stdout)}
+	start := time.Now()
+	executeQueries(context.Background(), config, []string{unreachable.DSN(), up.DSN()}, "SELECT 1")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("run took %v, want the unreachable instance to fail fast", elapsed)
+	}
+	if !strings.Contains(stdout.String(), "connection timeout after 30ms") {
+		t.Errorf("output lacks the connection timeout:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "query error") {
+		t.Errorf("a connection timeout is reported as a query error:\n%s", stdout.String())
+	}
+}
+
+func TestConfig_IOTimeouts(t *testing.T) {
+	config := &Config{ReadTimeout: 30 * time.Second, WriteTimeout: 10 * time.Second}
+	if opts := config.connectOptions(); opts.ReadTimeout != 30*time.Second || opts.WriteTimeout != 10*time.Second {
+		t.Errorf("connectOptions() = %+v, want the read and write timeouts", opts)
+	}
+
+	invalid := Config{Instances: "user:pass@tcp(host:3306)/db", Statements: "SELECT 1", ReadTimeout: -time.Second}
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "--read-timeout") {
+		t.Errorf("Validate() error = %v, want a negative --read-timeout rejected", err)
+	}
+}
+
+func TestExecuteQueries_QueryTimeout(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "query-timeout")
+	srv.Handle("SELECT * FROM locked", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1), Delay: time.Minute})
+	srv.Handle("SELECT 2", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1)})
+
+	for _, stopOnError := range []bool{false, true} {
+		config := &Config{Instances: "x", Statements: "x", QueryTimeout: 50 * time.Millisecond}
+		if stopOnError {
+			config.MaxErrorsPerInstance = 1
+		}
+		if err := config.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		var stdout bytes.Buffer
+		config.output = db.NewOutputSink(&stdout, &bytes.Buffer{})
+		err := executeQueries(context.Background(), config, []string{srv.DSN()}, "SELECT * FROM locked; SELECT 2")
+		var exitErr *exitError
+		if !errors.As(err, &exitErr) || exitErr.category != categoryTimeout {
+			t.Errorf("stop on error %t: error %v, want the timeout exit category", stopOnError, err)
+		}
+		out := stdout.String()
+		if !strings.Contains(out, "query timed out after 50ms") {
+			t.Errorf("stop on error %t: output does not report the timeout:\n%s", stopOnError, out)
+		}
+		if ran := strings.Contains(out, "SELECT 2\nn\n1"); ran == stopOnError {
+			t.Errorf("stop on error %t: next statement ran %t:\n%s", stopOnError, ran, out)
+		}
+	}
+	if config := (&Config{Instances: "x", Statements: "x", QueryTimeout: -time.Second}); config.Validate() == nil {
+		t.Error("Validate() accepted a negative --query-timeout")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// tlsMode returns the mode connections are encrypted in: --tls-mode, or, as with
+// the mysql client, verify-ca when only a CA is given and preferred when only a
+// client certificate is. "" leaves each DSN's tls parameter alone.
+func (c *Config) tlsMode() db.TLSMode {
+	switch {
+	case c.TLSMode != "":
+		return db.TLSMode(c.TLSMode)
+	case c.TLSCA != "":
+		return db.TLSVerifyCA
+	case c.TLSCert != "":
+		return db.TLSPreferred
+	}
+	return ""
+}
+
+// validateTLS checks the --tls-* flags
+func (c *Config) validateTLS() error {
+	if c.TLSMode != "" {
+		if _, err := db.ParseTLSMode(c.TLSMode); err != nil {
+			return fmt.Errorf("--tls-mode: %w", err)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+	if c.tlsMode() == db.TLSDisable && (c.TLSCA != "" || c.TLSCert != "") {
+		return fmt.Errorf("--tls-ca, --tls-cert and --tls-key cannot be used with --tls-mode=disable")
+	}
+	return nil
+}
+
+// registerTLS loads the --tls-* certificate files for the connections to use
+func (c *Config) registerTLS() error {
+	mode := c.tlsMode()
+	if mode == "" {
+		return nil
+	}
+	opts := db.TLSOptions{Mode: mode}
+	for _, f := range []struct {
+		flag, path string
+		dest       *string
+	}{{"--tls-ca", c.TLSCA, &opts.CA}, {"--tls-cert", c.TLSCert, &opts.Cert}, {"--tls-key", c.TLSKey, &opts.Key}} {
+		if f.path == "" {
+			continue
+		}
+		path, err := expandPath(f.path)
+		if err != nil {
+			return fmt.Errorf("failed to expand %s path: %w", f.flag, err)
+		}
+		*f.dest = path
+	}
+	if err := db.RegisterTLS(opts); err != nil {
+		return fmt.Errorf("TLS: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+func TestConfig_TLSMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		want    db.TLSMode
+		wantErr string
+	}{
+		{name: "no flags", config: Config{}, want: ""},
+		{name: "mode", config: Config{TLSMode: "verify-identity", TLSCA: "ca.pem"}, want: db.TLSVerifyIdentity},
+		{name: "CA alone verifies it", config: Config{TLSCA: "ca.pem"}, want: db.TLSVerifyCA},
+		{name: "client certificate alone", config: Config{TLSCert: "client.pem", TLSKey: "client-key.pem"}, want: db.TLSPreferred},
+		{name: "unknown mode", config: Config{TLSMode: "verify-full"}, wantErr: "--tls-mode"},
+		{name: "certificate without key", config: Config{TLSCert: "client.pem"}, wantErr: "--tls-key"},
+		{name: "files with disable", config: Config{TLSMode: "disable", TLSCA: "ca.pem"}, wantErr: "disable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			c.Instances = "user:pass@tcp(host:3306)/db"
+			c.Statements = "SELECT 1"
+			err := c.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Validate() error = %v, want one mentioning %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := c.tlsMode(); got != tt.want {
+				t.Errorf("tlsMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_RegisterTLS(t *testing.T) {
+	c := Config{TLSMode: "require"}
+	if err := c.registerTLS(); err != nil {
+		t.Errorf("registerTLS() without files error = %v", err)
+	}
+
+	c.TLSCA = filepath.Join(t.TempDir(), "missing-ca.pem")
+	if err := c.registerTLS(); err == nil || !strings.Contains(err.Error(), "CA file") {
+		t.Errorf("registerTLS() error = %v, want the missing CA file reported", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// sshNone is the "ssh" value of a --json server that is connected to directly,
+// even under --ssh
+const sshNone = "none"
+
+// bastion returns the bastion a server names, "" if it is connected to directly
+func (s *Server) bastion() string {
+	if strings.EqualFold(strings.TrimSpace(s.SSH), sshNone) {
+		return ""
+	}
+	return s.SSH
+}
+
+// usesSSH reports whether any instance is reached through a bastion
+func (c *Config) usesSSH() bool {
+	if c.SSH != "" {
+		return true
+	}
+	for _, bastion := range c.bastions {
+		if bastion != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// openTunnels loads the SSH keys and known hosts when any instance is behind a
+// bastion. The tunnels themselves are opened as instances are connected to.
+func (c *Config) openTunnels() error {
+	if !c.usesSSH() {
+		return nil
+	}
+	knownHosts, err := expandPath(c.SSHKnownHosts)
+	if err != nil {
+		return fmt.Errorf("failed to expand --ssh-known-hosts path: %w", err)
+	}
+	keyFile := c.SSHKey
+	if keyFile != "" {
+		if keyFile, err = expandPath(keyFile); err != nil {
+			return fmt.Errorf("failed to expand --ssh-key path: %w", err)
+		}
+	}
+	tunnels, err := db.NewTunnels(db.TunnelConfig{Bastion: c.SSH, Bastions: c.bastions, KeyFile: keyFile, KnownHosts: knownHosts})
+	if err != nil {
+		return fmt.Errorf("SSH: %w", err)
+	}
+	c.tunnels = tunnels
+	return nil
+}
+
+// closeTunnels stops forwarding and disconnects from the bastions
+func (c *Config) closeTunnels() {
+	if c.tunnels != nil {
+		c.tunnels.Close()
+		c.tunnels = nil
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db"
+)
+
+// printXML prints an instance's results for --format xml, as an <instance>
+// element of the run's document holding a <resultset> per statement
+func (c *Config) printXML(instanceDSN string, results []db.QueryResult) {
+	start := time.Now()
+	served := instanceDSN
+	if len(results) > 0 && results[0].Instance != "" {
+		served = results[0].Instance // The group member that served, under --failover
+	}
+	_ = c.sink().BlockFor(instanceDSN, db.StreamResults, func(w io.Writer) {
+		db.WriteXMLInstance(w, served, results)
+	})
+	c.clock.printed(instanceDSN, start)
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// AuthStatus classifies the outcome of checking an instance's credentials
+type AuthStatus string
+
+// Outcomes of CheckAuth
+const (
+	AuthOK              AuthStatus = "ok"               // Connected, and a trivial query ran
+	AuthAccessDenied    AuthStatus = "access-denied"    // The server rejected the user or password
+	AuthDatabaseDenied  AuthStatus = "database-denied"  // The user may not access the database in the DSN
+	AuthUnknownDatabase AuthStatus = "unknown-database" // The database in the DSN does not exist
+	AuthFailed          AuthStatus = "failed"           // Any other failure, e.g. the instance is unreachable
+)
+
+// MySQL error numbers CheckAuth tells apart
+const (
+	errDBAccessDenied       = 1044 // ER_DBACCESS_DENIED_ERROR
+	errAccessDenied         = 1045 // ER_ACCESS_DENIED_ERROR
+	errBadDB                = 1049 // ER_BAD_DB_ERROR
+	errAccessDeniedNoPasswd = 1698 // ER_ACCESS_DENIED_NO_PASSWORD_ERROR
+)
+
+// AuthResult is the outcome of checking one instance's credentials
+type AuthResult struct {
+	Instance  string
+	Status    AuthStatus
+	Err       error         // Why the check failed; nil for AuthOK
+	Handshake time.Duration // Time taken to connect, or to fail connecting
+}
+
+// ClassifyAuthError maps an error from connecting or running a statement to an
+// AuthStatus by its MySQL error number
+func ClassifyAuthError(err error) AuthStatus {
+	if err == nil {
+		return AuthOK
+	}
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return AuthFailed
+	}
+	switch mysqlErr.Number {
+	case errAccessDenied, errAccessDeniedNoPasswd:
+		return AuthAccessDenied
+	case errDBAccessDenied:
+		return AuthDatabaseDenied
+	case errBadDB:
+		return AuthUnknownDatabase
+	default:
+		return AuthFailed
+	}
+}
+
+// CheckAuth verifies that an instance accepts the DSN's credentials and database
+// without running any of the user's statements: it connects, pings and runs
+// SELECT 1.
+func CheckAuth(ctx context.Context, instanceDSN string, opts ExecOptions) AuthResult {
+	instanceDSN = strings.TrimSpace(instanceDSN)
+	start := time.Now()
+	sess, err := Connect(ctx, instanceDSN, opts)
+	res := AuthResult{Instance: instanceDSN, Handshake: time.Since(start)}
+	if err == nil {
+		defer sess.Close()
+		var one int
+		if queryErr := sess.conn.QueryRowContext(ctx, "SELECT 1").Scan(&one); queryErr != nil {
+			err = fmt.Errorf("SELECT 1 failed: %w", queryErr)
+		}
+	}
+	res.Status, res.Err = ClassifyAuthError(err), err
+	return res
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyAuthError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want AuthStatus
+	}{
+		{name: "no error", err: nil, want: AuthOK},
+		{name: "wrong password", err: &mysql.MySQLError{Number: 1045, Message: "Access denied for user 'app'@'10.0.0.1' (using password: YES)"}, want: AuthAccessDenied},
+		{name: "no password given", err: &mysql.MySQLError{Number: 1698, Message: "Access denied for user 'root'@'localhost'"}, want: AuthAccessDenied},
+		{name: "no grant on database", err: &mysql.MySQLError{Number: 1044, Message: "Access denied for user 'app'@'%' to database 'billing'"}, want: AuthDatabaseDenied},
+		{name: "unknown database", err: &mysql.MySQLError{Number: 1049, Message: "Unknown database 'shpo'"}, want: AuthUnknownDatabase},
+		{name: "wrapped", err: fmt.Errorf("failed to ping database: %w", &mysql.MySQLError{Number: 1049}), want: AuthUnknownDatabase},
+		{name: "other server error", err: &mysql.MySQLError{Number: 1040, Message: "Too many connections"}, want: AuthFailed},
+		{name: "network error", err: errors.New("dial tcp 10.0.0.1:3306: connection refused"), want: AuthFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyAuthError(tt.err); got != tt.want {
+				t.Errorf("ClassifyAuthError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuth(t *testing.T) {
+	useFakeDriver(t)
+
+	ok := dbtest.NewServer(t, "auth-ok")
+	ok.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: [][]driver.Value{{int64(1)}}})
+	denied := dbtest.NewServer(t, "auth-denied")
+	denied.FailConnect(&mysql.MySQLError{Number: 1045, Message: "Access denied"})
+	blocked := dbtest.NewServer(t, "auth-blocked")
+	blocked.Handle("SELECT 1", dbtest.Response{Err: &mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}})
+
+	tests := []struct {
+		srv  *dbtest.Server
+		want AuthStatus
+	}{
+		{ok, AuthOK},
+		{denied, AuthAccessDenied},
+		{blocked, AuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.srv.Host, func(t *testing.T) {
+			res := CheckAuth(context.Background(), tt.srv.DSN(), ExecOptions{})
+			if res.Status != tt.want || (res.Err == nil) != (tt.want == AuthOK) {
+				t.Errorf("CheckAuth() = %s (%v), want %s", res.Status, res.Err, tt.want)
+			}
+		})
+	}
+	if got := ok.Executed(); len(got) != 1 || got[0] != "SELECT 1" {
+		t.Errorf("executed %v, want only SELECT 1", got)
+	}
+}
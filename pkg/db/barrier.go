@@ -0,0 +1,60 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+)
+
+// barrierDirective matches the comment that separates execution phases
+var barrierDirective = regexp.MustCompile(`(?i)^--\s*csql:\s*barrier$`)
+
+// SplitBarriers splits sqls into phases at "-- csql: barrier" comments. Every
+// instance finishes a phase before any instance starts the next one. A barrier
+// also ends the statement before it; barriers inside strings or block comments are
+// ignored, and phases without statements (split by term) are dropped. Each phase
+// starts with the line breaks before it, so its statements keep their script lines.
+func SplitBarriers(sqls string, term Terminator) []string {
+	var phases []string
+	var current strings.Builder
+	lines := 0 // Line breaks before the current token
+	for _, tok := range tokenizeSQL(sqls) {
+		if tok.Kind == tokenComment && barrierDirective.MatchString(strings.TrimSpace(tok.Text)) {
+			phases = append(phases, current.String())
+			current.Reset()
+			current.WriteString(strings.Repeat("\n", lines+strings.Count(tok.Text, "\n")))
+			lines += strings.Count(tok.Text, "\n")
+			continue
+		}
+		current.WriteString(tok.Text)
+		lines += strings.Count(tok.Text, "\n")
+	}
+	phases = append(phases, current.String())
+
+	var nonEmpty []string
+	for _, phase := range phases {
+		if len(term.Split(phase)) > 0 {
+			nonEmpty = append(nonEmpty, phase)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return []string{sqls}
+	}
+	return nonEmpty
+}
+
+// SkippedResults reports every statement in sqls, split by term, as skipped on an
+// instance, with err explaining why
+func SkippedResults(instanceDSN string, sqls string, term Terminator, err error) []QueryResult {
+	var results []QueryResult
+	for _, stmtInfo := range term.Split(sqls) {
+		results = append(results, QueryResult{
+			Instance:       instanceDSN,
+			Statement:      stmtInfo.reportedSQL(),
+			Err:            err,
+			VerticalFormat: stmtInfo.Vertical,
+			Skipped:        true,
+			Line:           stmtInfo.Line,
+		})
+	}
+	return results
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitBarriers(t *testing.T) {
+	tests := []struct {
+		name string
+		sqls string
+		want [][]string // Statements per phase
+	}{
+		{
+			name: "no barrier",
+			sqls: "SELECT 1; SELECT 2",
+			want: [][]string{{"SELECT 1", "SELECT 2"}},
+		},
+		{
+			name: "one barrier",
+			sqls: "ALTER TABLE t ADD c INT;\n-- csql: barrier\nUPDATE t SET c = 1;",
+			want: [][]string{{"ALTER TABLE t ADD c INT"}, {"UPDATE t SET c = 1"}},
+		},
+		{
+			name: "spacing and case",
+			sqls: "SELECT 1;\n--csql:barrier\nSELECT 2;\n  --   CSQL:  Barrier  \nSELECT 3",
+			want: [][]string{{"SELECT 1"}, {"SELECT 2"}, {"SELECT 3"}},
+		},
+		{
+			name: "barrier ends an unterminated statement",
+			sqls: "SELECT 1\n-- csql: barrier\nSELECT 2",
+			want: [][]string{{"SELECT 1"}, {"SELECT 2"}},
+		},
+		{
+			name: "directive inside a string or block comment is not a barrier",
+			sqls: "SELECT '\n-- csql: barrier\n';\n/*\n-- csql: barrier\n*/ SELECT 2",
+			want: [][]string{{"SELECT '\n-- csql: barrier\n'", "/*\n-- csql: barrier\n*/ SELECT 2"}},
+		},
+		{
+			name: "other comments are kept",
+			sqls: "-- csql: barrier-ish\nSELECT 1",
+			want: [][]string{{"-- csql: barrier-ish\nSELECT 1"}},
+		},
+		{
+			name: "empty phases are dropped",
+			sqls: "-- csql: barrier\nSELECT 1;\n-- csql: barrier\n-- csql: barrier\nSELECT 2;\n-- csql: barrier\n",
+			want: [][]string{{"SELECT 1"}, {"SELECT 2"}},
+		},
+		{
+			name: "without statements the input is left alone",
+			sqls: "-- csql: barrier\n",
+			want: [][]string{{"-- csql: barrier"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phases := SplitBarriers(tt.sqls, Terminator{})
+			var got [][]string
+			for _, phase := range phases {
+				var stmts []string
+				for _, stmt := range splitSQLStatements(phase) {
+					stmts = append(stmts, stmt.SQL)
+				}
+				got = append(got, stmts)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitBarriers(%q) phases = %q, want %q", tt.sqls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkippedResults(t *testing.T) {
+	reason := errors.New("not run")
+	results := SkippedResults("dsn", "SELECT 1;\nSHOW SLAVE STATUS\\G", Terminator{}, reason)
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	want := []QueryResult{
+		{Instance: "dsn", Statement: "SELECT 1", Err: reason, Skipped: true, Line: 1},
+		{Instance: "dsn", Statement: "SHOW SLAVE STATUS\\G", Err: reason, Skipped: true, VerticalFormat: true, Line: 2},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("SkippedResults() = %+v, want %+v", results, want)
+	}
+}
+
+func TestSplitBarriers_KeepsLines(t *testing.T) {
+	sqls := "ALTER TABLE t ADD c INT;\n\n-- csql: barrier\n-- Backfill\nUPDATE t SET c = 1;\n-- csql: barrier\nSELECT COUNT(*) FROM t"
+	var term Terminator
+	var got []int
+	for _, phase := range SplitBarriers(sqls, term) {
+		for _, stmt := range term.Split(phase) {
+			got = append(got, stmt.Line)
+		}
+	}
+	if want := []int{1, 5, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %v, want %v", got, want)
+	}
+}
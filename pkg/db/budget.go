@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrBudgetExceeded is reported when a run-wide row or byte budget is exhausted
+var ErrBudgetExceeded = errors.New("run budget exceeded")
+
+// ErrResultTooLarge is reported for a statement whose rows outgrow ExecOptions.MaxResultBytes
+var ErrResultTooLarge = errors.New("result too large")
+
+// AbandonedError is reported for the statements skipped on an instance once
+// ExecOptions.MaxErrorsPerInstance of its statements failed
+type AbandonedError struct {
+	Errors int // Statements that had failed on the instance
+}
+
+func (e *AbandonedError) Error() string {
+	return fmt.Sprintf("instance abandoned after %d errors", e.Errors)
+}
+
+// RunBudget tracks rows and bytes received across all instances of a run.
+// It is safe for concurrent use; a nil *RunBudget imposes no limits.
+type RunBudget struct {
+	MaxRows  int64 // 0 means unlimited
+	MaxBytes int64 // 0 means unlimited
+
+	rows     atomic.Int64
+	bytes    atomic.Int64
+	exceeded atomic.Bool
+	cancel   context.CancelFunc
+}
+
+// NewRunBudget creates a budget that calls cancel once either limit is exceeded
+func NewRunBudget(maxRows, maxBytes int64, cancel context.CancelFunc) *RunBudget {
+	return &RunBudget{MaxRows: maxRows, MaxBytes: maxBytes, cancel: cancel}
+}
+
+// Add records received rows and bytes, returning ErrBudgetExceeded once a limit is crossed
+func (b *RunBudget) Add(rows, bytes int64) error {
+	if b == nil {
+		return nil
+	}
+	totalRows := b.rows.Add(rows)
+	totalBytes := b.bytes.Add(bytes)
+
+	if (b.MaxRows > 0 && totalRows > b.MaxRows) || (b.MaxBytes > 0 && totalBytes > b.MaxBytes) {
+		if b.exceeded.CompareAndSwap(false, true) && b.cancel != nil {
+			b.cancel()
+		}
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// Exceeded reports whether a limit has been crossed
+func (b *RunBudget) Exceeded() bool {
+	return b != nil && b.exceeded.Load()
+}
+
+// Rows returns the number of rows received so far
+func (b *RunBudget) Rows() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.rows.Load()
+}
+
+// Bytes returns the approximate number of bytes received so far
+func (b *RunBudget) Bytes() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.bytes.Load()
+}
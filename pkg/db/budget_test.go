@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+// useFakeDriver routes instance connections to the dbtest driver for the test's duration
+func useFakeDriver(t *testing.T) {
+	t.Helper()
+	original := DriverName
+	DriverName = dbtest.DriverName
+	t.Cleanup(func() { DriverName = original })
+}
+
+func TestRunBudget_Add(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxRows  int64
+		maxBytes int64
+		adds     [][2]int64
+		wantErr  bool
+	}{
+		{name: "unlimited", adds: [][2]int64{{1000, 1 << 30}}},
+		{name: "under row limit", maxRows: 10, adds: [][2]int64{{5, 0}, {5, 0}}},
+		{name: "over row limit", maxRows: 10, adds: [][2]int64{{5, 0}, {6, 0}}, wantErr: true},
+		{name: "under byte limit", maxBytes: 100, adds: [][2]int64{{1, 60}, {1, 40}}},
+		{name: "over byte limit", maxBytes: 100, adds: [][2]int64{{1, 60}, {1, 41}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled := false
+			b := NewRunBudget(tt.maxRows, tt.maxBytes, func() { cancelled = true })
+			var err error
+			for _, add := range tt.adds {
+				err = b.Add(add[0], add[1])
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if b.Exceeded() != tt.wantErr || cancelled != tt.wantErr {
+				t.Errorf("Exceeded() = %v, cancelled = %v, want %v", b.Exceeded(), cancelled, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunBudget_Nil(t *testing.T) {
+	var b *RunBudget
+	if err := b.Add(1, 1); err != nil {
+		t.Errorf("nil budget Add() error = %v", err)
+	}
+	if b.Exceeded() || b.Rows() != 0 || b.Bytes() != 0 {
+		t.Error("nil budget should report no usage")
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_BudgetCancelsInstances(t *testing.T) {
+	useFakeDriver(t)
+
+	const instances = 4
+	const maxRows = 250
+	var dsns []string
+	for i := 0; i < instances; i++ {
+		srv := dbtest.NewServer(t, fmt.Sprintf("budget-%d", i))
+		srv.Handle("SELECT n FROM big", dbtest.Response{
+			Columns:  []string{"n"},
+			Rows:     dbtest.IntRows(1000),
+			RowDelay: 100 * time.Microsecond,
+		})
+		dsns = append(dsns, srv.DSN())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	budget := NewRunBudget(maxRows, 0, cancel)
+	opts := ExecOptions{Budget: budget}
+
+	var wg sync.WaitGroup
+	results := make([][]QueryResult, instances)
+	for i, dsn := range dsns {
+		wg.Add(1)
+		go func(i int, dsn string) {
+			defer wg.Done()
+			results[i] = RunSQLOnInstanceWithOptions(ctx, dsn, "SELECT n FROM big; SELECT n FROM big", opts)
+		}(i, dsn)
+	}
+	wg.Wait()
+
+	if !budget.Exceeded() {
+		t.Fatal("expected budget to be exceeded")
+	}
+	// Each instance may count at most one row past the limit before noticing
+	if got := budget.Rows(); got > maxRows+instances {
+		t.Errorf("budget counted %d rows, want at most %d", got, maxRows+instances)
+	}
+
+	var returned int
+	for i, instanceResults := range results {
+		if len(instanceResults) != 2 {
+			t.Fatalf("instance %d returned %d results, want 2", i, len(instanceResults))
+		}
+		for _, res := range instanceResults {
+			returned += res.RowCount
+		}
+		// The second statement can never run once the budget is gone
+		second := instanceResults[1]
+		if !second.Skipped || !errors.Is(second.Err, ErrBudgetExceeded) {
+			t.Errorf("instance %d second statement: Skipped = %v, Err = %v", i, second.Skipped, second.Err)
+		}
+	}
+	if returned > maxRows+instances {
+		t.Errorf("instances returned %d rows in total, want at most %d", returned, maxRows+instances)
+	}
+
+	// Instances started after the budget is exhausted never connect
+	late := dbtest.NewServer(t, "budget-late")
+	lateResults := RunSQLOnInstanceWithOptions(ctx, late.DSN(), "SELECT 1", opts)
+	if len(lateResults) != 1 || !lateResults[0].Skipped || !errors.Is(lateResults[0].Err, ErrBudgetExceeded) {
+		t.Errorf("late instance results = %+v, want a single skipped result", lateResults)
+	}
+	if late.Opened() != 0 {
+		t.Errorf("late instance opened %d connections, want 0", late.Opened())
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_BytesReceived(t *testing.T) {
+	useFakeDriver(t)
+
+	srv := dbtest.NewServer(t, "bytes-1")
+	srv.Handle("SELECT name FROM t", dbtest.Response{
+		Columns: []string{"name"},
+		Rows:    [][]driver.Value{{"abc"}, {"de"}, {nil}},
+	})
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), "SELECT name FROM t", ExecOptions{})
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].RowCount != 3 || results[0].BytesReceived != 5 {
+		t.Errorf("RowCount = %d, BytesReceived = %d, want 3 and 5", results[0].RowCount, results[0].BytesReceived)
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_MaxResultBytes(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "max-result-bytes")
+	srv.Handle("SELECT n FROM big", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1000)})
+	srv.Handle("SELECT n FROM small", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(3)})
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		wantErr  string // Error on the big result; "" if it is kept whole
+	}{
+		{name: "unlimited", maxBytes: 0},
+		{name: "at the cap", maxBytes: 8000}, // 1000 rows of one 8-byte value
+		{name: "over the cap", maxBytes: 800, wantErr: "result too large: more than 800 bytes after 101 rows; statement aborted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+				"SELECT n FROM big; SELECT n FROM small", ExecOptions{MaxResultBytes: tt.maxBytes})
+			if len(results) != 2 {
+				t.Fatalf("got %d results, want 2", len(results))
+			}
+
+			big := results[0]
+			if tt.wantErr == "" {
+				if big.Err != nil || big.RowCount != 1000 {
+					t.Errorf("big result = %d rows, error %v; want all 1000 rows", big.RowCount, big.Err)
+				}
+			} else {
+				if !errors.Is(big.Err, ErrResultTooLarge) || big.Err.Error() != tt.wantErr {
+					t.Errorf("big result error = %v, want %q", big.Err, tt.wantErr)
+				}
+				if big.Rows != nil || big.Skipped {
+					t.Errorf("aborted result kept %d rows (skipped %v), want them released", len(big.Rows), big.Skipped)
+				}
+			}
+
+			// The cap applies per statement: the next one still runs
+			if small := results[1]; small.Err != nil || small.RowCount != 3 {
+				t.Errorf("small result = %d rows, error %v; want 3 rows", small.RowCount, small.Err)
+			}
+		})
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_MaxErrorsPerInstance(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "max-errors")
+	broken := dbtest.Response{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")}
+	srv.Handle("SELECT a FROM t", broken)
+	srv.Handle("SELECT b FROM t", broken)
+	srv.Handle("SELECT c FROM t", broken)
+	script := "SELECT a FROM t; SELECT 1; SELECT b FROM t; SELECT c FROM t; SELECT 2"
+
+	tests := []struct {
+		name         string
+		maxErrors    int
+		wantExecuted int // Statements sent before the instance was abandoned
+		wantFailures int // Errors counted when it was, 0 if it was not
+	}{
+		{name: "unlimited", maxErrors: 0, wantExecuted: 5},
+		{name: "first error", maxErrors: 1, wantExecuted: 1, wantFailures: 1},
+		{name: "successes do not count", maxErrors: 2, wantExecuted: 3, wantFailures: 2},
+		{name: "threshold on the last error", maxErrors: 3, wantExecuted: 4, wantFailures: 3},
+		{name: "threshold never reached", maxErrors: 4, wantExecuted: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(srv.Executed())
+			results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), script,
+				ExecOptions{MaxErrorsPerInstance: tt.maxErrors})
+			if len(results) != 5 {
+				t.Fatalf("got %d results, want one per statement", len(results))
+			}
+			if got := len(srv.Executed()) - before; got != tt.wantExecuted {
+				t.Errorf("executed %d statements, want %d", got, tt.wantExecuted)
+			}
+			for i, res := range results {
+				var abandoned *AbandonedError
+				isAbandoned := errors.As(res.Err, &abandoned)
+				if want := i >= tt.wantExecuted; isAbandoned != want || res.Skipped != want {
+					t.Errorf("result %d (%s): skipped %v, error %v; want abandoned %v", i, res.Statement, res.Skipped, res.Err, want)
+					continue
+				}
+				if isAbandoned && abandoned.Errors != tt.wantFailures {
+					t.Errorf("result %d: abandoned after %d errors, want %d", i, abandoned.Errors, tt.wantFailures)
+				}
+			}
+		})
+	}
+}
+
+func TestRunSQLOnSession_MaxErrorsPerInstanceAcrossRuns(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "max-errors-session")
+	srv.Handle("SELECT a FROM t", dbtest.Response{Err: errors.New("Error 1146: Table 'app.t' doesn't exist")})
+	opts := ExecOptions{MaxErrorsPerInstance: 2}
+
+	sess, err := Connect(context.Background(), srv.DSN(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	// The errors of earlier runs on a session, e.g. barrier phases, count too
+	first := RunSQLOnSession(context.Background(), sess, "SELECT a FROM t; SELECT 1", opts)
+	if first[1].Err != nil {
+		t.Fatalf("first run: %v, want the second statement to run", first[1].Err)
+	}
+	second := RunSQLOnSession(context.Background(), sess, "SELECT a FROM t; SELECT 2", opts)
+	if !second[1].Skipped || second[1].Err == nil || second[1].Err.Error() != "instance abandoned after 2 errors" {
+		t.Errorf("second run: skipped %v, error %v; want abandoned after 2 errors", second[1].Skipped, second[1].Err)
+	}
+
+	// A cancelled run neither counts nor reports abandonment
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, res := range RunSQLOnSession(ctx, sess, "SELECT 3", opts) {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("cancelled run: error %v, want the cancellation", res.Err)
+		}
+	}
+}
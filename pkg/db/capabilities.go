@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Capability is an auxiliary query that optional features rely on. Restricted
+// users, or proxies filtering queries, may be denied some of them.
+type Capability string
+
+// Capabilities probed on each connection
+const (
+	CapShowWarnings Capability = "SHOW WARNINGS"      // Reading the warnings of the last statement
+	CapConnectionID Capability = "CONNECTION_ID()"    // Identifying the connection, e.g. to KILL a running statement
+	CapInfoSchema   Capability = "information_schema" // Reading server metadata tables
+	CapVariables    Capability = "@@variables"        // Reading system variables such as @@server_id
+)
+
+// capabilityProbes pairs each capability with the cheapest query that proves it
+var capabilityProbes = []struct {
+	capability Capability
+	query      string
+}{
+	{CapShowWarnings, "SHOW WARNINGS LIMIT 0"},
+	{CapConnectionID, "SELECT CONNECTION_ID()"},
+	{CapInfoSchema, "SELECT 1 FROM information_schema.SCHEMATA LIMIT 1"},
+	{CapVariables, "SELECT @@version"},
+}
+
+// Capabilities records which auxiliary queries an instance permits
+type Capabilities struct {
+	Probed      bool                 // The probe ran; until then every capability is assumed available
+	Unavailable map[Capability]error // Denied capabilities, with the error their probe got
+}
+
+// Has reports whether a capability is available
+func (c Capabilities) Has(capability Capability) bool {
+	_, denied := c.Unavailable[capability]
+	return !denied
+}
+
+// String lists the unavailable capabilities with the reason each was denied
+func (c Capabilities) String() string {
+	var parts []string
+	for _, probe := range capabilityProbes {
+		if err, denied := c.Unavailable[probe.capability]; denied {
+			parts = append(parts, fmt.Sprintf("%s (%v)", probe.capability, err))
+		}
+	}
+	if len(parts) == 0 {
+		return "all available"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// InstanceInfo describes a connected instance
+type InstanceInfo struct {
+	Instance     string        // The instance DSN as configured
+	Handshake    time.Duration // Time taken to open and verify the connection
+	Capabilities Capabilities  // Auxiliary queries the connection may run
+}
+
+// Info describes the session's instance, probing its capabilities on first use
+func (s *Session) Info(ctx context.Context) InstanceInfo {
+	return InstanceInfo{
+		Instance:     s.Instance,
+		Handshake:    s.Handshake,
+		Capabilities: s.capabilities(ctx, ExecOptions{}),
+	}
+}
+
+// capabilities probes the connection the first time a feature asks and caches the
+// outcome for the life of the session. Unavailable capabilities are reported once,
+// from verbosity 2.
+func (s *Session) capabilities(ctx context.Context, opts ExecOptions) Capabilities {
+	if !s.caps.Probed {
+		s.caps = probeCapabilities(ctx, s)
+	}
+	if opts.Verbose >= 2 && s.caps.Probed && len(s.caps.Unavailable) > 0 && !s.capsReported {
+		opts.output().Printf(StreamDiagnostics, "[%s] capabilities unavailable, dependent features disabled: %s\n",
+			maskPasswordInDSN(s.Instance), s.caps)
+		s.capsReported = true
+	}
+	return s.caps
+}
+
+// probeCapabilities runs every probe query on the session. A probe interrupted by
+// ctx proves nothing, so the result is then left unprobed to be retried.
+func probeCapabilities(ctx context.Context, s *Session) Capabilities {
+	caps := Capabilities{Unavailable: make(map[Capability]error)}
+	for _, probe := range capabilityProbes {
+		rows, err := s.conn.QueryContext(ctx, probe.query)
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Close()
+		}
+		if ctx.Err() != nil {
+			return Capabilities{}
+		}
+		if err != nil {
+			caps.Unavailable[probe.capability] = err
+		}
+	}
+	caps.Probed = true
+	return caps
+}
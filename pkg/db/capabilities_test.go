@@ -0,0 +1,104 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+// restrictedServer is a fake instance whose user may not read warnings or system variables
+func restrictedServer(t *testing.T, host string) *dbtest.Server {
+	t.Helper()
+	srv := dbtest.NewServer(t, host)
+	srv.Handle("SHOW WARNINGS LIMIT 0", dbtest.Response{Err: &mysql.MySQLError{Number: 1227, Message: "Access denied"}})
+	srv.Handle("SELECT @@version", dbtest.Response{Err: &mysql.MySQLError{Number: 1142, Message: "query blocked by proxy rule"}})
+	return srv
+}
+
+func TestSession_Info_Capabilities(t *testing.T) {
+	useFakeDriver(t)
+	srv := restrictedServer(t, "caps-info")
+
+	sess, err := Connect(context.Background(), srv.DSN(), ExecOptions{})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer sess.Close()
+
+	info := sess.Info(context.Background())
+	if info.Instance != srv.DSN() || !info.Capabilities.Probed {
+		t.Fatalf("Info() = %+v, want the probed instance", info)
+	}
+	tests := []struct {
+		capability Capability
+		want       bool
+	}{
+		{CapShowWarnings, false},
+		{CapConnectionID, true},
+		{CapInfoSchema, true},
+		{CapVariables, false},
+	}
+	for _, tt := range tests {
+		if got := info.Capabilities.Has(tt.capability); got != tt.want {
+			t.Errorf("Has(%s) = %t, want %t", tt.capability, got, tt.want)
+		}
+	}
+	if want := "SHOW WARNINGS (Error 1227: Access denied), @@variables (Error 1142: query blocked by proxy rule)"; info.Capabilities.String() != want {
+		t.Errorf("String() = %q, want %q", info.Capabilities.String(), want)
+	}
+
+	// The probe is cached for the session
+	sess.Info(context.Background())
+	if got := len(srv.Executed()); got != len(capabilityProbes) {
+		t.Errorf("executed %d queries over two Info calls, want the %d probes once", got, len(capabilityProbes))
+	}
+}
+
+func TestRunSQLOnSession_DeniedCapabilityDisablesFeature(t *testing.T) {
+	useFakeDriver(t)
+
+	tests := []struct {
+		name       string
+		verbose    int
+		wantLogged int
+	}{
+		{name: "quiet", verbose: 0, wantLogged: 0},
+		{name: "logged once at -vv", verbose: 2, wantLogged: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := restrictedServer(t, "caps-run")
+			var stderr bytes.Buffer
+			opts := ExecOptions{FailoverAware: true, Verbose: tt.verbose, Output: NewOutputSink(&bytes.Buffer{}, &stderr)}
+
+			pool := NewInstancePool()
+			defer pool.Close()
+			for i := 0; i < 2; i++ {
+				if results := pool.Run(context.Background(), srv.DSN(), "SELECT 1", opts); results[0].Err != nil {
+					t.Fatalf("Run() error = %v", results[0].Err)
+				}
+			}
+
+			if info, ok := pool.Info(context.Background(), srv.DSN()); !ok || info.Capabilities.Has(CapVariables) {
+				t.Errorf("pool.Info() = %+v, %t; want system variables unavailable", info, ok)
+			}
+
+			// --failover-aware normally reads @@server_id; the restricted user is not asked
+			for _, query := range srv.Executed() {
+				if query == "SELECT @@server_id" {
+					t.Errorf("read @@server_id although system variables are unavailable")
+				}
+			}
+			if got := strings.Count(stderr.String(), "capabilities unavailable"); got != tt.wantLogged {
+				t.Errorf("capabilities logged %d times, want %d; stderr:\n%s", got, tt.wantLogged, stderr.String())
+			}
+			if tt.wantLogged > 0 && !strings.Contains(stderr.String(), "@@variables (Error 1142") {
+				t.Errorf("log does not name the unavailable capability:\n%s", stderr.String())
+			}
+		})
+	}
+}
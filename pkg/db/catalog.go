@@ -0,0 +1,102 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language of the built-in Msg* texts
+const DefaultLanguage = "en"
+
+var (
+	catalogMu sync.RWMutex
+	// catalogs holds the translated result messages per language code
+	catalogs = map[string]Messages{
+		DefaultLanguage: {},
+		"de": {
+			EmptySet:  "Leere Menge.",
+			NoColumns: "Anweisung erfolgreich ausgeführt, keine Spalten zurückgegeben.",
+			QueryOK:   "Abfrage OK",
+			RowsInSet: "{rows} Zeilen im Ergebnis",
+			Error:     "FEHLER",
+			Skipped:   "ÜBERSPRUNGEN",
+
+			RowsOmitted: "({rows} weitere Zeilen ausgelassen)",
+		},
+		"es": {
+			EmptySet:  "Conjunto vacío.",
+			NoColumns: "Sentencia ejecutada correctamente, no se devolvieron columnas.",
+			QueryOK:   "Consulta OK",
+			RowsInSet: "{rows} filas en el conjunto",
+			Error:     "ERROR",
+			Skipped:   "OMITIDA",
+
+			RowsOmitted: "({rows} filas más omitidas)",
+		},
+		"fr": {
+			EmptySet:  "Ensemble vide.",
+			NoColumns: "Instruction exécutée avec succès, aucune colonne renvoyée.",
+			QueryOK:   "Requête OK",
+			RowsInSet: "{rows} lignes dans l'ensemble",
+			Error:     "ERREUR",
+			Skipped:   "IGNORÉE",
+
+			RowsOmitted: "({rows} lignes supplémentaires omises)",
+		},
+	}
+)
+
+// RegisterCatalog adds or replaces the messages for a language. Texts left empty
+// fall back to English.
+func RegisterCatalog(lang string, msgs Messages) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	catalogs[normalizeLanguage(lang)] = msgs
+}
+
+// Languages returns the language codes with a catalog, sorted
+func Languages() []string {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// MessagesFor returns the messages for a language or locale name such as "de",
+// "de_DE" or "de_DE.UTF-8". The bool is false, and English is returned, when no
+// catalog matches.
+func MessagesFor(lang string) (Messages, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	msgs, ok := catalogs[normalizeLanguage(lang)]
+	return msgs, ok
+}
+
+// normalizeLanguage reduces a locale name to its lowercase language code, mapping
+// the C and POSIX locales to English
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" || lang == "c" || lang == "posix" {
+		return DefaultLanguage
+	}
+	return lang
+}
+
+// LanguageFromEnv picks the message language from the environment the way POSIX
+// locales do: LC_ALL, then LC_MESSAGES, then LANG. getenv is usually os.Getenv.
+func LanguageFromEnv(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := getenv(name); v != "" {
+			return v
+		}
+	}
+	return DefaultLanguage
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestMessagesFor(t *testing.T) {
+	tests := []struct {
+		lang      string
+		wantEmpty string
+		wantOK    bool
+	}{
+		{lang: "", wantEmpty: MsgEmptySet, wantOK: true},
+		{lang: "en_US.UTF-8", wantEmpty: MsgEmptySet, wantOK: true},
+		{lang: "C", wantEmpty: MsgEmptySet, wantOK: true},
+		{lang: "POSIX", wantEmpty: MsgEmptySet, wantOK: true},
+		{lang: "de", wantEmpty: "Leere Menge.", wantOK: true},
+		{lang: "de_DE.UTF-8", wantEmpty: "Leere Menge.", wantOK: true},
+		{lang: "es-MX", wantEmpty: "Conjunto vacío.", wantOK: true},
+		{lang: "FR_ca@euro", wantEmpty: "Ensemble vide.", wantOK: true},
+		{lang: "tlh", wantEmpty: MsgEmptySet, wantOK: false},
+	}
+	for _, tt := range tests {
+		msgs, ok := MessagesFor(tt.lang)
+		if got := msgs.withDefaults().EmptySet; got != tt.wantEmpty || ok != tt.wantOK {
+			t.Errorf("MessagesFor(%q) = %q, %t; want %q, %t", tt.lang, got, ok, tt.wantEmpty, tt.wantOK)
+		}
+	}
+}
+
+func TestLanguageFromEnv(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{env: map[string]string{}, want: DefaultLanguage},
+		{env: map[string]string{"LANG": "de_DE.UTF-8"}, want: "de_DE.UTF-8"},
+		{env: map[string]string{"LANG": "de_DE.UTF-8", "LC_MESSAGES": "fr_FR"}, want: "fr_FR"},
+		{env: map[string]string{"LANG": "de_DE.UTF-8", "LC_MESSAGES": "fr_FR", "LC_ALL": "es_ES"}, want: "es_ES"},
+	}
+	for _, tt := range tests {
+		if got := LanguageFromEnv(func(name string) string { return tt.env[name] }); got != tt.want {
+			t.Errorf("LanguageFromEnv(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterCatalog_TranslatedOutput(t *testing.T) {
+	originalNoColor := color.NoColor
+	t.Cleanup(func() {
+		color.NoColor = originalNoColor
+		catalogMu.Lock()
+		delete(catalogs, "pl")
+		catalogMu.Unlock()
+	})
+	color.NoColor = true
+
+	// Texts left out of a catalog fall back to English
+	RegisterCatalog("pl_PL", Messages{EmptySet: "Pusty zbiór.", RowsInSet: "wierszy: {rows}", Error: "BŁĄD"})
+	msgs, ok := MessagesFor("pl")
+	if !ok {
+		t.Fatal("registered catalog not found")
+	}
+
+	tests := []struct {
+		name string
+		res  QueryResult
+		want []string
+	}{
+		{
+			name: "empty set",
+			res:  QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT a FROM t", Columns: []string{"a"}},
+			want: []string{"Pusty zbiór.", "(wierszy: 0)"},
+		},
+		{
+			name: "no result set falls back to English",
+			res:  QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "SET @a = 1"},
+			want: []string{MsgNoColumns, MsgQueryOK},
+		},
+		{
+			name: "error label",
+			res:  QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT x", Err: errors.New("unknown column")},
+			want: []string{"BŁĄD SELECT x: unknown column"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			RenderResult(&buf, tt.res, nil, PrintOptions{Verbose: 2, Messages: msgs})
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+
+	if langs := strings.Join(Languages(), ","); !strings.Contains(langs, "pl") || !strings.Contains(langs, "de") {
+		t.Errorf("Languages() = %s, want the built-in and registered catalogs", langs)
+	}
+}
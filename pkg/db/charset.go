@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CharsetVariables are the session variables that decide how text is encoded
+// between the client and the server, in the order they are reported
+var CharsetVariables = []string{"character_set_client", "character_set_connection", "character_set_results", "collation_connection"}
+
+// charsetQuery reads CharsetVariables in one round trip
+const charsetQuery = "SELECT @@character_set_client, @@character_set_connection, @@character_set_results, @@collation_connection"
+
+// ErrCharsetUnavailable is the reason an instance that does not expose session
+// variables, such as a ProxySQL admin interface, is left out of the charset check
+var ErrCharsetUnavailable = errors.New("session variables are not available")
+
+// SessionCharset is the character set state of an instance's session
+type SessionCharset struct {
+	Instance string
+	Values   []string // Parallel to CharsetVariables; NULL reads as ""
+	Err      error    // Why the variables could not be read; the instance is then not compared
+}
+
+// Charset reads the character set variables of an instance's session, connecting
+// first if needed, so they are those of the session its statements will run on
+func (p *InstancePool) Charset(ctx context.Context, instanceDSN string, opts ExecOptions) SessionCharset {
+	sess, failure, ok := p.open(ctx, instanceDSN, opts)
+	if !ok {
+		return SessionCharset{Instance: instanceDSN, Err: failure.Err}
+	}
+	if !sess.capabilities(ctx, opts).Has(CapVariables) {
+		return SessionCharset{Instance: instanceDSN, Err: ErrCharsetUnavailable}
+	}
+
+	values := make([]sql.NullString, len(CharsetVariables))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := sess.conn.QueryRowContext(ctx, charsetQuery).Scan(dest...); err != nil {
+		return SessionCharset{Instance: instanceDSN, Err: err}
+	}
+	charset := SessionCharset{Instance: instanceDSN, Values: make([]string, len(values))}
+	for i, v := range values {
+		charset.Values[i] = v.String
+	}
+	return charset
+}
+
+// CharsetMismatch is a character set variable of an instance that differs from
+// the expected character set or, without one, from the rest of the fleet
+type CharsetMismatch struct {
+	Instance string
+	Variable string
+	Got      string
+	Want     string
+	Majority bool // Want is the value most instances have, not the expected one
+}
+
+func (m CharsetMismatch) String() string {
+	source := "expected"
+	if m.Majority {
+		source = "most instances have"
+	}
+	return fmt.Sprintf("%s=%s (%s %s)", m.Variable, m.Got, source, m.Want)
+}
+
+// normalizeCharset spells utf8 as utf8mb3, as MySQL 8.0.30 and later report it,
+// in a character set or collation name
+func normalizeCharset(name string) string {
+	if name == "utf8" || strings.HasPrefix(name, "utf8_") {
+		return "utf8mb3" + strings.TrimPrefix(name, "utf8")
+	}
+	return name
+}
+
+// matchesCharset reports whether a variable's value agrees with the expected
+// character set: character_set_* must be it and the collation must belong to it
+func matchesCharset(variable, value, expect string) bool {
+	value, expect = normalizeCharset(value), normalizeCharset(expect)
+	if variable == "collation_connection" {
+		return strings.HasPrefix(value, expect+"_")
+	}
+	return value == expect
+}
+
+// majorityValue returns the most common value of a variable among the instances
+// whose variables were read; a tie goes to the value seen first
+func majorityValue(charsets []SessionCharset, variable int) string {
+	counts := make(map[string]int)
+	majority := ""
+	for _, cs := range charsets {
+		if cs.Err != nil {
+			continue
+		}
+		v := cs.Values[variable]
+		counts[v]++
+		if counts[v] > counts[majority] || len(counts) == 1 {
+			majority = v
+		}
+	}
+	return majority
+}
+
+// CompareCharsets returns the variables of each instance that disagree with the
+// expected character set, when one is given, or with the majority of the fleet.
+// A variable that matches the expected character set is still compared with the
+// fleet, which catches e.g. utf8mb4_general_ci among utf8mb4_0900_ai_ci sessions.
+// Instances whose variables could not be read are left out.
+func CompareCharsets(charsets []SessionCharset, expect string) []CharsetMismatch {
+	majorities := make([]string, len(CharsetVariables))
+	for i := range CharsetVariables {
+		majorities[i] = majorityValue(charsets, i)
+	}
+
+	var mismatches []CharsetMismatch
+	for _, cs := range charsets {
+		if cs.Err != nil {
+			continue
+		}
+		for i, variable := range CharsetVariables {
+			got := cs.Values[i]
+			switch {
+			case expect != "" && !matchesCharset(variable, got, expect):
+				want := expect
+				if variable == "collation_connection" {
+					want = expect + "_*"
+				}
+				mismatches = append(mismatches, CharsetMismatch{Instance: cs.Instance, Variable: variable, Got: got, Want: want})
+			case got != majorities[i]:
+				mismatches = append(mismatches, CharsetMismatch{Instance: cs.Instance, Variable: variable, Got: got, Want: majorities[i], Majority: true})
+			}
+		}
+	}
+	return mismatches
+}
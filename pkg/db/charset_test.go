@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestCompareCharsets(t *testing.T) {
+	charset := func(instance, client, connection, results, collation string) SessionCharset {
+		return SessionCharset{Instance: instance, Values: []string{client, connection, results, collation}}
+	}
+	utf8mb4 := func(instance string) SessionCharset {
+		return charset(instance, "utf8mb4", "utf8mb4", "utf8mb4", "utf8mb4_0900_ai_ci")
+	}
+
+	tests := []struct {
+		name     string
+		charsets []SessionCharset
+		expect   string
+		want     []CharsetMismatch
+	}{
+		{
+			name:     "all alike",
+			charsets: []SessionCharset{utf8mb4("db1"), utf8mb4("db2")},
+			expect:   "utf8mb4",
+		},
+		{
+			name: "odd one out",
+			charsets: []SessionCharset{
+				utf8mb4("db1"),
+				charset("db2", "latin1", "latin1", "utf8mb4", "latin1_swedish_ci"),
+				utf8mb4("db3"),
+			},
+			want: []CharsetMismatch{
+				{Instance: "db2", Variable: "character_set_client", Got: "latin1", Want: "utf8mb4", Majority: true},
+				{Instance: "db2", Variable: "character_set_connection", Got: "latin1", Want: "utf8mb4", Majority: true},
+				{Instance: "db2", Variable: "collation_connection", Got: "latin1_swedish_ci", Want: "utf8mb4_0900_ai_ci", Majority: true},
+			},
+		},
+		{
+			name:     "the whole fleet differs from the expected",
+			charsets: []SessionCharset{charset("db1", "utf8mb4", "latin1", "utf8mb4", "latin1_swedish_ci"), charset("db2", "utf8mb4", "latin1", "utf8mb4", "latin1_swedish_ci")},
+			expect:   "utf8mb4",
+			want: []CharsetMismatch{
+				{Instance: "db1", Variable: "character_set_connection", Got: "latin1", Want: "utf8mb4"},
+				{Instance: "db1", Variable: "collation_connection", Got: "latin1_swedish_ci", Want: "utf8mb4_*"},
+				{Instance: "db2", Variable: "character_set_connection", Got: "latin1", Want: "utf8mb4"},
+				{Instance: "db2", Variable: "collation_connection", Got: "latin1_swedish_ci", Want: "utf8mb4_*"},
+			},
+		},
+		{
+			name:     "expected character set in another collation",
+			charsets: []SessionCharset{utf8mb4("db1"), charset("db2", "utf8mb4", "utf8mb4", "utf8mb4", "utf8mb4_general_ci"), utf8mb4("db3")},
+			expect:   "utf8mb4",
+			want:     []CharsetMismatch{{Instance: "db2", Variable: "collation_connection", Got: "utf8mb4_general_ci", Want: "utf8mb4_0900_ai_ci", Majority: true}},
+		},
+		{
+			name:     "utf8 is utf8mb3",
+			charsets: []SessionCharset{charset("db1", "utf8mb3", "utf8mb3", "utf8mb3", "utf8mb3_general_ci")},
+			expect:   "utf8",
+		},
+		{
+			name:     "unreadable instances are left out",
+			charsets: []SessionCharset{{Instance: "proxysql", Err: ErrCharsetUnavailable}, utf8mb4("db1")},
+			expect:   "utf8mb4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareCharsets(tt.charsets, tt.expect)
+			if len(got) != len(tt.want) {
+				t.Fatalf("CompareCharsets() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("mismatch %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInstancePool_Charset(t *testing.T) {
+	useFakeDriver(t)
+	mysql := dbtest.NewServer(t, "charset-mysql")
+	mysql.Handle(charsetQuery, dbtest.Response{
+		Columns: CharsetVariables,
+		Rows:    [][]driver.Value{{"utf8mb4", "utf8mb4", nil, "utf8mb4_0900_ai_ci"}},
+	})
+	proxy := dbtest.NewServer(t, "charset-proxy")
+	proxy.Handle("SELECT @@version", dbtest.Response{Err: errors.New("Error 1045: ProxySQL Admin Error: no such column")})
+
+	pool := NewInstancePool()
+	defer pool.Close()
+	ctx := context.Background()
+
+	got := pool.Charset(ctx, mysql.DSN(), ExecOptions{})
+	if got.Err != nil {
+		t.Fatalf("Charset() error = %v", got.Err)
+	}
+	want := []string{"utf8mb4", "utf8mb4", "", "utf8mb4_0900_ai_ci"}
+	for i := range want {
+		if got.Values[i] != want[i] {
+			t.Errorf("%s = %q, want %q", CharsetVariables[i], got.Values[i], want[i])
+		}
+	}
+
+	if got := pool.Charset(ctx, proxy.DSN(), ExecOptions{}); !errors.Is(got.Err, ErrCharsetUnavailable) {
+		t.Errorf("Charset() on a proxy error = %v, want ErrCharsetUnavailable", got.Err)
+	}
+	for _, query := range proxy.Executed() {
+		if query == charsetQuery {
+			t.Errorf("the charset query ran on an instance without session variables")
+		}
+	}
+
+	// The statements run on the session that was checked
+	pool.Run(ctx, mysql.DSN(), "SELECT 1", ExecOptions{})
+	if opened := mysql.Opened(); opened != 1 {
+		t.Errorf("opened %d connection(s), want the checked session reused", opened)
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// statementKeywords returns up to n leading keywords of a statement, upper-cased,
+// past comments, opening parentheses and the opening of an executable comment, so
+// mysqldump's /*!40000 DROP DATABASE IF EXISTS `app`*/ reads as DROP DATABASE
+func statementKeywords(stmt string, n int) []string {
+	rest := stripSQLComments(stmt)
+	var keywords []string
+	for len(keywords) < n {
+		rest = strings.TrimLeftFunc(rest, func(r rune) bool { return r == '(' || unicode.IsSpace(r) })
+		if strings.HasPrefix(rest, "/*!") {
+			rest = strings.TrimLeftFunc(rest[len("/*!"):], unicode.IsDigit) // Skip the version
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) && r != '_' })
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			break
+		}
+		keywords = append(keywords, strings.ToUpper(rest[:end]))
+		rest = rest[end:]
+	}
+	return keywords
+}
+
+// IsDropDatabase reports whether a statement drops a database, spelled DROP
+// DATABASE or DROP SCHEMA
+func IsDropDatabase(stmt string) bool {
+	keywords := statementKeywords(stmt, 2)
+	return len(keywords) == 2 && keywords[0] == "DROP" && (keywords[1] == "DATABASE" || keywords[1] == "SCHEMA")
+}
+
+// IsUnsafeUpdate reports whether a statement is an UPDATE or DELETE without a
+// WHERE or LIMIT clause, which changes every row of its tables. Clauses are only
+// looked for outside strings, comments and parentheses, so the WHERE of a subquery
+// does not count.
+func IsUnsafeUpdate(stmt string) bool {
+	keywords := statementKeywords(stmt, 1)
+	if len(keywords) != 1 || (keywords[0] != "UPDATE" && keywords[0] != "DELETE") {
+		return false
+	}
+	depth := 0
+	for _, tok := range tokenizeSQL(stmt) {
+		switch tok.Kind {
+		case tokenText:
+			depth += strings.Count(tok.Text, "(") - strings.Count(tok.Text, ")")
+		case tokenKeyword:
+			if word := strings.ToUpper(tok.Text); depth == 0 && (word == "WHERE" || word == "LIMIT") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// FindDropDatabase returns the statements of sqls that drop a database
+func FindDropDatabase(sqls string, term Terminator) []StatementInfo {
+	return findStatements(sqls, term, IsDropDatabase)
+}
+
+// FindUnsafeUpdates returns the statements of sqls that update or delete without
+// a WHERE or LIMIT clause
+func FindUnsafeUpdates(sqls string, term Terminator) []StatementInfo {
+	return findStatements(sqls, term, IsUnsafeUpdate)
+}
+
+// findStatements returns the statements of sqls that match
+func findStatements(sqls string, term Terminator, match func(stmt string) bool) []StatementInfo {
+	var found []StatementInfo
+	for _, stmt := range term.Split(sqls) {
+		if match(stmt.SQL) {
+			found = append(found, stmt)
+		}
+	}
+	return found
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsDropDatabase(t *testing.T) {
+	tests := map[string]bool{
+		"DROP DATABASE app":                        true,
+		"drop schema if exists app":                true,
+		"DROP/**/DATABASE app":                     true,
+		"-- cleanup\nDROP\n  DATABASE `app`":       true,
+		"/*!40000 DROP DATABASE IF EXISTS `app`*/": true,
+		"DROP TABLE app.databases":                 false,
+		"DROP SCHEMAS":                             false,
+		"SELECT 'DROP DATABASE app'":               false,
+		"/* DROP DATABASE app */ SELECT 1":         false,
+		"CREATE DATABASE app":                      false,
+		"DROP":                                     false,
+		"":                                         false,
+	}
+	for stmt, want := range tests {
+		if got := IsDropDatabase(stmt); got != want {
+			t.Errorf("IsDropDatabase(%q) = %v, want %v", stmt, got, want)
+		}
+	}
+}
+
+func TestFindDropDatabase(t *testing.T) {
+	sqls := "CREATE TABLE t (id INT);\nDROP DATABASE old_app;\nSELECT 'DROP SCHEMA x';\n\nDrop Schema tmp;"
+	var got []int
+	for _, stmt := range FindDropDatabase(sqls, Terminator{}) {
+		got = append(got, stmt.Line)
+	}
+	if want := []int{2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindDropDatabase() lines = %v, want %v", got, want)
+	}
+
+	// A /* inside a -- comment opens nothing, so the DROP is a statement of its own
+	hidden := "SELECT 1; -- see /* notes\nDROP DATABASE app;\nSELECT 3;"
+	if drops := FindDropDatabase(hidden, Terminator{}); len(drops) != 1 || drops[0].Line != 2 {
+		t.Errorf("FindDropDatabase(%q) = %+v, want the DROP on line 2", hidden, drops)
+	}
+
+	lineMode := Terminator{Mode: TerminatorLine, Token: "GO"}
+	if drops := FindDropDatabase("SELECT 1\nGO\nDROP DATABASE app\nGO\n", lineMode); len(drops) != 1 {
+		t.Errorf("FindDropDatabase() with line terminator = %+v, want one statement", drops)
+	}
+}
+
+func TestIsUnsafeUpdate(t *testing.T) {
+	tests := map[string]bool{
+		"UPDATE users SET active = 0":                                        true,
+		"DELETE FROM sessions":                                               true,
+		"delete from sessions -- WHERE expired":                              true,
+		"UPDATE t SET note = 'WHERE id = 1'":                                 true,
+		"UPDATE t SET c = (SELECT MAX(c) FROM s WHERE s.id = 1)":             true,
+		"DELETE t1 FROM t1 JOIN t2 ON t1.id = t2.id":                         true,
+		"/* nightly */ UPDATE users SET active = 0":                          true,
+		"UPDATE users SET active = 0 WHERE id = 7":                           false,
+		"update users set active = 0\n  where id = 7":                        false,
+		"DELETE FROM sessions /* oldest first */ ORDER BY created LIMIT 100": false,
+		"UPDATE t SET c = (SELECT 1) WHERE id IN (SELECT id FROM s)":         false,
+		"DELETE t1 FROM t1 JOIN t2 ON t1.id = t2.id WHERE t2.gone = 1":       false,
+		"SELECT * FROM users":                                                false,
+		"INSERT INTO t SELECT * FROM s":                                      false,
+		"":                                                                   false,
+	}
+	for stmt, want := range tests {
+		if got := IsUnsafeUpdate(stmt); got != want {
+			t.Errorf("IsUnsafeUpdate(%q) = %v, want %v", stmt, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import "strings"
+
+// stripStatementComments rewrites each statement's SQL without comments, keeping the
+// original text for display. Statements that consisted only of comments are dropped.
+func stripStatementComments(statements []StatementInfo) []StatementInfo {
+	var out []StatementInfo
+	for _, stmt := range statements {
+		stripped := stripSQLComments(stmt.SQL)
+		if stripped == "" {
+			continue
+		}
+		if stripped != stmt.SQL {
+			stmt.Display = stmt.SQL
+			stmt.SQL = stripped
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// stripSQLComments removes -- and /* */ comments from a statement using the same quote
+// rules as the splitter. Optimizer hints (/*+ ... */) and MySQL executable comments
+// (/*! ... */) change how a statement runs, so they are always preserved.
+func stripSQLComments(stmt string) string {
+	var b strings.Builder
+	var inSingleQuote, inDoubleQuote, inBacktick bool
+
+	runes := []rune(stmt)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		inQuote := inSingleQuote || inDoubleQuote || inBacktick
+
+		// Handle escape sequences in strings
+		if inQuote && r == '\\' && i+1 < len(runes) {
+			b.WriteRune(r)
+			i++
+			b.WriteRune(runes[i])
+			continue
+		}
+
+		if !inQuote {
+			// Line comment: drop everything up to (but not including) the newline
+			if r == '-' && i+1 < len(runes) && runes[i+1] == '-' {
+				for i+1 < len(runes) && runes[i+1] != '\n' && runes[i+1] != '\r' {
+					i++
+				}
+				continue
+			}
+			// Block comment: keep hints and executable comments verbatim
+			if r == '/' && i+1 < len(runes) && runes[i+1] == '*' {
+				commentEnd := len(runes) // Unterminated comments run to the end
+				for j := i + 2; j+1 < len(runes); j++ {
+					if runes[j] == '*' && runes[j+1] == '/' {
+						commentEnd = j + 2
+						break
+					}
+				}
+				comment := string(runes[i:commentEnd])
+				if strings.HasPrefix(comment, "/*+") || strings.HasPrefix(comment, "/*!") {
+					b.WriteString(comment)
+				} else {
+					b.WriteRune(' ') // Keep surrounding tokens apart
+				}
+				i = commentEnd - 1
+				continue
+			}
+		}
+
+		switch r {
+		case '\'':
+			if !inDoubleQuote && !inBacktick {
+				inSingleQuote = !inSingleQuote
+			}
+		case '"':
+			if !inSingleQuote && !inBacktick {
+				inDoubleQuote = !inDoubleQuote
+			}
+		case '`':
+			if !inSingleQuote && !inDoubleQuote {
+				inBacktick = !inBacktick
+			}
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.TrimSpace(b.String())
+}
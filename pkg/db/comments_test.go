@@ -0,0 +1,57 @@
+package db
+
+import "testing"
+
+func TestStripSQLComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "no comments", input: "SELECT 1", want: "SELECT 1"},
+		{name: "leading line comment", input: "-- find users\nSELECT * FROM users", want: "SELECT * FROM users"},
+		{name: "trailing line comment", input: "SELECT 1 -- one", want: "SELECT 1"},
+		{name: "block comment", input: "SELECT /* all */ * FROM t", want: "SELECT   * FROM t"},
+		{name: "block comment between tokens", input: "SELECT/*x*/1", want: "SELECT 1"},
+		{name: "multi-line block comment", input: "/*\n header\n*/\nSELECT 1", want: "SELECT 1"},
+		{name: "optimizer hint preserved", input: "SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM t", want: "SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM t"},
+		{name: "hint kept regular comment dropped", input: "SELECT /*+ NO_INDEX(t) */ /* why */ a FROM t -- note", want: "SELECT /*+ NO_INDEX(t) */   a FROM t"},
+		{name: "executable comment preserved", input: "CREATE TABLE t (a INT) /*!50100 PARTITION BY HASH(a) */", want: "CREATE TABLE t (a INT) /*!50100 PARTITION BY HASH(a) */"},
+		{name: "comment markers in single quotes", input: "SELECT '-- not a comment', '/* nor this */'", want: "SELECT '-- not a comment', '/* nor this */'"},
+		{name: "comment markers in double quotes", input: `SELECT "a -- b"`, want: `SELECT "a -- b"`},
+		{name: "comment markers in backticks", input: "SELECT `a/*b*/` FROM t", want: "SELECT `a/*b*/` FROM t"},
+		{name: "escaped quote in string", input: `SELECT 'it\'s -- here' -- gone`, want: `SELECT 'it\'s -- here'`},
+		{name: "only comments", input: "-- nothing\n/* at all */", want: ""},
+		{name: "unterminated block comment", input: "SELECT 1 /* oops", want: "SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripSQLComments(tt.input); got != tt.want {
+				t.Errorf("stripSQLComments(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripStatementComments(t *testing.T) {
+	statements := splitSQLStatements("/* header only */;\nSELECT /* a */ 1; SELECT /*+ BKA(t) */ 2\\G; SELECT 3;")
+	got := stripStatementComments(statements)
+
+	want := []StatementInfo{
+		{SQL: "SELECT   1", Display: "SELECT /* a */ 1", Line: 2},
+		{SQL: "SELECT /*+ BKA(t) */ 2", Vertical: true, Line: 2},
+		{SQL: "SELECT 3", Line: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("stripStatementComments() returned %d statements, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got[0].displaySQL() != "SELECT /* a */ 1" {
+		t.Errorf("displaySQL() = %q, want the statement as written", got[0].displaySQL())
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers of a server that is out of connection slots
+const (
+	errTooManyConnections     = 1040 // ER_CON_COUNT_ERROR (max_connections)
+	errTooManyUserConnections = 1203 // ER_TOO_MANY_USER_CONNECTIONS (max_user_connections)
+)
+
+// Backoff between connection attempts refused for too many connections; tests
+// shorten it
+var (
+	connectBackoffBase = 500 * time.Millisecond
+	connectBackoffMax  = 8 * time.Second
+)
+
+// isTooManyConnections reports whether err is a server refusing a connection
+// because all its connections, or all those of the user, are taken
+func isTooManyConnections(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) &&
+		(mysqlErr.Number == errTooManyConnections || mysqlErr.Number == errTooManyUserConnections)
+}
+
+// connectBackoff returns how long to wait before retry number attempt (from 0):
+// doubling from connectBackoffBase up to connectBackoffMax, with up to half of it
+// random so instances refused at once do not all come back at once
+func connectBackoff(attempt int) time.Duration {
+	delay := connectBackoffMax
+	if attempt < 16 {
+		delay = min(connectBackoffBase<<attempt, connectBackoffMax)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// openConn takes a connection from db and pings it. While the server refuses it
+// for too many connections, it waits and tries again, up to opts.ConnectRetries
+// times; any other error is returned at once. Each attempt is given
+// opts.ConnectTimeout, if set, and fails with ErrConnectTimeout once it is up.
+func openConn(ctx context.Context, db *sql.DB, instanceDSN string, opts ExecOptions) (*sql.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := connectAttempt(ctx, db, opts.ConnectTimeout)
+		if err == nil {
+			return conn, nil
+		}
+		if attempt >= opts.ConnectRetries || !isTooManyConnections(err) {
+			return nil, err
+		}
+
+		delay := connectBackoff(attempt)
+		opts.output().Printf(StreamDiagnostics, "[%s] %v; retrying in %v (%d of %d)\n",
+			maskPasswordInDSN(instanceDSN), err, delay.Round(time.Millisecond), attempt+1, opts.ConnectRetries)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// connectAttempt takes a connection from db and pings it within timeout (0 = no
+// limit beyond ctx)
+func connectAttempt(ctx context.Context, db *sql.DB, timeout time.Duration) (*sql.Conn, error) {
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn, err := db.Conn(attemptCtx)
+	if err == nil {
+		if err = conn.PingContext(attemptCtx); err == nil {
+			return conn, nil
+		}
+		conn.Close()
+	}
+	if timeout > 0 && isConnectTimeout(ctx, attemptCtx, err) {
+		return nil, connectTimeoutError(timeout, err)
+	}
+	return nil, err
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+// shortConnectBackoff makes connection retries quick for the test's duration
+func shortConnectBackoff(t *testing.T) {
+	t.Helper()
+	base, max := connectBackoffBase, connectBackoffMax
+	connectBackoffBase, connectBackoffMax = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { connectBackoffBase, connectBackoffMax = base, max })
+}
+
+var (
+	tooManyConnections     = &mysql.MySQLError{Number: 1040, Message: "Too many connections"}
+	tooManyUserConnections = &mysql.MySQLError{Number: 1203, Message: "User app already has more than 'max_user_connections' active connections"}
+	accessDenied           = &mysql.MySQLError{Number: 1045, Message: "Access denied for user 'app'@'10.0.0.1' (using password: YES)"}
+)
+
+func TestConnect_RetryOnTooManyConnections(t *testing.T) {
+	useFakeDriver(t)
+	shortConnectBackoff(t)
+
+	srv := dbtest.NewServer(t, "busy-1")
+	srv.FailConnectSequence(tooManyConnections, tooManyUserConnections, nil)
+	var diag bytes.Buffer
+	sess, err := Connect(context.Background(), srv.DSN(), ExecOptions{ConnectRetries: 3, Output: NewOutputSink(&bytes.Buffer{}, &diag)})
+	if err != nil {
+		t.Fatalf("Connect() error = %v, want success on the third attempt", err)
+	}
+	sess.Close()
+	if srv.Opened() != 1 {
+		t.Errorf("opened %d connection(s), want 1", srv.Opened())
+	}
+	lines := strings.Split(strings.TrimSpace(diag.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "Error 1040") || !strings.HasSuffix(lines[1], "(2 of 3)") {
+		t.Errorf("diagnostics =\n%s\nwant one line per retry", diag.String())
+	}
+	if strings.Contains(diag.String(), "secret") {
+		t.Errorf("diagnostics expose the password:\n%s", diag.String())
+	}
+}
+
+func TestConnect_RetryOnTooManyConnectionsGivesUp(t *testing.T) {
+	useFakeDriver(t)
+	shortConnectBackoff(t)
+	quiet := NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})
+
+	tests := []struct {
+		name    string
+		errs    []error
+		retries int
+		wantErr string
+	}{
+		{name: "retries disabled", errs: []error{tooManyConnections, nil}, wantErr: "Error 1040"},
+		{name: "retries exhausted", errs: []error{tooManyConnections, tooManyConnections, tooManyConnections, nil}, retries: 2, wantErr: "Error 1040"},
+		{name: "other errors are not retried", errs: []error{accessDenied, nil}, retries: 3, wantErr: "Error 1045"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := dbtest.NewServer(t, "busy-gives-up-"+string(rune('a'+i)))
+			srv.FailConnectSequence(tt.errs...)
+			_, err := Connect(context.Background(), srv.DSN(), ExecOptions{ConnectRetries: tt.retries, Output: quiet})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Connect() error = %v, want %q", err, tt.wantErr)
+			}
+			if srv.Opened() != 0 {
+				t.Errorf("opened %d connection(s), want none", srv.Opened())
+			}
+		})
+	}
+}
+
+func TestConnect_RetryOnTooManyConnectionsCancelled(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "busy-cancelled")
+	srv.FailConnect(tooManyConnections)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Connect(ctx, srv.DSN(), ExecOptions{ConnectRetries: 5, Output: NewOutputSink(&bytes.Buffer{}, &bytes.Buffer{})})
+	if err == nil || !strings.Contains(err.Error(), "Error 1040") {
+		t.Errorf("Connect() error = %v, want the last refusal", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Connect() took %v, want the backoff cut short by cancellation", elapsed)
+	}
+}
+
+func TestConnectBackoff(t *testing.T) {
+	for attempt, want := range []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if got := connectBackoff(attempt); got < want/2 || got > want {
+				t.Fatalf("connectBackoff(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+			}
+		}
+	}
+	if got := connectBackoff(100); got > connectBackoffMax {
+		t.Errorf("connectBackoff(100) = %v, want at most %v", got, connectBackoffMax)
+	}
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrConnectTimeout is the error of an instance that could not be connected to
+// within ExecOptions.ConnectTimeout, as opposed to a statement that failed
+var ErrConnectTimeout = errors.New("connection timeout")
+
+// withConnectTimeout sets the driver's dial timeout parameter of a DSN, replacing
+// any timeout the DSN has and keeping its other parameters
+func withConnectTimeout(dsn string, timeout time.Duration) string {
+	return setDSNParam(dsn, "timeout", timeout.String())
+}
+
+// withIOTimeouts sets the driver's readTimeout and writeTimeout parameters of a
+// DSN, for those of the timeouts that are set, replacing any the DSN has. They
+// bound each read and write on an established connection, so a server that
+// accepts a connection but stops responding fails the statement instead of
+// hanging it.
+func withIOTimeouts(dsn string, read, write time.Duration) string {
+	if read > 0 {
+		dsn = setDSNParam(dsn, "readTimeout", read.String())
+	}
+	if write > 0 {
+		dsn = setDSNParam(dsn, "writeTimeout", write.String())
+	}
+	return dsn
+}
+
+// setDSNParam sets a parameter in the query string of a DSN, which follows the
+// last slash as in user:pass@tcp(host:3306)/db?param=value. A DSN without a
+// slash gets one, as the driver requires it before parameters.
+func setDSNParam(dsn, key, value string) string {
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return dsn + "/?" + key + "=" + value
+	}
+	q := strings.Index(dsn[slash:], "?")
+	if q < 0 {
+		return dsn + "?" + key + "=" + value
+	}
+	base, query := dsn[:slash+q], dsn[slash+q+1:]
+	var params []string
+	for _, param := range strings.Split(query, "&") {
+		if name, _, _ := strings.Cut(param, "="); param == "" || name == key {
+			continue
+		}
+		params = append(params, param)
+	}
+	params = append(params, key+"="+value)
+	return base + "?" + strings.Join(params, "&")
+}
+
+// isConnectTimeout reports whether connecting failed because it took too long:
+// the dial timed out, or the attempt's deadline passed while the run's context
+// (parent) is still live
+func isConnectTimeout(parent, attempt context.Context, err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return parent.Err() == nil && errors.Is(attempt.Err(), context.DeadlineExceeded)
+}
+
+// connectTimeoutError reports a connection attempt that took longer than timeout
+func connectTimeoutError(timeout time.Duration, err error) error {
+	return fmt.Errorf("%w after %v: %v", ErrConnectTimeout, timeout, err)
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestWithConnectTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{name: "no parameters", dsn: "u:p@tcp(h:3306)/app", want: "u:p@tcp(h:3306)/app?timeout=2s"},
+		{name: "other parameters kept", dsn: "u:p@tcp(h:3306)/app?tls=true&charset=utf8mb4", want: "u:p@tcp(h:3306)/app?tls=true&charset=utf8mb4&timeout=2s"},
+		{name: "existing timeout replaced", dsn: "u:p@tcp(h:3306)/app?timeout=30s&tls=true", want: "u:p@tcp(h:3306)/app?tls=true&timeout=2s"},
+		{name: "empty query string", dsn: "u:p@tcp(h:3306)/app?", want: "u:p@tcp(h:3306)/app?timeout=2s"},
+		{name: "no database", dsn: "u:p@tcp(h:3306)/", want: "u:p@tcp(h:3306)/?timeout=2s"},
+		{name: "no slash", dsn: "u:p@tcp(h:3306)", want: "u:p@tcp(h:3306)/?timeout=2s"},
+		{name: "question mark in password", dsn: "u:p?x@tcp(h:3306)/app", want: "u:p?x@tcp(h:3306)/app?timeout=2s"},
+		{name: "readTimeout is another parameter", dsn: "u:p@tcp(h:3306)/app?readTimeout=5s", want: "u:p@tcp(h:3306)/app?readTimeout=5s&timeout=2s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withConnectTimeout(tt.dsn, 2*time.Second)
+			if got != tt.want {
+				t.Errorf("withConnectTimeout(%q) = %q, want %q", tt.dsn, got, tt.want)
+			}
+			cfg, err := mysql.ParseDSN(got)
+			if err != nil {
+				t.Fatalf("the driver rejects %q: %v", got, err)
+			}
+			if cfg.Timeout != 2*time.Second {
+				t.Errorf("the driver reads timeout %v from %q, want 2s", cfg.Timeout, got)
+			}
+		})
+	}
+}
+
+func TestConnect_ConnectTimeout(t *testing.T) {
+	useFakeDriver(t)
+	slow := dbtest.NewServer(t, "connect-slow")
+	slow.ConnectDelay(time.Second)
+
+	start := time.Now()
+	_, err := Connect(context.Background(), slow.DSN(), ExecOptions{ConnectTimeout: 20 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Connect() took %v, want it to give up after the timeout", elapsed)
+	}
+	if !errors.Is(err, ErrConnectTimeout) || !strings.HasPrefix(err.Error(), "connection timeout after 20ms") {
+		t.Errorf("Connect() error = %v, want a connection timeout", err)
+	}
+
+	// A server that answers in time connects as before
+	slow.ConnectDelay(0)
+	sess, err := Connect(context.Background(), slow.DSN(), ExecOptions{ConnectTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	sess.Close()
+}
+
+func TestRunSQLOnInstanceWithOptions_ConnectTimeoutIsNotAQueryError(t *testing.T) {
+	useFakeDriver(t)
+	slow := dbtest.NewServer(t, "connect-timeout-run")
+	slow.ConnectDelay(time.Second)
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), slow.DSN(), "SELECT 1", ExecOptions{ConnectTimeout: 20 * time.Millisecond})
+	if len(results) != 1 || !results[0].ConnectFailed || !errors.Is(results[0].Err, ErrConnectTimeout) {
+		t.Fatalf("results = %+v, want one connection timeout", results)
+	}
+	if strings.Contains(results[0].Err.Error(), "query error") {
+		t.Errorf("error %q reads as a query error", results[0].Err)
+	}
+}
+
+func TestWithIOTimeouts(t *testing.T) {
+	tests := []struct {
+		name        string
+		dsn         string
+		read, write time.Duration
+		want        string
+	}{
+		{name: "neither", dsn: "u:p@tcp(h:3306)/app", want: "u:p@tcp(h:3306)/app"},
+		{name: "both", dsn: "u:p@tcp(h:3306)/app", read: 30 * time.Second, write: 10 * time.Second, want: "u:p@tcp(h:3306)/app?readTimeout=30s&writeTimeout=10s"},
+		{name: "read only, other parameters kept", dsn: "u:p@tcp(h:3306)/app?tls=true", read: time.Minute, want: "u:p@tcp(h:3306)/app?tls=true&readTimeout=1m0s"},
+		{name: "existing values replaced", dsn: "u:p@tcp(h:3306)/app?writeTimeout=1h&timeout=5s", write: 2 * time.Second, want: "u:p@tcp(h:3306)/app?timeout=5s&writeTimeout=2s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withIOTimeouts(tt.dsn, tt.read, tt.write)
+			if got != tt.want {
+				t.Errorf("withIOTimeouts(%q) = %q, want %q", tt.dsn, got, tt.want)
+			}
+			cfg, err := mysql.ParseDSN(got)
+			if err != nil {
+				t.Fatalf("the driver rejects %q: %v", got, err)
+			}
+			if tt.read > 0 && cfg.ReadTimeout != tt.read || tt.write > 0 && cfg.WriteTimeout != tt.write {
+				t.Errorf("the driver reads readTimeout %v, writeTimeout %v from %q", cfg.ReadTimeout, cfg.WriteTimeout, got)
+			}
+		})
+	}
+}
+
+func TestConnect_IOTimeouts(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "io-timeouts")
+
+	sess, err := Connect(context.Background(), srv.DSN(), ExecOptions{ReadTimeout: 30 * time.Second, WriteTimeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer sess.Close()
+	cfg, err := mysql.ParseDSN(sess.connectDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadTimeout != 30*time.Second || cfg.WriteTimeout != 10*time.Second {
+		t.Errorf("connected with readTimeout %v, writeTimeout %v, want 30s and 10s", cfg.ReadTimeout, cfg.WriteTimeout)
+	}
+	if sess.Instance != srv.DSN() {
+		t.Errorf("session instance = %q, want the DSN as given", sess.Instance)
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVImportOptions control the INSERT statements CSVInserts generates
+type CSVImportOptions struct {
+	Table     string // Target table, as table or db.table
+	BatchSize int    // Rows per INSERT statement (0 = DefaultValuesPerInsert)
+	Null      string // Fields inserted as NULL, e.g. \N; "" inserts every field as a string, empty ones included
+}
+
+// CSVInserts reads CSV with a header row naming the columns and returns INSERT
+// statements adding its rows to opts.Table, without terminators. Values are
+// inserted as quoted strings, which the server converts to the columns' types;
+// only fields holding opts.Null are NULL.
+// Rows with a different number of fields than the header are an error.
+func CSVInserts(r io.Reader, opts CSVImportOptions) ([]string, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultValuesPerInsert
+	}
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("CSV has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // Spreadsheets often start with a byte order mark
+	seen := make(map[string]bool, len(header))
+	quotedCols := make([]string, len(header))
+	for i, col := range header {
+		col = strings.TrimSpace(col)
+		if col == "" {
+			return nil, fmt.Errorf("CSV header: column %d has no name", i+1)
+		}
+		if seen[strings.ToLower(col)] {
+			return nil, fmt.Errorf("CSV header: column %s appears more than once", col)
+		}
+		seen[strings.ToLower(col)] = true
+		quotedCols[i] = QuoteIdentifier(col)
+	}
+	prefix := "INSERT INTO " + QuoteTableName(opts.Table) + " (" + strings.Join(quotedCols, ", ") + ") VALUES "
+
+	var statements []string
+	var stmt strings.Builder
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rows%batchSize == 0 {
+			if rows > 0 {
+				statements = append(statements, stmt.String())
+			}
+			stmt.Reset()
+			stmt.WriteString(prefix)
+		} else {
+			stmt.WriteString(",")
+		}
+		stmt.WriteString("(")
+		for i, field := range record {
+			if i > 0 {
+				stmt.WriteString(",")
+			}
+			if opts.Null != "" && field == opts.Null {
+				stmt.WriteString("NULL")
+			} else {
+				stmt.WriteString("'" + EscapeSQLString(field) + "'")
+			}
+		}
+		stmt.WriteString(")")
+		rows++
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("CSV has no rows below its header")
+	}
+	return append(statements, stmt.String()), nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVInserts(t *testing.T) {
+	const csvText = "\ufeffid,name,note\n" +
+		"1,tea,\n" +
+		"2,\"coffee, black\",\"it's \"\"strong\"\"; \\ hot\"\n" +
+		"3,,\\N\n"
+
+	tests := []struct {
+		name string
+		opts CSVImportOptions
+		want []string
+	}{
+		{
+			name: "one batch without a NULL token",
+			opts: CSVImportOptions{Table: "shop.products"},
+			want: []string{"INSERT INTO `shop`.`products` (`id`, `name`, `note`) VALUES " +
+				`('1','tea',''),('2','coffee, black','it\'s \"strong\"; \\ hot'),('3','','\\N')`},
+		},
+		{
+			name: "batches and a NULL token",
+			opts: CSVImportOptions{Table: "products", BatchSize: 2, Null: `\N`},
+			want: []string{
+				"INSERT INTO `products` (`id`, `name`, `note`) VALUES " +
+					`('1','tea',''),('2','coffee, black','it\'s \"strong\"; \\ hot')`,
+				"INSERT INTO `products` (`id`, `name`, `note`) VALUES ('3','',NULL)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CSVInserts(strings.NewReader(csvText), tt.opts)
+			if err != nil {
+				t.Fatalf("CSVInserts() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CSVInserts() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			// Semicolons and quotes in values must not split the statements
+			if split := splitSQLStatements(strings.Join(got, ";\n") + ";"); len(split) != len(tt.want) {
+				t.Errorf("generated SQL splits into %d statements, want %d", len(split), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCSVInserts_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{name: "empty", csv: "", wantErr: "no header"},
+		{name: "header only", csv: "id,name\n", wantErr: "no rows"},
+		{name: "unnamed column", csv: "id,,name\n1,2,3\n", wantErr: "column 2 has no name"},
+		{name: "repeated column", csv: "id,name,ID\n1,2,3\n", wantErr: "more than once"},
+		{name: "short row", csv: "id,name\n1,tea\n2\n", wantErr: "wrong number of fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CSVInserts(strings.NewReader(tt.csv), CSVImportOptions{Table: "t"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CSVInserts() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// CSVInstanceColumn is the name of the leading column that carries each row's
+// instance when rows of several instances share one CSV block
+const CSVInstanceColumn = "instance"
+
+// CSVOptions controls how PrintResultCSV writes a result
+type CSVOptions struct {
+	// Lead each row with an instance column holding the masked DSN, instead of a
+	// "# instance:" comment line before the block, so the rows of several
+	// instances can share one header
+	InstanceColumn bool
+
+	// Leave out the header row, when the same header was already written for an
+	// earlier block of rows
+	SkipHeader bool
+
+	Null string // How NULL is written; "" is an empty field
+}
+
+// CSVHeader returns the header row PrintResultCSV writes for a result
+func CSVHeader(res QueryResult, opts CSVOptions) []string {
+	if opts.InstanceColumn {
+		return append([]string{CSVInstanceColumn}, res.Columns...)
+	}
+	return res.Columns
+}
+
+// PrintResultCSV writes the rows of a query result as RFC 4180 CSV: a header row
+// with the column names, then a record per row, NULL as an empty field unless
+// opts says otherwise. Instances are told apart by a "# instance:" comment line
+// before the block or by a leading instance column. Statements that return no
+// columns, such as DDL, write nothing.
+func PrintResultCSV(w io.Writer, res QueryResult, opts CSVOptions) error {
+	if res.Err != nil {
+		return fmt.Errorf("cannot export failed statement: %w", res.Err)
+	}
+	if len(res.Columns) == 0 {
+		return nil
+	}
+
+	instance := maskPasswordInDSN(res.Instance)
+	header := CSVHeader(res, opts)
+	if !opts.InstanceColumn {
+		fmt.Fprintf(w, "# instance: %s\n", instance)
+	}
+
+	cw := csv.NewWriter(w)
+	if !opts.SkipHeader {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	for _, row := range res.Rows {
+		record := make([]string, 0, len(header))
+		if opts.InstanceColumn {
+			record = append(record, instance)
+		}
+		record = append(record, csvFields(row, opts.Null)...)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvFields formats a row's values as CSV fields, with NULL as null
+func csvFields(row []interface{}, null string) []string {
+	out := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			out[i] = null
+		case []byte:
+			out[i] = string(v)
+		default:
+			out[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrintResultCSV_Quoting(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string // The field as written
+	}{
+		{name: "plain", value: []byte("abc"), want: "abc"},
+		{name: "NULL is empty", value: nil, want: ""},
+		{name: "empty string is empty", value: []byte(""), want: ""},
+		{name: "comma", value: []byte("a,b"), want: `"a,b"`},
+		{name: "quote", value: []byte(`say "hi"`), want: `"say ""hi"""`},
+		{name: "newline", value: []byte("line1\nline2"), want: "\"line1\nline2\""},
+		{name: "carriage return", value: []byte("a\rb"), want: "\"a\rb\""},
+		{name: "leading space", value: []byte(" x"), want: `" x"`},
+		{name: "number", value: int64(42), want: "42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			res := QueryResult{Instance: "u:p@tcp(h:3306)/d", Columns: []string{"v"}, Rows: [][]interface{}{{tt.value}}, RowCount: 1}
+			if err := PrintResultCSV(&buf, res, CSVOptions{InstanceColumn: true}); err != nil {
+				t.Fatalf("PrintResultCSV() error = %v", err)
+			}
+			want := "instance,v\nu:****@tcp(h:3306)/d," + tt.want + "\n"
+			if buf.String() != want {
+				t.Errorf("PrintResultCSV() = %q, want %q", buf.String(), want)
+			}
+
+			// Whatever the quoting, a CSV reader gets the value back
+			records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+			if err != nil {
+				t.Fatalf("output is not valid CSV: %v", err)
+			}
+			if got, want := records[1][1], csvFields([]interface{}{tt.value}, "")[0]; got != want {
+				t.Errorf("read back %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPrintResultCSV_InstanceComment(t *testing.T) {
+	res := QueryResult{
+		Instance: "u:p@tcp(h:3306)/d",
+		Columns:  []string{"id", "name"},
+		Rows:     [][]interface{}{{int64(1), []byte("a")}, {int64(2), nil}},
+		RowCount: 2,
+	}
+	var buf bytes.Buffer
+	if err := PrintResultCSV(&buf, res, CSVOptions{}); err != nil {
+		t.Fatalf("PrintResultCSV() error = %v", err)
+	}
+	want := "# instance: u:****@tcp(h:3306)/d\nid,name\n1,a\n2,\n"
+	if buf.String() != want {
+		t.Errorf("PrintResultCSV() = %q, want %q", buf.String(), want)
+	}
+
+	// Readers that skip comment lines see a plain table
+	r := csv.NewReader(strings.NewReader(buf.String()))
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if wantRecords := [][]string{{"id", "name"}, {"1", "a"}, {"2", ""}}; !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("records = %q, want %q", records, wantRecords)
+	}
+}
+
+func TestPrintResultCSV_NullTokenAndSkipHeader(t *testing.T) {
+	res := QueryResult{
+		Instance: "u:p@tcp(h:3306)/d",
+		Columns:  []string{"id", "note"},
+		Rows:     [][]interface{}{{int64(1), nil}, {int64(2), ""}},
+		RowCount: 2,
+	}
+	var buf bytes.Buffer
+	if err := PrintResultCSV(&buf, res, CSVOptions{InstanceColumn: true, SkipHeader: true, Null: `\N`}); err != nil {
+		t.Fatalf("PrintResultCSV() error = %v", err)
+	}
+	// The token keeps NULL apart from the empty string
+	want := "u:****@tcp(h:3306)/d,1,\\N\nu:****@tcp(h:3306)/d,2,\n"
+	if buf.String() != want {
+		t.Errorf("PrintResultCSV() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrintResultCSV_NoColumnsAndErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintResultCSV(&buf, QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "CREATE TABLE t (id INT)"}, CSVOptions{}); err != nil {
+		t.Fatalf("PrintResultCSV() error = %v for a statement without columns", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("PrintResultCSV() wrote %q for a statement without columns", buf.String())
+	}
+
+	err := PrintResultCSV(&buf, QueryResult{Instance: "u:p@tcp(h:3306)/d", Err: errors.New("boom")}, CSVOptions{})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("PrintResultCSV() error = %v, want the statement's error", err)
+	}
+}
@@ -2,14 +2,21 @@ package db
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	// Needed for robust DSN parsing
 	"github.com/fatih/color"
@@ -21,17 +28,168 @@ import (
 type StatementInfo struct {
 	SQL      string
 	Vertical bool
+	Display  string // Statement as written, when SQL was rewritten for execution
+	Line     int    // Line of the script the statement starts on, past leading comments (0 = unknown)
 }
 
+// displaySQL returns the statement as it should be reported to the user
+func (s StatementInfo) displaySQL() string {
+	if s.Display != "" {
+		return s.Display
+	}
+	return s.SQL
+}
+
+// reportedSQL returns the statement as reported in results, with \G added back
+func (s StatementInfo) reportedSQL() string {
+	if s.Vertical {
+		return s.displaySQL() + "\\G"
+	}
+	return s.displaySQL()
+}
+
+// ColumnType describes a result column as reported by the driver
+type ColumnType struct {
+	Name         string `json:"name"`
+	DatabaseType string `json:"type"`     // Driver type name, e.g. VARCHAR, BIGINT, BLOB
+	Nullable     *bool  `json:"nullable"` // Whether the column may hold NULL; nil if the driver cannot tell
+	Length       *int64 `json:"length"`   // Length of variable-length types; nil if unknown or not applicable
+}
+
+// RowHandler receives a row of a statement on an instance while the statement's
+// rows are still being read. It is called from the goroutine running the
+// instance, so handlers shared by instances must be safe for concurrent use.
+type RowHandler func(instanceDSN, statement string, columns []string, row []interface{})
+
 type QueryResult struct {
 	Instance       string
 	Statement      string // The original statement including \G if used
 	Rows           [][]interface{}
 	Columns        []string
+	ColumnTypes    []ColumnType // Column type information, parallel to Columns
 	Err            error
 	VerticalFormat bool          // Flag to indicate vertical output
 	Duration       time.Duration // Query execution time
 	RowCount       int           // Number of rows returned
+	BytesReceived  int64         // Approximate size of the returned row data
+	Skipped        bool          // Statement was not executed; Err explains why
+	ConnectFailed  bool          // The instance could not be reached; no statement was executed
+	Handshake      time.Duration // Time spent connecting; set on the first result of an instance run
+	Processing     time.Duration // Client time spent reading and scanning the rows
+	QueryID        string        // Id the executed statement was marked with, with ExecOptions.MarkQueryID
+	OmittedRows    int           // Rows after the first that ExecOptions.FirstRowOnly did not keep
+	RowsAffected   int64         // Rows changed by the statement, reported with ExecOptions.ExecOnly
+	Line           int           // Line of the script the statement starts on (0 = unknown)
+	Source         string        // Name of the script, from ExecOptions.Source
+	Plan           string        // EXPLAIN output captured by ExecOptions.ExplainOnSlow or ExplainOnError
+	PlanReason     string        // Why the plan was captured, e.g. "took 2.1s, over 1s"
+	PlanWarnings   string        // SHOW WARNINGS after an EXPLAIN, with ExecOptions.ExplainWarnings
+}
+
+// Location returns where the statement is in its script, e.g. "deploy.sql:412",
+// or "" if the script has no name or the line is unknown
+func (r QueryResult) Location() string {
+	if r.Source == "" || r.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", r.Source, r.Line)
+}
+
+// DriverName is the database/sql driver used to open instance connections.
+// Tests substitute a fake driver registered under a different name.
+var DriverName = "mysql"
+
+// ExecOptions controls how statements are executed on an instance
+type ExecOptions struct {
+	Verbose        int
+	Budget         *RunBudget  // Optional run-wide row/byte budget shared by all instances
+	MaxResultBytes int64       // Abort a statement once its rows take up more than this (0 = unlimited)
+	StripComments  bool        // Remove comments (except optimizer hints) before execution
+	FailoverAware  bool        // Reconnect and retry a statement once when the server was demoted or lost
+	KillOnCancel   bool        // Send KILL QUERY for a statement interrupted by cancellation
+	MarkQueryID    bool        // Prefix each executed statement with a /* csql:<id> */ comment
+	Terminator     Terminator  // How scripts are split into statements (default semicolons)
+	Output         *OutputSink // Where diagnostics are written (default DefaultOutput)
+
+	// Skip the remaining statements on an instance once this many failed on its
+	// session (0 = unlimited). Skipped and cancelled statements do not count.
+	MaxErrorsPerInstance int
+
+	// Called with each statement that fails, as soon as it has, before the next
+	// statement starts (nil = none). Skipped and cancelled statements are not
+	// reported. Cancelling the run's context from it skips the rest of the script.
+	OnFailure func(res QueryResult)
+
+	// Keep only the first row of each result. Later rows are counted without being
+	// scanned; the driver still reads them off the connection.
+	FirstRowOnly bool
+
+	// Read only the column types of each result, not its rows, which are discarded
+	// unscanned; results report no rows
+	ColumnsOnly bool
+
+	// Run statements with Exec, so any result set is discarded by the driver
+	// without being scanned; results report only the rows affected
+	ExecOnly bool
+
+	// Hand each row to OnRow as soon as it is scanned instead of keeping it in the
+	// result, which then reports only the number of rows (nil = keep the rows)
+	OnRow RowHandler
+
+	// Name of the script the statements come from, e.g. its file, so errors can
+	// point at the statement's line
+	Source string
+
+	// Capture the plan of statements that take longer than ExplainOnSlow (0 = never)
+	// or fail with an execution error such as a lock wait timeout, with EXPLAIN on
+	// the same connection. Statements EXPLAIN does not accept are never explained.
+	ExplainOnSlow  time.Duration
+	ExplainOnError bool
+
+	// Read SHOW WARNINGS after an EXPLAIN, whether a statement of the script or a
+	// captured plan, for the notes the optimizer leaves, e.g. the rewritten query
+	ExplainWarnings bool
+
+	// Reach instances through SSH bastions by dialing local forwarded ports
+	// (nil = connect directly)
+	Tunnels *Tunnels
+
+	// Dial instances through the SOCKS5 proxy registered with RegisterProxy.
+	// Instances reached through a bastion are not proxied.
+	Proxy bool
+
+	// Encrypt connections in this mode, with the configuration registered by
+	// RegisterTLS, overriding the DSNs' tls parameters ("" = as each DSN says)
+	TLS TLSMode
+
+	// Connect again, backing off, up to this many times while a server refuses
+	// connections with too many connections (1040 or 1203); 0 = never
+	ConnectRetries int
+
+	// Give up connecting to an instance after this long (0 = the driver's default):
+	// the driver's dial timeout, and a deadline for the handshake and ping.
+	// Instances that take longer fail with ErrConnectTimeout.
+	ConnectTimeout time.Duration
+
+	// Fail a statement when the server sends nothing for ReadTimeout, or takes no
+	// data for WriteTimeout, on an established connection (0 = wait forever): the
+	// driver's readTimeout and writeTimeout. A read timeout also ends a statement
+	// that is legitimately silent for longer, such as a slow aggregate.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Interrupt a statement that runs longer than this (0 = no limit). It fails
+	// with a *QueryTimeoutError and the session reconnects, replaying USE and SET
+	// statements, so the instance's next statement still runs.
+	QueryTimeout time.Duration
+}
+
+// output returns the sink diagnostics are written to
+func (o ExecOptions) output() *OutputSink {
+	if o.Output != nil {
+		return o.Output
+	}
+	return DefaultOutput
 }
 
 // RunSQLOnInstance connects to a single instance and executes all SQL statements.
@@ -41,225 +199,499 @@ func RunSQLOnInstance(instanceDSN string, sqls string) []QueryResult {
 
 // RunSQLOnInstanceWithVerbosity connects to a single instance and executes all SQL statements with verbosity control.
 func RunSQLOnInstanceWithVerbosity(instanceDSN string, sqls string, verbose int) []QueryResult {
-	statementList := splitSQLStatements(sqls) // Now returns []StatementInfo
-	results := []QueryResult{}
+	return RunSQLOnInstanceWithOptions(context.Background(), instanceDSN, sqls, ExecOptions{Verbose: verbose})
+}
+
+// RunSQLOnInstanceContext connects to a single instance and executes all SQL statements
+// with verbosity control. Cancelling ctx aborts the in-flight query and skips the rest.
+func RunSQLOnInstanceContext(ctx context.Context, instanceDSN string, sqls string, verbose int) []QueryResult {
+	return RunSQLOnInstanceWithOptions(ctx, instanceDSN, sqls, ExecOptions{Verbose: verbose})
+}
 
+// RunSQLOnInstanceWithOptions connects to a single instance and executes all SQL statements.
+// Cancelling ctx aborts the in-flight query and marks the remaining statements as skipped.
+func RunSQLOnInstanceWithOptions(ctx context.Context, instanceDSN string, sqls string, opts ExecOptions) []QueryResult {
 	// Trim space from instance DSN just in case
 	instanceDSN = strings.TrimSpace(instanceDSN)
 
-	db, err := sql.Open("mysql", instanceDSN)
+	// Don't even connect if the run was cancelled before this instance started
+	if ctx.Err() != nil {
+		return []QueryResult{{Instance: instanceDSN, Skipped: true, Err: skipReason(ctx, opts)}}
+	}
+
+	start := time.Now()
+	sess, err := Connect(ctx, instanceDSN, opts)
 	if err != nil {
 		// Return a single error result for the whole instance if connection fails
-		results = append(results, QueryResult{Instance: instanceDSN, Err: fmt.Errorf("failed to open connection: %w", err)})
-		return results
+		return []QueryResult{connectFailure(ctx, instanceDSN, err, time.Since(start), opts)}
 	}
-	defer db.Close()
+	defer sess.Close()
 
-	// Ping to verify connection early
-	err = db.Ping()
-	if err != nil {
-		results = append(results, QueryResult{Instance: instanceDSN, Err: fmt.Errorf("failed to ping database: %w", err)})
-		return results
+	return RunSQLOnSession(ctx, sess, sqls, opts)
+}
+
+// connectFailure is the single result reported for an instance that could not be reached
+func connectFailure(ctx context.Context, instanceDSN string, err error, handshake time.Duration, opts ExecOptions) QueryResult {
+	if ctx.Err() != nil {
+		return QueryResult{Instance: instanceDSN, Skipped: true, Err: skipReason(ctx, opts)}
 	}
+	return QueryResult{Instance: instanceDSN, Err: err, ConnectFailed: true, Handshake: handshake}
+}
 
+// RunSQLOnSession executes all SQL statements on an already open session.
+// Cancelling ctx aborts the in-flight query and marks the remaining statements as skipped.
+func RunSQLOnSession(ctx context.Context, sess *Session, sqls string, opts ExecOptions) []QueryResult {
+	statementList := opts.Terminator.Split(sqls)
+	if opts.StripComments {
+		statementList = stripStatementComments(statementList)
+	}
+	run := &sessionRun{sess: sess, opts: opts}
+	if opts.FailoverAware {
+		run.currentServerID = "unknown"
+		if sess.capabilities(ctx, opts).Has(CapVariables) {
+			run.currentServerID = serverID(ctx, sess.conn)
+		}
+	}
+	if opts.KillOnCancel && sess.capabilities(ctx, opts).Has(CapConnectionID) {
+		run.connectionID = connectionID(ctx, sess.conn)
+	}
+
+	results := make([]QueryResult, 0, len(statementList))
 	for _, stmtInfo := range statementList {
-		// Use stmtInfo.SQL (without \G) for query execution
-		// Use stmtInfo.SQL (original, potentially with \G) for reporting in QueryResult
-		stmtToExecute := stmtInfo.SQL
-		originalStmt := stmtInfo.SQL // Store original for reporting
-		if stmtInfo.Vertical {
-			originalStmt += "\\G" // Add back for display if needed, or just use the flag
+		if ctx.Err() == nil && opts.MaxErrorsPerInstance > 0 && sess.failed >= opts.MaxErrorsPerInstance {
+			results = append(results, QueryResult{
+				Instance:       sess.Instance,
+				Statement:      stmtInfo.reportedSQL(),
+				Err:            &AbandonedError{Errors: sess.failed},
+				VerticalFormat: stmtInfo.Vertical,
+				Skipped:        true,
+				Line:           stmtInfo.Line,
+				Source:         opts.Source,
+			})
+			continue
+		}
+		res := run.statement(ctx, stmtInfo)
+		failed := res.Err != nil && !res.Skipped && ctx.Err() == nil
+		if failed {
+			sess.failed++
+		}
+		var timeout *QueryTimeoutError
+		if errors.As(res.Err, &timeout) && ctx.Err() == nil {
+			if err := run.reconnect(ctx); err != nil {
+				res.Err = fmt.Errorf("%w (reconnect failed: %v)", res.Err, err)
+			}
+		}
+		run.explainWarnings(ctx, stmtInfo, &res)
+		run.explain(ctx, stmtInfo, &res)
+		res.Line, res.Source = stmtInfo.Line, opts.Source
+		results = append(results, res)
+		if failed && opts.OnFailure != nil {
+			opts.OnFailure(res)
 		}
+	}
 
-		// Time the query execution
-		startTime := time.Now()
-		rows, err := db.Query(stmtToExecute)
-		duration := time.Since(startTime)
+	// Connection setup is reported once per session, on the first result it produced
+	if len(results) > 0 && !sess.handshakeReported {
+		results[0].Handshake = sess.Handshake
+		sess.handshakeReported = true
+	}
+	return results
+}
 
-		if err != nil {
-			results = append(results, QueryResult{
+// sessionRun is the state carried across the statements of one run on a session
+type sessionRun struct {
+	sess *Session
+	opts ExecOptions
+
+	// Failover tracking: the session statements to replay and the server we're talking to
+	sessionStmts    []string
+	currentServerID string
+
+	// With KillOnCancel, the server's id for the connection and whether it was killed
+	connectionID string
+	killed       bool
+}
+
+// statement executes one statement and reads its rows. A panic while executing or
+// scanning (e.g. a pathological row) is recovered into the statement's result, so
+// the remaining statements on the instance still run.
+func (r *sessionRun) statement(ctx context.Context, stmtInfo StatementInfo) (res QueryResult) {
+	sess, opts := r.sess, r.opts
+	instanceDSN := sess.Instance
+
+	// Use stmtInfo.SQL (without \G) for query execution
+	// Use the statement as written (potentially with \G) for reporting in QueryResult
+	stmtToExecute := stmtInfo.SQL
+	originalStmt := stmtInfo.reportedSQL() // Store original for reporting
+	var queryID string
+	if opts.MarkQueryID {
+		queryID = NewQueryID()
+		stmtToExecute = QueryIDComment(queryID) + " " + stmtToExecute
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			res = QueryResult{
 				Instance:       instanceDSN,
 				Statement:      originalStmt,
-				Err:            fmt.Errorf("query error: %w", err),
+				Err:            fmt.Errorf("panic while executing statement: %v", p),
 				VerticalFormat: stmtInfo.Vertical,
-				Duration:       duration,
-			})
-			continue // Move to the next statement
+			}
 		}
+	}()
 
-		// Process rows even if there's an error getting columns later
-		cols, colErr := rows.Columns()
-		var allRows [][]interface{}
-		var scanErr error
+	// Once the run is cancelled, report the remaining statements as skipped
+	if ctx.Err() != nil {
+		return QueryResult{
+			Instance:       instanceDSN,
+			Statement:      originalStmt,
+			Err:            skipReason(ctx, opts),
+			VerticalFormat: stmtInfo.Vertical,
+			Skipped:        true,
+		}
+	}
+
+	// The statement's own deadline; ending the run still cancels it
+	stmtCtx := ctx
+	if opts.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		stmtCtx, cancel = context.WithTimeoutCause(ctx, opts.QueryTimeout, &QueryTimeoutError{Timeout: opts.QueryTimeout})
+		defer cancel()
+	}
 
-		if colErr == nil {
-			for rows.Next() {
-				vals := make([]interface{}, len(cols))
-				scanArgs := make([]interface{}, len(cols))
-				for i := range vals {
-					scanArgs[i] = &vals[i]
+	// Time the query execution
+	var rows *sql.Rows
+	var execResult sql.Result
+	execute := func() (err error) {
+		if opts.ExecOnly {
+			execResult, err = sess.conn.ExecContext(stmtCtx, stmtToExecute)
+			return err
+		}
+		rows, err = sess.conn.QueryContext(stmtCtx, stmtToExecute)
+		return err
+	}
+	startTime := time.Now()
+	err := execute()
+	if err != nil && opts.FailoverAware && stmtCtx.Err() == nil && isFailoverError(err) {
+		// Reconnect (re-resolving the endpoint) and retry the statement once
+		fresh, failoverErr := failover(ctx, sess.connectDSN, sess.db, sess.conn, r.sessionStmts,
+			sess.capabilities(ctx, opts).Has(CapVariables))
+		if failoverErr != nil {
+			err = fmt.Errorf("%w (failover reconnect failed: %v)", err, failoverErr)
+		} else {
+			opts.output().Printf(StreamDiagnostics, "[%s] failover after %v: reconnected, server_id %s -> %s\n",
+				maskPasswordInDSN(sess.connectDSN), err, r.currentServerID, fresh.serverID)
+			sess.db, sess.conn, r.currentServerID = fresh.db, fresh.conn, fresh.serverID
+			if r.connectionID != "" {
+				r.connectionID = connectionID(ctx, sess.conn)
+			}
+			err = execute()
+		}
+	}
+	duration := time.Since(startTime)
+	if err == nil && (opts.FailoverAware || opts.QueryTimeout > 0) && isSessionStatement(stmtInfo.SQL) {
+		r.sessionStmts = append(r.sessionStmts, stmtToExecute)
+	}
+
+	if err != nil {
+		if stmtCtx.Err() != nil {
+			r.killCancelled(stmtCtx)
+			err = skipReason(stmtCtx, opts)
+		} else {
+			err = newQueryError(err)
+		}
+		return QueryResult{
+			Instance:       instanceDSN,
+			Statement:      originalStmt,
+			Err:            fmt.Errorf("query error: %w", err),
+			VerticalFormat: stmtInfo.Vertical,
+			Duration:       duration,
+			QueryID:        queryID,
+		}
+	}
+	if opts.ExecOnly {
+		affected, _ := execResult.RowsAffected() // Unsupported only by other drivers
+		return QueryResult{
+			Instance:       instanceDSN,
+			Statement:      originalStmt,
+			VerticalFormat: stmtInfo.Vertical,
+			Duration:       duration,
+			QueryID:        queryID,
+			RowsAffected:   affected,
+		}
+	}
+	defer rows.Close() // Also releases the connection if reading rows panics
+	scanStart := time.Now()
+
+	// Process rows even if there's an error getting columns later
+	cols, colErr := rows.Columns()
+	colTypes := columnTypesOf(rows)
+	var allRows [][]interface{}
+	rowCount := 0
+	var bytesReceived int64
+	var scanErr error
+	omitted := 0
+
+	if colErr == nil && opts.ColumnsOnly {
+		// Only the columns were asked for; closing rows discards the result unread
+	} else if colErr == nil {
+		for rows.Next() {
+			if opts.FirstRowOnly && rowCount > 0 {
+				omitted++
+				continue
+			}
+			vals := make([]interface{}, len(cols))
+			scanArgs := make([]interface{}, len(cols))
+			for i := range vals {
+				scanArgs[i] = &vals[i]
+			}
+			scanErr = rows.Scan(scanArgs...)
+			if scanErr != nil && stmtCtx.Err() != nil {
+				// The run was cancelled or the statement timed out while reading; stop quietly
+				r.killCancelled(stmtCtx)
+				if err == nil {
+					err = fmt.Errorf("rows iteration error: %w", skipReason(stmtCtx, opts))
 				}
-				scanErr = rows.Scan(scanArgs...)
-				if scanErr != nil {
-					// Log scan error but continue processing other rows/statements
-					fmt.Fprintf(os.Stderr, "[%s] %s - Row scan error: %v\n", instanceDSN, stmtToExecute, scanErr)
-					// Store the first scan error encountered for this statement result
-					if err == nil { // Only capture the first error
-						err = fmt.Errorf("row scan error: %w", scanErr)
-					}
-					continue // Skip this row
+				break
+			}
+			if scanErr != nil {
+				// Log scan error but continue processing other rows/statements
+				opts.output().Printf(StreamDiagnostics, "[%s] %s - Row scan error: %v\n", instanceDSN, stmtToExecute, scanErr)
+				// Store the first scan error encountered for this statement result
+				if err == nil { // Only capture the first error
+					err = fmt.Errorf("row scan error: %w", scanErr)
 				}
-				// Copy values as Scan reuses the buffer
-				rowCopy := make([]interface{}, len(vals))
-				for i, v := range vals {
-					// Handle potential nil values from DB
-					if b, ok := v.([]byte); ok {
-						rowCopy[i] = string(b) // Convert bytes to string for better display
-					} else {
-						rowCopy[i] = v
-					}
+				continue // Skip this row
+			}
+			// Copy values as Scan reuses the buffer
+			rowCopy := make([]interface{}, len(vals))
+			for i, v := range vals {
+				// Handle potential nil values from DB
+				if b, ok := v.([]byte); ok {
+					rowCopy[i] = string(b) // Convert bytes to string for better display
+				} else {
+					rowCopy[i] = v
 				}
+			}
+			if opts.OnRow != nil {
+				opts.OnRow(instanceDSN, originalStmt, cols, rowCopy)
+			} else {
 				allRows = append(allRows, rowCopy)
 			}
-		} else {
-			// If getting columns failed, record that error
-			err = fmt.Errorf("failed to get columns: %w", colErr)
+			rowCount++
+
+			rowBytes := estimateRowBytes(rowCopy)
+			bytesReceived += rowBytes
+			if budgetErr := opts.Budget.Add(1, rowBytes); budgetErr != nil {
+				err = budgetErr
+				break // Stop reading; the budget cancels the rest of the run
+			}
+			if opts.MaxResultBytes > 0 && bytesReceived > opts.MaxResultBytes {
+				err = fmt.Errorf("%w: more than %d bytes after %d rows; statement aborted",
+					ErrResultTooLarge, opts.MaxResultBytes, rowCount)
+				allRows, rowCount = nil, 0 // Release what was read; the result is discarded
+				break
+			}
 		}
+	} else {
+		// If getting columns failed, record that error
+		err = fmt.Errorf("failed to get columns: %w", colErr)
+	}
 
-		// Check for errors encountered during row iteration
-		if rows.Err() != nil {
-			if err == nil { // Prioritize earlier errors
-				err = fmt.Errorf("rows iteration error: %w", rows.Err())
+	// Check for errors encountered during row iteration
+	if rows.Err() != nil {
+		if err == nil { // Prioritize earlier errors
+			iterErr := rows.Err()
+			if stmtCtx.Err() != nil {
+				r.killCancelled(stmtCtx)
+				iterErr = skipReason(stmtCtx, opts)
+			} else {
+				iterErr = newQueryError(iterErr)
 			}
+			err = fmt.Errorf("rows iteration error: %w", iterErr)
 		}
+	}
 
-		// Make sure to pass the Vertical flag when creating the result
-		results = append(results, QueryResult{
-			Instance:       instanceDSN,
-			Statement:      originalStmt, // Report the statement as entered
-			Rows:           allRows,
-			Columns:        cols,
-			Err:            err, // Includes potential scan/column errors
-			VerticalFormat: stmtInfo.Vertical,
-			Duration:       duration,
-			RowCount:       len(allRows),
-		})
-		rows.Close() // Close rows as soon as possible
+	// Make sure to pass the Vertical flag when creating the result
+	return QueryResult{
+		Instance:       instanceDSN,
+		Statement:      originalStmt, // Report the statement as entered
+		Rows:           allRows,
+		Columns:        cols,
+		ColumnTypes:    colTypes,
+		Err:            err, // Includes potential scan/column errors
+		VerticalFormat: stmtInfo.Vertical,
+		Duration:       duration,
+		RowCount:       rowCount,
+		BytesReceived:  bytesReceived,
+		Processing:     time.Since(scanStart),
+		QueryID:        queryID,
+		OmittedRows:    omitted,
 	}
+}
 
-	return results
+// skipReason explains why work was skipped after ctx was cancelled: the budget, or
+// the cause ctx was cancelled with (context.Canceled or DeadlineExceeded if none)
+func skipReason(ctx context.Context, opts ExecOptions) error {
+	if opts.Budget.Exceeded() {
+		return ErrBudgetExceeded
+	}
+	return context.Cause(ctx)
+}
+
+// estimateRowBytes approximates the size of a scanned row's values
+func estimateRowBytes(row []interface{}) int64 {
+	var n int64
+	for _, v := range row {
+		switch val := v.(type) {
+		case nil:
+		case string:
+			n += int64(len(val))
+		case []byte:
+			n += int64(len(val))
+		default:
+			n += 8 // Numeric and time values
+		}
+	}
+	return n
+}
+
+// columnTypesOf captures the driver's column type information, or nil if unavailable
+func columnTypesOf(rows *sql.Rows) []ColumnType {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil
+	}
+	colTypes := make([]ColumnType, len(types))
+	for i, ct := range types {
+		colTypes[i] = ColumnType{Name: ct.Name(), DatabaseType: ct.DatabaseTypeName()}
+		if nullable, ok := ct.Nullable(); ok {
+			colTypes[i].Nullable = &nullable
+		}
+		if length, ok := ct.Length(); ok {
+			colTypes[i].Length = &length
+		}
+	}
+	return colTypes
 }
 
-// splitSQLStatements splits SQL string and detects \G, handling semicolons in strings and comments
+// splitSQLStatements splits SQL string on semicolons, \g and \G (detecting vertical
+// output), handling terminators in strings and comments. As in the mysql client,
+// \c discards the statement typed so far.
 func splitSQLStatements(sqls string) []StatementInfo {
+	// Reading from a strings.Reader cannot fail
+	statements, _ := splitSQLStatementsReader(strings.NewReader(sqls))
+	return statements
+}
+
+// splitSQLStatementsReader splits SQL read from r, streaming rune by rune so large
+// scripts are never materialized as a rune slice.
+func splitSQLStatementsReader(r io.Reader) ([]StatementInfo, error) {
+	reader, ok := r.(io.RuneScanner)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+
 	var statements []StatementInfo
 	var currentStatement strings.Builder
-	var inSingleQuote, inDoubleQuote, inBacktick bool
-	var inLineComment, inBlockComment bool
-
-	runes := []rune(sqls)
-	for i := 0; i < len(runes); i++ {
-		r := runes[i]
-
-		// Handle escape sequences in strings
-		if (inSingleQuote || inDoubleQuote || inBacktick) && r == '\\' && i+1 < len(runes) {
-			currentStatement.WriteRune(r)
-			i++ // Skip next character
-			if i < len(runes) {
-				currentStatement.WriteRune(runes[i])
-			}
-			continue
+	var scan sqlScanner
+
+	// The line being read, and where the current statement's first token and, for
+	// statements that are only comments, its first comment are
+	line, startLine, commentLine := 1, 0, 0
+	read := func() (rune, error) {
+		r, _, err := reader.ReadRune()
+		if r == '\n' {
+			line++
 		}
+		return r, err
+	}
+	appendCurrent := func(vertical bool) {
+		if startLine == 0 {
+			startLine = commentLine
+		}
+		statements = appendStatement(statements, currentStatement.String(), vertical, startLine)
+		currentStatement.Reset()
+		startLine, commentLine = 0, 0
+	}
 
-		// Handle comments
-		if !inSingleQuote && !inDoubleQuote && !inBacktick {
-			// Start of line comment
-			if r == '-' && i+1 < len(runes) && runes[i+1] == '-' {
-				inLineComment = true
-				currentStatement.WriteRune(r)
-				continue
-			}
-			// Start of block comment
-			if r == '/' && i+1 < len(runes) && runes[i+1] == '*' {
-				inBlockComment = true
-				currentStatement.WriteRune(r)
-				continue
-			}
-			// End of block comment
-			if inBlockComment && r == '*' && i+1 < len(runes) && runes[i+1] == '/' {
-				inBlockComment = false
-				currentStatement.WriteRune(r)
-				i++ // Skip the '/'
-				if i < len(runes) {
-					currentStatement.WriteRune(runes[i])
-				}
-				continue
-			}
+	// peek returns the next rune without consuming it
+	peek := func() (rune, bool) {
+		next, _, err := reader.ReadRune()
+		if err != nil {
+			return 0, false
 		}
+		_ = reader.UnreadRune()
+		return next, true
+	}
 
-		// End line comment on newline
-		if inLineComment && (r == '\n' || r == '\r') {
-			inLineComment = false
+	for {
+		r, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SQL: %w", err)
 		}
 
-		// Handle string delimiters (only if not in comments)
-		if !inLineComment && !inBlockComment {
-			switch r {
-			case '\'':
-				if !inDoubleQuote && !inBacktick {
-					inSingleQuote = !inSingleQuote
-				}
-			case '"':
-				if !inSingleQuote && !inBacktick {
-					inDoubleQuote = !inDoubleQuote
-				}
-			case '`':
-				if !inSingleQuote && !inDoubleQuote {
-					inBacktick = !inBacktick
+		if scan.inCode() {
+			// \g ends a statement like a semicolon, \G also asks for vertical output
+			// and \c clears it
+			if r == '\\' {
+				if next, hasNext := peek(); hasNext && (next == 'g' || next == 'G' || next == 'c') {
+					_, _ = read() // Skip the command letter
+					if next == 'c' {
+						currentStatement.Reset()
+						startLine, commentLine = 0, 0
+					} else {
+						appendCurrent(next == 'G')
+					}
+					continue
 				}
 			}
+			if r == ';' {
+				appendCurrent(false)
+				continue
+			}
 		}
 
-		// Handle semicolon (statement separator)
-		if r == ';' && !inSingleQuote && !inDoubleQuote && !inBacktick && !inLineComment && !inBlockComment {
-			// End of statement
-			stmt := strings.TrimSpace(currentStatement.String())
-			if stmt != "" {
-				info := StatementInfo{SQL: stmt, Vertical: false}
-				if strings.HasSuffix(stmt, "\\G") {
-					info.Vertical = true
-					// Remove \G for execution
-					info.SQL = strings.TrimSpace(stmt[:len(stmt)-2])
-				}
-				// Only add if the SQL part is not empty after removing \G
-				if info.SQL != "" {
-					statements = append(statements, info)
+		wasComment := scan.inComment()
+		width := scan.next(r, peek)
+		if startLine == 0 && !unicode.IsSpace(r) {
+			if wasComment || scan.inComment() {
+				if commentLine == 0 {
+					commentLine = line
 				}
+			} else {
+				startLine = line
 			}
-			currentStatement.Reset()
-		} else {
-			currentStatement.WriteRune(r)
+		}
+		currentStatement.WriteRune(r)
+		if width == 2 {
+			next, _ := read() // The second rune of an escape or comment delimiter
+			currentStatement.WriteRune(next)
 		}
 	}
 
 	// Handle the last statement if it doesn't end with semicolon
-	if currentStatement.Len() > 0 {
-		stmt := strings.TrimSpace(currentStatement.String())
-		if stmt != "" {
-			info := StatementInfo{SQL: stmt, Vertical: false}
-			if strings.HasSuffix(stmt, "\\G") {
-				info.Vertical = true
-				// Remove \G for execution
-				info.SQL = strings.TrimSpace(stmt[:len(stmt)-2])
-			}
-			// Only add if the SQL part is not empty after removing \G
-			if info.SQL != "" {
-				statements = append(statements, info)
-			}
-		}
+	appendCurrent(false)
+
+	return statements, nil
+}
+
+// appendStatement trims a raw statement and appends it if non-empty; vertical
+// marks a statement terminated by \G, and line is where it starts
+func appendStatement(statements []StatementInfo, raw string, vertical bool, line int) []StatementInfo {
+	stmt := strings.TrimSpace(raw)
+	if stmt == "" {
+		return statements
 	}
+	return append(statements, StatementInfo{SQL: stmt, Vertical: vertical, Line: line})
+}
 
-	return statements
+// MaskDSN returns dsn with its password masked, for display and logging
+func MaskDSN(dsn string) string {
+	return maskPasswordInDSN(dsn)
 }
 
 // maskPasswordInDSN takes a DSN string and returns a version with the password masked.
@@ -303,44 +735,159 @@ func PrintResult(res QueryResult, instanceColor *color.Color, useTableFormat boo
 	PrintResultWithVerbosity(res, instanceColor, useTableFormat, 0)
 }
 
+// PrintOptions controls how query results are rendered
+type PrintOptions struct {
+	TableFormat bool
+	Verbose     int
+	PrettySQL   bool        // Show statements with their line breaks and syntax highlighting
+	Output      *OutputSink // Where results are printed (default DefaultOutput)
+	Plain       bool        // Never emit ANSI colors, e.g. when rendering for a file
+
+	TableRowThreshold int    // Results with more rows skip tablewriter (0 = no limit)
+	LargeTable        string // LargeTableFallback (default) or LargeTableChunk for results over the threshold
+	TableSampleRows   int    // Rows sampled for column widths and rows per chunk with LargeTableChunk
+
+	Align           bool // Pad the default output's columns to their widths instead of separating them with tabs
+	AlignSampleRows int  // Rows sampled for column widths with Align (0 = DefaultAlignSampleRows)
+
+	TableStyle  string // Border style of TableFormat: TableStyleMySQL (default), TableStyleMarkdown, TableStyleBox or TableStyleASCII
+	HeaderTypes bool   // Show each column's type under its name in the TableFormat header
+
+	VerticalSeparator int  // Asterisks on each side of a vertical row marker (0 = DefaultVerticalSeparator)
+	VerticalHeaders   bool // Head the row markers of vertical output with the masked instance
+
+	Messages Messages // Texts such as "Empty set."; zero value uses the defaults
+}
+
+// DefaultVerticalSeparator is how many asterisks frame a row marker in vertical output
+const DefaultVerticalSeparator = 20
+
+// verticalSeparator returns the row marker frame of vertical output
+func (o PrintOptions) verticalSeparator() string {
+	if o.VerticalSeparator > 0 {
+		return strings.Repeat("*", o.VerticalSeparator)
+	}
+	return strings.Repeat("*", DefaultVerticalSeparator)
+}
+
+// output returns the sink results are printed to
+func (o PrintOptions) output() *OutputSink {
+	if o.Output != nil {
+		return o.Output
+	}
+	return DefaultOutput
+}
+
 // PrintResultWithVerbosity prints the query result with verbosity control.
 func PrintResultWithVerbosity(res QueryResult, instanceColor *color.Color, useTableFormat bool, verbose int) {
-	maskedDSN := maskPasswordInDSN(res.Instance)                     // Mask the password
-	instanceStr := instanceColor.SprintFunc()("[" + maskedDSN + "]") // Use masked DSN
+	PrintResultWithOptions(res, instanceColor, PrintOptions{TableFormat: useTableFormat, Verbose: verbose})
+}
+
+// PrintResultWithOptions prints the query result as configured by opts, as a single
+// block of the output sink so concurrent printers never interleave.
+func PrintResultWithOptions(res QueryResult, instanceColor *color.Color, opts PrintOptions) {
+	_ = opts.output().Block(StreamResults, func(w io.Writer) {
+		RenderResult(w, res, instanceColor, opts)
+	})
+}
+
+// plainColor returns c, or a color that prints text unchanged when c is nil
+func plainColor(c *color.Color) *color.Color {
+	if c != nil {
+		return c
+	}
+	plain := color.New()
+	plain.DisableColor()
+	return plain
+}
+
+// paint returns a function coloring text with attrs, or leaving it unchanged when
+// the options ask for plain output
+func (o PrintOptions) paint(attrs ...color.Attribute) func(a ...interface{}) string {
+	c := color.New(attrs...)
+	if o.Plain {
+		c.DisableColor()
+	}
+	return c.SprintFunc()
+}
+
+// RenderResult writes the query result to w as configured by opts, followed by
+// its captured plan. A nil instanceColor, or opts.Plain, prints the instance label
+// without color.
+func RenderResult(w io.Writer, res QueryResult, instanceColor *color.Color, opts PrintOptions) {
+	renderResult(w, res, instanceColor, opts)
+	writePlan(w, res, opts)
+}
+
+// renderResult writes the query result itself
+func renderResult(w io.Writer, res QueryResult, instanceColor *color.Color, opts PrintOptions) {
+	useTableFormat, verbose := opts.TableFormat, opts.Verbose
+	msgs := opts.Messages.withDefaults()
+	if opts.Plain {
+		instanceColor = nil
+	}
+	maskedDSN := maskPasswordInDSN(res.Instance)                                 // Mask the password
+	instanceStr := plainColor(instanceColor).SprintFunc()("[" + maskedDSN + "]") // Use masked DSN
+
+	if res.Skipped {
+		skipColor := opts.paint(color.FgYellow)
+		fmt.Fprintf(w, "%s %s %s: %v\n", instanceStr, skipColor(msgs.Skipped), res.Statement, res.Err)
+		return
+	}
 
 	if res.Err != nil {
-		errorColor := color.New(color.FgRed).SprintFunc()
-		fmt.Printf("%s %s %s: %v\n", instanceStr, errorColor("ERROR"), res.Statement, res.Err)
+		errorColor := opts.paint(color.FgRed)
+		if location := res.Location(); location != "" {
+			fmt.Fprintf(w, "%s %s %s: %s: %v\n", instanceStr, errorColor(msgs.Error), location, res.MarkedStatement(), res.Err)
+		} else {
+			fmt.Fprintf(w, "%s %s %s: %v\n", instanceStr, errorColor(msgs.Error), res.MarkedStatement(), res.Err)
+		}
 		return
 	}
 
 	// Verbosity level 1 and above: Show statement separators
 	if verbose >= 1 {
-		fmt.Println(strings.Repeat("-", 14))
+		fmt.Fprintln(w, strings.Repeat("-", 14))
 	}
 
-	fmt.Printf("%s %s\n", instanceStr, res.Statement)
+	if opts.PrettySQL || verbose >= 1 {
+		// Multi-line statements start on their own line so their indentation lines up
+		statement := res.MarkedStatement()
+		if !opts.Plain {
+			statement = highlightSQL(statement)
+		}
+		if strings.Contains(res.Statement, "\n") {
+			fmt.Fprintf(w, "%s\n%s\n", instanceStr, statement)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", instanceStr, statement)
+		}
+	} else {
+		fmt.Fprintf(w, "%s %s\n", instanceStr, res.MarkedStatement())
+	}
 
 	// Verbosity level 3: Show timing information
 	if verbose >= 3 {
-		fmt.Printf("Query time: %v\n", res.Duration)
+		fmt.Fprintf(w, "Query time: %v\n", res.Duration)
 	}
 
 	if res.VerticalFormat {
 		// --- Vertical Output ---
+		if len(res.Columns) == 0 {
+			writeNoColumns(w, res, verbose, msgs)
+			return
+		}
 		if len(res.Rows) == 0 {
-			fmt.Println("Empty set.")
-			// Verbosity level 2 and above: Show row count
-			if verbose >= 2 {
-				fmt.Printf("(%d rows in set", res.RowCount)
-				if verbose >= 3 {
-					fmt.Printf(" (%v)", res.Duration)
-				}
-				fmt.Println(")")
-			}
+			fmt.Fprintln(w, msgs.EmptySet)
+			writeRowCount(w, res, verbose, msgs)
 			return
 		}
-		rowSeparator := strings.Repeat("*", 20)
+		rowSeparator := opts.verticalSeparator()
+		if opts.VerticalHeaders {
+			// With many instances fanned out, the rows are otherwise hard to tell apart
+			frame := strings.Repeat("=", len(rowSeparator))
+			fmt.Fprintln(w, plainColor(instanceColor).Sprintf("%s %s %s", frame, maskedDSN, frame))
+		}
+		digits := len(strconv.Itoa(len(res.Rows))) // Row numbers are zero-padded so the markers line up
 		maxColWidth := 0
 		for _, colName := range res.Columns {
 			if len(colName) > maxColWidth {
@@ -348,7 +895,7 @@ func PrintResultWithVerbosity(res QueryResult, instanceColor *color.Color, useTa
 			}
 		}
 		for i, row := range res.Rows {
-			fmt.Printf("%s %d. row %s\n", rowSeparator, i+1, rowSeparator)
+			fmt.Fprintf(w, "%s %0*d. row %s\n", rowSeparator, digits, i+1, rowSeparator)
 			for j, colName := range res.Columns {
 				valStr := "NULL"
 				if j < len(row) && row[j] != nil {
@@ -358,135 +905,80 @@ func PrintResultWithVerbosity(res QueryResult, instanceColor *color.Color, useTa
 						valStr = fmt.Sprintf("%v", row[j])
 					}
 				}
-				fmt.Printf("%*s: %s\n", maxColWidth, colName, valStr)
-			}
-		}
-		// Verbosity level 2 and above: Show row count for vertical format
-		if verbose >= 2 {
-			fmt.Printf("(%d rows in set", res.RowCount)
-			if verbose >= 3 {
-				fmt.Printf(" (%v)", res.Duration)
+				fmt.Fprintf(w, "%*s: %s\n", maxColWidth, colName, valStr)
 			}
-			fmt.Println(")")
 		}
+		writeRowCount(w, res, verbose, msgs)
 	} else if useTableFormat {
 		// --- Table Writer Output ---
 		if len(res.Columns) == 0 {
-			fmt.Println("Statement executed successfully, no columns returned.")
-			// Verbosity level 2 and above: Show timing for non-select statements
-			if verbose >= 2 {
-				fmt.Printf("Query OK")
-				if verbose >= 3 {
-					fmt.Printf(" (%v)", res.Duration)
-				}
-				fmt.Println()
-			}
+			writeNoColumns(w, res, verbose, msgs)
 			return
 		}
 		if len(res.Rows) == 0 {
-			fmt.Println("Empty set.")
-			// Verbosity level 2 and above: Show row count
-			if verbose >= 2 {
-				fmt.Printf("(%d rows in set", res.RowCount)
-				if verbose >= 3 {
-					fmt.Printf(" (%v)", res.Duration)
-				}
-				fmt.Println(")")
-			}
+			fmt.Fprintln(w, msgs.EmptySet)
+			writeRowCount(w, res, verbose, msgs)
 			return
 		}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader(res.Columns)
-		// Settings for MySQL client-like borders and wrapping:
-		table.SetAutoWrapText(true) // Enable text wrapping
-		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-		table.SetAlignment(tablewriter.ALIGN_LEFT)
-		table.SetHeaderLine(true) // Use RowSeparator for header line
-		table.SetBorder(true)     // Enable overall border (+ corners)
-		table.SetRowLine(false)   // Disable lines between data rows
-
-		// Use MySQL client style border characters (+, -, |)
-		table.SetCenterSeparator("+") // Character for intersections
-		table.SetColumnSeparator("|") // Character for vertical lines
-		table.SetRowSeparator("-")    // Character for horizontal lines
-		// Ensure SetBorders is not used, as it can override separators
-
-		// Convert rows to [][]string for tablewriter
-		data := make([][]string, len(res.Rows))
-		for i, row := range res.Rows {
-			data[i] = make([]string, len(row))
-			for j, v := range row {
-				if b, ok := v.([]byte); ok {
-					data[i][j] = string(b)
-				} else if v == nil {
-					data[i][j] = "NULL"
-				} else {
-					data[i][j] = fmt.Sprintf("%v", v)
+		if opts.overTableThreshold(res) {
+			renderLargeTable(w, res, opts)
+		} else {
+			style := opts.tableStyle()
+			var rendered strings.Builder
+			table := tablewriter.NewWriter(&rendered)
+			headerRows := style.headerRows(opts.tableHeader(res))
+			header := make([]string, len(res.Columns))
+			for i := range header {
+				lines := make([]string, len(headerRows))
+				for j, row := range headerRows {
+					lines[j] = style.cell(row[i])
 				}
+				header[i] = strings.Join(lines, "\n") // tablewriter draws a line per \n
 			}
-		}
-		table.AppendBulk(data)
-		table.Render()
+			table.SetHeader(header)
+			// Settings for MySQL client-like wrapping:
+			table.SetAutoWrapText(true) // Enable text wrapping
+			table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeaderLine(true) // Use RowSeparator for header line
+			table.SetRowLine(false)   // Disable lines between data rows
+			style.configure(table)    // Border characters, (+, -, |) by default
 
-		// Verbosity level 2 and above: Show row count for table format
-		if verbose >= 2 {
-			fmt.Printf("(%d rows in set", res.RowCount)
-			if verbose >= 3 {
-				fmt.Printf(" (%v)", res.Duration)
+			// Convert rows to [][]string for tablewriter
+			data := make([][]string, len(res.Rows))
+			for i, row := range res.Rows {
+				data[i] = rowStrings(row)
+				for j := range data[i] {
+					data[i][j] = style.cell(data[i][j])
+				}
 			}
-			fmt.Println(")")
+			table.AppendBulk(data)
+			table.Render()
+			io.WriteString(w, style.fixJunctions(rendered.String()))
 		}
 
+		writeRowCount(w, res, verbose, msgs)
+
 	} else {
 		// --- Standard Tabular Output (Default) ---
 		if len(res.Columns) == 0 {
-			fmt.Println("Statement executed successfully, no columns returned.")
-			// Verbosity level 2 and above: Show timing for non-select statements
-			if verbose >= 2 {
-				fmt.Printf("Query OK")
-				if verbose >= 3 {
-					fmt.Printf(" (%v)", res.Duration)
-				}
-				fmt.Println()
-			}
+			writeNoColumns(w, res, verbose, msgs)
 			return
 		}
-		bold := color.New(color.Bold).SprintFunc()
-		fmt.Println(bold(strings.Join(res.Columns, "\t")))
-		if len(res.Rows) == 0 {
-			fmt.Println("Empty set.")
-			// Verbosity level 2 and above: Show row count
-			if verbose >= 2 {
-				fmt.Printf("(%d rows in set", res.RowCount)
-				if verbose >= 3 {
-					fmt.Printf(" (%v)", res.Duration)
-				}
-				fmt.Println(")")
-			}
-			return
-		}
-		for _, row := range res.Rows {
-			rowStrings := make([]string, len(row))
-			for i, v := range row {
-				if b, ok := v.([]byte); ok {
-					rowStrings[i] = string(b)
-				} else if v == nil {
-					rowStrings[i] = "NULL"
-				} else {
-					rowStrings[i] = fmt.Sprintf("%v", v)
-				}
+		if opts.Align {
+			renderAligned(w, res, opts)
+		} else {
+			bold := opts.paint(color.Bold)
+			fmt.Fprintln(w, bold(strings.Join(res.Columns, "\t")))
+			for _, row := range res.Rows {
+				fmt.Fprintln(w, strings.Join(rowStrings(row), "\t"))
 			}
-			fmt.Println(strings.Join(rowStrings, "\t"))
 		}
-		// Verbosity level 2 and above: Show row count for standard format
-		if verbose >= 2 {
-			fmt.Printf("(%d rows in set", res.RowCount)
-			if verbose >= 3 {
-				fmt.Printf(" (%v)", res.Duration)
-			}
-			fmt.Println(")")
+		if len(res.Rows) == 0 {
+			fmt.Fprintln(w, msgs.EmptySet)
 		}
+		writeRowCount(w, res, verbose, msgs)
 	}
 }
 
@@ -497,50 +989,204 @@ type MyCnf struct {
 	Host     string
 	Port     string
 	Database string
+
+	Path     string         // The option file the settings were read from
+	Warnings []MyCnfWarning // Settings that were ignored or may not mean what they look like
+}
+
+// MyCnfWarning describes an option file setting that was ignored or looks suspicious
+type MyCnfWarning struct {
+	Line    int
+	Key     string // The option name as written
+	Message string
+}
+
+func (w MyCnfWarning) String() string {
+	return fmt.Sprintf("line %d: %s: %s", w.Line, w.Key, w.Message)
 }
 
-// ParseMyCnf parses ~/.my.cnf for credentials
+// ErrInsecureMyCnf is returned for option files that other users can read. go-csql
+// injects the credentials from it into every connection, so it refuses to use them.
+var ErrInsecureMyCnf = errors.New("option file is readable by other users")
+
+// myCnfKeyVal matches "key = value"; option names may use - or _ as separators
+var myCnfKeyVal = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_-]*)\s*=\s*(.*)$`)
+
+// myCnfGroup matches a "[group]" header
+var myCnfGroup = regexp.MustCompile(`^\[\s*([^\]]*?)\s*\]`)
+
+// myCnfGroups are the option groups read, in the order they apply: the mysql
+// client reads [client] and then [mysql], whose settings override
+var myCnfGroups = []string{"client", "mysql"}
+
+// credentialHints mark option names that look like credentials or authentication
+// settings; unrecognized ones are reported rather than silently ignored
+var credentialHints = []string{"user", "pass", "pwd", "login", "auth", "secret", "token"}
+
+// ErrMyCnfNotFound is returned by ParseMyCnfFrom for an option file that does not exist
+var ErrMyCnfNotFound = errors.New("option file does not exist")
+
+// ParseMyCnf parses ~/.my.cnf for credentials. A missing file is reported as
+// ErrMyCnfNotFound, which callers reading the default file usually ignore.
 func ParseMyCnf() (*MyCnf, error) {
 	usr, err := user.Current()
 	if err != nil {
 		return nil, err
 	}
-	path := filepath.Join(usr.HomeDir, ".my.cnf")
+	return ParseMyCnfFrom(filepath.Join(usr.HomeDir, ".my.cnf"))
+}
+
+// ParseMyCnfFrom parses the option file at path, e.g. /etc/mysql/client.cnf, as
+// ParseMyCnfFile does, returning ErrMyCnfNotFound if it does not exist
+func ParseMyCnfFrom(path string) (*MyCnf, error) {
+	cnf, err := ParseMyCnfFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", path, ErrMyCnfNotFound)
+	}
+	return cnf, err
+}
+
+// ParseMyCnfFile parses an option file in .my.cnf format. Only the [client] and
+// [mysql] groups are read, [mysql] overriding [client] wherever they appear in the
+// file, so the host of a [mysqldump] group does not leak into connections. Values
+// may be quoted and followed by # comments, and option names may carry the loose-
+// prefix. Unrecognized options that look like credentials are recorded in Warnings.
+// Files readable by other users are rejected with ErrInsecureMyCnf.
+func ParseMyCnfFile(path string) (*MyCnf, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	cnf := &MyCnf{}
+	if info, err := f.Stat(); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o004 != 0 {
+		return nil, fmt.Errorf("%s: %w (mode %04o); restrict it with chmod 600", path, ErrInsecureMyCnf, info.Mode().Perm())
+	}
+
+	// Settings of each group, applied in group order once the file is read
+	type setting struct {
+		line       int
+		key, value string
+		name       string // The option name as written
+	}
+	settings := make(map[string][]setting)
+	cnf := &MyCnf{Path: path}
+	group := ""
 	scanner := bufio.NewScanner(f)
-	keyVal := regexp.MustCompile(`^([a-zA-Z_]+)\s*=\s*(.*)$`)
+	lineNo := 0
 	for scanner.Scan() {
-		line := scanner.Text()
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
 		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
 			continue
 		}
-		m := keyVal.FindStringSubmatch(line)
-		if len(m) == 3 {
-			switch strings.ToLower(m[1]) {
+		if m := myCnfGroup.FindStringSubmatch(line); m != nil {
+			group = strings.ToLower(m[1])
+			continue
+		}
+		m := myCnfKeyVal.FindStringSubmatch(line)
+		if len(m) != 3 || !slices.Contains(myCnfGroups, group) {
+			continue
+		}
+		key := strings.ReplaceAll(strings.ToLower(m[1]), "-", "_")
+		key = strings.TrimPrefix(key, "loose_")
+		value, problem := parseOptionValue(m[2])
+		if problem != "" {
+			cnf.Warnings = append(cnf.Warnings, MyCnfWarning{Line: lineNo, Key: m[1], Message: problem})
+		}
+		settings[group] = append(settings[group], setting{line: lineNo, key: key, value: value, name: m[1]})
+	}
+	for _, group := range myCnfGroups {
+		for _, opt := range settings[group] {
+			switch opt.key {
 			case "user":
-				cnf.User = m[2]
+				cnf.User = opt.value
 			case "password":
-				cnf.Password = m[2]
+				cnf.Password = opt.value
 			case "host":
-				cnf.Host = m[2]
+				cnf.Host = opt.value
 			case "port":
-				cnf.Port = m[2]
+				cnf.Port = opt.value
 			case "database":
-				cnf.Database = m[2]
+				cnf.Database = opt.value
+			default:
+				for _, hint := range credentialHints {
+					if strings.Contains(opt.key, hint) {
+						cnf.Warnings = append(cnf.Warnings, MyCnfWarning{Line: opt.line, Key: opt.name,
+							Message: "looks like a credential but is not supported by go-csql; ignored"})
+						break
+					}
+				}
 			}
 		}
 	}
+	slices.SortStableFunc(cnf.Warnings, func(a, b MyCnfWarning) int { return a.Line - b.Line })
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
 	return cnf, nil
 }
 
-// FillDSN fills missing DSN parts from MyCnf
-func FillDSN(dsn string, cnf *MyCnf) string {
+// parseOptionValue returns an option's value without surrounding quotes or a
+// trailing # comment, the way the mysql client reads it. problem is set when the
+// value is likely not what was meant.
+func parseOptionValue(raw string) (value string, problem string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		value, _, _ = strings.Cut(raw, "#")
+		return strings.TrimSpace(value), ""
+	}
+
+	quote := raw[0]
+	var b strings.Builder
+	for i := 1; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 's':
+				b.WriteByte(' ')
+			default:
+				b.WriteByte(raw[i])
+			}
+		case c == quote:
+			rest := strings.TrimSpace(raw[i+1:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return b.String(), fmt.Sprintf("unexpected %q after the quoted value; ignored", rest)
+			}
+			return b.String(), ""
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return raw, "unterminated quote; the value is used as written, quotes included"
+}
+
+// Where the parts of a DSN came from, as reported by FillDSN
+const (
+	SourceNone    = ""        // Neither given nor filled
+	SourceDSN     = "dsn"     // Given in the DSN itself
+	SourceMyCnf   = "my.cnf"  // Filled from the option file
+	SourceDefault = "default" // Filled with the built-in localhost:3306
+)
+
+// DSNProvenance records where each part of a DSN returned by FillDSN came from,
+// to explain for example why a connection logs in as an unexpected user
+type DSNProvenance struct {
+	User     string
+	Password string
+	Host     string
+	Port     string
+	Database string
+}
+
+// FillDSN fills missing DSN parts from MyCnf and reports where each part came
+// from. A nil cnf fills nothing and only reports which parts the DSN gives.
+func FillDSN(dsn string, cnf *MyCnf) (string, DSNProvenance) {
 	// Only fill if DSN is missing user/password/host/port/db
 	user, pass, netloc, db := "", "", "", ""
 	// Parse DSN: user:pass@tcp(host:port)/db
@@ -561,25 +1207,40 @@ func FillDSN(dsn string, cnf *MyCnf) string {
 			db = netdb[1]
 		}
 	}
+
+	var prov DSNProvenance
+	given := func(part string) string {
+		if part != "" {
+			return SourceDSN
+		}
+		return SourceNone
+	}
+	prov.User, prov.Password, prov.Host, prov.Port, prov.Database = given(user), given(pass), given(netloc), given(netloc), given(db)
+	if cnf == nil {
+		return dsn, prov
+	}
+
 	if user == "" && cnf.User != "" {
-		user = cnf.User
+		user, prov.User = cnf.User, SourceMyCnf
 	}
 	if pass == "" && cnf.Password != "" {
-		pass = cnf.Password
+		pass, prov.Password = cnf.Password, SourceMyCnf
 	}
 	if netloc == "" {
 		host := "localhost"
+		prov.Host = SourceDefault
 		if cnf.Host != "" {
-			host = cnf.Host
+			host, prov.Host = cnf.Host, SourceMyCnf
 		}
 		port := "3306"
+		prov.Port = SourceDefault
 		if cnf.Port != "" {
-			port = cnf.Port
+			port, prov.Port = cnf.Port, SourceMyCnf
 		}
 		netloc = "tcp(" + host + ":" + port + ")"
 	}
 	if db == "" && cnf.Database != "" {
-		db = cnf.Database
+		db, prov.Database = cnf.Database, SourceMyCnf
 	}
-	return user + ":" + pass + "@" + netloc + "/" + db
+	return user + ":" + pass + "@" + netloc + "/" + db, prov
 }
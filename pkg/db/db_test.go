@@ -1,9 +1,19 @@
 package db
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
 )
 
 func TestSplitSQLStatements(t *testing.T) {
@@ -100,6 +110,56 @@ func TestSplitSQLStatements(t *testing.T) {
 				{SQL: "SELECT 2", Vertical: false},
 			},
 		},
+		{
+			name:  "lowercase \\g terminator",
+			input: "SELECT 1\\g SELECT 2\\g",
+			expected: []StatementInfo{
+				{SQL: "SELECT 1", Vertical: false},
+				{SQL: "SELECT 2", Vertical: false},
+			},
+		},
+		{
+			name:  "mixed \\g, ; and \\G",
+			input: "SELECT 1\\g\nSELECT * FROM users\\G\nSELECT 2; SELECT 3 \\g;\nSELECT 4\\G;",
+			expected: []StatementInfo{
+				{SQL: "SELECT 1", Vertical: false},
+				{SQL: "SELECT * FROM users", Vertical: true},
+				{SQL: "SELECT 2", Vertical: false},
+				{SQL: "SELECT 3", Vertical: false},
+				{SQL: "SELECT 4", Vertical: true},
+			},
+		},
+		{
+			name:  "\\g inside strings and comments",
+			input: "SELECT 'a\\g', \"b\\G\" /* c\\g */\\g -- d\\g\nSELECT `e\\g`;",
+			expected: []StatementInfo{
+				{SQL: "SELECT 'a\\g', \"b\\G\" /* c\\g */", Vertical: false},
+				{SQL: "-- d\\g\nSELECT `e\\g`", Vertical: false},
+			},
+		},
+		{
+			name:  "\\c discards the statement so far",
+			input: "SELECT 1;\nDELETE FROM orders\nWHERE id > \\c\nSELECT 2;",
+			expected: []StatementInfo{
+				{SQL: "SELECT 1", Vertical: false},
+				{SQL: "SELECT 2", Vertical: false},
+			},
+		},
+		{
+			name:  "\\c at the end leaves nothing to run",
+			input: "SELECT 1; UPDATE t SET c = 1\\c",
+			expected: []StatementInfo{
+				{SQL: "SELECT 1", Vertical: false},
+			},
+		},
+		{
+			name:  "\\c inside strings and comments",
+			input: "SELECT 'a\\c', `b\\c` /* c\\c */ -- d\\c\n;\\c SELECT 3\\G",
+			expected: []StatementInfo{
+				{SQL: "SELECT 'a\\c', `b\\c` /* c\\c */ -- d\\c", Vertical: false},
+				{SQL: "SELECT 3", Vertical: true},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -227,6 +287,142 @@ func TestParseMyCnf(t *testing.T) {
 	}
 }
 
+// writeMyCnf writes an option file with the given permissions to a temp dir
+func writeMyCnf(t *testing.T, content string, perm os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".my.cnf")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil { // Not subject to the umask
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseMyCnfFrom(t *testing.T) {
+	cnf, err := ParseMyCnfFrom(writeMyCnf(t, "[client]\nuser=alice\n", 0600))
+	if err != nil || cnf.User != "alice" {
+		t.Fatalf("ParseMyCnfFrom() = %+v, %v; want user alice", cnf, err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "client.cnf")
+	if _, err := ParseMyCnfFrom(missing); !errors.Is(err, ErrMyCnfNotFound) || !strings.Contains(err.Error(), missing) {
+		t.Errorf("ParseMyCnfFrom() of a missing file error = %v, want ErrMyCnfNotFound naming the file", err)
+	}
+}
+
+func TestParseMyCnfFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		want         MyCnf
+		wantWarnings []string
+	}{
+		{
+			name:    "plain values",
+			content: "[client]\nuser=app\npassword=s3cret\nhost=db1\nport=3307\ndatabase=shop\n",
+			want:    MyCnf{User: "app", Password: "s3cret", Host: "db1", Port: "3307", Database: "shop"},
+		},
+		{
+			name:    "quoted values lose their quotes",
+			content: "[client]\npassword = \"abc\"\nuser = 'app'\nhost=\"db # 1\"  # quoted hash is kept\n",
+			want:    MyCnf{User: "app", Password: "abc", Host: "db # 1"},
+		},
+		{
+			name:    "escapes in quoted values",
+			content: "[client]\npassword=\"a\\\"b\\\\c\\sd\"\n",
+			want:    MyCnf{Password: `a"b\c d`},
+		},
+		{
+			name:    "inline comments",
+			content: "[client]\nhost = db1.example.com # primary\nport=3306#default\n",
+			want:    MyCnf{Host: "db1.example.com", Port: "3306"},
+		},
+		{
+			name:    "loose prefix and dashes",
+			content: "[client]\nloose-user=app\nloose_password=pw\nLOOSE-HOST=db1\n",
+			want:    MyCnf{User: "app", Password: "pw", Host: "db1"},
+		},
+		{
+			name:    "unrecognized credential-like keys warn",
+			content: "[client]\nuser=app\npasswd=pw\nlogin-path=prod\ndefault-character-set=utf8mb4\nssl-mode=REQUIRED\n",
+			want:    MyCnf{User: "app"},
+			wantWarnings: []string{
+				"line 3: passwd: looks like a credential but is not supported by go-csql; ignored",
+				"line 4: login-path: looks like a credential but is not supported by go-csql; ignored",
+			},
+		},
+		{
+			name: "only the client and mysql groups are read",
+			content: "host=nogroup\n[mysql]\nhost=db2\ndatabase=\"shop\"\n[mysqldump]\nhost=dump-host\nuser=dumper\n" +
+				"[client]\nuser=app\nhost=db1\npassword=\"p@ss\"\n[ Client ]\nport=3307\n[mysqld]\nport=3306\n",
+			want: MyCnf{User: "app", Password: "p@ss", Host: "db2", Port: "3307", Database: "shop"},
+		},
+		{
+			name:    "credential-like keys of other groups are not reported",
+			content: "[client]\nuser=app\n[mysqldump]\npasswd=pw\n[mysql]\nlogin-path=prod\n",
+			want:    MyCnf{User: "app"},
+			wantWarnings: []string{
+				"line 6: login-path: looks like a credential but is not supported by go-csql; ignored",
+			},
+		},
+		{
+			name:    "suspicious quoting warns",
+			content: "[client]\npassword=\"abc\nuser='app' extra\n",
+			want:    MyCnf{Password: `"abc`, User: "app"},
+			wantWarnings: []string{
+				"line 2: password: unterminated quote; the value is used as written, quotes included",
+				`line 3: user: unexpected "extra" after the quoted value; ignored`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeMyCnf(t, tt.content, 0600)
+			cnf, err := ParseMyCnfFile(path)
+			if err != nil {
+				t.Fatalf("ParseMyCnfFile() error = %v", err)
+			}
+			var warnings []string
+			for _, w := range cnf.Warnings {
+				warnings = append(warnings, w.String())
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("Warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+			cnf.Warnings, tt.want.Path = nil, path
+			if !reflect.DeepEqual(*cnf, tt.want) {
+				t.Errorf("ParseMyCnfFile() = %+v, want %+v", *cnf, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMyCnfFile_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	tests := []struct {
+		perm    os.FileMode
+		wantErr bool
+	}{
+		{perm: 0600},
+		{perm: 0640},
+		{perm: 0644, wantErr: true},
+		{perm: 0604, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.perm.String(), func(t *testing.T) {
+			_, err := ParseMyCnfFile(writeMyCnf(t, "[client]\npassword=pw\n", tt.perm))
+			if got := errors.Is(err, ErrInsecureMyCnf); got != tt.wantErr {
+				t.Errorf("ParseMyCnfFile() error = %v, want insecure %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestFillDSN(t *testing.T) {
 	cnf := &MyCnf{
 		User:     "testuser",
@@ -270,7 +466,7 @@ func TestFillDSN(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := FillDSN(tt.dsn, cnf)
+			result, _ := FillDSN(tt.dsn, cnf)
 			if result != tt.expected {
 				t.Errorf("FillDSN() = %q, expected %q", result, tt.expected)
 			}
@@ -278,6 +474,107 @@ func TestFillDSN(t *testing.T) {
 	}
 }
 
+func TestFillDSN_Provenance(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		cnf  *MyCnf
+		want DSNProvenance
+	}{
+		{
+			name: "user from DSN, password and database from my.cnf",
+			dsn:  "app:@tcp(db1:3306)/",
+			cnf:  &MyCnf{User: "root", Password: "pw", Database: "prod"},
+			want: DSNProvenance{User: SourceDSN, Password: SourceMyCnf, Host: SourceDSN, Port: SourceDSN, Database: SourceMyCnf},
+		},
+		{
+			name: "user from my.cnf, address defaulted",
+			dsn:  "@/app",
+			cnf:  &MyCnf{User: "root", Port: "3307"},
+			want: DSNProvenance{User: SourceMyCnf, Host: SourceDefault, Port: SourceMyCnf, Database: SourceDSN},
+		},
+		{
+			name: "nothing to fill from",
+			dsn:  "app:secret@tcp(db1:3306)/",
+			cnf:  &MyCnf{},
+			want: DSNProvenance{User: SourceDSN, Password: SourceDSN, Host: SourceDSN, Port: SourceDSN},
+		},
+		{
+			name: "no option file",
+			dsn:  "app:@tcp(db1:3306)/app",
+			want: DSNProvenance{User: SourceDSN, Host: SourceDSN, Port: SourceDSN, Database: SourceDSN},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, got := FillDSN(tt.dsn, tt.cnf)
+			if got != tt.want {
+				t.Errorf("FillDSN() provenance = %+v, want %+v", got, tt.want)
+			}
+			if tt.cnf == nil && dsn != tt.dsn {
+				t.Errorf("FillDSN() without an option file = %q, want the DSN unchanged", dsn)
+			}
+		})
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_UsePersists(t *testing.T) {
+	useFakeDriver(t)
+
+	srv := dbtest.NewServer(t, "use-1")
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+		"SELECT DATABASE(); USE otherdb; SELECT DATABASE(); SELECT 1; SELECT DATABASE()", ExecOptions{})
+
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5", len(results))
+	}
+	wantSchemas := map[int]string{0: "app", 2: "otherdb", 4: "otherdb"}
+	for i, want := range wantSchemas {
+		res := results[i]
+		if res.Err != nil || len(res.Rows) != 1 || res.Rows[0][0] != want {
+			t.Errorf("statement %d (%s): rows = %v, err = %v, want schema %q", i, res.Statement, res.Rows, res.Err, want)
+		}
+	}
+	if srv.Opened() != 1 {
+		t.Errorf("opened %d connections, want a single session", srv.Opened())
+	}
+}
+
+func TestRunSQLOnSession_RecoversStatementPanic(t *testing.T) {
+	useFakeDriver(t)
+
+	srv := dbtest.NewServer(t, "panic-1")
+	srv.Handle("SELECT bad", dbtest.Response{
+		Columns: []string{"v"},
+		Rows:    dbtest.IntRows(2),
+		Panic:   "formatter exploded",
+	})
+	srv.Handle("SELECT good", dbtest.Response{Columns: []string{"v"}, Rows: dbtest.IntRows(3)})
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+		"SELECT good; SELECT bad; SELECT good", ExecOptions{})
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, i := range []int{0, 2} {
+		if results[i].Err != nil || results[i].RowCount != 3 {
+			t.Errorf("statement %d: rows = %d, err = %v, want 3 rows and no error", i, results[i].RowCount, results[i].Err)
+		}
+	}
+	bad := results[1]
+	if bad.Err == nil || !strings.Contains(bad.Err.Error(), "formatter exploded") {
+		t.Errorf("panicking statement err = %v, want the recovered panic", bad.Err)
+	}
+	if bad.Statement != "SELECT bad" {
+		t.Errorf("panicking statement reported as %q", bad.Statement)
+	}
+	if srv.Opened() != 1 {
+		t.Errorf("opened %d connections, want the session to survive the panic", srv.Opened())
+	}
+}
+
 func TestStatementInfo(t *testing.T) {
 	// Test StatementInfo struct
 	stmt := StatementInfo{
@@ -313,6 +610,135 @@ func BenchmarkSplitSQLStatementsWithStrings(b *testing.B) {
 	}
 }
 
+func TestSplitSQLStatementsReader(t *testing.T) {
+	// bufio path: a plain io.Reader that is not an io.RuneScanner
+	input := strings.Repeat("SELECT 'a;b' /* ; */; -- ;\n", 1000)
+	got, err := splitSQLStatementsReader(iotest.OneByteReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("splitSQLStatementsReader() error = %v", err)
+	}
+	want := splitSQLStatements(input)
+	if len(got) != len(want) {
+		t.Fatalf("splitSQLStatementsReader() returned %d statements, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("statement %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	readErr := errors.New("disk on fire")
+	_, err = splitSQLStatementsReader(iotest.ErrReader(readErr))
+	if !errors.Is(err, readErr) {
+		t.Errorf("splitSQLStatementsReader() error = %v, want %v", err, readErr)
+	}
+}
+
+func TestSplitSQLStatements_Lines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []int
+	}{
+		{
+			name:  "one per line",
+			input: "SELECT 1;\nSELECT 2;\nSELECT 3;",
+			want:  []int{1, 2, 3},
+		},
+		{
+			name:  "blank lines",
+			input: "\n\nSELECT 1;\n\n\nSELECT 2;\n",
+			want:  []int{3, 6},
+		},
+		{
+			name:  "leading comments are skipped",
+			input: "SELECT 1;\n-- Backfill\n/* in\n   batches */\nUPDATE t SET c = 1;",
+			want:  []int{1, 5},
+		},
+		{
+			name:  "comment after the first token",
+			input: "SELECT 1; /* trailing */\nSELECT -- inline\n  2",
+			want:  []int{1, 2},
+		},
+		{
+			name:  "CRLF endings",
+			input: "SELECT 1;\r\n\r\n/* header\r\n */\r\nSELECT\r\n  2;\r\nSELECT 3",
+			want:  []int{1, 5, 7},
+		},
+		{
+			name:  "line breaks inside strings are counted",
+			input: "INSERT INTO t VALUES ('a\nb\\\nc');\nSELECT 2;",
+			want:  []int{1, 4},
+		},
+		{
+			name:  "\\c restarts the statement",
+			input: "SELECT 1;\n-- oops\nDELETE FROM t\\c\n\nSELECT 2;",
+			want:  []int{1, 5},
+		},
+		{
+			name:  "block comment opening inside a line comment",
+			input: "SELECT 1; -- see /* notes\nSELECT 2;\nSELECT 3;",
+			want:  []int{1, 2, 3},
+		},
+		{
+			name:  "line comment opening inside a block comment",
+			input: "/* drop -- old */ SELECT 1; SELECT 2;\nSELECT 3;",
+			want:  []int{1, 1, 2},
+		},
+		{
+			name:  "statements of comments only start at the comment",
+			input: "SELECT 1;\n\n/*!40101 SET NAMES utf8mb4 */;\nSELECT 2\\G\nSELECT 3",
+			want:  []int{1, 3, 4, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamed, err := splitSQLStatementsReader(iotest.OneByteReader(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, statements := range map[string][]StatementInfo{"in memory": splitSQLStatements(tt.input), "streamed": streamed} {
+				var got []int
+				for _, stmt := range statements {
+					got = append(got, stmt.Line)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s: lines = %v, want %v (statements %+v)", name, got, tt.want, statements)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderResult_ErrorLocation(t *testing.T) {
+	res := QueryResult{Instance: "dsn", Statement: "DROP TABLE t", Err: errors.New("Error 1051: Unknown table 'app.t'"), Line: 412}
+	var buf bytes.Buffer
+	RenderResult(&buf, res, nil, PrintOptions{Plain: true})
+	if got, want := buf.String(), "[dsn] ERROR DROP TABLE t: Error 1051: Unknown table 'app.t'\n"; got != want {
+		t.Errorf("without a source: %q, want %q", got, want)
+	}
+
+	res.Source = "deploy.sql"
+	buf.Reset()
+	RenderResult(&buf, res, nil, PrintOptions{Plain: true})
+	if got, want := buf.String(), "[dsn] ERROR deploy.sql:412: DROP TABLE t: Error 1051: Unknown table 'app.t'\n"; got != want {
+		t.Errorf("with a source: %q, want %q", got, want)
+	}
+}
+
+// BenchmarkSplitSQLStatementsLarge splits a multi-MB script; the streaming splitter
+// avoids allocating a rune slice of the whole input.
+func BenchmarkSplitSQLStatementsLarge(b *testing.B) {
+	sql := strings.Repeat("INSERT INTO t (a, b) VALUES (1, 'some; text'), (2, 'more text'); -- comment\n", 50000)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(sql)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		splitSQLStatements(sql)
+	}
+}
+
 func BenchmarkMaskPasswordInDSN(b *testing.B) {
 	dsn := "user:very_long_complex_password_with_special_chars!@#$%^&*()@tcp(very.long.hostname.example.com:3306)/very_long_database_name"
 
@@ -321,3 +747,202 @@ func BenchmarkMaskPasswordInDSN(b *testing.B) {
 		maskPasswordInDSN(dsn)
 	}
 }
+
+func TestRenderResult_VerticalRowMarkers(t *testing.T) {
+	rows := func(n int) [][]interface{} {
+		out := make([][]interface{}, n)
+		for i := range out {
+			out[i] = []interface{}{int64(i + 1)}
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		rows      int
+		separator int
+		want      []string // Markers of the first rows, then the last one
+	}{
+		{name: "single digit", rows: 3, want: []string{"******************** 1. row ********************", "******************** 3. row ********************"}},
+		{name: "two digits", rows: 12, want: []string{"******************** 01. row ********************", "******************** 12. row ********************"}},
+		{name: "three digits", rows: 100, separator: 3, want: []string{"*** 001. row ***", "*** 100. row ***"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := QueryResult{Instance: "db1", Columns: []string{"id"}, Rows: rows(tt.rows), VerticalFormat: true}
+			var buf bytes.Buffer
+			RenderResult(&buf, res, nil, PrintOptions{Plain: true, VerticalSeparator: tt.separator})
+
+			var markers []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(line, "*") {
+					markers = append(markers, line)
+				}
+			}
+			if len(markers) != tt.rows {
+				t.Fatalf("got %d row markers, want %d:\n%s", len(markers), tt.rows, buf.String())
+			}
+			if got := []string{markers[0], markers[len(markers)-1]}; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("markers = %q, want %q", got, tt.want)
+			}
+			for _, marker := range markers {
+				if len(marker) != len(markers[0]) {
+					t.Errorf("marker %q is not as wide as %q", marker, markers[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderResult_VerticalHeaders(t *testing.T) {
+	res := QueryResult{Instance: "u:secret@tcp(db1:3306)/", Statement: "SELECT id", Columns: []string{"id"}, Rows: [][]interface{}{{int64(7)}}, VerticalFormat: true}
+	var buf bytes.Buffer
+	RenderResult(&buf, res, nil, PrintOptions{Plain: true, VerticalSeparator: 3, VerticalHeaders: true})
+	want := "[u:****@tcp(db1:3306)/] SELECT id\n=== u:****@tcp(db1:3306)/ ===\n*** 1. row ***\nid: 7\n"
+	if got := buf.String(); got != want {
+		t.Errorf("RenderResult() = %q, want %q", got, want)
+	}
+
+	// Without rows there are no markers to head
+	buf.Reset()
+	res.Rows = nil
+	RenderResult(&buf, res, nil, PrintOptions{Plain: true, VerticalHeaders: true})
+	if strings.Contains(buf.String(), "===") {
+		t.Errorf("RenderResult() of an empty result = %q, want no instance header", buf.String())
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_ExecOnly(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "exec-only")
+	srv.Handle("UPDATE t SET x = 1", dbtest.Response{Rows: dbtest.IntRows(3)}) // The fake reports its rows as affected
+	srv.Handle("SELECT n FROM five", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(5)})
+	srv.Handle("DROP TABLE gone", dbtest.Response{Err: errors.New("Error 1051: Unknown table 'gone'")})
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+		"UPDATE t SET x = 1; SELECT n FROM five; DROP TABLE gone", ExecOptions{ExecOnly: true})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if res := results[0]; res.Err != nil || res.RowsAffected != 3 {
+		t.Errorf("UPDATE: %d row(s) affected, error %v; want 3 and no error", res.RowsAffected, res.Err)
+	}
+	if res := results[1]; res.Err != nil || res.Columns != nil || res.Rows != nil || res.RowCount != 0 {
+		t.Errorf("SELECT: columns %v, %d row(s), error %v; want the result set discarded", res.Columns, res.RowCount, res.Err)
+	}
+	if res := results[2]; res.Err == nil || !strings.Contains(res.Err.Error(), "Unknown table") {
+		t.Errorf("DROP: error %v, want the server's error", res.Err)
+	}
+	if got := srv.Executed(); len(got) != 3 {
+		t.Errorf("executed %q, want all three statements", got)
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_OnRow(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "on-row")
+	srv.Handle("SELECT n FROM slow", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(3), RowDelay: 20 * time.Millisecond})
+
+	start := time.Now()
+	var got []interface{}
+	var arrivals []time.Duration
+	onRow := func(instanceDSN, statement string, columns []string, row []interface{}) {
+		if instanceDSN != srv.DSN() || statement != "SELECT n FROM slow" || !reflect.DeepEqual(columns, []string{"n"}) {
+			t.Errorf("OnRow(%q, %q, %q), want the instance, statement and columns", instanceDSN, statement, columns)
+		}
+		got = append(got, row[0])
+		arrivals = append(arrivals, time.Since(start))
+	}
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), "SELECT n FROM slow", ExecOptions{OnRow: onRow})
+	elapsed := time.Since(start)
+
+	if len(results) != 1 || results[0].Err != nil || results[0].RowCount != 3 || results[0].Rows != nil {
+		t.Fatalf("results = %+v, want 3 rows counted and none kept", results)
+	}
+	if !reflect.DeepEqual(got, []interface{}{int64(1), int64(2), int64(3)}) {
+		t.Errorf("rows handed over = %v, want 1, 2, 3", got)
+	}
+	// The first row arrives while the others are still being read
+	if len(arrivals) > 0 && arrivals[0] >= elapsed-20*time.Millisecond {
+		t.Errorf("first row handed over after %v of %v, want it before the statement finished", arrivals[0], elapsed)
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_FirstRowOnly(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "first-row-only")
+	srv.Handle("SELECT n FROM five", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(5)})
+	srv.Handle("SELECT n FROM one", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1)})
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+		"SELECT n FROM five; SELECT n FROM one", ExecOptions{FirstRowOnly: true})
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	tests := []struct {
+		res         QueryResult
+		wantOmitted int
+		wantNote    string
+	}{
+		{res: results[0], wantOmitted: 4, wantNote: "(4 more rows omitted)"},
+		{res: results[1], wantOmitted: 0},
+	}
+	for _, tt := range tests {
+		if tt.res.Err != nil || len(tt.res.Rows) != 1 || tt.res.RowCount != 1 || tt.res.OmittedRows != tt.wantOmitted {
+			t.Errorf("%s: %d rows (count %d), %d omitted, error %v; want 1 row and %d omitted",
+				tt.res.Statement, len(tt.res.Rows), tt.res.RowCount, tt.res.OmittedRows, tt.res.Err, tt.wantOmitted)
+		}
+		for _, opts := range []PrintOptions{{Plain: true}, {Plain: true, TableFormat: true}} {
+			var buf bytes.Buffer
+			RenderResult(&buf, tt.res, nil, opts)
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			last := lines[len(lines)-1]
+			if tt.wantNote != "" && last != tt.wantNote {
+				t.Errorf("%s (table %t): last line %q, want the note %q", tt.res.Statement, opts.TableFormat, last, tt.wantNote)
+			}
+			if tt.wantNote == "" && strings.Contains(buf.String(), "omitted") {
+				t.Errorf("%s (table %t): unexpected note:\n%s", tt.res.Statement, opts.TableFormat, buf.String())
+			}
+		}
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_QueryTimeout(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "query-timeout")
+	srv.Handle("SELECT * FROM locked", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1), Delay: time.Minute})
+	srv.Handle("SELECT n FROM slow", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(100), RowDelay: 10 * time.Millisecond})
+	srv.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: dbtest.IntRows(1)})
+	const script = "USE shop; SELECT * FROM locked; SELECT n FROM slow; SELECT 1"
+	timeout := 50 * time.Millisecond
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), script, ExecOptions{QueryTimeout: timeout})
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for _, res := range results[1:3] {
+		var timeoutErr *QueryTimeoutError
+		if !errors.As(res.Err, &timeoutErr) || !errors.Is(res.Err, context.DeadlineExceeded) || res.Skipped {
+			t.Errorf("%s: error %v, want a *QueryTimeoutError", res.Statement, res.Err)
+		}
+		if res.Err == nil || !strings.Contains(res.Err.Error(), "query timed out after 50ms") {
+			t.Errorf("%s: error %q, want it to say how long the statement was allowed", res.Statement, res.Err)
+		}
+		if res.Duration < timeout && res.Processing < timeout {
+			t.Errorf("%s: took %v + %v, want the time up to the timeout recorded", res.Statement, res.Duration, res.Processing)
+		}
+	}
+	// The session reconnected, restoring USE, and went on
+	if res := results[3]; res.Err != nil || res.RowCount != 1 {
+		t.Errorf("statement after the timeouts: %d row(s), error %v; want it run", res.RowCount, res.Err)
+	}
+	if got, want := srv.Executed(), []string{"USE shop", "SELECT * FROM locked", "USE shop", "SELECT n FROM slow", "USE shop", "SELECT 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+
+	// Stopping at the first error skips the rest instead
+	results = RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), script, ExecOptions{QueryTimeout: timeout, MaxErrorsPerInstance: 1})
+	var abandoned *AbandonedError
+	if len(results) != 4 || !errors.As(results[2].Err, &abandoned) || !results[3].Skipped {
+		t.Errorf("with MaxErrorsPerInstance 1, results = %+v; want those after the timeout skipped", results)
+	}
+}
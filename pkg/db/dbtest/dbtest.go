@@ -0,0 +1,401 @@
+// Package dbtest provides a scripted in-memory database/sql driver for testing
+// go-csql's execution paths without a MySQL server.
+//
+// Each fake Server is addressed by the host in a MySQL-style DSN, so DSNs such as
+// "user:pass@tcp(fake-1:3306)/app" keep working with the DSN helpers in pkg/db.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DriverName is the name the fake driver is registered under
+const DriverName = "csqlfake"
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Server{}
+
+	connecting    atomic.Int64 // Handshakes in progress across all servers
+	maxConnecting atomic.Int64 // High-water mark of concurrent handshakes
+	openConns     atomic.Int64 // Connections currently open across all servers
+	maxOpenConns  atomic.Int64 // High-water mark of openConns
+)
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+// Response scripts the outcome of a query on a fake server
+type Response struct {
+	Columns  []string
+	Types    []string // Database type names, parallel to Columns (default VARCHAR)
+	Nullable []bool   // Whether each column may hold NULL, parallel to Columns (default unknown)
+	Lengths  []int64  // Length of each column, parallel to Columns (default unknown)
+	Rows     [][]driver.Value
+	Err      error
+	Delay    time.Duration // Time before the query returns, aborted by context cancellation
+	RowDelay time.Duration // Time before each row is produced
+	Panic    interface{}   // If set, the driver panics with this value after producing Rows
+}
+
+// Server is a scripted fake MySQL server
+type Server struct {
+	Host string
+
+	mu        sync.Mutex
+	responses map[string][]Response
+	fallback  *Response
+	connErr   error
+	connErrs  []error // Outcomes of the next connections, before connErr applies
+	connDelay time.Duration
+	executed  []Execution
+
+	opened  atomic.Int64 // Connections opened over the server's lifetime
+	open    atomic.Int64 // Connections currently open
+	maxOpen atomic.Int64 // High-water mark of simultaneously open connections
+}
+
+// NewServer registers a fake server for host and unregisters it when the test ends
+func NewServer(t testing.TB, host string) *Server {
+	t.Helper()
+	s := &Server{Host: host, responses: map[string][]Response{}}
+
+	registryMu.Lock()
+	registry[host] = s
+	registryMu.Unlock()
+
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, host)
+		registryMu.Unlock()
+	})
+	return s
+}
+
+// DSN returns a MySQL-style DSN addressing this server
+func (s *Server) DSN() string {
+	return "user:secret@tcp(" + s.Host + ":3306)/app"
+}
+
+// Handle scripts the response for an exact statement
+func (s *Server) Handle(query string, resp Response) {
+	s.HandleSequence(query, resp)
+}
+
+// HandleSequence scripts successive responses for an exact statement; the last
+// response repeats once the sequence is exhausted.
+func (s *Server) HandleSequence(query string, resps ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[query] = resps
+}
+
+// Fallback scripts the response for statements without a specific handler.
+// Without a fallback, unknown statements succeed with no result set.
+func (s *Server) Fallback(resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = &resp
+}
+
+// FailConnect makes new connections fail with err (nil restores connectivity)
+func (s *Server) FailConnect(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connErr = err
+}
+
+// FailConnectSequence makes successive new connections fail with each error in
+// turn, a nil error letting that connection through; once the sequence is
+// exhausted, FailConnect applies again.
+func (s *Server) FailConnectSequence(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connErrs = errs
+}
+
+// ConnectDelay makes each new connection take d to establish. A DSN with a
+// shorter timeout parameter fails once it passes, as the driver's dial would.
+func (s *Server) ConnectDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connDelay = d
+}
+
+// Execution records a statement run on a fake server and when it ran
+type Execution struct {
+	Query    string
+	Started  time.Time
+	Finished time.Time // When the scripted delay ended; zero while still running
+}
+
+// Executed returns the statements executed so far, in order
+func (s *Server) Executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries := make([]string, len(s.executed))
+	for i, e := range s.executed {
+		queries[i] = e.Query
+	}
+	return queries
+}
+
+// Executions returns the statements executed so far with their timing, in order
+func (s *Server) Executions() []Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Execution(nil), s.executed...)
+}
+
+// Opened returns the number of connections opened so far
+func (s *Server) Opened() int64 { return s.opened.Load() }
+
+// MaxOpen returns the highest number of simultaneously open connections
+func (s *Server) MaxOpen() int64 { return s.maxOpen.Load() }
+
+// MaxConcurrentConnects returns the highest number of connection handshakes that
+// were in progress at once, across all servers, since the last ResetConnectStats
+func MaxConcurrentConnects() int64 { return maxConnecting.Load() }
+
+// OpenConnections returns the number of connections open across all servers
+func OpenConnections() int64 { return openConns.Load() }
+
+// MaxOpenConnections returns the highest number of connections that were open at
+// once, across all servers, since the last ResetConnectStats
+func MaxOpenConnections() int64 { return maxOpenConns.Load() }
+
+// ResetConnectStats clears the MaxConcurrentConnects and MaxOpenConnections
+// high-water marks
+func ResetConnectStats() {
+	maxConnecting.Store(0)
+	maxOpenConns.Store(openConns.Load())
+}
+
+// raiseHighWater raises max to at least v
+func raiseHighWater(max *atomic.Int64, v int64) {
+	for {
+		cur := max.Load()
+		if v <= cur || max.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// response records a statement and returns its scripted response, and the index of
+// its execution record. Unscripted USE and SELECT DATABASE() statements act on the
+// connection's schema.
+func (s *Server) response(c *conn, query string) (Response, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executed = append(s.executed, Execution{Query: query, Started: time.Now()})
+	return s.scripted(c, query), len(s.executed) - 1
+}
+
+// finished marks an execution record as done
+func (s *Server) finished(execution int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executed[execution].Finished = time.Now()
+}
+
+// scripted returns the response for a statement. s.mu must be held.
+func (s *Server) scripted(c *conn, query string) Response {
+	if resps, ok := s.responses[query]; ok && len(resps) > 0 {
+		resp := resps[0]
+		if len(resps) > 1 {
+			s.responses[query] = resps[1:]
+		}
+		return resp
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(upper, "USE ") {
+		c.schema = strings.Trim(strings.TrimSpace(query[len("USE "):]), "`")
+		return Response{}
+	}
+	if upper == "SELECT DATABASE()" {
+		return Response{Columns: []string{"DATABASE()"}, Rows: [][]driver.Value{{c.schema}}}
+	}
+
+	if s.fallback != nil {
+		return *s.fallback
+	}
+	return Response{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	host := cfg.Addr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	registryMu.Lock()
+	s, ok := registry[host]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dbtest: no fake server for host %q", host)
+	}
+
+	s.mu.Lock()
+	connErr, connDelay := s.connErr, s.connDelay
+	if len(s.connErrs) > 0 {
+		connErr, s.connErrs = s.connErrs[0], s.connErrs[1:]
+	}
+	s.mu.Unlock()
+
+	raiseHighWater(&maxConnecting, connecting.Add(1))
+	timedOut := cfg.Timeout > 0 && connDelay > cfg.Timeout
+	if timedOut {
+		connDelay = cfg.Timeout
+	}
+	time.Sleep(connDelay)
+	connecting.Add(-1)
+	if timedOut {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+	if connErr != nil {
+		return nil, connErr
+	}
+
+	s.opened.Add(1)
+	raiseHighWater(&s.maxOpen, s.open.Add(1))
+	raiseHighWater(&maxOpenConns, openConns.Add(1))
+	return &conn{server: s, schema: cfg.DBName}, nil
+}
+
+type conn struct {
+	server *Server
+	schema string // Current default schema, changed by USE
+	closed bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("dbtest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.server.open.Add(-1)
+		openConns.Add(-1)
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("dbtest: transactions are not supported")
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	resp, err := c.run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{resp: resp}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	resp, err := c.run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(resp.Rows)), nil
+}
+
+// run waits out the scripted delay and returns the scripted response
+func (c *conn) run(ctx context.Context, query string) (Response, error) {
+	resp, execution := c.server.response(c, query)
+	defer c.server.finished(execution)
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-ctx.Done():
+			return Response{}, ctx.Err()
+		}
+	}
+	if resp.Err != nil {
+		return Response{}, resp.Err
+	}
+	return resp, nil
+}
+
+type rows struct {
+	resp Response
+	pos  int
+}
+
+func (r *rows) Columns() []string { return r.resp.Columns }
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.resp.Rows) {
+		if r.resp.Panic != nil {
+			panic(r.resp.Panic)
+		}
+		return io.EOF
+	}
+	if r.resp.RowDelay > 0 {
+		time.Sleep(r.resp.RowDelay)
+	}
+	copy(dest, r.resp.Rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if index < len(r.resp.Types) {
+		return r.resp.Types[index]
+	}
+	return "VARCHAR"
+}
+
+// ColumnTypeNullable implements driver.RowsColumnTypeNullable
+func (r *rows) ColumnTypeNullable(index int) (bool, bool) {
+	if index < len(r.resp.Nullable) {
+		return r.resp.Nullable[index], true
+	}
+	return false, false
+}
+
+// ColumnTypeLength implements driver.RowsColumnTypeLength
+func (r *rows) ColumnTypeLength(index int) (int64, bool) {
+	if index < len(r.resp.Lengths) {
+		return r.resp.Lengths[index], true
+	}
+	return 0, false
+}
+
+// IntRows builds n single-column rows holding 1..n, handy for row-count tests
+func IntRows(n int) [][]driver.Value {
+	out := make([][]driver.Value, n)
+	for i := range out {
+		out[i] = []driver.Value{int64(i + 1)}
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffOptions controls how DiffResults compares results across instances
+type DiffOptions struct {
+	// Compare rows in the order they came in; by default rows are compared as a
+	// multiset, as the order of rows without ORDER BY is not defined
+	Ordered bool
+
+	// Columns left out of the comparison, such as timestamps expected to differ
+	IgnoreColumns []string
+
+	// Report instances that returned the same rows as the others in a different
+	// order; only meaningful without Ordered, which counts that as a difference
+	VerifyOrder bool
+}
+
+// DiffGroup is a set of instances that returned identical results for a statement
+type DiffGroup struct {
+	Digest    string // Of the columns and rows, as compared
+	Columns   []string
+	Rows      int
+	Instances []string // In instance order
+}
+
+// DiffFailure is an instance whose statement failed or was skipped, so its result
+// could not be compared
+type DiffFailure struct {
+	Instance string
+	Err      error
+}
+
+// StatementDiff compares the results of one statement across instances
+type StatementDiff struct {
+	Statement string
+	Groups    []DiffGroup // Largest first, ties in instance order
+	Failures  []DiffFailure
+	Order     []OrderMismatch // With DiffOptions.VerifyOrder
+
+	// The instances returned different column sets, so their rows were not
+	// compared and Groups is empty
+	Shapes *ShapeMismatchError
+}
+
+// Agree reports whether every instance returned the same result
+func (d StatementDiff) Agree() bool {
+	return len(d.Groups) <= 1 && len(d.Failures) == 0 && len(d.Order) == 0 && d.Shapes == nil
+}
+
+// DiffReport is the comparison of a run's results across instances, statement by
+// statement
+type DiffReport struct {
+	Statements []StatementDiff
+}
+
+// Differences returns the number of statements whose results differ
+func (r DiffReport) Differences() int {
+	n := 0
+	for _, d := range r.Statements {
+		if !d.Agree() {
+			n++
+		}
+	}
+	return n
+}
+
+// DiffResults compares each statement's results across instances, which run the
+// same statements, so the nth result of every instance is of the same statement.
+// Instances are grouped by the digest of their columns and rows (see
+// DigestResult), sorted unless opts.Ordered. Instances that could not be reached
+// count as failed for every statement. A statement whose results have different
+// columns (see CheckShapes) is not compared; its diff reports the shapes instead.
+// Only columns named by opts.IgnoreColumns may differ.
+func DiffResults(instanceList []string, results map[string][]QueryResult, opts DiffOptions) DiffReport {
+	statements := 0
+	for _, instanceDSN := range instanceList {
+		if res := results[instanceDSN]; !connectFailed(res) {
+			statements = max(statements, len(res))
+		}
+	}
+
+	var report DiffReport
+	for i := 0; i < statements; i++ {
+		var diff StatementDiff
+		var compared []QueryResult
+		for _, instanceDSN := range instanceList {
+			instanceResults := results[instanceDSN]
+			if connectFailed(instanceResults) {
+				diff.Failures = append(diff.Failures, DiffFailure{Instance: instanceDSN, Err: instanceResults[0].Err})
+				continue
+			}
+			if i >= len(instanceResults) {
+				continue
+			}
+			res := instanceResults[i]
+			if diff.Statement == "" {
+				diff.Statement = res.Statement
+			}
+			if res.Err != nil || res.Skipped {
+				diff.Failures = append(diff.Failures, DiffFailure{Instance: instanceDSN, Err: res.Err})
+				continue
+			}
+
+			res.Instance = instanceDSN
+			compared = append(compared, WithoutColumns(res, opts.IgnoreColumns))
+		}
+		if errors.As(CheckShapes(compared), &diff.Shapes) {
+			report.Statements = append(report.Statements, diff)
+			continue
+		}
+
+		byDigest := make(map[string]int) // Index into diff.Groups
+		for _, res := range compared {
+			rowDigest := DigestResult(res)
+			rows := rowDigest.Sorted
+			if opts.Ordered {
+				rows = rowDigest.Ordered
+			}
+			digest := digestRows([]string{strings.Join(res.Columns, "\x00"), rows})
+			g, ok := byDigest[digest]
+			if !ok {
+				g = len(diff.Groups)
+				byDigest[digest] = g
+				diff.Groups = append(diff.Groups, DiffGroup{Digest: digest, Columns: res.Columns, Rows: len(res.Rows)})
+			}
+			diff.Groups[g].Instances = append(diff.Groups[g].Instances, res.Instance)
+		}
+		sort.SliceStable(diff.Groups, func(a, b int) bool {
+			return len(diff.Groups[a].Instances) > len(diff.Groups[b].Instances)
+		})
+		if opts.VerifyOrder && !opts.Ordered {
+			diff.Order = VerifyOrder(compared)
+		}
+		report.Statements = append(report.Statements, diff)
+	}
+	return report
+}
+
+// connectFailed reports whether an instance's results are only the failure to
+// connect to it
+func connectFailed(results []QueryResult) bool {
+	return len(results) == 1 && results[0].ConnectFailed
+}
+
+// WriteDiffReport writes a report of which instances agree on each statement's
+// result and which differ: for a difference, the instances of each distinct
+// result, the most common first, and the instances that failed
+func WriteDiffReport(w io.Writer, report DiffReport) {
+	for i, d := range report.Statements {
+		statement := strings.Join(strings.Fields(d.Statement), " ") // One line, however the statement was written
+		if d.Agree() {
+			instances := 0
+			rows := 0
+			if len(d.Groups) == 1 {
+				instances, rows = len(d.Groups[0].Instances), d.Groups[0].Rows
+			}
+			fmt.Fprintf(w, "Statement %d: %s\n  same on all %d instance(s) (%d row(s))\n", i+1, statement, instances, rows)
+			continue
+		}
+
+		fmt.Fprintf(w, "Statement %d: %s\n", i+1, statement)
+		if len(d.Groups) > 1 {
+			fmt.Fprintf(w, "  DIFFERS: %d distinct result(s)\n", len(d.Groups))
+		}
+		if d.Shapes != nil {
+			fmt.Fprintf(w, "  DIFFERS: %d distinct column set(s); rows not compared\n", len(d.Shapes.Shapes))
+			for _, shape := range d.Shapes.Shapes {
+				fmt.Fprintf(w, "  columns (%s): %s\n", strings.Join(shape.Columns, ", "), maskedList(shape.Instances))
+			}
+		}
+		for g, group := range d.Groups {
+			fmt.Fprintf(w, "  result %d: %d row(s), %d column(s), digest %s: %s\n",
+				g+1, group.Rows, len(group.Columns), group.Digest[:12], maskedList(group.Instances))
+		}
+		for _, f := range d.Failures {
+			fmt.Fprintf(w, "  failed: %s: %v\n", maskPasswordInDSN(f.Instance), f.Err)
+		}
+		for _, m := range d.Order {
+			fmt.Fprintf(w, "  order: %s\n", m)
+		}
+	}
+	differ := report.Differences()
+	if differ == 0 {
+		fmt.Fprintf(w, "All %d statement(s) returned the same results on every instance.\n", len(report.Statements))
+		return
+	}
+	fmt.Fprintf(w, "%d of %d statement(s) differ across instances.\n", differ, len(report.Statements))
+}
+
+// maskedList joins DSNs with their passwords masked
+func maskedList(instances []string) string {
+	masked := make([]string, len(instances))
+	for i, instanceDSN := range instances {
+		masked[i] = maskPasswordInDSN(instanceDSN)
+	}
+	return strings.Join(masked, ", ")
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	instances := []string{"u:p@tcp(db1:3306)/", "u:p@tcp(db2:3306)/", "u:p@tcp(db3:3306)/", "u:p@tcp(db4:3306)/"}
+	result := func(instance string, columns []string, rows ...[]interface{}) QueryResult {
+		return QueryResult{Instance: instance, Statement: "SELECT id, seen FROM t", Columns: columns, Rows: rows, RowCount: len(rows)}
+	}
+	cols := []string{"id", "seen"}
+	results := map[string][]QueryResult{
+		instances[0]: {result(instances[0], cols, []interface{}{int64(1), "mon"}, []interface{}{int64(2), "mon"})},
+		instances[1]: {result(instances[1], cols, []interface{}{int64(2), "tue"}, []interface{}{int64(1), "tue"})}, // Other order, other seen
+		instances[2]: {result(instances[2], cols, []interface{}{int64(1), "mon"})},                                 // A row short
+		instances[3]: {{Instance: instances[3], Err: errors.New("connection refused"), ConnectFailed: true}},
+	}
+
+	report := DiffResults(instances, results, DiffOptions{})
+	if len(report.Statements) != 1 {
+		t.Fatalf("got %d statement(s), want 1", len(report.Statements))
+	}
+	d := report.Statements[0]
+	if len(d.Groups) != 3 || len(d.Failures) != 1 || d.Failures[0].Instance != instances[3] {
+		t.Fatalf("diff = %+v, want three distinct results and db4 failed", d)
+	}
+
+	// Ignoring the differing column, db1 and db2 return the same multiset of rows
+	report = DiffResults(instances, results, DiffOptions{IgnoreColumns: []string{"SEEN"}, VerifyOrder: true})
+	d = report.Statements[0]
+	if len(d.Groups) != 2 || !reflect.DeepEqual(d.Groups[0].Instances, instances[:2]) || d.Groups[0].Rows != 2 {
+		t.Fatalf("groups = %+v, want db1 and db2 together first", d.Groups)
+	}
+	if len(d.Order) != 1 || d.Order[0].Instance != instances[1] || d.Order[0].Row != 0 {
+		t.Errorf("order mismatches = %+v, want db2 reported", d.Order)
+	}
+
+	// Compared in order, they differ
+	report = DiffResults(instances, results, DiffOptions{IgnoreColumns: []string{"seen"}, Ordered: true})
+	if d := report.Statements[0]; len(d.Groups) != 3 || d.Order != nil {
+		t.Errorf("ordered diff = %+v, want three distinct results", d)
+	}
+}
+
+func TestDiffResults_ColumnsCount(t *testing.T) {
+	instances := []string{"u:p@tcp(db1:3306)/", "u:p@tcp(db2:3306)/"}
+	results := map[string][]QueryResult{
+		instances[0]: {{Statement: "SELECT 1", Columns: []string{"a"}, Rows: [][]interface{}{{int64(1)}}}},
+		instances[1]: {{Statement: "SELECT 1", Columns: []string{"b"}, Rows: [][]interface{}{{int64(1)}}}},
+	}
+	if d := DiffResults(instances, results, DiffOptions{}).Statements[0]; d.Agree() {
+		t.Error("results with the same rows under other column names agree, want them to differ")
+	}
+}
+
+func TestWriteDiffReport(t *testing.T) {
+	report := DiffReport{Statements: []StatementDiff{
+		{Statement: "SELECT 1", Groups: []DiffGroup{{Digest: strings.Repeat("a", 64), Columns: []string{"1"}, Rows: 1, Instances: []string{"u:p@tcp(db1:3306)/", "u:p@tcp(db2:3306)/"}}}},
+		{
+			Statement: "SELECT v\n  FROM t",
+			Groups: []DiffGroup{
+				{Digest: strings.Repeat("b", 64), Columns: []string{"v"}, Rows: 3, Instances: []string{"u:p@tcp(db1:3306)/"}},
+				{Digest: strings.Repeat("c", 64), Columns: []string{"v"}, Rows: 2, Instances: []string{"u:p@tcp(db2:3306)/"}},
+			},
+			Failures: []DiffFailure{{Instance: "u:p@tcp(db3:3306)/", Err: errors.New("boom")}},
+		},
+	}}
+	var buf bytes.Buffer
+	WriteDiffReport(&buf, report)
+	want := "Statement 1: SELECT 1\n" +
+		"  same on all 2 instance(s) (1 row(s))\n" +
+		"Statement 2: SELECT v FROM t\n" +
+		"  DIFFERS: 2 distinct result(s)\n" +
+		"  result 1: 3 row(s), 1 column(s), digest bbbbbbbbbbbb: u:****@tcp(db1:3306)/\n" +
+		"  result 2: 2 row(s), 1 column(s), digest cccccccccccc: u:****@tcp(db2:3306)/\n" +
+		"  failed: u:****@tcp(db3:3306)/: boom\n" +
+		"1 of 2 statement(s) differ across instances.\n"
+	if buf.String() != want {
+		t.Errorf("WriteDiffReport() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestDiffResults_Shapes(t *testing.T) {
+	instances := []string{"u:p@tcp(db1:3306)/", "u:p@tcp(db2:3306)/", "u:p@tcp(db3:3306)/"}
+	status := func(columns ...string) []QueryResult {
+		row := make([]interface{}, len(columns))
+		for i := range row {
+			row[i] = []byte("x")
+		}
+		return []QueryResult{{Statement: "SHOW REPLICA STATUS", Columns: columns, Rows: [][]interface{}{row}}}
+	}
+	results := map[string][]QueryResult{
+		instances[0]: status("Source_Host", "Seconds_Behind_Source"),
+		instances[1]: status("Source_Host", "Seconds_Behind_Source"),
+		instances[2]: status("Source_Host", "Seconds_Behind_Source", "Replica_UUID"),
+	}
+
+	d := DiffResults(instances, results, DiffOptions{}).Statements[0]
+	if d.Agree() || d.Groups != nil || d.Shapes == nil || len(d.Shapes.Shapes) != 2 {
+		t.Fatalf("diff = %+v, want the two column sets reported instead of groups", d)
+	}
+	if got := d.Shapes.Shapes[1].Instances; !reflect.DeepEqual(got, instances[2:]) {
+		t.Errorf("second column set from %q, want db3", got)
+	}
+	var buf bytes.Buffer
+	WriteDiffReport(&buf, DiffReport{Statements: []StatementDiff{d}})
+	want := "Statement 1: SHOW REPLICA STATUS\n" +
+		"  DIFFERS: 2 distinct column set(s); rows not compared\n" +
+		"  columns (Source_Host, Seconds_Behind_Source): u:****@tcp(db1:3306)/, u:****@tcp(db2:3306)/\n" +
+		"  columns (Source_Host, Seconds_Behind_Source, Replica_UUID): u:****@tcp(db3:3306)/\n"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("WriteDiffReport() =\n%s\nwant it to start with\n%s", buf.String(), want)
+	}
+
+	// Ignoring the extra column, the rows are compared
+	d = DiffResults(instances, results, DiffOptions{IgnoreColumns: []string{"Replica_UUID"}}).Statements[0]
+	if !d.Agree() {
+		t.Errorf("diff ignoring the extra column = %+v, want agreement", d)
+	}
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ResultDigest fingerprints the rows of a result for comparison across instances
+type ResultDigest struct {
+	Sorted  string // Independent of row order; duplicate rows still count
+	Ordered string // Also depends on the order the rows came in
+	Rows    int
+}
+
+// canonicalValue encodes a scanned value so equal values encode the same on every
+// instance and NULL never collides with a string such as "NULL" or ""
+func canonicalValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "N"
+	case []byte:
+		return fmt.Sprintf("S%d:%s", len(v), v)
+	case string:
+		return fmt.Sprintf("S%d:%s", len(v), v)
+	case time.Time:
+		s := v.UTC().Format(time.RFC3339Nano)
+		return fmt.Sprintf("T%d:%s", len(s), s)
+	default:
+		s := fmt.Sprint(v)
+		return fmt.Sprintf("V%d:%s", len(s), s)
+	}
+}
+
+// canonicalRow encodes a row; values are length-prefixed, so no two rows encode alike
+func canonicalRow(row []interface{}) string {
+	var b strings.Builder
+	for _, v := range row {
+		b.WriteString(canonicalValue(v))
+	}
+	return b.String()
+}
+
+// canonicalRows encodes every row of a result, in order
+func canonicalRows(res QueryResult) []string {
+	rows := make([]string, len(res.Rows))
+	for i, row := range res.Rows {
+		rows[i] = canonicalRow(row)
+	}
+	return rows
+}
+
+// digestRows hashes encoded rows in the order given
+func digestRows(rows []string) string {
+	h := sha256.New()
+	for _, row := range rows {
+		fmt.Fprintf(h, "%d:%s", len(row), row)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DigestResult returns the order-insensitive and order-sensitive digests of a
+// result's rows. The sorted digest sorts the encoded rows, so it treats rows as a
+// multiset: [a a b] and [a b b] differ. Sorting is by encoding, not by any server
+// collation, so it is stable across instances whatever their collations.
+func DigestResult(res QueryResult) ResultDigest {
+	rows := canonicalRows(res)
+	digest := ResultDigest{Ordered: digestRows(rows), Rows: len(rows)}
+	slices.Sort(rows)
+	digest.Sorted = digestRows(rows)
+	return digest
+}
+
+// WithoutColumns returns a copy of a result without the named columns, matched
+// case-insensitively as MySQL matches column names, so comparisons across
+// instances can ignore columns expected to differ, such as an auto-updating
+// last_seen timestamp. Every column of that name is dropped; names the result
+// does not have are ignored. The result's rows are not modified.
+func WithoutColumns(res QueryResult, ignore []string) QueryResult {
+	if len(ignore) == 0 || len(res.Columns) == 0 {
+		return res
+	}
+	keep := make([]int, 0, len(res.Columns))
+	for i, col := range res.Columns {
+		if !slices.ContainsFunc(ignore, func(name string) bool { return strings.EqualFold(strings.TrimSpace(name), col) }) {
+			keep = append(keep, i)
+		}
+	}
+	if len(keep) == len(res.Columns) {
+		return res
+	}
+
+	out := res
+	out.Columns = make([]string, len(keep))
+	for j, i := range keep {
+		out.Columns[j] = res.Columns[i]
+	}
+	if len(res.ColumnTypes) == len(res.Columns) {
+		out.ColumnTypes = make([]ColumnType, len(keep))
+		for j, i := range keep {
+			out.ColumnTypes[j] = res.ColumnTypes[i]
+		}
+	}
+	out.Rows = make([][]interface{}, len(res.Rows))
+	for r, row := range res.Rows {
+		out.Rows[r] = make([]interface{}, 0, len(keep))
+		for _, i := range keep {
+			if i < len(row) {
+				out.Rows[r] = append(out.Rows[r], row[i])
+			}
+		}
+	}
+	return out
+}
+
+// OrderMismatch is an instance that returned the same rows as the reference
+// instance, but in a different order
+type OrderMismatch struct {
+	Instance  string
+	Reference string
+	Row       int // Index of the first row that differs
+}
+
+func (m OrderMismatch) String() string {
+	return fmt.Sprintf("%s returned the same rows as %s in a different order, first at row %d",
+		maskPasswordInDSN(m.Instance), maskPasswordInDSN(m.Reference), m.Row+1)
+}
+
+// VerifyOrder compares the results of one statement across instances against the
+// first successful one and reports the instances whose rows are the same multiset
+// but differently ordered, e.g. because a replica sorts with another collation.
+// Results with different rows are a difference in content, not order, and are
+// left to the order-insensitive comparison; failed and skipped results are ignored.
+func VerifyOrder(results []QueryResult) []OrderMismatch {
+	var mismatches []OrderMismatch
+	var reference []string
+	var referenceDigest ResultDigest
+	referenceInstance := ""
+	for _, res := range results {
+		if res.Err != nil || res.Skipped {
+			continue
+		}
+		if reference == nil {
+			reference, referenceDigest, referenceInstance = canonicalRows(res), DigestResult(res), res.Instance
+			continue
+		}
+		digest := DigestResult(res)
+		if digest.Sorted != referenceDigest.Sorted || digest.Ordered == referenceDigest.Ordered {
+			continue
+		}
+		rows := canonicalRows(res)
+		first := 0
+		for first < len(rows) && rows[first] == reference[first] {
+			first++
+		}
+		mismatches = append(mismatches, OrderMismatch{Instance: res.Instance, Reference: referenceInstance, Row: first})
+	}
+	return mismatches
+}
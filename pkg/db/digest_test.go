@@ -0,0 +1,214 @@
+package db
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDigestResult(t *testing.T) {
+	digest := func(rows ...[]interface{}) ResultDigest {
+		return DigestResult(QueryResult{Columns: []string{"a", "b"}, Rows: rows})
+	}
+	tests := []struct {
+		name        string
+		a, b        ResultDigest
+		sameSorted  bool
+		sameOrdered bool
+	}{
+		{
+			name:        "identical",
+			a:           digest([]interface{}{int64(1), "x"}, []interface{}{int64(2), "y"}),
+			b:           digest([]interface{}{int64(1), "x"}, []interface{}{int64(2), "y"}),
+			sameSorted:  true,
+			sameOrdered: true,
+		},
+		{
+			name:       "reordered",
+			a:          digest([]interface{}{int64(1), "x"}, []interface{}{int64(2), "y"}),
+			b:          digest([]interface{}{int64(2), "y"}, []interface{}{int64(1), "x"}),
+			sameSorted: true,
+		},
+		{
+			name: "duplicates count",
+			a:    digest([]interface{}{int64(1), "x"}, []interface{}{int64(1), "x"}, []interface{}{int64(2), "y"}),
+			b:    digest([]interface{}{int64(1), "x"}, []interface{}{int64(2), "y"}, []interface{}{int64(2), "y"}),
+		},
+		{
+			name: "duplicate row is not the same as one row",
+			a:    digest([]interface{}{int64(1), "x"}, []interface{}{int64(1), "x"}),
+			b:    digest([]interface{}{int64(1), "x"}),
+		},
+		{
+			name: "NULL is not the string NULL",
+			a:    digest([]interface{}{nil, "x"}),
+			b:    digest([]interface{}{"NULL", "x"}),
+		},
+		{
+			name: "NULL is not the empty string",
+			a:    digest([]interface{}{nil, "x"}),
+			b:    digest([]interface{}{"", "x"}),
+		},
+		{
+			name: "values do not run into each other",
+			a:    digest([]interface{}{"ab", "c"}),
+			b:    digest([]interface{}{"a", "bc"}),
+		},
+		{
+			name:        "text as bytes or string",
+			a:           digest([]interface{}{[]byte("x"), nil}),
+			b:           digest([]interface{}{"x", nil}),
+			sameSorted:  true,
+			sameOrdered: true,
+		},
+		{
+			name:        "times in other zones",
+			a:           digest([]interface{}{time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), nil}),
+			b:           digest([]interface{}{time.Date(2024, 5, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*3600)), nil}),
+			sameSorted:  true,
+			sameOrdered: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Sorted == tt.b.Sorted; got != tt.sameSorted {
+				t.Errorf("same sorted digest = %v, want %v", got, tt.sameSorted)
+			}
+			if got := tt.a.Ordered == tt.b.Ordered; got != tt.sameOrdered {
+				t.Errorf("same ordered digest = %v, want %v", got, tt.sameOrdered)
+			}
+		})
+	}
+}
+
+func TestVerifyOrder(t *testing.T) {
+	result := func(instance string, rows ...[]interface{}) QueryResult {
+		return QueryResult{Instance: instance, Columns: []string{"name"}, Rows: rows}
+	}
+	row := func(v interface{}) []interface{} { return []interface{}{v} }
+	failed := errors.New("Error 1146: Table 'app.t' doesn't exist")
+
+	tests := []struct {
+		name    string
+		results []QueryResult
+		want    []OrderMismatch
+	}{
+		{
+			name: "same order everywhere",
+			results: []QueryResult{
+				result("db1", row("a"), row("b")),
+				result("db2", row("a"), row("b")),
+			},
+		},
+		{
+			name: "collation puts a row elsewhere",
+			results: []QueryResult{
+				result("db1", row("a"), row("B"), row("c")),
+				result("db2", row("a"), row("c"), row("B")),
+				result("db3", row("a"), row("B"), row("c")),
+			},
+			want: []OrderMismatch{{Instance: "db2", Reference: "db1", Row: 1}},
+		},
+		{
+			name: "NULLs sorted last instead of first",
+			results: []QueryResult{
+				result("db1", row(nil), row("a"), row("b")),
+				result("db2", row("a"), row("b"), row(nil)),
+			},
+			want: []OrderMismatch{{Instance: "db2", Reference: "db1", Row: 0}},
+		},
+		{
+			name: "duplicate rows in another order",
+			results: []QueryResult{
+				result("db1", row("a"), row("a"), row("b")),
+				result("db2", row("a"), row("b"), row("a")),
+			},
+			want: []OrderMismatch{{Instance: "db2", Reference: "db1", Row: 1}},
+		},
+		{
+			name: "different duplicates are a content difference",
+			results: []QueryResult{
+				result("db1", row("a"), row("a"), row("b")),
+				result("db2", row("b"), row("a"), row("b")),
+			},
+		},
+		{
+			name: "different rows are a content difference",
+			results: []QueryResult{
+				result("db1", row("a"), row("b")),
+				result("db2", row("b"), row("c")),
+			},
+		},
+		{
+			name: "failed and skipped results are ignored",
+			results: []QueryResult{
+				{Instance: "db0", Err: failed},
+				result("db1", row("a"), row("b")),
+				{Instance: "db2", Err: failed, Skipped: true},
+				result("db3", row("b"), row("a")),
+			},
+			want: []OrderMismatch{{Instance: "db3", Reference: "db1", Row: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VerifyOrder(tt.results)
+			if len(got) != len(tt.want) {
+				t.Fatalf("VerifyOrder() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("mismatch %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithoutColumns(t *testing.T) {
+	seen := func(id int64, state string, lastSeen time.Time) QueryResult {
+		return QueryResult{
+			Columns:     []string{"id", "state", "last_seen"},
+			ColumnTypes: []ColumnType{{Name: "id"}, {Name: "state"}, {Name: "last_seen"}},
+			Rows:        [][]interface{}{{id, state, lastSeen}},
+		}
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	primary := seen(1, "active", now)
+	replica := seen(1, "active", now.Add(time.Second))
+	stale := seen(1, "paused", now.Add(time.Second))
+
+	if DigestResult(primary).Sorted == DigestResult(replica).Sorted {
+		t.Fatal("results with different timestamps digest the same")
+	}
+	ignore := []string{"LAST_SEEN"} // Matched case-insensitively
+	if DigestResult(WithoutColumns(primary, ignore)).Sorted != DigestResult(WithoutColumns(replica, ignore)).Sorted {
+		t.Error("an ignored column still makes the results differ")
+	}
+	if DigestResult(WithoutColumns(primary, ignore)).Sorted == DigestResult(WithoutColumns(stale, ignore)).Sorted {
+		t.Error("a difference in a compared column was hidden")
+	}
+
+	got := WithoutColumns(primary, []string{"state", "missing"})
+	if want := []string{"id", "last_seen"}; !slices.Equal(got.Columns, want) {
+		t.Errorf("columns = %q, want %q", got.Columns, want)
+	}
+	if len(got.ColumnTypes) != 2 || got.ColumnTypes[1].Name != "last_seen" {
+		t.Errorf("column types = %+v, want those of the kept columns", got.ColumnTypes)
+	}
+	if len(got.Rows[0]) != 2 || got.Rows[0][0] != int64(1) || got.Rows[0][1] != now {
+		t.Errorf("row = %v, want id and last_seen", got.Rows[0])
+	}
+	if len(primary.Columns) != 3 || len(primary.Rows[0]) != 3 {
+		t.Error("WithoutColumns modified the original result")
+	}
+}
+
+func TestOrderMismatch_String(t *testing.T) {
+	m := OrderMismatch{Instance: "u:secret@tcp(db2:3306)/", Reference: "u:secret@tcp(db1:3306)/", Row: 1}
+	want := "u:****@tcp(db2:3306)/ returned the same rows as u:****@tcp(db1:3306)/ in a different order, first at row 2"
+	if got := m.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ConnectionError is reported for an instance that could not be reached: opening
+// the connection, the handshake or the ping failed, so no statement ran on it.
+// Use errors.As to tell it apart from a QueryError.
+type ConnectionError struct {
+	Instance string // The instance DSN as configured
+	Err      error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// QueryError is reported for a statement that failed on a reachable instance,
+// whether the server rejected it or its rows failed while being read
+type QueryError struct {
+	Number uint16 // The server's error number, e.g. 1146 for a missing table; 0 if the error did not come from the server
+	Err    error
+}
+
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// newQueryError wraps a statement's error, taking the error number from the
+// server's error if there is one
+func newQueryError(err error) *QueryError {
+	qe := &QueryError{Err: err}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		qe.Number = mysqlErr.Number
+	}
+	return qe
+}
+
+// QueryTimeoutError is reported for a statement interrupted after running longer
+// than ExecOptions.QueryTimeout. It matches context.DeadlineExceeded.
+type QueryTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("query timed out after %v", e.Timeout)
+}
+
+func (e *QueryTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
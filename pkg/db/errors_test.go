@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestRunSQL_TypedErrors(t *testing.T) {
+	useFakeDriver(t)
+	down := dbtest.NewServer(t, "typed-down")
+	down.FailConnect(errors.New("connection refused"))
+	up := dbtest.NewServer(t, "typed-up")
+	up.Handle("SELECT * FROM missing", dbtest.Response{Err: &mysql.MySQLError{Number: 1146, Message: "Table 'app.missing' doesn't exist"}})
+	up.Handle("SELECT 1", dbtest.Response{Columns: []string{"1"}, Rows: [][]driver.Value{{int64(1)}}})
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), down.DSN(), "SELECT 1", ExecOptions{})
+	var connErr *ConnectionError
+	if len(results) != 1 || !errors.As(results[0].Err, &connErr) || connErr.Instance != down.DSN() {
+		t.Fatalf("results = %+v, want a *ConnectionError for the unreachable instance", results)
+	}
+	var queryErr *QueryError
+	if errors.As(results[0].Err, &queryErr) {
+		t.Errorf("connect failure %v is also a *QueryError", results[0].Err)
+	}
+
+	results = RunSQLOnInstanceWithOptions(context.Background(), up.DSN(), "SELECT * FROM missing; SELECT 1", ExecOptions{})
+	if len(results) != 2 || !errors.As(results[0].Err, &queryErr) || queryErr.Number != 1146 {
+		t.Fatalf("results = %+v, want a *QueryError numbered 1146", results)
+	}
+	if errors.As(results[0].Err, &connErr) {
+		t.Errorf("statement failure %v is also a *ConnectionError", results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Errorf("second statement error = %v, want none", results[1].Err)
+	}
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Errors raised while a statement executes, after it was parsed and resolved, so
+// its plan can explain the failure: timeouts, lock waits and resource limits
+var executionErrors = map[uint16]bool{
+	1028: true, // ER_FILSORT_ABORT
+	1038: true, // ER_OUT_OF_SORTMEMORY
+	1114: true, // ER_RECORD_FILE_FULL (a temporary table filled up)
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1206: true, // ER_LOCK_TABLE_FULL
+	1213: true, // ER_LOCK_DEADLOCK
+	1317: true, // ER_QUERY_INTERRUPTED
+	3024: true, // ER_QUERY_TIMEOUT (max_execution_time)
+	3572: true, // ER_LOCK_NOWAIT
+}
+
+const errParse = 1064 // ER_PARSE_ERROR, e.g. FORMAT=TREE before MySQL 8.0.16
+
+// explainableKeywords are the statements EXPLAIN accepts
+var explainableKeywords = map[string]bool{
+	"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true, "REPLACE": true, "TABLE": true, "WITH": true,
+}
+
+// explainable reports whether EXPLAIN accepts a statement, judging by its first
+// keyword
+func explainable(stmt string) bool {
+	keywords := statementKeywords(stmt, 1)
+	return len(keywords) == 1 && explainableKeywords[keywords[0]]
+}
+
+// explainsQuery reports whether a statement is an EXPLAIN of a query, after which
+// SHOW WARNINGS holds the query as the optimizer rewrote it. EXPLAIN of a table,
+// like DESCRIBE, is not.
+func explainsQuery(stmt string) bool {
+	keywords := statementKeywords(stmt, 2)
+	if len(keywords) < 2 {
+		return false
+	}
+	switch keywords[0] {
+	case "EXPLAIN", "DESCRIBE", "DESC":
+		return explainableKeywords[keywords[1]] || keywords[1] == "FORMAT" || keywords[1] == "ANALYZE"
+	}
+	return false
+}
+
+// explainReason returns why a statement's plan should be captured under opts, or
+// "" if it should not
+func (o ExecOptions) explainReason(res QueryResult) string {
+	if res.Skipped {
+		return ""
+	}
+	if res.Err != nil {
+		var mysqlErr *mysql.MySQLError
+		if o.ExplainOnError && errors.As(res.Err, &mysqlErr) && executionErrors[mysqlErr.Number] {
+			return fmt.Sprintf("failed with error %d", mysqlErr.Number)
+		}
+		return ""
+	}
+	if took := res.Duration + res.Processing; o.ExplainOnSlow > 0 && took > o.ExplainOnSlow {
+		return fmt.Sprintf("took %v, over %v", took.Round(time.Millisecond), o.ExplainOnSlow)
+	}
+	return ""
+}
+
+// explain captures the plan of a statement on the session it just ran on, if its
+// result calls for one. The plan is read as a tree, or in the traditional tabular
+// format from servers without FORMAT=TREE; failing to read it only leaves a note.
+func (r *sessionRun) explain(ctx context.Context, stmtInfo StatementInfo, res *QueryResult) {
+	reason := r.opts.explainReason(*res)
+	if reason == "" || ctx.Err() != nil || !explainable(stmtInfo.SQL) {
+		return
+	}
+	res.PlanReason = reason
+	plan, err := queryPlan(ctx, r.sess.conn, "EXPLAIN FORMAT=TREE "+stmtInfo.SQL)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errParse {
+		plan, err = queryPlan(ctx, r.sess.conn, "EXPLAIN "+stmtInfo.SQL)
+	}
+	if err != nil {
+		plan = fmt.Sprintf("(plan unavailable: %v)", err)
+	} else if r.opts.ExplainWarnings {
+		res.PlanWarnings = r.warnings(ctx)
+	}
+	res.Plan = plan
+}
+
+// explainWarnings reads SHOW WARNINGS after a statement of the script that is
+// itself an EXPLAIN, with ExplainWarnings
+func (r *sessionRun) explainWarnings(ctx context.Context, stmtInfo StatementInfo, res *QueryResult) {
+	if !r.opts.ExplainWarnings || res.Err != nil || res.Skipped || ctx.Err() != nil || !explainsQuery(stmtInfo.SQL) {
+		return
+	}
+	res.PlanWarnings = r.warnings(ctx)
+}
+
+// warnings returns the warnings of the last statement on the session, or a note
+// saying why they could not be read
+func (r *sessionRun) warnings(ctx context.Context) string {
+	warnings, err := readWarnings(ctx, r.sess.conn)
+	if err != nil {
+		return fmt.Sprintf("(warnings unavailable: %v)", err)
+	}
+	return warnings
+}
+
+// readWarnings runs SHOW WARNINGS and renders a line per warning, e.g.
+// "Note 1003: /* select#1 */ select ..."
+func readWarnings(ctx context.Context, conn *sql.Conn) (string, error) {
+	rows, err := conn.QueryContext(ctx, "SHOW WARNINGS")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var level, code, message string
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", level, code, message))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// queryPlan runs an EXPLAIN statement and renders its result as text: a tree plan
+// is its single value, a tabular plan a table aligned with spaces
+func queryPlan(ctx context.Context, conn *sql.Conn, explain string) (string, error) {
+	rows, err := conn.QueryContext(ctx, explain)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var lines [][]string
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		scanArgs := make([]interface{}, len(cols))
+		for i := range vals {
+			scanArgs[i] = &vals[i]
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return "", err
+		}
+		line := make([]string, len(vals))
+		for i, v := range vals {
+			line[i] = "NULL"
+			if v.Valid {
+				line[i] = v.String
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if len(cols) == 1 {
+		var plan []string
+		for _, line := range lines {
+			plan = append(plan, line[0])
+		}
+		return strings.TrimRight(strings.Join(plan, "\n"), "\n"), nil
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	for _, line := range lines {
+		fmt.Fprintln(tw, strings.Join(line, "\t"))
+	}
+	tw.Flush()
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// writePlan prints the plan captured for a result and the warnings read after an
+// EXPLAIN, dimmed, under the result
+func writePlan(w io.Writer, res QueryResult, opts PrintOptions) {
+	dim := opts.paint(color.Faint)
+	if res.Plan != "" {
+		fmt.Fprintln(w, dim(fmt.Sprintf("EXPLAIN (statement %s):", res.PlanReason)))
+		for _, line := range strings.Split(res.Plan, "\n") {
+			fmt.Fprintln(w, dim("  "+line))
+		}
+	}
+	if res.PlanWarnings != "" {
+		fmt.Fprintln(w, dim("SHOW WARNINGS:"))
+		for _, line := range strings.Split(res.PlanWarnings, "\n") {
+			fmt.Fprintln(w, dim("  "+line))
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestExplainable(t *testing.T) {
+	tests := map[string]bool{
+		"SELECT * FROM t":                      true,
+		"select*from t":                        true,
+		"/* report */ SELECT 1":                true,
+		"-- report\nUPDATE t SET c = 1":        true,
+		"(SELECT 1) UNION (SELECT 2)":          true,
+		"WITH x AS (SELECT 1) SELECT * FROM x": true,
+		"INSERT INTO t SELECT * FROM s":        true,
+		"DELETE FROM t WHERE id = 1":           true,
+		"ALTER TABLE t ADD c INT":              false,
+		"SHOW PROCESSLIST":                     false,
+		"SET @x = (SELECT 1)":                  false,
+		"/*!40101 SET NAMES utf8mb4 */":        false,
+		"EXPLAIN SELECT 1":                     false,
+		"CALL refresh_totals()":                false,
+		"SELECTION":                            false,
+		"":                                     false,
+	}
+	for stmt, want := range tests {
+		if got := explainable(stmt); got != want {
+			t.Errorf("explainable(%q) = %v, want %v", stmt, got, want)
+		}
+	}
+}
+
+// explains returns the EXPLAIN statements run on srv
+func explains(srv *dbtest.Server) []string {
+	var got []string
+	for _, query := range srv.Executed() {
+		if strings.HasPrefix(query, "EXPLAIN") {
+			got = append(got, query)
+		}
+	}
+	return got
+}
+
+func TestRunSQLOnInstance_ExplainOnSlow(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "explain-slow")
+	srv.Handle("SELECT * FROM orders", dbtest.Response{Columns: []string{"id"}, Rows: dbtest.IntRows(2), Delay: 30 * time.Millisecond})
+	srv.Handle("ALTER TABLE orders ADD c INT", dbtest.Response{Delay: 30 * time.Millisecond})
+	srv.Handle("EXPLAIN FORMAT=TREE SELECT * FROM orders", dbtest.Response{
+		Columns: []string{"EXPLAIN"},
+		Rows:    [][]driver.Value{{"-> Table scan on orders  (cost=0.35 rows=2)\n"}},
+	})
+
+	sqls := "SELECT 1; SELECT * FROM orders; ALTER TABLE orders ADD c INT"
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), sqls, ExecOptions{ExplainOnSlow: 10 * time.Millisecond})
+	if got := explains(srv); len(got) != 1 || got[0] != "EXPLAIN FORMAT=TREE SELECT * FROM orders" {
+		t.Fatalf("EXPLAIN statements = %q, want only the slow SELECT explained", got)
+	}
+	if results[0].Plan != "" || results[2].Plan != "" {
+		t.Errorf("plans attached to a fast or unexplainable statement: %+v", results)
+	}
+	if got := results[1].Plan; got != "-> Table scan on orders  (cost=0.35 rows=2)" {
+		t.Errorf("Plan = %q, want the tree", got)
+	}
+	if !strings.HasPrefix(results[1].PlanReason, "took ") {
+		t.Errorf("PlanReason = %q, want the time taken", results[1].PlanReason)
+	}
+
+	// Without the option nothing is explained
+	srv2 := dbtest.NewServer(t, "explain-off")
+	srv2.Handle("SELECT * FROM orders", dbtest.Response{Delay: 30 * time.Millisecond})
+	RunSQLOnInstanceWithOptions(context.Background(), srv2.DSN(), sqls, ExecOptions{})
+	if got := explains(srv2); len(got) != 0 {
+		t.Errorf("EXPLAIN statements = %q without ExplainOnSlow", got)
+	}
+}
+
+func TestRunSQLOnInstance_ExplainOnError(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "explain-error")
+	const update = "UPDATE orders SET state = 'done' WHERE id < 100"
+	srv.Handle(update, dbtest.Response{Err: &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}})
+	srv.Handle("SELECT * FROM missing", dbtest.Response{Err: &mysql.MySQLError{Number: 1146, Message: "Table 'app.missing' doesn't exist"}})
+	// An older server without FORMAT=TREE gets the traditional format
+	srv.Handle("EXPLAIN FORMAT=TREE "+update, dbtest.Response{Err: &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}})
+	srv.Handle("EXPLAIN "+update, dbtest.Response{
+		Columns: []string{"id", "select_type", "table", "type", "key", "rows"},
+		Rows:    [][]driver.Value{{"1", "UPDATE", "orders", "range", "PRIMARY", "99"}},
+	})
+
+	sqls := update + "; SELECT * FROM missing"
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), sqls, ExecOptions{ExplainOnError: true})
+	want := []string{"EXPLAIN FORMAT=TREE " + update, "EXPLAIN " + update}
+	if got := explains(srv); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("EXPLAIN statements = %q, want %q; errors before execution are not explained", got, want)
+	}
+	wantPlan := "id  select_type  table   type   key      rows\n1   UPDATE       orders  range  PRIMARY  99"
+	if got := results[0].Plan; got != wantPlan {
+		t.Errorf("Plan = %q, want %q", got, wantPlan)
+	}
+	if got := results[0].PlanReason; got != "failed with error 1205" {
+		t.Errorf("PlanReason = %q", got)
+	}
+	if results[1].Plan != "" {
+		t.Errorf("plan attached to a statement that failed to resolve: %q", results[1].Plan)
+	}
+}
+
+func TestRenderResult_Plan(t *testing.T) {
+	res := QueryResult{
+		Instance:   "dsn",
+		Statement:  "SELECT * FROM orders",
+		Columns:    []string{"id"},
+		Rows:       [][]interface{}{{int64(1)}},
+		RowCount:   1,
+		Plan:       "-> Filter: (orders.state = 'new')\n    -> Table scan on orders",
+		PlanReason: "took 2.1s, over 1s",
+	}
+	var buf bytes.Buffer
+	RenderResult(&buf, res, nil, PrintOptions{Plain: true})
+	want := "EXPLAIN (statement took 2.1s, over 1s):\n  -> Filter: (orders.state = 'new')\n      -> Table scan on orders\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("RenderResult() =\n%s\nwant the plan last:\n%s", buf.String(), want)
+	}
+}
+
+func TestExplainsQuery(t *testing.T) {
+	tests := map[string]bool{
+		"EXPLAIN SELECT * FROM t":          true,
+		"explain format=json SELECT 1":     true,
+		"EXPLAIN ANALYZE SELECT 1":         true,
+		"DESCRIBE UPDATE t SET c = 1":      true,
+		"/* plan */ EXPLAIN DELETE FROM t": true,
+		"EXPLAIN orders":                   false,
+		"DESCRIBE orders":                  false,
+		"DESC orders":                      false,
+		"SELECT 'EXPLAIN SELECT 1'":        false,
+		"EXPLAIN":                          false,
+	}
+	for stmt, want := range tests {
+		if got := explainsQuery(stmt); got != want {
+			t.Errorf("explainsQuery(%q) = %v, want %v", stmt, got, want)
+		}
+	}
+}
+
+func TestRunSQLOnInstance_ExplainWarnings(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "explain-warnings")
+	const explain = "EXPLAIN SELECT * FROM orders WHERE id IN (SELECT order_id FROM items)"
+	srv.Handle(explain, dbtest.Response{
+		Columns: []string{"id", "select_type", "table"},
+		Rows:    [][]driver.Value{{"1", "SIMPLE", "orders"}},
+	})
+	srv.Handle("SHOW WARNINGS", dbtest.Response{
+		Columns: []string{"Level", "Code", "Message"},
+		Rows:    [][]driver.Value{{"Note", int64(1003), "/* select#1 */ select `app`.`orders`.`id` AS `id` from `app`.`orders` semi join (`app`.`items`)"}},
+	})
+
+	sqls := explain + "; DESCRIBE orders; SELECT 1"
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), sqls, ExecOptions{ExplainWarnings: true})
+	want := []string{explain, "SHOW WARNINGS", "DESCRIBE orders", "SELECT 1"}
+	if got := srv.Executed(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("executed %q, want SHOW WARNINGS right after the EXPLAIN only", got)
+	}
+	wantWarnings := "Note 1003: /* select#1 */ select `app`.`orders`.`id` AS `id` from `app`.`orders` semi join (`app`.`items`)"
+	if got := results[0].PlanWarnings; got != wantWarnings {
+		t.Errorf("PlanWarnings = %q, want %q", got, wantWarnings)
+	}
+	if results[1].PlanWarnings != "" || results[2].PlanWarnings != "" {
+		t.Errorf("warnings attached to statements that are not an EXPLAIN of a query: %+v", results[1:])
+	}
+
+	// A plan captured for a failed statement is followed up too
+	srv2 := dbtest.NewServer(t, "explain-warnings-on-error")
+	const update = "UPDATE orders SET state = 'done' WHERE id < 100"
+	srv2.Handle(update, dbtest.Response{Err: &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}})
+	srv2.Handle("EXPLAIN FORMAT=TREE "+update, dbtest.Response{Columns: []string{"EXPLAIN"}, Rows: [][]driver.Value{{"-> Update orders"}}})
+	srv2.Handle("SHOW WARNINGS", dbtest.Response{
+		Columns: []string{"Level", "Code", "Message"},
+		Rows:    [][]driver.Value{{"Note", int64(1003), "update `app`.`orders` set `state` = 'done' where (`id` < 100)"}},
+	})
+	results = RunSQLOnInstanceWithOptions(context.Background(), srv2.DSN(), update, ExecOptions{ExplainOnError: true, ExplainWarnings: true})
+	if got := results[0].PlanWarnings; got != "Note 1003: update `app`.`orders` set `state` = 'done' where (`id` < 100)" {
+		t.Errorf("PlanWarnings of the captured plan = %q", got)
+	}
+}
+
+func TestRenderResult_PlanWarnings(t *testing.T) {
+	res := QueryResult{
+		Instance:     "dsn",
+		Statement:    "EXPLAIN SELECT * FROM orders",
+		Columns:      []string{"id", "table"},
+		Rows:         [][]interface{}{{int64(1), []byte("orders")}},
+		RowCount:     1,
+		PlanWarnings: "Note 1003: /* select#1 */ select `app`.`orders`.`id` AS `id` from `app`.`orders`",
+	}
+	var buf bytes.Buffer
+	RenderResult(&buf, res, nil, PrintOptions{Plain: true})
+	want := "SHOW WARNINGS:\n  Note 1003: /* select#1 */ select `app`.`orders`.`id` AS `id` from `app`.`orders`\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("RenderResult() =\n%s\nwant the warnings last:\n%s", buf.String(), want)
+	}
+}
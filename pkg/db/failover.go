@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// failoverNetwork is the driver network name whose dialer re-resolves the host on every dial
+const failoverNetwork = "csql-failover"
+
+// MySQL error numbers returned by a node that has been demoted during failover
+const (
+	errOptionPreventsStatement = 1290 // ER_OPTION_PREVENTS_STATEMENT (--read-only)
+	errReadOnlyMode            = 1836 // ER_READ_ONLY_MODE
+)
+
+var registerFailoverDialer sync.Once
+
+// hostResolver looks up the addresses of a host
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// failoverDialer dials a host:port after a fresh DNS lookup, so a reconnect after a
+// cluster endpoint moves reaches the new node instead of a cached stale address.
+type failoverDialer struct {
+	resolver hostResolver
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialContext resolves the host and dials each address in turn until one succeeds
+func (d failoverDialer) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range addrs {
+		conn, err := d.dial(ctx, "tcp", net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// withFailoverNetwork rewrites a tcp DSN to dial through the re-resolving failover dialer
+func withFailoverNetwork(dsn string) string {
+	registerFailoverDialer.Do(func() {
+		dialer := failoverDialer{resolver: net.DefaultResolver, dial: (&net.Dialer{}).DialContext}
+		mysql.RegisterDialContext(failoverNetwork, dialer.DialContext)
+	})
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil || cfg.Net != "tcp" {
+		return dsn // Leave unix sockets and unparseable DSNs alone
+	}
+	cfg.Net = failoverNetwork
+	return cfg.FormatDSN()
+}
+
+// isFailoverError reports whether err indicates the server was demoted or the
+// connection was lost, i.e. a reconnect may reach a different, writable node
+func isFailoverError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errOptionPreventsStatement || mysqlErr.Number == errReadOnlyMode
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
+
+// isSessionStatement reports whether a statement changes session state that must be
+// re-established after reconnecting
+func isSessionStatement(stmt string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(stmt))
+	return strings.HasPrefix(upper, "USE ") || strings.HasPrefix(upper, "SET ")
+}
+
+// serverID returns the server's @@server_id, or "unknown" if it cannot be read
+func serverID(ctx context.Context, conn *sql.Conn) string {
+	var id string
+	if err := conn.QueryRowContext(ctx, "SELECT @@server_id").Scan(&id); err != nil {
+		return "unknown"
+	}
+	return id
+}
+
+// reconnect gives the session a fresh connection after a statement timed out: the
+// driver interrupts a statement by closing its connection. The session statements
+// run so far (USE, SET) are replayed on the new one.
+func (r *sessionRun) reconnect(ctx context.Context) error {
+	sess := r.sess
+	fresh, err := failover(ctx, sess.connectDSN, sess.db, sess.conn, r.sessionStmts, false)
+	if err != nil {
+		return err
+	}
+	sess.db, sess.conn = fresh.db, fresh.conn
+	r.killed = false // KILL QUERY may be needed again, for the new connection
+	if r.connectionID != "" {
+		r.connectionID = connectionID(ctx, sess.conn)
+	}
+	return nil
+}
+
+// failoverSession is the replacement connection established by failover
+type failoverSession struct {
+	db       *sql.DB
+	conn     *sql.Conn
+	serverID string
+}
+
+// failover opens a fresh connection pool and session to the same DSN, replays the
+// session statements and, if system variables may be read, identifies the new
+// server. The old session is only closed once the new one is usable.
+func failover(ctx context.Context, dsn string, oldDB *sql.DB, oldConn *sql.Conn, session []string, readServerID bool) (failoverSession, error) {
+	fresh, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return failoverSession{}, err
+	}
+	conn, err := fresh.Conn(ctx)
+	if err == nil {
+		err = conn.PingContext(ctx)
+		if err != nil {
+			conn.Close()
+		}
+	}
+	if err != nil {
+		fresh.Close()
+		return failoverSession{}, err
+	}
+	for _, stmt := range session {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			conn.Close()
+			fresh.Close()
+			return failoverSession{}, fmt.Errorf("failed to re-establish session (%s): %w", stmt, err)
+		}
+	}
+
+	oldConn.Close()
+	oldDB.Close()
+	id := "unknown"
+	if readServerID {
+		id = serverID(ctx, conn)
+	}
+	return failoverSession{db: fresh, conn: conn, serverID: id}, nil
+}
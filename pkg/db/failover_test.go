@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+	"github.com/go-sql-driver/mysql"
+)
+
+// stubResolver returns successive address lists, simulating DNS moving during failover
+type stubResolver struct {
+	answers [][]string
+	lookups int
+}
+
+func (r *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	answer := r.answers[r.lookups%len(r.answers)]
+	r.lookups++
+	return answer, nil
+}
+
+func TestFailoverDialer_ResolvesOnEveryDial(t *testing.T) {
+	resolver := &stubResolver{answers: [][]string{{"10.0.0.1"}, {"10.0.0.2"}}}
+	var dialed []string
+	dialer := failoverDialer{
+		resolver: resolver,
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := dialer.DialContext(context.Background(), "cluster.example.com:3306")
+		if err != nil {
+			t.Fatalf("DialContext() error = %v", err)
+		}
+		conn.Close()
+	}
+
+	if resolver.lookups != 2 {
+		t.Errorf("resolver called %d times, want 2", resolver.lookups)
+	}
+	want := []string{"10.0.0.1:3306", "10.0.0.2:3306"}
+	if strings.Join(dialed, ",") != strings.Join(want, ",") {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+}
+
+func TestFailoverDialer_TriesEachAddress(t *testing.T) {
+	resolver := &stubResolver{answers: [][]string{{"10.0.0.1", "10.0.0.2"}}}
+	var dialed []string
+	dialer := failoverDialer{
+		resolver: resolver,
+		dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			if strings.HasPrefix(addr, "10.0.0.1") {
+				return nil, errors.New("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+
+	conn, err := dialer.DialContext(context.Background(), "cluster.example.com:3306")
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	conn.Close()
+	if len(dialed) != 2 || dialed[1] != "10.0.0.2:3306" {
+		t.Errorf("dialed %v, want fallback to 10.0.0.2:3306", dialed)
+	}
+}
+
+func TestIsFailoverError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "read-only option", err: &mysql.MySQLError{Number: 1290, Message: "--read-only"}, want: true},
+		{name: "read-only mode", err: &mysql.MySQLError{Number: 1836}, want: true},
+		{name: "wrapped read-only", err: fmt.Errorf("query error: %w", &mysql.MySQLError{Number: 1290}), want: true},
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "invalid connection", err: mysql.ErrInvalidConn, want: true},
+		{name: "network error", err: &net.OpError{Op: "read", Err: errors.New("reset")}, want: true},
+		{name: "syntax error", err: &mysql.MySQLError{Number: 1064}, want: false},
+		{name: "plain error", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFailoverError(tt.err); got != tt.want {
+				t.Errorf("isFailoverError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFailoverNetwork(t *testing.T) {
+	got := withFailoverNetwork("user:pass@tcp(cluster:3306)/app")
+	if !strings.Contains(got, "@"+failoverNetwork+"(cluster:3306)/app") {
+		t.Errorf("withFailoverNetwork() = %q, want failover network", got)
+	}
+
+	unix := "user:pass@unix(/tmp/mysql.sock)/app"
+	if got := withFailoverNetwork(unix); got != unix {
+		t.Errorf("withFailoverNetwork() = %q, want unix DSN unchanged", got)
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_FailoverRetry(t *testing.T) {
+	useFakeDriver(t)
+
+	readOnly := &mysql.MySQLError{Number: 1290, Message: "The MySQL server is running with the --read-only option"}
+	newServer := func(t *testing.T, host string) *dbtest.Server {
+		srv := dbtest.NewServer(t, host)
+		srv.HandleSequence("SELECT @@server_id",
+			dbtest.Response{Columns: []string{"@@server_id"}, Rows: [][]driver.Value{{"101"}}},
+			dbtest.Response{Columns: []string{"@@server_id"}, Rows: [][]driver.Value{{"202"}}},
+		)
+		srv.HandleSequence("INSERT INTO t VALUES (1)", dbtest.Response{Err: readOnly}, dbtest.Response{})
+		return srv
+	}
+
+	t.Run("failover aware", func(t *testing.T) {
+		srv := newServer(t, "failover-1")
+		results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+			"SET NAMES utf8mb4; INSERT INTO t VALUES (1); SELECT 2", ExecOptions{FailoverAware: true})
+
+		for _, res := range results {
+			if res.Err != nil {
+				t.Errorf("%s: unexpected error %v", res.Statement, res.Err)
+			}
+		}
+		if srv.Opened() != 2 {
+			t.Errorf("opened %d connections, want 2 (initial + failover)", srv.Opened())
+		}
+		// The capability probe runs once per session; the reconnect reuses its outcome
+		var want []string
+		for _, probe := range capabilityProbes {
+			want = append(want, probe.query)
+		}
+		want = append(want,
+			"SELECT @@server_id", "SET NAMES utf8mb4", "INSERT INTO t VALUES (1)",
+			"SET NAMES utf8mb4", "SELECT @@server_id", "INSERT INTO t VALUES (1)", "SELECT 2",
+		)
+		if got := srv.Executed(); strings.Join(got, ";") != strings.Join(want, ";") {
+			t.Errorf("executed %q, want %q", got, want)
+		}
+	})
+
+	t.Run("not failover aware", func(t *testing.T) {
+		srv := newServer(t, "failover-2")
+		results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+			"INSERT INTO t VALUES (1)", ExecOptions{})
+		if len(results) != 1 || !errors.Is(results[0].Err, readOnly) {
+			t.Errorf("results = %+v, want read-only error", results)
+		}
+		if srv.Opened() != 1 {
+			t.Errorf("opened %d connections, want 1", srv.Opened())
+		}
+	})
+
+	t.Run("retries only once", func(t *testing.T) {
+		srv := dbtest.NewServer(t, "failover-3")
+		srv.Handle("INSERT INTO t VALUES (1)", dbtest.Response{Err: readOnly})
+		results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(),
+			"INSERT INTO t VALUES (1)", ExecOptions{FailoverAware: true})
+		if len(results) != 1 || !errors.Is(results[0].Err, readOnly) {
+			t.Errorf("results = %+v, want read-only error after one retry", results)
+		}
+		if srv.Opened() != 2 {
+			t.Errorf("opened %d connections, want 2", srv.Opened())
+		}
+	})
+}
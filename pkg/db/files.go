@@ -0,0 +1,177 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SyncFile is an output file that is safe for concurrent block writes. Each Write
+// is applied whole under the file's own lock.
+type SyncFile struct {
+	mu      sync.Mutex
+	f       *os.File
+	path    string // Final path of the file
+	tmpPath string // With atomic creation, where content is written until Close
+	closed  bool
+}
+
+// CreateAtomic creates a file that only appears at path once it is closed: content
+// goes to a temporary file in the same directory, renamed over path by Close.
+// Readers never see a half-written file.
+func CreateAtomic(path string) (*SyncFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &SyncFile{f: f, path: path, tmpPath: f.Name()}, nil
+}
+
+// CreateSynced creates or truncates the file at path for writing in place, as for a
+// tee or audit file that should be readable while the run is in progress. Close
+// syncs it to disk.
+func CreateSynced(path string) (*SyncFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &SyncFile{f: f, path: path}, nil
+}
+
+// Path returns the file's final path
+func (sf *SyncFile) Path() string { return sf.path }
+
+// Write writes p as one block
+func (sf *SyncFile) Write(p []byte) (int, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.closed {
+		return 0, fmt.Errorf("write to closed output file %s", sf.path)
+	}
+	return sf.f.Write(p)
+}
+
+// Close syncs the file to disk and closes it; an atomically created file is then
+// renamed into place
+func (sf *SyncFile) Close() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.closed {
+		return nil
+	}
+	sf.closed = true
+
+	err := sf.f.Sync()
+	if closeErr := sf.f.Close(); err == nil {
+		err = closeErr
+	}
+	if sf.tmpPath == "" {
+		return err
+	}
+	if err == nil {
+		err = os.Rename(sf.tmpPath, sf.path)
+	}
+	if err != nil {
+		os.Remove(sf.tmpPath)
+	}
+	return err
+}
+
+// InstanceAddr returns the host:port of dsn for compact display, or the masked
+// DSN if it does not parse
+func InstanceAddr(dsn string) string {
+	if cfg, err := mysql.ParseDSN(dsn); err == nil && cfg.Addr != "" {
+		return cfg.Addr
+	}
+	return maskPasswordInDSN(dsn)
+}
+
+// unsafeFileChars matches characters not kept in generated output file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// InstanceOutputPath returns the file under dir that an instance's results are
+// written to, named after its host, port and schema, e.g. dir/db1_3306_app.out
+func InstanceOutputPath(dir, instanceDSN string) string {
+	name := maskPasswordInDSN(instanceDSN)
+	if cfg, err := mysql.ParseDSN(instanceDSN); err == nil {
+		name = cfg.Addr
+		if cfg.DBName != "" {
+			name += "_" + cfg.DBName
+		}
+	}
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_.")
+	if name == "" {
+		name = "instance"
+	}
+	return filepath.Join(dir, name+".out")
+}
+
+// OutputFiles are the files a run writes results to: one per instance under an
+// output directory, and an optional tee file receiving every result block
+type OutputFiles struct {
+	files []*SyncFile
+}
+
+// OpenOutputFiles creates the output files and routes sink to them. With dir set,
+// each instance's blocks (written with BlockFor, keyed by instance DSN) go to its
+// own file, which appears when the files are closed. With teePath set, every
+// result block is also written to that file. Two instances resolving to the same
+// file is an error, as their results would be mixed together.
+func OpenOutputFiles(sink *OutputSink, dir string, instances []string, teePath string) (*OutputFiles, error) {
+	if dir != "" {
+		owners := map[string]string{}
+		for _, instanceDSN := range instances {
+			path := InstanceOutputPath(dir, instanceDSN)
+			if teePath != "" && filepath.Clean(teePath) == path {
+				return nil, fmt.Errorf("--tee file %s is also the output file of %s", teePath, MaskDSN(instanceDSN))
+			}
+			if other, taken := owners[path]; taken {
+				return nil, fmt.Errorf("instances %s and %s both write to %s", MaskDSN(other), MaskDSN(instanceDSN), path)
+			}
+			owners[path] = instanceDSN
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	out := &OutputFiles{}
+	if teePath != "" {
+		tee, err := CreateSynced(teePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tee file: %w", err)
+		}
+		out.files = append(out.files, tee)
+		sink.Tee(tee)
+	}
+	if dir != "" {
+		for _, instanceDSN := range instances {
+			f, err := CreateAtomic(InstanceOutputPath(dir, instanceDSN))
+			if err != nil {
+				out.Close()
+				return nil, fmt.Errorf("failed to create output file for %s: %w", MaskDSN(instanceDSN), err)
+			}
+			out.files = append(out.files, f)
+			sink.RouteKey(instanceDSN, f)
+		}
+	}
+	return out, nil
+}
+
+// Close closes every file, putting per-instance files in place, and reports all
+// failures
+func (o *OutputFiles) Close() error {
+	var errs []error
+	for _, f := range o.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
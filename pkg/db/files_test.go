@@ -0,0 +1,155 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInstanceOutputPath(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"user:pass@tcp(db1:3306)/app", "db1_3306_app.out"},
+		{"user:pass@tcp(db1:3306)/", "db1_3306.out"},
+		{"user:pass@tcp([::1]:3307)/app", "1_3307_app.out"},
+		{"user:pass@unix(/var/run/mysqld/mysqld.sock)/app", "var_run_mysqld_mysqld.sock_app.out"},
+	}
+	for _, tt := range tests {
+		if got := InstanceOutputPath("out", tt.dsn); got != filepath.Join("out", tt.want) {
+			t.Errorf("InstanceOutputPath(%q) = %q, want %q", tt.dsn, got, filepath.Join("out", tt.want))
+		}
+	}
+}
+
+func TestOpenOutputFiles_PathCollision(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewOutputSink(io.Discard, io.Discard)
+
+	// Same host, port and schema with different users map to the same file
+	_, err := OpenOutputFiles(sink, dir, []string{
+		"alice:secret@tcp(db1:3306)/app",
+		"bob:secret@tcp(db1:3306)/app",
+	}, "")
+	if err == nil || !strings.Contains(err.Error(), "both write to") || strings.Contains(err.Error(), "secret") {
+		t.Errorf("OpenOutputFiles() error = %v, want a masked collision error", err)
+	}
+
+	_, err = OpenOutputFiles(sink, dir, []string{"u:p@tcp(db1:3306)/app"}, filepath.Join(dir, "db1_3306_app.out"))
+	if err == nil || !strings.Contains(err.Error(), "--tee") {
+		t.Errorf("OpenOutputFiles() error = %v, want a tee collision error", err)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files were created despite the collision: %v", entries)
+	}
+}
+
+func TestOutputFiles_ConcurrentInstances(t *testing.T) {
+	const instances, blocksEach = 50, 20
+	dir := filepath.Join(t.TempDir(), "results")
+	teePath := filepath.Join(t.TempDir(), "tee.log")
+
+	var dsns []string
+	for i := 0; i < instances; i++ {
+		dsns = append(dsns, fmt.Sprintf("u:p@tcp(host-%d:3306)/app", i))
+	}
+
+	var stdout, stderr lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+	files, err := OpenOutputFiles(sink, dir, dsns, teePath)
+	if err != nil {
+		t.Fatalf("OpenOutputFiles() error = %v", err)
+	}
+
+	block := func(instance, b int) string {
+		var sb strings.Builder
+		for l := 0; l < 3; l++ {
+			fmt.Fprintf(&sb, "instance %d block %d line %d\n", instance, b, l)
+		}
+		return sb.String()
+	}
+
+	var wg sync.WaitGroup
+	for i, dsn := range dsns {
+		wg.Add(1)
+		go func(i int, dsn string) {
+			defer wg.Done()
+			for b := 0; b < blocksEach; b++ {
+				err := sink.BlockFor(dsn, StreamResults, func(w io.Writer) {
+					// Write piecemeal to give interleaving every chance to happen
+					for _, line := range strings.SplitAfter(block(i, b), "\n") {
+						io.WriteString(w, line)
+					}
+				})
+				if err != nil {
+					t.Errorf("BlockFor(%d) error = %v", i, err)
+				}
+			}
+		}(i, dsn)
+	}
+	wg.Wait()
+
+	// Instance files only appear once closed
+	if _, err := os.Stat(InstanceOutputPath(dir, dsns[0])); !os.IsNotExist(err) {
+		t.Errorf("instance file visible before Close: %v", err)
+	}
+	if err := files.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for i, dsn := range dsns {
+		data, err := os.ReadFile(InstanceOutputPath(dir, dsn))
+		if err != nil {
+			t.Fatalf("instance %d: %v", i, err)
+		}
+		var want strings.Builder
+		for b := 0; b < blocksEach; b++ {
+			want.WriteString(block(i, b))
+		}
+		if string(data) != want.String() {
+			t.Errorf("instance %d file content mismatch:\n%s", i, data)
+		}
+	}
+
+	tee, err := os.ReadFile(teePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	teeText := string(tee)
+	for i := 0; i < instances; i++ {
+		for b := 0; b < blocksEach; b++ {
+			if n := strings.Count(teeText, block(i, b)); n != 1 {
+				t.Fatalf("tee has block %d/%d %d times, want exactly once", i, b, n)
+			}
+		}
+	}
+	if lines := strings.Count(teeText, "\n"); lines != instances*blocksEach*3 {
+		t.Errorf("tee has %d lines, want %d", lines, instances*blocksEach*3)
+	}
+
+	if stdout.String() != "" {
+		t.Errorf("routed blocks leaked to stdout: %q", stdout.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != instances {
+		t.Errorf("output dir has %d entries, want %d (temporary files left behind?)", len(entries), instances)
+	}
+}
+
+func TestOutputSink_UnroutedKeyUsesStream(t *testing.T) {
+	var stdout, stderr, tee lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+	sink.Tee(&tee)
+
+	_ = sink.BlockFor("unrouted", StreamResults, func(w io.Writer) { io.WriteString(w, "result\n") })
+	sink.Printf(StreamDiagnostics, "warning\n")
+
+	if stdout.String() != "result\n" || tee.String() != "result\n" || stderr.String() != "warning\n" {
+		t.Errorf("stdout=%q tee=%q stderr=%q", stdout.String(), tee.String(), stderr.String())
+	}
+}
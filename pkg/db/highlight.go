@@ -0,0 +1,169 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+)
+
+// sqlTokenKind classifies a span of SQL text for highlighting
+type sqlTokenKind int
+
+const (
+	tokenText       sqlTokenKind = iota // Whitespace, punctuation and operators
+	tokenWord                           // Identifiers and other bare words
+	tokenKeyword                        // Reserved words such as SELECT
+	tokenString                         // '...' and "..." literals
+	tokenIdentifier                     // `...` quoted identifiers
+	tokenNumber                         // Numeric literals
+	tokenComment                        // -- and /* */ comments
+)
+
+// sqlToken is a span of SQL text; concatenating all tokens yields the original text
+type sqlToken struct {
+	Kind sqlTokenKind
+	Text string
+	Open bool // A quote or block comment that was never closed
+}
+
+// sqlKeywords are the words highlighted as keywords
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		ADD ALL ALTER AND ANALYZE AS ASC BEGIN BETWEEN BY CASE CHANGE COLUMN COMMIT CONSTRAINT
+		CREATE CROSS DATABASE DATABASES DEFAULT DELETE DESC DESCRIBE DISTINCT DROP ELSE END
+		EXISTS EXPLAIN FALSE FOR FOREIGN FROM FULL GRANT GROUP HAVING IF IGNORE IN INDEX INNER
+		INSERT INTERVAL INTO IS JOIN KEY KILL LEFT LIKE LIMIT LOCK MODIFY NOT NULL OFFSET ON OR
+		ORDER OUTER PRIMARY PROCEDURE REFERENCES RENAME REPLACE REVOKE RIGHT ROLLBACK SCHEMA
+		SELECT SET SHOW START STATUS TABLE TABLES THEN TRANSACTION TRIGGER TRUE TRUNCATE UNION
+		UNIQUE UNLOCK UPDATE USE USING VALUES VARIABLES VIEW WHEN WHERE WITH`) {
+		sqlKeywords[kw] = true
+	}
+}
+
+// tokenizeSQL splits SQL into highlightable tokens. Quotes and comments are found by
+// the splitter's sqlScanner, so both always agree on what is a string or comment.
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	runes := []rune(sql)
+
+	emit := func(kind sqlTokenKind, start, end int) {
+		// Merge adjacent plain text so output stays compact
+		if kind == tokenText && len(tokens) > 0 && tokens[len(tokens)-1].Kind == tokenText {
+			tokens[len(tokens)-1].Text += string(runes[start:end])
+			return
+		}
+		tokens = append(tokens, sqlToken{Kind: kind, Text: string(runes[start:end])})
+	}
+	// markOpen flags the last emitted token as an unclosed quote or comment
+	markOpen := func() { tokens[len(tokens)-1].Open = true }
+
+	// peekAt returns a peek function for the rune after index i
+	peekAt := func(i int) func() (rune, bool) {
+		return func() (rune, bool) {
+			if i+1 < len(runes) {
+				return runes[i+1], true
+			}
+			return 0, false
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		var scan sqlScanner
+		width := scan.next(r, peekAt(i))
+		switch {
+		case !scan.inCode():
+			// A quote or comment opens here; it ends where the scanner is back in
+			// statement text, and unterminated ones run to the end
+			kind := tokenString
+			switch {
+			case scan.inComment():
+				kind = tokenComment
+			case r == '`':
+				kind = tokenIdentifier
+			}
+			lineComment := scan.state == scanLineComment
+			end, open := len(runes), true
+			for j := i + width; j < len(runes); {
+				w := scan.next(runes[j], peekAt(j))
+				if scan.inCode() {
+					end, open = j+w, false
+					if lineComment {
+						end = j // The line break is not part of the comment
+					}
+					break
+				}
+				j += w
+			}
+			if lineComment {
+				open = false // A -- comment ends with the script
+			}
+			emit(kind, i, end)
+			if open {
+				markOpen()
+			}
+			i = end
+
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			emit(tokenNumber, i, end)
+			i = end
+
+		case unicode.IsLetter(r) || r == '_' || r == '@':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) ||
+				runes[end] == '_' || runes[end] == '$' || runes[end] == '@') {
+				end++
+			}
+			kind := tokenWord
+			if sqlKeywords[strings.ToUpper(string(runes[i:end]))] {
+				kind = tokenKeyword
+			}
+			emit(kind, i, end)
+			i = end
+
+		default:
+			emit(tokenText, i, i+1)
+			i++
+		}
+	}
+	return tokens
+}
+
+// Highlight colors per token kind
+var (
+	keywordColor = color.New(color.FgBlue, color.Bold)
+	stringColor  = color.New(color.FgGreen)
+	numberColor  = color.New(color.FgMagenta)
+	commentColor = color.New(color.FgHiBlack)
+)
+
+// highlightSQL returns sql with ANSI syntax highlighting. Like all color output it is
+// plain text when color is disabled (--no-color, NO_COLOR or a non-TTY stdout).
+func highlightSQL(sql string) string {
+	if color.NoColor {
+		return sql
+	}
+	var b strings.Builder
+	for _, tok := range tokenizeSQL(sql) {
+		switch tok.Kind {
+		case tokenKeyword:
+			b.WriteString(keywordColor.Sprint(tok.Text))
+		case tokenString:
+			b.WriteString(stringColor.Sprint(tok.Text))
+		case tokenNumber:
+			b.WriteString(numberColor.Sprint(tok.Text))
+		case tokenComment:
+			b.WriteString(commentColor.Sprint(tok.Text))
+		default:
+			b.WriteString(tok.Text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// trickySQL exercises the quote, escape and comment rules shared with the splitter
+var trickySQL = []string{
+	"SELECT 1; SELECT 2",
+	"SELECT 'a;b' AS x; SELECT \"c;d\"",
+	"SELECT 'it\\'s; here'; SELECT 2",
+	"SELECT 'it''s; doubled'; SELECT 2",
+	"SELECT `weird;col` FROM t; SELECT 2",
+	"SELECT 1; -- comment; with semicolon\nSELECT 2",
+	"SELECT 1 /* block; comment */; SELECT 2",
+	"SELECT 1; -- see /* notes\nSELECT 2;\nSELECT 3",
+	"/* drop -- old */ SELECT 1; SELECT 2;\nSELECT 3",
+	"SELECT '-- not a comment;'; SELECT '/* nor; this */'",
+	"SELECT \"mixed 'quotes;' here\"; SELECT 2",
+	"SELECT 'unterminated; string",
+	"SELECT 1 /* unterminated; comment",
+	"SELECT 'trailing backslash\\",
+	"SELECT 'ünïcödé; 日本' AS 🙂; SELECT 2",
+}
+
+func TestTokenizeSQL_Kinds(t *testing.T) {
+	tokens := tokenizeSQL("SELECT name, 42 FROM `t` WHERE a = 'x' -- done")
+
+	want := []sqlToken{
+		{Kind: tokenKeyword, Text: "SELECT"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenWord, Text: "name"},
+		{Kind: tokenText, Text: ", "},
+		{Kind: tokenNumber, Text: "42"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenKeyword, Text: "FROM"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenIdentifier, Text: "`t`"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenKeyword, Text: "WHERE"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenWord, Text: "a"},
+		{Kind: tokenText, Text: " = "},
+		{Kind: tokenString, Text: "'x'"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenComment, Text: "-- done"},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("tokenizeSQL() returned %d tokens, want %d: %+v", len(tokens), len(want), tokens)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d = %+v, want %+v", i, tokens[i], want[i])
+		}
+	}
+}
+
+func TestTokenizeSQL_Strings(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string // Expected string/comment tokens, in order
+	}{
+		{input: `SELECT 'it\'s'`, want: []string{`'it\'s'`}},
+		{input: `SELECT 'it''s'`, want: []string{`'it'`, `'s'`}},
+		{input: `SELECT "a 'b' c"`, want: []string{`"a 'b' c"`}},
+		{input: "SELECT 'a' /* b 'c' */ 'd'", want: []string{"'a'", "/* b 'c' */", "'d'"}},
+		{input: "SELECT 'open", want: []string{"'open"}},
+		{input: "-- a 'quote\nSELECT 'x'", want: []string{"-- a 'quote", "'x'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got []string
+			for _, tok := range tokenizeSQL(tt.input) {
+				if tok.Kind == tokenString || tok.Kind == tokenComment {
+					got = append(got, tok.Text)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("tokenizeSQL(%q) strings/comments = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeSQL_RoundTrip(t *testing.T) {
+	for _, input := range trickySQL {
+		var b strings.Builder
+		for _, tok := range tokenizeSQL(input) {
+			b.WriteString(tok.Text)
+		}
+		if b.String() != input {
+			t.Errorf("tokens of %q reassemble to %q", input, b.String())
+		}
+	}
+}
+
+// The tokenizer and the splitter must agree on which semicolons separate statements
+func TestTokenizeSQL_AgreesWithSplitter(t *testing.T) {
+	for _, input := range trickySQL {
+		var separators int
+		for _, tok := range tokenizeSQL(input) {
+			if tok.Kind == tokenText {
+				separators += strings.Count(tok.Text, ";")
+			}
+		}
+		if got := len(splitSQLStatements(input)); got != separators+1 {
+			t.Errorf("%q: splitter found %d statements, tokenizer found %d separators", input, got, separators)
+		}
+	}
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestHighlightSQL(t *testing.T) {
+	original := color.NoColor
+	defer func() { color.NoColor = original }()
+
+	sql := "SELECT a,\n       'b' -- note\nFROM t\nWHERE n > 10"
+
+	color.NoColor = true
+	if got := highlightSQL(sql); got != sql {
+		t.Errorf("highlightSQL() with color disabled = %q, want unchanged", got)
+	}
+
+	color.NoColor = false
+	got := highlightSQL(sql)
+	if got == sql {
+		t.Error("highlightSQL() with color enabled produced no escape codes")
+	}
+	if plain := ansiEscape.ReplaceAllString(got, ""); plain != sql {
+		t.Errorf("highlightSQL() without escape codes = %q, want %q", plain, sql)
+	}
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// histogramBarWidth is the length of the bar of the fullest bucket
+const histogramBarWidth = 40
+
+// Histogram counts values in exponential buckets: values below 1 share one bucket,
+// then each bucket doubles, [1, 2), [2, 4), [4, 8) and so on, so values spanning
+// several orders of magnitude stay readable
+type Histogram struct {
+	counts   map[int]int // By bucket index; see bucketIndex
+	N        int
+	Min, Max float64
+}
+
+// HistogramBucket is a bucket of a histogram: the values in [Low, High)
+type HistogramBucket struct {
+	Low, High float64 // Low is -Inf for the bucket below 1
+	Count     int
+}
+
+// bucketIndex returns the bucket of v: 0 below 1, else k+1 for [2^k, 2^(k+1))
+func bucketIndex(v float64) int {
+	if v < 1 || math.IsNaN(v) {
+		return 0
+	}
+	_, exp := math.Frexp(v) // v = frac * 2^exp with frac in [0.5, 1)
+	return exp
+}
+
+// bucketBounds returns the values bucket i holds
+func bucketBounds(i int) (float64, float64) {
+	if i == 0 {
+		return math.Inf(-1), 1
+	}
+	return math.Ldexp(1, i-1), math.Ldexp(1, i)
+}
+
+// Add counts a value
+func (h *Histogram) Add(v float64) {
+	if h.counts == nil {
+		h.counts = make(map[int]int)
+	}
+	if h.N == 0 || v < h.Min {
+		h.Min = v
+	}
+	if h.N == 0 || v > h.Max {
+		h.Max = v
+	}
+	h.counts[bucketIndex(v)]++
+	h.N++
+}
+
+// Buckets returns the buckets from the lowest to the highest that holds a value,
+// including the empty ones in between so gaps in the distribution show
+func (h *Histogram) Buckets() []HistogramBucket {
+	if h.N == 0 {
+		return nil
+	}
+	var buckets []HistogramBucket
+	for i := bucketIndex(h.Min); i <= bucketIndex(h.Max); i++ {
+		low, high := bucketBounds(i)
+		buckets = append(buckets, HistogramBucket{Low: low, High: high, Count: h.counts[i]})
+	}
+	return buckets
+}
+
+// ValueRange labels a bucket of plain numbers, e.g. "[4, 8)" or "< 1"
+func ValueRange(low, high float64) string {
+	if math.IsInf(low, -1) {
+		return "< " + strconv.FormatFloat(high, 'f', -1, 64)
+	}
+	return fmt.Sprintf("[%s, %s)", strconv.FormatFloat(low, 'f', -1, 64), strconv.FormatFloat(high, 'f', -1, 64))
+}
+
+// WriteHistogram writes a line per bucket, labelled by label, with its count and
+// a bar scaled to the fullest bucket
+func WriteHistogram(w io.Writer, h *Histogram, label func(low, high float64) string) {
+	buckets := h.Buckets()
+	fullest := 0
+	for _, b := range buckets {
+		fullest = max(fullest, b.Count)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, b := range buckets {
+		bar := ""
+		if b.Count > 0 {
+			bar = " " + strings.Repeat("#", max(1, b.Count*histogramBarWidth/fullest))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", label(b.Low, b.High), b.Count, bar) // Right alignment indents the labels
+	}
+	tw.Flush()
+}
+
+// SingleValue returns the number a result holds when it is exactly one row of one
+// numeric column, as returned by SELECT COUNT(*) or SUM(...). A NULL value is not
+// a number.
+func SingleValue(res QueryResult) (float64, bool) {
+	if len(res.Columns) != 1 || len(res.Rows) != 1 || res.RowCount > 1 || res.OmittedRows > 0 {
+		return 0, false
+	}
+	var s string
+	switch v := res.Rows[0][0].(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64, int32, int, uint64, uint32, float64, float32:
+		s = fmt.Sprint(v)
+	default:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHistogram_Buckets(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   []string // Label and count of each bucket
+	}{
+		{name: "empty"},
+		{name: "one value", values: []float64{5}, want: []string{"[4, 8) 1"}},
+		{
+			name:   "bounds go to the higher bucket",
+			values: []float64{1, 2, 3, 4, 7.99, 8},
+			want:   []string{"[1, 2) 1", "[2, 4) 2", "[4, 8) 2", "[8, 16) 1"},
+		},
+		{
+			name:   "values below 1 share a bucket",
+			values: []float64{0, 0, -3, 0.5, 1},
+			want:   []string{"< 1 4", "[1, 2) 1"},
+		},
+		{
+			name:   "empty buckets in between are kept",
+			values: []float64{0, 3, 1000},
+			want:   []string{"< 1 1", "[1, 2) 0", "[2, 4) 1", "[4, 8) 0", "[8, 16) 0", "[16, 32) 0", "[32, 64) 0", "[64, 128) 0", "[128, 256) 0", "[256, 512) 0", "[512, 1024) 1"},
+		},
+		{
+			name:   "large counts",
+			values: []float64{15230, 16384, 9_000_000_000},
+			want:   []string{"[8192, 16384) 1", "[16384, 32768) 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h Histogram
+			for _, v := range tt.values {
+				h.Add(v)
+			}
+			buckets := h.Buckets()
+			if tt.name == "large counts" {
+				// Only check the ends; the buckets up to 2^33 are all there
+				if last := buckets[len(buckets)-1]; last.Low != math.Ldexp(1, 33) || last.Count != 1 {
+					t.Errorf("last bucket = %+v, want [2^33, 2^34) with one value", last)
+				}
+				buckets = buckets[:2]
+			}
+			if len(buckets) != len(tt.want) {
+				t.Fatalf("Buckets() = %+v, want %q", buckets, tt.want)
+			}
+			for i, b := range buckets {
+				if got := fmt.Sprintf("%s %d", ValueRange(b.Low, b.High), b.Count); got != tt.want[i] {
+					t.Errorf("bucket %d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+			if h.N != len(tt.values) {
+				t.Errorf("N = %d, want %d", h.N, len(tt.values))
+			}
+		})
+	}
+}
+
+func TestHistogram_MinMax(t *testing.T) {
+	var h Histogram
+	for _, v := range []float64{12, -4, 300, 0} {
+		h.Add(v)
+	}
+	if h.Min != -4 || h.Max != 300 {
+		t.Errorf("Min, Max = %v, %v, want -4, 300", h.Min, h.Max)
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	var h Histogram
+	for _, v := range []float64{0, 9, 10, 12, 14, 15, 40} {
+		h.Add(v)
+	}
+	var buf bytes.Buffer
+	WriteHistogram(&buf, &h, ValueRange)
+	want := "" +
+		"       < 1  1 ########\n" +
+		"    [1, 2)  0\n" +
+		"    [2, 4)  0\n" +
+		"    [4, 8)  0\n" +
+		"   [8, 16)  5 ########################################\n" +
+		"  [16, 32)  0\n" +
+		"  [32, 64)  1 ########\n"
+	if buf.String() != want {
+		t.Errorf("WriteHistogram() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestSingleValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		res    QueryResult
+		want   float64
+		wantOK bool
+	}{
+		{name: "count as text", res: QueryResult{Columns: []string{"COUNT(*)"}, Rows: [][]interface{}{{[]byte("15230")}}, RowCount: 1}, want: 15230, wantOK: true},
+		{name: "integer", res: QueryResult{Columns: []string{"n"}, Rows: [][]interface{}{{int64(7)}}, RowCount: 1}, want: 7, wantOK: true},
+		{name: "decimal", res: QueryResult{Columns: []string{"avg"}, Rows: [][]interface{}{{"12.5000"}}, RowCount: 1}, want: 12.5, wantOK: true},
+		{name: "NULL", res: QueryResult{Columns: []string{"SUM(x)"}, Rows: [][]interface{}{{nil}}, RowCount: 1}},
+		{name: "text", res: QueryResult{Columns: []string{"name"}, Rows: [][]interface{}{{"orders"}}, RowCount: 1}},
+		{name: "two columns", res: QueryResult{Columns: []string{"a", "b"}, Rows: [][]interface{}{{int64(1), int64(2)}}, RowCount: 1}},
+		{name: "two rows", res: QueryResult{Columns: []string{"n"}, Rows: [][]interface{}{{int64(1)}, {int64(2)}}, RowCount: 2}},
+		{name: "first of several rows", res: QueryResult{Columns: []string{"n"}, Rows: [][]interface{}{{int64(1)}}, RowCount: 1, OmittedRows: 1}},
+		{name: "no rows", res: QueryResult{Columns: []string{"n"}}},
+		{name: "no result set", res: QueryResult{}},
+	}
+	for _, tt := range tests {
+		got, ok := SingleValue(tt.res)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("%s: SingleValue() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSONIndent is the indentation of pretty-printed JSON output
+const JSONIndent = "  "
+
+// MarshalJSON encodes v compactly, one value per line as is best for piping into
+// other tools, or indented for reading when pretty is set
+func MarshalJSON(v interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(v, "", JSONIndent)
+	}
+	return json.Marshal(v)
+}
+
+// JSONResult is a QueryResult as written by WriteResultJSON. Error is null when the
+// statement succeeded; skipped statements carry the reason they were skipped.
+type JSONResult struct {
+	Instance     string          `json:"instance"` // With the password masked
+	Statement    string          `json:"statement"`
+	Location     string          `json:"location,omitempty"` // Where the statement is in its script, e.g. "deploy.sql:12"
+	Columns      []string        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowCount     int             `json:"row_count"`
+	DurationMS   float64         `json:"duration_ms"`
+	Error        *string         `json:"error"`
+	Skipped      bool            `json:"skipped,omitempty"`
+	Plan         string          `json:"plan,omitempty"` // EXPLAIN output captured for a slow or failed statement
+	PlanReason   string          `json:"plan_reason,omitempty"`
+	PlanWarnings string          `json:"plan_warnings,omitempty"` // SHOW WARNINGS after an EXPLAIN, e.g. the rewritten query
+}
+
+// NewJSONResult converts a result for JSON output. NULLs stay null and byte
+// slices become strings, so text columns read as text rather than base64.
+func NewJSONResult(res QueryResult) JSONResult {
+	out := JSONResult{
+		Instance:     maskPasswordInDSN(res.Instance),
+		Statement:    res.Statement,
+		Location:     res.Location(),
+		Columns:      res.Columns,
+		Rows:         make([][]interface{}, len(res.Rows)),
+		RowCount:     res.RowCount,
+		DurationMS:   float64(res.Duration) / float64(time.Millisecond),
+		Skipped:      res.Skipped,
+		Plan:         res.Plan,
+		PlanReason:   res.PlanReason,
+		PlanWarnings: res.PlanWarnings,
+	}
+	if out.Columns == nil {
+		out.Columns = []string{}
+	}
+	for i, row := range res.Rows {
+		out.Rows[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			out.Rows[i][j] = v
+		}
+	}
+	if res.Err != nil {
+		msg := res.Err.Error()
+		out.Error = &msg
+	}
+	return out
+}
+
+// WriteResultJSON writes a result as a JSON object: on one line, so a run's
+// results form a stream of lines for jq and similar tools, or indented
+func WriteResultJSON(w io.Writer, res QueryResult, pretty bool) error {
+	data, err := MarshalJSON(NewJSONResult(res), pretty)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteRowNDJSON writes a row as a JSON object on one line, e.g.
+// {"instance":"u:****@tcp(db1:3306)/","statement":"SELECT ...","row":{"id":1}}.
+// The row's keys keep the order of the columns; NULL is null.
+func WriteRowNDJSON(w io.Writer, instanceDSN, statement string, columns []string, row []interface{}) error {
+	var b bytes.Buffer
+	b.WriteString(`{"instance":`)
+	if err := writeJSONValue(&b, maskPasswordInDSN(instanceDSN)); err != nil {
+		return err
+	}
+	b.WriteString(`,"statement":`)
+	if err := writeJSONValue(&b, statement); err != nil {
+		return err
+	}
+	b.WriteString(`,"row":{`)
+	for i, col := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := writeJSONValue(&b, col); err != nil {
+			return err
+		}
+		b.WriteByte(':')
+		var v interface{}
+		if i < len(row) {
+			v = row[i]
+		}
+		if raw, ok := v.([]byte); ok {
+			v = string(raw)
+		}
+		if err := writeJSONValue(&b, v); err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	b.WriteString("}}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeJSONValue appends the JSON encoding of v to b
+func writeJSONValue(b *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b.Write(data)
+	return nil
+}
+
+// ndjsonError is the line WriteErrorNDJSON writes in the stream of rows
+type ndjsonError struct {
+	Instance  string `json:"instance"`
+	Statement string `json:"statement"`
+	Location  string `json:"location,omitempty"`
+	Error     string `json:"error"`
+	Skipped   bool   `json:"skipped,omitempty"`
+}
+
+// WriteErrorNDJSON writes the error of a failed or skipped statement as a JSON
+// line, so a stream of rows also shows which statements produced none
+func WriteErrorNDJSON(w io.Writer, res QueryResult) error {
+	data, err := json.Marshal(ndjsonError{
+		Instance:  maskPasswordInDSN(res.Instance),
+		Statement: res.Statement,
+		Location:  res.Location(),
+		Error:     res.Err.Error(),
+		Skipped:   res.Skipped,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	results := []map[string]interface{}{
+		{"instance": "u:****@tcp(db1:3306)/", "statement": "SELECT 1", "columns": []string{"1"}, "rows": [][]interface{}{{1}}},
+		{"instance": "u:****@tcp(db2:3306)/", "statement": "SELECT 1", "error": "boom"},
+	}
+
+	compact, err := MarshalJSON(results, false)
+	if err != nil {
+		t.Fatalf("MarshalJSON(compact) error = %v", err)
+	}
+	pretty, err := MarshalJSON(results, true)
+	if err != nil {
+		t.Fatalf("MarshalJSON(pretty) error = %v", err)
+	}
+
+	if bytes.ContainsRune(compact, '\n') {
+		t.Errorf("compact output spans lines:\n%s", compact)
+	}
+	if !strings.Contains(string(pretty), "\n  {\n    \"columns\": [\n      \"1\"\n    ],") {
+		t.Errorf("pretty output is not indented by %q:\n%s", JSONIndent, pretty)
+	}
+	// Both encode the same value
+	var squeezed bytes.Buffer
+	if err := json.Compact(&squeezed, pretty); err != nil {
+		t.Fatal(err)
+	}
+	if squeezed.String() != string(compact) {
+		t.Errorf("pretty output compacts to\n%s\nwant\n%s", squeezed.String(), compact)
+	}
+}
+
+func TestWriteResultJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		res  QueryResult
+		want string
+	}{
+		{
+			name: "rows with NULLs and bytes",
+			res: QueryResult{
+				Instance:  "u:secret@tcp(db1:3306)/app",
+				Statement: "SELECT id, name, note FROM users",
+				Columns:   []string{"id", "name", "note"},
+				Rows:      [][]interface{}{{int64(1), []byte("ann"), nil}, {int64(2), "bob", "hi"}},
+				RowCount:  2,
+				Duration:  1500 * time.Microsecond,
+			},
+			want: `{"instance":"u:****@tcp(db1:3306)/app","statement":"SELECT id, name, note FROM users","columns":["id","name","note"],` +
+				`"rows":[[1,"ann",null],[2,"bob","hi"]],"row_count":2,"duration_ms":1.5,"error":null}`,
+		},
+		{
+			name: "failed statement",
+			res: QueryResult{
+				Instance:  "u:secret@tcp(db2:3306)/app",
+				Statement: "SELECT broken",
+				Line:      4,
+				Source:    "report.sql",
+				Err:       errors.New("query error: Error 1054: Unknown column 'broken'"),
+			},
+			want: `{"instance":"u:****@tcp(db2:3306)/app","statement":"SELECT broken","location":"report.sql:4","columns":[],` +
+				`"rows":[],"row_count":0,"duration_ms":0,"error":"query error: Error 1054: Unknown column 'broken'"}`,
+		},
+		{
+			name: "skipped statement",
+			res:  QueryResult{Instance: "db3", Statement: "SELECT 2", Err: ErrBudgetExceeded, Skipped: true},
+			want: `{"instance":"db3","statement":"SELECT 2","columns":[],"rows":[],"row_count":0,"duration_ms":0,"error":"run budget exceeded","skipped":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var compact, pretty bytes.Buffer
+			if err := WriteResultJSON(&compact, tt.res, false); err != nil {
+				t.Fatalf("WriteResultJSON() error = %v", err)
+			}
+			if got := compact.String(); got != tt.want+"\n" {
+				t.Errorf("WriteResultJSON() =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			if err := WriteResultJSON(&pretty, tt.res, true); err != nil {
+				t.Fatalf("WriteResultJSON(pretty) error = %v", err)
+			}
+			var squeezed bytes.Buffer
+			if err := json.Compact(&squeezed, pretty.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(pretty.String(), "\n"+JSONIndent+`"statement"`) || squeezed.String() != tt.want {
+				t.Errorf("pretty output is not the same result indented:\n%s", pretty.String())
+			}
+		})
+	}
+}
+
+func TestWriteRowNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	columns := []string{"zeta", "alpha", "note"} // Not alphabetical, to check the order is kept
+	if err := WriteRowNDJSON(&buf, "u:secret@tcp(db1:3306)/app", "SELECT zeta, alpha, note FROM t", columns,
+		[]interface{}{int64(7), []byte("a\"b"), nil}); err != nil {
+		t.Fatalf("WriteRowNDJSON() error = %v", err)
+	}
+	want := `{"instance":"u:****@tcp(db1:3306)/app","statement":"SELECT zeta, alpha, note FROM t","row":{"zeta":7,"alpha":"a\"b","note":null}}` + "\n"
+	if buf.String() != want {
+		t.Errorf("WriteRowNDJSON() =\n%s\nwant\n%s", buf.String(), want)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Errorf("line is not JSON: %v", err)
+	}
+}
+
+func TestWriteErrorNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	res := QueryResult{Instance: "u:secret@tcp(db1:3306)/app", Statement: "SELECT broken", Line: 2, Source: "q.sql", Err: errors.New("query error: boom")}
+	if err := WriteErrorNDJSON(&buf, res); err != nil {
+		t.Fatalf("WriteErrorNDJSON() error = %v", err)
+	}
+	want := `{"instance":"u:****@tcp(db1:3306)/app","statement":"SELECT broken","location":"q.sql:2","error":"query error: boom"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("WriteErrorNDJSON() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
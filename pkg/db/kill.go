@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// killTimeout bounds connecting to the instance and sending KILL QUERY
+const killTimeout = 5 * time.Second
+
+// connectionID returns the server's id for conn, as KILL expects it, or "" if it
+// cannot be read
+func connectionID(ctx context.Context, conn *sql.Conn) string {
+	var id uint64
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+// killQuery stops the statement running on the connection with id, over a
+// connection of its own. Cancelling a statement's context only drops the client
+// side; without KILL QUERY the server keeps running it, holding its locks.
+func killQuery(db *sql.DB, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "KILL QUERY "+id)
+	return err
+}
+
+// killCancelled sends KILL QUERY for a statement interrupted by ctx being cancelled,
+// when opts ask for it and the connection id is known
+func (r *sessionRun) killCancelled(ctx context.Context) {
+	if ctx.Err() == nil || !r.opts.KillOnCancel || r.connectionID == "" || r.killed {
+		return
+	}
+	r.killed = true // The connection is gone with the statement; one KILL is enough
+	sess := r.sess
+	if err := killQuery(sess.db, r.connectionID); err != nil {
+		r.opts.output().Printf(StreamDiagnostics, "[%s] KILL QUERY %s failed: %v\n", maskPasswordInDSN(sess.Instance), r.connectionID, err)
+		return
+	}
+	if r.opts.Verbose >= 1 {
+		r.opts.output().Printf(StreamDiagnostics, "[%s] KILL QUERY %s sent for the cancelled statement\n", maskPasswordInDSN(sess.Instance), r.connectionID)
+	}
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LintIssue is a problem found in a script before it is sent to any server
+type LintIssue struct {
+	Statement int // 1-based index of the statement in the script
+	Line      int // 1-based line in the script
+	Column    int // 1-based column in the script, counted in characters
+	Message   string
+	Warning   bool // The statement could not be fully checked; it does not block execution
+}
+
+func (i LintIssue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%d:%d: %s: %s (statement %d)", i.Line, i.Column, level, i.Message, i.Statement)
+}
+
+// SyntaxError is a syntax error reported by an SQLParser, positioned within the statement
+type SyntaxError struct {
+	Line   int // 1-based line within the statement
+	Column int // 1-based column within the statement
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// SQLParser is a full MySQL grammar parser used by LintSQL in addition to the built-in
+// structural checks. Execution never depends on it; statements are always split and
+// sent by the built-in splitter.
+type SQLParser interface {
+	// Parse checks a single statement. Syntax errors are returned as *SyntaxError;
+	// any other error means the parser could not handle the statement.
+	Parse(sql string) error
+}
+
+// lintParser is the optional parser, registered by a program embedding this package
+var lintParser SQLParser
+
+// RegisterSQLParser installs the parser LintSQL uses for full syntax checks
+func RegisterSQLParser(p SQLParser) {
+	lintParser = p
+}
+
+// HasSQLParser reports whether a full SQL parser is available to LintSQL
+func HasSQLParser() bool {
+	return lintParser != nil
+}
+
+// LintSQL checks each statement of a script, split by term, client-side: unterminated strings, quoted
+// identifiers and comments and unbalanced parentheses are always reported, and when an
+// SQLParser is registered each remaining statement is also parsed. Statements the
+// parser rejects but that rely on version-specific /*! ... */ syntax, or that the
+// parser cannot handle at all, are reported as warnings.
+func LintSQL(sqls string, term Terminator) []LintIssue {
+	var issues []LintIssue
+	offset := 0
+	for n, stmt := range term.Split(sqls) {
+		// The splitter keeps statement text verbatim, so it can be located in the script
+		start := offset
+		if idx := strings.Index(sqls[offset:], stmt.SQL); idx >= 0 {
+			start = offset + idx
+			offset = start + len(stmt.SQL)
+		}
+		line, col := textPosition(sqls[:start], 1, 1)
+
+		issue := func(relLine, relCol int, msg string, warning bool) LintIssue {
+			absCol := relCol
+			if relLine == 1 {
+				absCol = col + relCol - 1
+			}
+			return LintIssue{Statement: n + 1, Line: line + relLine - 1, Column: absCol, Message: msg, Warning: warning}
+		}
+
+		structural := checkStatementStructure(stmt.SQL)
+		for _, si := range structural {
+			issues = append(issues, issue(si.line, si.column, si.msg, false))
+		}
+		if len(structural) > 0 || lintParser == nil {
+			continue
+		}
+
+		err := lintParser.Parse(stmt.SQL)
+		if err == nil {
+			continue
+		}
+		var synErr *SyntaxError
+		switch {
+		case errors.As(err, &synErr) && !strings.Contains(stmt.SQL, "/*!"):
+			issues = append(issues, issue(synErr.Line, synErr.Column, synErr.Msg, false))
+		case errors.As(err, &synErr):
+			issues = append(issues, issue(synErr.Line, synErr.Column, "parser rejected version-specific syntax: "+synErr.Msg, true))
+		default:
+			issues = append(issues, issue(1, 1, fmt.Sprintf("statement not checked by parser: %v", err), true))
+		}
+	}
+	return issues
+}
+
+// structureIssue is a structural problem positioned within a statement
+type structureIssue struct {
+	line, column int
+	msg          string
+}
+
+// checkStatementStructure finds unclosed quotes and comments and unbalanced
+// parentheses. Its tokens come from the splitter's sqlScanner, so it applies the
+// same quoting and comment rules.
+func checkStatementStructure(sql string) []structureIssue {
+	var issues []structureIssue
+	var openParens []structureIssue // Positions of '(' not yet closed
+	line, col := 1, 1
+
+	for _, tok := range tokenizeSQL(sql) {
+		if tok.Open {
+			issues = append(issues, structureIssue{line: line, column: col, msg: "unterminated " + openTokenName(tok.Kind)})
+		}
+		for _, r := range tok.Text {
+			if tok.Kind == tokenText {
+				switch r {
+				case '(':
+					openParens = append(openParens, structureIssue{line: line, column: col, msg: "unclosed parenthesis"})
+				case ')':
+					if len(openParens) == 0 {
+						issues = append(issues, structureIssue{line: line, column: col, msg: "unmatched closing parenthesis"})
+					} else {
+						openParens = openParens[:len(openParens)-1]
+					}
+				}
+			}
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+	}
+	return append(issues, openParens...)
+}
+
+// openTokenName names what an unclosed token of kind was opened as
+func openTokenName(kind sqlTokenKind) string {
+	switch kind {
+	case tokenIdentifier:
+		return "quoted identifier"
+	case tokenComment:
+		return "comment"
+	default:
+		return "string"
+	}
+}
+
+// UnterminatedError is returned by CheckTerminated for a script that ends inside
+// a quote or block comment, which the splitter would otherwise send to the server
+// as one statement swallowing everything after the opening
+type UnterminatedError struct {
+	What   string // "string", "quoted identifier" or "comment"
+	Line   int    // 1-based line of the opening quote or comment
+	Column int    // 1-based column of the opening quote or comment
+}
+
+func (e *UnterminatedError) Error() string {
+	return fmt.Sprintf("unterminated %s in SQL, opened at line %d column %d", e.What, e.Line, e.Column)
+}
+
+// CheckTerminated returns an *UnterminatedError if splitting sqls ends inside a
+// string, quoted identifier or block comment. It scans with the splitter's
+// sqlScanner, so it sees exactly the quotes and comments the splitter does. Only
+// the first such opening is reported, since it runs to the end of the script.
+func CheckTerminated(sqls string) error {
+	line, col := 1, 1
+	for _, tok := range tokenizeSQL(sqls) {
+		if tok.Open {
+			return &UnterminatedError{What: openTokenName(tok.Kind), Line: line, Column: col}
+		}
+		line, col = textPosition(tok.Text, line, col)
+	}
+	return nil
+}
+
+// textPosition advances a 1-based line and column over text
+func textPosition(text string, line, col int) (int, int) {
+	for _, r := range text {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLintSQL_Structure(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string // Expected issues, as LintIssue.String()
+	}{
+		{name: "clean script", sql: "SELECT 1;\nSELECT COUNT(*) FROM t WHERE a IN (1, 2);", want: nil},
+		{name: "parens inside strings are ignored", sql: "SELECT ')' , '(';", want: nil},
+		{
+			name: "unterminated string",
+			sql:  "SELECT 1;\nSELECT 'abc;\nSELECT 2;",
+			want: []string{"2:8: error: unterminated string (statement 2)"},
+		},
+		{
+			name: "unterminated quoted identifier",
+			sql:  "SELECT `col FROM t",
+			want: []string{"1:8: error: unterminated quoted identifier (statement 1)"},
+		},
+		{
+			name: "unterminated block comment",
+			sql:  "SELECT 1 /* no end",
+			want: []string{"1:10: error: unterminated comment (statement 1)"},
+		},
+		{
+			name: "unclosed parenthesis",
+			sql:  "SELECT 1;\n\n  SELECT COUNT(\n    id FROM t;",
+			want: []string{"3:15: error: unclosed parenthesis (statement 2)"},
+		},
+		{
+			name: "unmatched closing parenthesis",
+			sql:  "SELECT (1 + 2)) FROM t",
+			want: []string{"1:15: error: unmatched closing parenthesis (statement 1)"},
+		},
+		{
+			name: "second line of statement",
+			sql:  "SELECT a,\n  (b FROM t;",
+			want: []string{"2:3: error: unclosed parenthesis (statement 1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range LintSQL(tt.sql, Terminator{}) {
+				got = append(got, issue.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("LintSQL(%q) =\n%s\nwant\n%s", tt.sql, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// fakeParser rejects statements containing "FORM" as a syntax error and those
+// containing "PLUGIN" as unsupported
+type fakeParser struct{}
+
+func (fakeParser) Parse(sql string) error {
+	if i := strings.Index(sql, "FORM"); i >= 0 {
+		line := strings.Count(sql[:i], "\n") + 1
+		col := i - strings.LastIndex(sql[:i], "\n")
+		return &SyntaxError{Line: line, Column: col, Msg: `near "FORM"`}
+	}
+	if strings.Contains(sql, "PLUGIN") {
+		return errors.New("statement type not supported")
+	}
+	return nil
+}
+
+func TestCheckTerminated(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string // Expected error; empty for none
+	}{
+		{name: "terminated", sql: "SELECT 'a;b', `c`;\nSELECT 1 /* x */; -- trailing comment"},
+		{name: "escaped and doubled quotes", sql: "SELECT 'it\\'s', 'it''s';"},
+		{name: "block comment opening inside a line comment", sql: "SELECT 1; -- see /* notes\nSELECT 2;\nSELECT 3;"},
+		{name: "line comment opening inside a block comment", sql: "/* drop -- old */ SELECT 1; SELECT 2;\nSELECT 3;"},
+		{name: "quote after a commented comment opening", sql: "SELECT 1; -- see /* notes\nSELECT 'x;", want: "unterminated string in SQL, opened at line 2 column 8"},
+		{name: "block comment swallowing a line comment", sql: "SELECT 1;\n/* -- */ SELECT 2 /* open;", want: "unterminated comment in SQL, opened at line 2 column 19"},
+		{name: "single quote", sql: "SELECT 1;\nUPDATE t SET a = 'x;\nSELECT 2;", want: "unterminated string in SQL, opened at line 2 column 18"},
+		{name: "backtick", sql: "SELECT `col FROM t;", want: "unterminated quoted identifier in SQL, opened at line 1 column 8"},
+		{name: "block comment", sql: "SELECT 1;\n/* disabled:\nSELECT 2;", want: "unterminated comment in SQL, opened at line 2 column 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTerminated(tt.sql)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("CheckTerminated() error = %v, want nil", err)
+				}
+				return
+			}
+			var unterminated *UnterminatedError
+			if !errors.As(err, &unterminated) || err.Error() != tt.want {
+				t.Errorf("CheckTerminated() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintSQL_Parser(t *testing.T) {
+	RegisterSQLParser(fakeParser{})
+	t.Cleanup(func() { RegisterSQLParser(nil) })
+
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{name: "valid", sql: "SELECT a FROM t;", want: nil},
+		{
+			name: "syntax error positioned in script",
+			sql:  "SELECT 1;\n  SELECT a\n  FORM t;",
+			want: []string{`3:3: error: near "FORM" (statement 2)`},
+		},
+		{
+			name: "syntax error on first line of indented statement",
+			sql:  "SELECT 1;  SELECT a FORM t;",
+			want: []string{`1:21: error: near "FORM" (statement 2)`},
+		},
+		{
+			name: "version-specific syntax degrades to warning",
+			sql:  "/*!80000 SELECT a FORM t */;",
+			want: []string{`1:19: warning: parser rejected version-specific syntax: near "FORM" (statement 1)`},
+		},
+		{
+			name: "unsupported statement degrades to warning",
+			sql:  "INSTALL PLUGIN x SONAME 'x.so';",
+			want: []string{"1:1: warning: statement not checked by parser: statement type not supported (statement 1)"},
+		},
+		{
+			name: "structural errors skip the parser",
+			sql:  "SELECT (a FORM t;",
+			want: []string{"1:8: error: unclosed parenthesis (statement 1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range LintSQL(tt.sql, Terminator{}) {
+				got = append(got, issue.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("LintSQL(%q) =\n%s\nwant\n%s", tt.sql, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownCell escapes a value for a cell of a GitHub-flavored markdown table:
+// pipes would end the cell and line breaks the row
+var markdownCell = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// markdownFence returns a code fence longer than any run of backticks in s, so a
+// statement quoting one cannot close its block early
+func markdownFence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// MarkdownOptions controls how PrintResultMarkdown writes a result
+type MarkdownOptions struct {
+	ItalicNull bool // Write NULL as *NULL*, telling it apart from the string 'NULL'
+}
+
+// markdownRow writes cells as a row of a markdown table
+func markdownRow(w io.Writer, cells []string) {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = markdownCell.Replace(cell)
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+}
+
+// markdownValueRow writes a row of values, NULL as opts say. Italic NULL markup
+// is added after escaping, so it is not escaped itself.
+func markdownValueRow(w io.Writer, row []interface{}, opts MarkdownOptions) {
+	cells := rowStrings(row)
+	for i, v := range row {
+		if v == nil && opts.ItalicNull {
+			cells[i] = "*NULL*"
+		} else {
+			cells[i] = markdownCell.Replace(cells[i])
+		}
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+}
+
+// markdownSeparator returns the row under a table's header: numeric columns are
+// right-aligned, the others left-aligned
+func markdownSeparator(res QueryResult) string {
+	cells := make([]string, len(res.Columns))
+	for i := range cells {
+		cells[i] = ":---"
+		if i < len(res.ColumnTypes) && isNumericType(res.ColumnTypes[i].DatabaseType) {
+			cells[i] = "---:"
+		}
+	}
+	return "| " + strings.Join(cells, " | ") + " |"
+}
+
+// PrintResultMarkdown writes a result as GitHub-flavored markdown, ready to paste
+// into an issue or wiki page: a heading with the instance, the statement in a
+// fenced code block, then a table of the rows. Empty results, statements without
+// columns and failed or skipped statements get a one-line italic note instead.
+func PrintResultMarkdown(w io.Writer, res QueryResult, opts MarkdownOptions) {
+	heading := maskPasswordInDSN(res.Instance)
+	if location := res.Location(); location != "" {
+		heading += " (" + location + ")"
+	}
+	fence := markdownFence(res.Statement)
+	fmt.Fprintf(w, "#### %s\n\n%ssql\n%s\n%s\n\n", heading, fence, res.Statement, fence)
+
+	switch {
+	case res.Skipped:
+		fmt.Fprintf(w, "_Skipped: %s_\n\n", markdownCell.Replace(fmt.Sprint(res.Err)))
+		return
+	case res.Err != nil:
+		fmt.Fprintf(w, "_Error: %s_\n\n", markdownCell.Replace(fmt.Sprint(res.Err)))
+		return
+	case len(res.Columns) == 0:
+		fmt.Fprintf(w, "_No result set._\n\n")
+		return
+	case len(res.Rows) == 0:
+		fmt.Fprintf(w, "_Empty set._\n\n")
+		return
+	}
+
+	markdownRow(w, res.Columns)
+	fmt.Fprintln(w, markdownSeparator(res))
+	for _, row := range res.Rows {
+		markdownValueRow(w, row, opts)
+	}
+	if res.OmittedRows > 0 {
+		fmt.Fprintf(w, "\n_%d more row(s) not shown._\n", res.OmittedRows)
+	}
+	fmt.Fprintln(w)
+}
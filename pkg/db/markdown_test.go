@@ -0,0 +1,73 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPrintResultMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		res  QueryResult
+		opts MarkdownOptions
+		want string
+	}{
+		{
+			name: "table with escaped values",
+			res: QueryResult{
+				Instance:  "u:secret@tcp(db1:3306)/app",
+				Statement: "SELECT id, note FROM t",
+				Columns:   []string{"id", "note"},
+				Rows:      [][]interface{}{{int64(1), []byte("a|b")}, {int64(2), nil}, {int64(3), []byte("line1\nline2")}},
+				RowCount:  3,
+			},
+			want: "#### u:****@tcp(db1:3306)/app\n\n```sql\nSELECT id, note FROM t\n```\n\n" +
+				"| id | note |\n| :--- | :--- |\n| 1 | a\\|b |\n| 2 | NULL |\n| 3 | line1<br>line2 |\n\n",
+		},
+		{
+			name: "numeric columns right-aligned, NULL in italics",
+			res: QueryResult{
+				Instance:    "u:p@tcp(db1:3306)/",
+				Statement:   "SELECT id, price, note FROM t",
+				Columns:     []string{"id", "price", "note"},
+				ColumnTypes: []ColumnType{{DatabaseType: "UNSIGNED BIGINT"}, {DatabaseType: "DECIMAL"}, {DatabaseType: "VARCHAR"}},
+				Rows:        [][]interface{}{{int64(1), nil, []byte("NULL")}, {int64(2), []byte("9.50"), []byte("*x*")}},
+				RowCount:    2,
+			},
+			opts: MarkdownOptions{ItalicNull: true},
+			want: "#### u:****@tcp(db1:3306)/\n\n```sql\nSELECT id, price, note FROM t\n```\n\n" +
+				"| id | price | note |\n| ---: | ---: | :--- |\n| 1 | *NULL* | NULL |\n| 2 | 9.50 | *x* |\n\n",
+		},
+		{
+			name: "empty set",
+			res:  QueryResult{Instance: "u:p@tcp(db1:3306)/", Statement: "SELECT 1 FROM t WHERE 0", Columns: []string{"1"}},
+			want: "#### u:****@tcp(db1:3306)/\n\n```sql\nSELECT 1 FROM t WHERE 0\n```\n\n_Empty set._\n\n",
+		},
+		{
+			name: "statement without columns",
+			res:  QueryResult{Instance: "u:p@tcp(db1:3306)/", Statement: "SET @a = 1"},
+			want: "#### u:****@tcp(db1:3306)/\n\n```sql\nSET @a = 1\n```\n\n_No result set._\n\n",
+		},
+		{
+			name: "error",
+			res:  QueryResult{Instance: "u:p@tcp(db1:3306)/", Statement: "SELECT x", Err: errors.New("unknown column 'x' | bad")},
+			want: "#### u:****@tcp(db1:3306)/\n\n```sql\nSELECT x\n```\n\n_Error: unknown column 'x' \\| bad_\n\n",
+		},
+		{
+			name: "statement quoting a fence",
+			res:  QueryResult{Instance: "u:p@tcp(db1:3306)/", Statement: "SELECT '```'"},
+			want: "#### u:****@tcp(db1:3306)/\n\n````sql\nSELECT '```'\n````\n\n_No result set._\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintResultMarkdown(&buf, tt.res, tt.opts)
+			if buf.String() != tt.want {
+				t.Errorf("PrintResultMarkdown() =\n%q\nwant\n%q", buf.String(), tt.want)
+			}
+		})
+	}
+}
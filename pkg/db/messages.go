@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Default texts printed alongside results, in every output format
+const (
+	MsgEmptySet  = "Empty set."
+	MsgNoColumns = "Statement executed successfully, no columns returned."
+	MsgQueryOK   = "Query OK"
+	MsgRowsInSet = "{rows} rows in set" // {rows} is replaced by the row count
+	MsgError     = "ERROR"
+	MsgSkipped   = "SKIPPED"
+
+	MsgRowsOmitted = "({rows} more rows omitted)" // {rows} is replaced by the number of rows not shown
+)
+
+// Messages customizes (or localizes) the texts printed alongside results. Empty
+// fields use the Msg* defaults.
+type Messages struct {
+	EmptySet  string // A statement returned columns but no rows
+	NoColumns string // A statement returned no result set, e.g. INSERT or SET
+	QueryOK   string // Footer for statements without a result set (-vv)
+	RowsInSet string // Row count footer (-vv); {rows} is replaced by the count
+	Error     string // Label of a failed statement
+	Skipped   string // Label of a statement that was not run
+
+	RowsOmitted string // Note under a result cut short by --first-row-only; {rows} is replaced by the count
+}
+
+// withDefaults fills empty fields with the default texts
+func (m Messages) withDefaults() Messages {
+	if m.EmptySet == "" {
+		m.EmptySet = MsgEmptySet
+	}
+	if m.NoColumns == "" {
+		m.NoColumns = MsgNoColumns
+	}
+	if m.QueryOK == "" {
+		m.QueryOK = MsgQueryOK
+	}
+	if m.RowsInSet == "" {
+		m.RowsInSet = MsgRowsInSet
+	}
+	if m.Error == "" {
+		m.Error = MsgError
+	}
+	if m.Skipped == "" {
+		m.Skipped = MsgSkipped
+	}
+	if m.RowsOmitted == "" {
+		m.RowsOmitted = MsgRowsOmitted
+	}
+	return m
+}
+
+// rowsInSet renders the row count footer text for n rows
+func (m Messages) rowsInSet(n int) string {
+	return strings.ReplaceAll(m.RowsInSet, "{rows}", strconv.Itoa(n))
+}
+
+// writeNoColumns reports a statement without a result set; -vv adds the Query OK footer
+func writeNoColumns(w io.Writer, res QueryResult, verbose int, msgs Messages) {
+	fmt.Fprintln(w, msgs.NoColumns)
+	// Verbosity level 2 and above: Show timing for non-select statements
+	if verbose >= 2 {
+		fmt.Fprint(w, msgs.QueryOK)
+		if verbose >= 3 {
+			fmt.Fprintf(w, " (%v)", res.Duration)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeRowCount writes the row count footer shown from -vv, with timing at -vvv.
+// A note on rows omitted by ExecOptions.FirstRowOnly is always written.
+func writeRowCount(w io.Writer, res QueryResult, verbose int, msgs Messages) {
+	if res.OmittedRows > 0 {
+		fmt.Fprintln(w, strings.ReplaceAll(msgs.RowsOmitted, "{rows}", strconv.Itoa(res.OmittedRows)))
+	}
+	if verbose < 2 {
+		return
+	}
+	fmt.Fprintf(w, "(%s", msgs.rowsInSet(res.RowCount))
+	if verbose >= 3 {
+		fmt.Fprintf(w, " (%v)", res.Duration)
+	}
+	fmt.Fprintln(w, ")")
+}
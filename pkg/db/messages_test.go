@@ -0,0 +1,67 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestRenderResult_Messages(t *testing.T) {
+	originalNoColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = originalNoColor })
+	color.NoColor = true
+
+	noColumns := QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "SET @a = 1"}
+	emptySet := QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT a FROM t", Columns: []string{"a"}}
+	withRows := QueryResult{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT a FROM t", Columns: []string{"a"},
+		Rows: [][]interface{}{{1}, {2}}, RowCount: 2}
+
+	custom := Messages{EmptySet: "Leere Menge.", NoColumns: "Anweisung ausgeführt.", QueryOK: "OK", RowsInSet: "{rows} Zeilen"}
+
+	formats := map[string]func(QueryResult, *PrintOptions) QueryResult{
+		"plain":    func(r QueryResult, o *PrintOptions) QueryResult { return r },
+		"table":    func(r QueryResult, o *PrintOptions) QueryResult { o.TableFormat = true; return r },
+		"vertical": func(r QueryResult, o *PrintOptions) QueryResult { r.VerticalFormat = true; return r },
+	}
+	tests := []struct {
+		name     string
+		res      QueryResult
+		verbose  int
+		messages Messages
+		want     []string
+		notWant  []string
+	}{
+		{name: "no result set", res: noColumns, want: []string{MsgNoColumns}, notWant: []string{MsgEmptySet, MsgQueryOK}},
+		{name: "no result set -vv", res: noColumns, verbose: 2, want: []string{MsgNoColumns, MsgQueryOK + "\n"}},
+		{name: "empty set", res: emptySet, want: []string{MsgEmptySet}, notWant: []string{MsgNoColumns, "rows in set"}},
+		{name: "empty set -vv", res: emptySet, verbose: 2, want: []string{MsgEmptySet, "(0 rows in set)"}},
+		{name: "rows -vv", res: withRows, verbose: 2, want: []string{"(2 rows in set)"}, notWant: []string{MsgEmptySet}},
+		{name: "custom no result set", res: noColumns, verbose: 2, messages: custom, want: []string{"Anweisung ausgeführt.", "OK\n"}, notWant: []string{MsgNoColumns}},
+		{name: "custom empty set", res: emptySet, verbose: 2, messages: custom, want: []string{"Leere Menge.", "(0 Zeilen)"}, notWant: []string{MsgEmptySet}},
+		{name: "partial custom messages keep defaults", res: withRows, verbose: 2, messages: Messages{EmptySet: "none"}, want: []string{"(2 rows in set)"}},
+	}
+
+	for _, tt := range tests {
+		for format, apply := range formats {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				opts := PrintOptions{Verbose: tt.verbose, Messages: tt.messages}
+				res := apply(tt.res, &opts)
+
+				var buf bytes.Buffer
+				RenderResult(&buf, res, nil, opts)
+				for _, want := range tt.want {
+					if !strings.Contains(buf.String(), want) {
+						t.Errorf("output missing %q:\n%s", want, buf.String())
+					}
+				}
+				for _, notWant := range tt.notWant {
+					if strings.Contains(buf.String(), notWant) {
+						t.Errorf("output unexpectedly contains %q:\n%s", notWant, buf.String())
+					}
+				}
+			})
+		}
+	}
+}
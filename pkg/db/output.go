@@ -0,0 +1,204 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Stream identifies the kind of output a block carries. By contract, results go to
+// stdout and diagnostics (progress, warnings, failover notes) go to stderr, unless a
+// stream is routed elsewhere.
+type Stream int
+
+const (
+	StreamResults     Stream = iota // Query results and run information
+	StreamDiagnostics               // Errors, warnings and progress
+)
+
+// OutputSink serializes output from concurrent goroutines. Everything is written in
+// blocks (one result, one diagnostic line) and a block is never split by another
+// goroutine's output. Blocks can also be held back per key and released later, so
+// modes that report instances in a fixed order can still render concurrently.
+//
+// Blocks written for a key (an instance) can be routed to a writer of their own,
+// and every result block can be copied to a tee writer. Writers given to RouteKey
+// and Tee must be safe for concurrent use, such as *SyncFile: keyed blocks are
+// written under the writer's own lock, so instances writing to different files
+// never wait on each other. They are files, so RenderFor renders their blocks
+// without colors, while the stream writers follow the process-wide color setting.
+type OutputSink struct {
+	mu      sync.Mutex
+	writers map[Stream]io.Writer
+	held    map[string][]heldBlock
+	keyed   map[string]io.Writer
+	tee     io.Writer
+}
+
+// heldBlock is a rendered block waiting for Release
+type heldBlock struct {
+	stream Stream
+	data   []byte
+}
+
+// NewOutputSink returns a sink writing results to stdout and diagnostics to stderr
+func NewOutputSink(stdout, stderr io.Writer) *OutputSink {
+	return &OutputSink{
+		writers: map[Stream]io.Writer{StreamResults: stdout, StreamDiagnostics: stderr},
+		held:    map[string][]heldBlock{},
+		keyed:   map[string]io.Writer{},
+	}
+}
+
+// DefaultOutput is the sink used when no other sink is configured
+var DefaultOutput = NewOutputSink(os.Stdout, os.Stderr)
+
+// Route sends a stream's blocks to w, e.g. a file, instead of its current writer
+func (s *OutputSink) Route(stream Stream, w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writers[stream] = w
+}
+
+// RouteKey sends the blocks written for key with BlockFor to w instead of their stream
+func (s *OutputSink) RouteKey(key string, w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyed[key] = w
+}
+
+// Tee copies every result block to w as well, e.g. an audit file
+func (s *OutputSink) Tee(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tee = w
+}
+
+// Writer returns the writer a stream is currently routed to
+func (s *OutputSink) Writer(stream Stream) io.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writers[stream]
+}
+
+// WriteBlock writes data to a stream as one uninterrupted block
+func (s *OutputSink) WriteBlock(stream Stream, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(s.writers[stream], stream, data, data)
+}
+
+// writeLocked writes a block to w and, for results, teeData to the tee. s.mu must
+// be held.
+func (s *OutputSink) writeLocked(w io.Writer, stream Stream, data, teeData []byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return writeTee(s.tee, stream, teeData)
+}
+
+// writeTee copies a result block to the tee, if there is one
+func writeTee(tee io.Writer, stream Stream, data []byte) error {
+	if stream == StreamResults && tee != nil {
+		if _, err := tee.Write(data); err != nil {
+			return fmt.Errorf("tee: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteBlockFor writes a block belonging to key. If key was routed with RouteKey the
+// block goes to that writer (and the tee) without holding the sink's lock;
+// otherwise it is written to its stream like WriteBlock.
+func (s *OutputSink) WriteBlockFor(key string, stream Stream, data []byte) error {
+	return s.writeFor(key, stream, data, data)
+}
+
+// writeFor writes a block belonging to key as WriteBlockFor does, with teeData as
+// the tee's copy
+func (s *OutputSink) writeFor(key string, stream Stream, data, teeData []byte) error {
+	s.mu.Lock()
+	w, routed := s.keyed[key]
+	if !routed {
+		defer s.mu.Unlock()
+		return s.writeLocked(s.writers[stream], stream, data, teeData)
+	}
+	tee := s.tee
+	s.mu.Unlock()
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return writeTee(tee, stream, teeData)
+}
+
+// Block renders a block with render and writes it as a unit. Rendering happens
+// outside the lock, so slow formatting never blocks other goroutines' output.
+func (s *OutputSink) Block(stream Stream, render func(w io.Writer)) error {
+	var buf bytes.Buffer
+	render(&buf)
+	return s.WriteBlock(stream, buf.Bytes())
+}
+
+// BlockFor renders a block belonging to key and writes it with WriteBlockFor
+func (s *OutputSink) BlockFor(key string, stream Stream, render func(w io.Writer)) error {
+	var buf bytes.Buffer
+	render(&buf)
+	return s.WriteBlockFor(key, stream, buf.Bytes())
+}
+
+// RenderFor renders a block belonging to key and writes it like WriteBlockFor,
+// rendered once per kind of destination: render is called with plain set for the
+// files (a writer routed with RouteKey, and the tee), so they never receive ANSI
+// colors, and with plain unset for a stream writer. A result printed to a colored
+// terminal and teed to a file is thus rendered twice, and the file gets the same
+// bytes as it would without the terminal.
+func (s *OutputSink) RenderFor(key string, stream Stream, render func(w io.Writer, plain bool)) error {
+	s.mu.Lock()
+	_, routed := s.keyed[key]
+	teed := stream == StreamResults && s.tee != nil
+	s.mu.Unlock()
+
+	var colored, plain bytes.Buffer
+	if routed || teed || color.NoColor {
+		render(&plain, true)
+	}
+	if routed || color.NoColor {
+		return s.writeFor(key, stream, plain.Bytes(), plain.Bytes())
+	}
+	render(&colored, false)
+	return s.writeFor(key, stream, colored.Bytes(), plain.Bytes())
+}
+
+// Printf writes a formatted block, typically a single diagnostic line
+func (s *OutputSink) Printf(stream Stream, format string, args ...interface{}) {
+	_ = s.WriteBlock(stream, []byte(fmt.Sprintf(format, args...)))
+}
+
+// Hold renders a block and keeps it under key until Release is called
+func (s *OutputSink) Hold(key string, stream Stream, render func(w io.Writer)) {
+	var buf bytes.Buffer
+	render(&buf)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held[key] = append(s.held[key], heldBlock{stream: stream, data: buf.Bytes()})
+}
+
+// Release writes all blocks held under key, in the order they were held, without
+// letting other output in between
+func (s *OutputSink) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocks := s.held[key]
+	delete(s.held, key)
+	for _, b := range blocks {
+		if err := s.writeLocked(s.writers[b.stream], b.stream, b.data, b.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
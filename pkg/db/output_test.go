@@ -0,0 +1,284 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// lockedBuffer is a bytes.Buffer that tolerates concurrent writers, so the race
+// detector only flags races inside the sink itself
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestOutputSink_ConcurrentBlocksDoNotInterleave(t *testing.T) {
+	var stdout, stderr lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+
+	const goroutines, blocksEach, linesPerBlock = 32, 50, 5
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for b := 0; b < blocksEach; b++ {
+				_ = sink.Block(StreamResults, func(w io.Writer) {
+					for l := 0; l < linesPerBlock; l++ {
+						// Write piecemeal to give interleaving every chance to happen
+						fmt.Fprintf(w, "g%d b%d ", g, b)
+						fmt.Fprintf(w, "line%d\n", l)
+					}
+				})
+				sink.Printf(StreamDiagnostics, "g%d heartbeat %d\n", g, b)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != goroutines*blocksEach*linesPerBlock {
+		t.Fatalf("got %d result lines, want %d", len(lines), goroutines*blocksEach*linesPerBlock)
+	}
+	for i := 0; i < len(lines); i += linesPerBlock {
+		owner := strings.Fields(lines[i])
+		for l := 0; l < linesPerBlock; l++ {
+			want := fmt.Sprintf("%s %s line%d", owner[0], owner[1], l)
+			if lines[i+l] != want {
+				t.Fatalf("block starting at line %d interleaved: line %d = %q, want %q", i, i+l, lines[i+l], want)
+			}
+		}
+	}
+
+	heartbeats := strings.Count(stderr.String(), "heartbeat")
+	if heartbeats != goroutines*blocksEach {
+		t.Errorf("got %d heartbeat lines, want %d", heartbeats, goroutines*blocksEach)
+	}
+}
+
+// ansiSequence matches the SGR escape sequences colors are written with
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripANSI removes color escape sequences from s
+func stripANSI(s string) string {
+	return ansiSequence.ReplaceAllString(s, "")
+}
+
+func TestOutputSink_ConcurrentColoredLinesStayIntact(t *testing.T) {
+	originalNoColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = originalNoColor })
+	color.NoColor = false
+
+	var stdout, stderr lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+	colors := []*color.Color{color.New(color.FgCyan), color.New(color.FgMagenta, color.Bold), color.New(color.FgYellow)}
+
+	const goroutines, linesEach = 24, 40
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			c := colors[g%len(colors)]
+			for l := 0; l < linesEach; l++ {
+				// A colored result line and a progress line, as mid-flight streaming writes them
+				_ = sink.RenderFor(fmt.Sprint(g), StreamResults, func(w io.Writer, plain bool) {
+					if plain {
+						fmt.Fprintf(w, "g%d line%d\n", g, l)
+						return
+					}
+					c.Fprint(w, "g", g)
+					fmt.Fprintf(w, " line%d", l)
+					c.Fprint(w, "")
+					fmt.Fprintln(w)
+				})
+				sink.Printf(StreamDiagnostics, "%s g%d progress %d\n", c.Sprint("=>"), g, l)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	plain := regexp.MustCompile(`^g\d+ line\d+$`)
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		if stripped := stripANSI(line); !plain.MatchString(stripped) {
+			t.Fatalf("result line garbled: %q", line)
+		}
+		if !strings.HasPrefix(line, "\x1b[") {
+			t.Fatalf("result line lost its color: %q", line)
+		}
+	}
+	progress := regexp.MustCompile(`^=> g\d+ progress \d+$`)
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	if len(lines) != goroutines*linesEach {
+		t.Fatalf("got %d progress lines, want %d", len(lines), goroutines*linesEach)
+	}
+	for _, line := range lines {
+		if !progress.MatchString(stripANSI(line)) || strings.Count(line, "\x1b[") != 2 {
+			t.Fatalf("progress line garbled: %q", line)
+		}
+	}
+}
+
+func TestOutputSink_HoldAndRelease(t *testing.T) {
+	var stdout, stderr lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				sink.Hold(key, StreamResults, func(w io.Writer) { fmt.Fprintf(w, "%s%d\n", key, i) })
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if stdout.String() != "" {
+		t.Fatalf("held blocks were written before release: %q", stdout.String())
+	}
+	for _, key := range []string{"c", "a", "b"} {
+		if err := sink.Release(key); err != nil {
+			t.Fatalf("Release(%q) error = %v", key, err)
+		}
+	}
+	if want := "c0\nc1\nc2\na0\na1\na2\nb0\nb1\nb2\n"; stdout.String() != want {
+		t.Errorf("released output = %q, want %q", stdout.String(), want)
+	}
+
+	// Released keys are forgotten
+	if err := sink.Release("a"); err != nil || stdout.String() != "c0\nc1\nc2\na0\na1\na2\nb0\nb1\nb2\n" {
+		t.Errorf("second Release wrote again or failed: %v", err)
+	}
+}
+
+func TestOutputSink_Route(t *testing.T) {
+	var stdout, stderr, file lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+	sink.Route(StreamDiagnostics, &file)
+
+	sink.Printf(StreamResults, "result\n")
+	sink.Printf(StreamDiagnostics, "warning\n")
+
+	if stdout.String() != "result\n" || stderr.String() != "" || file.String() != "warning\n" {
+		t.Errorf("stdout=%q stderr=%q file=%q, want diagnostics routed to file", stdout.String(), stderr.String(), file.String())
+	}
+}
+
+func TestOutputSink_RenderFor(t *testing.T) {
+	original := color.NoColor
+	t.Cleanup(func() { color.NoColor = original })
+	color.NoColor = false
+
+	render := func(w io.Writer, plain bool) {
+		fmt.Fprintf(w, "%s\n", map[bool]string{true: "plain", false: "colored"}[plain])
+	}
+
+	tests := []struct {
+		name                   string
+		routed, teed           bool
+		wantStdout, wantRouted string
+		wantTee                string
+	}{
+		{name: "terminal only", wantStdout: "colored\n"},
+		{name: "terminal and tee", teed: true, wantStdout: "colored\n", wantTee: "plain\n"},
+		{name: "routed file and tee", routed: true, teed: true, wantRouted: "plain\n", wantTee: "plain\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, routed, tee lockedBuffer
+			sink := NewOutputSink(&stdout, io.Discard)
+			if tt.routed {
+				sink.RouteKey("db1", &routed)
+			}
+			if tt.teed {
+				sink.Tee(&tee)
+			}
+			if err := sink.RenderFor("db1", StreamResults, render); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.wantStdout || routed.String() != tt.wantRouted || tee.String() != tt.wantTee {
+				t.Errorf("stdout=%q routed=%q tee=%q, want %q %q %q", stdout.String(), routed.String(), tee.String(),
+					tt.wantStdout, tt.wantRouted, tt.wantTee)
+			}
+		})
+	}
+}
+
+func TestPrintResultWithOptions_UsesSink(t *testing.T) {
+	var stdout, stderr lockedBuffer
+	sink := NewOutputSink(&stdout, &stderr)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res := QueryResult{
+				Instance:  fmt.Sprintf("u:p@tcp(h%d:3306)/d", i),
+				Statement: "SELECT a, b",
+				Columns:   []string{"a", "b"},
+				Rows:      [][]interface{}{{i, "x"}, {i, "y"}},
+				RowCount:  2,
+			}
+			PrintResultWithOptions(res, color.New(color.FgCyan), PrintOptions{Output: sink})
+		}(i)
+	}
+	wg.Wait()
+
+	// Each result is header, column line and two rows, always contiguous
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 20*4 {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), 20*4, stdout.String())
+	}
+	for i := 0; i < len(lines); i += 4 {
+		var n int
+		if _, err := fmt.Sscanf(lines[i+2], "%d\tx", &n); err != nil {
+			t.Fatalf("line %d = %q, want first row of a result", i+2, lines[i+2])
+		}
+		if want := fmt.Sprintf("%d\ty", n); lines[i+3] != want {
+			t.Errorf("line %d = %q, want %q (result interleaved)", i+3, lines[i+3], want)
+		}
+	}
+}
+
+func TestPrintResult_NilColor(t *testing.T) {
+	originalNoColor, originalOutput := color.NoColor, DefaultOutput
+	t.Cleanup(func() { color.NoColor, DefaultOutput = originalNoColor, originalOutput })
+	color.NoColor = false // Colors on, so a nil color would have to be dereferenced
+
+	results := []QueryResult{
+		{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT 1", Columns: []string{"1"}, Rows: [][]interface{}{{1}}, RowCount: 1},
+		{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT x", Err: fmt.Errorf("unknown column")},
+		{Instance: "u:p@tcp(h:3306)/d", Statement: "SELECT 2", Skipped: true, Err: fmt.Errorf("cancelled")},
+	}
+	for _, res := range results {
+		var stdout, stderr lockedBuffer
+		DefaultOutput = NewOutputSink(&stdout, &stderr)
+
+		PrintResultWithVerbosity(res, nil, true, 3)
+		if !strings.Contains("\n"+stdout.String(), "\n[u:****@tcp(h:3306)/d] ") {
+			t.Errorf("%s: output = %q, want an uncolored instance label", res.Statement, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParquetType is the physical type, with its annotation, of a Parquet column
+type ParquetType string
+
+const (
+	ParquetInt64  ParquetType = "INT64"
+	ParquetDouble ParquetType = "DOUBLE"
+	ParquetString ParquetType = "UTF8"       // BYTE_ARRAY annotated as UTF-8 text
+	ParquetBinary ParquetType = "BYTE_ARRAY" // Raw bytes, for binary columns
+)
+
+// ParquetColumn is a column of a Parquet file written by WriteParquet. Every
+// column is optional, as any value may be NULL.
+type ParquetColumn struct {
+	Name string // Letters, digits and _ only, unique within the file
+	Type ParquetType
+}
+
+// ParquetEncoder writes rows to w as a Parquet file. Values are in their text
+// form, nil for NULL, and are converted to each column's type by the encoder.
+type ParquetEncoder interface {
+	Encode(w io.Writer, columns []ParquetColumn, rows [][]*string) error
+}
+
+// ErrParquetUnavailable is returned by WriteParquet in builds without a Parquet encoder
+var ErrParquetUnavailable = errors.New("Parquet output requires a build with -tags parquet")
+
+var parquetEncoder ParquetEncoder
+
+// RegisterParquetEncoder installs the encoder WriteParquet uses
+func RegisterParquetEncoder(e ParquetEncoder) {
+	parquetEncoder = e
+}
+
+// HasParquetEncoder reports whether WriteParquet can write files
+func HasParquetEncoder() bool {
+	return parquetEncoder != nil
+}
+
+// ParquetInstanceColumn is the leading column of a file written by WriteParquet,
+// holding the masked DSN of the instance each row came from
+const ParquetInstanceColumn = "instance"
+
+// WriteParquet writes the rows of results, from any number of instances and
+// statements, to w as a single Parquet file: an instance column, then the
+// results' columns with types inferred from their database types. Failed and
+// skipped results and statements without columns are left out; the others must
+// all have the same columns, or a *ShapeMismatchError is returned. It returns the
+// number of rows written.
+func WriteParquet(w io.Writer, results []QueryResult) (int, error) {
+	if parquetEncoder == nil {
+		return 0, ErrParquetUnavailable
+	}
+	var kept []QueryResult
+	for _, res := range results {
+		if res.Err == nil && !res.Skipped && len(res.Columns) > 0 {
+			kept = append(kept, res)
+		}
+	}
+	if len(kept) == 0 {
+		return 0, fmt.Errorf("no statement returned a result set to write")
+	}
+	if err := CheckShapes(kept); err != nil {
+		return 0, err
+	}
+
+	var rows [][]*string
+	for _, res := range kept {
+		instance := MaskDSN(res.Instance)
+		for _, row := range res.Rows {
+			values := make([]*string, 1, len(row)+1)
+			values[0] = &instance
+			for _, v := range row {
+				values = append(values, parquetValue(v))
+			}
+			rows = append(rows, values)
+		}
+	}
+	if err := parquetEncoder.Encode(w, ParquetSchema(parquetColumnTypes(kept)), rows); err != nil {
+		return 0, fmt.Errorf("failed to write Parquet: %w", err)
+	}
+	return len(rows), nil
+}
+
+// parquetColumnTypes returns the column types of results sharing their columns,
+// from the first result that has them all
+func parquetColumnTypes(results []QueryResult) []ColumnType {
+	for _, res := range results {
+		if len(res.ColumnTypes) == len(res.Columns) {
+			return res.ColumnTypes
+		}
+	}
+	types := make([]ColumnType, len(results[0].Columns))
+	for i, col := range results[0].Columns {
+		types[i] = ColumnType{Name: col}
+	}
+	return types
+}
+
+// ParquetSchema returns the columns of a file written by WriteParquet for results
+// with these column types: integers become INT64 (BIGINT UNSIGNED, which may not
+// fit, and DECIMAL, which must stay exact, become text), FLOAT and DOUBLE become
+// DOUBLE, binary columns raw bytes, and everything else, dates included, text.
+func ParquetSchema(columnTypes []ColumnType) []ParquetColumn {
+	columns := []ParquetColumn{{Name: ParquetInstanceColumn, Type: ParquetString}}
+	used := map[string]bool{ParquetInstanceColumn: true}
+	for _, ct := range columnTypes {
+		columns = append(columns, ParquetColumn{Name: parquetName(ct.Name, used), Type: parquetType(ct.DatabaseType)})
+	}
+	return columns
+}
+
+// parquetType infers the Parquet type of a column from its database type
+func parquetType(dbType string) ParquetType {
+	upper := strings.ToUpper(dbType)
+	switch strings.TrimPrefix(upper, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "YEAR":
+		return ParquetInt64
+	case "BIGINT":
+		if strings.HasPrefix(upper, "UNSIGNED ") {
+			return ParquetString
+		}
+		return ParquetInt64
+	case "FLOAT", "DOUBLE":
+		return ParquetDouble
+	}
+	if isBinaryType(upper) {
+		return ParquetBinary
+	}
+	return ParquetString
+}
+
+// parquetName makes a column name a valid, unique Parquet name: characters other
+// than letters, digits and _ become _, and repeated names get a _2, _3... suffix
+func parquetName(name string, used map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	if base == "" {
+		base = "_"
+	}
+	unique := base
+	for n := 2; used[strings.ToLower(unique)]; n++ {
+		unique = base + "_" + strconv.Itoa(n)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// parquetValue returns a scanned value in text form, nil for NULL
+func parquetValue(v interface{}) *string {
+	if v == nil {
+		return nil
+	}
+	s := string(valueBytes(v))
+	return &s
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// recordingEncoder is a ParquetEncoder that keeps what it was asked to write
+type recordingEncoder struct {
+	columns []ParquetColumn
+	rows    [][]*string
+}
+
+func (e *recordingEncoder) Encode(_ io.Writer, columns []ParquetColumn, rows [][]*string) error {
+	e.columns, e.rows = columns, rows
+	return nil
+}
+
+// useParquetEncoder installs e for the test's duration
+func useParquetEncoder(t *testing.T, e ParquetEncoder) {
+	t.Helper()
+	original := parquetEncoder
+	RegisterParquetEncoder(e)
+	t.Cleanup(func() { parquetEncoder = original })
+}
+
+func TestParquetSchema(t *testing.T) {
+	got := ParquetSchema([]ColumnType{
+		{Name: "id", DatabaseType: "INT"},
+		{Name: "hits", DatabaseType: "UNSIGNED BIGINT"},
+		{Name: "ratio", DatabaseType: "DOUBLE"},
+		{Name: "total", DatabaseType: "DECIMAL"},
+		{Name: "COUNT(*)", DatabaseType: "BIGINT"},
+		{Name: "payload", DatabaseType: "BLOB"},
+		{Name: "created", DatabaseType: "DATETIME"},
+		{Name: "Instance", DatabaseType: "VARCHAR"},
+		{Name: "id", DatabaseType: "YEAR"},
+	})
+	want := []ParquetColumn{
+		{Name: "instance", Type: ParquetString},
+		{Name: "id", Type: ParquetInt64},
+		{Name: "hits", Type: ParquetString},
+		{Name: "ratio", Type: ParquetDouble},
+		{Name: "total", Type: ParquetString},
+		{Name: "COUNT___", Type: ParquetInt64},
+		{Name: "payload", Type: ParquetBinary},
+		{Name: "created", Type: ParquetString},
+		{Name: "Instance_2", Type: ParquetString},
+		{Name: "id_2", Type: ParquetInt64},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParquetSchema() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	results := []QueryResult{
+		{Instance: "app:secret@tcp(db1:3306)/shop", Statement: "SELECT id, name FROM t", Columns: []string{"id", "name"},
+			ColumnTypes: []ColumnType{{Name: "id", DatabaseType: "BIGINT"}, {Name: "name", DatabaseType: "VARCHAR"}},
+			Rows:        [][]interface{}{{int64(1), []byte("tea")}, {int64(2), nil}}},
+		{Instance: "app:secret@tcp(db2:3306)/shop", Statement: "SELECT id, name FROM t", Err: errors.New("boom")},
+		{Instance: "app:secret@tcp(db3:3306)/shop", Statement: "SET @a = 1"},
+		{Instance: "app:secret@tcp(db3:3306)/shop", Statement: "SELECT id, name FROM t", Columns: []string{"id", "name"},
+			Rows: [][]interface{}{{[]byte("3"), []byte("coffee")}}},
+	}
+
+	useParquetEncoder(t, nil) // As in builds without the parquet tag
+	if _, err := WriteParquet(io.Discard, results); !errors.Is(err, ErrParquetUnavailable) {
+		t.Fatalf("WriteParquet() without an encoder error = %v, want ErrParquetUnavailable", err)
+	}
+
+	enc := &recordingEncoder{}
+	RegisterParquetEncoder(enc)
+	n, err := WriteParquet(&bytes.Buffer{}, results)
+	if err != nil || n != 3 {
+		t.Fatalf("WriteParquet() = %d, %v; want 3 rows", n, err)
+	}
+	if want := []ParquetColumn{{"instance", ParquetString}, {"id", ParquetInt64}, {"name", ParquetString}}; !reflect.DeepEqual(enc.columns, want) {
+		t.Errorf("columns = %+v, want %+v", enc.columns, want)
+	}
+	var got [][]string
+	for _, row := range enc.rows {
+		var values []string
+		for _, v := range row {
+			if v == nil {
+				values = append(values, "<nil>")
+			} else {
+				values = append(values, *v)
+			}
+		}
+		got = append(got, values)
+	}
+	want := [][]string{
+		{"app:****@tcp(db1:3306)/shop", "1", "tea"},
+		{"app:****@tcp(db1:3306)/shop", "2", "<nil>"},
+		{"app:****@tcp(db3:3306)/shop", "3", "coffee"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+
+	// Results of different shapes cannot share a file
+	results = append(results, QueryResult{Instance: "app:secret@tcp(db4:3306)/shop", Statement: "SELECT 1", Columns: []string{"1"}})
+	var mismatch *ShapeMismatchError
+	if _, err := WriteParquet(&bytes.Buffer{}, results); !errors.As(err, &mismatch) {
+		t.Errorf("WriteParquet() error = %v, want a *ShapeMismatchError", err)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build parquet
+
+package db
+
+// Parquet output for --parquet using github.com/xitongsys/parquet-go.
+// Build with: go get github.com/xitongsys/parquet-go && go build -tags parquet ./...
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func init() {
+	RegisterParquetEncoder(xitongsysEncoder{})
+}
+
+// xitongsysEncoder writes Parquet files with parquet-go's CSV writer, which takes
+// rows of text values and converts them to the columns' types
+type xitongsysEncoder struct{}
+
+func (xitongsysEncoder) Encode(w io.Writer, columns []ParquetColumn, rows [][]*string) error {
+	metadata := make([]string, len(columns))
+	for i, col := range columns {
+		var typ string
+		switch col.Type {
+		case ParquetInt64:
+			typ = "type=INT64"
+		case ParquetDouble:
+			typ = "type=DOUBLE"
+		case ParquetString:
+			typ = "type=BYTE_ARRAY, convertedtype=UTF8"
+		default:
+			typ = "type=BYTE_ARRAY"
+		}
+		metadata[i] = fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", col.Name, typ)
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(metadata, w, 1)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := pw.WriteString(row); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
@@ -0,0 +1,66 @@
+//go:build parquet
+
+package db
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// memFile is an in-memory Parquet file for reading back what was written
+type memFile struct {
+	*bytes.Reader
+}
+
+func (f memFile) Write(p []byte) (int, error)               { return 0, io.ErrUnexpectedEOF }
+func (f memFile) Close() error                              { return nil }
+func (f memFile) Open(string) (source.ParquetFile, error)   { return f, nil }
+func (f memFile) Create(string) (source.ParquetFile, error) { return f, nil }
+
+func TestWriteParquet_ReadsBack(t *testing.T) {
+	results := []QueryResult{
+		{
+			Instance:    "app:secret@tcp(db1:3306)/shop",
+			Columns:     []string{"id", "price", "name"},
+			ColumnTypes: []ColumnType{{Name: "id", DatabaseType: "BIGINT"}, {Name: "price", DatabaseType: "DOUBLE"}, {Name: "name", DatabaseType: "VARCHAR"}},
+			Rows:        [][]interface{}{{int64(1), float64(2.5), []byte("tea")}, {int64(2), nil, []byte("café")}},
+		},
+		{
+			Instance: "app:secret@tcp(db2:3306)/shop",
+			Columns:  []string{"id", "price", "name"},
+			Rows:     [][]interface{}{{[]byte("3"), []byte("4.25"), nil}},
+		},
+	}
+	var buf bytes.Buffer
+	n, err := WriteParquet(&buf, results)
+	if err != nil || n != 3 {
+		t.Fatalf("WriteParquet() = %d, %v; want 3 rows", n, err)
+	}
+
+	pr, err := reader.NewParquetReader(memFile{bytes.NewReader(buf.Bytes())}, nil, 1)
+	if err != nil {
+		t.Fatalf("NewParquetReader() error = %v", err)
+	}
+	defer pr.ReadStop()
+	if got := pr.GetNumRows(); got != 3 {
+		t.Errorf("GetNumRows() = %d, want 3", got)
+	}
+	var names []string
+	var types []parquet.Type
+	for i, el := range pr.SchemaHandler.SchemaElements[1:] { // Past the root
+		names = append(names, pr.SchemaHandler.GetExName(i+1))
+		types = append(types, el.GetType())
+	}
+	if want := []string{"instance", "id", "price", "name"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("columns = %q, want %q", names, want)
+	}
+	if want := []parquet.Type{parquet.Type_BYTE_ARRAY, parquet.Type_INT64, parquet.Type_DOUBLE, parquet.Type_BYTE_ARRAY}; !reflect.DeepEqual(types, want) {
+		t.Errorf("types = %v, want %v", types, want)
+	}
+}
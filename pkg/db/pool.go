@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Session is an open connection to one instance. All statements run on a session
+// share one server session, so USE, SET and temporary tables carry over.
+type Session struct {
+	Instance  string        // The instance DSN as configured
+	Handshake time.Duration // Time taken to open and verify the connection
+
+	connectDSN        string // DSN actually dialed (rewritten for SSH tunnels, --proxy and --failover-aware)
+	db                *sql.DB
+	conn              *sql.Conn // Replaced when failover reconnects
+	handshakeReported bool      // Handshake was attached to a result already
+	caps              Capabilities
+	capsReported      bool // Unavailable capabilities were logged already
+	failed            int  // Statements that failed, across runs, for MaxErrorsPerInstance
+}
+
+// Connect opens a session to an instance and verifies it with a ping. Its errors
+// are a *ConnectionError.
+func Connect(ctx context.Context, instanceDSN string, opts ExecOptions) (*Session, error) {
+	start := time.Now()
+	dsn := withTLS(instanceDSN, opts.TLS)
+	connectDSN := dsn
+	if opts.Tunnels != nil {
+		var err error
+		if connectDSN, err = opts.Tunnels.rewrite(ctx, instanceDSN, dsn); err != nil {
+			return nil, &ConnectionError{Instance: instanceDSN, Err: err}
+		}
+	}
+	switch {
+	case opts.Proxy && connectDSN == dsn:
+		// The proxy resolves the host on every dial, as the failover dialer would
+		connectDSN = withProxyNetwork(connectDSN)
+	case opts.FailoverAware:
+		connectDSN = withFailoverNetwork(connectDSN)
+	}
+	if opts.ConnectTimeout > 0 {
+		connectDSN = withConnectTimeout(connectDSN, opts.ConnectTimeout)
+	}
+	connectDSN = withIOTimeouts(connectDSN, opts.ReadTimeout, opts.WriteTimeout)
+
+	db, err := sql.Open(DriverName, connectDSN)
+	if err != nil {
+		return nil, &ConnectionError{Instance: instanceDSN, Err: fmt.Errorf("failed to open connection: %w", err)}
+	}
+
+	// Ping to verify connection early
+	conn, err := openConn(ctx, db, instanceDSN, opts)
+	if err != nil {
+		db.Close()
+		if !errors.Is(err, ErrConnectTimeout) {
+			err = fmt.Errorf("failed to ping database: %w", err)
+		}
+		return nil, &ConnectionError{Instance: instanceDSN, Err: err}
+	}
+
+	return &Session{
+		Instance:   instanceDSN,
+		Handshake:  time.Since(start),
+		connectDSN: connectDSN,
+		db:         db,
+		conn:       conn,
+	}, nil
+}
+
+// Close ends the session and its connection pool
+func (s *Session) Close() error {
+	connErr := s.conn.Close()
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	return connErr
+}
+
+// InstancePool holds one session per instance across several runs. Sessions are
+// opened up front by PreConnect, so the connection handshake cost is paid
+// concurrently, or on an instance's first Run.
+type InstancePool struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	failures map[string]QueryResult // Connect failures, as the instance's single result
+}
+
+// NewInstancePool returns an empty pool that connects to instances on first use
+func NewInstancePool() *InstancePool {
+	return &InstancePool{
+		sessions: make(map[string]*Session),
+		failures: make(map[string]QueryResult),
+	}
+}
+
+// PreConnect opens sessions to all instances concurrently, with at most maxParallel
+// handshakes in flight (0 means unlimited). Instances that cannot be reached are
+// recorded as failures rather than aborting the others.
+func PreConnect(ctx context.Context, instances []string, maxParallel int, opts ExecOptions) *InstancePool {
+	pool := NewInstancePool()
+	if maxParallel <= 0 || maxParallel > len(instances) {
+		maxParallel = len(instances)
+	}
+
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for _, instanceDSN := range instances {
+		wg.Add(1)
+		go func(dsn string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			sess, err := Connect(ctx, dsn, opts)
+
+			pool.mu.Lock()
+			defer pool.mu.Unlock()
+			if err != nil {
+				pool.failures[dsn] = connectFailure(ctx, dsn, err, time.Since(start), opts)
+				return
+			}
+			pool.sessions[dsn] = sess
+		}(instanceDSN)
+	}
+	wg.Wait()
+	return pool
+}
+
+// Session returns the warm session for an instance, if pre-connecting succeeded
+func (p *InstancePool) Session(instanceDSN string) (*Session, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sess, ok := p.sessions[instanceDSN]
+	return sess, ok
+}
+
+// Info describes an instance with an open session in the pool, probing its
+// capabilities on first use
+func (p *InstancePool) Info(ctx context.Context, instanceDSN string) (InstanceInfo, bool) {
+	sess, ok := p.Session(instanceDSN)
+	if !ok {
+		return InstanceInfo{}, false
+	}
+	return sess.Info(ctx), true
+}
+
+// Failures returns the instances that could not be pre-connected, keyed by DSN,
+// each with the result describing why
+func (p *InstancePool) Failures() map[string]QueryResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failures := make(map[string]QueryResult, len(p.failures))
+	for dsn, res := range p.failures {
+		failures[dsn] = res
+	}
+	return failures
+}
+
+// Run executes sqls on an instance's session, so session state (USE, SET, temporary
+// tables) carries over between runs. An instance that failed to connect reports
+// that failure; an instance without a session is connected to now and its session
+// kept for the next run.
+func (p *InstancePool) Run(ctx context.Context, instanceDSN string, sqls string, opts ExecOptions) []QueryResult {
+	sess, failure, ok := p.open(ctx, instanceDSN, opts)
+	if !ok {
+		return []QueryResult{failure}
+	}
+	return RunSQLOnSession(ctx, sess, sqls, opts)
+}
+
+// open returns an instance's session, connecting now if it has none. An instance
+// that cannot be connected to returns the result describing why instead.
+func (p *InstancePool) open(ctx context.Context, instanceDSN string, opts ExecOptions) (*Session, QueryResult, bool) {
+	p.mu.Lock()
+	sess, ok := p.sessions[instanceDSN]
+	failure, failed := p.failures[instanceDSN]
+	p.mu.Unlock()
+
+	switch {
+	case ok:
+		return sess, QueryResult{}, true
+	case failed:
+		return nil, failure, false
+	}
+
+	// Don't even connect if the run was cancelled before this instance started
+	if ctx.Err() != nil {
+		return nil, QueryResult{Instance: instanceDSN, Skipped: true, Err: skipReason(ctx, opts)}, false
+	}
+	start := time.Now()
+	sess, err := Connect(ctx, instanceDSN, opts)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		failure = connectFailure(ctx, instanceDSN, err, time.Since(start), opts)
+		if failure.ConnectFailed {
+			p.failures[instanceDSN] = failure
+		}
+		return nil, failure, false
+	}
+	p.sessions[instanceDSN] = sess
+	return sess, QueryResult{}, true
+}
+
+// Close closes every session in the pool
+func (p *InstancePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for dsn, sess := range p.sessions {
+		sess.Close()
+		delete(p.sessions, dsn)
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestPreConnect(t *testing.T) {
+	useFakeDriver(t)
+	healthy := dbtest.NewServer(t, "pool-healthy")
+	healthy.ConnectDelay(5 * time.Millisecond)
+	down := dbtest.NewServer(t, "pool-down")
+	down.FailConnect(errors.New("connection refused"))
+
+	pool := PreConnect(context.Background(), []string{healthy.DSN(), down.DSN()}, 0, ExecOptions{})
+	defer pool.Close()
+
+	if _, ok := pool.Session(healthy.DSN()); !ok {
+		t.Fatal("healthy instance has no session")
+	}
+	failures := pool.Failures()
+	if len(failures) != 1 || !failures[down.DSN()].ConnectFailed {
+		t.Fatalf("Failures() = %+v, want the unreachable instance", failures)
+	}
+
+	results := pool.Run(context.Background(), healthy.DSN(), "SELECT 1; SELECT 2", ExecOptions{})
+	if len(results) != 2 || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("Run() = %+v, want two successful results", results)
+	}
+	if results[0].Handshake < 5*time.Millisecond || results[1].Handshake != 0 {
+		t.Errorf("Handshake = %v, %v; want the connect time on the first result only", results[0].Handshake, results[1].Handshake)
+	}
+	if healthy.Opened() != 1 {
+		t.Errorf("healthy instance opened %d connections, want the pre-connected one only", healthy.Opened())
+	}
+
+	results = pool.Run(context.Background(), down.DSN(), "SELECT 1", ExecOptions{})
+	if len(results) != 1 || !results[0].ConnectFailed {
+		t.Errorf("Run() on unreachable instance = %+v, want its connect failure", results)
+	}
+}
+
+func TestPreConnect_MaxParallel(t *testing.T) {
+	useFakeDriver(t)
+
+	var instances []string
+	for i := 0; i < 6; i++ {
+		srv := dbtest.NewServer(t, fmt.Sprintf("pool-parallel-%d", i))
+		srv.ConnectDelay(20 * time.Millisecond)
+		instances = append(instances, srv.DSN())
+	}
+
+	for _, maxParallel := range []int{1, 2, 0} {
+		t.Run(fmt.Sprintf("max=%d", maxParallel), func(t *testing.T) {
+			dbtest.ResetConnectStats()
+			pool := PreConnect(context.Background(), instances, maxParallel, ExecOptions{})
+			defer pool.Close()
+
+			if len(pool.Failures()) != 0 {
+				t.Fatalf("unexpected failures: %+v", pool.Failures())
+			}
+			got := dbtest.MaxConcurrentConnects()
+			if maxParallel > 0 && got > int64(maxParallel) {
+				t.Errorf("%d handshakes ran concurrently, want at most %d", got, maxParallel)
+			}
+			if maxParallel == 0 && got < 2 {
+				t.Errorf("unlimited pre-connect ran %d handshake(s) at once, want them concurrent", got)
+			}
+		})
+	}
+}
+
+func TestInstancePool_KeepsSessionsAcrossRuns(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "pool-lazy")
+	srv.ConnectDelay(5 * time.Millisecond)
+
+	pool := NewInstancePool()
+	defer pool.Close()
+
+	first := pool.Run(context.Background(), srv.DSN(), "USE otherdb", ExecOptions{})
+	second := pool.Run(context.Background(), srv.DSN(), "SELECT DATABASE()", ExecOptions{})
+
+	if len(second) != 1 || second[0].Err != nil || second[0].Rows[0][0] != "otherdb" {
+		t.Fatalf("second Run() = %+v, want the schema selected by the first run", second)
+	}
+	if srv.Opened() != 1 {
+		t.Errorf("opened %d connections, want one session for both runs", srv.Opened())
+	}
+	if first[0].Handshake < 5*time.Millisecond || second[0].Handshake != 0 {
+		t.Errorf("Handshake = %v, %v; want the connect time reported on the first run only", first[0].Handshake, second[0].Handshake)
+	}
+
+	// An unreachable instance is not retried on every run
+	down := dbtest.NewServer(t, "pool-lazy-down")
+	down.FailConnect(errors.New("connection refused"))
+	for i := 0; i < 2; i++ {
+		if results := pool.Run(context.Background(), down.DSN(), "SELECT 1", ExecOptions{}); !results[0].ConnectFailed {
+			t.Errorf("Run() on unreachable instance = %+v, want its connect failure", results)
+		}
+	}
+	if _, failed := pool.Failures()[down.DSN()]; !failed {
+		t.Error("connect failure was not recorded")
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errNoSuchThread is ER_NO_SUCH_THREAD, returned by KILL for a thread that is gone
+const errNoSuchThread = 1094
+
+// processListQuery reads every thread but the one asking
+const processListQuery = "SELECT ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO " +
+	"FROM information_schema.PROCESSLIST WHERE ID <> CONNECTION_ID()"
+
+// Process is one thread of an instance's processlist
+type Process struct {
+	Instance string
+	ID       uint64
+	User     string
+	Host     string
+	DB       string
+	Command  string
+	Time     int64 // Seconds in the current state
+	State    string
+	Info     string // The statement being run; empty when idle
+}
+
+// ListProcesses returns the threads running on an instance, leaving out the
+// connection that reads them
+func ListProcesses(ctx context.Context, instanceDSN string, opts ExecOptions) ([]Process, error) {
+	instanceDSN = strings.TrimSpace(instanceDSN)
+	sess, err := Connect(ctx, instanceDSN, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+
+	rows, err := sess.conn.QueryContext(ctx, processListQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the processlist: %w", err)
+	}
+	defer rows.Close()
+
+	var processes []Process
+	for rows.Next() {
+		p := Process{Instance: instanceDSN}
+		var host, database, command, state, info sql.NullString
+		var seconds sql.NullInt64
+		if err := rows.Scan(&p.ID, &p.User, &host, &database, &command, &seconds, &state, &info); err != nil {
+			return nil, fmt.Errorf("failed to read the processlist: %w", err)
+		}
+		p.Host, p.DB, p.Command, p.State, p.Info = host.String, database.String, command.String, state.String, info.String
+		p.Time = seconds.Int64
+		processes = append(processes, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the processlist: %w", err)
+	}
+	return processes, nil
+}
+
+// KillThreads sends KILL for each thread id on an instance, over one connection,
+// and returns an error per id (nil when it was killed). A thread that exited
+// before its KILL yields an error IsNoSuchThread recognizes. If the instance cannot
+// be reached, every id gets the connection error.
+func KillThreads(ctx context.Context, instanceDSN string, ids []uint64, opts ExecOptions) []error {
+	errs := make([]error, len(ids))
+	sess, err := Connect(ctx, strings.TrimSpace(instanceDSN), opts)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer sess.Close()
+
+	for i, id := range ids {
+		_, errs[i] = sess.conn.ExecContext(ctx, fmt.Sprintf("KILL %d", id))
+	}
+	return errs
+}
+
+// IsNoSuchThread reports whether err is the server saying the thread to kill does
+// not exist, e.g. because its statement finished in the meantime
+func IsNoSuchThread(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errNoSuchThread
+}
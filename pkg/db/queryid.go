@@ -0,0 +1,33 @@
+package db
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// NewQueryID returns a random version 4 UUID identifying one statement execution
+func NewQueryID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err)) // Only on a broken system
+	}
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// QueryIDComment returns the comment a statement is marked with for id. The server
+// ignores it, but keeps it in the statement text of the processlist and of the slow
+// and general query logs, so the statement can be found there by its id.
+func QueryIDComment(id string) string {
+	return "/* csql:" + id + " */"
+}
+
+// MarkedStatement returns the statement as reported, preceded by its query id
+// comment when it was executed with one
+func (r QueryResult) MarkedStatement() string {
+	if r.QueryID == "" {
+		return r.Statement
+	}
+	return QueryIDComment(r.QueryID) + " " + r.Statement
+}
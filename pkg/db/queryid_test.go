@@ -0,0 +1,73 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewQueryID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := NewQueryID()
+		if !uuidV4.MatchString(id) {
+			t.Fatalf("NewQueryID() = %q, want a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("NewQueryID() returned %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRunSQLOnInstance_MarkQueryID(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "queryid")
+	srv.Fallback(dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(1)})
+
+	tests := []struct {
+		name     string
+		mark     bool
+		sql      string
+		wantExec string // Executed text after the marker, or all of it without one
+	}{
+		{name: "unmarked", sql: "SELECT n FROM t", wantExec: "SELECT n FROM t"},
+		{name: "marked", mark: true, sql: "SELECT n FROM t", wantExec: "SELECT n FROM t"},
+		{name: "optimizer hint kept in place", mark: true, sql: "SELECT /*+ MAX_EXECUTION_TIME(100) */ n FROM t", wantExec: "SELECT /*+ MAX_EXECUTION_TIME(100) */ n FROM t"},
+		{name: "vertical", mark: true, sql: "SELECT n FROM t\\G", wantExec: "SELECT n FROM t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(srv.Executed())
+			results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), tt.sql, ExecOptions{MarkQueryID: tt.mark})
+			res := results[0]
+			executed := srv.Executed()[before:]
+			if res.Err != nil || len(executed) != 1 {
+				t.Fatalf("result = %+v, executed %q", res, executed)
+			}
+
+			if !tt.mark {
+				if res.QueryID != "" || executed[0] != tt.wantExec {
+					t.Errorf("executed %q with id %q, want %q unmarked", executed[0], res.QueryID, tt.wantExec)
+				}
+				return
+			}
+			if want := "/* csql:" + res.QueryID + " */ " + tt.wantExec; !uuidV4.MatchString(res.QueryID) || executed[0] != want {
+				t.Errorf("executed %q, want %q", executed[0], want)
+			}
+
+			// The id is echoed with the statement so it can be grepped in the server logs
+			var out bytes.Buffer
+			RenderResult(&out, res, nil, PrintOptions{})
+			if !strings.Contains(out.String(), "/* csql:"+res.QueryID+" */ "+tt.sql) {
+				t.Errorf("output does not echo the query id:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package db
+
+// StatementSchema describes the columns a statement returns on an instance, for
+// tools that need a result's shape before its data
+type StatementSchema struct {
+	Instance  string       `json:"instance"` // With the password masked
+	Statement string       `json:"statement"`
+	Location  string       `json:"location,omitempty"` // Where the statement is in its script, e.g. "report.sql:12"
+	Columns   []ColumnType `json:"columns"`            // Empty for statements that return no rows
+	Error     string       `json:"error,omitempty"`
+}
+
+// ResultSchema describes the columns of a result, as read with ExecOptions.ColumnsOnly
+func ResultSchema(res QueryResult) StatementSchema {
+	schema := StatementSchema{
+		Instance:  maskPasswordInDSN(res.Instance),
+		Statement: res.Statement,
+		Location:  res.Location(),
+		Columns:   res.ColumnTypes,
+	}
+	if schema.Columns == nil {
+		schema.Columns = []ColumnType{}
+	}
+	if res.Err != nil {
+		schema.Error = res.Err.Error()
+	}
+	return schema
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestResultSchema(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "schema")
+	srv.Handle("SELECT id, name, note FROM users", dbtest.Response{
+		Columns:  []string{"id", "name", "note"},
+		Types:    []string{"BIGINT", "VARCHAR", "TEXT"},
+		Nullable: []bool{false, false, true},
+		Lengths:  []int64{0, 64, 65535},
+		Rows:     [][]driver.Value{{int64(1), "ann", nil}},
+	})
+	srv.Handle("SET @x = 1", dbtest.Response{})
+	srv.Handle("SELECT broken", dbtest.Response{Err: errors.New("Error 1054: Unknown column 'broken' in 'field list'")})
+
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), "SELECT id, name, note FROM users; SET @x = 1; SELECT broken", ExecOptions{ColumnsOnly: true})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[0].RowCount != 0 || len(results[0].Rows) != 0 {
+		t.Errorf("read %d row(s), want none with ColumnsOnly", results[0].RowCount)
+	}
+
+	want := []string{
+		`{"instance":"user:****@tcp(schema:3306)/app","statement":"SELECT id, name, note FROM users","columns":[` +
+			`{"name":"id","type":"BIGINT","nullable":false,"length":0},` +
+			`{"name":"name","type":"VARCHAR","nullable":false,"length":64},` +
+			`{"name":"note","type":"TEXT","nullable":true,"length":65535}]}`,
+		`{"instance":"user:****@tcp(schema:3306)/app","statement":"SET @x = 1","columns":[]}`,
+		`{"instance":"user:****@tcp(schema:3306)/app","statement":"SELECT broken","columns":[],"error":"query error: Error 1054: Unknown column 'broken' in 'field list'"}`,
+	}
+	for i, res := range results {
+		data, err := MarshalJSON(ResultSchema(res), false)
+		if err != nil {
+			t.Fatalf("MarshalJSON() error = %v", err)
+		}
+		if string(data) != want[i] {
+			t.Errorf("schema %d =\n%s\nwant\n%s", i, data, want[i])
+		}
+	}
+}
+
+func TestResultSchema_UnknownNullabilityAndLength(t *testing.T) {
+	res := QueryResult{Instance: "db1", Statement: "SELECT 1", Line: 3, Source: "report.sql", ColumnTypes: []ColumnType{{Name: "1", DatabaseType: "BIGINT"}}}
+	data, err := MarshalJSON(ResultSchema(res), false)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	want := `{"instance":"db1","statement":"SELECT 1","location":"report.sql:3","columns":[{"name":"1","type":"BIGINT","nullable":null,"length":null}]}`
+	if string(data) != want {
+		t.Errorf("schema = %s, want %s", data, want)
+	}
+}
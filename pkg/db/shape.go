@@ -0,0 +1,146 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ResultShape is one distinct column list a statement returned, and the instances
+// that returned it
+type ResultShape struct {
+	Columns   []string
+	Instances []string
+}
+
+// ShapeMismatchError is reported by cross-instance operations (merging, diffing,
+// checksums, a shared CSV header) when a statement returned different columns on
+// different instances, e.g. SHOW REPLICA STATUS across server versions
+type ShapeMismatchError struct {
+	Statement string
+	Shapes    []ResultShape
+}
+
+func (e *ShapeMismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s returned %d different column sets:", e.Statement, len(e.Shapes))
+	for _, shape := range e.Shapes {
+		masked := make([]string, len(shape.Instances))
+		for i, instanceDSN := range shape.Instances {
+			masked[i] = maskPasswordInDSN(instanceDSN)
+		}
+		fmt.Fprintf(&b, "\n  (%s) from %s", strings.Join(shape.Columns, ", "), strings.Join(masked, ", "))
+	}
+	return b.String()
+}
+
+// ResultShapes groups the results of one statement across instances by column
+// list, in the order the shapes are first seen. Failed and skipped results and
+// statements without columns have no shape and are left out.
+func ResultShapes(results []QueryResult) []ResultShape {
+	var shapes []ResultShape
+	for _, res := range results {
+		if res.Err != nil || res.Skipped || len(res.Columns) == 0 {
+			continue
+		}
+		i := slices.IndexFunc(shapes, func(s ResultShape) bool { return slices.Equal(s.Columns, res.Columns) })
+		if i < 0 {
+			shapes = append(shapes, ResultShape{Columns: res.Columns})
+			i = len(shapes) - 1
+		}
+		shapes[i].Instances = append(shapes[i].Instances, res.Instance)
+	}
+	return shapes
+}
+
+// CheckShapes returns a *ShapeMismatchError unless the results of a statement all
+// have the same columns in the same order. A permutation counts as a different
+// shape, since positional consumers would pair values with the wrong columns.
+func CheckShapes(results []QueryResult) error {
+	shapes := ResultShapes(results)
+	if len(shapes) < 2 {
+		return nil
+	}
+	statement := ""
+	if len(results) > 0 {
+		statement = results[0].Statement
+	}
+	return &ShapeMismatchError{Statement: statement, Shapes: shapes}
+}
+
+// UnionColumns returns every column of shapes in the order first seen. A name
+// repeated within one shape (SELECT a, a) is kept as often as any shape repeats it.
+func UnionColumns(shapes []ResultShape) []string {
+	var union []string
+	for _, shape := range shapes {
+		seen := make(map[string]int)
+		for _, col := range shape.Columns {
+			seen[col]++
+			if countOf(union, col) < seen[col] {
+				union = append(union, col)
+			}
+		}
+	}
+	return union
+}
+
+// CoerceColumns returns res with its columns, column types and row values
+// rearranged to columns, filling columns res does not have with NULL. Repeated
+// names are matched by occurrence; columns of res not in columns are dropped.
+func CoerceColumns(res QueryResult, columns []string) QueryResult {
+	// source[i] is the index in res.Columns that column i comes from, or -1
+	source := make([]int, len(columns))
+	used := make(map[string]int)
+	for i, col := range columns {
+		source[i] = nthIndex(res.Columns, col, used[col])
+		used[col]++
+	}
+
+	out := res
+	out.Columns = columns
+	if res.ColumnTypes != nil {
+		out.ColumnTypes = make([]ColumnType, len(columns))
+		for i, from := range source {
+			if from >= 0 && from < len(res.ColumnTypes) {
+				out.ColumnTypes[i] = res.ColumnTypes[from]
+			} else {
+				out.ColumnTypes[i] = ColumnType{Name: columns[i]}
+			}
+		}
+	}
+	out.Rows = make([][]interface{}, len(res.Rows))
+	for r, row := range res.Rows {
+		coerced := make([]interface{}, len(columns))
+		for i, from := range source {
+			if from >= 0 && from < len(row) {
+				coerced[i] = row[from]
+			}
+		}
+		out.Rows[r] = coerced
+	}
+	return out
+}
+
+// countOf returns how often s occurs in list
+func countOf(list []string, s string) int {
+	n := 0
+	for _, v := range list {
+		if v == s {
+			n++
+		}
+	}
+	return n
+}
+
+// nthIndex returns the index of the nth (from 0) occurrence of s in list, or -1
+func nthIndex(list []string, s string, n int) int {
+	for i, v := range list {
+		if v == s {
+			if n == 0 {
+				return i
+			}
+			n--
+		}
+	}
+	return -1
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shapeResult returns a result of "SHOW REPLICA STATUS" on instance with columns
+func shapeResult(instance string, columns ...string) QueryResult {
+	return QueryResult{Instance: "u:secret@tcp(" + instance + ":3306)/", Statement: "SHOW REPLICA STATUS", Columns: columns}
+}
+
+func TestCheckShapes(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []QueryResult
+		wantShapes [][]string // Column sets in the error; nil when the shapes agree
+	}{
+		{
+			name:    "same columns",
+			results: []QueryResult{shapeResult("db1", "a", "b"), shapeResult("db2", "a", "b")},
+		},
+		{
+			name: "failed and skipped results have no shape",
+			results: []QueryResult{
+				shapeResult("db1", "a", "b"),
+				{Instance: "db2", Err: errors.New("boom")},
+				{Instance: "db3", Skipped: true, Err: errors.New("cancelled")},
+				shapeResult("db4"),
+			},
+		},
+		{
+			name:       "permuted columns",
+			results:    []QueryResult{shapeResult("db1", "a", "b"), shapeResult("db2", "b", "a")},
+			wantShapes: [][]string{{"a", "b"}, {"b", "a"}},
+		},
+		{
+			name: "subset of columns",
+			results: []QueryResult{
+				shapeResult("db1", "a", "b"),
+				shapeResult("db2", "a", "b", "c"),
+				shapeResult("db3", "a", "b"),
+			},
+			wantShapes: [][]string{{"a", "b"}, {"a", "b", "c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckShapes(tt.results)
+			if tt.wantShapes == nil {
+				if err != nil {
+					t.Errorf("CheckShapes() error = %v, want nil", err)
+				}
+				return
+			}
+			var mismatch *ShapeMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("CheckShapes() error = %v, want a *ShapeMismatchError", err)
+			}
+			var got [][]string
+			for _, shape := range mismatch.Shapes {
+				got = append(got, shape.Columns)
+			}
+			if !reflect.DeepEqual(got, tt.wantShapes) {
+				t.Errorf("shapes = %q, want %q", got, tt.wantShapes)
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("error leaks the password: %v", err)
+			}
+		})
+	}
+}
+
+func TestShapeMismatchError_Error(t *testing.T) {
+	err := CheckShapes([]QueryResult{
+		shapeResult("db1", "a", "b"),
+		shapeResult("db2", "a", "b", "c"),
+		shapeResult("db3", "a", "b"),
+	})
+	want := "SHOW REPLICA STATUS returned 2 different column sets:\n" +
+		"  (a, b) from u:****@tcp(db1:3306)/, u:****@tcp(db3:3306)/\n" +
+		"  (a, b, c) from u:****@tcp(db2:3306)/"
+	if err == nil || err.Error() != want {
+		t.Errorf("error =\n%v\nwant\n%s", err, want)
+	}
+}
+
+func TestUnionColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		shapes [][]string
+		want   []string
+	}{
+		{name: "subset", shapes: [][]string{{"a", "b"}, {"a", "b", "c"}}, want: []string{"a", "b", "c"}},
+		{name: "permuted", shapes: [][]string{{"a", "b"}, {"b", "a"}}, want: []string{"a", "b"}},
+		{name: "disjoint tails", shapes: [][]string{{"a", "x"}, {"a", "y"}}, want: []string{"a", "x", "y"}},
+		{name: "repeated name", shapes: [][]string{{"a", "a"}, {"a", "b"}}, want: []string{"a", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shapes []ResultShape
+			for _, cols := range tt.shapes {
+				shapes = append(shapes, ResultShape{Columns: cols})
+			}
+			if got := UnionColumns(shapes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnionColumns() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceColumns(t *testing.T) {
+	res := QueryResult{
+		Columns:     []string{"b", "a", "a"},
+		ColumnTypes: []ColumnType{{Name: "b", DatabaseType: "INT"}, {Name: "a", DatabaseType: "VARCHAR"}, {Name: "a", DatabaseType: "TEXT"}},
+		Rows:        [][]interface{}{{int64(1), "x", "y"}, {int64(2), nil, "z"}},
+	}
+
+	got := CoerceColumns(res, []string{"a", "b", "c", "a"})
+	if want := [][]interface{}{{"x", int64(1), nil, "y"}, {nil, int64(2), nil, "z"}}; fmt.Sprint(got.Rows) != fmt.Sprint(want) {
+		t.Errorf("rows = %v, want %v", got.Rows, want)
+	}
+	wantTypes := []ColumnType{{Name: "a", DatabaseType: "VARCHAR"}, {Name: "b", DatabaseType: "INT"}, {Name: "c"}, {Name: "a", DatabaseType: "TEXT"}}
+	if !reflect.DeepEqual(got.ColumnTypes, wantTypes) {
+		t.Errorf("column types = %v, want %v", got.ColumnTypes, wantTypes)
+	}
+	if fmt.Sprint(res.Rows[0]) != "[1 x y]" {
+		t.Errorf("CoerceColumns modified the original rows: %v", res.Rows)
+	}
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowSorter_BytewiseVsCollated(t *testing.T) {
+	words := []string{"zebra", "éclair", "Zoo", "apple", "Äpfel", "eclair"}
+	res := QueryResult{Columns: []string{"word"}}
+	for _, w := range words {
+		res.Rows = append(res.Rows, []interface{}{[]byte(w)})
+	}
+
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		// Byte order puts capitals before lowercase and accented letters last
+		{locale: "", want: []string{"Zoo", "apple", "eclair", "zebra", "Äpfel", "éclair"}},
+		{locale: "en", want: []string{"Äpfel", "apple", "eclair", "éclair", "zebra", "Zoo"}},
+		// Swedish sorts Ä as a letter of its own, after Z
+		{locale: "sv", want: []string{"apple", "eclair", "éclair", "zebra", "Zoo", "Äpfel"}},
+	}
+	for _, tt := range tests {
+		t.Run("locale "+tt.locale, func(t *testing.T) {
+			sorter, err := NewRowSorter(tt.locale)
+			if err != nil {
+				t.Fatalf("NewRowSorter() error = %v", err)
+			}
+			sorted := sorter.Sort(res)
+			var got []string
+			for _, row := range sorted.Rows {
+				got = append(got, string(row[0].([]byte)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sorted = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if string(res.Rows[0][0].([]byte)) != "zebra" {
+		t.Error("Sort() modified the result's rows")
+	}
+}
+
+func TestRowSorter_NullsNumbersAndLaterColumns(t *testing.T) {
+	res := QueryResult{
+		Columns:     []string{"n", "name"},
+		ColumnTypes: []ColumnType{{Name: "n", DatabaseType: "INT"}, {Name: "name", DatabaseType: "VARCHAR"}},
+		Rows: [][]interface{}{
+			{[]byte("10"), []byte("b")},
+			{[]byte("9"), []byte("a")},
+			{nil, []byte("z")},
+			{[]byte("10"), []byte("a")},
+		},
+	}
+	sorter, _ := NewRowSorter("")
+	want := [][]interface{}{
+		{nil, []byte("z")},
+		{[]byte("9"), []byte("a")},
+		{[]byte("10"), []byte("a")},
+		{[]byte("10"), []byte("b")},
+	}
+	if got := sorter.Sort(res).Rows; !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted = %q, want %q", got, want)
+	}
+
+	if _, err := NewRowSorter("not a locale!"); err == nil {
+		t.Error("NewRowSorter() accepted an invalid locale")
+	}
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultValuesPerInsert is the number of rows batched into a single INSERT statement
+const DefaultValuesPerInsert = 100
+
+// PrintResultSQL renders the rows of a query result as INSERT statements for the given
+// table ("table" or "db.table"), batching valuesPerInsert rows per statement.
+// The output is meant to be fed back into csql, so the instance and statement are
+// emitted as SQL line comments.
+func PrintResultSQL(w io.Writer, res QueryResult, table string, valuesPerInsert int) error {
+	if res.Err != nil {
+		return fmt.Errorf("cannot export failed statement: %w", res.Err)
+	}
+	if valuesPerInsert <= 0 {
+		valuesPerInsert = DefaultValuesPerInsert
+	}
+
+	fmt.Fprintf(w, "-- instance: %s\n", maskPasswordInDSN(res.Instance))
+	for _, line := range strings.Split(res.MarkedStatement(), "\n") {
+		fmt.Fprintf(w, "-- %s\n", line)
+	}
+
+	if len(res.Columns) == 0 {
+		fmt.Fprintln(w, "-- statement returned no columns")
+		return nil
+	}
+	if len(res.Rows) == 0 {
+		fmt.Fprintln(w, "-- 0 rows")
+		return nil
+	}
+
+	quotedCols := make([]string, len(res.Columns))
+	for i, col := range res.Columns {
+		quotedCols[i] = QuoteIdentifier(col)
+	}
+	prefix := "INSERT INTO " + QuoteTableName(table) + " (" + strings.Join(quotedCols, ", ") + ") VALUES "
+
+	for start := 0; start < len(res.Rows); start += valuesPerInsert {
+		end := start + valuesPerInsert
+		if end > len(res.Rows) {
+			end = len(res.Rows)
+		}
+
+		var stmt strings.Builder
+		stmt.WriteString(prefix)
+		for i, row := range res.Rows[start:end] {
+			if i > 0 {
+				stmt.WriteString(",")
+			}
+			stmt.WriteString("(")
+			for j, v := range row {
+				if j > 0 {
+					stmt.WriteString(",")
+				}
+				dbType := ""
+				if j < len(res.ColumnTypes) {
+					dbType = res.ColumnTypes[j].DatabaseType
+				}
+				stmt.WriteString(FormatSQLValue(v, dbType))
+			}
+			stmt.WriteString(")")
+		}
+		stmt.WriteString(";\n")
+
+		if _, err := io.WriteString(w, stmt.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QuoteIdentifier quotes a MySQL identifier with backticks, doubling embedded backticks
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteTableName quotes a "table" or "db.table" name, quoting each part separately
+func QuoteTableName(name string) string {
+	parts := strings.SplitN(name, ".", 2)
+	for i, part := range parts {
+		parts[i] = QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// FormatSQLValue renders a scanned value as a MySQL literal. The column's database type
+// decides between numeric (unquoted), binary (hex literal) and string (escaped) forms.
+func FormatSQLValue(v interface{}, dbType string) string {
+	if v == nil {
+		return "NULL"
+	}
+
+	switch val := v.(type) {
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case int32:
+		return strconv.FormatInt(int64(val), 10)
+	case int:
+		return strconv.Itoa(val)
+	case uint64:
+		return strconv.FormatUint(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case bool:
+		if val {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return "'" + val.Format("2006-01-02 15:04:05.999999") + "'"
+	case []byte:
+		return formatSQLText(string(val), dbType)
+	case string:
+		return formatSQLText(val, dbType)
+	default:
+		return "'" + EscapeSQLString(fmt.Sprintf("%v", val)) + "'"
+	}
+}
+
+// formatSQLText renders a textual value according to the column's database type
+func formatSQLText(s string, dbType string) string {
+	switch {
+	case isBinaryType(dbType):
+		if s == "" {
+			return "''"
+		}
+		return "0x" + hex.EncodeToString([]byte(s))
+	case isNumericType(dbType) && isNumericLiteral(s):
+		return s
+	default:
+		return "'" + EscapeSQLString(s) + "'"
+	}
+}
+
+// isNumericType reports whether a driver type name denotes a numeric column
+func isNumericType(dbType string) bool {
+	switch strings.TrimPrefix(strings.ToUpper(dbType), "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return true
+	}
+	return false
+}
+
+// isBinaryType reports whether a driver type name denotes a binary column
+func isBinaryType(dbType string) bool {
+	switch strings.ToUpper(dbType) {
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		return true
+	}
+	return false
+}
+
+// isNumericLiteral reports whether s can be emitted unquoted as a numeric literal
+func isNumericLiteral(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+	// ParseFloat also accepts forms MySQL does not (Inf, NaN, hex floats, underscores)
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789+-.eE", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// EscapeSQLString escapes a string for use inside a single-quoted MySQL literal,
+// matching mysql_real_escape_string. It assumes NO_BACKSLASH_ESCAPES is not set.
+func EscapeSQLString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\x1a':
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
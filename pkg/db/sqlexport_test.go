@@ -0,0 +1,196 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEscapeSQLString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain text", input: "hello", want: "hello"},
+		{name: "empty string", input: "", want: ""},
+		{name: "single quote", input: "it's", want: `it\'s`},
+		{name: "double quote", input: `say "hi"`, want: `say \"hi\"`},
+		{name: "backslash", input: `C:\path\to`, want: `C:\\path\\to`},
+		{name: "backslash before quote", input: `\'`, want: `\\\'`},
+		{name: "trailing backslash", input: `abc\`, want: `abc\\`},
+		{name: "newline and carriage return", input: "a\r\nb", want: `a\r\nb`},
+		{name: "NUL byte", input: "a\x00b", want: `a\0b`},
+		{name: "ctrl-Z", input: "a\x1ab", want: `a\Zb`},
+		{name: "tab passes through", input: "a\tb", want: "a\tb"},
+		{name: "other control chars pass through", input: "\x01\x07\x1b", want: "\x01\x07\x1b"},
+		{name: "emoji", input: "ok 👍 done", want: "ok 👍 done"},
+		{name: "multibyte with quote", input: "日本'語", want: `日本\'語`},
+		{name: "SQL injection attempt", input: "'; DROP TABLE t; --", want: `\'; DROP TABLE t; --`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeSQLString(tt.input); got != tt.want {
+				t.Errorf("EscapeSQLString(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// unescapeSQLString reverses EscapeSQLString the way the MySQL parser reads a quoted literal
+func unescapeSQLString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '0':
+			b.WriteByte(0)
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'Z':
+			b.WriteByte('\x1a')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func TestEscapeSQLString_RoundTrip(t *testing.T) {
+	var all strings.Builder
+	for c := 0; c < 256; c++ {
+		all.WriteByte(byte(c))
+	}
+	inputs := []string{
+		all.String(),
+		`\\\'''""` + "\x00\x00",
+		"emoji 🎉 with 'quotes' and \\ backslashes\n",
+	}
+
+	for _, input := range inputs {
+		escaped := EscapeSQLString(input)
+		if got := unescapeSQLString(escaped); got != input {
+			t.Errorf("round trip of %q produced %q", input, got)
+		}
+		// An escaped literal must never contain an unescaped single quote
+		for i := 0; i < len(escaped); i++ {
+			if escaped[i] == '\\' {
+				i++
+				continue
+			}
+			if escaped[i] == '\'' {
+				t.Errorf("escaped %q contains unescaped quote at %d", escaped, i)
+			}
+		}
+	}
+}
+
+func TestFormatSQLValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		dbType string
+		want   string
+	}{
+		{name: "NULL", value: nil, dbType: "VARCHAR", want: "NULL"},
+		{name: "NULL numeric", value: nil, dbType: "INT", want: "NULL"},
+		{name: "int64", value: int64(-42), dbType: "BIGINT", want: "-42"},
+		{name: "uint64", value: uint64(18446744073709551615), dbType: "UNSIGNED BIGINT", want: "18446744073709551615"},
+		{name: "float64", value: float64(1.5), dbType: "DOUBLE", want: "1.5"},
+		{name: "numeric string", value: "12345", dbType: "INT", want: "12345"},
+		{name: "unsigned numeric string", value: "7", dbType: "UNSIGNED TINYINT", want: "7"},
+		{name: "decimal string", value: "-0.001", dbType: "DECIMAL", want: "-0.001"},
+		{name: "exponent string", value: "1e+20", dbType: "DOUBLE", want: "1e+20"},
+		{name: "non-numeric in numeric column is quoted", value: "NaN", dbType: "DOUBLE", want: "'NaN'"},
+		{name: "numeric-looking text column is quoted", value: "00123", dbType: "VARCHAR", want: "'00123'"},
+		{name: "string", value: "O'Reilly", dbType: "VARCHAR", want: `'O\'Reilly'`},
+		{name: "bytes as text", value: []byte("abc"), dbType: "TEXT", want: "'abc'"},
+		{name: "binary", value: "\x00\x01\xff", dbType: "VARBINARY", want: "0x0001ff"},
+		{name: "blob bytes", value: []byte("hi"), dbType: "BLOB", want: "0x6869"},
+		{name: "empty binary", value: "", dbType: "BINARY", want: "''"},
+		{name: "bit", value: "\x01", dbType: "BIT", want: "0x01"},
+		{name: "unknown type falls back to string", value: "x", dbType: "", want: "'x'"},
+		{name: "emoji", value: "🙂", dbType: "VARCHAR", want: "'🙂'"},
+		{name: "bool", value: true, dbType: "TINYINT", want: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatSQLValue(tt.value, tt.dbType); got != tt.want {
+				t.Errorf("FormatSQLValue(%#v, %q) = %s, want %s", tt.value, tt.dbType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteTableName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "users", want: "`users`"},
+		{input: "app.users", want: "`app`.`users`"},
+		{input: "we`ird.t", want: "`we``ird`.`t`"},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteTableName(tt.input); got != tt.want {
+			t.Errorf("QuoteTableName(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPrintResultSQL(t *testing.T) {
+	res := QueryResult{
+		Instance:  "user:secret@tcp(localhost:3306)/app",
+		Statement: "SELECT id, name, avatar FROM users",
+		Columns:   []string{"id", "name", "avatar"},
+		ColumnTypes: []ColumnType{
+			{Name: "id", DatabaseType: "BIGINT"},
+			{Name: "name", DatabaseType: "VARCHAR"},
+			{Name: "avatar", DatabaseType: "BLOB"},
+		},
+		Rows: [][]interface{}{
+			{"1", "alice", "\x89PNG"},
+			{"2", "b'ob", nil},
+			{"3", nil, ""},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintResultSQL(&buf, res, "app.users", 2); err != nil {
+		t.Fatalf("PrintResultSQL() error = %v", err)
+	}
+
+	want := "-- instance: user:****@tcp(localhost:3306)/app\n" +
+		"-- SELECT id, name, avatar FROM users\n" +
+		"INSERT INTO `app`.`users` (`id`, `name`, `avatar`) VALUES (1,'alice',0x89504e47),(2,'b\\'ob',NULL);\n" +
+		"INSERT INTO `app`.`users` (`id`, `name`, `avatar`) VALUES (3,NULL,'');\n"
+	if got := buf.String(); got != want {
+		t.Errorf("PrintResultSQL() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPrintResultSQL_NoRowsAndErrors(t *testing.T) {
+	var buf bytes.Buffer
+	res := QueryResult{Instance: "u@tcp(h:3306)/d", Statement: "SELECT 1 FROM t WHERE 0", Columns: []string{"1"}}
+	if err := PrintResultSQL(&buf, res, "t", 0); err != nil {
+		t.Fatalf("PrintResultSQL() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "INSERT") {
+		t.Errorf("PrintResultSQL() emitted INSERT for empty result: %q", buf.String())
+	}
+
+	res.Err = errors.New("boom")
+	if err := PrintResultSQL(&buf, res, "t", 0); err == nil {
+		t.Error("PrintResultSQL() expected error for failed statement")
+	}
+}
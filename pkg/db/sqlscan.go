@@ -0,0 +1,87 @@
+package db
+
+// sqlScanState is what a position in SQL text is inside of
+type sqlScanState int
+
+const (
+	scanCode         sqlScanState = iota // Statement text, where terminators count
+	scanSingleQuote                      // '...'
+	scanDoubleQuote                      // "..."
+	scanBacktick                         // `...`
+	scanLineComment                      // -- to the end of the line
+	scanBlockComment                     // /* ... */
+)
+
+// sqlScanner is the quote, escape and comment state machine shared by the
+// statement splitter and tokenizeSQL, and so by the lint checks and
+// CheckTerminated, so they always agree on where statements end. Comment
+// openings only count in statement text: a /* inside a -- comment, or a -- inside
+// a block comment, is part of that comment.
+type sqlScanner struct {
+	state sqlScanState
+}
+
+// inCode reports whether the scanner is in statement text, outside any quote or
+// comment
+func (s *sqlScanner) inCode() bool {
+	return s.state == scanCode
+}
+
+// inComment reports whether the scanner is inside a comment
+func (s *sqlScanner) inComment() bool {
+	return s.state == scanLineComment || s.state == scanBlockComment
+}
+
+// next advances the scanner over r, given the rune after it, and returns how many
+// runes go together from r: 2 for an escape inside a quote and for the -- /* and
+// */ comment delimiters, else 1. The caller consumes the second rune unseen.
+func (s *sqlScanner) next(r rune, peek func() (rune, bool)) int {
+	following, ok := peek()
+	switch s.state {
+	case scanCode:
+		switch {
+		case r == '-' && ok && following == '-':
+			s.state = scanLineComment
+			return 2
+		case r == '/' && ok && following == '*':
+			s.state = scanBlockComment
+			return 2
+		case r == '\'':
+			s.state = scanSingleQuote
+		case r == '"':
+			s.state = scanDoubleQuote
+		case r == '`':
+			s.state = scanBacktick
+		}
+	case scanLineComment:
+		if r == '\n' || r == '\r' {
+			s.state = scanCode
+		}
+	case scanBlockComment:
+		if r == '*' && ok && following == '/' {
+			s.state = scanCode
+			return 2
+		}
+	default:
+		if r == '\\' && ok {
+			return 2 // Skip the escaped character
+		}
+		if r == s.quote() {
+			s.state = scanCode
+		}
+	}
+	return 1
+}
+
+// quote returns the rune closing the quote the scanner is in
+func (s *sqlScanner) quote() rune {
+	switch s.state {
+	case scanSingleQuote:
+		return '\''
+	case scanDoubleQuote:
+		return '"'
+	case scanBacktick:
+		return '`'
+	}
+	return 0
+}
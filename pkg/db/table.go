@@ -0,0 +1,207 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
+)
+
+// How --table renders results over the table row threshold
+const (
+	LargeTableFallback = "fallback" // Print the result in the plain tab-separated format
+	LargeTableChunk    = "chunk"    // Draw the table with column widths sampled from the first rows
+)
+
+// Defaults for large --table results
+const (
+	DefaultTableRowThreshold = 10000
+	DefaultTableSampleRows   = 1000
+	DefaultAlignSampleRows   = 1000
+)
+
+// overTableThreshold reports whether a result is too large for tablewriter, which
+// buffers the whole table and measures every cell before printing anything
+func (o PrintOptions) overTableThreshold(res QueryResult) bool {
+	return o.TableRowThreshold > 0 && len(res.Rows) > o.TableRowThreshold
+}
+
+// renderLargeTable renders a result over the table row threshold the way opts.LargeTable asks
+func renderLargeTable(w io.Writer, res QueryResult, opts PrintOptions) {
+	if opts.LargeTable == LargeTableChunk {
+		renderChunkedTable(w, opts.tableHeader(res), res.Rows, opts.TableSampleRows, opts.tableStyle())
+		return
+	}
+
+	opts.output().Printf(StreamDiagnostics, "[%s] %d rows exceed the table row threshold (%d); printing without borders\n",
+		maskPasswordInDSN(res.Instance), len(res.Rows), opts.TableRowThreshold)
+	bold := opts.paint(color.Bold)
+	for _, line := range opts.tableHeader(res) {
+		fmt.Fprintln(w, bold(strings.Join(line, "\t")))
+	}
+	for _, row := range res.Rows {
+		fmt.Fprintln(w, strings.Join(rowStrings(row), "\t"))
+	}
+}
+
+// tableHeader returns the lines of a --table header: the column names and, with
+// HeaderTypes, their types below them ("" where the driver reported none)
+func (o PrintOptions) tableHeader(res QueryResult) [][]string {
+	if !o.HeaderTypes {
+		return [][]string{res.Columns}
+	}
+	types := make([]string, len(res.Columns))
+	for i := range types {
+		if i < len(res.ColumnTypes) {
+			types[i] = res.ColumnTypes[i].DatabaseType
+		}
+	}
+	return [][]string{res.Columns, types}
+}
+
+// renderChunkedTable draws a table in the given style a chunk of sampleRows rows at a
+// time, below a header of one or more lines. Column widths come from the header and
+// the first sampleRows rows only, so later cells that are wider are truncated rather
+// than re-measuring everything.
+func renderChunkedTable(w io.Writer, header [][]string, rows [][]interface{}, sampleRows int, style tableStyle) {
+	if sampleRows <= 0 {
+		sampleRows = DefaultTableSampleRows
+	}
+
+	header = style.headerRows(header)
+	widths := columnWidths(header[0], rows, sampleRows)
+	for _, line := range header[1:] {
+		for i, cell := range line {
+			widths[i] = max(widths[i], displayWidth(tableCell(cell)))
+		}
+	}
+	if style.topBottom {
+		fmt.Fprintln(w, style.border(borderTop, widths))
+	}
+	for _, line := range header {
+		fmt.Fprintln(w, style.line(line, widths))
+	}
+	fmt.Fprintln(w, style.border(borderHeader, widths))
+	for start := 0; start < len(rows); start += sampleRows {
+		chunk := rows[start:min(start+sampleRows, len(rows))]
+		var sb strings.Builder
+		for _, row := range chunk {
+			sb.WriteString(style.line(rowStrings(row), widths))
+			sb.WriteByte('\n')
+		}
+		io.WriteString(w, sb.String())
+	}
+	if style.topBottom {
+		fmt.Fprintln(w, style.border(borderBottom, widths))
+	}
+}
+
+// renderAligned prints a result in the default format with every column padded
+// to its width, like the mysql client's aligned batch output. Widths come from the
+// header and the first sampleRows rows so huge results are not measured in full;
+// later rows with wider values are printed whole, out of line, and counted in a
+// notice on the diagnostics stream.
+func renderAligned(w io.Writer, res QueryResult, opts PrintOptions) {
+	sampleRows := opts.AlignSampleRows
+	if sampleRows <= 0 {
+		sampleRows = DefaultAlignSampleRows
+	}
+	widths := columnWidths(res.Columns, res.Rows, sampleRows)
+
+	bold := opts.paint(color.Bold)
+	header, _ := alignedLine(res.Columns, widths)
+	fmt.Fprintln(w, bold(header))
+	overflowed := 0
+	for _, row := range res.Rows {
+		line, wider := alignedLine(rowStrings(row), widths)
+		if wider {
+			overflowed++
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	if overflowed > 0 {
+		opts.output().Printf(StreamDiagnostics, "[%s] %d row(s) after the first %d have values wider than their column and are not aligned\n",
+			maskPasswordInDSN(res.Instance), overflowed, sampleRows)
+	}
+}
+
+// columnWidths returns the display width of every column, measured over the
+// header and the first sampleRows rows in a single pass
+func columnWidths(columns []string, rows [][]interface{}, sampleRows int) []int {
+	widths := make([]int, len(columns))
+	for i, col := range columns {
+		widths[i] = displayWidth(tableCell(col))
+	}
+	for _, row := range rows[:min(sampleRows, len(rows))] {
+		for i, cell := range rowStrings(row) {
+			if i < len(widths) {
+				widths[i] = max(widths[i], displayWidth(tableCell(cell)))
+			}
+		}
+	}
+	return widths
+}
+
+// displayWidth returns how many terminal columns s takes up: wide characters
+// such as CJK count twice, combining marks not at all
+func displayWidth(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// alignedLine joins cells with each one but the last padded to its column width,
+// and reports whether any cell was wider than its column
+func alignedLine(cells []string, widths []int) (string, bool) {
+	var sb strings.Builder
+	wider := false
+	for i, cell := range cells {
+		cell = tableCell(cell)
+		if i > 0 {
+			sb.WriteString("  ")
+		}
+		sb.WriteString(cell)
+		if i >= len(widths) {
+			continue
+		}
+		if pad := widths[i] - displayWidth(cell); pad < 0 {
+			wider = true
+		} else if i < len(cells)-1 {
+			sb.WriteString(strings.Repeat(" ", pad))
+		}
+	}
+	return sb.String(), wider
+}
+
+// rowStrings formats a row's values for display, with NULL for nil
+func rowStrings(row []interface{}) []string {
+	out := make([]string, len(row))
+	for i, v := range row {
+		if b, ok := v.([]byte); ok {
+			out[i] = string(b)
+		} else if v == nil {
+			out[i] = "NULL"
+		} else {
+			out[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	return out
+}
+
+// tableCell flattens line breaks and tabs, which would break a fixed-width row
+func tableCell(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(s)
+}
+
+// fitCell truncates s to width display columns, marking the cut with an ellipsis
+// so a shortened value (e.g. a number) is never mistaken for the real one
+func fitCell(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	return runewidth.Truncate(s, width, "…")
+}
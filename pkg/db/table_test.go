@@ -0,0 +1,292 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// columnBoundaries returns the rune offsets of the '|' or '+' separators in a table line
+func columnBoundaries(line string) []int {
+	var offsets []int
+	for i, r := range []rune(line) {
+		if r == '|' || r == '+' {
+			offsets = append(offsets, i)
+		}
+	}
+	return offsets
+}
+
+func TestRenderChunkedTable_AlignedAcrossChunks(t *testing.T) {
+	rows := [][]interface{}{
+		{int64(1), "alpha", nil},
+		{int64(2), "beta", []byte("x")},
+		// Rows past the sample: wider values, multi-byte text and line breaks
+		{int64(3), "a much longer value than sampled", "y"},
+		{int64(40000), "héllo", "z"},
+		{int64(5), "line\nbreak", "tab\there"},
+	}
+
+	var buf bytes.Buffer
+	renderChunkedTable(&buf, [][]string{{"id", "name", "extra"}}, rows, 2, tableStyles[TableStyleMySQL])
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	// Border, header, border, 5 rows, border
+	if len(lines) != 9 {
+		t.Fatalf("got %d lines, want 9:\n%s", len(lines), buf.String())
+	}
+	want := columnBoundaries(lines[0])
+	for i, line := range lines {
+		if got := columnBoundaries(line); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("line %d %q: separators at %v, want %v", i, line, got, want)
+		}
+		if utf8.RuneCountInString(line) != utf8.RuneCountInString(lines[0]) {
+			t.Errorf("line %d %q has a different width than the border", i, line)
+		}
+	}
+
+	wantLines := map[int]string{
+		0: "+----+-------+-------+",
+		1: "| id | name  | extra |",
+		3: "| 1  | alpha | NULL  |",
+		5: "| 3  | a mu… | y     |",
+		6: "| 4… | héllo | z     |",
+		7: "| 5  | line… | tab … |",
+	}
+	for i, want := range wantLines {
+		if lines[i] != want {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestFitCell(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 6, "abcdef"},
+		{"abcdefg", 6, "abcde…"},
+		{"40000", 2, "4…"},
+		{"abc", 0, ""},
+		{"héllo wörld", 8, "héllo w…"},
+	}
+	for _, tt := range tests {
+		if got := fitCell(tt.in, tt.width); got != tt.want {
+			t.Errorf("fitCell(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRenderResult_LargeTable(t *testing.T) {
+	color.NoColor = true
+	res := QueryResult{
+		Instance:  "u:secret@tcp(h:3306)/d",
+		Statement: "SELECT n",
+		Columns:   []string{"n"},
+		Rows:      [][]interface{}{{1}, {2}, {3}},
+		RowCount:  3,
+	}
+
+	tests := []struct {
+		name       string
+		opts       PrintOptions
+		wantOut    []string
+		wantNotice bool
+	}{
+		{
+			name:    "under threshold uses the table renderer",
+			opts:    PrintOptions{TableFormat: true, TableRowThreshold: 3},
+			wantOut: []string{"+---+", "| N |", "| 1 |"},
+		},
+		{
+			name:       "over threshold falls back to plain output",
+			opts:       PrintOptions{TableFormat: true, TableRowThreshold: 2},
+			wantOut:    []string{"\nn\n1\n2\n3\n"},
+			wantNotice: true,
+		},
+		{
+			name:    "over threshold in chunk mode keeps the table",
+			opts:    PrintOptions{TableFormat: true, TableRowThreshold: 2, LargeTable: LargeTableChunk, TableSampleRows: 1},
+			wantOut: []string{"+---+\n| n |\n+---+\n| 1 |\n| 2 |\n| 3 |\n+---+\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			tt.opts.Output = NewOutputSink(&stdout, &stderr)
+
+			var out bytes.Buffer
+			RenderResult(&out, res, color.New(color.FgCyan), tt.opts)
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			gotNotice := strings.Contains(stderr.String(), "exceed the table row threshold")
+			if gotNotice != tt.wantNotice {
+				t.Errorf("notice = %q, want notice %v", stderr.String(), tt.wantNotice)
+			}
+			if strings.Contains(stderr.String(), "secret") {
+				t.Errorf("notice leaks the password: %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestColumnWidths(t *testing.T) {
+	rows := [][]interface{}{
+		{int64(1), "日本語", nil},
+		{int64(22), "été", []byte("xy")},
+		{int64(333333), "past the sample", "ignored"},
+	}
+	got := columnWidths([]string{"id", "name", "extra"}, rows, 2)
+	// 日本語 takes two columns per character; the combining accent takes none
+	if want := []int{2, 6, 5}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("columnWidths() = %v, want %v", got, want)
+	}
+}
+
+func TestRenderResult_Align(t *testing.T) {
+	color.NoColor = true
+	res := QueryResult{
+		Instance:  "u:secret@tcp(h:3306)/d",
+		Statement: "SELECT id, name, note",
+		Columns:   []string{"id", "name", "note"},
+		Rows: [][]interface{}{
+			{int64(1), "日本", nil},
+			{int64(20), "bob", "multi\nline"},
+			{int64(300000), "a name wider than sampled", "x"},
+		},
+		RowCount: 3,
+	}
+
+	tests := []struct {
+		name       string
+		sample     int
+		wantLines  []string
+		wantNotice bool
+	}{
+		{
+			name:   "all rows sampled",
+			sample: 0,
+			wantLines: []string{
+				"id      name                       note",
+				"1       日本                       NULL",
+				"20      bob                        multi line",
+				"300000  a name wider than sampled  x",
+			},
+		},
+		{
+			name:   "wider values past the sample",
+			sample: 2,
+			wantLines: []string{
+				"id  name  note",
+				"1   日本  NULL",
+				"20  bob   multi line",
+				"300000  a name wider than sampled  x",
+			},
+			wantNotice: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, stdout, stderr bytes.Buffer
+			opts := PrintOptions{Align: true, AlignSampleRows: tt.sample, Output: NewOutputSink(&stdout, &stderr)}
+			RenderResult(&out, res, nil, opts)
+
+			lines := strings.Split(out.String(), "\n")[1:] // Skip the statement line
+			for i, want := range tt.wantLines {
+				if i >= len(lines) || lines[i] != want {
+					t.Errorf("output:\n%s\nline %d: want %q", out.String(), i, want)
+				}
+			}
+			gotNotice := strings.Contains(stderr.String(), "1 row(s) after the first 2 have values wider")
+			if gotNotice != tt.wantNotice {
+				t.Errorf("notice = %q, want notice %v", stderr.String(), tt.wantNotice)
+			}
+			if strings.Contains(stderr.String(), "secret") {
+				t.Errorf("notice leaks the password: %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestRenderResult_HeaderTypes(t *testing.T) {
+	res := QueryResult{
+		Instance:    "db1",
+		Columns:     []string{"id", "name", "computed"},
+		ColumnTypes: []ColumnType{{Name: "id", DatabaseType: "BIGINT"}, {Name: "name", DatabaseType: "VARCHAR"}},
+		Rows:        [][]interface{}{{int64(1), "a", nil}, {int64(2), "b", "x"}},
+	}
+	tests := []struct {
+		style   string
+		table   []string // Drawn by tablewriter
+		chunked []string // Drawn a chunk at a time, over the table row threshold
+	}{
+		{
+			style: TableStyleMySQL,
+			table: []string{
+				"+--------+---------+----------+",
+				"| ID     | NAME    | COMPUTED |",
+				"| BIGINT | VARCHAR |          |",
+				"+--------+---------+----------+",
+				"| 1      | a       | NULL     |",
+				"| 2      | b       | x        |",
+				"+--------+---------+----------+",
+			},
+			chunked: []string{
+				"+--------+---------+----------+",
+				"| id     | name    | computed |",
+				"| BIGINT | VARCHAR |          |",
+				"+--------+---------+----------+",
+				"| 1      | a       | NULL     |",
+				"| 2      | b       | x        |",
+				"+--------+---------+----------+",
+			},
+		},
+		{
+			// Markdown has a single header row, so name and type share it
+			style: TableStyleMarkdown,
+			table: []string{
+				"| ID<BR>BIGINT | NAME<BR>VARCHAR | COMPUTED |", // tablewriter upper-cases headers
+				"|--------------|-----------------|----------|",
+				"| 1            | a               | NULL     |",
+				"| 2            | b               | x        |",
+			},
+			chunked: []string{
+				"| id<br>BIGINT | name<br>VARCHAR | computed |",
+				"|--------------|-----------------|----------|",
+				"| 1            | a               | NULL     |",
+				"| 2            | b               | x        |",
+			},
+		},
+	}
+
+	render := func(opts PrintOptions) []string {
+		var buf bytes.Buffer
+		RenderResult(&buf, res, nil, opts)
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		return lines[1:] // After the instance header
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			opts := PrintOptions{TableFormat: true, TableStyle: tt.style, HeaderTypes: true, Plain: true}
+			if got := render(opts); strings.Join(got, "\n") != strings.Join(tt.table, "\n") {
+				t.Errorf("table =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.table, "\n"))
+			}
+			opts.TableRowThreshold, opts.LargeTable = 1, LargeTableChunk
+			if got := render(opts); strings.Join(got, "\n") != strings.Join(tt.chunked, "\n") {
+				t.Errorf("chunked table =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.chunked, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// --table border styles
+const (
+	TableStyleMySQL    = "mysql"    // +---+ borders, like the mysql client (the default)
+	TableStyleMarkdown = "markdown" // A GitHub-flavored Markdown table
+	TableStyleBox      = "box"      // Unicode box-drawing characters
+	TableStyleASCII    = "ascii"    // Column separators and a header line, without outer borders
+)
+
+// Border rows of a table, indexing tableStyle.junctions
+const (
+	borderTop = iota
+	borderHeader
+	borderBottom
+)
+
+// tableStyle is the set of characters a --table style draws with
+type tableStyle struct {
+	horizontal string       // Fills border lines
+	vertical   string       // Separates columns
+	junctions  [3][3]string // Left, inner and right junction of the top, header and bottom borders
+	edges      bool         // Draw the left and right edges
+	topBottom  bool         // Draw borders above the header and below the last row
+	escape     bool         // Escape the vertical separator inside cells (Markdown)
+	lineBreak  string       // Joins a multi-line header into one row, for styles without multi-line rows
+}
+
+// tableStyles are the presets selectable with TableStyle
+var tableStyles = map[string]tableStyle{
+	TableStyleMySQL:    {horizontal: "-", vertical: "|", junctions: uniformJunctions("+"), edges: true, topBottom: true},
+	TableStyleMarkdown: {horizontal: "-", vertical: "|", junctions: uniformJunctions("|"), edges: true, escape: true, lineBreak: "<br>"},
+	TableStyleASCII:    {horizontal: "-", vertical: "|", junctions: uniformJunctions("+")},
+	TableStyleBox: {
+		horizontal: "─",
+		vertical:   "│",
+		junctions:  [3][3]string{{"┌", "┬", "┐"}, {"├", "┼", "┤"}, {"└", "┴", "┘"}},
+		edges:      true,
+		topBottom:  true,
+	},
+}
+
+// TableStyles lists the --table styles, default first
+func TableStyles() []string {
+	return []string{TableStyleMySQL, TableStyleMarkdown, TableStyleBox, TableStyleASCII}
+}
+
+// ValidTableStyle reports whether name is a --table style; empty means the default
+func ValidTableStyle(name string) bool {
+	_, ok := tableStyles[name]
+	return ok || name == ""
+}
+
+// uniformJunctions uses one junction character everywhere
+func uniformJunctions(j string) [3][3]string {
+	row := [3]string{j, j, j}
+	return [3][3]string{row, row, row}
+}
+
+// tableStyle returns the style opts select, the mysql preset by default
+func (o PrintOptions) tableStyle() tableStyle {
+	if style, ok := tableStyles[o.TableStyle]; ok {
+		return style
+	}
+	return tableStyles[TableStyleMySQL]
+}
+
+// configure applies the style to a tablewriter table. tablewriter draws every
+// junction with the same character; fixJunctions corrects the rest afterwards.
+func (s tableStyle) configure(table *tablewriter.Table) {
+	table.SetBorders(tablewriter.Border{Left: s.edges, Right: s.edges, Top: s.topBottom, Bottom: s.topBottom})
+	table.SetCenterSeparator(s.junctions[borderHeader][1])
+	table.SetColumnSeparator(s.vertical)
+	table.SetRowSeparator(s.horizontal)
+}
+
+// cell prepares a value for a table cell of this style
+func (s tableStyle) cell(v string) string {
+	if s.escape {
+		return strings.ReplaceAll(v, s.vertical, `\`+s.vertical)
+	}
+	return v
+}
+
+// headerRows returns the rows a header of one or more lines is drawn as: as is, or
+// joined into a single row for styles with a lineBreak (Markdown has one header row)
+func (s tableStyle) headerRows(header [][]string) [][]string {
+	if s.lineBreak == "" || len(header) < 2 {
+		return header
+	}
+	joined := make([]string, len(header[0]))
+	for i := range joined {
+		var cell []string
+		for _, line := range header {
+			if line[i] != "" {
+				cell = append(cell, line[i])
+			}
+		}
+		joined[i] = strings.Join(cell, s.lineBreak)
+	}
+	return [][]string{joined}
+}
+
+// fixJunctions rewrites the corners and edge junctions of the border lines in a
+// table rendered by tablewriter, for styles whose junctions differ by position
+func (s tableStyle) fixJunctions(rendered string) string {
+	if s.junctions == uniformJunctions(s.junctions[borderHeader][1]) {
+		return rendered
+	}
+	center := s.junctions[borderHeader][1]
+	lines := strings.Split(rendered, "\n")
+	var borders []int // Indexes of the border lines
+	for i, line := range lines {
+		if strings.HasPrefix(line, center) {
+			borders = append(borders, i)
+		}
+	}
+	for n, i := range borders {
+		row := borderHeader
+		switch {
+		case n == 0 && s.topBottom:
+			row = borderTop
+		case n == len(borders)-1 && s.topBottom:
+			row = borderBottom
+		}
+		j := s.junctions[row]
+		line := strings.TrimRight(lines[i], " ")
+		inner := strings.TrimSuffix(strings.TrimPrefix(line, center), center)
+		lines[i] = j[0] + strings.ReplaceAll(inner, center, j[1]) + j[2]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// border returns a border line of the style for the given column widths
+func (s tableStyle) border(row int, widths []int) string {
+	segments := make([]string, len(widths))
+	for i, width := range widths {
+		pad := 2
+		if !s.edges && (i == 0 || i == len(widths)-1) {
+			pad = 1 // No space is needed toward a missing edge
+		}
+		segments[i] = strings.Repeat(s.horizontal, width+pad)
+	}
+	j := s.junctions[row]
+	line := strings.Join(segments, j[1])
+	if s.edges {
+		line = j[0] + line + j[2]
+	}
+	return line
+}
+
+// line returns a row of cells of the style, padding or truncating each cell to
+// its width
+func (s tableStyle) line(cells []string, widths []int) string {
+	var sb strings.Builder
+	if s.edges {
+		sb.WriteString(s.vertical + " ")
+	}
+	for i, width := range widths {
+		cell := ""
+		if i < len(cells) {
+			cell = fitCell(tableCell(s.cell(cells[i])), width)
+		}
+		if i > 0 {
+			sb.WriteString(" " + s.vertical + " ")
+		}
+		sb.WriteString(cell)
+		if s.edges || i < len(widths)-1 {
+			sb.WriteString(strings.Repeat(" ", width-displayWidth(cell)))
+		}
+	}
+	if s.edges {
+		sb.WriteString(" " + s.vertical)
+	}
+	return sb.String()
+}
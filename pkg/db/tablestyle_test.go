@@ -0,0 +1,67 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderResult_TableStyles(t *testing.T) {
+	res := QueryResult{Instance: "db1", Columns: []string{"id", "name"}, Rows: [][]interface{}{{int64(1), "a|b"}, {int64(22), "héllo"}}}
+	tests := []struct {
+		style   string
+		table   []string // Drawn by tablewriter
+		chunked []string // Drawn a chunk at a time, over the table row threshold
+	}{
+		{
+			style:   TableStyleMySQL,
+			table:   []string{"+----+-------+", "| ID | NAME  |", "+----+-------+", "| 1  | a|b   |", "| 22 | héllo |", "+----+-------+"},
+			chunked: []string{"+----+-------+", "| id | name  |", "+----+-------+", "| 1  | a|b   |", "| 22 | héllo |", "+----+-------+"},
+		},
+		{
+			style:   TableStyleMarkdown,
+			table:   []string{"| ID | NAME  |", "|----|-------|", `| 1  | a\|b  |`, "| 22 | héllo |"},
+			chunked: []string{"| id | name  |", "|----|-------|", `| 1  | a\|b  |`, "| 22 | héllo |"},
+		},
+		{
+			style:   TableStyleBox,
+			table:   []string{"┌────┬───────┐", "│ ID │ NAME  │", "├────┼───────┤", "│ 1  │ a|b   │", "│ 22 │ héllo │", "└────┴───────┘"},
+			chunked: []string{"┌────┬───────┐", "│ id │ name  │", "├────┼───────┤", "│ 1  │ a|b   │", "│ 22 │ héllo │", "└────┴───────┘"},
+		},
+		{
+			style:   TableStyleASCII,
+			table:   []string{"  ID | NAME   ", "-----+--------", "  1  | a|b    ", "  22 | héllo  "},
+			chunked: []string{"id | name", "---+------", "1  | a|b", "22 | héllo"},
+		},
+	}
+
+	render := func(opts PrintOptions) []string {
+		var buf bytes.Buffer
+		RenderResult(&buf, res, nil, opts)
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		return lines[1:] // After the instance header
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			opts := PrintOptions{TableFormat: true, TableStyle: tt.style, Plain: true}
+			if got := render(opts); strings.Join(got, "\n") != strings.Join(tt.table, "\n") {
+				t.Errorf("table =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.table, "\n"))
+			}
+			opts.TableRowThreshold, opts.LargeTable = 1, LargeTableChunk
+			if got := render(opts); strings.Join(got, "\n") != strings.Join(tt.chunked, "\n") {
+				t.Errorf("chunked table =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.chunked, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidTableStyle(t *testing.T) {
+	for _, style := range append(TableStyles(), "") {
+		if !ValidTableStyle(style) {
+			t.Errorf("ValidTableStyle(%q) = false", style)
+		}
+	}
+	if ValidTableStyle("fancy") {
+		t.Error(`ValidTableStyle("fancy") = true`)
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Statement terminator modes
+const (
+	TerminatorSemicolon = "semicolon" // ; \g and \G, outside strings and comments (the default)
+	TerminatorLine      = "line"      // A line consisting solely of a token, e.g. GO
+	TerminatorNull      = "null"      // A 0x00 byte, as written by find -print0 style generators
+)
+
+// Terminator selects how a script is split into statements. The zero value splits
+// on semicolons.
+type Terminator struct {
+	Mode  string // TerminatorSemicolon (or empty), TerminatorLine or TerminatorNull
+	Token string // With TerminatorLine, the separator line, matched ignoring case and surrounding spaces
+}
+
+// ParseTerminator parses a --terminator value: semicolon, line:<token> or null
+func ParseTerminator(spec string) (Terminator, error) {
+	switch {
+	case spec == "" || spec == TerminatorSemicolon:
+		return Terminator{Mode: TerminatorSemicolon}, nil
+	case spec == TerminatorNull:
+		return Terminator{Mode: TerminatorNull}, nil
+	case strings.HasPrefix(spec, TerminatorLine+":"):
+		token := strings.TrimSpace(strings.TrimPrefix(spec, TerminatorLine+":"))
+		if token == "" {
+			return Terminator{}, fmt.Errorf("invalid terminator %q: line: needs a token, e.g. line:GO", spec)
+		}
+		return Terminator{Mode: TerminatorLine, Token: token}, nil
+	}
+	return Terminator{}, fmt.Errorf("invalid terminator %q: must be semicolon, line:<token> or null", spec)
+}
+
+// Semicolon reports whether t splits on semicolons, with quote and comment handling
+func (t Terminator) Semicolon() bool {
+	return t.Mode == "" || t.Mode == TerminatorSemicolon
+}
+
+// Separator returns text that ends a statement in t's mode. It goes on its own
+// line, so a trailing -- comment cannot swallow it.
+func (t Terminator) Separator() string {
+	switch t.Mode {
+	case TerminatorNull:
+		return "\x00"
+	case TerminatorLine:
+		return "\n" + t.Token + "\n"
+	default:
+		return "\n;\n"
+	}
+}
+
+// Split splits sqls into statements. The line and null modes take everything
+// between terminators as one statement, semicolons, quotes and comments included,
+// since their terminator cannot be mistaken for statement text; a statement ending
+// in \G still asks for vertical output, and empty segments are dropped.
+func (t Terminator) Split(sqls string) []StatementInfo {
+	// A segment of the script between terminators, and the line it starts on
+	type segment struct {
+		text string
+		line int
+	}
+	var segments []segment
+	switch t.Mode {
+	case TerminatorNull:
+		line := 1
+		for _, text := range strings.Split(sqls, "\x00") {
+			segments = append(segments, segment{text, line})
+			line += strings.Count(text, "\n")
+		}
+	case TerminatorLine:
+		var current strings.Builder
+		start := 1
+		for i, line := range strings.SplitAfter(sqls, "\n") {
+			if strings.EqualFold(strings.TrimSpace(line), t.Token) {
+				segments = append(segments, segment{current.String(), start})
+				current.Reset()
+				start = i + 2
+				continue
+			}
+			current.WriteString(line)
+		}
+		segments = append(segments, segment{current.String(), start})
+	default:
+		return splitSQLStatements(sqls)
+	}
+
+	var statements []StatementInfo
+	for _, seg := range segments {
+		stmt := strings.TrimSpace(seg.text)
+		vertical := strings.HasSuffix(stmt, `\G`)
+		if vertical || strings.HasSuffix(stmt, `\g`) {
+			stmt = stmt[:len(stmt)-2]
+		}
+		leading := seg.text[:len(seg.text)-len(strings.TrimLeftFunc(seg.text, unicode.IsSpace))]
+		statements = appendStatement(statements, stmt, vertical, seg.line+strings.Count(leading, "\n"))
+	}
+	return statements
+}
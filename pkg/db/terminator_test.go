@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestParseTerminator(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Terminator
+		wantErr string
+	}{
+		{spec: "", want: Terminator{Mode: TerminatorSemicolon}},
+		{spec: "semicolon", want: Terminator{Mode: TerminatorSemicolon}},
+		{spec: "null", want: Terminator{Mode: TerminatorNull}},
+		{spec: "line:GO", want: Terminator{Mode: TerminatorLine, Token: "GO"}},
+		{spec: "line: / ", want: Terminator{Mode: TerminatorLine, Token: "/"}},
+		{spec: "line:", wantErr: "needs a token"},
+		{spec: "GO", wantErr: "must be semicolon, line:<token> or null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseTerminator(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseTerminator(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseTerminator(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerminator_Split(t *testing.T) {
+	goLine := Terminator{Mode: TerminatorLine, Token: "GO"}
+	null := Terminator{Mode: TerminatorNull}
+	tests := []struct {
+		name  string
+		term  Terminator
+		input string
+		want  []StatementInfo
+	}{
+		{
+			name:  "semicolons are literal between lines",
+			term:  goLine,
+			input: "CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END\nGO\nSELECT 3\nGO\n",
+			want:  []StatementInfo{{SQL: "CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", Line: 1}, {SQL: "SELECT 3", Line: 3}},
+		},
+		{
+			name:  "token inside statement text",
+			term:  goLine,
+			input: "SELECT 'GO' AS go\nFROM t -- GO\nGO\nSELECT 1 GO\nGOTO\nUPDATE t SET s = 'x\nGO'\nGO\nSELECT '\nGO\n'",
+			want: []StatementInfo{
+				{SQL: "SELECT 'GO' AS go\nFROM t -- GO", Line: 1},
+				{SQL: "SELECT 1 GO\nGOTO\nUPDATE t SET s = 'x\nGO'", Line: 4},
+				// Quotes are not tracked: a token line always separates
+				{SQL: "SELECT '", Line: 9},
+				{SQL: "'", Line: 11},
+			},
+		},
+		{
+			name:  "token matched ignoring case, spaces and CRLF",
+			term:  goLine,
+			input: "SELECT 1\r\n  go  \r\nSELECT 2",
+			want:  []StatementInfo{{SQL: "SELECT 1", Line: 1}, {SQL: "SELECT 2", Line: 3}},
+		},
+		{
+			name:  "empty segments are dropped",
+			term:  goLine,
+			input: "GO\n\nGO\nSELECT 1\nGO\n   \nGO\nGO",
+			want:  []StatementInfo{{SQL: "SELECT 1", Line: 4}},
+		},
+		{
+			name:  "vertical output per statement",
+			term:  goLine,
+			input: "SHOW REPLICA STATUS\\G\nGO\nSELECT 1\\g\nGO",
+			want:  []StatementInfo{{SQL: "SHOW REPLICA STATUS", Vertical: true, Line: 1}, {SQL: "SELECT 1", Line: 3}},
+		},
+		{
+			name:  "null separated",
+			term:  null,
+			input: "SELECT 'a;b'; SELECT 2\x00INSERT INTO t VALUES (')\\G\x00",
+			want:  []StatementInfo{{SQL: "SELECT 'a;b'; SELECT 2", Line: 1}, {SQL: "INSERT INTO t VALUES (')", Vertical: true, Line: 1}},
+		},
+		{
+			name:  "null separated with empty segments",
+			term:  null,
+			input: "\x00\x00SELECT 1\x00 \n\x00",
+			want:  []StatementInfo{{SQL: "SELECT 1", Line: 1}},
+		},
+		{
+			name:  "semicolon is the default",
+			term:  Terminator{},
+			input: "SELECT 'x;y'; SELECT 2\\G",
+			want:  []StatementInfo{{SQL: "SELECT 'x;y'", Line: 1}, {SQL: "SELECT 2", Vertical: true, Line: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.term.Split(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerminator_Separator(t *testing.T) {
+	statements := []string{"SELECT 1 -- one", "SELECT 2"}
+	for _, term := range []Terminator{{}, {Mode: TerminatorLine, Token: "GO"}, {Mode: TerminatorNull}} {
+		var script string
+		for _, stmt := range statements {
+			script += stmt + term.Separator()
+		}
+		var got []string
+		for _, stmt := range term.Split(script) {
+			got = append(got, stmt.SQL)
+		}
+		if !reflect.DeepEqual(got, statements) {
+			t.Errorf("%+v: Split of joined statements = %q, want %q", term, got, statements)
+		}
+	}
+}
+
+func TestRunSQLOnInstanceWithOptions_Terminator(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "terminator")
+
+	script := "CREATE TRIGGER trg BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; END\nGO\nSELECT 1\nGO\n"
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), script, ExecOptions{Terminator: Terminator{Mode: TerminatorLine, Token: "GO"}})
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	want := []string{"CREATE TRIGGER trg BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; END", "SELECT 1"}
+	if got := srv.Executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+}
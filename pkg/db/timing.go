@@ -0,0 +1,47 @@
+package db
+
+import "time"
+
+// InstanceTiming breaks an instance's wall time down into where it went, to tell
+// whether a slow run was waiting on the server or on csql itself
+type InstanceTiming struct {
+	Wall       time.Duration // From the start of the run until the instance's last result was handled
+	Handshake  time.Duration // Opening and verifying the connection
+	QueryTime  time.Duration // Sum of statement Durations: waiting on the server
+	Processing time.Duration // Client side: reading and scanning rows, plus what the caller adds (e.g. printing)
+	Idle       time.Duration // The remaining wall time: waiting for a turn, a barrier or other instances
+}
+
+// TimingOf sums the time recorded on an instance's results and attributes what
+// they don't cover of wall to Idle
+func TimingOf(results []QueryResult, wall time.Duration) InstanceTiming {
+	var t InstanceTiming
+	for _, res := range results {
+		t.Add(res)
+	}
+	t.Finish(wall)
+	return t
+}
+
+// Add accumulates the handshake, query and processing time recorded on a result
+func (t *InstanceTiming) Add(res QueryResult) {
+	t.Handshake += res.Handshake
+	t.QueryTime += res.Duration
+	t.Processing += res.Processing
+}
+
+// Finish sets the wall time and attributes whatever the other phases don't cover
+// to Idle. Call it after all processing time was added.
+func (t *InstanceTiming) Finish(wall time.Duration) {
+	t.Wall = wall
+	t.Idle = max(0, wall-t.Handshake-t.QueryTime-t.Processing)
+}
+
+// Sum adds up the timings of several instances, e.g. for a run total
+func (t *InstanceTiming) Sum(other InstanceTiming) {
+	t.Wall += other.Wall
+	t.Handshake += other.Handshake
+	t.QueryTime += other.QueryTime
+	t.Processing += other.Processing
+	t.Idle += other.Idle
+}
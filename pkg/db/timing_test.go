@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-csql/pkg/db/dbtest"
+)
+
+func TestTimingOf(t *testing.T) {
+	ms := time.Millisecond
+	results := []QueryResult{
+		{Handshake: 10 * ms, Duration: 20 * ms, Processing: 5 * ms},
+		{Duration: 30 * ms, Processing: 5 * ms},
+	}
+
+	tests := []struct {
+		name string
+		wall time.Duration
+		want InstanceTiming
+	}{
+		{
+			name: "idle remainder",
+			wall: 100 * ms,
+			want: InstanceTiming{Wall: 100 * ms, Handshake: 10 * ms, QueryTime: 50 * ms, Processing: 10 * ms, Idle: 30 * ms},
+		},
+		{
+			// Clock granularity can make the phases add up to slightly more than the wall time
+			name: "never negative idle",
+			wall: 60 * ms,
+			want: InstanceTiming{Wall: 60 * ms, Handshake: 10 * ms, QueryTime: 50 * ms, Processing: 10 * ms},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TimingOf(results, tt.wall); got != tt.want {
+				t.Errorf("TimingOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunSQLOnInstance_RecordsProcessingTime(t *testing.T) {
+	useFakeDriver(t)
+	srv := dbtest.NewServer(t, "timing")
+	srv.Handle("SELECT n FROM t", dbtest.Response{Columns: []string{"n"}, Rows: dbtest.IntRows(100)})
+
+	start := time.Now()
+	results := RunSQLOnInstanceWithOptions(context.Background(), srv.DSN(), "SELECT n FROM t", ExecOptions{})
+	timing := TimingOf(results, time.Since(start))
+
+	if results[0].Err != nil {
+		t.Fatalf("RunSQLOnInstanceWithOptions() error = %v", results[0].Err)
+	}
+	if timing.Processing <= 0 || timing.Handshake <= 0 {
+		t.Errorf("timing = %+v, want handshake and processing time recorded", timing)
+	}
+	if sum := timing.Handshake + timing.QueryTime + timing.Processing + timing.Idle; sum != timing.Wall {
+		t.Errorf("phases add up to %v, want the wall time %v", sum, timing.Wall)
+	}
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithTLS(t *testing.T) {
+	const dsn = "app:pw@tcp(db1:3306)/shop?tls=true&parseTime=true"
+	tests := []struct {
+		mode TLSMode
+		want string
+	}{
+		{mode: "", want: dsn},
+		{mode: TLSDisable, want: "app:pw@tcp(db1:3306)/shop?parseTime=true&tls=false"},
+		{mode: TLSPreferred, want: "app:pw@tcp(db1:3306)/shop?parseTime=true&tls=csql&allowFallbackToPlaintext=true"},
+		{mode: TLSRequire, want: "app:pw@tcp(db1:3306)/shop?parseTime=true&tls=csql"},
+		{mode: TLSVerifyCA, want: "app:pw@tcp(db1:3306)/shop?parseTime=true&tls=csql"},
+		{mode: TLSVerifyIdentity, want: "app:pw@tcp(db1:3306)/shop?parseTime=true&tls=csql"},
+	}
+	for _, tt := range tests {
+		if got := withTLS(dsn, tt.mode); got != tt.want {
+			t.Errorf("withTLS(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+	if got, want := withTLS("app@tcp(db1:3306)/", TLSRequire), "app@tcp(db1:3306)/?tls=csql"; got != want {
+		t.Errorf("withTLS() without parameters = %q, want %q", got, want)
+	}
+
+	if _, err := ParseTLSMode("verify_full"); err == nil {
+		t.Error("ParseTLSMode() accepted an unknown mode")
+	}
+}
+
+// testCert returns a certificate for host signed by a new CA, and the CA's PEM
+func testCert(t *testing.T, host string) (tls.Certificate, []byte) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{leafDER}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+}
+
+// handshake connects a client with cfg to a server presenting cert, setting
+// ServerName to host as the driver does for verifying configs
+func handshake(cfg *tls.Config, cert tls.Certificate, host string) error {
+	cfg = cfg.Clone()
+	if !cfg.InsecureSkipVerify {
+		cfg.ServerName = host
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	go func() {
+		serverConn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer serverConn.Close()
+		tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	}()
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		return err
+	}
+	defer clientConn.Close()
+	return tls.Client(clientConn, cfg).Handshake()
+}
+
+func TestNewTLSConfig_Verification(t *testing.T) {
+	cert, caPEM := testCert(t, "db1.example.com")
+	_, otherCAPEM := testCert(t, "db1.example.com")
+	dir := t.TempDir()
+	ca, otherCA := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "other-ca.pem")
+	if err := os.WriteFile(ca, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(otherCA, otherCAPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   TLSOptions
+		host   string
+		wantOK bool
+	}{
+		{name: "require trusts any certificate", opts: TLSOptions{Mode: TLSRequire, CA: otherCA}, host: "db1.example.com", wantOK: true},
+		{name: "verify-ca", opts: TLSOptions{Mode: TLSVerifyCA, CA: ca}, host: "db1.example.com", wantOK: true},
+		{name: "verify-ca ignores the host", opts: TLSOptions{Mode: TLSVerifyCA, CA: ca}, host: "10.0.0.5", wantOK: true},
+		{name: "verify-ca with an untrusted CA", opts: TLSOptions{Mode: TLSVerifyCA, CA: otherCA}, host: "db1.example.com"},
+		{name: "verify-identity", opts: TLSOptions{Mode: TLSVerifyIdentity, CA: ca}, host: "db1.example.com", wantOK: true},
+		{name: "verify-identity checks the host", opts: TLSOptions{Mode: TLSVerifyIdentity, CA: ca}, host: "10.0.0.5"},
+		{name: "verify-identity with an untrusted CA", opts: TLSOptions{Mode: TLSVerifyIdentity, CA: otherCA}, host: "db1.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := newTLSConfig(tt.opts)
+			if err != nil {
+				t.Fatalf("newTLSConfig() error = %v", err)
+			}
+			if err := handshake(cfg, cert, tt.host); (err == nil) != tt.wantOK {
+				t.Errorf("handshake error = %v, want success %t", err, tt.wantOK)
+			}
+		})
+	}
+
+	if _, err := newTLSConfig(TLSOptions{Mode: TLSVerifyCA, CA: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("newTLSConfig() accepted a missing CA file")
+	}
+}
@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshHandshakeTimeout bounds connecting and authenticating to a bastion
+const sshHandshakeTimeout = 15 * time.Second
+
+// defaultSSHKeys are the private keys in ~/.ssh tried when no key file is given
+var defaultSSHKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshClient is the part of *ssh.Client a tunnel needs; tests substitute a fake
+type sshClient interface {
+	Dial(network, addr string) (net.Conn, error)
+	Close() error
+}
+
+// bastionDialer opens an SSH connection to a bastion given as user@host:port
+type bastionDialer func(ctx context.Context, bastion string) (sshClient, error)
+
+// TunnelConfig describes how instances are reached through SSH bastions
+type TunnelConfig struct {
+	Bastion    string            // Bastion of every instance, as [user@]host[:port]; "" connects directly
+	Bastions   map[string]string // Bastions of individual instances by DSN, overriding Bastion
+	KeyFile    string            // Private key to authenticate with; "" tries the keys in ~/.ssh
+	KnownHosts string            // known_hosts file the bastions' host keys are checked against
+}
+
+// Tunnels forwards instance connections through SSH bastions. An instance's
+// host:port is forwarded from a local port, and its DSN rewritten to dial that
+// port, so the driver connects as usual. Each bastion gets one SSH connection and
+// each instance address one local listener, opened on first use and shared by all
+// connections to it; Close tears them down.
+type Tunnels struct {
+	bastion  string
+	bastions map[string]string
+	dial     bastionDialer
+	agent    net.Conn // Connection to ssh-agent, if one is used
+
+	mu       sync.Mutex
+	clients  map[string]sshClient    // By bastion
+	failed   map[string]error        // Bastions that could not be reached, so they are not retried per instance
+	forwards map[string]net.Listener // By bastion and instance address
+}
+
+// NewTunnels loads the SSH keys and known hosts of cfg. Bastions are only
+// connected to once an instance behind them is.
+func NewTunnels(cfg TunnelConfig) (*Tunnels, error) {
+	hostKeys, err := knownhosts.New(cfg.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+	signers, agentConn, err := sshSigners(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	t := newTunnels(cfg, sshDialer(ssh.PublicKeys(signers...), hostKeys))
+	t.agent = agentConn
+	return t, nil
+}
+
+// newTunnels returns tunnels that reach bastions with dial
+func newTunnels(cfg TunnelConfig, dial bastionDialer) *Tunnels {
+	return &Tunnels{
+		bastion:  cfg.Bastion,
+		bastions: cfg.Bastions,
+		dial:     dial,
+		clients:  make(map[string]sshClient),
+		failed:   make(map[string]error),
+		forwards: make(map[string]net.Listener),
+	}
+}
+
+// ParseBastion splits a bastion given as [user@]host[:port] into the SSH user,
+// by default the local one, and the address to dial, by default on port 22
+func ParseBastion(spec string) (string, string, error) {
+	login, hostPort, found := strings.Cut(strings.TrimSpace(spec), "@")
+	if !found {
+		login, hostPort = "", login
+	}
+	if login == "" {
+		if u, err := user.Current(); err == nil {
+			login = u.Username
+		}
+	}
+	host, port := hostPort, "22"
+	if h, p, err := net.SplitHostPort(hostPort); err == nil {
+		host, port = h, p
+	}
+	if host == "" || strings.ContainsAny(host, "@/ ") {
+		return "", "", fmt.Errorf("invalid SSH bastion %q: expected [user@]host[:port]", spec)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("invalid SSH bastion %q: bad port %q", spec, port)
+	}
+	return login, net.JoinHostPort(host, port), nil
+}
+
+// bastionFor returns the bastion an instance is reached through, "" for none
+func (t *Tunnels) bastionFor(instanceDSN string) string {
+	if b, ok := t.bastions[instanceDSN]; ok {
+		return b
+	}
+	return t.bastion
+}
+
+// Rewrite returns the DSN to dial for an instance: its address replaced by a
+// local port forwarded through its bastion. DSNs without a bastion, unix socket
+// DSNs and DSNs the driver cannot parse are returned unchanged.
+func (t *Tunnels) Rewrite(ctx context.Context, instanceDSN string) (string, error) {
+	return t.rewrite(ctx, instanceDSN, instanceDSN)
+}
+
+// rewrite is Rewrite for dsn, the instance's DSN with parameters such as tls
+// changed, finding its bastion by the DSN as configured
+func (t *Tunnels) rewrite(ctx context.Context, instanceDSN, dsn string) (string, error) {
+	bastion := t.bastionFor(instanceDSN)
+	if bastion == "" {
+		return dsn, nil
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil || cfg.Net != "tcp" {
+		return dsn, nil
+	}
+	local, err := t.forward(ctx, bastion, cfg.Addr)
+	if err != nil {
+		return "", fmt.Errorf("SSH tunnel to %s via %s: %w", cfg.Addr, bastion, err)
+	}
+	if cfg.TLS != nil && cfg.TLS.ServerName != "" {
+		// The driver checks the certificate against the instance's host, set as
+		// ServerName while parsing; keep it rather than checking the local end
+		name := "csql-tunnel-" + cfg.TLSConfig + "-" + cfg.TLS.ServerName
+		if err := mysql.RegisterTLSConfig(name, cfg.TLS); err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = name
+	}
+	cfg.Addr = local
+	return cfg.FormatDSN(), nil
+}
+
+// forward returns the local address forwarded to addr through bastion, connecting
+// to the bastion and listening on a local port the first time
+func (t *Tunnels) forward(ctx context.Context, bastion, addr string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := bastion + " " + addr
+	if l, ok := t.forwards[key]; ok {
+		return l.Addr().String(), nil
+	}
+	if err := t.failed[bastion]; err != nil {
+		return "", err
+	}
+	client, ok := t.clients[bastion]
+	if !ok {
+		var err error
+		if client, err = t.dial(ctx, bastion); err != nil {
+			t.failed[bastion] = err
+			return "", err
+		}
+		t.clients[bastion] = client
+	}
+
+	// Reach the instance once up front, so an address the bastion cannot connect
+	// to fails here with the reason rather than as a dropped MySQL handshake
+	probe, err := client.Dial("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	probe.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	t.forwards[key] = l
+	go serveForward(l, client, addr)
+	return l.Addr().String(), nil
+}
+
+// serveForward relays each connection accepted on l to addr through the bastion
+// until l is closed
+func serveForward(l net.Listener, client sshClient, addr string) {
+	for {
+		local, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer local.Close()
+			remote, err := client.Dial("tcp", addr)
+			if err != nil {
+				return
+			}
+			defer remote.Close()
+			relay(local, remote)
+		}()
+	}
+}
+
+// relay copies between two connections until either side is done; the caller's
+// deferred closes then end the other direction
+func relay(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(a, b); done <- struct{}{} }()
+	go func() { io.Copy(b, a); done <- struct{}{} }()
+	<-done
+}
+
+// Close stops forwarding and disconnects from the bastions
+func (t *Tunnels) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for key, l := range t.forwards {
+		errs = append(errs, l.Close())
+		delete(t.forwards, key)
+	}
+	for bastion, client := range t.clients {
+		errs = append(errs, client.Close())
+		delete(t.clients, bastion)
+	}
+	if t.agent != nil {
+		errs = append(errs, t.agent.Close())
+		t.agent = nil
+	}
+	return errors.Join(errs...)
+}
+
+// sshSigners returns the keys to authenticate to bastions with: keyFile, or the
+// unencrypted default keys in ~/.ssh, plus those held by ssh-agent
+func sshSigners(keyFile string) ([]ssh.Signer, net.Conn, error) {
+	var signers []ssh.Signer
+	if keyFile != "" {
+		signer, err := readSSHKey(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		signers = append(signers, signer)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultSSHKeys {
+			if signer, err := readSSHKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	var agentConn net.Conn
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil && len(agentSigners) > 0 {
+				signers = append(signers, agentSigners...)
+				agentConn = conn
+			} else {
+				conn.Close()
+			}
+		}
+	}
+
+	if len(signers) == 0 {
+		return nil, nil, fmt.Errorf("no SSH key found: none of %s in ~/.ssh is usable and ssh-agent holds no keys",
+			strings.Join(defaultSSHKeys, ", "))
+	}
+	return signers, agentConn, nil
+}
+
+// readSSHKey reads an unencrypted private key
+func readSSHKey(path string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(pem)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("SSH key %s is encrypted; add it to ssh-agent instead", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// sshDialer connects to bastions with key-based authentication, checking their
+// host keys
+func sshDialer(auth ssh.AuthMethod, hostKeys ssh.HostKeyCallback) bastionDialer {
+	return func(ctx context.Context, bastion string) (sshClient, error) {
+		login, addr, err := ParseBastion(bastion)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := (&net.Dialer{Timeout: sshHandshakeTimeout}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(sshHandshakeTimeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetDeadline(deadline)
+		config := &ssh.ClientConfig{User: login, Auth: []ssh.AuthMethod{auth}, HostKeyCallback: hostKeys}
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return ssh.NewClient(c, chans, reqs), nil
+	}
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// fakeBastion stands in for an SSH connection: it reaches the instance addresses
+// it knows by dialing local echo servers in their place
+type fakeBastion struct {
+	mu      sync.Mutex
+	routes  map[string]string // Instance address -> local echo server
+	dialed  []string
+	closed  bool
+	dialErr error
+}
+
+func (b *fakeBastion) Dial(network, addr string) (net.Conn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dialed = append(b.dialed, addr)
+	local, ok := b.routes[addr]
+	if !ok {
+		return nil, fmt.Errorf("ssh: rejected: connect failed (Connection refused)")
+	}
+	return net.Dial("tcp", local)
+}
+
+func (b *fakeBastion) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+// echoServer starts a server that echoes whatever it is sent, prefixed with name
+func echoServer(t *testing.T, name string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.WriteString(conn, name+":")
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// roundTrip sends msg to addr and returns the echo
+func roundTrip(t *testing.T, addr, msg string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, msg); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := io.ReadAtLeast(conn, buf, len(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !strings.HasSuffix(string(buf[:n]), msg) {
+		m, err := conn.Read(buf[n:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += m
+	}
+	return string(buf[:n])
+}
+
+// fakeDialer returns a bastion dialer over fakes and the bastions dialed, by name
+func fakeDialer(bastions map[string]*fakeBastion) (bastionDialer, *[]string) {
+	var dialed []string
+	var mu sync.Mutex
+	return func(ctx context.Context, bastion string) (sshClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dialed = append(dialed, bastion)
+		b, ok := bastions[bastion]
+		if !ok {
+			return nil, errors.New("ssh: handshake failed: connection refused")
+		}
+		if b.dialErr != nil {
+			return nil, b.dialErr
+		}
+		return b, nil
+	}, &dialed
+}
+
+func TestParseBastion(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantUser string
+		wantAddr string
+		wantErr  bool
+	}{
+		{spec: "ops@bastion.example.com", wantUser: "ops", wantAddr: "bastion.example.com:22"},
+		{spec: "ops@bastion:2222", wantUser: "ops", wantAddr: "bastion:2222"},
+		{spec: " ops@10.0.0.1 ", wantUser: "ops", wantAddr: "10.0.0.1:22"},
+		{spec: "ops@[2001:db8::1]:2222", wantUser: "ops", wantAddr: "[2001:db8::1]:2222"},
+		{spec: "ops@", wantErr: true},
+		{spec: "ops@bastion:ssh", wantErr: true},
+		{spec: "ops@bastion:0", wantErr: true},
+		{spec: "ops@jump@bastion", wantErr: true},
+		{spec: "ops@tcp(bastion)/db", wantErr: true},
+	}
+	for _, tt := range tests {
+		user, addr, err := ParseBastion(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBastion(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (user != tt.wantUser || addr != tt.wantAddr) {
+			t.Errorf("ParseBastion(%q) = %q, %q, want %q, %q", tt.spec, user, addr, tt.wantUser, tt.wantAddr)
+		}
+	}
+
+	// Without a user the local one is used
+	if user, addr, err := ParseBastion("bastion"); err != nil || addr != "bastion:22" || user == "" {
+		t.Errorf("ParseBastion(\"bastion\") = %q, %q, %v; want the local user on port 22", user, addr, err)
+	}
+}
+
+func TestTunnels_Rewrite(t *testing.T) {
+	jump := &fakeBastion{routes: map[string]string{
+		"db1:3306": echoServer(t, "db1"),
+		"db2:3307": echoServer(t, "db2"),
+	}}
+	eu := &fakeBastion{routes: map[string]string{"db3:3306": echoServer(t, "db3")}}
+	dial, dialed := fakeDialer(map[string]*fakeBastion{"ops@jump": jump, "ops@eu-jump": eu})
+
+	const (
+		db1    = "app:s3cret@tcp(db1:3306)/shop?parseTime=true"
+		db2    = "app:s3cret@tcp(db2:3307)/shop"
+		db3    = "app:s3cret@tcp(db3:3306)/shop"
+		direct = "app:s3cret@tcp(db4:3306)/shop"
+		socket = "app:s3cret@unix(/var/run/mysqld/mysqld.sock)/shop"
+	)
+	tunnels := newTunnels(TunnelConfig{
+		Bastion:  "ops@jump",
+		Bastions: map[string]string{db3: "ops@eu-jump", direct: ""},
+	}, dial)
+	defer tunnels.Close()
+
+	ctx := context.Background()
+	rewrite := func(dsn string) *mysql.Config {
+		t.Helper()
+		got, err := tunnels.Rewrite(ctx, dsn)
+		if err != nil {
+			t.Fatalf("Rewrite(%q) error = %v", dsn, err)
+		}
+		cfg, err := mysql.ParseDSN(got)
+		if err != nil {
+			t.Fatalf("Rewrite(%q) = %q: %v", dsn, got, err)
+		}
+		return cfg
+	}
+
+	cfg1 := rewrite(db1)
+	if host, _, _ := net.SplitHostPort(cfg1.Addr); host != "127.0.0.1" {
+		t.Errorf("db1 dials %s, want a local port", cfg1.Addr)
+	}
+	if cfg1.User != "app" || cfg1.Passwd != "s3cret" || cfg1.DBName != "shop" || !cfg1.ParseTime {
+		t.Errorf("db1 rewritten to %+v, want only the address changed", cfg1)
+	}
+	if got := roundTrip(t, cfg1.Addr, "ping"); got != "db1:ping" {
+		t.Errorf("db1 tunnel relayed %q, want db1:ping", got)
+	}
+	if again := rewrite(db1); again.Addr != cfg1.Addr {
+		t.Errorf("db1 rewritten to %s, then %s; want the forward reused", cfg1.Addr, again.Addr)
+	}
+
+	cfg2 := rewrite(db2)
+	if cfg2.Addr == cfg1.Addr {
+		t.Errorf("db1 and db2 share the local port %s", cfg1.Addr)
+	}
+	if got := roundTrip(t, cfg2.Addr, "ping"); got != "db2:ping" {
+		t.Errorf("db2 tunnel relayed %q, want db2:ping", got)
+	}
+	if got := roundTrip(t, rewrite(db3).Addr, "ping"); got != "db3:ping" {
+		t.Errorf("db3 tunnel relayed %q, want db3:ping", got)
+	}
+
+	for _, dsn := range []string{direct, socket} {
+		if got, err := tunnels.Rewrite(ctx, dsn); err != nil || got != dsn {
+			t.Errorf("Rewrite(%q) = %q, %v; want it unchanged", dsn, got, err)
+		}
+	}
+
+	if strings.Join(*dialed, ",") != "ops@jump,ops@eu-jump" {
+		t.Errorf("bastions dialed = %q, want one SSH connection per bastion", *dialed)
+	}
+
+	if err := tunnels.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !jump.closed || !eu.closed {
+		t.Error("Close() left an SSH connection open")
+	}
+	if conn, err := net.Dial("tcp", cfg1.Addr); err == nil {
+		conn.Close()
+		t.Errorf("Close() left %s listening", cfg1.Addr)
+	}
+}
+
+func TestTunnels_RewriteErrors(t *testing.T) {
+	jump := &fakeBastion{routes: map[string]string{}}
+	dial, dialed := fakeDialer(map[string]*fakeBastion{"ops@jump": jump})
+	tunnels := newTunnels(TunnelConfig{
+		Bastion:  "ops@jump",
+		Bastions: map[string]string{"app@tcp(db2:3306)/": "ops@down", "app@tcp(db3:3306)/": "ops@down"},
+	}, dial)
+	defer tunnels.Close()
+	ctx := context.Background()
+
+	_, err := tunnels.Rewrite(ctx, "app@tcp(db1:3306)/")
+	if err == nil || !strings.Contains(err.Error(), "SSH tunnel to db1:3306 via ops@jump") || !strings.Contains(err.Error(), "Connection refused") {
+		t.Errorf("unreachable instance: error = %v, want the bastion's reason", err)
+	}
+
+	for _, dsn := range []string{"app@tcp(db2:3306)/", "app@tcp(db3:3306)/"} {
+		if _, err := tunnels.Rewrite(ctx, dsn); err == nil || !strings.Contains(err.Error(), "handshake failed") {
+			t.Errorf("Rewrite(%q) error = %v, want the bastion's failure", dsn, err)
+		}
+	}
+	if strings.Join(*dialed, ",") != "ops@jump,ops@down" {
+		t.Errorf("bastions dialed = %q, want an unreachable bastion tried once", *dialed)
+	}
+}
+
+func TestTunnels_RewriteTLS(t *testing.T) {
+	jump := &fakeBastion{routes: map[string]string{"db1.example.com:3306": echoServer(t, "db1")}}
+	dial, _ := fakeDialer(map[string]*fakeBastion{"ops@jump": jump})
+	tunnels := newTunnels(TunnelConfig{Bastion: "ops@jump"}, dial)
+	defer tunnels.Close()
+
+	got, err := tunnels.Rewrite(context.Background(), "app@tcp(db1.example.com:3306)/shop?tls=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mysql.ParseDSN(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLS == nil || cfg.TLS.ServerName != "db1.example.com" {
+		t.Errorf("TLS config = %+v, want the certificate checked against db1.example.com", cfg.TLS)
+	}
+}
+
+func TestConnect_Tunnels(t *testing.T) {
+	useFakeDriver(t)
+	jump := &fakeBastion{routes: map[string]string{"db1:3306": echoServer(t, "db1")}}
+	dial, _ := fakeDialer(map[string]*fakeBastion{"ops@jump": jump})
+	tunnels := newTunnels(TunnelConfig{Bastion: "ops@jump"}, dial)
+	defer tunnels.Close()
+
+	_, err := Connect(context.Background(), "app@tcp(db9:3306)/", ExecOptions{Tunnels: tunnels})
+	if err == nil || !strings.Contains(err.Error(), "SSH tunnel to db9:3306") {
+		t.Errorf("Connect() error = %v, want the tunnel failure", err)
+	}
+}
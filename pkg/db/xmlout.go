@@ -0,0 +1,65 @@
+package db
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlText escapes s for XML text or a double-quoted attribute value
+func xmlText(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s)) // Writing to a strings.Builder cannot fail
+	return sb.String()
+}
+
+// WriteXMLDocumentStart opens the document of a run's XML output. Each instance's
+// results follow as an <instance> element, so the output of many instances stays
+// a single well-formed document.
+func WriteXMLDocumentStart(w io.Writer) {
+	fmt.Fprint(w, "<?xml version=\"1.0\"?>\n\n<csql xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\">\n")
+}
+
+// WriteXMLDocumentEnd closes the document opened by WriteXMLDocumentStart
+func WriteXMLDocumentEnd(w io.Writer) {
+	fmt.Fprint(w, "</csql>\n")
+}
+
+// WriteXMLInstance writes an instance's results as an <instance> element carrying
+// the masked DSN, with a <resultset> per statement laid out as by mysql --xml:
+// a <row> of <field name="..."> elements per row, and xsi:nil for NULL. A failed
+// or skipped statement's resultset carries its error instead of rows.
+func WriteXMLInstance(w io.Writer, instanceDSN string, results []QueryResult) {
+	fmt.Fprintf(w, "<instance dsn=\"%s\">\n", xmlText(maskPasswordInDSN(instanceDSN)))
+	for _, res := range results {
+		writeXMLResultset(w, res)
+	}
+	fmt.Fprint(w, "</instance>\n")
+}
+
+// writeXMLResultset writes a result as a <resultset> element
+func writeXMLResultset(w io.Writer, res QueryResult) {
+	fmt.Fprintf(w, "<resultset statement=\"%s\"", xmlText(res.Statement))
+	if res.Skipped {
+		fmt.Fprint(w, " skipped=\"true\"")
+	}
+	if res.Err != nil {
+		fmt.Fprintf(w, " error=\"%s\" />\n", xmlText(res.Err.Error()))
+		return
+	}
+	fmt.Fprint(w, ">\n")
+	for _, row := range res.Rows {
+		fmt.Fprint(w, "  <row>\n")
+		values := rowStrings(row)
+		for i, col := range res.Columns {
+			if i >= len(row) || row[i] == nil {
+				fmt.Fprintf(w, "\t<field name=\"%s\" xsi:nil=\"true\" />\n", xmlText(col))
+				continue
+			}
+			fmt.Fprintf(w, "\t<field name=\"%s\">%s</field>\n", xmlText(col), xmlText(values[i]))
+		}
+		fmt.Fprint(w, "  </row>\n")
+	}
+	fmt.Fprint(w, "</resultset>\n")
+}
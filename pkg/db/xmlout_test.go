@@ -0,0 +1,80 @@
+package db
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteXMLInstance(t *testing.T) {
+	results := []QueryResult{
+		{
+			Statement: `SELECT id, note FROM t WHERE note <> "a&b"`,
+			Columns:   []string{"id", "note"},
+			Rows:      [][]interface{}{{int64(1), []byte("<b>&</b>")}, {int64(2), nil}},
+			RowCount:  2,
+		},
+		{Statement: "SELECT broken", Err: errors.New(`Unknown column "broken"`)},
+	}
+	var buf bytes.Buffer
+	WriteXMLInstance(&buf, "u:secret@tcp(db1:3306)/app", results)
+
+	want := `<instance dsn="u:****@tcp(db1:3306)/app">
+<resultset statement="SELECT id, note FROM t WHERE note &lt;&gt; &#34;a&amp;b&#34;">
+  <row>
+	<field name="id">1</field>
+	<field name="note">&lt;b&gt;&amp;&lt;/b&gt;</field>
+  </row>
+  <row>
+	<field name="id">2</field>
+	<field name="note" xsi:nil="true" />
+  </row>
+</resultset>
+<resultset statement="SELECT broken" error="Unknown column &#34;broken&#34;" />
+</instance>
+`
+	if buf.String() != want {
+		t.Errorf("WriteXMLInstance() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteXMLDocument_IsWellFormed(t *testing.T) {
+	var buf bytes.Buffer
+	WriteXMLDocumentStart(&buf)
+	for _, host := range []string{"db1", "db2"} {
+		WriteXMLInstance(&buf, "u:p@tcp("+host+":3306)/", []QueryResult{{
+			Statement: "SELECT v\nFROM t",
+			Columns:   []string{"v"},
+			Rows:      [][]interface{}{{[]byte("a\x00b")}, {nil}},
+			RowCount:  2,
+		}})
+	}
+	WriteXMLDocumentEnd(&buf)
+
+	var doc struct {
+		Instances []struct {
+			DSN        string `xml:"dsn,attr"`
+			Resultsets []struct {
+				Statement string `xml:"statement,attr"`
+				Rows      []struct {
+					Fields []struct {
+						Name  string `xml:"name,attr"`
+						Value string `xml:",chardata"`
+					} `xml:"field"`
+				} `xml:"row"`
+			} `xml:"resultset"`
+		} `xml:"instance"`
+	}
+	if err := xml.NewDecoder(strings.NewReader(buf.String())).Decode(&doc); err != nil {
+		t.Fatalf("output is not a well-formed document: %v\n%s", err, buf.String())
+	}
+	if len(doc.Instances) != 2 || doc.Instances[1].DSN != "u:****@tcp(db2:3306)/" {
+		t.Fatalf("instances = %+v, want db1 and db2", doc.Instances)
+	}
+	rs := doc.Instances[0].Resultsets[0]
+	if rs.Statement != "SELECT v\nFROM t" || len(rs.Rows) != 2 || rs.Rows[0].Fields[0].Value != "a\uFFFDb" {
+		t.Errorf("resultset = %+v, want the statement's line break kept and the invalid character replaced", rs)
+	}
+}
@@ -0,0 +1,494 @@
+// Package sqllog extracts replayable SQL statements from MySQL server logs.
+package sqllog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binlogDelimiter ends every statement in mysqlbinlog output (DELIMITER /*!*/;)
+const binlogDelimiter = "/*!*/;"
+
+var (
+	// eventHeaderRe matches an event header such as
+	// "#240115 10:30:00 server id 1  end_log_pos 1234 CRC32 0x1a2b3c4d  Query ..."
+	eventHeaderRe = regexp.MustCompile(`^#\d{6}\s+\d{1,2}:\d{2}:\d{2}\s+server id\s+(\d+)`)
+	// tableMapRe matches the Table_map part of a row event header
+	tableMapRe = regexp.MustCompile("Table_map: (`[^`]*`\\.`[^`]*`) mapped to number")
+	// rowValueCommentRe matches the type annotation mysqlbinlog -vv appends to row values
+	rowValueCommentRe = regexp.MustCompile(`\s+/\* [A-Z0-9_/]+(\([0-9,]+\))? meta=\d+ nullable=\d is_null=\d \*/$`)
+	// unsignedValueRe matches a negative integer printed with its unsigned reading, "-1 (4294967295)"
+	unsignedValueRe = regexp.MustCompile(`^-\d+ \((\d+)\)$`)
+)
+
+// Event is one statement recovered from mysqlbinlog output or a slow query log
+type Event struct {
+	SQL      string // Executable SQL, without the binlog delimiter
+	Database string // Default database of a statement event, or the schema a row change applies to
+	ServerID uint32 // server_id from the event header
+	Line     int    // 1-based input line the statement starts on
+	Setup    bool   // Session setup: SET TIMESTAMP, SET @@session... (only with IncludeSessionSetup), or a slow log's USE
+
+	// Slow log only
+	Time       time.Time     // When the statement finished, from # Time: or SET timestamp; zero if unknown
+	QueryTime  time.Duration // Query_time the server logged for it
+	Connection uint64        // Id of the server connection that ran it
+}
+
+// BinlogOptions filters the events ParseBinlogText returns
+type BinlogOptions struct {
+	IncludeSessionSetup bool   // Keep the session setup mysqlbinlog prints before each statement
+	Database            string // Only events for this database (empty = all)
+	ServerID            uint32 // Only events written by this server_id (0 = all)
+}
+
+// column is one table column from the "# Columns(...)" metadata of mysqlbinlog
+// --print-table-metadata
+type column struct {
+	name     string // Quoted as printed, e.g. `id`
+	unsigned bool
+}
+
+// rowChange is one row of a decoded row event (### pseudo-SQL)
+type rowChange struct {
+	op    string // INSERT, UPDATE or DELETE
+	table string // `db`.`table`
+	line  int
+	where []assignment // Before image (UPDATE, DELETE)
+	set   []assignment // After image (INSERT, UPDATE)
+}
+
+// assignment is one "@N=value" line of the pseudo-SQL
+type assignment struct {
+	pos   int // 1-based column position
+	value string
+}
+
+// binlogParser holds the state of a ParseBinlogText run
+type binlogParser struct {
+	opts   BinlogOptions
+	events []Event
+
+	serverID  uint32 // server_id of the current event
+	database  string // Default database from the last "use"
+	emittedDB string // Database of the last USE written to events
+
+	stmt     []string // Lines of the statement being read
+	stmtLine int
+	rows     []string // Consecutive ### lines of a row event
+	rowsLine int
+	inBlob   bool // Inside a BINLOG '...' base64 block
+
+	tableMap    string              // Table of the last Table_map header
+	columnsText strings.Builder     // "# Columns(" metadata being read
+	inColumns   bool                // Reading multi-line column metadata
+	columns     map[string][]column // Column metadata per `db`.`table`
+
+	setup    []Event // Session setup for the next statement, dropped if that is filtered out
+	txnStart int     // Index of the open BEGIN in events, or -1
+	txnUsed  bool    // A statement was written since the open BEGIN
+}
+
+// ParseBinlogText extracts the SQL statements from the text mysqlbinlog prints,
+// typically with --base64-output=decode-rows -v. Statement-format events are returned
+// as logged. Row events are rebuilt from the ### pseudo-SQL into INSERT, UPDATE and
+// DELETE statements, which needs the column names from --print-table-metadata.
+// BINLOG base64 blocks are skipped, as is session setup unless IncludeSessionSetup.
+func ParseBinlogText(r io.Reader, opts BinlogOptions) ([]Event, error) {
+	p := &binlogParser{opts: opts, columns: map[string][]column{}, txnStart: -1}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := p.line(scanner.Text(), lineNo); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read binlog text: %w", err)
+	}
+	if err := p.flushRows(); err != nil {
+		return nil, err
+	}
+	if len(p.stmt) > 0 {
+		return nil, fmt.Errorf("line %d: statement not terminated by %s", p.stmtLine, binlogDelimiter)
+	}
+	return p.events, nil
+}
+
+// JoinStatements renders events as a script of ;-separated statements. The
+// separator goes on its own line so a trailing -- comment cannot swallow it.
+func JoinStatements(events []Event) string {
+	var b strings.Builder
+	for _, e := range events {
+		b.WriteString(e.SQL)
+		b.WriteString("\n;\n")
+	}
+	return b.String()
+}
+
+// line consumes one input line
+func (p *binlogParser) line(line string, lineNo int) error {
+	if p.inBlob {
+		if strings.HasSuffix(strings.TrimSpace(line), "'"+binlogDelimiter) {
+			p.inBlob = false
+		}
+		return nil
+	}
+
+	if len(p.stmt) == 0 && strings.HasPrefix(line, "###") {
+		if len(p.rows) == 0 {
+			p.rowsLine = lineNo
+		}
+		p.rows = append(p.rows, strings.TrimSpace(strings.TrimPrefix(line, "###")))
+		return nil
+	}
+	if err := p.flushRows(); err != nil {
+		return err
+	}
+
+	trimmed := strings.TrimSpace(line)
+	if len(p.stmt) == 0 {
+		switch {
+		case trimmed == "":
+			return nil
+		case strings.HasPrefix(trimmed, "#"):
+			p.header(line)
+			return nil
+		case strings.HasPrefix(trimmed, "DELIMITER "):
+			return nil
+		case strings.HasPrefix(trimmed, "BINLOG '"):
+			p.inBlob = !strings.HasSuffix(trimmed, "'"+binlogDelimiter)
+			return nil
+		case strings.HasPrefix(trimmed, "/*!") && strings.HasSuffix(trimmed, "*/;") && !strings.HasSuffix(trimmed, binlogDelimiter):
+			// Version comments such as /*!50530 SET @@SESSION.PSEUDO_SLAVE_MODE=1*/;
+			// are written before the delimiter is switched
+			p.statement(strings.TrimSuffix(trimmed, ";"), lineNo)
+			return nil
+		}
+		p.stmtLine = lineNo
+	}
+
+	p.stmt = append(p.stmt, line)
+	if strings.HasSuffix(trimmed, binlogDelimiter) {
+		text := strings.TrimSuffix(strings.TrimRight(strings.Join(p.stmt, "\n"), " \t\r"), binlogDelimiter)
+		p.stmt = nil
+		p.statement(strings.TrimSpace(text), p.stmtLine)
+	}
+	return nil
+}
+
+// header handles a # line: event headers, Table_map and column metadata
+func (p *binlogParser) header(line string) {
+	if p.inColumns {
+		p.columnsText.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "#")))
+		p.finishColumns()
+		return
+	}
+	if m := eventHeaderRe.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.ParseUint(m[1], 10, 32)
+		p.serverID = uint32(id)
+		if t := tableMapRe.FindStringSubmatch(line); t != nil {
+			p.tableMap = t[1]
+		}
+		return
+	}
+	if rest, ok := strings.CutPrefix(line, "# Columns("); ok && p.tableMap != "" {
+		p.columnsText.Reset()
+		p.columnsText.WriteString(rest)
+		p.inColumns = true
+		p.finishColumns()
+	}
+}
+
+// finishColumns parses the column metadata once its closing parenthesis was read
+func (p *binlogParser) finishColumns() {
+	defs, done := splitColumnDefs(p.columnsText.String())
+	if !done {
+		p.columnsText.WriteString(" ")
+		return
+	}
+	cols := make([]column, 0, len(defs))
+	for _, def := range defs {
+		name := def
+		if strings.HasPrefix(def, "`") {
+			if end := strings.Index(def[1:], "`"); end >= 0 {
+				name = def[:end+2]
+			}
+		} else if i := strings.IndexByte(def, ' '); i >= 0 {
+			name = "`" + def[:i] + "`"
+		}
+		cols = append(cols, column{name: name, unsigned: strings.Contains(strings.ToUpper(def), " UNSIGNED")})
+	}
+	p.columns[p.tableMap] = cols
+	p.inColumns = false
+}
+
+// splitColumnDefs splits the text after "# Columns(" at top-level commas. The bool
+// is false while the closing parenthesis has not been read yet.
+func splitColumnDefs(text string) ([]string, bool) {
+	var defs []string
+	var cur strings.Builder
+	depth := 0
+	var quote rune
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '`' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')' && depth == 0:
+			defs = append(defs, strings.TrimSpace(cur.String()))
+			return defs, true
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			defs = append(defs, strings.TrimSpace(cur.String()))
+			cur.Reset()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	return nil, false
+}
+
+// statement classifies a complete delimited statement and records it
+func (p *binlogParser) statement(sql string, lineNo int) {
+	upper := strings.ToUpper(sql)
+	switch {
+	case sql == "", strings.HasPrefix(sql, `/*!\C`):
+		// Client character set switches are mysql client commands, not SQL
+	case strings.HasPrefix(upper, "ROLLBACK") && strings.Contains(sql, "added by mysqlbinlog"):
+		// mysqlbinlog closes its output with a ROLLBACK of its own
+	case strings.HasPrefix(upper, "USE "):
+		p.database = strings.Trim(strings.TrimSpace(sql[4:]), "`")
+	case isSessionSetup(upper):
+		if p.opts.IncludeSessionSetup && p.fromServer() {
+			p.setup = append(p.setup, Event{SQL: sql, Database: p.database, ServerID: p.serverID, Line: lineNo, Setup: true})
+		}
+	case upper == "BEGIN":
+		if !p.fromServer() {
+			p.setup = nil
+			return
+		}
+		p.txnStart, p.txnUsed = len(p.events), false
+		p.emitSetup()
+		p.events = append(p.events, Event{SQL: sql, Database: p.database, ServerID: p.serverID, Line: lineNo})
+	case upper == "COMMIT" || upper == "ROLLBACK":
+		if !p.fromServer() || p.txnStart < 0 {
+			// mysqlbinlog also prints a ROLLBACK after the format description event
+			p.setup = nil
+			return
+		}
+		if !p.txnUsed {
+			// Every statement of the transaction was filtered out
+			p.events = p.events[:p.txnStart]
+		} else {
+			p.emitSetup()
+			p.events = append(p.events, Event{SQL: sql, Database: p.database, ServerID: p.serverID, Line: lineNo})
+		}
+		p.txnStart = -1
+	default:
+		if !p.fromServer() || !p.inDatabase(p.database) {
+			p.setup = nil
+			return
+		}
+		p.emitUse(lineNo)
+		p.emit(Event{SQL: sql, Database: p.database, ServerID: p.serverID, Line: lineNo})
+	}
+}
+
+// isSessionSetup reports whether an upper-cased statement only prepares the session
+// for the statement that follows it in the same event
+func isSessionSetup(upper string) bool {
+	for _, prefix := range []string{"SET TIMESTAMP", "SET @@", "SET INSERT_ID", "SET LAST_INSERT_ID", "SET @`", "SET @OLD", "/*!"} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromServer reports whether the current event passes the server_id filter
+func (p *binlogParser) fromServer() bool {
+	return p.opts.ServerID == 0 || p.serverID == p.opts.ServerID
+}
+
+// inDatabase reports whether a database passes the database filter
+func (p *binlogParser) inDatabase(database string) bool {
+	return p.opts.Database == "" || database == p.opts.Database
+}
+
+// emitUse writes a USE when the statement's default database differs from the last one
+func (p *binlogParser) emitUse(lineNo int) {
+	if p.database == "" || p.database == p.emittedDB {
+		return
+	}
+	p.emittedDB = p.database
+	p.events = append(p.events, Event{SQL: "USE `" + p.database + "`", Database: p.database, ServerID: p.serverID, Line: lineNo})
+}
+
+// emitSetup writes the pending session setup of the current event
+func (p *binlogParser) emitSetup() {
+	p.events = append(p.events, p.setup...)
+	p.setup = nil
+}
+
+// emit writes a statement preceded by its session setup
+func (p *binlogParser) emit(e Event) {
+	p.emitSetup()
+	p.events = append(p.events, e)
+	p.txnUsed = true
+}
+
+// flushRows turns the collected ### pseudo-SQL into statements
+func (p *binlogParser) flushRows() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	lines, first := p.rows, p.rowsLine
+	p.rows = nil
+
+	var changes []*rowChange
+	var section *[]assignment
+	for i, line := range lines {
+		lineNo := first + i
+		var cur *rowChange
+		if len(changes) > 0 {
+			cur = changes[len(changes)-1]
+		}
+		switch {
+		case strings.HasPrefix(line, "INSERT INTO "):
+			changes = append(changes, &rowChange{op: "INSERT", table: strings.TrimPrefix(line, "INSERT INTO "), line: lineNo})
+		case strings.HasPrefix(line, "UPDATE "):
+			changes = append(changes, &rowChange{op: "UPDATE", table: strings.TrimPrefix(line, "UPDATE "), line: lineNo})
+		case strings.HasPrefix(line, "DELETE FROM "):
+			changes = append(changes, &rowChange{op: "DELETE", table: strings.TrimPrefix(line, "DELETE FROM "), line: lineNo})
+		case cur == nil:
+			return fmt.Errorf("line %d: row event value %q without INSERT, UPDATE or DELETE", lineNo, line)
+		case line == "SET":
+			section = &cur.set
+		case line == "WHERE":
+			section = &cur.where
+		case strings.HasPrefix(line, "@"):
+			a, err := parseAssignment(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if section == nil {
+				return fmt.Errorf("line %d: row event value %q outside SET or WHERE", lineNo, line)
+			}
+			*section = append(*section, a)
+		default:
+			return fmt.Errorf("line %d: unrecognized row event line %q", lineNo, line)
+		}
+	}
+
+	for _, change := range changes {
+		database := tableSchema(change.table)
+		if !p.fromServer() || !p.inDatabase(database) {
+			p.setup = nil
+			continue
+		}
+		sql, err := change.sql(p.columns[change.table])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", change.line, err)
+		}
+		p.emit(Event{SQL: sql, Database: database, ServerID: p.serverID, Line: change.line})
+	}
+	return nil
+}
+
+// parseAssignment parses "@3='value'", dropping a -vv type annotation
+func parseAssignment(line string) (assignment, error) {
+	pos, value, ok := strings.Cut(line[1:], "=")
+	n, err := strconv.Atoi(pos)
+	if !ok || err != nil || n < 1 {
+		return assignment{}, fmt.Errorf("malformed row event value %q", line)
+	}
+	return assignment{pos: n, value: rowValueCommentRe.ReplaceAllString(value, "")}, nil
+}
+
+// tableSchema returns the database of a `db`.`table` name
+func tableSchema(table string) string {
+	if i := strings.Index(table, "`.`"); i >= 0 {
+		return strings.Trim(table[:i], "`")
+	}
+	return ""
+}
+
+// sql rebuilds the statement for one changed row. The before image identifies the
+// row by every column, so UPDATE and DELETE are limited to one row.
+func (rc *rowChange) sql(cols []column) (string, error) {
+	if len(cols) == 0 {
+		return "", fmt.Errorf("row event for %s has no column names; run mysqlbinlog with --print-table-metadata", rc.table)
+	}
+	name := func(a assignment) (string, error) {
+		if a.pos > len(cols) {
+			return "", fmt.Errorf("row event for %s sets column @%d but the table has %d columns", rc.table, a.pos, len(cols))
+		}
+		return cols[a.pos-1].name, nil
+	}
+	value := func(a assignment) string {
+		if m := unsignedValueRe.FindStringSubmatch(a.value); m != nil && cols[a.pos-1].unsigned {
+			return m[1]
+		}
+		return a.value
+	}
+	list := func(as []assignment, sep string, where bool) (string, error) {
+		parts := make([]string, 0, len(as))
+		for _, a := range as {
+			col, err := name(a)
+			if err != nil {
+				return "", err
+			}
+			if v := value(a); where && v == "NULL" {
+				parts = append(parts, col+" IS NULL")
+			} else {
+				parts = append(parts, col+"="+v)
+			}
+		}
+		return strings.Join(parts, sep), nil
+	}
+
+	switch rc.op {
+	case "INSERT":
+		names := make([]string, 0, len(rc.set))
+		values := make([]string, 0, len(rc.set))
+		for _, a := range rc.set {
+			col, err := name(a)
+			if err != nil {
+				return "", err
+			}
+			names = append(names, col)
+			values = append(values, value(a))
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", rc.table, strings.Join(names, ", "), strings.Join(values, ", ")), nil
+	case "UPDATE":
+		set, err := list(rc.set, ", ", false)
+		if err != nil {
+			return "", err
+		}
+		where, err := list(rc.where, " AND ", true)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s LIMIT 1", rc.table, set, where), nil
+	default:
+		where, err := list(rc.where, " AND ", true)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT 1", rc.table, where), nil
+	}
+}
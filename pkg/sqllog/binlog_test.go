@@ -0,0 +1,184 @@
+package sqllog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseFixture parses a mysqlbinlog output file from testdata
+func parseFixture(t *testing.T, name string, opts BinlogOptions) []Event {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := ParseBinlogText(f, opts)
+	if err != nil {
+		t.Fatalf("ParseBinlogText(%s) error = %v", name, err)
+	}
+	return events
+}
+
+// statements returns the SQL of events
+func statements(events []Event) []string {
+	sqls := make([]string, 0, len(events))
+	for _, e := range events {
+		sqls = append(sqls, e.SQL)
+	}
+	return sqls
+}
+
+func TestParseBinlogText_StatementFormat(t *testing.T) {
+	const insert = "INSERT INTO orders (customer, note)\nVALUES ('ada', 'ships; fragile -- handle with care')"
+	const purge = "DELETE FROM log WHERE created < '2023-01-01'"
+	const alter = "ALTER TABLE orders ADD COLUMN shipped_at DATETIME NULL"
+
+	tests := []struct {
+		name string
+		opts BinlogOptions
+		want []string
+	}{
+		{
+			name: "statements only",
+			want: []string{"BEGIN", "USE `shop`", insert, "COMMIT", "BEGIN", "USE `audit`", purge, "COMMIT", "USE `shop`", alter},
+		},
+		{
+			name: "database filter",
+			opts: BinlogOptions{Database: "shop"},
+			want: []string{"BEGIN", "USE `shop`", insert, "COMMIT", alter},
+		},
+		{
+			name: "server id filter",
+			opts: BinlogOptions{ServerID: 2},
+			want: []string{"BEGIN", "USE `audit`", purge, "COMMIT"},
+		},
+		{
+			name: "session setup",
+			opts: BinlogOptions{IncludeSessionSetup: true, ServerID: 2},
+			want: []string{"SET TIMESTAMP=1705314730", "BEGIN", "USE `audit`", "SET TIMESTAMP=1705314730", purge, "COMMIT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statements(parseFixture(t, "statement.txt", tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBinlogText_StatementFormatSetup(t *testing.T) {
+	events := parseFixture(t, "statement.txt", BinlogOptions{IncludeSessionSetup: true, ServerID: 1})
+
+	var setup []string
+	for _, e := range events {
+		if e.Setup {
+			setup = append(setup, e.SQL)
+		}
+		if strings.Contains(e.SQL, "BINLOG") || strings.Contains(e.SQL, `\C`) || strings.Contains(e.SQL, "added by mysqlbinlog") {
+			t.Errorf("event %q should have been skipped", e.SQL)
+		}
+	}
+	for _, want := range []string{"SET @@session.sql_mode=1168113696", "SET INSERT_ID=42", "SET TIMESTAMP=1705314780"} {
+		if !strings.Contains(strings.Join(setup, "\n"), want) {
+			t.Errorf("session setup is missing %q: %q", want, setup)
+		}
+	}
+
+	first := events[0]
+	if first.Line != 15 || first.ServerID != 1 || first.Database != "" {
+		t.Errorf("first event = %+v, want the GTID_NEXT setup on line 15 from server 1", first)
+	}
+}
+
+func TestParseBinlogText_RowFormat(t *testing.T) {
+	const insertAda = "INSERT INTO `shop`.`orders` (`id`, `customer`, `status`, `note`) VALUES (1, 'ada', 1, NULL)"
+	const insertBob = "INSERT INTO `shop`.`orders` (`id`, `customer`, `status`, `note`) VALUES (4294967295, 'bob', 2, 'it''s fragile')"
+	const update = "UPDATE `shop`.`orders` SET `id`=1, `customer`='ada', `status`=2, `note`='paid by card' " +
+		"WHERE `id`=1 AND `customer`='ada' AND `status`=1 AND `note` IS NULL LIMIT 1"
+	const del = "DELETE FROM `audit`.`log` WHERE `id`=-5 AND `msg`='old' LIMIT 1"
+
+	tests := []struct {
+		name string
+		opts BinlogOptions
+		want []string
+	}{
+		{
+			name: "all rows",
+			want: []string{"BEGIN", insertAda, insertBob, "COMMIT", "BEGIN", update, del, "COMMIT"},
+		},
+		{
+			name: "database filter drops empty transactions",
+			opts: BinlogOptions{Database: "audit"},
+			want: []string{"BEGIN", del, "COMMIT"},
+		},
+		{
+			name: "server id filter",
+			opts: BinlogOptions{ServerID: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := parseFixture(t, "row.txt", tt.opts)
+			got := statements(events)
+			if len(got) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	events := parseFixture(t, "row.txt", BinlogOptions{})
+	if e := events[1]; e.Line != 31 || e.Database != "shop" {
+		t.Errorf("first row event = %+v, want line 31 in shop", e)
+	}
+}
+
+func TestParseBinlogText_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "row event without column metadata",
+			input:   "#240115 11:00:05 server id 1  end_log_pos 362 CRC32 0x2c3d4e5f \tTable_map: `shop`.`t` mapped to number 91\n### INSERT INTO `shop`.`t`\n### SET\n###   @1=1\n",
+			wantErr: "line 2: row event for `shop`.`t` has no column names; run mysqlbinlog with --print-table-metadata",
+		},
+		{
+			name:    "more values than columns",
+			input:   "#240115 11:00:05 server id 1  end_log_pos 362 CRC32 0x2c3d4e5f \tTable_map: `shop`.`t` mapped to number 91\n# Columns(`a` INT)\n### DELETE FROM `shop`.`t`\n### WHERE\n###   @1=1\n###   @2=2\n",
+			wantErr: "sets column @2 but the table has 1 columns",
+		},
+		{
+			name:    "unterminated statement",
+			input:   "DELIMITER /*!*/;\nINSERT INTO t VALUES (1)\n",
+			wantErr: "line 2: statement not terminated by /*!*/;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBinlogText(strings.NewReader(tt.input), BinlogOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseBinlogText() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJoinStatements(t *testing.T) {
+	got := JoinStatements([]Event{{SQL: "SELECT 1 -- trailing"}, {SQL: "SELECT 2"}})
+	if want := "SELECT 1 -- trailing\n;\nSELECT 2\n;\n"; got != want {
+		t.Errorf("JoinStatements() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package sqllog
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// valueListRe matches a parenthesized list of placeholders, e.g. an IN list
+var valueListRe = regexp.MustCompile(`\(\?(?:\s*,\s*\?)+\)`)
+
+// Fingerprint normalizes a statement into the text its digest groups by: literal
+// values become ?, lists of them (?+), comments are dropped, whitespace collapsed
+// and everything outside backticks lower-cased. Statements differing only in their
+// values share a fingerprint.
+func Fingerprint(sql string) string {
+	runes := []rune(sql)
+	var b strings.Builder
+	space := false // Whitespace or a comment was skipped since the last rune written
+	write := func(s string) {
+		// Separators hug their neighbors however the statement was spaced
+		if space && b.Len() > 0 && s != "," && s != ")" && !strings.HasSuffix(b.String(), "(") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s)
+		space = s == "," // Always followed by a single space
+	}
+	// afterWord reports whether the last rune written continues an identifier
+	afterWord := func() bool {
+		out := b.String()
+		if out == "" || space {
+			return false
+		}
+		last := rune(out[len(out)-1])
+		return last == '_' || last == '$' || unicode.IsLetter(last) || unicode.IsDigit(last)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+		case r == '\'' || r == '"':
+			i = skipQuoted(runes, i)
+			write("?")
+		case r == '`':
+			end := skipQuoted(runes, i)
+			write(string(runes[i : end+1]))
+			i = end
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 3; i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/'); i++ {
+			}
+			space = true
+		case r == '#' || (r == '-' && i+2 < len(runes) && runes[i+1] == '-' && unicode.IsSpace(runes[i+2])):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			space = true
+		case unicode.IsDigit(r) && !afterWord():
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || unicode.IsLetter(runes[i+1]) || runes[i+1] == '.') {
+				i++ // Digits, a decimal point, an exponent or a 0x prefix
+			}
+			write("?")
+		default:
+			write(string(unicode.ToLower(r)))
+		}
+	}
+	return valueListRe.ReplaceAllString(b.String(), "(?+)")
+}
+
+// skipQuoted returns the index of the quote closing the one at start, honoring
+// backslash escapes and doubled quotes, or the last index if it is never closed
+func skipQuoted(runes []rune, start int) int {
+	quote := runes[start]
+	for i := start + 1; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && quote != '`':
+			i++
+		case runes[i] == quote && i+1 < len(runes) && runes[i+1] == quote:
+			i++
+		case runes[i] == quote:
+			return i
+		}
+	}
+	return len(runes) - 1
+}
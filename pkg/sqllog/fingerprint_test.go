@@ -0,0 +1,28 @@
+package sqllog
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{"numbers and strings", "SELECT * FROM orders WHERE id = 42 AND status = 'open'", "select * from orders where id = ? and status = ?"},
+		{"whitespace and case", "SELECT  a\n\tFROM   T", "select a from t"},
+		{"in list", "DELETE FROM t WHERE id IN (1, 2,3)", "delete from t where id in (?+)"},
+		{"comma spacing", "SELECT a ,b,  c FROM t", "select a, b, c from t"},
+		{"escaped and doubled quotes", `SELECT 'it\'s', 'a''b', "c"`, "select ?, ?, ?"},
+		{"digits in identifiers", "SELECT col1 FROM t2 WHERE x = 1.5e3", "select col1 from t2 where x = ?"},
+		{"hex literal", "SELECT 0xFF", "select ?"},
+		{"backticks keep case", "SELECT `Col` FROM `My Table`", "select `Col` from `My Table`"},
+		{"comments dropped", "SELECT /* hint */ 1 -- trailing\n, 2 # more", "select ?, ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fingerprint(tt.sql); got != tt.want {
+				t.Errorf("Fingerprint(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
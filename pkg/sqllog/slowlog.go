@@ -0,0 +1,152 @@
+package sqllog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// slowLogNoiseRe matches the lines the server writes at the top of a slow log
+	// each time it (re)opens it
+	slowLogNoiseRe = regexp.MustCompile(`(^\S.*, Version: .* started with:$)|(^Tcp port: )|(^Time\s+Id\s+Command\s+Argument$)`)
+	// connectionIDRe matches the connection id of a "# User@Host:" line
+	connectionIDRe = regexp.MustCompile(`\sId:\s*(\d+)`)
+	// queryTimeRe matches the Query_time of a "# Query_time:" line
+	queryTimeRe = regexp.MustCompile(`Query_time:\s*([0-9.]+)`)
+)
+
+// slowLogParser holds the state of a ParseSlowLog run
+type slowLogParser struct {
+	events    []Event
+	database  string // Default database from the last "use"
+	emittedDB string // Database of the last USE written to events
+	lastTime  time.Time
+
+	// The entry being read
+	time      time.Time // From # Time:, which MySQL 5.6 omits within the same second
+	timestamp time.Time // From SET timestamp
+	hasUser   bool
+	queryTime time.Duration
+	conn      uint64
+	stmt      []string
+	stmtLine  int
+}
+
+// ParseSlowLog extracts the statements of a MySQL slow query log with the time
+// each finished, its Query_time and the connection that ran it. A USE event marked
+// as Setup precedes each statement whose default database differs from the last.
+// Administrator commands (Quit, Ping...) have no SQL and are skipped.
+func ParseSlowLog(r io.Reader) ([]Event, error) {
+	p := &slowLogParser{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := p.line(scanner.Text(), lineNo); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read slow log: %w", err)
+	}
+	p.flush()
+	return p.events, nil
+}
+
+// line consumes one input line
+func (p *slowLogParser) line(line string, lineNo int) error {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case slowLogNoiseRe.MatchString(trimmed):
+		return nil
+	case strings.HasPrefix(trimmed, "# Time:"):
+		p.flush()
+		t, err := parseSlowLogTime(strings.TrimSpace(strings.TrimPrefix(trimmed, "# Time:")))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		p.time = t
+		return nil
+	case strings.HasPrefix(trimmed, "# User@Host:"):
+		if p.hasUser || len(p.stmt) > 0 {
+			p.flush()
+		}
+		p.hasUser = true
+		if m := connectionIDRe.FindStringSubmatch(trimmed); m != nil {
+			p.conn, _ = strconv.ParseUint(m[1], 10, 64)
+		}
+		return nil
+	}
+
+	if len(p.stmt) == 0 {
+		upper := strings.ToUpper(trimmed)
+		switch {
+		case trimmed == "":
+			return nil
+		case strings.HasPrefix(trimmed, "#"):
+			if m := queryTimeRe.FindStringSubmatch(trimmed); m != nil {
+				seconds, err := strconv.ParseFloat(m[1], 64)
+				if err != nil {
+					return fmt.Errorf("line %d: invalid Query_time %q", lineNo, m[1])
+				}
+				p.queryTime = time.Duration(seconds * float64(time.Second))
+			}
+			return nil
+		case strings.HasPrefix(upper, "USE ") && strings.HasSuffix(trimmed, ";"):
+			p.database = strings.Trim(strings.TrimSpace(strings.TrimSuffix(trimmed[4:], ";")), "`")
+			return nil
+		case strings.HasPrefix(upper, "SET TIMESTAMP=") && strings.HasSuffix(trimmed, ";"):
+			if unix, err := strconv.ParseInt(strings.TrimSuffix(trimmed[len("SET TIMESTAMP="):], ";"), 10, 64); err == nil {
+				p.timestamp = time.Unix(unix, 0).UTC()
+			}
+			return nil
+		}
+		p.stmtLine = lineNo
+	}
+	p.stmt = append(p.stmt, line)
+	return nil
+}
+
+// flush records the entry read so far and starts the next one
+func (p *slowLogParser) flush() {
+	sql := strings.TrimSpace(strings.Join(p.stmt, "\n"))
+	sql = strings.TrimSpace(strings.TrimSuffix(sql, ";"))
+	if sql != "" {
+		// Prefer # Time:, which has microseconds since MySQL 5.7
+		t := p.time
+		if t.IsZero() {
+			t = p.timestamp
+		}
+		if t.IsZero() {
+			t = p.lastTime
+		}
+		p.lastTime = t
+
+		if p.database != "" && p.database != p.emittedDB {
+			p.emittedDB = p.database
+			p.events = append(p.events, Event{SQL: "USE `" + p.database + "`", Database: p.database, Line: p.stmtLine,
+				Setup: true, Time: t, Connection: p.conn})
+		}
+		p.events = append(p.events, Event{SQL: sql, Database: p.database, Line: p.stmtLine,
+			Time: t, QueryTime: p.queryTime, Connection: p.conn})
+	}
+	p.time, p.timestamp, p.hasUser, p.queryTime, p.conn, p.stmt = time.Time{}, time.Time{}, false, 0, 0, nil
+}
+
+// parseSlowLogTime parses the value of a "# Time:" line: an RFC 3339 timestamp
+// since MySQL 5.7, or "YYMMDD H:MM:SS" in server local time before
+func parseSlowLogTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("060102 15:04:05", strings.Join(strings.Fields(value), " "), time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid slow log time %q", value)
+}
@@ -0,0 +1,69 @@
+package sqllog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSlowLog(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "slow.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := ParseSlowLog(f)
+	if err != nil {
+		t.Fatalf("ParseSlowLog() error = %v", err)
+	}
+
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	want := []Event{
+		{SQL: "USE `shop`", Database: "shop", Line: 9, Setup: true, Time: at("2024-01-15T10:30:00.25Z"), Connection: 12},
+		{SQL: "SELECT COUNT(*) FROM orders WHERE status = 'open'", Database: "shop", Line: 9,
+			Time: at("2024-01-15T10:30:00.25Z"), QueryTime: 250 * time.Millisecond, Connection: 12},
+		{SQL: "UPDATE orders\nSET status = 'late; check'\nWHERE id IN (1, 2, 3)", Database: "shop", Line: 14,
+			Time: at("2024-01-15T10:30:01.5Z"), QueryTime: time.Second, Connection: 13},
+		// No # Time: line (as MySQL 5.6 writes within the same second); SET timestamp stands in
+		{SQL: "USE `audit`", Database: "audit", Line: 29, Setup: true, Time: at("2024-01-15T10:30:05Z"), Connection: 14},
+		{SQL: "SELECT * FROM log LIMIT 10", Database: "audit", Line: 29,
+			Time: at("2024-01-15T10:30:05Z"), QueryTime: 500 * time.Millisecond, Connection: 14},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("ParseSlowLog() =\n%+v\nwant\n%+v", events, want)
+	}
+}
+
+func TestParseSlowLog_Errors(t *testing.T) {
+	_, err := ParseSlowLog(strings.NewReader("# Time: yesterday\nSELECT 1;\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1: invalid slow log time") {
+		t.Errorf("ParseSlowLog() error = %v, want an invalid time on line 1", err)
+	}
+}
+
+func TestParseSlowLogTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-01-15T10:30:00.123456Z", time.Date(2024, 1, 15, 10, 30, 0, 123456000, time.UTC)},
+		{"2024-01-15T10:30:00.123456+01:00", time.Date(2024, 1, 15, 9, 30, 0, 123456000, time.UTC)},
+		{"240115 10:30:00", time.Date(2024, 1, 15, 10, 30, 0, 0, time.Local)},
+		{"240115  9:05:00", time.Date(2024, 1, 15, 9, 5, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := parseSlowLogTime(tt.in)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSlowLogTime(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}